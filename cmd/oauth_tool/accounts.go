@@ -0,0 +1,212 @@
+/*
+ * 文件作用：运维命令行的账号管理命令
+ * 负责功能：
+ *   - 账号列表/详情/创建
+ *   - 启用/禁用账号
+ *   - 触发健康检查、使用 SessionKey 刷新 Token
+ * 重要程度：⭐⭐ 辅助（运维工具）
+ * 依赖模块：无
+ */
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// accountItem 账号列表项（只解析需要展示的字段）
+type accountItem struct {
+	ID                 uint       `json:"id"`
+	Name               string     `json:"name"`
+	Type               string     `json:"type"`
+	Platform           string     `json:"platform"`
+	Status             string     `json:"status"`
+	Enabled            bool       `json:"enabled"`
+	Priority           int        `json:"priority"`
+	TokenExpiry        *time.Time `json:"token_expiry"`
+	LastUsedAt         *time.Time `json:"last_used_at"`
+	LastError          string     `json:"last_error"`
+	TodayCount         int64      `json:"today_count"`
+	TodayCost          float64    `json:"today_cost"`
+	CurrentConcurrency int64      `json:"current_concurrency"`
+	MaxConcurrency     int        `json:"max_concurrency"`
+}
+
+func runAccounts(app *cli, args []string) error {
+	return dispatch(app, "accounts", args, map[string]command{
+		"list":          accountsList,
+		"get":           accountsGet,
+		"create":        accountsCreate,
+		"enable":        accountsSetStatus("valid"),
+		"disable":       accountsSetStatus("disabled"),
+		"check":         accountsCheck,
+		"refresh-token": accountsRefreshToken,
+	})
+}
+
+// accountsList 账号列表
+func accountsList(app *cli, args []string) error {
+	fs := flag.NewFlagSet("accounts list", flag.ExitOnError)
+	platform := fs.String("platform", "", "平台: claude/openai/gemini/other")
+	status := fs.String("status", "", "状态: valid/rate_limited/token_expired/suspended/banned/disabled ...")
+	page := fs.Int("page", 1, "页码")
+	size := fs.Int("size", 100, "每页数量")
+	fs.Parse(args)
+
+	var result struct {
+		Items []json.RawMessage `json:"items"`
+		Total int64             `json:"total"`
+	}
+	err := app.client.get("/api/admin/accounts", map[string]string{
+		"platform":  *platform,
+		"status":    *status,
+		"page":      strconv.Itoa(*page),
+		"page_size": strconv.Itoa(*size),
+	}, &result)
+	if err != nil {
+		return err
+	}
+
+	return app.output(result, func() {
+		t := newTable("ID", "名称", "类型", "状态", "启用", "优先级", "并发", "今日请求", "今日费用", "Token 过期", "最后错误")
+		for _, raw := range result.Items {
+			var a accountItem
+			json.Unmarshal(raw, &a)
+			t.row(a.ID, a.Name, a.Type, a.Status, a.Enabled, a.Priority,
+				fmt.Sprintf("%d/%d", a.CurrentConcurrency, a.MaxConcurrency),
+				a.TodayCount, fmt.Sprintf("$%.4f", a.TodayCost), a.TokenExpiry, truncate(a.LastError, 40))
+		}
+		t.flush()
+		fmt.Printf("\n共 %d 个账号\n", result.Total)
+	})
+}
+
+// accountsGet 账号详情
+func accountsGet(app *cli, args []string) error {
+	id, err := parseID(args)
+	if err != nil {
+		return err
+	}
+	var account map[string]interface{}
+	if err := app.client.get("/api/admin/accounts/"+id, nil, &account); err != nil {
+		return err
+	}
+	// 详情默认即以 JSON 展示（字段较多）
+	return printJSON(account)
+}
+
+// accountsCreate 创建账号（从 JSON 文件或命令行参数）
+func accountsCreate(app *cli, args []string) error {
+	fs := flag.NewFlagSet("accounts create", flag.ExitOnError)
+	file := fs.String("f", "", "账号 JSON 文件（字段同管理接口，- 表示标准输入）")
+	name := fs.String("name", "", "账号名称")
+	accountType := fs.String("type", "", "账号类型，如 claude-official / claude-console / openai / gemini-api")
+	apiKey := fs.String("api-key", "", "API Key")
+	accessToken := fs.String("access-token", "", "Access Token")
+	refreshToken := fs.String("refresh-token", "", "Refresh Token")
+	sessionKey := fs.String("session-key", "", "Claude SessionKey")
+	baseURL := fs.String("base-url", "", "自定义 Base URL")
+	proxyID := fs.Uint("proxy-id", 0, "代理 ID")
+	priority := fs.Int("priority", 50, "优先级 1-100")
+	maxConcurrency := fs.Int("max-concurrency", 5, "最大并发数")
+	fs.Parse(args)
+
+	var req map[string]interface{}
+	if *file != "" {
+		data, err := readInput(*file)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, &req); err != nil {
+			return fmt.Errorf("解析账号文件失败: %w", err)
+		}
+	} else {
+		if *name == "" || *accountType == "" {
+			return fmt.Errorf("请通过 -f 指定账号文件，或至少指定 -name 与 -type")
+		}
+		req = map[string]interface{}{
+			"name":            *name,
+			"type":            *accountType,
+			"enabled":         true,
+			"priority":        *priority,
+			"max_concurrency": *maxConcurrency,
+			"api_key":         *apiKey,
+			"access_token":    *accessToken,
+			"refresh_token":   *refreshToken,
+			"session_key":     *sessionKey,
+			"base_url":        *baseURL,
+		}
+		if *proxyID > 0 {
+			req["proxy_id"] = *proxyID
+		}
+	}
+
+	var account accountItem
+	if err := app.client.post("/api/admin/accounts", req, &account); err != nil {
+		return err
+	}
+	return app.message(account, "账号已创建: #%d %s (%s)", account.ID, account.Name, account.Type)
+}
+
+// accountsSetStatus 启用/禁用账号
+func accountsSetStatus(status string) command {
+	return func(app *cli, args []string) error {
+		id, err := parseID(args)
+		if err != nil {
+			return err
+		}
+		if err := app.client.put("/api/admin/accounts/"+id+"/status", obj{"status": status}, nil); err != nil {
+			return err
+		}
+		return app.message(obj{"id": id, "status": status}, "账号 #%s 状态已更新为 %s", id, status)
+	}
+}
+
+// accountsCheck 触发健康检查（单个账号同步返回结果，-all 为后台全局检查）
+func accountsCheck(app *cli, args []string) error {
+	fs := flag.NewFlagSet("accounts check", flag.ExitOnError)
+	all := fs.Bool("all", false, "触发全局健康检查")
+	fs.Parse(args)
+
+	if *all {
+		if err := app.client.post("/api/admin/health-check/trigger", nil, nil); err != nil {
+			return err
+		}
+		return app.message(obj{"triggered": true}, "全局健康检查已触发，可通过 health status 查看进度")
+	}
+
+	id, err := parseID(fs.Args())
+	if err != nil {
+		return err
+	}
+	var result struct {
+		Healthy bool   `json:"healthy"`
+		Message string `json:"message"`
+	}
+	if err := app.client.post("/api/admin/accounts/"+id+"/health-check", nil, &result); err != nil {
+		return err
+	}
+	if err := app.message(result, "账号 #%s: %s", id, result.Message); err != nil {
+		return err
+	}
+	if !result.Healthy {
+		os.Exit(1)
+	}
+	return nil
+}
+
+// accountsRefreshToken 使用账号保存的 SessionKey 重新走 OAuth 授权并更新 Token
+func accountsRefreshToken(app *cli, args []string) error {
+	id, err := parseID(args)
+	if err != nil {
+		return err
+	}
+	if err := app.client.post("/api/admin/accounts/"+id+"/refresh-token", nil, nil); err != nil {
+		return err
+	}
+	return app.message(obj{"id": id, "refreshed": true}, "账号 #%s Token 已刷新", id)
+}
@@ -0,0 +1,109 @@
+/*
+ * 文件作用：运维命令行的登录/登出命令
+ * 负责功能：
+ *   - 管理员登录（支持验证码）并保存凭证
+ *   - 删除本地凭证
+ * 重要程度：⭐⭐ 辅助（运维工具）
+ * 依赖模块：无
+ */
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// runLogin 登录
+func runLogin(app *cli, args []string) error {
+	fs := flag.NewFlagSet("login", flag.ExitOnError)
+	username := fs.String("u", "admin", "用户名")
+	password := fs.String("p", "", "密码（默认读取 CLI_PROXY_PASSWORD 或交互输入）")
+	fs.Parse(args)
+
+	stdin := bufio.NewReader(os.Stdin)
+	if *password == "" {
+		*password = os.Getenv("CLI_PROXY_PASSWORD")
+	}
+	if *password == "" {
+		*password = prompt(stdin, "密码: ")
+	}
+
+	req := map[string]string{"username": *username, "password": *password}
+
+	// 启用验证码时将图片保存到临时文件，由用户查看后输入
+	var captcha struct {
+		CaptchaID string `json:"captcha_id"`
+		Image     string `json:"image"`
+		Enabled   bool   `json:"enabled"`
+	}
+	if err := app.client.get("/api/auth/captcha", nil, &captcha); err == nil && captcha.Enabled && captcha.CaptchaID != "" {
+		path, err := saveCaptchaImage(captcha.Image)
+		if err != nil {
+			return fmt.Errorf("保存验证码图片失败: %w", err)
+		}
+		fmt.Fprintf(os.Stderr, "验证码图片已保存到 %s\n", path)
+		req["captcha_id"] = captcha.CaptchaID
+		req["captcha_code"] = prompt(stdin, "验证码: ")
+		defer os.Remove(path)
+	}
+
+	var result struct {
+		Token              string `json:"token"`
+		MustChangePassword bool   `json:"must_change_password"`
+	}
+	if err := app.client.post("/api/auth/login", req, &result); err != nil {
+		return err
+	}
+
+	app.creds.Server = app.client.server
+	app.creds.Token = result.Token
+	app.creds.Username = *username
+	app.creds.LoginAt = time.Now()
+	if err := saveCredentials(app.creds); err != nil {
+		return fmt.Errorf("保存凭证失败: %w", err)
+	}
+
+	if result.MustChangePassword {
+		fmt.Fprintln(os.Stderr, "提示: 当前为初始密码，需先在管理后台修改密码后才能调用其他管理接口")
+	}
+	return app.message(obj{"server": app.creds.Server, "username": *username},
+		"登录成功: %s@%s（凭证已保存到 %s）", *username, app.creds.Server, credentialsPath())
+}
+
+// runLogout 删除本地凭证
+func runLogout(app *cli, args []string) error {
+	if err := os.Remove(credentialsPath()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return app.message(obj{"logged_out": true}, "已删除本地凭证")
+}
+
+// saveCaptchaImage 将 data URL 格式的验证码图片写入临时文件
+func saveCaptchaImage(image string) (string, error) {
+	ext := ".png"
+	if idx := strings.Index(image, ","); idx >= 0 {
+		if strings.Contains(image[:idx], "svg") {
+			ext = ".svg"
+		}
+		image = image[idx+1:]
+	}
+	data, err := base64.StdEncoding.DecodeString(image)
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("cli-proxy-captcha-%d%s", time.Now().UnixNano(), ext))
+	return path, os.WriteFile(path, data, 0o600)
+}
+
+// prompt 从标准输入读取一行
+func prompt(reader *bufio.Reader, label string) string {
+	fmt.Fprint(os.Stderr, label)
+	line, _ := reader.ReadString('\n')
+	return strings.TrimSpace(line)
+}
@@ -0,0 +1,187 @@
+/*
+ * 文件作用：运维命令行的管理接口客户端
+ * 负责功能：
+ *   - 封装管理接口请求（JWT 认证、统一响应解析）
+ *   - 登录凭证的本地保存与读取
+ * 重要程度：⭐⭐ 辅助（运维工具）
+ * 依赖模块：无
+ */
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// defaultServer 默认服务地址
+const defaultServer = "http://127.0.0.1:8080"
+
+// credentials 本地保存的登录凭证
+type credentials struct {
+	Server   string    `json:"server"`
+	Token    string    `json:"token"`
+	Username string    `json:"username"`
+	LoginAt  time.Time `json:"login_at"`
+}
+
+// credentialsPath 凭证文件路径（~/.cli-proxy/credentials.json）
+func credentialsPath() string {
+	if path := os.Getenv("CLI_PROXY_CREDENTIALS"); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		home = "."
+	}
+	return filepath.Join(home, ".cli-proxy", "credentials.json")
+}
+
+// loadCredentials 读取本地凭证（不存在时返回空凭证）
+func loadCredentials() *credentials {
+	creds := &credentials{}
+	data, err := os.ReadFile(credentialsPath())
+	if err == nil {
+		json.Unmarshal(data, creds)
+	}
+	return creds
+}
+
+// saveCredentials 保存本地凭证（仅当前用户可读）
+func saveCredentials(creds *credentials) error {
+	path := credentialsPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(creds, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+// apiClient 管理接口客户端
+type apiClient struct {
+	server string
+	token  string
+	http   *http.Client
+}
+
+// apiError 接口错误
+type apiError struct {
+	Status  int
+	Message string
+}
+
+func (e *apiError) Error() string {
+	if e.Status == http.StatusUnauthorized {
+		return fmt.Sprintf("未登录或登录已过期（%s），请先执行 login", e.Message)
+	}
+	return fmt.Sprintf("HTTP %d: %s", e.Status, e.Message)
+}
+
+func newAPIClient(server, token string) *apiClient {
+	return &apiClient{
+		server: strings.TrimRight(server, "/"),
+		token:  token,
+		http:   &http.Client{Timeout: 5 * time.Minute},
+	}
+}
+
+// get 发送 GET 请求，query 中的空值会被忽略
+func (c *apiClient) get(path string, query map[string]string, out interface{}) error {
+	if len(query) > 0 {
+		values := url.Values{}
+		for k, v := range query {
+			if v != "" {
+				values.Set(k, v)
+			}
+		}
+		if encoded := values.Encode(); encoded != "" {
+			path += "?" + encoded
+		}
+	}
+	return c.do(http.MethodGet, path, nil, out)
+}
+
+// post 发送 POST 请求
+func (c *apiClient) post(path string, body, out interface{}) error {
+	return c.do(http.MethodPost, path, body, out)
+}
+
+// put 发送 PUT 请求
+func (c *apiClient) put(path string, body, out interface{}) error {
+	return c.do(http.MethodPut, path, body, out)
+}
+
+// do 发送请求并解析响应
+// 管理接口大多使用 {code, message, data} 包装，部分接口直接返回 {data} 或 {error}，统一解出 data 部分
+func (c *apiClient) do(method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, c.server+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("请求 %s 失败: %w", c.server, err)
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	var envelope struct {
+		Code    *int            `json:"code"`
+		Message string          `json:"message"`
+		Error   string          `json:"error"`
+		Data    json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(raw, &envelope); err != nil {
+		if resp.StatusCode >= 400 {
+			return &apiError{Status: resp.StatusCode, Message: strings.TrimSpace(string(raw))}
+		}
+		return fmt.Errorf("无法解析响应: %w", err)
+	}
+
+	if resp.StatusCode >= 400 || (envelope.Code != nil && *envelope.Code != 0) {
+		message := envelope.Message
+		if envelope.Error != "" {
+			message = envelope.Error
+		}
+		return &apiError{Status: resp.StatusCode, Message: message}
+	}
+
+	if out == nil {
+		return nil
+	}
+	payload := []byte(envelope.Data)
+	if len(payload) == 0 {
+		payload = raw
+	}
+	return json.Unmarshal(payload, out)
+}
@@ -0,0 +1,156 @@
+/*
+ * 文件作用：运维命令行的 API Key 管理命令
+ * 负责功能：
+ *   - API Key 列表/创建
+ *   - 启用/禁用 API Key
+ * 重要程度：⭐⭐ 辅助（运维工具）
+ * 依赖模块：无
+ */
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// apiKeyItem API Key 列表项
+type apiKeyItem struct {
+	ID               uint       `json:"id"`
+	Name             string     `json:"name"`
+	KeyPrefix        string     `json:"key_prefix"`
+	Status           string     `json:"status"`
+	AllowedPlatforms string     `json:"allowed_platforms"`
+	RateLimit        int        `json:"rate_limit"`
+	DailyLimit       int        `json:"daily_limit"`
+	MonthlyQuota     float64    `json:"monthly_quota"`
+	RequestCount     int64      `json:"request_count"`
+	CostUsed         float64    `json:"cost_used"`
+	ExpiresAt        *time.Time `json:"expires_at"`
+	LastUsedAt       *time.Time `json:"last_used_at"`
+}
+
+func runKeys(app *cli, args []string) error {
+	return dispatch(app, "keys", args, map[string]command{
+		"list":    keysList,
+		"create":  keysCreate,
+		"enable":  keysSetStatus("active"),
+		"disable": keysSetStatus("disabled"),
+	})
+}
+
+// keysList API Key 列表
+func keysList(app *cli, args []string) error {
+	fs := flag.NewFlagSet("keys list", flag.ExitOnError)
+	page := fs.Int("page", 1, "页码")
+	size := fs.Int("size", 100, "每页数量（最大 100）")
+	fs.Parse(args)
+
+	var result struct {
+		Items []apiKeyItem `json:"items"`
+		Total int64        `json:"total"`
+	}
+	err := app.client.get("/api/admin/api-keys", map[string]string{
+		"page":      strconv.Itoa(*page),
+		"page_size": strconv.Itoa(*size),
+	}, &result)
+	if err != nil {
+		return err
+	}
+
+	return app.output(result, func() {
+		t := newTable("ID", "名称", "前缀", "状态", "平台", "RPM", "日限", "月额度", "请求数", "已用费用", "过期时间", "最后使用")
+		for _, k := range result.Items {
+			t.row(k.ID, k.Name, k.KeyPrefix, k.Status, k.AllowedPlatforms, k.RateLimit, k.DailyLimit,
+				k.MonthlyQuota, k.RequestCount, fmt.Sprintf("$%.4f", k.CostUsed), k.ExpiresAt, k.LastUsedAt)
+		}
+		t.flush()
+		fmt.Printf("\n共 %d 个 API Key\n", result.Total)
+	})
+}
+
+// keysCreate 创建 API Key
+func keysCreate(app *cli, args []string) error {
+	fs := flag.NewFlagSet("keys create", flag.ExitOnError)
+	name := fs.String("name", "", "名称（必填）")
+	description := fs.String("desc", "", "描述")
+	platforms := fs.String("platforms", "all", "允许的平台，逗号分隔")
+	models := fs.String("models", "", "允许的模型，逗号分隔")
+	rateLimit := fs.Int("rpm", 0, "每分钟请求限制（0 不限）")
+	dailyLimit := fs.Int("daily", 0, "每日请求限制（0 不限）")
+	quota := fs.Float64("quota", 0, "月额度（美元，0 不限）")
+	expires := fs.String("expires", "", "过期时间（RFC3339 或 30d 这样的有效期）")
+	fs.Parse(args)
+
+	if *name == "" {
+		return fmt.Errorf("请通过 -name 指定名称")
+	}
+	req := obj{
+		"name":              *name,
+		"description":       *description,
+		"allowed_platforms": *platforms,
+		"allowed_models":    *models,
+		"rate_limit":        *rateLimit,
+		"daily_limit":       *dailyLimit,
+		"monthly_quota":     *quota,
+	}
+	if *expires != "" {
+		expiresAt, err := parseExpiry(*expires)
+		if err != nil {
+			return err
+		}
+		req["expires_at"] = expiresAt
+	}
+
+	var result struct {
+		ID        uint   `json:"id"`
+		Name      string `json:"name"`
+		Key       string `json:"key"`
+		KeyPrefix string `json:"key_prefix"`
+	}
+	if err := app.client.post("/api/admin/api-keys", req, &result); err != nil {
+		return err
+	}
+	if err := app.message(result, "API Key 已创建: #%d %s\n%s", result.ID, result.Name, result.Key); err != nil {
+		return err
+	}
+	if !app.json {
+		fmt.Fprintln(os.Stderr, "完整 Key 只显示这一次，请妥善保存")
+	}
+	return nil
+}
+
+// keysSetStatus 启用/禁用 API Key（直接设置目标状态，不依赖当前状态，禁用已暂停的 Key 不会将其恢复）
+func keysSetStatus(status string) command {
+	return func(app *cli, args []string) error {
+		id, err := parseID(args)
+		if err != nil {
+			return err
+		}
+		var result struct {
+			Status string `json:"status"`
+		}
+		if err := app.client.put("/api/admin/api-keys/"+id+"/status", obj{"status": status}, &result); err != nil {
+			return err
+		}
+		return app.message(obj{"id": id, "status": result.Status}, "API Key #%s 当前状态: %s", id, result.Status)
+	}
+}
+
+// parseExpiry 解析过期时间（支持 RFC3339 与 Nd/Nh 形式的有效期）
+func parseExpiry(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if n := len(s); n > 1 && s[n-1] == 'd' {
+		if days, err := strconv.Atoi(s[:n-1]); err == nil && days > 0 {
+			return time.Now().AddDate(0, 0, days), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil && d > 0 {
+		return time.Now().Add(d), nil
+	}
+	return time.Time{}, fmt.Errorf("无效的过期时间: %s", s)
+}
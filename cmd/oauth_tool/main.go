@@ -1,30 +1,180 @@
+/*
+ * 文件作用：运维命令行入口，通过管理接口操作运行中的服务
+ * 负责功能：
+ *   - 全局参数解析（服务地址、Token、JSON 输出）
+ *   - 子命令分发（登录、账号、API Key、OAuth、健康检查、日志、用量）
+ * 重要程度：⭐⭐ 辅助（运维工具）
+ * 依赖模块：无（仅通过 HTTP 调用管理接口）
+ */
 package main
 
 import (
+	"flag"
 	"fmt"
+	"io"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
 )
 
-const (
-	ClaudeAIURL      = "https://claude.ai"
-	OAuthClientID    = "9d1c250a-e61b-44d9-88ed-5944d1962f5e"
-	OAuthRedirectURI = "https://console.anthropic.com/oauth/code/callback"
-	OAuthTokenURL    = "https://console.anthropic.com/v1/oauth/token"
-)
+const usage = `cli-proxy 运维工具
+
+用法:
+  oauth_tool [全局参数] <命令> [子命令] [参数]
+
+全局参数:
+  -server URL   服务地址（默认取登录时保存的地址，或环境变量 CLI_PROXY_SERVER）
+  -token TOKEN  管理员 JWT（默认取登录时保存的 Token，或环境变量 CLI_PROXY_TOKEN）
+  -json         以 JSON 输出，便于脚本处理
+
+命令:
+  login   [-u 用户名] [-p 密码]               登录并保存凭证
+  logout                                     删除本地凭证
+
+  accounts list [-platform P] [-status S]    账号列表
+  accounts get <id>                          账号详情
+  accounts create -f file.json | -name N -type T [...]
+                                             创建账号
+  accounts enable <id> / disable <id>        启用 / 禁用账号
+  accounts check <id> | -all                 触发健康检查
+  accounts refresh-token <id>                使用 SessionKey 重新走 OAuth 授权并更新 Token
+
+  keys list                                  API Key 列表
+  keys create -name N [...]                  创建 API Key（完整 Key 只显示一次）
+  keys enable <id> / disable <id>            启用 / 禁用 API Key
+
+  oauth session-key [-session-key K] [-create -name N]
+                                             SessionKey → OAuth Token（可直接创建账号）
+
+  health status                              健康检查服务状态
+
+  logs tail [-n 20] [-f] [-failed] [...]     查看（持续跟踪）请求日志
+
+  usage summary|daily|models|keys [...]      用量报表
+
+环境变量:
+  ANTHROPIC_SESSION_KEY   oauth session-key 的默认 SessionKey
+  CLI_PROXY_PASSWORD      login 的默认密码
+  CLI_PROXY_CREDENTIALS   凭证文件路径（默认 ~/.cli-proxy/credentials.json）
+`
+
+// cli 命令行上下文
+type cli struct {
+	client *apiClient
+	creds  *credentials
+	json   bool
+}
+
+// command 子命令处理函数
+type command func(app *cli, args []string) error
+
+var commands = map[string]command{
+	"login":    runLogin,
+	"logout":   runLogout,
+	"accounts": runAccounts,
+	"keys":     runKeys,
+	"oauth":    runOAuth,
+	"health":   runHealth,
+	"logs":     runLogs,
+	"usage":    runUsage,
+}
 
 func main() {
-	// 从环境变量获取 Session Key
-	sessionKey := os.Getenv("ANTHROPIC_SESSION_KEY")
-	if sessionKey == "" {
-		fmt.Println("错误: 请设置环境变量 ANTHROPIC_SESSION_KEY")
-		fmt.Println("\n使用方法:")
-		fmt.Println("  export ANTHROPIC_SESSION_KEY='your-session-key'")
-		fmt.Println("  ./oauth_tool")
-		return
-	}
-
-	fmt.Println("OAuth 工具已准备就绪")
-	fmt.Printf("Session Key: %s...%s\n", sessionKey[:10], sessionKey[len(sessionKey)-10:])
-	fmt.Println("\n注意: 此工具需要完整实现才能使用")
-	fmt.Println("当前版本仅作为示例")
+	fs := flag.NewFlagSet("oauth_tool", flag.ExitOnError)
+	fs.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	server := fs.String("server", "", "服务地址")
+	token := fs.String("token", "", "管理员 JWT")
+	jsonOutput := fs.Bool("json", false, "以 JSON 输出")
+	fs.Parse(os.Args[1:])
+
+	args := fs.Args()
+	if len(args) == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	run, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "未知命令: %s\n\n", args[0])
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	creds := loadCredentials()
+	app := &cli{creds: creds, json: *jsonOutput}
+	app.client = newAPIClient(firstNonEmpty(*server, os.Getenv("CLI_PROXY_SERVER"), creds.Server, defaultServer),
+		firstNonEmpty(*token, os.Getenv("CLI_PROXY_TOKEN"), creds.Token))
+
+	if err := run(app, args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// dispatch 分发二级子命令
+func dispatch(app *cli, group string, args []string, subs map[string]command) error {
+	if len(args) == 0 {
+		return fmt.Errorf("缺少子命令，可用: %s", subcommandNames(subs))
+	}
+	run, ok := subs[args[0]]
+	if !ok {
+		return fmt.Errorf("未知子命令 %s %s，可用: %s", group, args[0], subcommandNames(subs))
+	}
+	return run(app, args[1:])
+}
+
+// output 按输出模式打印：JSON 模式直接输出数据，否则调用表格输出函数
+func (app *cli) output(data interface{}, human func()) error {
+	if app.json {
+		return printJSON(data)
+	}
+	human()
+	return nil
+}
+
+// message 输出操作结果
+func (app *cli) message(data interface{}, format string, args ...interface{}) error {
+	return app.output(data, func() { fmt.Printf(format+"\n", args...) })
+}
+
+// obj JSON 对象简写
+type obj = map[string]interface{}
+
+// firstNonEmpty 返回第一个非空字符串
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// subcommandNames 子命令名称列表（排序后用于提示）
+func subcommandNames(subs map[string]command) string {
+	names := make([]string, 0, len(subs))
+	for name := range subs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// parseID 解析位置参数中的数字 ID
+func parseID(args []string) (string, error) {
+	if len(args) == 0 {
+		return "", fmt.Errorf("缺少 ID 参数")
+	}
+	if _, err := strconv.ParseUint(args[0], 10, 32); err != nil {
+		return "", fmt.Errorf("无效的 ID: %s", args[0])
+	}
+	return args[0], nil
+}
+
+// readInput 读取文件内容（- 表示标准输入）
+func readInput(path string) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(path)
 }
@@ -0,0 +1,79 @@
+/*
+ * 文件作用：运维命令行的 OAuth 命令
+ * 负责功能：
+ *   - 使用 Claude SessionKey 换取 OAuth Token（由服务端执行授权流程，走服务端默认代理）
+ *   - 可选直接创建 claude-official 账号
+ * 重要程度：⭐⭐ 辅助（运维工具）
+ * 依赖模块：无
+ */
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+func runOAuth(app *cli, args []string) error {
+	return dispatch(app, "oauth", args, map[string]command{
+		"session-key": oauthSessionKey,
+	})
+}
+
+// oauthSessionKey SessionKey → OAuth Token
+func oauthSessionKey(app *cli, args []string) error {
+	fs := flag.NewFlagSet("oauth session-key", flag.ExitOnError)
+	sessionKey := fs.String("session-key", os.Getenv("ANTHROPIC_SESSION_KEY"), "Claude SessionKey（默认读取 ANTHROPIC_SESSION_KEY）")
+	create := fs.Bool("create", false, "授权成功后直接创建 claude-official 账号")
+	name := fs.String("name", "", "创建账号时的名称（默认使用组织 UUID）")
+	showTokens := fs.Bool("show-tokens", false, "输出完整 Token（默认遮蔽）")
+	fs.Parse(args)
+
+	if *sessionKey == "" {
+		return fmt.Errorf("请通过 -session-key 或环境变量 ANTHROPIC_SESSION_KEY 指定 SessionKey")
+	}
+
+	var tokens map[string]interface{}
+	err := app.client.post("/api/admin/oauth/cookie-auth", obj{
+		"platform":    "claude",
+		"session_key": *sessionKey,
+	}, &tokens)
+	if err != nil {
+		return err
+	}
+
+	accessToken, _ := tokens["access_token"].(string)
+	refreshToken, _ := tokens["refresh_token"].(string)
+	orgUUID, _ := tokens["organization_uuid"].(string)
+
+	if *create {
+		accountName := *name
+		if accountName == "" {
+			accountName = "claude-" + orgUUID
+		}
+		var account accountItem
+		err := app.client.post("/api/admin/accounts", obj{
+			"name":            accountName,
+			"type":            "claude-official",
+			"enabled":         true,
+			"access_token":    accessToken,
+			"refresh_token":   refreshToken,
+			"session_key":     *sessionKey,
+			"organization_id": orgUUID,
+			"auth_type":       "oauth",
+		}, &account)
+		if err != nil {
+			return fmt.Errorf("授权成功，但创建账号失败: %w", err)
+		}
+		return app.message(account, "授权成功，已创建账号 #%d %s（组织 %s）", account.ID, account.Name, orgUUID)
+	}
+
+	if !*showTokens {
+		tokens["access_token"] = maskSecret(accessToken)
+		tokens["refresh_token"] = maskSecret(refreshToken)
+	}
+	return app.output(tokens, func() {
+		fmt.Printf("授权成功\n  组织 UUID:     %s\n  Access Token:  %v\n  Refresh Token: %v\n  有效期:        %v 秒\n",
+			orgUUID, tokens["access_token"], tokens["refresh_token"], tokens["expires_in"])
+	})
+}
@@ -0,0 +1,101 @@
+/*
+ * 文件作用：运维命令行的输出格式化
+ * 负责功能：
+ *   - JSON 输出（便于脚本处理）
+ *   - 表格输出（便于人工查看）
+ * 重要程度：⭐⭐ 辅助（运维工具）
+ * 依赖模块：无
+ */
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// printJSON 以缩进 JSON 输出
+func printJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(v)
+}
+
+// table 表格输出
+type table struct {
+	w *tabwriter.Writer
+}
+
+func newTable(headers ...string) *table {
+	t := &table{w: tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)}
+	fmt.Fprintln(t.w, strings.Join(headers, "\t"))
+	return t
+}
+
+func (t *table) row(values ...interface{}) {
+	cells := make([]string, len(values))
+	for i, v := range values {
+		cells[i] = formatCell(v)
+	}
+	fmt.Fprintln(t.w, strings.Join(cells, "\t"))
+}
+
+func (t *table) flush() {
+	t.w.Flush()
+}
+
+// formatCell 格式化单元格
+func formatCell(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return "-"
+	case string:
+		if val == "" {
+			return "-"
+		}
+		return val
+	case bool:
+		if val {
+			return "yes"
+		}
+		return "no"
+	case float64:
+		if val == float64(int64(val)) {
+			return fmt.Sprintf("%d", int64(val))
+		}
+		return fmt.Sprintf("%.4f", val)
+	case *time.Time:
+		if val == nil {
+			return "-"
+		}
+		return val.Local().Format("2006-01-02 15:04:05")
+	case time.Time:
+		if val.IsZero() {
+			return "-"
+		}
+		return val.Local().Format("2006-01-02 15:04:05")
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// truncate 截断长文本
+func truncate(s string, max int) string {
+	s = strings.ReplaceAll(s, "\n", " ")
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max-1]) + "…"
+}
+
+// maskSecret 遮蔽敏感字符串
+func maskSecret(s string) string {
+	if len(s) <= 12 {
+		return strings.Repeat("*", len(s))
+	}
+	return s[:6] + "..." + s[len(s)-4:]
+}
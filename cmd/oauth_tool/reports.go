@@ -0,0 +1,292 @@
+/*
+ * 文件作用：运维命令行的查询类命令
+ * 负责功能：
+ *   - 健康检查服务状态
+ *   - 请求日志查看与持续跟踪（tail -f）
+ *   - 用量报表（汇总/每日/模型/API Key）
+ * 重要程度：⭐⭐ 辅助（运维工具）
+ * 依赖模块：无
+ */
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"time"
+)
+
+// requestLogItem 请求日志项
+type requestLogItem struct {
+	ID          uint      `json:"id"`
+	AccountID   uint      `json:"account_id"`
+	APIKeyID    *uint     `json:"api_key_id"`
+	Platform    string    `json:"platform"`
+	Model       string    `json:"model"`
+	Path        string    `json:"path"`
+	RequestIP   string    `json:"request_ip"`
+	StatusCode  int       `json:"status_code"`
+	Success     bool      `json:"success"`
+	Error       string    `json:"error"`
+	Duration    int64     `json:"duration"`
+	TotalTokens int       `json:"total_tokens"`
+	TotalCost   float64   `json:"total_cost"`
+	CacheStatus string    `json:"cache_status"`
+	CreatedAt   time.Time `json:"created_at"`
+	Account     *struct {
+		Name string `json:"name"`
+	} `json:"account"`
+}
+
+// runHealth 健康检查服务
+func runHealth(app *cli, args []string) error {
+	return dispatch(app, "health", args, map[string]command{
+		"status": func(app *cli, args []string) error {
+			var status map[string]interface{}
+			if err := app.client.get("/api/admin/health-check/status", nil, &status); err != nil {
+				return err
+			}
+			return printJSON(status)
+		},
+	})
+}
+
+// runLogs 请求日志
+func runLogs(app *cli, args []string) error {
+	return dispatch(app, "logs", args, map[string]command{
+		"tail": logsTail,
+	})
+}
+
+// logsTail 输出最近 N 条请求日志，-f 时轮询输出新日志
+func logsTail(app *cli, args []string) error {
+	fs := flag.NewFlagSet("logs tail", flag.ExitOnError)
+	n := fs.Int("n", 20, "输出最近 N 条")
+	follow := fs.Bool("f", false, "持续跟踪新日志")
+	interval := fs.Duration("interval", 2*time.Second, "跟踪时的轮询间隔")
+	accountID := fs.Uint("account", 0, "按账号 ID 过滤")
+	platform := fs.String("platform", "", "按平台过滤")
+	modelName := fs.String("model", "", "按模型过滤")
+	failed := fs.Bool("failed", false, "只看失败请求")
+	fs.Parse(args)
+
+	query := map[string]string{
+		"page":      "1",
+		"page_size": strconv.Itoa(*n),
+		"platform":  *platform,
+		"model":     *modelName,
+	}
+	if *accountID > 0 {
+		query["account_id"] = strconv.FormatUint(uint64(*accountID), 10)
+	}
+	if *failed {
+		query["success"] = "false"
+	}
+
+	fetch := func() ([]requestLogItem, error) {
+		var result struct {
+			Items []requestLogItem `json:"items"`
+		}
+		if err := app.client.get("/api/admin/logs", query, &result); err != nil {
+			return nil, err
+		}
+		return result.Items, nil
+	}
+
+	// JSON 模式逐行输出（JSON Lines），便于管道处理
+	emit := func(item requestLogItem) {
+		if app.json {
+			data, _ := json.Marshal(item)
+			fmt.Println(string(data))
+			return
+		}
+		status := "OK "
+		if !item.Success {
+			status = "ERR"
+		}
+		account := strconv.FormatUint(uint64(item.AccountID), 10)
+		if item.Account != nil && item.Account.Name != "" {
+			account = item.Account.Name
+		}
+		apiKey := "-"
+		if item.APIKeyID != nil {
+			apiKey = strconv.FormatUint(uint64(*item.APIKeyID), 10)
+		}
+		line := fmt.Sprintf("%s #%d %s %d %-28s acct=%s key=%s %6dms tok=%d $%.4f",
+			item.CreatedAt.Local().Format("01-02 15:04:05"), item.ID, status, item.StatusCode,
+			truncate(item.Model, 28), account, apiKey, item.Duration, item.TotalTokens, item.TotalCost)
+		if item.CacheStatus != "" {
+			line += " cache=" + item.CacheStatus
+		}
+		if item.Error != "" {
+			line += " | " + truncate(item.Error, 120)
+		}
+		fmt.Println(line)
+	}
+
+	items, err := fetch()
+	if err != nil {
+		return err
+	}
+	// 接口按时间倒序返回，输出时转为正序
+	var lastID uint
+	for i := len(items) - 1; i >= 0; i-- {
+		emit(items[i])
+		if items[i].ID > lastID {
+			lastID = items[i].ID
+		}
+	}
+	if !*follow {
+		return nil
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt)
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+		}
+		items, err := fetch()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "拉取日志失败: %v\n", err)
+			continue
+		}
+		for i := len(items) - 1; i >= 0; i-- {
+			if items[i].ID > lastID {
+				emit(items[i])
+				lastID = items[i].ID
+			}
+		}
+	}
+}
+
+// runUsage 用量报表
+func runUsage(app *cli, args []string) error {
+	return dispatch(app, "usage", args, map[string]command{
+		"summary": usageSummary,
+		"daily":   usageDaily,
+		"models":  usageModels,
+		"keys":    usageKeys,
+	})
+}
+
+// dateRangeFlags 注册日期范围参数
+func dateRangeFlags(fs *flag.FlagSet) (start, end *string) {
+	start = fs.String("start", "", "开始日期 YYYY-MM-DD")
+	end = fs.String("end", "", "结束日期 YYYY-MM-DD")
+	return
+}
+
+// usageSummary 总用量汇总
+func usageSummary(app *cli, args []string) error {
+	fs := flag.NewFlagSet("usage summary", flag.ExitOnError)
+	start, end := dateRangeFlags(fs)
+	fs.Parse(args)
+
+	var summary struct {
+		TotalRequests int64   `json:"total_requests"`
+		TotalTokens   int64   `json:"total_tokens"`
+		TotalCost     float64 `json:"total_cost"`
+		TodayRequests int64   `json:"today_requests"`
+		TodayTokens   int64   `json:"today_tokens"`
+		TodayCost     float64 `json:"today_cost"`
+	}
+	if err := app.client.get("/api/admin/usage/summary", map[string]string{"start_date": *start, "end_date": *end}, &summary); err != nil {
+		return err
+	}
+	return app.output(summary, func() {
+		t := newTable("范围", "请求数", "Token", "费用")
+		t.row("合计", summary.TotalRequests, summary.TotalTokens, fmt.Sprintf("$%.4f", summary.TotalCost))
+		t.row("今日", summary.TodayRequests, summary.TodayTokens, fmt.Sprintf("$%.4f", summary.TodayCost))
+		t.flush()
+	})
+}
+
+// usageDaily 每日用量
+func usageDaily(app *cli, args []string) error {
+	fs := flag.NewFlagSet("usage daily", flag.ExitOnError)
+	start, end := dateRangeFlags(fs)
+	fs.Parse(args)
+
+	var result struct {
+		StartDate string `json:"start_date"`
+		EndDate   string `json:"end_date"`
+		Daily     []struct {
+			Date         string  `json:"date"`
+			RequestCount int64   `json:"request_count"`
+			TotalTokens  int64   `json:"total_tokens"`
+			TotalCost    float64 `json:"total_cost"`
+		} `json:"daily"`
+	}
+	if err := app.client.get("/api/admin/usage/daily", map[string]string{"start_date": *start, "end_date": *end}, &result); err != nil {
+		return err
+	}
+	return app.output(result, func() {
+		t := newTable("日期", "请求数", "Token", "费用")
+		for _, d := range result.Daily {
+			t.row(d.Date, d.RequestCount, d.TotalTokens, fmt.Sprintf("$%.4f", d.TotalCost))
+		}
+		t.flush()
+	})
+}
+
+// usageModels 按模型汇总
+func usageModels(app *cli, args []string) error {
+	fs := flag.NewFlagSet("usage models", flag.ExitOnError)
+	start, end := dateRangeFlags(fs)
+	fs.Parse(args)
+
+	var result struct {
+		Models []struct {
+			Model        string  `json:"model"`
+			RequestCount int64   `json:"request_count"`
+			TotalTokens  int64   `json:"total_tokens"`
+			TotalCost    float64 `json:"total_cost"`
+		} `json:"models"`
+	}
+	if err := app.client.get("/api/admin/usage/models", map[string]string{"start_date": *start, "end_date": *end}, &result); err != nil {
+		return err
+	}
+	return app.output(result, func() {
+		t := newTable("模型", "请求数", "Token", "费用")
+		for _, m := range result.Models {
+			t.row(m.Model, m.RequestCount, m.TotalTokens, fmt.Sprintf("$%.4f", m.TotalCost))
+		}
+		t.flush()
+	})
+}
+
+// usageKeys 各 API Key 某日用量
+func usageKeys(app *cli, args []string) error {
+	fs := flag.NewFlagSet("usage keys", flag.ExitOnError)
+	date := fs.String("date", "", "日期 YYYY-MM-DD（默认今天）")
+	fs.Parse(args)
+
+	var result struct {
+		Date    string `json:"date"`
+		APIKeys []struct {
+			APIKeyID      uint    `json:"api_key_id"`
+			TotalRequests int64   `json:"total_requests"`
+			TotalTokens   int64   `json:"total_tokens"`
+			TotalCost     float64 `json:"total_cost"`
+		} `json:"api_keys"`
+	}
+	if err := app.client.get("/api/admin/usage/api-keys", map[string]string{"date": *date}, &result); err != nil {
+		return err
+	}
+	return app.output(result, func() {
+		fmt.Printf("日期: %s\n", result.Date)
+		t := newTable("API Key", "请求数", "Token", "费用")
+		for _, k := range result.APIKeys {
+			t.row(k.APIKeyID, k.TotalRequests, k.TotalTokens, fmt.Sprintf("$%.4f", k.TotalCost))
+		}
+		t.flush()
+	})
+}
@@ -0,0 +1,196 @@
+/*
+ * 文件作用：config 子命令，命令行方式导出/对比/应用声明式配置
+ * 负责功能：
+ *   - cli-proxy config export [-o file] [-sections a,b] [-omit-secrets]
+ *   - cli-proxy config plan  (-f file | -dir dir) [-prune]
+ *   - cli-proxy config apply (-f file | -dir dir) [-prune] [-yes]
+ * 重要程度：⭐⭐ 辅助（运维工具）
+ * 依赖模块：config, configsync, repository
+ */
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"cli-proxy/internal/config"
+	"cli-proxy/internal/configsync"
+	"cli-proxy/internal/repository"
+	"cli-proxy/pkg/logger"
+)
+
+const configUsage = `用法:
+  cli-proxy config export [-o file] [-sections a,b] [-omit-secrets]
+  cli-proxy config plan   (-f file | -dir dir) [-prune]
+  cli-proxy config apply  (-f file | -dir dir) [-prune] [-yes]
+
+说明:
+  export 将数据库中的运行时配置导出为 YAML（默认输出到标准输出）
+  plan   对比配置文件与数据库，输出变更计划，不修改数据库
+  apply  应用配置文件（单个事务），运行中的服务需重启或通过管理接口应用才能刷新缓存
+`
+
+// configStdout 命令输出（日志控制台输出改写到标准错误，保证 export 输出可直接重定向为文件）
+var configStdout = os.Stdout
+
+// runConfigCommand 执行 config 子命令，返回进程退出码
+func runConfigCommand(configPath string, args []string) int {
+	os.Stdout = os.Stderr
+	defer func() { os.Stdout = configStdout }()
+
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, configUsage)
+		return 2
+	}
+
+	var err error
+	switch args[0] {
+	case "export":
+		err = configExport(configPath, args[1:])
+	case "plan":
+		err = configPlanOrApply(configPath, args[1:], false)
+	case "apply":
+		err = configPlanOrApply(configPath, args[1:], true)
+	default:
+		fmt.Fprint(os.Stderr, configUsage)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// configExport 导出配置
+func configExport(configPath string, args []string) error {
+	fs := flag.NewFlagSet("config export", flag.ExitOnError)
+	output := fs.String("o", "", "输出文件（默认标准输出）")
+	sections := fs.String("sections", "", "导出的分区，逗号分隔（默认全部: "+strings.Join(configsync.SectionNames(), ",")+"）")
+	omitSecrets := fs.Bool("omit-secrets", false, "敏感字段（如代理密码）导出为空")
+	fs.Parse(args)
+
+	if err := openConfigDB(configPath); err != nil {
+		return err
+	}
+
+	opts := configsync.ExportOptions{OmitSecrets: *omitSecrets}
+	for _, name := range strings.Split(*sections, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			opts.Sections = append(opts.Sections, name)
+		}
+	}
+	doc, err := configsync.Export(repository.GetDB(), opts)
+	if err != nil {
+		return err
+	}
+	data, err := configsync.Marshal(doc)
+	if err != nil {
+		return err
+	}
+
+	if *output == "" {
+		_, err = configStdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(*output, data, 0o600); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "已导出到 %s\n", *output)
+	return nil
+}
+
+// configPlanOrApply 输出变更计划，apply 时确认后应用
+func configPlanOrApply(configPath string, args []string, apply bool) error {
+	name := "config plan"
+	if apply {
+		name = "config apply"
+	}
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	file := fs.String("f", "", "配置文件")
+	dir := fs.String("dir", "", "配置目录（合并目录下全部 *.yaml / *.yml）")
+	prune := fs.Bool("prune", false, "删除配置中不存在的条目（只影响文件中出现的分区）")
+	yes := fs.Bool("yes", false, "跳过确认")
+	fs.Parse(args)
+
+	doc, err := loadConfigDocument(*file, *dir)
+	if err != nil {
+		return err
+	}
+	if err := openConfigDB(configPath); err != nil {
+		return err
+	}
+
+	opts := configsync.Options{Prune: *prune}
+	plan, err := configsync.BuildPlan(repository.GetDB(), doc, opts)
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(configStdout, plan.String())
+	if !apply || len(plan.Changes) == 0 {
+		return nil
+	}
+
+	if !*yes {
+		fmt.Fprint(configStdout, "\n确认应用以上变更? [y/N] ")
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if a := strings.ToLower(strings.TrimSpace(answer)); a != "y" && a != "yes" {
+			fmt.Fprintln(configStdout, "已取消")
+			return nil
+		}
+	}
+
+	if _, err := configsync.Apply(repository.GetDB(), doc, opts); err != nil {
+		return err
+	}
+	fmt.Fprintln(configStdout, "配置已应用")
+	return nil
+}
+
+// loadConfigDocument 读取配置文件或配置目录
+func loadConfigDocument(file, dir string) (*configsync.Document, error) {
+	switch {
+	case file != "" && dir != "":
+		return nil, fmt.Errorf("-f 与 -dir 只能指定一个")
+	case dir != "":
+		return configsync.LoadDir(dir)
+	case file == "-":
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, err
+		}
+		return configsync.Parse(data)
+	case file != "":
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		return configsync.Parse(data)
+	}
+	return nil, fmt.Errorf("请通过 -f 或 -dir 指定配置")
+}
+
+// openConfigDB 加载配置并连接数据库（日志写入日志目录，不干扰标准输出）
+func openConfigDB(configPath string) error {
+	if err := config.Load(configPath); err != nil {
+		return fmt.Errorf("加载配置失败: %w", err)
+	}
+	logDir := config.Cfg.Log.Dir
+	if logDir == "" {
+		logDir = "logs"
+	}
+	if err := logger.Init(logDir, logger.ParseLevel(config.Cfg.Log.Level)); err != nil {
+		return fmt.Errorf("初始化日志失败: %w", err)
+	}
+	if err := repository.InitMySQL(); err != nil {
+		return fmt.Errorf("连接数据库失败: %w", err)
+	}
+	if err := repository.AutoMigrate(); err != nil {
+		return fmt.Errorf("数据库迁移失败: %w", err)
+	}
+	return nil
+}
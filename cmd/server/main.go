@@ -25,9 +25,11 @@ import (
 	"time"
 
 	"cli-proxy/internal/config"
+	"cli-proxy/internal/configsync"
 	"cli-proxy/internal/handler"
 	"cli-proxy/internal/middleware"
 	"cli-proxy/internal/model"
+	"cli-proxy/internal/proxy/scheduler"
 	"cli-proxy/internal/repository"
 	"cli-proxy/internal/service"
 	"cli-proxy/pkg/logger"
@@ -57,8 +59,14 @@ func main() {
 
 	startTime := time.Now()
 
-	// 加载配置
 	configPath := "configs/config.yaml"
+
+	// 子命令：配置导出/对比/应用
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		os.Exit(runConfigCommand(configPath, os.Args[2:]))
+	}
+
+	// 加载配置
 	if err := config.Load(configPath); err != nil {
 		panic(fmt.Sprintf("加载配置失败: %v", err))
 	}
@@ -128,6 +136,19 @@ func main() {
 		log.Warn("初始化错误规则配置: %v", err)
 	}
 
+	// 声明式配置同步（以配置目录为准）
+	if config.Cfg.GitOps.Enabled {
+		syncStart := time.Now()
+		plan, err := service.GetConfigSyncService().SyncFromDir(config.Cfg.GitOps.GetDir(), config.Cfg.GitOps.Prune)
+		if err != nil {
+			log.Error("声明式配置同步失败: %v", err)
+			panic(err)
+		}
+		log.Info("声明式配置同步完成 | 目录: %s | 新增: %d | 修改: %d | 删除: %d | 耗时: %v",
+			config.Cfg.GitOps.GetDir(), plan.Summary[configsync.ActionCreate], plan.Summary[configsync.ActionUpdate],
+			plan.Summary[configsync.ActionDelete], time.Since(syncStart))
+	}
+
 	// 初始化默认管理员账户
 	if err := repository.InitDefaultAdmin(); err != nil {
 		log.Warn("初始化默认管理员: %v", err)
@@ -158,6 +179,35 @@ func main() {
 		log.Info("用量同步服务已启动 | 间隔: %v", usageSyncInterval)
 	}
 
+	// 启动周期用量报表任务（是否生成由 usage_report_enabled 控制，每轮检查时读取）
+	usageReportService := service.GetUsageReportService()
+	usageReportService.Start()
+
+	// 启动请求日志清理任务（分级保留、归档、分区维护，间隔每轮读取配置）
+	logRetentionService := service.GetLogRetentionService()
+	logRetentionService.Start()
+
+	// 应用系统日志保留策略和外部投递配置
+	systemLogService := service.GetSystemLogService()
+	if err := systemLogService.Apply(); err != nil {
+		log.Warn("应用系统日志配置失败: %v", err)
+	}
+
+	// 启动 IP 封禁名单同步（定期刷新生效中的封禁、写入命中统计）
+	ipGuardService := service.GetIPGuardService()
+	ipGuardService.Start()
+
+	// 启动客户端指纹分析（异步统计、异常检测，是否采集由 fingerprint_enabled 控制）
+	clientFingerprintService := service.GetClientFingerprintService()
+	clientFingerprintService.Start()
+
+	// 启动代理健康检查（定期测试代理连通性，失败代理不参与代理池分配）
+	proxyHealthCheckService := service.GetProxyHealthCheckService()
+	proxyHealthCheckService.Start()
+
+	// 应用账户并发全满时的排队配置
+	scheduler.GetFairQueue().Configure(configService.GetRequestQueueConfig())
+
 	// 设置配置变更回调
 	handler.SetConfigChangeCallback(func(key, value string) {
 		switch key {
@@ -183,6 +233,28 @@ func main() {
 				usageSyncService.StartAutoSync(usageSyncInterval)
 				log.Info("用量同步服务已重启 | 新间隔: %v", usageSyncInterval)
 			}
+		case model.ConfigUsageReportEnabled:
+			// 开启后立即补齐上一个周期的报表，无需等待下一轮检查
+			if value == "true" {
+				go usageReportService.RunScheduled(context.Background())
+			}
+		case model.ConfigRequestLogRetentionInterval:
+			// 立即执行一轮，下一轮按新间隔计时
+			logRetentionService.Trigger()
+		default:
+			if service.IsSystemLogConfig(key) {
+				if err := systemLogService.Apply(); err != nil {
+					log.Warn("应用系统日志配置失败: %v", err)
+				}
+			}
+			if service.IsProxyPoolConfig(key) {
+				proxyHealthCheckService.OnConfigChange(key, value)
+				log.Info("代理池配置已更新: %s = %s", key, value)
+			}
+			if service.IsRequestQueueConfig(key) {
+				scheduler.GetFairQueue().Configure(configService.GetRequestQueueConfig())
+				log.Info("请求排队配置已更新: %s = %s", key, value)
+			}
 		}
 	})
 
@@ -195,6 +267,13 @@ func main() {
 	// 创建路由
 	r := gin.New()
 
+	// 可信代理：只采信可信代理转发的客户端 IP 头部
+	trustedProxies := config.Cfg.Server.GetTrustedProxies()
+	if err := middleware.ConfigureClientIP(r, trustedProxies, config.Cfg.Server.GetRemoteIPHeaders(), config.Cfg.Server.GetCountryHeader()); err != nil {
+		panic(fmt.Sprintf("可信代理配置无效: %v", err))
+	}
+	log.Info("可信代理: %v", trustedProxies)
+
 	// 基础中间件
 	r.Use(middleware.Logger())
 	r.Use(middleware.Recovery())
@@ -262,6 +341,21 @@ func main() {
 		log.Info("用量同步服务已停止")
 	}
 
+	// 停止周期用量报表任务
+	usageReportService.Stop()
+
+	// 停止请求日志清理任务
+	logRetentionService.Stop()
+
+	// 停止 IP 封禁名单同步
+	ipGuardService.Stop()
+
+	// 停止客户端指纹分析（写入剩余统计）
+	clientFingerprintService.Stop()
+
+	// 停止代理健康检查
+	proxyHealthCheckService.Stop()
+
 	// 创建超时上下文
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
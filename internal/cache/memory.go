@@ -4,6 +4,7 @@
  *   - 会话绑定存储（SessionStore）
  *   - 并发计数管理（ConcurrencyManager）
  *   - 账户不可用标记（UnavailableMarker）
+ *   - 响应缓存（ResponseCache，见 response.go）
  *   - 过期数据自动清理
  * 重要程度：⭐⭐⭐⭐ 重要（内存缓存核心）
  * 依赖模块：config
@@ -13,6 +14,8 @@ package cache
 import (
 	"context"
 	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -407,11 +410,16 @@ func (s *SessionStore) ClearAll() int {
 
 // ConcurrencyCounter 带TTL的并发计数器
 type ConcurrencyCounter struct {
-	mu    sync.Mutex
-	slots []time.Time // 每个槽位的获取时间
-	limit int
+	mu       sync.Mutex
+	slots    []time.Time // 每个槽位的获取时间
+	limit    int
+	waiting  int           // 排队等待槽位的请求数
+	released chan struct{} // 槽位释放通知（释放时关闭并重建）
 }
 
+// 排队等待时检查过期槽位的间隔（槽位也可能因 TTL 过期而空出）
+const concurrencyWaitRecheck = time.Second
+
 // Count 获取当前有效并发数（排除过期槽位）
 func (c *ConcurrencyCounter) Count(ttl time.Duration) int {
 	c.mu.Lock()
@@ -436,6 +444,56 @@ func (c *ConcurrencyCounter) Acquire(limit int, ttl time.Duration) (bool, int) {
 	return true, len(c.slots)
 }
 
+// AcquireWait 获取并发槽位，已满时最多排队等待 wait（ctx 取消时放弃）
+func (c *ConcurrencyCounter) AcquireWait(ctx context.Context, limit int, ttl, wait time.Duration) (bool, int) {
+	if acquired, count := c.Acquire(limit, ttl); acquired || wait <= 0 {
+		return acquired, count
+	}
+
+	deadline := time.NewTimer(wait)
+	defer deadline.Stop()
+	for {
+		c.mu.Lock()
+		c.cleanExpiredLocked(ttl)
+		if len(c.slots) < limit {
+			c.slots = append(c.slots, time.Now())
+			count := len(c.slots)
+			c.mu.Unlock()
+			return true, count
+		}
+		if c.released == nil {
+			c.released = make(chan struct{})
+		}
+		released := c.released
+		c.waiting++
+		c.mu.Unlock()
+
+		expired := false
+		select {
+		case <-released:
+		case <-time.After(concurrencyWaitRecheck):
+		case <-deadline.C:
+			expired = true
+		case <-ctx.Done():
+			expired = true
+		}
+
+		c.mu.Lock()
+		c.waiting--
+		c.mu.Unlock()
+		if expired {
+			return false, c.Count(ttl)
+		}
+	}
+}
+
+// Waiting 获取排队等待的请求数
+func (c *ConcurrencyCounter) Waiting() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.waiting
+}
+
 // Release 释放并发槽位（移除最老的一个）
 func (c *ConcurrencyCounter) Release() {
 	c.mu.Lock()
@@ -444,6 +502,7 @@ func (c *ConcurrencyCounter) Release() {
 	if len(c.slots) > 0 {
 		c.slots = c.slots[1:] // 移除最老的槽位
 	}
+	c.notifyLocked()
 }
 
 // Reset 重置计数器
@@ -451,6 +510,15 @@ func (c *ConcurrencyCounter) Reset() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	c.slots = nil
+	c.notifyLocked()
+}
+
+// notifyLocked 唤醒排队等待的请求（需要持有锁）
+func (c *ConcurrencyCounter) notifyLocked() {
+	if c.released != nil {
+		close(c.released)
+		c.released = nil
+	}
 }
 
 // cleanExpiredLocked 清理过期槽位（需要持有锁）
@@ -477,7 +545,7 @@ func (c *ConcurrencyCounter) cleanExpiredLocked(ttl time.Duration) {
 // ConcurrencyManager 并发控制管理器（带TTL支持）
 type ConcurrencyManager struct {
 	accountCounters sync.Map // accountID -> *ConcurrencyCounter
-	userCounters    sync.Map // userID -> *ConcurrencyCounter
+	userCounters    sync.Map // "user:<id>" / "apikey:<id>" -> *ConcurrencyCounter
 	accountLimits   sync.Map // accountID -> int (自定义限制)
 
 	cleanupInterval time.Duration
@@ -581,9 +649,20 @@ func (m *ConcurrencyManager) getOrCreateAccountCounter(accountID uint) *Concurre
 	return val.(*ConcurrencyCounter)
 }
 
-// getOrCreateUserCounter 获取或创建用户计数器
-func (m *ConcurrencyManager) getOrCreateUserCounter(userID uint) *ConcurrencyCounter {
-	val, _ := m.userCounters.LoadOrStore(userID, &ConcurrencyCounter{})
+// 用户与 API Key 计数器的键前缀（两者 ID 来自不同的表，不能共用同一个键）
+const (
+	userCounterPrefix   = "user:"
+	apiKeyCounterPrefix = "apikey:"
+)
+
+// counterKey 计数器键
+func counterKey(prefix string, id uint) string {
+	return prefix + strconv.FormatUint(uint64(id), 10)
+}
+
+// getOrCreateUserCounter 获取或创建用户（或 API Key）计数器
+func (m *ConcurrencyManager) getOrCreateUserCounter(key string) *ConcurrencyCounter {
+	val, _ := m.userCounters.LoadOrStore(key, &ConcurrencyCounter{})
 	return val.(*ConcurrencyCounter)
 }
 
@@ -627,7 +706,7 @@ func (m *ConcurrencyManager) AcquireUser(ctx context.Context, userID uint, limit
 		limit = 10 // 默认用户并发限制
 	}
 
-	counter := m.getOrCreateUserCounter(userID)
+	counter := m.getOrCreateUserCounter(counterKey(userCounterPrefix, userID))
 	ttl := getConcurrencyTTL()
 	acquired, count := counter.Acquire(limit, ttl)
 	return acquired, int64(count)
@@ -635,24 +714,83 @@ func (m *ConcurrencyManager) AcquireUser(ctx context.Context, userID uint, limit
 
 // ReleaseUser 释放用户并发槽位
 func (m *ConcurrencyManager) ReleaseUser(ctx context.Context, userID uint) {
-	counter := m.getOrCreateUserCounter(userID)
+	counter := m.getOrCreateUserCounter(counterKey(userCounterPrefix, userID))
 	counter.Release()
 }
 
 // GetUserConcurrency 获取用户当前并发数
 func (m *ConcurrencyManager) GetUserConcurrency(userID uint) int64 {
-	counter := m.getOrCreateUserCounter(userID)
+	counter := m.getOrCreateUserCounter(counterKey(userCounterPrefix, userID))
 	ttl := getConcurrencyTTL()
 	return int64(counter.Count(ttl))
 }
 
 // ResetUserConcurrency 重置用户并发计数
 func (m *ConcurrencyManager) ResetUserConcurrency(userID uint) {
-	if val, ok := m.userCounters.Load(userID); ok {
+	if val, ok := m.userCounters.Load(counterKey(userCounterPrefix, userID)); ok {
+		val.(*ConcurrencyCounter).Reset()
+	}
+}
+
+// AcquireAPIKeyWait 获取 API Key 并发槽位，已满时最多排队等待 wait
+func (m *ConcurrencyManager) AcquireAPIKeyWait(ctx context.Context, apiKeyID uint, limit int, wait time.Duration) (bool, int64) {
+	counter := m.getOrCreateUserCounter(counterKey(apiKeyCounterPrefix, apiKeyID))
+	acquired, count := counter.AcquireWait(ctx, limit, getConcurrencyTTL(), wait)
+	return acquired, int64(count)
+}
+
+// ReleaseAPIKey 释放 API Key 并发槽位
+func (m *ConcurrencyManager) ReleaseAPIKey(ctx context.Context, apiKeyID uint) {
+	m.getOrCreateUserCounter(counterKey(apiKeyCounterPrefix, apiKeyID)).Release()
+}
+
+// ResetAPIKeyConcurrency 重置 API Key 并发计数
+func (m *ConcurrencyManager) ResetAPIKeyConcurrency(apiKeyID uint) {
+	if val, ok := m.userCounters.Load(counterKey(apiKeyCounterPrefix, apiKeyID)); ok {
 		val.(*ConcurrencyCounter).Reset()
 	}
 }
 
+// APIKeyConcurrencyStat API Key 当前并发状态
+type APIKeyConcurrencyStat struct {
+	APIKeyID uint  `json:"api_key_id"`
+	InFlight int64 `json:"in_flight"`
+	Waiting  int64 `json:"waiting"`
+}
+
+// ListAPIKeyConcurrency 列出有进行中或排队请求的 API Key（按进行中数量降序）
+func (m *ConcurrencyManager) ListAPIKeyConcurrency() []APIKeyConcurrencyStat {
+	ttl := getConcurrencyTTL()
+	stats := make([]APIKeyConcurrencyStat, 0)
+	m.userCounters.Range(func(key, value interface{}) bool {
+		idStr, ok := strings.CutPrefix(key.(string), apiKeyCounterPrefix)
+		if !ok {
+			return true
+		}
+		id, err := strconv.ParseUint(idStr, 10, 64)
+		if err != nil {
+			return true
+		}
+		counter := value.(*ConcurrencyCounter)
+		stat := APIKeyConcurrencyStat{
+			APIKeyID: uint(id),
+			InFlight: int64(counter.Count(ttl)),
+			Waiting:  int64(counter.Waiting()),
+		}
+		if stat.InFlight > 0 || stat.Waiting > 0 {
+			stats = append(stats, stat)
+		}
+		return true
+	})
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].InFlight != stats[j].InFlight {
+			return stats[i].InFlight > stats[j].InFlight
+		}
+		return stats[i].APIKeyID < stats[j].APIKeyID
+	})
+	return stats
+}
+
 // Stats 获取并发管理器统计
 func (m *ConcurrencyManager) Stats() (accountCount, userCount int) {
 	m.accountCounters.Range(func(_, _ interface{}) bool {
@@ -842,6 +980,7 @@ type MemoryCache struct {
 	Sessions    *SessionStore
 	Concurrency *ConcurrencyManager
 	Unavailable *UnavailableMarker
+	Responses   *ResponseCache
 }
 
 // 全局内存缓存单例
@@ -857,6 +996,7 @@ func GetMemoryCache() *MemoryCache {
 			Sessions:    GetSessionStore(),
 			Concurrency: GetConcurrencyManager(),
 			Unavailable: GetUnavailableMarker(),
+			Responses:   GetResponseCache(),
 		}
 	})
 	return globalMemoryCache
@@ -876,6 +1016,7 @@ func (c *MemoryCache) Stats() map[string]interface{} {
 		"unavailable_count":         c.Unavailable.Count(),
 		"account_concurrency_count": accountConcurrency,
 		"user_concurrency_count":    userConcurrency,
+		"response_cache_count":      c.Responses.Count(),
 	}
 }
 
@@ -884,5 +1025,6 @@ func (c *MemoryCache) ClearAll() map[string]int {
 	return map[string]int{
 		"sessions":    c.Sessions.ClearAll(),
 		"unavailable": c.Unavailable.ClearAll(),
+		"responses":   c.Responses.ClearAll(),
 	}
 }
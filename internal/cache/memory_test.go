@@ -0,0 +1,84 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cli-proxy/internal/config"
+)
+
+func setTestConcurrencyConfig(t *testing.T) {
+	t.Helper()
+	prev := config.Cfg
+	config.Cfg = &config.Config{}
+	t.Cleanup(func() { config.Cfg = prev })
+}
+
+func TestConcurrencyCounterAcquireWait(t *testing.T) {
+	c := &ConcurrencyCounter{}
+	if ok, _ := c.Acquire(1, time.Minute); !ok {
+		t.Fatal("expected first slot")
+	}
+
+	// 等待超时
+	start := time.Now()
+	if ok, count := c.AcquireWait(context.Background(), 1, time.Minute, 30*time.Millisecond); ok || count != 1 {
+		t.Fatalf("expected wait timeout, got %v %d", ok, count)
+	}
+	if time.Since(start) < 30*time.Millisecond {
+		t.Fatal("expected to wait before giving up")
+	}
+
+	// 等待期间槽位释放
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		c.Release()
+	}()
+	if ok, _ := c.AcquireWait(context.Background(), 1, time.Minute, time.Second); !ok {
+		t.Fatal("expected slot after release")
+	}
+
+	// 客户端取消时放弃排队，不占用槽位
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+	start = time.Now()
+	if ok, _ := c.AcquireWait(ctx, 1, time.Minute, 5*time.Second); ok {
+		t.Fatal("expected cancelled wait to fail")
+	}
+	if time.Since(start) > time.Second {
+		t.Fatal("expected cancel to end the wait promptly")
+	}
+	if c.Waiting() != 0 || c.Count(time.Minute) != 1 {
+		t.Fatalf("unexpected state: waiting %d, count %d", c.Waiting(), c.Count(time.Minute))
+	}
+}
+
+func TestConcurrencyManagerSeparatesUsersAndAPIKeys(t *testing.T) {
+	setTestConcurrencyConfig(t)
+	m := &ConcurrencyManager{}
+
+	if ok, _ := m.AcquireUser(context.Background(), 7, 1); !ok {
+		t.Fatal("expected user slot")
+	}
+	// 同一 ID 的 API Key 使用独立计数器
+	if ok, _ := m.AcquireAPIKeyWait(context.Background(), 7, 1, 0); !ok {
+		t.Fatal("expected api key slot independent of user 7")
+	}
+	if ok, _ := m.AcquireAPIKeyWait(context.Background(), 7, 1, 0); ok {
+		t.Fatal("expected api key limit enforced")
+	}
+
+	stats := m.ListAPIKeyConcurrency()
+	if len(stats) != 1 || stats[0].APIKeyID != 7 || stats[0].InFlight != 1 {
+		t.Fatalf("unexpected api key stats: %+v", stats)
+	}
+
+	m.ReleaseAPIKey(context.Background(), 7)
+	if m.GetUserConcurrency(7) != 1 || len(m.ListAPIKeyConcurrency()) != 0 {
+		t.Fatal("releasing the api key must not touch the user counter")
+	}
+}
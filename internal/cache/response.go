@@ -0,0 +1,240 @@
+/*
+ * 文件作用：响应缓存，按规范化请求哈希缓存确定性请求的完整响应
+ * 负责功能：
+ *   - 非流式响应体与流式 SSE 原始字节的存储和回放
+ *   - TTL 过期与总大小上限（LRU 淘汰）
+ *   - 命中/未命中统计、按 API Key / 模型清理
+ * 重要程度：⭐⭐⭐ 一般（可选功能，需客户端通过请求头开启）
+ * 依赖模块：config
+ */
+package cache
+
+import (
+	"container/list"
+	"sort"
+	"sync"
+	"time"
+
+	"cli-proxy/internal/config"
+)
+
+// ResponseCacheEntry 响应缓存条目
+type ResponseCacheEntry struct {
+	Key          string    `json:"key"`
+	APIKeyID     uint      `json:"api_key_id"`
+	Model        string    `json:"model"`
+	Path         string    `json:"path"`
+	Stream       bool      `json:"stream"`
+	StatusCode   int       `json:"status_code"`
+	ContentType  string    `json:"content_type"`
+	Body         []byte    `json:"-"`
+	Size         int       `json:"size"`
+	InputTokens  int       `json:"input_tokens"`
+	OutputTokens int       `json:"output_tokens"`
+	Hits         int64     `json:"hits"`
+	CreatedAt    time.Time `json:"created_at"`
+	ExpireAt     time.Time `json:"expire_at"`
+}
+
+// IsExpired 检查是否过期
+func (e *ResponseCacheEntry) IsExpired() bool {
+	return time.Now().After(e.ExpireAt)
+}
+
+// ResponseCacheStats 响应缓存统计
+type ResponseCacheStats struct {
+	Entries    int   `json:"entries"`
+	Bytes      int64 `json:"bytes"`
+	MaxBytes   int64 `json:"max_bytes"`
+	Hits       int64 `json:"hits"`
+	Misses     int64 `json:"misses"`
+	Evictions  int64 `json:"evictions"`
+	TTLSeconds int   `json:"ttl_seconds"`
+}
+
+// ResponseCache 响应缓存（LRU + TTL）
+type ResponseCache struct {
+	mu      sync.Mutex
+	entries map[string]*list.Element // key -> element(*ResponseCacheEntry)
+	lru     *list.List               // 队首为最近使用
+	bytes   int64
+
+	hits      int64
+	misses    int64
+	evictions int64
+}
+
+// 全局响应缓存单例
+var (
+	globalResponseCache *ResponseCache
+	responseCacheOnce   sync.Once
+)
+
+// GetResponseCache 获取响应缓存单例
+func GetResponseCache() *ResponseCache {
+	responseCacheOnce.Do(func() {
+		globalResponseCache = &ResponseCache{
+			entries: make(map[string]*list.Element),
+			lru:     list.New(),
+		}
+	})
+	return globalResponseCache
+}
+
+// getResponseCacheMaxBytes 获取缓存总大小上限
+func getResponseCacheMaxBytes() int64 {
+	return int64(config.Cfg.Cache.GetResponseCacheMaxMB()) * 1024 * 1024
+}
+
+// Get 获取缓存条目（过期条目视为未命中并删除）
+func (c *ResponseCache) Get(key string) (*ResponseCacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+	entry := elem.Value.(*ResponseCacheEntry)
+	if entry.IsExpired() {
+		c.removeElement(elem)
+		c.misses++
+		return nil, false
+	}
+
+	entry.Hits++
+	c.hits++
+	c.lru.MoveToFront(elem)
+	return entry, true
+}
+
+// Set 写入缓存条目，超出总大小上限时按 LRU 淘汰
+func (c *ResponseCache) Set(entry *ResponseCacheEntry) {
+	entry.Size = len(entry.Body)
+	maxBytes := getResponseCacheMaxBytes()
+	if int64(entry.Size) > maxBytes {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[entry.Key]; ok {
+		c.removeElement(elem)
+	}
+	c.entries[entry.Key] = c.lru.PushFront(entry)
+	c.bytes += int64(entry.Size)
+
+	for c.bytes > maxBytes {
+		oldest := c.lru.Back()
+		if oldest == nil {
+			break
+		}
+		c.removeElement(oldest)
+		c.evictions++
+	}
+}
+
+// Delete 删除指定缓存条目
+func (c *ResponseCache) Delete(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return false
+	}
+	c.removeElement(elem)
+	return true
+}
+
+// Purge 按条件清理缓存，apiKeyID 为 0 / model 为空表示不限
+func (c *ResponseCache) Purge(apiKeyID uint, model string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	count := 0
+	for _, elem := range c.entries {
+		entry := elem.Value.(*ResponseCacheEntry)
+		if apiKeyID > 0 && entry.APIKeyID != apiKeyID {
+			continue
+		}
+		if model != "" && entry.Model != model {
+			continue
+		}
+		c.removeElement(elem)
+		count++
+	}
+	return count
+}
+
+// ClearAll 清除所有缓存条目
+func (c *ResponseCache) ClearAll() int {
+	return c.Purge(0, "")
+}
+
+// List 列出未过期的缓存条目（按创建时间倒序），返回分页结果和总数
+func (c *ResponseCache) List(apiKeyID uint, model string, offset, limit int) ([]ResponseCacheEntry, int) {
+	c.mu.Lock()
+	result := make([]ResponseCacheEntry, 0, len(c.entries))
+	for _, elem := range c.entries {
+		entry := elem.Value.(*ResponseCacheEntry)
+		if entry.IsExpired() {
+			c.removeElement(elem)
+			continue
+		}
+		if apiKeyID > 0 && entry.APIKeyID != apiKeyID {
+			continue
+		}
+		if model != "" && entry.Model != model {
+			continue
+		}
+		result = append(result, *entry)
+	}
+	c.mu.Unlock()
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.After(result[j].CreatedAt)
+	})
+
+	total := len(result)
+	if offset >= total {
+		return []ResponseCacheEntry{}, total
+	}
+	end := offset + limit
+	if limit <= 0 || end > total {
+		end = total
+	}
+	return result[offset:end], total
+}
+
+// Stats 获取响应缓存统计
+func (c *ResponseCache) Stats() ResponseCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return ResponseCacheStats{
+		Entries:    len(c.entries),
+		Bytes:      c.bytes,
+		MaxBytes:   getResponseCacheMaxBytes(),
+		Hits:       c.hits,
+		Misses:     c.misses,
+		Evictions:  c.evictions,
+		TTLSeconds: config.Cfg.Cache.GetResponseCacheTTL(),
+	}
+}
+
+// Count 统计缓存条目数量
+func (c *ResponseCache) Count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// removeElement 删除条目（调用方需持有锁）
+func (c *ResponseCache) removeElement(elem *list.Element) {
+	entry := elem.Value.(*ResponseCacheEntry)
+	c.lru.Remove(elem)
+	delete(c.entries, entry.Key)
+	c.bytes -= int64(entry.Size)
+}
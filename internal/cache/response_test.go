@@ -0,0 +1,111 @@
+package cache
+
+import (
+	"container/list"
+	"strings"
+	"testing"
+	"time"
+
+	"cli-proxy/internal/config"
+)
+
+func newTestResponseCache(t *testing.T, maxMB int) *ResponseCache {
+	t.Helper()
+	prev := config.Cfg
+	config.Cfg = &config.Config{Cache: config.CacheConfig{ResponseCacheMaxMB: maxMB}}
+	t.Cleanup(func() { config.Cfg = prev })
+	return &ResponseCache{entries: make(map[string]*list.Element), lru: list.New()}
+}
+
+func testEntry(key string, apiKeyID uint, model string, size int, ttl time.Duration) *ResponseCacheEntry {
+	now := time.Now()
+	return &ResponseCacheEntry{
+		Key:       key,
+		APIKeyID:  apiKeyID,
+		Model:     model,
+		Body:      []byte(strings.Repeat("x", size)),
+		CreatedAt: now,
+		ExpireAt:  now.Add(ttl),
+	}
+}
+
+func TestResponseCacheGetSetAndExpiry(t *testing.T) {
+	c := newTestResponseCache(t, 1)
+
+	if _, ok := c.Get("missing"); ok {
+		t.Fatal("expected miss")
+	}
+	c.Set(testEntry("a", 1, "m", 10, time.Minute))
+	entry, ok := c.Get("a")
+	if !ok || entry.Size != 10 || entry.Hits != 1 {
+		t.Fatalf("unexpected entry: %+v, %v", entry, ok)
+	}
+
+	// 过期条目视为未命中并被删除
+	c.Set(testEntry("expired", 1, "m", 10, -time.Second))
+	if _, ok := c.Get("expired"); ok {
+		t.Fatal("expected expired entry to miss")
+	}
+	stats := c.Stats()
+	if stats.Entries != 1 || stats.Bytes != 10 || stats.Hits != 1 || stats.Misses != 2 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+
+	// 覆盖写入不重复计算大小
+	c.Set(testEntry("a", 1, "m", 20, time.Minute))
+	if stats := c.Stats(); stats.Entries != 1 || stats.Bytes != 20 {
+		t.Fatalf("unexpected stats after overwrite: %+v", stats)
+	}
+}
+
+func TestResponseCacheLRUEviction(t *testing.T) {
+	c := newTestResponseCache(t, 1)
+	const size = 400 * 1024
+
+	c.Set(testEntry("a", 1, "m", size, time.Minute))
+	c.Set(testEntry("b", 1, "m", size, time.Minute))
+	c.Get("a") // a 最近使用，b 最先被淘汰
+	c.Set(testEntry("c", 1, "m", size, time.Minute))
+
+	if _, ok := c.Get("b"); ok {
+		t.Fatal("expected b evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := c.Get(key); !ok {
+			t.Fatalf("expected %s kept", key)
+		}
+	}
+	if stats := c.Stats(); stats.Evictions != 1 || stats.Bytes != 2*size {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+
+	// 超过总上限的单条不写入
+	c.Set(testEntry("huge", 1, "m", 2*1024*1024, time.Minute))
+	if _, ok := c.Get("huge"); ok {
+		t.Fatal("expected oversized entry rejected")
+	}
+}
+
+func TestResponseCachePurgeAndList(t *testing.T) {
+	c := newTestResponseCache(t, 1)
+	c.Set(testEntry("a", 1, "m1", 1, time.Minute))
+	c.Set(testEntry("b", 1, "m2", 1, time.Minute))
+	c.Set(testEntry("c", 2, "m1", 1, time.Minute))
+
+	if items, total := c.List(1, "", 0, 1); total != 2 || len(items) != 1 {
+		t.Fatalf("unexpected list: %d %d", len(items), total)
+	}
+	if items, total := c.List(0, "", 5, 10); total != 3 || len(items) != 0 {
+		t.Fatalf("unexpected list beyond range: %d %d", len(items), total)
+	}
+
+	if n := c.Purge(0, "m1"); n != 2 {
+		t.Fatalf("expected 2 purged, got %d", n)
+	}
+	if !c.Delete("b") || c.Delete("b") {
+		t.Fatal("unexpected delete result")
+	}
+	if c.Count() != 0 || c.Stats().Bytes != 0 {
+		t.Fatalf("expected empty cache, got %+v", c.Stats())
+	}
+}
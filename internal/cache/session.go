@@ -270,3 +270,28 @@ func (s *SessionCache) ResetUserConcurrency(ctx context.Context, userID uint) er
 	s.concurrencyManager.ResetUserConcurrency(userID)
 	return nil
 }
+
+// ==================== API Key 并发控制 ====================
+
+// AcquireAPIKeyConcurrency 获取 API Key 并发槽位，已满时最多排队等待 wait
+func (s *SessionCache) AcquireAPIKeyConcurrency(ctx context.Context, apiKeyID uint, limit int, wait time.Duration) (bool, int64, error) {
+	acquired, current := s.concurrencyManager.AcquireAPIKeyWait(ctx, apiKeyID, limit, wait)
+	return acquired, current, nil
+}
+
+// ReleaseAPIKeyConcurrency 释放 API Key 并发槽位
+func (s *SessionCache) ReleaseAPIKeyConcurrency(ctx context.Context, apiKeyID uint) error {
+	s.concurrencyManager.ReleaseAPIKey(ctx, apiKeyID)
+	return nil
+}
+
+// ResetAPIKeyConcurrency 重置 API Key 并发计数
+func (s *SessionCache) ResetAPIKeyConcurrency(ctx context.Context, apiKeyID uint) error {
+	s.concurrencyManager.ResetAPIKeyConcurrency(apiKeyID)
+	return nil
+}
+
+// ListAPIKeyConcurrency 列出有进行中或排队请求的 API Key
+func (s *SessionCache) ListAPIKeyConcurrency() []APIKeyConcurrencyStat {
+	return s.concurrencyManager.ListAPIKeyConcurrency()
+}
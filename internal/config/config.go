@@ -24,6 +24,22 @@ type Config struct {
 	Log    LogConfig    `yaml:"log"`
 	Cache  CacheConfig  `yaml:"cache"`
 	Security SecurityConfig `yaml:"security"`
+	GitOps GitOpsConfig `yaml:"gitops"`
+}
+
+// GitOpsConfig 声明式配置同步（启动时从配置目录同步数据库中的运行时配置）
+type GitOpsConfig struct {
+	Enabled bool   `yaml:"enabled"` // 是否在启动时同步
+	Dir     string `yaml:"dir"`     // 配置目录（目录下全部 *.yaml / *.yml 文件）
+	Prune   bool   `yaml:"prune"`   // 是否删除配置中不存在的条目（只影响配置文件中出现的分区）
+}
+
+// GetDir 获取配置目录
+func (g *GitOpsConfig) GetDir() string {
+	if g.Dir == "" {
+		return "deploy/config"
+	}
+	return g.Dir
 }
 
 // SecurityConfig 安全相关配置
@@ -34,6 +50,40 @@ type SecurityConfig struct {
 type ServerConfig struct {
 	Port int    `yaml:"port"`
 	Mode string `yaml:"mode"`
+	// 可信反向代理（IP/CIDR）：只有来自这些地址的请求才采信 X-Forwarded-For 等头部，未配置时信任本机和内网地址
+	TrustedProxies  []string `yaml:"trusted_proxies"`
+	RemoteIPHeaders []string `yaml:"remote_ip_headers"` // 读取客户端 IP 的头部（按顺序），默认 X-Forwarded-For, X-Real-IP
+	CountryHeader   string   `yaml:"country_header"`    // 可信代理提供的国家代码头部，默认 CF-IPCountry
+}
+
+// 默认可信代理：本机和内网地址（常见的同机/容器内反向代理部署）
+var defaultTrustedProxies = []string{
+	"127.0.0.1/8", "::1/128",
+	"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7",
+}
+
+// GetTrustedProxies 获取可信代理列表
+func (c *ServerConfig) GetTrustedProxies() []string {
+	if c.TrustedProxies == nil {
+		return defaultTrustedProxies
+	}
+	return c.TrustedProxies
+}
+
+// GetRemoteIPHeaders 获取读取客户端 IP 的头部
+func (c *ServerConfig) GetRemoteIPHeaders() []string {
+	if len(c.RemoteIPHeaders) == 0 {
+		return []string{"X-Forwarded-For", "X-Real-IP"}
+	}
+	return c.RemoteIPHeaders
+}
+
+// GetCountryHeader 获取国家代码头部
+func (c *ServerConfig) GetCountryHeader() string {
+	if c.CountryHeader == "" {
+		return "CF-IPCountry"
+	}
+	return c.CountryHeader
 }
 
 type LogConfig struct {
@@ -69,6 +119,11 @@ type CacheConfig struct {
 	UnavailableTTL        int `yaml:"unavailable_ttl"`         // 临时不可用 TTL（分钟），默认 5
 	ConcurrencyTTL        int `yaml:"concurrency_ttl"`         // 并发计数 TTL（分钟），默认 5
 	DefaultConcurrencyMax int `yaml:"default_concurrency_max"` // 默认并发上限，默认 5
+
+	// 响应缓存（客户端通过 X-Response-Cache 请求头开启）
+	ResponseCacheTTL        int `yaml:"response_cache_ttl"`          // 响应缓存 TTL（秒），默认 300
+	ResponseCacheMaxMB      int `yaml:"response_cache_max_mb"`       // 响应缓存总大小上限（MB），默认 64
+	ResponseCacheMaxEntryKB int `yaml:"response_cache_max_entry_kb"` // 单条响应大小上限（KB），默认 1024
 }
 
 // GetSessionTTL 获取会话 TTL（分钟）
@@ -111,6 +166,30 @@ func (c *CacheConfig) GetDefaultConcurrencyMax() int {
 	return c.DefaultConcurrencyMax
 }
 
+// GetResponseCacheTTL 获取响应缓存 TTL（秒）
+func (c *CacheConfig) GetResponseCacheTTL() int {
+	if c.ResponseCacheTTL <= 0 {
+		return 300
+	}
+	return c.ResponseCacheTTL
+}
+
+// GetResponseCacheMaxMB 获取响应缓存总大小上限（MB）
+func (c *CacheConfig) GetResponseCacheMaxMB() int {
+	if c.ResponseCacheMaxMB <= 0 {
+		return 64
+	}
+	return c.ResponseCacheMaxMB
+}
+
+// GetResponseCacheMaxEntryKB 获取单条响应大小上限（KB）
+func (c *CacheConfig) GetResponseCacheMaxEntryKB() int {
+	if c.ResponseCacheMaxEntryKB <= 0 {
+		return 1024
+	}
+	return c.ResponseCacheMaxEntryKB
+}
+
 var Cfg *Config
 
 func Load(path string) error {
@@ -155,4 +234,10 @@ func overrideFromEnv() {
 	if database := os.Getenv("DB_NAME"); database != "" {
 		Cfg.MySQL.Database = database
 	}
+
+	// 声明式配置目录
+	if dir := os.Getenv("GITOPS_DIR"); dir != "" {
+		Cfg.GitOps.Enabled = true
+		Cfg.GitOps.Dir = dir
+	}
 }
@@ -0,0 +1,419 @@
+/*
+ * 文件作用：声明式配置同步，将数据库中的运行时配置导出/应用为版本化 YAML
+ * 负责功能：
+ *   - 导出：系统配置、错误规则、错误消息、模型映射、模型定价、客户端过滤、代理、网关
+ *   - 计划：对比 YAML 与数据库，列出将要新增/修改/删除的条目
+ *   - 应用：在单个事务内执行计划（可选删除 YAML 中不存在的条目）
+ *   - 目录加载：合并目录下的多个 YAML 文件，用于启动时 GitOps 同步
+ * 重要程度：⭐⭐⭐ 一般（运维功能）
+ * 依赖模块：model, gorm, yaml
+ */
+package configsync
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+)
+
+// DocumentVersion 当前配置文档版本
+const DocumentVersion = 1
+
+// 变更动作
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+)
+
+// Record 单条配置记录（字段名与 API 的 JSON 字段一致）
+type Record = map[string]interface{}
+
+// Document 配置文档
+type Document struct {
+	Version    int                 `yaml:"version"`
+	ExportedAt string              `yaml:"exported_at,omitempty"`
+	Sections   map[string][]Record `yaml:",inline"`
+}
+
+// FieldChange 字段变更
+type FieldChange struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
+
+// Change 单条记录变更
+type Change struct {
+	Section string        `json:"section"`
+	Key     string        `json:"key"`
+	Action  string        `json:"action"`
+	Fields  []FieldChange `json:"fields,omitempty"`
+
+	id     uint
+	record Record
+}
+
+// Plan 变更计划
+type Plan struct {
+	Changes []Change       `json:"changes"`
+	Summary map[string]int `json:"summary"` // create/update/delete 计数
+}
+
+// Options 计划/应用选项
+type Options struct {
+	Prune bool // 删除文档中对应分区里不存在的条目（只影响文档中出现的分区）
+}
+
+// ExportOptions 导出选项
+type ExportOptions struct {
+	Sections    []string // 为空时导出全部分区
+	OmitSecrets bool     // 不导出代理密码等敏感字段
+}
+
+// SectionNames 全部分区名称（按应用顺序）
+func SectionNames() []string {
+	names := make([]string, 0, len(sections))
+	for _, s := range sections {
+		names = append(names, s.name())
+	}
+	return names
+}
+
+// Export 从数据库导出配置文档
+func Export(db *gorm.DB, opts ExportOptions) (*Document, error) {
+	wanted := make(map[string]bool, len(opts.Sections))
+	for _, name := range opts.Sections {
+		if findSection(name) == nil {
+			return nil, fmt.Errorf("未知的配置分区: %s", name)
+		}
+		wanted[name] = true
+	}
+
+	doc := &Document{
+		Version:    DocumentVersion,
+		ExportedAt: time.Now().Format(time.RFC3339),
+		Sections:   make(map[string][]Record),
+	}
+	for _, s := range sections {
+		if len(wanted) > 0 && !wanted[s.name()] {
+			continue
+		}
+		current, order, err := s.load(db)
+		if err != nil {
+			return nil, fmt.Errorf("导出 %s 失败: %w", s.name(), err)
+		}
+		records := make([]Record, 0, len(order))
+		for _, key := range order {
+			record := current[key].record
+			if opts.OmitSecrets {
+				for _, field := range s.secretFields() {
+					delete(record, field)
+				}
+			}
+			records = append(records, record)
+		}
+		doc.Sections[s.name()] = records
+	}
+	return doc, nil
+}
+
+// Marshal 序列化配置文档为 YAML
+func Marshal(doc *Document) ([]byte, error) {
+	return yaml.Marshal(doc)
+}
+
+// Parse 解析 YAML 配置文档
+func Parse(data []byte) (*Document, error) {
+	var doc Document
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("YAML 解析失败: %w", err)
+	}
+	if doc.Version == 0 {
+		doc.Version = DocumentVersion
+	}
+	if doc.Version > DocumentVersion {
+		return nil, fmt.Errorf("不支持的配置文档版本: %d", doc.Version)
+	}
+	for name := range doc.Sections {
+		if findSection(name) == nil {
+			return nil, fmt.Errorf("未知的配置分区: %s", name)
+		}
+	}
+	return &doc, nil
+}
+
+// LoadDir 加载目录下全部 YAML 文件（按文件名排序）并合并为一个文档
+// 同一分区出现在多个文件中时条目追加合并
+func LoadDir(dir string) (*Document, error) {
+	var files []string
+	for _, pattern := range []string{"*.yaml", "*.yml"} {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("目录 %s 中没有 YAML 文件", dir)
+	}
+	sort.Strings(files)
+
+	merged := &Document{Version: DocumentVersion, Sections: make(map[string][]Record)}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		doc, err := Parse(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filepath.Base(file), err)
+		}
+		for name, records := range doc.Sections {
+			merged.Sections[name] = append(merged.Sections[name], records...)
+		}
+	}
+	return merged, nil
+}
+
+// BuildPlan 对比文档与数据库，生成变更计划
+func BuildPlan(db *gorm.DB, doc *Document, opts Options) (*Plan, error) {
+	plan := &Plan{Summary: map[string]int{ActionCreate: 0, ActionUpdate: 0, ActionDelete: 0}}
+	for _, s := range sections {
+		desired, ok := doc.Sections[s.name()]
+		if !ok {
+			continue
+		}
+		current, order, err := s.load(db)
+		if err != nil {
+			return nil, fmt.Errorf("读取 %s 失败: %w", s.name(), err)
+		}
+		changes, err := planSection(s, current, order, desired, opts.Prune)
+		if err != nil {
+			return nil, err
+		}
+		for _, change := range changes {
+			plan.Summary[change.Action]++
+		}
+		plan.Changes = append(plan.Changes, changes...)
+	}
+	return plan, nil
+}
+
+// Apply 在单个事务内应用配置文档，返回已执行的计划
+func Apply(db *gorm.DB, doc *Document, opts Options) (*Plan, error) {
+	var plan *Plan
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if plan, err = BuildPlan(tx, doc, opts); err != nil {
+			return err
+		}
+
+		// 先按分区顺序新增/修改（被依赖的分区在前），再逆序删除
+		for _, change := range plan.Changes {
+			s := findSection(change.Section)
+			switch change.Action {
+			case ActionCreate:
+				err = s.create(tx, change.record)
+			case ActionUpdate:
+				err = s.update(tx, change.id, change.record)
+			default:
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("%s %s [%s] 失败: %w", change.Action, change.Section, change.Key, err)
+			}
+		}
+		for i := len(plan.Changes) - 1; i >= 0; i-- {
+			change := plan.Changes[i]
+			if change.Action != ActionDelete {
+				continue
+			}
+			if err := findSection(change.Section).remove(tx, change.id); err != nil {
+				return fmt.Errorf("delete %s [%s] 失败: %w", change.Section, change.Key, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// String 以可读文本输出变更计划
+func (p *Plan) String() string {
+	if len(p.Changes) == 0 {
+		return "无变更，数据库已与配置一致\n"
+	}
+	var b strings.Builder
+	symbols := map[string]string{ActionCreate: "+", ActionUpdate: "~", ActionDelete: "-"}
+	for _, change := range p.Changes {
+		fmt.Fprintf(&b, "%s %s [%s]\n", symbols[change.Action], change.Section, change.Key)
+		for _, field := range change.Fields {
+			fmt.Fprintf(&b, "    %s: %s -> %s\n", field.Field, formatValue(field.Old), formatValue(field.New))
+		}
+	}
+	fmt.Fprintf(&b, "\n计划: 新增 %d, 修改 %d, 删除 %d\n",
+		p.Summary[ActionCreate], p.Summary[ActionUpdate], p.Summary[ActionDelete])
+	return b.String()
+}
+
+// HasSection 计划中是否包含指定分区的变更
+func (p *Plan) HasSection(name string) bool {
+	for _, change := range p.Changes {
+		if change.Section == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ChangedKeys 返回指定分区中发生变更的键
+func (p *Plan) ChangedKeys(section string) []string {
+	var keys []string
+	for _, change := range p.Changes {
+		if change.Section == section {
+			keys = append(keys, change.Key)
+		}
+	}
+	return keys
+}
+
+// planSection 计算单个分区的变更（纯函数，便于测试）
+func planSection(s section, current map[string]currentRecord, order []string, desired []Record, prune bool) ([]Change, error) {
+	var changes []Change
+	seen := make(map[string]bool, len(desired))
+
+	for i, raw := range desired {
+		record, err := normalize(expandEnv(raw))
+		if err != nil {
+			return nil, fmt.Errorf("%s 第 %d 条: %w", s.name(), i+1, err)
+		}
+		for _, field := range s.omitFields() {
+			delete(record, field)
+		}
+		key := s.key(record)
+		if key == "" {
+			return nil, fmt.Errorf("%s 第 %d 条缺少键字段: %s", s.name(), i+1, strings.Join(s.keyFields(), ", "))
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("%s 存在重复条目: %s", s.name(), key)
+		}
+		seen[key] = true
+
+		existing, ok := current[key]
+		if !ok {
+			changes = append(changes, Change{Section: s.name(), Key: key, Action: ActionCreate, record: record})
+			continue
+		}
+		if fields := diffRecord(existing.record, record); len(fields) > 0 {
+			changes = append(changes, Change{Section: s.name(), Key: key, Action: ActionUpdate, Fields: fields, id: existing.id, record: record})
+		}
+	}
+
+	if prune {
+		for _, key := range order {
+			if !seen[key] {
+				changes = append(changes, Change{Section: s.name(), Key: key, Action: ActionDelete, id: current[key].id})
+			}
+		}
+	}
+	return changes, nil
+}
+
+// diffRecord 对比字段（只比较期望记录中出现的字段）
+func diffRecord(current, desired Record) []FieldChange {
+	fields := make([]string, 0, len(desired))
+	for field := range desired {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	var changes []FieldChange
+	for _, field := range fields {
+		oldValue, newValue := current[field], desired[field]
+		if !valuesEqual(oldValue, newValue) {
+			changes = append(changes, FieldChange{Field: field, Old: oldValue, New: newValue})
+		}
+	}
+	return changes
+}
+
+// valuesEqual 比较两个 JSON 值（nil 与零值视为相等，避免 omitempty 字段产生伪差异）
+func valuesEqual(a, b interface{}) bool {
+	if isZero(a) && isZero(b) {
+		return true
+	}
+	ja, _ := json.Marshal(a)
+	jb, _ := json.Marshal(b)
+	return string(ja) == string(jb)
+}
+
+func isZero(v interface{}) bool {
+	switch val := v.(type) {
+	case nil:
+		return true
+	case string:
+		return val == ""
+	case float64:
+		return val == 0
+	case bool:
+		return !val
+	}
+	return false
+}
+
+// normalize 通过 JSON 往返统一值类型（YAML 整数 → float64 等）
+func normalize(record Record) (Record, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	var out Record
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// envRefPattern 环境变量引用（整个值为 ${VAR} 时替换），用于避免将密码提交到 git
+var envRefPattern = regexp.MustCompile(`^\$\{([A-Za-z_][A-Za-z0-9_]*)\}$`)
+
+// expandEnv 替换记录中的环境变量引用
+func expandEnv(record Record) Record {
+	out := make(Record, len(record))
+	for k, v := range record {
+		if s, ok := v.(string); ok {
+			if m := envRefPattern.FindStringSubmatch(s); m != nil {
+				v = os.Getenv(m[1])
+			}
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// formatValue 格式化字段值用于计划输出
+func formatValue(v interface{}) string {
+	if v == nil {
+		return "<nil>"
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	s := string(data)
+	if len(s) > 80 {
+		s = s[:77] + "..."
+	}
+	return s
+}
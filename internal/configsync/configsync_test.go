@@ -0,0 +1,149 @@
+package configsync
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseDocument(t *testing.T) {
+	doc, err := Parse([]byte(`
+version: 1
+system_configs:
+  - key: session_ttl
+    value: "60"
+error_rules:
+  - http_status_code: 429
+    keyword: ""
+    target_status: rate_limited
+    priority: 100
+`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(doc.Sections["system_configs"]) != 1 || len(doc.Sections["error_rules"]) != 1 {
+		t.Fatalf("unexpected sections: %+v", doc.Sections)
+	}
+
+	data, err := Marshal(doc)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	again, err := Parse(data)
+	if err != nil || again.Sections["system_configs"][0]["value"] != "60" {
+		t.Fatalf("round trip failed: %v\n%s", err, data)
+	}
+
+	if _, err := Parse([]byte("version: 1\nunknown_things: []\n")); err == nil {
+		t.Fatal("expected error for unknown section")
+	}
+	if _, err := Parse([]byte("version: 99\n")); err == nil {
+		t.Fatal("expected error for future version")
+	}
+}
+
+func TestPlanSection(t *testing.T) {
+	s := findSection("error_rules")
+	current := map[string]currentRecord{
+		"429//rate_limited": {id: 1, record: Record{"http_status_code": float64(429), "keyword": "", "target_status": "rate_limited", "priority": float64(100), "enabled": true}},
+		"503//overloaded":   {id: 2, record: Record{"http_status_code": float64(503), "keyword": "", "target_status": "overloaded", "priority": float64(100), "enabled": true}},
+	}
+	order := []string{"429//rate_limited", "503//overloaded"}
+	desired := []Record{
+		{"http_status_code": 429, "keyword": "", "target_status": "rate_limited", "priority": 120, "enabled": true},
+		{"http_status_code": 401, "keyword": "", "target_status": "invalid", "priority": 100},
+	}
+
+	changes, err := planSection(s, current, order, desired, false)
+	if err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+	if len(changes) != 2 {
+		t.Fatalf("expected 2 changes, got %+v", changes)
+	}
+	if changes[0].Action != ActionUpdate || changes[0].id != 1 || len(changes[0].Fields) != 1 || changes[0].Fields[0].Field != "priority" {
+		t.Fatalf("unexpected update: %+v", changes[0])
+	}
+	if changes[1].Action != ActionCreate || changes[1].Key != "401//invalid" {
+		t.Fatalf("unexpected create: %+v", changes[1])
+	}
+
+	changes, err = planSection(s, current, order, desired, true)
+	if err != nil {
+		t.Fatalf("plan with prune: %v", err)
+	}
+	if last := changes[len(changes)-1]; last.Action != ActionDelete || last.id != 2 {
+		t.Fatalf("expected delete of 503 rule, got %+v", last)
+	}
+
+	dup := append(desired, desired[0])
+	if _, err := planSection(s, current, order, dup, false); err == nil {
+		t.Fatal("expected duplicate key error")
+	}
+}
+
+func TestPlanSectionIgnoresOmittedAndEmptyFields(t *testing.T) {
+	s := findSection("proxies")
+	current := map[string]currentRecord{
+		"hk": {id: 3, record: Record{"name": "hk", "host": "1.2.3.4", "port": float64(8080), "remark": ""}},
+	}
+	desired := []Record{{"name": "hk", "host": "1.2.3.4", "port": 8080, "test_status": "failed", "id": 99}}
+
+	changes, err := planSection(s, current, []string{"hk"}, desired, false)
+	if err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+	if len(changes) != 0 {
+		t.Fatalf("expected no changes, got %+v", changes)
+	}
+}
+
+func TestExpandEnv(t *testing.T) {
+	t.Setenv("CONFIGSYNC_TEST_PASSWORD", "s3cret")
+	record := expandEnv(Record{"password": "${CONFIGSYNC_TEST_PASSWORD}", "pattern": "^foo${bar}$"})
+	if record["password"] != "s3cret" {
+		t.Fatalf("expected env expansion, got %v", record["password"])
+	}
+	if record["pattern"] != "^foo${bar}$" {
+		t.Fatalf("partial references should be kept, got %v", record["pattern"])
+	}
+}
+
+func TestLoadDirMergesFiles(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "10-configs.yaml"), []byte("system_configs:\n  - key: a\n    value: \"1\"\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "20-more.yml"), []byte("system_configs:\n  - key: b\n    value: \"2\"\nmodels:\n  - name: m\n"), 0o644)
+
+	doc, err := LoadDir(dir)
+	if err != nil {
+		t.Fatalf("load dir: %v", err)
+	}
+	if len(doc.Sections["system_configs"]) != 2 || len(doc.Sections["models"]) != 1 {
+		t.Fatalf("unexpected merge result: %+v", doc.Sections)
+	}
+
+	plan := &Plan{Summary: map[string]int{}}
+	if !strings.Contains(plan.String(), "无变更") {
+		t.Fatal("empty plan should report no changes")
+	}
+}
+
+func TestOptionalKeyFields(t *testing.T) {
+	s := findSection("error_rules")
+	base := Record{"http_status_code": 429, "keyword": "", "target_status": "rate_limited"}
+	if got := s.key(base); got != "429//rate_limited" {
+		t.Fatalf("unexpected key without optional fields: %q", got)
+	}
+
+	withEmpty := Record{"http_status_code": 429, "keyword": "", "target_status": "rate_limited", "header_name": ""}
+	if s.key(withEmpty) != s.key(base) {
+		t.Fatal("empty optional field should not change key")
+	}
+
+	withHeader := Record{"http_status_code": 429, "keyword": "", "target_status": "rate_limited",
+		"header_name": "anthropic-ratelimit-unified-status", "header_value": "rejected"}
+	if got := s.key(withHeader); got != "429//rate_limited/header_name=anthropic-ratelimit-unified-status/header_value=rejected" {
+		t.Fatalf("unexpected key with optional fields: %q", got)
+	}
+}
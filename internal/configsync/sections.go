@@ -0,0 +1,353 @@
+/*
+ * 文件作用：配置同步的分区定义，描述每类配置对应的表、键字段和特殊字段处理
+ * 负责功能：
+ *   - 通用表分区（基于 JSON 字段名读写 GORM 模型）
+ *   - 分区键计算、忽略字段、敏感字段
+ *   - 客户端过滤规则的外键转换（client_type_id ↔ client_id）
+ *   - 代理所属代理池的外键转换（pool_id ↔ 代理池名称）
+ * 重要程度：⭐⭐⭐ 一般（运维功能）
+ * 依赖模块：model, gorm
+ */
+package configsync
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"cli-proxy/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// currentRecord 数据库中的现有记录
+type currentRecord struct {
+	id     uint
+	record Record
+}
+
+// section 配置分区
+type section interface {
+	name() string
+	keyFields() []string
+	key(record Record) string
+	omitFields() []string
+	secretFields() []string
+	load(db *gorm.DB) (map[string]currentRecord, []string, error)
+	create(tx *gorm.DB, record Record) error
+	update(tx *gorm.DB, id uint, record Record) error
+	remove(tx *gorm.DB, id uint) error
+}
+
+// commonOmitFields 所有分区都不导出的字段
+var commonOmitFields = []string{"id", "created_at", "updated_at", "deleted_at"}
+
+// singletonKey 单例分区的固定键
+const singletonKey = "global"
+
+// sections 全部分区（按应用顺序，被依赖的分区在前）
+var sections = []section{
+	&tableSection[model.SystemConfig]{sectionName: "system_configs", keys: []string{"key"}},
+	&tableSection[model.ErrorRule]{
+		sectionName: "error_rules",
+		keys:        []string{"http_status_code", "keyword", "target_status"},
+		optionalKeys: []string{"status_codes", "message_regex", "json_path", "json_value",
+			"header_name", "header_value", "account_types", "platforms"},
+	},
+	&tableSection[model.ErrorMessage]{sectionName: "error_messages", keys: []string{"error_type"}, omit: []string{"original_message"}},
+	&tableSection[model.ModelMapping]{sectionName: "model_mappings", keys: []string{"source_model"}},
+	&tableSection[model.AIModel]{sectionName: "models", keys: []string{"name"}},
+	&tableSection[model.ClientRuleSet]{sectionName: "client_rule_sets", keys: []string{"set_key"}},
+	&tableSection[model.ClientType]{sectionName: "client_types", keys: []string{"client_id"}},
+	&tableSection[model.ClientFilterRule]{
+		sectionName: "client_filter_rules",
+		keys:        []string{"client_type", "rule_key"},
+		omit:        []string{"client_type_id"},
+		exportHook:  exportClientTypeRef,
+		resolveHook: resolveClientTypeRef,
+	},
+	&tableSection[model.ClientFilterConfig]{sectionName: "client_filter_config", singleton: true},
+	&tableSection[model.ProxyPool]{sectionName: "proxy_pools", keys: []string{"name"}},
+	&tableSection[model.Proxy]{
+		sectionName: "proxies",
+		keys:        []string{"name"},
+		omit:        []string{"test_status", "test_latency", "test_error", "last_test_at", "egress_ip", "egress_country", "egress_checked_at"},
+		secrets:     []string{"password"},
+		exportHook:  exportProxyPoolRef,
+		resolveHook: resolveProxyPoolRef,
+	},
+	&tableSection[model.Gateway]{
+		sectionName: "gateways",
+		keys:        []string{"name"},
+		omit:        []string{"test_status", "test_latency", "test_error", "last_test_at"},
+	},
+}
+
+// findSection 按名称查找分区
+func findSection(name string) section {
+	for _, s := range sections {
+		if s.name() == name {
+			return s
+		}
+	}
+	return nil
+}
+
+// tableSection 通用表分区
+type tableSection[T any] struct {
+	sectionName string
+	keys        []string
+	omit        []string
+	// 可选键字段：有值时追加到键中，缺失或为空时忽略（兼容新增字段前导出的配置）
+	optionalKeys []string
+	secrets      []string
+	singleton    bool // 全局唯一配置（如客户端过滤全局配置）
+
+	exportHook  func(db *gorm.DB, record Record) error // 导出前转换（如外键 ID → 业务键）
+	resolveHook func(tx *gorm.DB, record Record) error // 写入前转换（如业务键 → 外键 ID）
+}
+
+func (s *tableSection[T]) name() string { return s.sectionName }
+
+func (s *tableSection[T]) keyFields() []string { return s.keys }
+
+func (s *tableSection[T]) secretFields() []string { return s.secrets }
+
+func (s *tableSection[T]) omitFields() []string {
+	return append(append([]string{}, commonOmitFields...), s.omit...)
+}
+
+// key 计算记录键（任一键字段缺失时返回空字符串）
+func (s *tableSection[T]) key(record Record) string {
+	if s.singleton {
+		return singletonKey
+	}
+	parts := make([]string, 0, len(s.keys))
+	for _, field := range s.keys {
+		value, ok := record[field]
+		if !ok {
+			return ""
+		}
+		parts = append(parts, keyPart(value))
+	}
+	for _, field := range s.optionalKeys {
+		if part := keyPart(record[field]); part != "" {
+			parts = append(parts, field+"="+part)
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// load 读取全部记录，返回 键 → 记录 及按 ID 排序的键列表
+func (s *tableSection[T]) load(db *gorm.DB) (map[string]currentRecord, []string, error) {
+	var items []T
+	if err := db.Order("id ASC").Find(&items).Error; err != nil {
+		return nil, nil, err
+	}
+
+	current := make(map[string]currentRecord, len(items))
+	order := make([]string, 0, len(items))
+	for i := range items {
+		record, err := toRecord(&items[i])
+		if err != nil {
+			return nil, nil, err
+		}
+		id := uint(0)
+		if v, ok := record["id"].(float64); ok {
+			id = uint(v)
+		}
+		for _, field := range s.omitFields() {
+			delete(record, field)
+		}
+		if s.exportHook != nil {
+			if err := s.exportHook(db, record); err != nil {
+				return nil, nil, err
+			}
+		}
+		key := s.key(record)
+		if _, dup := current[key]; dup {
+			// 数据库中已有重复键时以最早的记录为准
+			continue
+		}
+		current[key] = currentRecord{id: id, record: record}
+		order = append(order, key)
+	}
+	return current, order, nil
+}
+
+// create 新增记录（只写入文档中出现的字段，其余字段使用数据库默认值）
+func (s *tableSection[T]) create(tx *gorm.DB, record Record) error {
+	record, err := s.resolve(tx, record)
+	if err != nil {
+		return err
+	}
+	var item T
+	if err := fromRecord(record, &item); err != nil {
+		return err
+	}
+	fields := structFieldsFor[T](record)
+	if len(fields) == 0 {
+		return tx.Create(&item).Error
+	}
+	return tx.Select(fields).Create(&item).Error
+}
+
+// update 修改记录（将文档字段合并到现有记录后整体保存）
+func (s *tableSection[T]) update(tx *gorm.DB, id uint, record Record) error {
+	record, err := s.resolve(tx, record)
+	if err != nil {
+		return err
+	}
+	var existing T
+	if err := tx.First(&existing, id).Error; err != nil {
+		return err
+	}
+	merged, err := toRecord(&existing)
+	if err != nil {
+		return err
+	}
+	for k, v := range record {
+		merged[k] = v
+	}
+	var item T
+	if err := fromRecord(merged, &item); err != nil {
+		return err
+	}
+	return tx.Save(&item).Error
+}
+
+// remove 删除记录
+func (s *tableSection[T]) remove(tx *gorm.DB, id uint) error {
+	return tx.Delete(new(T), id).Error
+}
+
+// resolve 执行写入前转换（在副本上进行，不影响计划中的记录）
+func (s *tableSection[T]) resolve(tx *gorm.DB, record Record) (Record, error) {
+	out := make(Record, len(record))
+	for k, v := range record {
+		out[k] = v
+	}
+	if s.resolveHook != nil {
+		if err := s.resolveHook(tx, out); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// toRecord 模型 → 记录
+func toRecord(item interface{}) (Record, error) {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return nil, err
+	}
+	var record Record
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// fromRecord 记录 → 模型
+func fromRecord(record Record, item interface{}) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, item)
+}
+
+// structFieldsFor 将记录中的 JSON 字段名转换为模型的结构体字段名（含时间戳字段）
+func structFieldsFor[T any](record Record) []string {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	var fields []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Name == "CreatedAt" || f.Name == "UpdatedAt" {
+			fields = append(fields, f.Name)
+			continue
+		}
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		if _, ok := record[name]; ok && f.Tag.Get("gorm") != "-" {
+			fields = append(fields, f.Name)
+		}
+	}
+	return fields
+}
+
+// keyPart 格式化键字段值
+func keyPart(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case float64:
+		if val == float64(int64(val)) {
+			return strconv.FormatInt(int64(val), 10)
+		}
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case string:
+		return val
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// exportClientTypeRef 客户端过滤规则导出时将 client_type_id 转换为 client_id
+func exportClientTypeRef(db *gorm.DB, record Record) error {
+	delete(record, "client_type")
+	id, _ := record["client_type_id"].(float64)
+	var ct model.ClientType
+	if err := db.Select("client_id").First(&ct, uint(id)).Error; err != nil {
+		return fmt.Errorf("客户端规则 %v 关联的客户端类型不存在", record["rule_key"])
+	}
+	record["client_type"] = ct.ClientID
+	delete(record, "client_type_id")
+	return nil
+}
+
+// resolveClientTypeRef 客户端过滤规则写入前将 client_type（client_id）转换为 client_type_id
+func resolveClientTypeRef(tx *gorm.DB, record Record) error {
+	clientID, _ := record["client_type"].(string)
+	var ct model.ClientType
+	if err := tx.Where("client_id = ?", clientID).First(&ct).Error; err != nil {
+		return fmt.Errorf("客户端类型不存在: %s", clientID)
+	}
+	record["client_type_id"] = ct.ID
+	delete(record, "client_type")
+	return nil
+}
+
+// exportProxyPoolRef 代理导出时将 pool_id 转换为代理池名称
+func exportProxyPoolRef(db *gorm.DB, record Record) error {
+	id, ok := record["pool_id"].(float64)
+	delete(record, "pool_id")
+	if !ok {
+		return nil
+	}
+	var pool model.ProxyPool
+	if err := db.Select("name").First(&pool, uint(id)).Error; err != nil {
+		return fmt.Errorf("代理 %v 所属的代理池不存在", record["name"])
+	}
+	record["pool"] = pool.Name
+	return nil
+}
+
+// resolveProxyPoolRef 代理写入前将 pool（代理池名称）转换为 pool_id，未指定时移出代理池
+func resolveProxyPoolRef(tx *gorm.DB, record Record) error {
+	name, _ := record["pool"].(string)
+	delete(record, "pool")
+	if name == "" {
+		record["pool_id"] = nil
+		return nil
+	}
+	var pool model.ProxyPool
+	if err := tx.Where("name = ?", name).First(&pool).Error; err != nil {
+		return fmt.Errorf("代理池不存在: %s", name)
+	}
+	record["pool_id"] = pool.ID
+	return nil
+}
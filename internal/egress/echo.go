@@ -0,0 +1,136 @@
+/*
+ * 文件作用：出口 IP 回显服务客户端
+ * 负责功能：
+ *   - 请求回显服务获取出口 IP 与国家/地区
+ *   - 兼容常见回显服务响应格式（JSON 字段或纯文本 IP）
+ * 重要程度：⭐⭐ 辅助（出口 IP 探测）
+ * 依赖模块：utils
+ */
+package egress
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"cli-proxy/pkg/utils"
+)
+
+// DefaultEchoURL 默认回显服务（返回 JSON，含 ip 与 country 字段）
+const DefaultEchoURL = "https://ipinfo.io/json"
+
+// maxEchoResponseBytes 回显服务响应体上限
+const maxEchoResponseBytes = 64 << 10
+
+// ErrInvalidEchoResponse 回显服务响应中没有有效 IP
+var ErrInvalidEchoResponse = errors.New("回显服务响应中没有有效的 IP 地址")
+
+// Info 出口信息
+type Info struct {
+	IP      string `json:"ip"`
+	Country string `json:"country,omitempty"` // 国家/地区代码（大写，回显服务未返回时为空）
+}
+
+// echoIPFields / echoCountryFields 常见回显服务的字段名（按优先级）
+var (
+	echoIPFields      = []string{"ip", "query", "origin", "ip_addr", "address"}
+	echoCountryFields = []string{"country", "country_code", "countryCode", "cc"}
+)
+
+// Discover 通过 client 请求回显服务获取出口信息
+func Discover(ctx context.Context, client *http.Client, echoURL string) (Info, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, echoURL, nil)
+	if err != nil {
+		return Info{}, fmt.Errorf("回显服务地址无效: %w", err)
+	}
+	req.Header.Set("Accept", "application/json, text/plain")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return Info{}, err
+	}
+	defer resp.Body.Close()
+
+	body, err := utils.ReadAllWithLimit(resp.Body, maxEchoResponseBytes)
+	if err != nil {
+		return Info{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Info{}, fmt.Errorf("回显服务返回 HTTP %d", resp.StatusCode)
+	}
+	return ParseEchoResponse(body)
+}
+
+// ParseEchoResponse 解析回显服务响应：JSON 对象（ip/query/origin 等字段，可带 country 等字段）或纯文本 IP
+func ParseEchoResponse(body []byte) (Info, error) {
+	text := strings.TrimSpace(string(body))
+
+	var fields map[string]interface{}
+	if strings.HasPrefix(text, "{") {
+		if err := json.Unmarshal([]byte(text), &fields); err != nil {
+			return Info{}, ErrInvalidEchoResponse
+		}
+		var info Info
+		for _, name := range echoIPFields {
+			if ip := normalizeIP(stringField(fields, name)); ip != "" {
+				info.IP = ip
+				break
+			}
+		}
+		if info.IP == "" {
+			return Info{}, ErrInvalidEchoResponse
+		}
+		for _, name := range echoCountryFields {
+			if country := normalizeCountry(stringField(fields, name)); country != "" {
+				info.Country = country
+				break
+			}
+		}
+		return info, nil
+	}
+
+	// 纯文本：取第一行
+	if i := strings.IndexAny(text, "\r\n"); i >= 0 {
+		text = text[:i]
+	}
+	if ip := normalizeIP(text); ip != "" {
+		return Info{IP: ip}, nil
+	}
+	return Info{}, ErrInvalidEchoResponse
+}
+
+// stringField 读取字符串字段
+func stringField(fields map[string]interface{}, name string) string {
+	s, _ := fields[name].(string)
+	return s
+}
+
+// normalizeIP 校验并规范化 IP（httpbin 等服务可能返回 "a, b" 形式，取第一个）
+func normalizeIP(s string) string {
+	if i := strings.IndexByte(s, ','); i >= 0 {
+		s = s[:i]
+	}
+	ip := net.ParseIP(strings.TrimSpace(s))
+	if ip == nil {
+		return ""
+	}
+	return ip.String()
+}
+
+// normalizeCountry 规范化国家/地区代码（只接受 2~3 位字母代码）
+func normalizeCountry(s string) string {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if len(s) < 2 || len(s) > 3 {
+		return ""
+	}
+	for _, r := range s {
+		if r < 'A' || r > 'Z' {
+			return ""
+		}
+	}
+	return s
+}
@@ -0,0 +1,86 @@
+package egress
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseEchoResponse(t *testing.T) {
+	cases := []struct {
+		body string
+		want Info
+	}{
+		{`{"ip":"203.0.113.7","country":"us","city":"Ashburn"}`, Info{IP: "203.0.113.7", Country: "US"}},
+		{`{"status":"success","query":"198.51.100.2","countryCode":"JP"}`, Info{IP: "198.51.100.2", Country: "JP"}},
+		{`{"origin":"192.0.2.1, 10.0.0.1"}`, Info{IP: "192.0.2.1"}},
+		{`{"ip":"2001:db8::1","country":"Germany","country_code":"DE"}`, Info{IP: "2001:db8::1", Country: "DE"}},
+		{"192.0.2.44\n", Info{IP: "192.0.2.44"}},
+	}
+	for _, c := range cases {
+		got, err := ParseEchoResponse([]byte(c.body))
+		if err != nil || got != c.want {
+			t.Errorf("ParseEchoResponse(%q) = %+v, %v; want %+v", c.body, got, err, c.want)
+		}
+	}
+
+	for _, body := range []string{"", "<html>blocked</html>", `{"ip":"not-an-ip"}`, `{"ip":`} {
+		if _, err := ParseEchoResponse([]byte(body)); err == nil {
+			t.Errorf("expected error for %q", body)
+		}
+	}
+}
+
+func TestDiscoverWithStubEndpoint(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"ip":"203.0.113.50","country":"SG"}`))
+	}))
+	defer srv.Close()
+
+	info, err := Discover(context.Background(), srv.Client(), srv.URL)
+	if err != nil || info != (Info{IP: "203.0.113.50", Country: "SG"}) {
+		t.Fatalf("unexpected result: %+v, %v", info, err)
+	}
+}
+
+func TestObserveTracksChanges(t *testing.T) {
+	now := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	tr := newTracker(nil)
+	tr.now = func() time.Time { return now }
+	tr.SetProxyEgress(1, Info{IP: "203.0.113.1", Country: "US"})
+	tr.SetProxyEgress(2, Info{IP: "203.0.113.2", Country: "US"})
+	tr.SetProxyEgress(3, Info{IP: "198.51.100.3", Country: "JP"})
+
+	// 未开启跟踪时忽略
+	if _, _, persist := tr.observe(100, 1); persist {
+		t.Fatal("expected no-op while disabled")
+	}
+	tr.SetEnabled(true)
+
+	// 首次记录
+	rec, change, persist := tr.observe(100, 1)
+	if !persist || change != nil || rec.IP != "203.0.113.1" || tr.Country(100) != "US" {
+		t.Fatalf("unexpected first observe: %+v %+v %v", rec, change, persist)
+	}
+	// 未变化时节流持久化
+	now = now.Add(time.Minute)
+	if _, _, persist := tr.observe(100, 1); persist {
+		t.Fatal("expected throttled persist")
+	}
+	// 同地区变化
+	_, change, _ = tr.observe(100, 2)
+	if change == nil || change.CrossRegion || change.OldIP != "203.0.113.1" {
+		t.Fatalf("expected same-region change, got %+v", change)
+	}
+	// 跨地区变化
+	rec, change, _ = tr.observe(100, 3)
+	if change == nil || !change.CrossRegion || rec.ChangeCount != 2 || tr.Country(100) != "JP" {
+		t.Fatalf("expected cross-region change, got %+v %+v", rec, change)
+	}
+	// 未探测的出口忽略
+	if _, _, persist := tr.observe(100, 9); persist {
+		t.Fatal("expected unknown egress ignored")
+	}
+}
@@ -0,0 +1,245 @@
+/*
+ * 文件作用：账户出口 IP 跟踪器
+ * 负责功能：
+ *   - 缓存各代理（及直连）最近一次探测到的出口 IP 与国家/地区
+ *   - 请求成功后记录账户使用的出口 IP，变化时告警并写入变更记录
+ *   - 提供账户已知出口地区（代理池分配与改绑代理时的地区校验使用）
+ * 重要程度：⭐⭐⭐ 一般（账户风控）
+ * 依赖模块：model, repository, logger
+ */
+package egress
+
+import (
+	"sync"
+	"time"
+
+	"cli-proxy/internal/model"
+	"cli-proxy/internal/repository"
+	"cli-proxy/pkg/logger"
+)
+
+// DirectProxyID 直连出口使用的代理 ID
+const DirectProxyID uint = 0
+
+// lastSeenPersistInterval 出口 IP 未变化时，最近出现时间的最小持久化间隔
+const lastSeenPersistInterval = 10 * time.Minute
+
+// Tracker 账户出口 IP 跟踪器
+type Tracker struct {
+	repo *repository.AccountEgressRepository
+	now  func() time.Time
+
+	mu          sync.Mutex
+	enabled     bool
+	proxies     map[uint]Info                 // 代理 ID → 出口（DirectProxyID 为直连）
+	accounts    map[uint]*model.AccountEgress // 账户 ID → 当前出口
+	persistedAt map[uint]time.Time            // 账户 ID → 最近一次持久化时间
+}
+
+var (
+	defaultTracker *Tracker
+	trackerOnce    sync.Once
+)
+
+// GetTracker 获取账户出口 IP 跟踪器单例（默认不启用，由出口 IP 检查配置开启后 Refresh 加载数据）
+func GetTracker() *Tracker {
+	trackerOnce.Do(func() {
+		defaultTracker = newTracker(repository.NewAccountEgressRepository())
+	})
+	return defaultTracker
+}
+
+func newTracker(repo *repository.AccountEgressRepository) *Tracker {
+	return &Tracker{
+		repo:        repo,
+		now:         time.Now,
+		proxies:     make(map[uint]Info),
+		accounts:    make(map[uint]*model.AccountEgress),
+		persistedAt: make(map[uint]time.Time),
+	}
+}
+
+// SetEnabled 开启或关闭账户出口 IP 跟踪
+func (t *Tracker) SetEnabled(enabled bool) {
+	t.mu.Lock()
+	t.enabled = enabled
+	t.mu.Unlock()
+}
+
+// Enabled 是否开启账户出口 IP 跟踪
+func (t *Tracker) Enabled() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.enabled
+}
+
+// Refresh 从数据库加载代理出口与账户当前出口
+func (t *Tracker) Refresh() {
+	log := logger.GetLogger("proxy")
+	proxies, err := t.repo.ListProxyEgress()
+	if err != nil {
+		log.Error("加载代理出口 IP 失败: %v", err)
+		return
+	}
+	records, err := t.repo.ListAll()
+	if err != nil {
+		log.Error("加载账户出口 IP 失败: %v", err)
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	direct, hasDirect := t.proxies[DirectProxyID]
+	t.proxies = make(map[uint]Info, len(proxies)+1)
+	if hasDirect {
+		t.proxies[DirectProxyID] = direct
+	}
+	for _, p := range proxies {
+		t.proxies[p.ID] = Info{IP: p.EgressIP, Country: p.EgressCountry}
+	}
+	t.accounts = make(map[uint]*model.AccountEgress, len(records))
+	t.persistedAt = make(map[uint]time.Time, len(records))
+	for i := range records {
+		t.accounts[records[i].AccountID] = &records[i]
+		t.persistedAt[records[i].AccountID] = records[i].LastSeenAt
+	}
+}
+
+// SetProxyEgress 更新代理（DirectProxyID 为直连）的出口信息
+func (t *Tracker) SetProxyEgress(proxyID uint, info Info) {
+	t.mu.Lock()
+	t.proxies[proxyID] = info
+	t.mu.Unlock()
+}
+
+// ProxyEgress 获取代理（DirectProxyID 为直连）最近一次探测到的出口信息
+func (t *Tracker) ProxyEgress(proxyID uint) (Info, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	info, ok := t.proxies[proxyID]
+	return info, ok
+}
+
+// Country 获取账户已知的出口国家/地区，未知或未开启跟踪时返回空
+func (t *Tracker) Country(accountID uint) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.enabled {
+		return ""
+	}
+	if rec := t.accounts[accountID]; rec != nil {
+		return rec.Country
+	}
+	return ""
+}
+
+// Reset 清除账户当前出口记录（确认切换地区或删除账户后调用，下次请求重新记录）
+func (t *Tracker) Reset(accountID uint) error {
+	t.mu.Lock()
+	delete(t.accounts, accountID)
+	delete(t.persistedAt, accountID)
+	t.mu.Unlock()
+	return t.repo.DeleteByAccountID(accountID)
+}
+
+// Observe 记录账户经 proxyID（DirectProxyID 为直连）请求成功，出口 IP 变化时告警并写入变更记录
+// 未开启跟踪或该出口尚未探测时忽略
+func (t *Tracker) Observe(accountID, proxyID uint) {
+	record, change, persist := t.observe(accountID, proxyID)
+	if !persist {
+		return
+	}
+
+	if change != nil {
+		log := logger.GetLogger("proxy")
+		msg := "账户出口 IP 发生变化"
+		if change.CrossRegion {
+			msg = "账户出口 IP 跨地区变化"
+		}
+		log.WarnZ(msg,
+			logger.AccountID(accountID),
+			logger.String("old_ip", change.OldIP),
+			logger.String("old_country", change.OldCountry),
+			logger.Uint("old_proxy_id", change.OldProxyID),
+			logger.String("new_ip", change.NewIP),
+			logger.String("new_country", change.NewCountry),
+			logger.Uint("new_proxy_id", change.NewProxyID),
+		)
+	}
+
+	go func() {
+		log := logger.GetLogger("proxy")
+		if err := t.repo.Upsert(&record); err != nil {
+			log.Error("保存账户出口 IP 失败: account_id=%d, error=%v", accountID, err)
+		}
+		if change != nil {
+			if err := t.repo.CreateChange(change); err != nil {
+				log.Error("保存账户出口 IP 变更记录失败: account_id=%d, error=%v", accountID, err)
+			}
+		}
+	}()
+}
+
+// observe 更新内存中的账户出口，返回需持久化的记录、变更记录（未变化时为 nil）及是否需要持久化
+func (t *Tracker) observe(accountID, proxyID uint) (model.AccountEgress, *model.AccountEgressChange, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.enabled {
+		return model.AccountEgress{}, nil, false
+	}
+	info, ok := t.proxies[proxyID]
+	if !ok || info.IP == "" {
+		return model.AccountEgress{}, nil, false
+	}
+	now := t.now()
+
+	rec := t.accounts[accountID]
+	if rec == nil {
+		rec = &model.AccountEgress{
+			AccountID:   accountID,
+			IP:          info.IP,
+			Country:     info.Country,
+			ProxyID:     proxyID,
+			FirstSeenAt: now,
+			LastSeenAt:  now,
+		}
+		t.accounts[accountID] = rec
+		t.persistedAt[accountID] = now
+		return *rec, nil, true
+	}
+
+	if rec.IP == info.IP {
+		rec.LastSeenAt = now
+		changedProxy := rec.ProxyID != proxyID
+		rec.ProxyID = proxyID
+		if info.Country != "" {
+			rec.Country = info.Country
+		}
+		if !changedProxy && now.Sub(t.persistedAt[accountID]) < lastSeenPersistInterval {
+			return *rec, nil, false
+		}
+		t.persistedAt[accountID] = now
+		return *rec, nil, true
+	}
+
+	change := &model.AccountEgressChange{
+		AccountID:   accountID,
+		OldIP:       rec.IP,
+		OldCountry:  rec.Country,
+		OldProxyID:  rec.ProxyID,
+		NewIP:       info.IP,
+		NewCountry:  info.Country,
+		NewProxyID:  proxyID,
+		CrossRegion: rec.Country != "" && info.Country != "" && rec.Country != info.Country,
+		CreatedAt:   now,
+	}
+	rec.IP = info.IP
+	rec.Country = info.Country
+	rec.ProxyID = proxyID
+	rec.ChangeCount++
+	rec.FirstSeenAt = now
+	rec.LastSeenAt = now
+	t.persistedAt[accountID] = now
+	return *rec, change, true
+}
@@ -1,31 +1,64 @@
 /*
  * 文件作用：错误规则匹配器，根据配置规则识别上游错误类型
  * 负责功能：
- *   - 错误规则缓存管理
- *   - HTTP状态码/关键词匹配
- *   - 目标账户状态确定
+ *   - 错误规则缓存管理（正则、状态码范围预编译）
+ *   - HTTP状态码/状态码范围/关键词/正则/JSON 路径/响应头/账户类型/平台匹配
+ *   - 目标账户状态与处理动作确定
  *   - 规则优先级处理
+ *   - 规则校验与样例测试
  * 重要程度：⭐⭐⭐⭐ 重要（错误处理核心）
- * 依赖模块：model, repository, logger
+ * 依赖模块：model, repository, adapter, logger
  */
 package errormatch
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"cli-proxy/internal/model"
+	"cli-proxy/internal/proxy/adapter"
 	"cli-proxy/internal/repository"
 	"cli-proxy/pkg/logger"
 )
 
+// defaultCooldown 限流规则未设置冷却时长时的默认值
+const defaultCooldown = time.Hour
+
 // ErrorRuleMatcher 错误规则匹配器（带缓存）
 type ErrorRuleMatcher struct {
 	repo  *repository.ErrorRuleRepository
-	cache []model.ErrorRule
+	cache []*compiledRule
 	mu    sync.RWMutex
 }
 
+// ErrorContext 待匹配的错误信息
+type ErrorContext struct {
+	StatusCode  int               `json:"status_code"`            // HTTP 状态码（0 表示未知）
+	Message     string            `json:"message"`                // 错误信息（通常为上游响应体）
+	Headers     map[string]string `json:"headers,omitempty"`      // 上游响应头
+	AccountType string            `json:"account_type,omitempty"` // 账户类型
+	Platform    string            `json:"platform,omitempty"`     // 平台
+}
+
+// compiledRule 预编译的规则
+type compiledRule struct {
+	rule         model.ErrorRule
+	statusRanges [][2]int
+	messageRegex *regexp.Regexp
+	jsonPath     []string
+	jsonValues   []string
+	headerName   string
+	headerValues []string
+	accountTypes []string
+	platforms    []string
+}
+
 var (
 	defaultMatcher *ErrorRuleMatcher
 	matcherOnce    sync.Once
@@ -51,11 +84,29 @@ func (m *ErrorRuleMatcher) Refresh() {
 		return
 	}
 
+	for id, err := range m.setRules(rules) {
+		log.Warn("错误规则无效，已跳过 | RuleID: %d | 原因: %v", id, err)
+	}
+	log.Info("错误规则缓存已刷新，共 %d 条规则", m.GetRuleCount())
+}
+
+// setRules 编译并替换规则缓存，编译失败的规则跳过并返回 规则ID → 原因
+func (m *ErrorRuleMatcher) setRules(rules []model.ErrorRule) map[uint]error {
+	compiled := make([]*compiledRule, 0, len(rules))
+	skipped := make(map[uint]error)
+	for _, rule := range rules {
+		cr, err := compileRule(rule)
+		if err != nil {
+			skipped[rule.ID] = err
+			continue
+		}
+		compiled = append(compiled, cr)
+	}
+
 	m.mu.Lock()
-	m.cache = rules
+	m.cache = compiled
 	m.mu.Unlock()
-
-	log.Info("错误规则缓存已刷新，共 %d 条规则", len(rules))
+	return skipped
 }
 
 // MatchResult 匹配结果
@@ -65,19 +116,54 @@ type MatchResult struct {
 	Rule         *model.ErrorRule
 }
 
+// Cooldown 限流冷却时长（未设置时为默认 1 小时）
+func (r *MatchResult) Cooldown() time.Duration {
+	if r.Rule != nil && r.Rule.CooldownSeconds > 0 {
+		return time.Duration(r.Rule.CooldownSeconds) * time.Second
+	}
+	return defaultCooldown
+}
+
+// RetryAction 规则指定的重试动作
+func (r *MatchResult) RetryAction() string {
+	if !r.Matched || r.Rule == nil {
+		return model.RetryActionDefault
+	}
+	return r.Rule.RetryAction
+}
+
+// ClientMessage 规则指定的客户端错误提示
+func (r *MatchResult) ClientMessage() string {
+	if !r.Matched || r.Rule == nil {
+		return ""
+	}
+	return r.Rule.ClientMessage
+}
+
 // Match 匹配错误
 // httpStatusCode: HTTP状态码（0表示未知）
 // errMsg: 错误信息
 func (m *ErrorRuleMatcher) Match(httpStatusCode int, errMsg string) *MatchResult {
+	return m.MatchContext(&ErrorContext{StatusCode: httpStatusCode, Message: errMsg})
+}
+
+// MatchError 匹配上游返回的错误（自动提取状态码和响应头）
+func (m *ErrorRuleMatcher) MatchError(err error, account *model.Account) *MatchResult {
+	return m.MatchContext(NewErrorContext(err, account))
+}
+
+// MatchContext 按完整错误信息匹配
+func (m *ErrorRuleMatcher) MatchContext(ec *ErrorContext) *MatchResult {
 	m.mu.RLock()
 	rules := m.cache
 	m.mu.RUnlock()
 
-	errMsgLower := strings.ToLower(errMsg)
+	in := newMatchInput(ec)
 
 	// 规则已按优先级排序，找到第一个匹配的就返回
-	for _, rule := range rules {
-		if m.matchRule(&rule, httpStatusCode, errMsgLower) {
+	for _, cr := range rules {
+		if cr.match(in) {
+			rule := cr.rule
 			return &MatchResult{
 				Matched:      true,
 				TargetStatus: rule.TargetStatus,
@@ -89,33 +175,273 @@ func (m *ErrorRuleMatcher) Match(httpStatusCode int, errMsg string) *MatchResult
 	return &MatchResult{Matched: false}
 }
 
-// matchRule 检查单条规则是否匹配
-func (m *ErrorRuleMatcher) matchRule(rule *model.ErrorRule, httpStatusCode int, errMsgLower string) bool {
-	// 检查HTTP状态码
-	statusCodeMatch := false
-	if rule.HTTPStatusCode == 0 {
-		// 规则不限制状态码
-		statusCodeMatch = true
-	} else if rule.HTTPStatusCode == httpStatusCode {
-		// 状态码匹配
-		statusCodeMatch = true
+// NewErrorContext 从错误构建匹配信息
+func NewErrorContext(err error, account *model.Account) *ErrorContext {
+	ec := &ErrorContext{}
+	if err != nil {
+		ec.Message = err.Error()
+	}
+	var upstreamErr *adapter.UpstreamError
+	if errors.As(err, &upstreamErr) {
+		ec.StatusCode = upstreamErr.StatusCode
+		ec.Headers = upstreamErr.Headers
+	}
+	if account != nil {
+		ec.AccountType = account.Type
+		ec.Platform = account.Platform
+		if ec.Platform == "" {
+			ec.Platform = model.GetPlatformByType(account.Type)
+		}
+	}
+	return ec
+}
+
+// ValidateRule 校验规则配置（正则、状态码范围、重试动作）
+func ValidateRule(rule *model.ErrorRule) error {
+	_, err := compileRule(*rule)
+	return err
+}
+
+// TestRule 用单条规则（可未保存）测试错误样例
+func TestRule(rule *model.ErrorRule, ec *ErrorContext) (bool, error) {
+	cr, err := compileRule(*rule)
+	if err != nil {
+		return false, err
+	}
+	return cr.match(newMatchInput(ec)), nil
+}
+
+// matchInput 预处理后的匹配输入
+type matchInput struct {
+	ec           *ErrorContext
+	messageLower string
+	parsed       interface{}
+	parsedOK     bool
+	parseOnce    bool
+}
+
+func newMatchInput(ec *ErrorContext) *matchInput {
+	if ec == nil {
+		ec = &ErrorContext{}
+	}
+	return &matchInput{ec: ec, messageLower: strings.ToLower(ec.Message)}
+}
+
+// json 解析错误信息中的 JSON（只解析一次；信息可能带有 "[HTTP 429] " 之类前缀）
+func (in *matchInput) json() (interface{}, bool) {
+	if in.parseOnce {
+		return in.parsed, in.parsedOK
+	}
+	in.parseOnce = true
+	msg := in.ec.Message
+	if err := json.Unmarshal([]byte(msg), &in.parsed); err == nil {
+		in.parsedOK = true
+	} else if i := strings.Index(msg, "{"); i > 0 {
+		in.parsedOK = json.Unmarshal([]byte(msg[i:]), &in.parsed) == nil
+	}
+	return in.parsed, in.parsedOK
+}
+
+// compileRule 编译规则
+func compileRule(rule model.ErrorRule) (*compiledRule, error) {
+	cr := &compiledRule{
+		rule:         rule,
+		jsonValues:   splitLower(rule.JSONValue),
+		headerName:   strings.ToLower(strings.TrimSpace(rule.HeaderName)),
+		headerValues: splitLower(rule.HeaderValue),
+		accountTypes: splitLower(rule.AccountTypes),
+		platforms:    splitLower(rule.Platforms),
 	}
 
-	if !statusCodeMatch {
+	ranges, err := parseStatusCodes(rule.StatusCodes)
+	if err != nil {
+		return nil, err
+	}
+	cr.statusRanges = ranges
+
+	if rule.MessageRegex != "" {
+		re, err := regexp.Compile("(?i)" + rule.MessageRegex)
+		if err != nil {
+			return nil, fmt.Errorf("正则表达式无效: %v", err)
+		}
+		cr.messageRegex = re
+	}
+
+	if path := strings.TrimSpace(rule.JSONPath); path != "" {
+		cr.jsonPath = strings.Split(strings.TrimPrefix(path, "$."), ".")
+	}
+
+	switch rule.RetryAction {
+	case model.RetryActionDefault, model.RetryActionSwitch, model.RetryActionNone:
+	default:
+		return nil, fmt.Errorf("无效的重试动作: %s", rule.RetryAction)
+	}
+	if rule.CooldownSeconds < 0 {
+		return nil, errors.New("冷却时长不能为负数")
+	}
+	return cr, nil
+}
+
+// parseStatusCodes 解析状态码列表/范围，如 "429,500-599"
+func parseStatusCodes(s string) ([][2]int, error) {
+	var ranges [][2]int
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		lo, hi := part, part
+		if i := strings.Index(part, "-"); i > 0 {
+			lo, hi = strings.TrimSpace(part[:i]), strings.TrimSpace(part[i+1:])
+		}
+		low, err1 := strconv.Atoi(lo)
+		high, err2 := strconv.Atoi(hi)
+		if err1 != nil || err2 != nil || low > high {
+			return nil, fmt.Errorf("状态码范围无效: %s", part)
+		}
+		ranges = append(ranges, [2]int{low, high})
+	}
+	return ranges, nil
+}
+
+// splitLower 按逗号拆分并转小写
+func splitLower(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.ToLower(strings.TrimSpace(part)); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// containsValue 取值是否在候选列表中（列表为空表示不限制）
+func containsValue(values []string, v string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	v = strings.ToLower(strings.TrimSpace(v))
+	for _, candidate := range values {
+		if candidate == v {
+			return true
+		}
+	}
+	return false
+}
+
+// match 检查单条规则是否匹配（所有条件均需满足）
+func (cr *compiledRule) match(in *matchInput) bool {
+	rule := &cr.rule
+	ec := in.ec
+
+	// HTTP 状态码（精确）
+	if rule.HTTPStatusCode != 0 && rule.HTTPStatusCode != ec.StatusCode {
 		return false
 	}
 
-	// 检查关键词
-	keywordMatch := false
-	if rule.Keyword == "" {
-		// 规则不限制关键词
-		keywordMatch = true
-	} else if strings.Contains(errMsgLower, strings.ToLower(rule.Keyword)) {
-		// 关键词匹配
-		keywordMatch = true
+	// 状态码范围
+	if len(cr.statusRanges) > 0 {
+		inRange := false
+		for _, r := range cr.statusRanges {
+			if ec.StatusCode >= r[0] && ec.StatusCode <= r[1] {
+				inRange = true
+				break
+			}
+		}
+		if !inRange {
+			return false
+		}
 	}
 
-	return keywordMatch
+	// 账户类型 / 平台
+	if len(cr.accountTypes) > 0 && !containsValue(cr.accountTypes, ec.AccountType) {
+		return false
+	}
+	if len(cr.platforms) > 0 && !containsValue(cr.platforms, ec.Platform) {
+		return false
+	}
+
+	// 关键词
+	if rule.Keyword != "" && !strings.Contains(in.messageLower, strings.ToLower(rule.Keyword)) {
+		return false
+	}
+
+	// 正则
+	if cr.messageRegex != nil && !cr.messageRegex.MatchString(ec.Message) {
+		return false
+	}
+
+	// 响应头
+	if cr.headerName != "" {
+		value, ok := lookupHeader(ec.Headers, cr.headerName)
+		if !ok || !containsValue(cr.headerValues, value) {
+			return false
+		}
+	}
+
+	// JSON 路径
+	if len(cr.jsonPath) > 0 {
+		root, ok := in.json()
+		if !ok {
+			return false
+		}
+		value, ok := lookupJSONPath(root, cr.jsonPath)
+		if !ok || !containsValue(cr.jsonValues, value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// lookupHeader 不区分大小写查找响应头
+func lookupHeader(headers map[string]string, name string) (string, bool) {
+	if v, ok := headers[name]; ok {
+		return v, true
+	}
+	for k, v := range headers {
+		if strings.ToLower(k) == name {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// lookupJSONPath 按点分路径取值（数组用数字下标），返回字符串形式
+func lookupJSONPath(root interface{}, path []string) (string, bool) {
+	cur := root
+	for _, key := range path {
+		switch node := cur.(type) {
+		case map[string]interface{}:
+			next, ok := node[key]
+			if !ok {
+				return "", false
+			}
+			cur = next
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return "", false
+			}
+			cur = node[i]
+		default:
+			return "", false
+		}
+	}
+
+	switch v := cur.(type) {
+	case nil:
+		return "", false
+	case string:
+		return v, true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(v), true
+	default:
+		data, _ := json.Marshal(v)
+		return string(data), true
+	}
 }
 
 // GetRuleCount 获取规则数量
@@ -0,0 +1,137 @@
+package errormatch
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"cli-proxy/internal/model"
+	"cli-proxy/internal/proxy/adapter"
+)
+
+// newTestMatcher 创建不依赖数据库的匹配器
+func newTestMatcher(rules ...model.ErrorRule) *ErrorRuleMatcher {
+	m := &ErrorRuleMatcher{}
+	m.setRules(rules)
+	return m
+}
+
+func TestMatchStatusRangeAndRegex(t *testing.T) {
+	m := newTestMatcher(
+		model.ErrorRule{ID: 1, StatusCodes: "500-599", MessageRegex: `upstream connect error|reset before headers`, TargetStatus: model.TargetStatusOverloaded},
+		model.ErrorRule{ID: 2, StatusCodes: "429, 529", TargetStatus: model.TargetStatusRateLimited},
+	)
+
+	if r := m.Match(502, "Upstream Connect Error or disconnect"); !r.Matched || r.Rule.ID != 1 {
+		t.Fatalf("expected rule 1, got %+v", r)
+	}
+	if r := m.Match(502, "bad gateway"); r.Matched {
+		t.Fatalf("expected no match when regex fails, got %+v", r)
+	}
+	if r := m.Match(529, "anything"); !r.Matched || r.Rule.ID != 2 {
+		t.Fatalf("expected rule 2, got %+v", r)
+	}
+	if r := m.Match(404, "anything"); r.Matched {
+		t.Fatalf("expected no match, got %+v", r)
+	}
+}
+
+func TestMatchJSONPath(t *testing.T) {
+	m := newTestMatcher(model.ErrorRule{ID: 1, JSONPath: "error.type", JSONValue: "overloaded_error, api_error", TargetStatus: model.TargetStatusOverloaded})
+
+	body := `[HTTP 500] {"type":"error","error":{"type":"api_error","message":"Internal server error"}}`
+	if r := m.Match(500, body); !r.Matched {
+		t.Fatalf("expected json path match")
+	}
+	if r := m.Match(400, `{"type":"error","error":{"type":"invalid_request_error"}}`); r.Matched {
+		t.Fatalf("expected no match for other error type")
+	}
+	if r := m.Match(500, "plain text"); r.Matched {
+		t.Fatalf("expected no match for non-json message")
+	}
+
+	// 数组下标与数字取值
+	m = newTestMatcher(model.ErrorRule{ID: 2, JSONPath: "errors.0.code", JSONValue: "429", TargetStatus: model.TargetStatusRateLimited})
+	if r := m.Match(0, `{"errors":[{"code":429}]}`); !r.Matched {
+		t.Fatalf("expected array path match")
+	}
+}
+
+func TestMatchHeaderAndAccountType(t *testing.T) {
+	m := newTestMatcher(model.ErrorRule{
+		ID:             1,
+		HTTPStatusCode: 429,
+		HeaderName:     "Anthropic-Ratelimit-Unified-Status",
+		HeaderValue:    "rejected",
+		AccountTypes:   model.AccountTypeClaudeOfficial,
+		TargetStatus:   model.TargetStatusRateLimited,
+		RetryAction:    model.RetryActionSwitch,
+		ClientMessage:  "订阅额度已用尽",
+	})
+
+	header := http.Header{}
+	header.Set("anthropic-ratelimit-unified-status", "rejected")
+	err := adapter.NewUpstreamErrorWithHeaders(429, `{"error":{"type":"rate_limit_error"}}`, header)
+
+	account := &model.Account{Type: model.AccountTypeClaudeOfficial}
+	r := m.MatchError(err, account)
+	if !r.Matched || r.RetryAction() != model.RetryActionSwitch {
+		t.Fatalf("expected header match with switch action, got %+v", r)
+	}
+
+	if r := m.MatchError(err, &model.Account{Type: model.AccountTypeClaudeConsole}); r.Matched {
+		t.Fatalf("expected no match for other account type")
+	}
+	if r := m.MatchError(adapter.NewUpstreamError(429, "rate limited"), account); r.Matched {
+		t.Fatalf("expected no match without header")
+	}
+
+	wrapped := WithClientMessage(err, r)
+	if msg, ok := ClientMessage(wrapped); !ok || msg != "订阅额度已用尽" {
+		t.Fatalf("expected client message, got %q", msg)
+	}
+	var upstream *adapter.UpstreamError
+	if !errors.As(wrapped, &upstream) || upstream.StatusCode != 429 {
+		t.Fatalf("wrapped error should unwrap to upstream error")
+	}
+}
+
+func TestCooldownAndPriorityOrder(t *testing.T) {
+	m := newTestMatcher(
+		model.ErrorRule{ID: 1, Keyword: "quota", TargetStatus: model.TargetStatusRateLimited, CooldownSeconds: 300},
+		model.ErrorRule{ID: 2, HTTPStatusCode: 429, TargetStatus: model.TargetStatusRateLimited},
+	)
+	r := m.Match(429, "Quota exceeded")
+	if r.Rule.ID != 1 || r.Cooldown().Seconds() != 300 {
+		t.Fatalf("expected first rule with 5m cooldown, got %+v", r)
+	}
+	if r := m.Match(429, "slow down"); r.Rule.ID != 2 || r.Cooldown() != defaultCooldown {
+		t.Fatalf("expected default cooldown, got %+v", r)
+	}
+}
+
+func TestValidateRule(t *testing.T) {
+	invalid := []model.ErrorRule{
+		{MessageRegex: "(unclosed", TargetStatus: model.TargetStatusInvalid},
+		{StatusCodes: "599-500", TargetStatus: model.TargetStatusInvalid},
+		{StatusCodes: "5xx", TargetStatus: model.TargetStatusInvalid},
+		{RetryAction: "later", TargetStatus: model.TargetStatusInvalid},
+		{CooldownSeconds: -1, TargetStatus: model.TargetStatusInvalid},
+	}
+	for _, rule := range invalid {
+		if err := ValidateRule(&rule); err == nil {
+			t.Fatalf("expected validation error for %+v", rule)
+		}
+	}
+
+	// 无效规则在刷新时被跳过，不影响其他规则
+	m := newTestMatcher(invalid[0], model.ErrorRule{ID: 9, HTTPStatusCode: 401, TargetStatus: model.TargetStatusInvalid})
+	if m.GetRuleCount() != 1 {
+		t.Fatalf("expected invalid rule skipped, got %d rules", m.GetRuleCount())
+	}
+
+	ok, err := TestRule(&model.ErrorRule{Keyword: "billing"}, &ErrorContext{StatusCode: 403, Message: "Billing issue"})
+	if err != nil || !ok {
+		t.Fatalf("expected draft rule to match, got %v %v", ok, err)
+	}
+}
@@ -0,0 +1,47 @@
+/*
+ * 文件作用：错误规则命中后返回客户端的提示
+ * 负责功能：
+ *   - 包装上游错误，附带规则指定的客户端提示
+ *   - 从错误链中提取客户端提示
+ * 重要程度：⭐⭐ 辅助
+ * 依赖模块：无
+ */
+package errormatch
+
+import "errors"
+
+// RuleError 命中错误规则且规则指定了客户端提示的错误
+type RuleError struct {
+	Err           error
+	RuleID        uint
+	ClientMessage string
+}
+
+func (e *RuleError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RuleError) Unwrap() error {
+	return e.Err
+}
+
+// WithClientMessage 匹配结果指定了客户端提示时包装错误，否则原样返回
+func WithClientMessage(err error, result *MatchResult) error {
+	if err == nil || result == nil {
+		return err
+	}
+	msg := result.ClientMessage()
+	if msg == "" {
+		return err
+	}
+	return &RuleError{Err: err, RuleID: result.Rule.ID, ClientMessage: msg}
+}
+
+// ClientMessage 从错误链中提取规则指定的客户端提示
+func ClientMessage(err error) (string, bool) {
+	var ruleErr *RuleError
+	if errors.As(err, &ruleErr) && ruleErr.ClientMessage != "" {
+		return ruleErr.ClientMessage, true
+	}
+	return "", false
+}
@@ -0,0 +1,230 @@
+/*
+ * 文件作用：代理请求实时事件中心
+ * 负责功能：
+ *   - 请求开始/结束事件发布
+ *   - 有界环形缓冲区（新连接可回看最近几分钟）
+ *   - 订阅者服务端过滤
+ *   - 慢订阅者背压处理（缓冲满时丢弃并计数，持续积压则断开）
+ * 重要程度：⭐⭐⭐ 一般（运维观测）
+ * 依赖模块：无
+ */
+package events
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// 事件类型
+const (
+	RequestStart  = "start"
+	RequestFinish = "finish"
+)
+
+const (
+	// 环形缓冲区容量
+	ringCapacity = 5000
+	// 订阅者缓冲区容量
+	subscriberBuffer = 512
+	// 订阅者连续丢弃超过该数量时断开（消费过慢）
+	maxSubscriberDrops = 2000
+)
+
+// RequestEvent 代理请求事件
+type RequestEvent struct {
+	Seq                 uint64    `json:"seq"`
+	Type                string    `json:"type"` // start/finish
+	RequestID           string    `json:"request_id"`
+	Time                time.Time `json:"time"`
+	APIKeyID            uint      `json:"api_key_id,omitempty"`
+	APIKeyName          string    `json:"api_key_name,omitempty"`
+	Method              string    `json:"method"`
+	Path                string    `json:"path"`
+	ClientIP            string    `json:"client_ip"`
+	Model               string    `json:"model,omitempty"`
+	Stream              bool      `json:"stream,omitempty"`
+	AccountID           uint      `json:"account_id,omitempty"`
+	Status              int       `json:"status,omitempty"`
+	Success             bool      `json:"success"`
+	Error               string    `json:"error,omitempty"`
+	InputTokens         int       `json:"input_tokens,omitempty"`
+	OutputTokens        int       `json:"output_tokens,omitempty"`
+	CacheReadTokens     int       `json:"cache_read_tokens,omitempty"`
+	CacheCreationTokens int       `json:"cache_creation_tokens,omitempty"`
+	LatencyMs           int64     `json:"latency_ms,omitempty"`
+	Retries             int       `json:"retries,omitempty"`
+	CacheStatus         string    `json:"cache_status,omitempty"`
+}
+
+// Filter 订阅过滤条件（零值表示不过滤）
+type Filter struct {
+	Type       string // start/finish
+	APIKeyID   uint
+	AccountID  uint
+	Model      string // 模糊匹配
+	ErrorsOnly bool   // 只看失败的结束事件
+	MinLatency int64  // 只看耗时不低于该值（毫秒）的结束事件
+}
+
+// Match 判断事件是否满足过滤条件
+func (f *Filter) Match(e *RequestEvent) bool {
+	if f.Type != "" && e.Type != f.Type {
+		return false
+	}
+	if f.APIKeyID > 0 && e.APIKeyID != f.APIKeyID {
+		return false
+	}
+	if f.AccountID > 0 && e.AccountID != f.AccountID {
+		return false
+	}
+	if f.Model != "" && !strings.Contains(e.Model, f.Model) {
+		return false
+	}
+	if f.ErrorsOnly && (e.Type != RequestFinish || e.Success) {
+		return false
+	}
+	if f.MinLatency > 0 && (e.Type != RequestFinish || e.LatencyMs < f.MinLatency) {
+		return false
+	}
+	return true
+}
+
+// Subscriber 事件订阅者（C 被关闭表示已被断开）
+type Subscriber struct {
+	C      chan RequestEvent
+	filter Filter
+	drops  int // 连续丢弃数
+	lagged int // 自上次读取 Dropped 以来的丢弃数
+	mu     sync.Mutex
+}
+
+// Dropped 返回并清零自上次调用以来被丢弃的事件数（用于提示客户端有数据丢失）
+func (s *Subscriber) Dropped() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := s.lagged
+	s.lagged = 0
+	return n
+}
+
+// Hub 请求事件中心
+type Hub struct {
+	mu          sync.RWMutex
+	seq         uint64
+	ring        []RequestEvent
+	head        int // 下一个写入位置
+	size        int
+	subscribers map[*Subscriber]struct{}
+}
+
+var (
+	hub     *Hub
+	hubOnce sync.Once
+)
+
+// GetHub 获取请求事件中心单例
+func GetHub() *Hub {
+	hubOnce.Do(func() {
+		hub = NewHub(ringCapacity)
+	})
+	return hub
+}
+
+// NewHub 创建事件中心（capacity 为环形缓冲区容量）
+func NewHub(capacity int) *Hub {
+	return &Hub{
+		ring:        make([]RequestEvent, capacity),
+		subscribers: make(map[*Subscriber]struct{}),
+	}
+}
+
+// Publish 发布事件（不阻塞：订阅者缓冲区满时丢弃该订阅者的这条事件）
+func (h *Hub) Publish(e RequestEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.seq++
+	e.Seq = h.seq
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	h.ring[h.head] = e
+	h.head = (h.head + 1) % len(h.ring)
+	if h.size < len(h.ring) {
+		h.size++
+	}
+
+	for sub := range h.subscribers {
+		if !sub.filter.Match(&e) {
+			continue
+		}
+		select {
+		case sub.C <- e:
+			sub.mu.Lock()
+			sub.drops = 0
+			sub.mu.Unlock()
+		default:
+			sub.mu.Lock()
+			sub.drops++
+			sub.lagged++
+			tooSlow := sub.drops >= maxSubscriberDrops
+			sub.mu.Unlock()
+			if tooSlow {
+				h.removeLocked(sub)
+			}
+		}
+	}
+}
+
+// Subscribe 订阅事件，返回订阅者和满足条件的历史事件（since 之后、afterSeq 之后）
+func (h *Hub) Subscribe(filter Filter, since time.Time, afterSeq uint64) (*Subscriber, []RequestEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	sub := &Subscriber{C: make(chan RequestEvent, subscriberBuffer), filter: filter}
+	h.subscribers[sub] = struct{}{}
+	return sub, h.recentLocked(&filter, since, afterSeq)
+}
+
+// Unsubscribe 取消订阅
+func (h *Hub) Unsubscribe(sub *Subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.removeLocked(sub)
+}
+
+// Recent 获取环形缓冲区中满足条件的事件（按时间正序）
+func (h *Hub) Recent(filter Filter, since time.Time) []RequestEvent {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.recentLocked(&filter, since, 0)
+}
+
+// SubscriberCount 当前订阅者数量
+func (h *Hub) SubscriberCount() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.subscribers)
+}
+
+func (h *Hub) recentLocked(filter *Filter, since time.Time, afterSeq uint64) []RequestEvent {
+	events := make([]RequestEvent, 0)
+	start := (h.head - h.size + len(h.ring)) % len(h.ring)
+	for i := 0; i < h.size; i++ {
+		e := &h.ring[(start+i)%len(h.ring)]
+		if e.Seq <= afterSeq || e.Time.Before(since) || !filter.Match(e) {
+			continue
+		}
+		events = append(events, *e)
+	}
+	return events
+}
+
+func (h *Hub) removeLocked(sub *Subscriber) {
+	if _, ok := h.subscribers[sub]; !ok {
+		return
+	}
+	delete(h.subscribers, sub)
+	close(sub.C)
+}
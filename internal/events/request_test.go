@@ -0,0 +1,72 @@
+package events
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHubRingBuffer(t *testing.T) {
+	h := NewHub(3)
+	for i := 0; i < 5; i++ {
+		h.Publish(RequestEvent{Type: RequestStart, RequestID: string(rune('a' + i))})
+	}
+	events := h.Recent(Filter{}, time.Time{})
+	if len(events) != 3 || events[0].RequestID != "c" || events[2].RequestID != "e" || events[2].Seq != 5 {
+		t.Fatalf("unexpected ring contents: %+v", events)
+	}
+}
+
+func TestHubSubscribeFilterAndBacklog(t *testing.T) {
+	h := NewHub(10)
+	h.Publish(RequestEvent{Type: RequestFinish, APIKeyID: 1, Success: true})
+	h.Publish(RequestEvent{Type: RequestFinish, APIKeyID: 1, Success: false})
+	h.Publish(RequestEvent{Type: RequestFinish, APIKeyID: 2, Success: false})
+
+	sub, backlog := h.Subscribe(Filter{APIKeyID: 1, ErrorsOnly: true}, time.Time{}, 0)
+	defer h.Unsubscribe(sub)
+	if len(backlog) != 1 || backlog[0].Seq != 2 {
+		t.Fatalf("unexpected backlog: %+v", backlog)
+	}
+
+	h.Publish(RequestEvent{Type: RequestStart, APIKeyID: 1})
+	h.Publish(RequestEvent{Type: RequestFinish, APIKeyID: 1, Success: false, Model: "m"})
+	select {
+	case e := <-sub.C:
+		if e.Seq != 5 {
+			t.Fatalf("unexpected event: %+v", e)
+		}
+	default:
+		t.Fatal("expected a matching event")
+	}
+
+	// 断线重连：afterSeq 之后的事件
+	sub2, backlog := h.Subscribe(Filter{}, time.Time{}, 4)
+	defer h.Unsubscribe(sub2)
+	if len(backlog) != 1 || backlog[0].Seq != 5 {
+		t.Fatalf("unexpected resume backlog: %+v", backlog)
+	}
+}
+
+func TestHubSlowSubscriber(t *testing.T) {
+	h := NewHub(10)
+	sub, _ := h.Subscribe(Filter{}, time.Time{}, 0)
+	for i := 0; i < subscriberBuffer+10; i++ {
+		h.Publish(RequestEvent{Type: RequestStart})
+	}
+	if n := sub.Dropped(); n != 10 {
+		t.Fatalf("dropped = %d, want 10", n)
+	}
+	if h.SubscriberCount() != 1 {
+		t.Fatal("subscriber should still be connected")
+	}
+
+	for i := 0; i < maxSubscriberDrops; i++ {
+		h.Publish(RequestEvent{Type: RequestStart})
+	}
+	if h.SubscriberCount() != 0 {
+		t.Fatal("slow subscriber should be disconnected")
+	}
+	for range sub.C {
+	}
+	h.Unsubscribe(sub) // 重复取消订阅不应 panic
+}
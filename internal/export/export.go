@@ -0,0 +1,367 @@
+/*
+ * 文件作用：表格数据流式导出，支持 CSV / XLSX / JSON 三种格式
+ * 负责功能：
+ *   - 统一的逐行写入接口（不在内存中缓存全部数据）
+ *   - CSV（带 UTF-8 BOM，Excel 直接打开不乱码）
+ *   - XLSX（流式写入 zip，内联字符串，无第三方依赖）
+ *   - JSON（对象数组，字段名取表头）
+ *   - CSV/XLSX 文本单元格防公式注入
+ * 重要程度：⭐⭐⭐ 一般（报表导出）
+ * 依赖模块：无
+ */
+package export
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 导出格式
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+	FormatJSON = "json"
+)
+
+// Writer 逐行写入的表格导出器
+type Writer interface {
+	// WriteHeader 写入表头（必须在第一行数据之前调用一次）
+	WriteHeader(columns []string) error
+	// WriteRow 写入一行数据，值的个数应与表头一致
+	WriteRow(values []interface{}) error
+	// Close 结束写入（不关闭底层 io.Writer）
+	Close() error
+}
+
+// ParseFormat 校验并规范化导出格式（默认 CSV）
+func ParseFormat(format string) (string, error) {
+	switch f := strings.ToLower(strings.TrimSpace(format)); f {
+	case "":
+		return FormatCSV, nil
+	case FormatCSV, FormatXLSX, FormatJSON:
+		return f, nil
+	}
+	return "", fmt.Errorf("不支持的导出格式: %s（可选 csv / xlsx / json）", format)
+}
+
+// ContentType 导出格式对应的 Content-Type
+func ContentType(format string) string {
+	switch format {
+	case FormatXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	case FormatJSON:
+		return "application/json; charset=utf-8"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// NewWriter 创建导出器，sheet 为 XLSX 的工作表名称
+func NewWriter(format string, w io.Writer, sheet string) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w)
+	case FormatXLSX:
+		return newXLSXWriter(w, sheet)
+	case FormatJSON:
+		return &jsonWriter{w: bufio.NewWriter(w)}, nil
+	}
+	return nil, fmt.Errorf("不支持的导出格式: %s", format)
+}
+
+// formatValue 将单元格值格式化为文本
+func formatValue(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case time.Time:
+		if val.IsZero() {
+			return ""
+		}
+		return val.Format("2006-01-02 15:04:05")
+	case *time.Time:
+		if val == nil {
+			return ""
+		}
+		return formatValue(*val)
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(val), 'f', -1, 32)
+	case bool:
+		return strconv.FormatBool(val)
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// cellText 表格单元格文本：以 = + - @ 制表符或回车开头的字符串加 ' 前缀，
+// 避免客户端可控内容（模型名、Key 名称等）在 Excel 中被当作公式执行
+func cellText(v interface{}) string {
+	s, ok := v.(string)
+	if !ok {
+		return formatValue(v)
+	}
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// ==================== CSV ====================
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func newCSVWriter(w io.Writer) (*csvWriter, error) {
+	// UTF-8 BOM，保证 Excel 正确识别中文
+	if _, err := io.WriteString(w, "\ufeff"); err != nil {
+		return nil, err
+	}
+	return &csvWriter{w: csv.NewWriter(w)}, nil
+}
+
+func (c *csvWriter) WriteHeader(columns []string) error {
+	return c.w.Write(columns)
+}
+
+func (c *csvWriter) WriteRow(values []interface{}) error {
+	record := make([]string, len(values))
+	for i, v := range values {
+		record[i] = cellText(v)
+	}
+	return c.w.Write(record)
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// ==================== JSON ====================
+
+type jsonWriter struct {
+	w       *bufio.Writer
+	columns []string
+	rows    int
+}
+
+func (j *jsonWriter) WriteHeader(columns []string) error {
+	j.columns = columns
+	_, err := j.w.WriteString("[")
+	return err
+}
+
+func (j *jsonWriter) WriteRow(values []interface{}) error {
+	// 按表头顺序手动拼接对象，保持字段顺序稳定
+	var b strings.Builder
+	if j.rows > 0 {
+		b.WriteString(",")
+	}
+	b.WriteString("\n  {")
+	for i, column := range j.columns {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		key, _ := json.Marshal(column)
+		b.Write(key)
+		b.WriteString(": ")
+		var v interface{}
+		if i < len(values) {
+			v = values[i]
+		}
+		if t, ok := v.(time.Time); ok {
+			v = t.Format(time.RFC3339)
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		b.Write(data)
+	}
+	b.WriteString("}")
+	j.rows++
+	_, err := j.w.WriteString(b.String())
+	return err
+}
+
+func (j *jsonWriter) Close() error {
+	if j.columns == nil {
+		j.w.WriteString("[")
+	}
+	if j.rows > 0 {
+		j.w.WriteString("\n")
+	}
+	j.w.WriteString("]\n")
+	return j.w.Flush()
+}
+
+// ==================== XLSX ====================
+
+// xlsxWriter 流式 XLSX 导出（单工作表，工作表 XML 边写边压缩）
+type xlsxWriter struct {
+	zw    *zip.Writer
+	sheet *bufio.Writer
+}
+
+const xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>
+<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>
+</Types>`
+
+const xlsxRootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>`
+
+const xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>
+</Relationships>`
+
+// 样式：0 默认，1 表头加粗
+const xlsxStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>
+<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>
+<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>
+<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>
+<cellXfs count="2"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/><xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/></cellXfs>
+</styleSheet>`
+
+func newXLSXWriter(w io.Writer, sheet string) (*xlsxWriter, error) {
+	if sheet == "" {
+		sheet = "Sheet1"
+	}
+	zw := zip.NewWriter(w)
+	files := []struct{ name, body string }{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRootRels},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+		{"xl/styles.xml", xlsxStyles},
+		{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="` + xmlEscape(sheetName(sheet)) + `" sheetId="1" r:id="rId1"/></sheets>
+</workbook>`},
+	}
+	for _, f := range files {
+		fw, err := zw.Create(f.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(fw, f.body); err != nil {
+			return nil, err
+		}
+	}
+
+	fw, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	sw := bufio.NewWriter(fw)
+	sw.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	return &xlsxWriter{zw: zw, sheet: sw}, nil
+}
+
+func (x *xlsxWriter) WriteHeader(columns []string) error {
+	values := make([]interface{}, len(columns))
+	for i, c := range columns {
+		values[i] = c
+	}
+	return x.writeRow(values, 1)
+}
+
+func (x *xlsxWriter) WriteRow(values []interface{}) error {
+	return x.writeRow(values, 0)
+}
+
+func (x *xlsxWriter) writeRow(values []interface{}, style int) error {
+	x.sheet.WriteString("<row>")
+	for _, v := range values {
+		styleAttr := ""
+		if style > 0 {
+			styleAttr = fmt.Sprintf(` s="%d"`, style)
+		}
+		if num, ok := numericValue(v); ok {
+			fmt.Fprintf(x.sheet, `<c%s><v>%s</v></c>`, styleAttr, num)
+			continue
+		}
+		text := cellText(v)
+		if text == "" {
+			x.sheet.WriteString("<c" + styleAttr + "/>")
+			continue
+		}
+		fmt.Fprintf(x.sheet, `<c t="inlineStr"%s><is><t xml:space="preserve">%s</t></is></c>`, styleAttr, xmlEscape(text))
+	}
+	_, err := x.sheet.WriteString("</row>")
+	return err
+}
+
+func (x *xlsxWriter) Close() error {
+	x.sheet.WriteString("</sheetData></worksheet>")
+	if err := x.sheet.Flush(); err != nil {
+		return err
+	}
+	return x.zw.Close()
+}
+
+// numericValue 数值类型单元格
+func numericValue(v interface{}) (string, bool) {
+	switch val := v.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprintf("%d", val), true
+	case float32:
+		return strconv.FormatFloat(float64(val), 'f', -1, 32), true
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64), true
+	}
+	return "", false
+}
+
+// sheetName 工作表名称（Excel 限制 31 个字符，且不能包含 []:*?/\）
+func sheetName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	if runes := []rune(name); len(runes) > 31 {
+		name = string(runes[:31])
+	}
+	return name
+}
+
+// xmlEscape 转义 XML 文本并去除 XML 1.0 不允许的控制字符
+func xmlEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '<':
+			b.WriteString("&lt;")
+		case r == '>':
+			b.WriteString("&gt;")
+		case r == '&':
+			b.WriteString("&amp;")
+		case r == '"':
+			b.WriteString("&quot;")
+		case r == '\t' || r == '\n' || r == '\r' || r >= 0x20 && r != 0xFFFE && r != 0xFFFF:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,129 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeAll(t *testing.T, format string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewWriter(format, &buf, "用量")
+	if err != nil {
+		t.Fatalf("new writer: %v", err)
+	}
+	ts := time.Date(2026, 10, 1, 8, 30, 0, 0, time.UTC)
+	w.WriteHeader([]string{"date", "model", "requests", "cost"})
+	w.WriteRow([]interface{}{ts, "claude-<opus>", int64(3), 1.25})
+	w.WriteRow([]interface{}{nil, "gpt,\"4\"", 0, 0.0})
+	if err := w.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestCSVWriter(t *testing.T) {
+	out := string(writeAll(t, FormatCSV))
+	if !strings.HasPrefix(out, "\ufeffdate,model,requests,cost\n") {
+		t.Fatalf("unexpected header: %q", out)
+	}
+	if !strings.Contains(out, "2026-10-01 08:30:00,claude-<opus>,3,1.25\n") || !strings.Contains(out, `,"gpt,""4""",0,0`) {
+		t.Fatalf("unexpected rows: %q", out)
+	}
+}
+
+func TestJSONWriter(t *testing.T) {
+	var rows []map[string]interface{}
+	if err := json.Unmarshal(writeAll(t, FormatJSON), &rows); err != nil {
+		t.Fatalf("invalid json: %v", err)
+	}
+	if len(rows) != 2 || rows[0]["model"] != "claude-<opus>" || rows[0]["requests"] != float64(3) || rows[1]["date"] != nil {
+		t.Fatalf("unexpected rows: %+v", rows)
+	}
+
+	var empty bytes.Buffer
+	w, _ := NewWriter(FormatJSON, &empty, "")
+	w.WriteHeader([]string{"a"})
+	w.Close()
+	if strings.TrimSpace(empty.String()) != "[]" {
+		t.Fatalf("empty export should be an empty array, got %q", empty.String())
+	}
+}
+
+func TestXLSXWriter(t *testing.T) {
+	data := writeAll(t, FormatXLSX)
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("invalid zip: %v", err)
+	}
+	files := map[string]string{}
+	for _, f := range zr.File {
+		rc, _ := f.Open()
+		body, _ := io.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(body)
+	}
+	for _, name := range []string{"[Content_Types].xml", "xl/workbook.xml", "xl/worksheets/sheet1.xml", "xl/styles.xml"} {
+		if _, ok := files[name]; !ok {
+			t.Fatalf("missing %s", name)
+		}
+	}
+	sheet := files["xl/worksheets/sheet1.xml"]
+	if !strings.Contains(sheet, "claude-&lt;opus&gt;") || !strings.Contains(sheet, "<c><v>3</v></c>") || !strings.Contains(sheet, "<c><v>1.25</v></c>") {
+		t.Fatalf("unexpected sheet: %s", sheet)
+	}
+	if strings.Count(sheet, "<row>") != 3 {
+		t.Fatalf("expected 3 rows: %s", sheet)
+	}
+}
+
+func TestFormulaCellsEscaped(t *testing.T) {
+	for _, format := range []string{FormatCSV, FormatXLSX} {
+		var buf bytes.Buffer
+		w, _ := NewWriter(format, &buf, "")
+		w.WriteHeader([]string{"model", "api_key_name", "delta"})
+		w.WriteRow([]interface{}{"=HYPERLINK(\"x\")", "@SUM(A1)", -5})
+		w.WriteRow([]interface{}{"+1", "-2", "\tcmd"})
+		if err := w.Close(); err != nil {
+			t.Fatalf("%s close: %v", format, err)
+		}
+		out := buf.String()
+		if format == FormatXLSX {
+			zr, _ := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+			for _, f := range zr.File {
+				if f.Name == "xl/worksheets/sheet1.xml" {
+					rc, _ := f.Open()
+					body, _ := io.ReadAll(rc)
+					rc.Close()
+					out = string(body)
+				}
+			}
+		}
+		for _, want := range []string{"'=HYPERLINK", "'@SUM", "'+1", "'-2", "'\tcmd"} {
+			if !strings.Contains(out, want) {
+				t.Fatalf("%s: expected %q in %q", format, want, out)
+			}
+		}
+		// 数值不受影响
+		if strings.Contains(out, "'-5") {
+			t.Fatalf("%s: numeric cell should not be escaped: %q", format, out)
+		}
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	if f, err := ParseFormat(""); err != nil || f != FormatCSV {
+		t.Fatalf("default format should be csv, got %q %v", f, err)
+	}
+	if f, _ := ParseFormat("XLSX"); f != FormatXLSX {
+		t.Fatalf("format should be case-insensitive, got %q", f)
+	}
+	if _, err := ParseFormat("pdf"); err == nil {
+		t.Fatal("expected error for unsupported format")
+	}
+}
@@ -0,0 +1,572 @@
+/*
+ * 文件作用：客户端过滤表达式的编译与求值
+ * 负责功能：
+ *   - 表达式编译（Env 注册自定义函数）
+ *   - 求值：缺失字段为 null，&& || 短路，类型不匹配报错
+ *   - 内置函数：has、size、matches、startsWith、endsWith、contains、lower、upper、trim、string、inCIDR
+ *   - 嵌套求值深度限制（防止规则集互相引用死循环）
+ * 重要程度：⭐⭐⭐⭐ 重要（客户端过滤规则引擎）
+ * 依赖模块：无
+ */
+package filterexpr
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxEvalDepth 嵌套求值最大深度（自定义函数中再次求值其他表达式时计数）
+const maxEvalDepth = 8
+
+// Vars 求值变量（值可为 nil、bool、数字、string、[]any、map[string]any、map[string]string）
+type Vars map[string]any
+
+// Activation 一次求值的上下文
+type Activation struct {
+	Vars  Vars
+	depth int
+}
+
+// NewActivation 创建求值上下文
+func NewActivation(vars Vars) *Activation {
+	return &Activation{Vars: vars}
+}
+
+// Func 自定义函数
+type Func func(act *Activation, args []any) (any, error)
+
+// Env 编译环境（内置函数 + 自定义函数）
+type Env struct {
+	funcs map[string]Func
+}
+
+// NewEnv 创建编译环境
+func NewEnv(funcs map[string]Func) *Env {
+	return &Env{funcs: funcs}
+}
+
+func (e *Env) hasFunc(name string) bool {
+	if _, ok := builtins[name]; ok {
+		return true
+	}
+	if e == nil {
+		return false
+	}
+	_, ok := e.funcs[name]
+	return ok
+}
+
+func (e *Env) lookup(name string) Func {
+	if fn, ok := builtins[name]; ok {
+		return fn
+	}
+	if e == nil {
+		return nil
+	}
+	return e.funcs[name]
+}
+
+// Program 编译后的表达式
+type Program struct {
+	src  string
+	root node
+	env  *Env
+}
+
+// Compile 编译表达式
+func (e *Env) Compile(src string) (*Program, error) {
+	src = strings.TrimSpace(src)
+	if src == "" {
+		return nil, fmt.Errorf("表达式不能为空")
+	}
+	if len(src) > maxExpressionLength {
+		return nil, fmt.Errorf("表达式过长（最多 %d 字符）", maxExpressionLength)
+	}
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens, env: e}
+	root, err := p.parseExpr(1)
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, fmt.Errorf("位置 %d: 多余的 %q", t.pos, t.text)
+	}
+	return &Program{src: src, root: root, env: e}, nil
+}
+
+// Compile 使用仅含内置函数的环境编译表达式
+func Compile(src string) (*Program, error) {
+	return NewEnv(nil).Compile(src)
+}
+
+// String 表达式源码
+func (p *Program) String() string {
+	return p.src
+}
+
+// Eval 求值
+func (p *Program) Eval(act *Activation) (any, error) {
+	act.depth++
+	defer func() { act.depth-- }()
+	if act.depth > maxEvalDepth {
+		return nil, fmt.Errorf("表达式嵌套过深（超过 %d 层，可能存在循环引用）", maxEvalDepth)
+	}
+	return p.eval(p.root, act)
+}
+
+// EvalBool 求值并要求结果为布尔值
+func (p *Program) EvalBool(act *Activation) (bool, error) {
+	v, err := p.Eval(act)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("表达式结果应为布尔值，实际为 %s", typeName(v))
+	}
+	return b, nil
+}
+
+func (p *Program) eval(n node, act *Activation) (any, error) {
+	switch n := n.(type) {
+	case *literalNode:
+		return n.value, nil
+	case *identNode:
+		return normalize(act.Vars[n.name]), nil
+	case *memberNode:
+		x, err := p.eval(n.x, act)
+		if err != nil {
+			return nil, err
+		}
+		return field(x, n.name), nil
+	case *indexNode:
+		x, err := p.eval(n.x, act)
+		if err != nil {
+			return nil, err
+		}
+		index, err := p.eval(n.index, act)
+		if err != nil {
+			return nil, err
+		}
+		return indexValue(x, index)
+	case *listNode:
+		list := make([]any, 0, len(n.elems))
+		for _, elem := range n.elems {
+			v, err := p.eval(elem, act)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, nil
+	case *callNode:
+		args := make([]any, 0, len(n.args))
+		for _, arg := range n.args {
+			v, err := p.eval(arg, act)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, v)
+		}
+		fn := p.env.lookup(n.fn)
+		if fn == nil {
+			return nil, fmt.Errorf("未知函数 %s", n.fn)
+		}
+		if n.re != nil {
+			fn = stringFunc(func(s, _ string) bool { return n.re.MatchString(s) })
+		}
+		v, err := fn(act, args)
+		if err != nil {
+			return nil, fmt.Errorf("%s(): %v", n.fn, err)
+		}
+		return normalize(v), nil
+	case *unaryNode:
+		x, err := p.eval(n.x, act)
+		if err != nil {
+			return nil, err
+		}
+		if n.op == "!" {
+			b, ok := x.(bool)
+			if !ok {
+				return nil, fmt.Errorf("'!' 需要布尔值，实际为 %s", typeName(x))
+			}
+			return !b, nil
+		}
+		f, ok := x.(float64)
+		if !ok {
+			return nil, fmt.Errorf("'-' 需要数字，实际为 %s", typeName(x))
+		}
+		return -f, nil
+	case *binaryNode:
+		return p.evalBinary(n, act)
+	}
+	return nil, fmt.Errorf("未知节点 %T", n)
+}
+
+func (p *Program) evalBinary(n *binaryNode, act *Activation) (any, error) {
+	l, err := p.eval(n.l, act)
+	if err != nil {
+		return nil, err
+	}
+
+	// 逻辑运算短路
+	if n.op == "&&" || n.op == "||" {
+		lb, ok := l.(bool)
+		if !ok {
+			return nil, fmt.Errorf("'%s' 左侧需要布尔值，实际为 %s", n.op, typeName(l))
+		}
+		if (n.op == "&&" && !lb) || (n.op == "||" && lb) {
+			return lb, nil
+		}
+		r, err := p.eval(n.r, act)
+		if err != nil {
+			return nil, err
+		}
+		rb, ok := r.(bool)
+		if !ok {
+			return nil, fmt.Errorf("'%s' 右侧需要布尔值，实际为 %s", n.op, typeName(r))
+		}
+		return rb, nil
+	}
+
+	r, err := p.eval(n.r, act)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "==":
+		return equal(l, r), nil
+	case "!=":
+		return !equal(l, r), nil
+	case "in":
+		return contains(r, l)
+	case "+":
+		if ls, ok := l.(string); ok {
+			if rs, ok := r.(string); ok {
+				return ls + rs, nil
+			}
+		}
+		lf, lok := l.(float64)
+		rf, rok := r.(float64)
+		if !lok || !rok {
+			return nil, fmt.Errorf("'+' 不支持 %s 与 %s", typeName(l), typeName(r))
+		}
+		return lf + rf, nil
+	case "-":
+		lf, lok := l.(float64)
+		rf, rok := r.(float64)
+		if !lok || !rok {
+			return nil, fmt.Errorf("'-' 不支持 %s 与 %s", typeName(l), typeName(r))
+		}
+		return lf - rf, nil
+	default:
+		return compare(n.op, l, r)
+	}
+}
+
+// ==================== 值操作 ====================
+
+// normalize 统一数值与容器类型（整数 -> float64，map[string]string -> map[string]any）
+func normalize(v any) any {
+	switch x := v.(type) {
+	case int:
+		return float64(x)
+	case int64:
+		return float64(x)
+	case uint:
+		return float64(x)
+	case uint64:
+		return float64(x)
+	case float32:
+		return float64(x)
+	case json.Number:
+		f, _ := x.Float64()
+		return f
+	case map[string]string:
+		m := make(map[string]any, len(x))
+		for k, s := range x {
+			m[k] = s
+		}
+		return m
+	case []string:
+		list := make([]any, len(x))
+		for i, s := range x {
+			list[i] = s
+		}
+		return list
+	}
+	return v
+}
+
+// field 读取对象字段（不存在或非对象时为 null）
+func field(x any, name string) any {
+	if m, ok := x.(map[string]any); ok {
+		return normalize(m[name])
+	}
+	return nil
+}
+
+// indexValue 下标访问：对象按 key，列表按序号（越界为 null）
+func indexValue(x, index any) (any, error) {
+	switch c := x.(type) {
+	case map[string]any:
+		key, ok := index.(string)
+		if !ok {
+			return nil, fmt.Errorf("对象下标应为字符串，实际为 %s", typeName(index))
+		}
+		return normalize(c[key]), nil
+	case []any:
+		f, ok := index.(float64)
+		if !ok {
+			return nil, fmt.Errorf("列表下标应为数字，实际为 %s", typeName(index))
+		}
+		i := int(f)
+		if i < 0 || i >= len(c) {
+			return nil, nil
+		}
+		return normalize(c[i]), nil
+	case nil:
+		return nil, nil
+	}
+	return nil, fmt.Errorf("%s 不支持下标访问", typeName(x))
+}
+
+func equal(l, r any) bool {
+	return reflect.DeepEqual(l, r)
+}
+
+// contains x in container：列表含元素、对象含 key、字符串含子串
+func contains(container, x any) (bool, error) {
+	switch c := container.(type) {
+	case []any:
+		for _, elem := range c {
+			if equal(normalize(elem), x) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case map[string]any:
+		key, ok := x.(string)
+		if !ok {
+			return false, nil
+		}
+		_, exists := c[key]
+		return exists, nil
+	case string:
+		s, ok := x.(string)
+		if !ok {
+			return false, fmt.Errorf("字符串 in 需要字符串，实际为 %s", typeName(x))
+		}
+		return strings.Contains(c, s), nil
+	case nil:
+		return false, nil
+	}
+	return false, fmt.Errorf("'in' 右侧需要列表、对象或字符串，实际为 %s", typeName(container))
+}
+
+func compare(op string, l, r any) (any, error) {
+	var cmp int
+	switch lv := l.(type) {
+	case float64:
+		rv, ok := r.(float64)
+		if !ok {
+			return nil, fmt.Errorf("无法比较 %s 与 %s", typeName(l), typeName(r))
+		}
+		switch {
+		case lv < rv:
+			cmp = -1
+		case lv > rv:
+			cmp = 1
+		}
+	case string:
+		rv, ok := r.(string)
+		if !ok {
+			return nil, fmt.Errorf("无法比较 %s 与 %s", typeName(l), typeName(r))
+		}
+		cmp = strings.Compare(lv, rv)
+	case nil:
+		// 缺失字段参与比较时结果为 false
+		return false, nil
+	default:
+		return nil, fmt.Errorf("%s 不支持 '%s'", typeName(l), op)
+	}
+	switch op {
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	case ">=":
+		return cmp >= 0, nil
+	}
+	return nil, fmt.Errorf("未知运算符 %s", op)
+}
+
+func typeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "bool"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "list"
+	case map[string]any:
+		return "map"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// toString 转换为字符串（null 为空字符串，对象/列表为 JSON）
+func toString(v any) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case bool:
+		return strconv.FormatBool(x)
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+// ==================== 内置函数 ====================
+
+func argCount(args []any, n int) error {
+	if len(args) != n {
+		return fmt.Errorf("需要 %d 个参数，实际 %d 个", n, len(args))
+	}
+	return nil
+}
+
+// stringFunc 字符串二元函数（第一个参数为 null 时返回 false）
+func stringFunc(f func(s, arg string) bool) Func {
+	return func(_ *Activation, args []any) (any, error) {
+		if err := argCount(args, 2); err != nil {
+			return nil, err
+		}
+		if args[0] == nil {
+			return false, nil
+		}
+		s, ok := args[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("第 1 个参数应为字符串，实际为 %s", typeName(args[0]))
+		}
+		arg, ok := args[1].(string)
+		if !ok {
+			return nil, fmt.Errorf("第 2 个参数应为字符串，实际为 %s", typeName(args[1]))
+		}
+		return f(s, arg), nil
+	}
+}
+
+// stringMap 字符串一元转换函数（null 视为空字符串）
+func stringMap(f func(string) string) Func {
+	return func(_ *Activation, args []any) (any, error) {
+		if err := argCount(args, 1); err != nil {
+			return nil, err
+		}
+		return f(toString(args[0])), nil
+	}
+}
+
+var builtins map[string]Func
+
+func init() {
+	builtins = map[string]Func{
+		// has(x) 字段是否存在且非 null（空字符串视为不存在，与请求头缺失等价）
+		"has": func(_ *Activation, args []any) (any, error) {
+			if err := argCount(args, 1); err != nil {
+				return nil, err
+			}
+			if s, ok := args[0].(string); ok {
+				return s != "", nil
+			}
+			return args[0] != nil, nil
+		},
+		// size(x) 字符串字符数 / 列表长度 / 对象字段数
+		"size": func(_ *Activation, args []any) (any, error) {
+			if err := argCount(args, 1); err != nil {
+				return nil, err
+			}
+			switch x := args[0].(type) {
+			case nil:
+				return 0.0, nil
+			case string:
+				return float64(len([]rune(x))), nil
+			case []any:
+				return float64(len(x)), nil
+			case map[string]any:
+				return float64(len(x)), nil
+			}
+			return nil, fmt.Errorf("%s 不支持 size", typeName(args[0]))
+		},
+		// 常量正则在编译期预编译到调用节点，这里只处理运行时拼出的正则（每次编译，不缓存）
+		"matches": stringFunc(func(s, pattern string) bool {
+			re, err := regexp.Compile(pattern)
+			return err == nil && re.MatchString(s)
+		}),
+		"startsWith": stringFunc(strings.HasPrefix),
+		"endsWith":   stringFunc(strings.HasSuffix),
+		"contains": func(act *Activation, args []any) (any, error) {
+			if err := argCount(args, 2); err != nil {
+				return nil, err
+			}
+			if list, ok := args[0].([]any); ok {
+				return contains(list, args[1])
+			}
+			return stringFunc(strings.Contains)(act, args)
+		},
+		"lower":  stringMap(strings.ToLower),
+		"upper":  stringMap(strings.ToUpper),
+		"trim":   stringMap(strings.TrimSpace),
+		"string": stringMap(func(s string) string { return s }),
+		// inCIDR(ip, "10.0.0.0/8") 或 inCIDR(ip, ["10.0.0.0/8", "1.2.3.4"])
+		"inCIDR": func(_ *Activation, args []any) (any, error) {
+			if err := argCount(args, 2); err != nil {
+				return nil, err
+			}
+			ip := net.ParseIP(toString(args[0]))
+			if ip == nil {
+				return false, nil
+			}
+			ranges := []any{args[1]}
+			if list, ok := args[1].([]any); ok {
+				ranges = list
+			}
+			for _, r := range ranges {
+				cidr := toString(r)
+				if !strings.Contains(cidr, "/") {
+					if other := net.ParseIP(cidr); other != nil && other.Equal(ip) {
+						return true, nil
+					}
+					continue
+				}
+				_, network, err := net.ParseCIDR(cidr)
+				if err != nil {
+					return nil, fmt.Errorf("无效的 CIDR %q", cidr)
+				}
+				if network.Contains(ip) {
+					return true, nil
+				}
+			}
+			return false, nil
+		},
+	}
+}
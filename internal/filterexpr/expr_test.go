@@ -0,0 +1,156 @@
+package filterexpr
+
+import (
+	"strings"
+	"testing"
+)
+
+func testVars() Vars {
+	return Vars{
+		"headers":    map[string]string{"x-app": "cli", "anthropic-version": "2023-06-01"},
+		"user_agent": "claude-cli/1.0.33 (external, cli)",
+		"ip":         "10.1.2.3",
+		"path":       "/claude/v1/messages",
+		"key":        map[string]any{"id": uint(7), "name": "team-a"},
+		"body": map[string]any{
+			"model":    "claude-sonnet-4",
+			"metadata": map[string]any{"user_id": "user_abc_account__session_1"},
+			"messages": []any{map[string]any{"role": "user"}},
+		},
+	}
+}
+
+func TestEvalExpressions(t *testing.T) {
+	cases := []struct {
+		expr string
+		want bool
+	}{
+		{`headers["x-app"] == "cli"`, true},
+		{`has(headers["x-app"]) && has(headers["anthropic-version"])`, true},
+		{`has(headers["anthropic-beta"])`, false},
+		{`user_agent.matches("^claude-cli/\\d+\\.\\d+")`, true},
+		{`body.metadata.user_id.startsWith("user_") && body.metadata.user_id.endsWith("_1")`, true},
+		{`body.missing.deep.field == null`, true},
+		{`body.missing.deep.startsWith("x")`, false},
+		{`key.id in [1, 7, 9] && key.name != "other"`, true},
+		{`size(body.messages) >= 1 && body.messages[0].role == "user"`, true},
+		{`"sonnet" in body.model || lower(path).contains("gemini")`, true},
+		{`inCIDR(ip, ["192.168.0.0/16", "10.0.0.0/8"]) && !inCIDR(ip, "10.1.2.4")`, true},
+		{`(path.startsWith("/gemini") || path.startsWith("/openai")) && true`, false},
+		{`"anthropic-version" in headers`, true},
+		{`size("你好") == 2 && 1 + 2 == 3 && -1 < 0`, true},
+	}
+	for _, tc := range cases {
+		prog, err := Compile(tc.expr)
+		if err != nil {
+			t.Fatalf("compile %q: %v", tc.expr, err)
+		}
+		got, err := prog.EvalBool(NewActivation(testVars()))
+		if err != nil {
+			t.Fatalf("eval %q: %v", tc.expr, err)
+		}
+		if got != tc.want {
+			t.Fatalf("eval %q = %v, want %v", tc.expr, got, tc.want)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	invalid := []string{
+		``,
+		`headers["x-app"] ==`,
+		`unknownFn(path)`,
+		`path.matches("(unclosed")`,
+		`"unterminated`,
+		`path == "a" ) `,
+		`path # 1`,
+	}
+	for _, expr := range invalid {
+		if _, err := Compile(expr); err == nil {
+			t.Fatalf("expected compile error for %q", expr)
+		}
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	invalid := []string{
+		`path && true`,
+		`size(true)`,
+		`path`,
+	}
+	for _, expr := range invalid {
+		prog, err := Compile(expr)
+		if err != nil {
+			t.Fatalf("compile %q: %v", expr, err)
+		}
+		if _, err := prog.EvalBool(NewActivation(testVars())); err == nil {
+			t.Fatalf("expected eval error for %q", expr)
+		}
+	}
+
+	// 短路：右侧的类型错误不会被求值
+	prog, _ := Compile(`false && size(true) > 0`)
+	if ok, err := prog.EvalBool(NewActivation(testVars())); err != nil || ok {
+		t.Fatalf("expected short-circuit false, got %v %v", ok, err)
+	}
+}
+
+func TestCustomFunctionsAndDepth(t *testing.T) {
+	var env *Env
+	sets := map[string]*Program{}
+	env = NewEnv(map[string]Func{
+		"ruleset": func(act *Activation, args []any) (any, error) {
+			prog := sets[toString(args[0])]
+			return prog.EvalBool(act)
+		},
+	})
+
+	var err error
+	if sets["ua"], err = env.Compile(`user_agent.startsWith("claude-cli/")`); err != nil {
+		t.Fatal(err)
+	}
+	if sets["loop"], err = env.Compile(`ruleset("loop")`); err != nil {
+		t.Fatal(err)
+	}
+
+	prog, err := env.Compile(`ruleset("ua") && has(headers["x-app"])`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := prog.EvalBool(NewActivation(testVars())); err != nil || !ok {
+		t.Fatalf("expected ruleset match, got %v %v", ok, err)
+	}
+
+	_, err = sets["loop"].EvalBool(NewActivation(testVars()))
+	if err == nil || !strings.Contains(err.Error(), "嵌套过深") {
+		t.Fatalf("expected depth error, got %v", err)
+	}
+
+	if _, err := Compile(`ruleset("ua")`); err == nil {
+		t.Fatalf("expected unknown function without env")
+	}
+}
+
+func TestMatchesLiteralAndRuntimePatterns(t *testing.T) {
+	prog, err := Compile(`path.matches("^/claude/")`)
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	if call, ok := prog.root.(*callNode); !ok || call.re == nil {
+		t.Fatal("expected literal pattern precompiled on the call node")
+	}
+
+	// 运行时拼出的正则每次编译，无效正则视为不匹配
+	prog, err = Compile(`path.matches(headers["x-pattern"])`)
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	for pattern, want := range map[string]bool{"^/claude/": true, "^/gemini/": false, "(unclosed": false} {
+		vars := testVars()
+		vars["headers"] = map[string]string{"x-pattern": pattern}
+		got, err := prog.EvalBool(NewActivation(vars))
+		if err != nil || got != want {
+			t.Fatalf("pattern %q: got %v %v, want %v", pattern, got, err, want)
+		}
+	}
+}
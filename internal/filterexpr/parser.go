@@ -0,0 +1,378 @@
+/*
+ * 文件作用：客户端过滤表达式语言的词法与语法分析（类 CEL 语法）
+ * 负责功能：
+ *   - 词法分析：标识符、字符串、数字、运算符、关键字
+ *   - 语法分析：逻辑 && || !、比较、in、字段/下标访问、函数与方法调用、列表字面量
+ *   - 编译期检查未知函数与常量正则
+ * 重要程度：⭐⭐⭐⭐ 重要（客户端过滤规则引擎）
+ * 依赖模块：无
+ *
+ * 语法示例：
+ *   headers["x-app"] == "cli" && user_agent.matches("^claude-cli/")
+ *   body.metadata.user_id.startsWith("user_") || key.id in [1, 2, 3]
+ *   inCIDR(ip, ["10.0.0.0/8", "192.168.0.0/16"]) && !ruleset("codex_cli")
+ */
+package filterexpr
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// maxExpressionLength 表达式最大长度
+const maxExpressionLength = 4096
+
+// tokenKind 词法单元类型
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// lex 词法分析
+func lex(src string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(src) {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '"' || c == '\'':
+			s, n, err := lexString(src[i:])
+			if err != nil {
+				return nil, fmt.Errorf("位置 %d: %v", i, err)
+			}
+			tokens = append(tokens, token{kind: tokString, text: s, pos: i})
+			i += n
+		case c >= '0' && c <= '9':
+			start := i
+			for i < len(src) && (src[i] >= '0' && src[i] <= '9' || src[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokNumber, text: src[start:i], pos: start})
+		case c == '_' || unicode.IsLetter(rune(c)):
+			start := i
+			for i < len(src) && (src[i] == '_' || unicode.IsLetter(rune(src[i])) || unicode.IsDigit(rune(src[i]))) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokIdent, text: src[start:i], pos: start})
+		default:
+			if i+1 < len(src) {
+				two := src[i : i+2]
+				switch two {
+				case "&&", "||", "==", "!=", "<=", ">=":
+					tokens = append(tokens, token{kind: tokOp, text: two, pos: i})
+					i += 2
+					continue
+				}
+			}
+			if strings.IndexByte("()[],.!<>+-", c) < 0 {
+				return nil, fmt.Errorf("位置 %d: 无法识别的字符 %q", i, c)
+			}
+			tokens = append(tokens, token{kind: tokOp, text: string(c), pos: i})
+			i++
+		}
+	}
+	return append(tokens, token{kind: tokEOF, pos: len(src)}), nil
+}
+
+// lexString 解析字符串字面量（支持 \" \' \\ \n \t 转义），返回内容与消耗的字节数
+func lexString(src string) (string, int, error) {
+	quote := src[0]
+	var b strings.Builder
+	for i := 1; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case c == quote:
+			return b.String(), i + 1, nil
+		case c == '\\' && i+1 < len(src):
+			i++
+			switch src[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			default:
+				b.WriteByte(src[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, fmt.Errorf("字符串缺少结束引号")
+}
+
+// node 语法树节点
+type node interface{}
+
+type (
+	literalNode struct{ value any }
+	identNode   struct{ name string }
+	memberNode  struct {
+		x    node
+		name string
+	}
+	indexNode struct{ x, index node }
+	callNode  struct {
+		fn   string
+		args []node
+		re   *regexp.Regexp // matches() 的常量正则（编译期预编译）
+	}
+	unaryNode struct {
+		op string
+		x  node
+	}
+	binaryNode struct {
+		op   string
+		l, r node
+	}
+	listNode struct{ elems []node }
+)
+
+// 二元运算符优先级（数值越大越先结合）
+var precedence = map[string]int{
+	"||": 1,
+	"&&": 2,
+	"==": 3, "!=": 3, "<": 3, "<=": 3, ">": 3, ">=": 3, "in": 3,
+	"+": 4, "-": 4,
+}
+
+// parser 递归下降 + 优先级爬升
+type parser struct {
+	tokens []token
+	pos    int
+	env    *Env
+}
+
+func (p *parser) peek() token { return p.tokens[p.pos] }
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) expectOp(op string) error {
+	t := p.next()
+	if t.kind != tokOp || t.text != op {
+		return fmt.Errorf("位置 %d: 期望 %q", t.pos, op)
+	}
+	return nil
+}
+
+func (p *parser) binaryOp() (string, int) {
+	t := p.peek()
+	if t.kind == tokOp || (t.kind == tokIdent && t.text == "in") {
+		if prec, ok := precedence[t.text]; ok {
+			return t.text, prec
+		}
+	}
+	return "", 0
+}
+
+func (p *parser) parseExpr(minPrec int) (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, prec := p.binaryOp()
+		if prec == 0 || prec < minPrec {
+			return left, nil
+		}
+		p.next()
+		right, err := p.parseExpr(prec + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: op, l: left, r: right}
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	t := p.peek()
+	if t.kind == tokOp && (t.text == "!" || t.text == "-") {
+		p.next()
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryNode{op: t.text, x: x}, nil
+	}
+	return p.parsePostfix()
+}
+
+func (p *parser) parsePostfix() (node, error) {
+	x, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if t.kind != tokOp {
+			return x, nil
+		}
+		switch t.text {
+		case ".":
+			p.next()
+			name := p.next()
+			if name.kind != tokIdent {
+				return nil, fmt.Errorf("位置 %d: '.' 之后应为字段或方法名", name.pos)
+			}
+			if p.peek().kind == tokOp && p.peek().text == "(" {
+				// 方法调用：x.f(a, b) 等价于 f(x, a, b)
+				args, err := p.parseArgs()
+				if err != nil {
+					return nil, err
+				}
+				call, err := p.newCall(name, append([]node{x}, args...))
+				if err != nil {
+					return nil, err
+				}
+				x = call
+			} else {
+				x = &memberNode{x: x, name: name.text}
+			}
+		case "[":
+			p.next()
+			index, err := p.parseExpr(1)
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectOp("]"); err != nil {
+				return nil, err
+			}
+			x = &indexNode{x: x, index: index}
+		default:
+			return x, nil
+		}
+	}
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokString:
+		return &literalNode{value: t.text}, nil
+	case tokNumber:
+		v, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("位置 %d: 无效的数字 %s", t.pos, t.text)
+		}
+		return &literalNode{value: v}, nil
+	case tokIdent:
+		switch t.text {
+		case "true":
+			return &literalNode{value: true}, nil
+		case "false":
+			return &literalNode{value: false}, nil
+		case "null":
+			return &literalNode{value: nil}, nil
+		}
+		if p.peek().kind == tokOp && p.peek().text == "(" {
+			args, err := p.parseArgs()
+			if err != nil {
+				return nil, err
+			}
+			return p.newCall(t, args)
+		}
+		return &identNode{name: t.text}, nil
+	case tokOp:
+		switch t.text {
+		case "(":
+			x, err := p.parseExpr(1)
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectOp(")"); err != nil {
+				return nil, err
+			}
+			return x, nil
+		case "[":
+			list := &listNode{}
+			if p.peek().kind == tokOp && p.peek().text == "]" {
+				p.next()
+				return list, nil
+			}
+			for {
+				elem, err := p.parseExpr(1)
+				if err != nil {
+					return nil, err
+				}
+				list.elems = append(list.elems, elem)
+				sep := p.next()
+				if sep.kind == tokOp && sep.text == "]" {
+					return list, nil
+				}
+				if sep.kind != tokOp || sep.text != "," {
+					return nil, fmt.Errorf("位置 %d: 列表元素之间应为 ','", sep.pos)
+				}
+			}
+		}
+	case tokEOF:
+		return nil, fmt.Errorf("表达式不完整")
+	}
+	return nil, fmt.Errorf("位置 %d: 意外的 %q", t.pos, t.text)
+}
+
+// parseArgs 解析调用参数 (a, b, ...)
+func (p *parser) parseArgs() ([]node, error) {
+	if err := p.expectOp("("); err != nil {
+		return nil, err
+	}
+	var args []node
+	if p.peek().kind == tokOp && p.peek().text == ")" {
+		p.next()
+		return args, nil
+	}
+	for {
+		arg, err := p.parseExpr(1)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		sep := p.next()
+		if sep.kind == tokOp && sep.text == ")" {
+			return args, nil
+		}
+		if sep.kind != tokOp || sep.text != "," {
+			return nil, fmt.Errorf("位置 %d: 参数之间应为 ','", sep.pos)
+		}
+	}
+}
+
+// newCall 创建函数调用节点（检查函数是否存在、常量正则是否有效）
+func (p *parser) newCall(name token, args []node) (node, error) {
+	if !p.env.hasFunc(name.text) {
+		return nil, fmt.Errorf("位置 %d: 未知函数 %s", name.pos, name.text)
+	}
+	call := &callNode{fn: name.text, args: args}
+	if name.text == "matches" && len(args) == 2 {
+		if lit, ok := args[1].(*literalNode); ok {
+			if pattern, ok := lit.value.(string); ok {
+				re, err := regexp.Compile(pattern)
+				if err != nil {
+					return nil, fmt.Errorf("位置 %d: 无效的正则 %q: %v", name.pos, pattern, err)
+				}
+				call.re = re
+			}
+		}
+	}
+	return call, nil
+}
@@ -6,6 +6,7 @@
  *   - 账户启用/禁用
  *   - 账户健康检查触发
  *   - 账户并发和缓存管理
+ *   - 账户批量导入/加密导出
  * 重要程度：⭐⭐⭐⭐ 重要（账户管理核心）
  * 依赖模块：service, model, repository
  */
@@ -13,12 +14,18 @@ package handler
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
 	"strconv"
+	"strings"
 
 	"cli-proxy/internal/model"
 	"cli-proxy/internal/repository"
 	"cli-proxy/internal/service"
 	"cli-proxy/pkg/response"
+	"cli-proxy/pkg/utils"
 
 	"github.com/gin-gonic/gin"
 )
@@ -86,6 +93,10 @@ func (h *AccountHandler) Update(c *gin.Context) {
 
 	account, err := h.service.Update(uint(id), &req)
 	if err != nil {
+		if errors.Is(err, service.ErrEgressRegionMismatch) {
+			response.Error(c, http.StatusConflict, err.Error())
+			return
+		}
 		response.InternalError(c, err.Error())
 		return
 	}
@@ -535,3 +546,77 @@ func (h *AccountHandler) BatchFetchUsage(c *gin.Context) {
 
 	response.Success(c, result)
 }
+
+// ========== 批量导入/导出 ==========
+
+// ImportAccounts 批量导入账户
+// POST /api/admin/accounts/import
+// 支持 JSON 请求体（data 字段携带文件内容）或 multipart 上传（file 字段）
+func (h *AccountHandler) ImportAccounts(c *gin.Context) {
+	var req service.ImportAccountsRequest
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		file, err := c.FormFile("file")
+		if err != nil {
+			response.BadRequest(c, "缺少导入文件")
+			return
+		}
+		f, err := file.Open()
+		if err != nil {
+			response.BadRequest(c, err.Error())
+			return
+		}
+		defer f.Close()
+		data, err := utils.ReadAllWithLimit(f, utils.MaxRequestBodyBytes)
+		if err != nil {
+			response.BadRequest(c, "导入文件过大")
+			return
+		}
+		req.Data = string(data)
+		req.Format = c.PostForm("format")
+		req.Passphrase = c.PostForm("passphrase")
+		req.DryRun, _ = strconv.ParseBool(c.PostForm("dry_run"))
+		req.HealthCheck, _ = strconv.ParseBool(c.PostForm("health_check"))
+		if groupID, err := strconv.ParseUint(c.PostForm("group_id"), 10, 32); err == nil {
+			req.GroupID = uint(groupID)
+		}
+		if req.Format == "" {
+			switch strings.ToLower(filepath.Ext(file.Filename)) {
+			case ".csv":
+				req.Format = service.AccountImportFormatCSV
+			case ".jsonl":
+				req.Format = service.AccountImportFormatJSONL
+			}
+		}
+	} else if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	result, err := h.service.ImportAccounts(&req)
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+	response.Success(c, result)
+}
+
+// ExportAccounts 导出账户为口令加密的凭证包（下载文件）
+// POST /api/admin/accounts/export
+func (h *AccountHandler) ExportAccounts(c *gin.Context) {
+	var req service.ExportAccountsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	bundle, err := h.service.ExportAccounts(&req)
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	filename := fmt.Sprintf("accounts-%s.json", bundle.ExportedAt.Format("20060102-150405"))
+	c.Header("Content-Description", "File Transfer")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+	c.JSON(http.StatusOK, bundle)
+}
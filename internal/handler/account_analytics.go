@@ -0,0 +1,72 @@
+/*
+ * 文件作用：账户成本与盈利分析处理器
+ * 负责功能：
+ *   - 各账户成本、收入、利润与利用率
+ *   - 各账户分组的成本与利润汇总
+ *   - 成本、收入与每次成功请求成本趋势
+ * 重要程度：⭐⭐⭐ 一般（运营分析）
+ * 依赖模块：service
+ */
+package handler
+
+import (
+	"strconv"
+
+	"cli-proxy/internal/service"
+	"cli-proxy/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AccountAnalyticsHandler 账户成本与盈利分析处理器
+type AccountAnalyticsHandler struct {
+	service *service.AccountAnalyticsService
+}
+
+// NewAccountAnalyticsHandler 创建账户成本与盈利分析处理器
+func NewAccountAnalyticsHandler() *AccountAnalyticsHandler {
+	return &AccountAnalyticsHandler{
+		service: service.NewAccountAnalyticsService(),
+	}
+}
+
+// GetAccounts 各账户成本与盈利
+// GET /api/admin/analytics/accounts?start_date=&end_date=&platform=
+func (h *AccountAnalyticsHandler) GetAccounts(c *gin.Context) {
+	accounts, totals, err := h.service.GetAccountProfitability(c.Request.Context(),
+		c.Query("start_date"), c.Query("end_date"), c.Query("platform"))
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+	response.Success(c, gin.H{
+		"accounts": accounts,
+		"totals":   totals,
+	})
+}
+
+// GetGroups 各账户分组成本与盈利
+// GET /api/admin/analytics/groups?start_date=&end_date=
+func (h *AccountAnalyticsHandler) GetGroups(c *gin.Context) {
+	groups, err := h.service.GetGroupProfitability(c.Request.Context(), c.Query("start_date"), c.Query("end_date"))
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+	response.Success(c, gin.H{"groups": groups})
+}
+
+// GetTrend 成本与收入趋势
+// GET /api/admin/analytics/trend?start_date=&end_date=&granularity=day|week|month&account_id=&group_id=
+func (h *AccountAnalyticsHandler) GetTrend(c *gin.Context) {
+	accountID, _ := strconv.ParseUint(c.Query("account_id"), 10, 32)
+	groupID, _ := strconv.ParseUint(c.Query("group_id"), 10, 32)
+
+	points, err := h.service.GetProfitTrend(c.Request.Context(), c.Query("start_date"), c.Query("end_date"),
+		c.Query("granularity"), uint(accountID), uint(groupID))
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+	response.Success(c, gin.H{"points": points})
+}
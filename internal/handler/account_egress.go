@@ -0,0 +1,65 @@
+/*
+ * 文件作用：账户出口 IP API 处理器
+ * 负责功能：
+ *   - 账户当前出口 IP 与最近变更查询
+ *   - 出口 IP 变更记录分页查询
+ * 重要程度：⭐⭐ 辅助（账户风控）
+ * 依赖模块：service, repository
+ */
+package handler
+
+import (
+	"strconv"
+
+	"cli-proxy/internal/repository"
+	"cli-proxy/internal/service"
+	"cli-proxy/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// accountEgressRecentChanges 账户出口详情返回的最近变更条数
+const accountEgressRecentChanges = 20
+
+// GetAccountEgress 获取账户当前出口 IP 与最近的变更记录
+func GetAccountEgress(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "invalid account id")
+		return
+	}
+
+	current, changes, err := service.GetAccountEgressService().Get(uint(id), accountEgressRecentChanges)
+	if err != nil {
+		response.InternalError(c, err.Error())
+		return
+	}
+	response.Success(c, gin.H{
+		"current": current,
+		"changes": changes,
+	})
+}
+
+// ListEgressChanges 分页查询出口 IP 变更记录（可按账户、是否跨地区筛选）
+func ListEgressChanges(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	accountID, _ := strconv.ParseUint(c.Query("account_id"), 10, 32)
+
+	filter := &repository.EgressChangeFilter{
+		AccountID:   uint(accountID),
+		CrossRegion: c.Query("cross_region") == "true",
+	}
+	changes, total, err := service.GetAccountEgressService().ListChanges(filter, page, pageSize)
+	if err != nil {
+		response.InternalError(c, err.Error())
+		return
+	}
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+	response.SuccessWithPagination(c, changes, total, page, pageSize)
+}
@@ -149,6 +149,36 @@ func (h *APIKeyHandler) AdminToggleStatus(c *gin.Context) {
 	response.Success(c, gin.H{"status": key.Status})
 }
 
+// AdminSetStatus 管理员将 API Key 设置为指定状态
+// PUT /api/admin/api-keys/:id/status {"status": "active" | "disabled"}
+func (h *APIKeyHandler) AdminSetStatus(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "无效的 API Key ID")
+		return
+	}
+
+	var req struct {
+		Status string `json:"status" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+	if req.Status != "active" && req.Status != "disabled" {
+		response.BadRequest(c, "无效的状态，仅支持 active 或 disabled")
+		return
+	}
+
+	key, err := h.service.SetStatus(uint(id), req.Status)
+	if err != nil {
+		response.InternalError(c, err.Error())
+		return
+	}
+
+	response.Success(c, gin.H{"status": key.Status})
+}
+
 // AdminLookup 管理员按 ID 批量查询 API Key（用于前端显示 sk- 前缀/完整 Key）
 // GET /api/api-keys/lookup?ids=1,2,3
 func (h *APIKeyHandler) AdminLookup(c *gin.Context) {
@@ -251,9 +281,17 @@ func (h *APIKeyHandler) GetMyInfo(c *gin.Context) {
 		"allowed_models":    key.AllowedModels,
 		"blocked_models":    key.BlockedModels,
 		"allowed_clients":   key.AllowedClients,
+		"allowed_ips":       key.AllowedIPs,
+		"allowed_countries": key.AllowedCountries,
+		"blocked_countries": key.BlockedCountries,
 		"rate_limit":        key.RateLimit,
 		"daily_limit":       key.DailyLimit,
 		"monthly_quota":     key.MonthlyQuota,
+		"max_concurrency":   key.MaxConcurrency,
+		"tpm_limit":         key.TPMLimit,
+		"itpm_limit":        key.ITPMLimit,
+		"otpm_limit":        key.OTPMLimit,
+		"preferred_locale":  key.PreferredLocale,
 		"expires_at":        key.ExpiresAt,
 		"created_at":        key.CreatedAt,
 	})
@@ -4,8 +4,9 @@
  *   - 缓存统计信息查询
  *   - 会话缓存管理（列表、删除）
  *   - 账户/用户缓存管理
- *   - 并发计数管理
+ *   - 并发计数管理（账户、API Key 实时并发）
  *   - 不可用账户标记管理
+ *   - 响应缓存管理（统计、列表、清理）
  *   - 缓存配置管理
  * 重要程度：⭐⭐⭐ 一般（管理后台功能）
  * 依赖模块：service, config
@@ -270,6 +271,38 @@ func (h *CacheHandler) ResetAccountConcurrency(c *gin.Context) {
 	response.Success(c, gin.H{"message": "concurrency reset"})
 }
 
+// ListAPIKeyConcurrency 列出各 API Key 实时并发（进行中/排队中）
+// GET /api/admin/cache/api-keys/concurrency
+func (h *CacheHandler) ListAPIKeyConcurrency(c *gin.Context) {
+	items, err := h.cacheService.ListAPIKeyConcurrency(c.Request.Context())
+	if err != nil {
+		response.InternalError(c, err.Error())
+		return
+	}
+
+	response.Success(c, gin.H{
+		"items": items,
+		"total": len(items),
+	})
+}
+
+// ResetAPIKeyConcurrency 重置 API Key 并发计数
+// DELETE /api/admin/cache/api-keys/:id/concurrency
+func (h *CacheHandler) ResetAPIKeyConcurrency(c *gin.Context) {
+	apiKeyID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "invalid api_key_id")
+		return
+	}
+
+	if err := h.cacheService.ResetAPIKeyConcurrency(c.Request.Context(), uint(apiKeyID)); err != nil {
+		response.InternalError(c, err.Error())
+		return
+	}
+
+	response.Success(c, gin.H{"message": "concurrency reset"})
+}
+
 // ClearCache 清理缓存
 func (h *CacheHandler) ClearCache(c *gin.Context) {
 	var req struct {
@@ -315,22 +348,28 @@ func (h *CacheHandler) ClearAPIKeyCache(c *gin.Context) {
 func (h *CacheHandler) GetCacheConfig(c *gin.Context) {
 	cfg := config.Cfg.Cache
 	response.Success(c, gin.H{
-		"session_ttl":             cfg.GetSessionTTL(),
-		"session_renewal_ttl":     cfg.GetSessionRenewalTTL(),
-		"unavailable_ttl":         cfg.GetUnavailableTTL(),
-		"concurrency_ttl":         cfg.GetConcurrencyTTL(),
-		"default_concurrency_max": cfg.GetDefaultConcurrencyMax(),
+		"session_ttl":                 cfg.GetSessionTTL(),
+		"session_renewal_ttl":         cfg.GetSessionRenewalTTL(),
+		"unavailable_ttl":             cfg.GetUnavailableTTL(),
+		"concurrency_ttl":             cfg.GetConcurrencyTTL(),
+		"default_concurrency_max":     cfg.GetDefaultConcurrencyMax(),
+		"response_cache_ttl":          cfg.GetResponseCacheTTL(),
+		"response_cache_max_mb":       cfg.GetResponseCacheMaxMB(),
+		"response_cache_max_entry_kb": cfg.GetResponseCacheMaxEntryKB(),
 	})
 }
 
 // UpdateCacheConfig 更新缓存配置（运行时修改，重启后恢复配置文件设置）
 func (h *CacheHandler) UpdateCacheConfig(c *gin.Context) {
 	var req struct {
-		SessionTTL            *int `json:"session_ttl"`
-		SessionRenewalTTL     *int `json:"session_renewal_ttl"`
-		UnavailableTTL        *int `json:"unavailable_ttl"`
-		ConcurrencyTTL        *int `json:"concurrency_ttl"`
-		DefaultConcurrencyMax *int `json:"default_concurrency_max"`
+		SessionTTL              *int `json:"session_ttl"`
+		SessionRenewalTTL       *int `json:"session_renewal_ttl"`
+		UnavailableTTL          *int `json:"unavailable_ttl"`
+		ConcurrencyTTL          *int `json:"concurrency_ttl"`
+		DefaultConcurrencyMax   *int `json:"default_concurrency_max"`
+		ResponseCacheTTL        *int `json:"response_cache_ttl"`
+		ResponseCacheMaxMB      *int `json:"response_cache_max_mb"`
+		ResponseCacheMaxEntryKB *int `json:"response_cache_max_entry_kb"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		response.BadRequest(c, err.Error())
@@ -353,13 +392,62 @@ func (h *CacheHandler) UpdateCacheConfig(c *gin.Context) {
 	if req.DefaultConcurrencyMax != nil && *req.DefaultConcurrencyMax > 0 {
 		cfg.DefaultConcurrencyMax = *req.DefaultConcurrencyMax
 	}
+	if req.ResponseCacheTTL != nil && *req.ResponseCacheTTL > 0 {
+		cfg.ResponseCacheTTL = *req.ResponseCacheTTL
+	}
+	if req.ResponseCacheMaxMB != nil && *req.ResponseCacheMaxMB > 0 {
+		cfg.ResponseCacheMaxMB = *req.ResponseCacheMaxMB
+	}
+	if req.ResponseCacheMaxEntryKB != nil && *req.ResponseCacheMaxEntryKB > 0 {
+		cfg.ResponseCacheMaxEntryKB = *req.ResponseCacheMaxEntryKB
+	}
+
+	response.Success(c, gin.H{
+		"message":                     "config updated (runtime only)",
+		"session_ttl":                 cfg.GetSessionTTL(),
+		"session_renewal_ttl":         cfg.GetSessionRenewalTTL(),
+		"unavailable_ttl":             cfg.GetUnavailableTTL(),
+		"concurrency_ttl":             cfg.GetConcurrencyTTL(),
+		"default_concurrency_max":     cfg.GetDefaultConcurrencyMax(),
+		"response_cache_ttl":          cfg.GetResponseCacheTTL(),
+		"response_cache_max_mb":       cfg.GetResponseCacheMaxMB(),
+		"response_cache_max_entry_kb": cfg.GetResponseCacheMaxEntryKB(),
+	})
+}
+
+// GetResponseCache 获取响应缓存统计和条目列表
+// 支持 api_key_id、model 过滤
+func (h *CacheHandler) GetResponseCache(c *gin.Context) {
+	apiKeyID, _ := strconv.ParseUint(c.Query("api_key_id"), 10, 32)
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if limit <= 0 || limit > 100 {
+		limit = 100
+	}
 
+	entries, total := h.cacheService.ListResponseCache(uint(apiKeyID), c.Query("model"), offset, limit)
 	response.Success(c, gin.H{
-		"message":                 "config updated (runtime only)",
-		"session_ttl":             cfg.GetSessionTTL(),
-		"session_renewal_ttl":     cfg.GetSessionRenewalTTL(),
-		"unavailable_ttl":         cfg.GetUnavailableTTL(),
-		"concurrency_ttl":         cfg.GetConcurrencyTTL(),
-		"default_concurrency_max": cfg.GetDefaultConcurrencyMax(),
+		"stats":   h.cacheService.GetResponseCacheStats(),
+		"entries": entries,
+		"total":   total,
+		"offset":  offset,
+		"limit":   limit,
 	})
 }
+
+// DeleteResponseCacheEntry 删除单条响应缓存
+func (h *CacheHandler) DeleteResponseCacheEntry(c *gin.Context) {
+	key := c.Param("key")
+	if !h.cacheService.DeleteResponseCache(key) {
+		response.NotFound(c, "cache entry not found")
+		return
+	}
+	response.Success(c, gin.H{"message": "cache entry removed"})
+}
+
+// PurgeResponseCache 按条件清理响应缓存（不带条件时清空全部）
+func (h *CacheHandler) PurgeResponseCache(c *gin.Context) {
+	apiKeyID, _ := strconv.ParseUint(c.Query("api_key_id"), 10, 32)
+	deleted := h.cacheService.PurgeResponseCache(uint(apiKeyID), c.Query("model"))
+	response.Success(c, gin.H{"deleted_count": deleted})
+}
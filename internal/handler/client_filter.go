@@ -3,8 +3,10 @@
  * 负责功能：
  *   - 全局过滤配置管理
  *   - 客户端类型定义（Claude Code/Cursor/Cline等）
- *   - 过滤规则CRUD
- *   - 规则测试验证
+ *   - 过滤规则CRUD（含表达式规则、影子模式）
+ *   - 可复用规则集CRUD
+ *   - 规则匹配统计
+ *   - 规则测试验证、表达式试运行
  *   - 缓存刷新
  * 重要程度：⭐⭐⭐ 一般（客户端过滤功能）
  * 依赖模块：service, model
@@ -12,6 +14,7 @@
 package handler
 
 import (
+	"errors"
 	"strconv"
 
 	"cli-proxy/internal/model"
@@ -118,6 +121,10 @@ func (h *ClientFilterHandler) CreateClientType(c *gin.Context) {
 	}
 
 	if err := h.service.CreateClientType(&ct); err != nil {
+		if errors.Is(err, service.ErrInvalidClientFilterRule) {
+			response.BadRequest(c, err.Error())
+			return
+		}
 		response.InternalError(c, "创建客户端类型失败: "+err.Error())
 		return
 	}
@@ -141,6 +148,10 @@ func (h *ClientFilterHandler) UpdateClientType(c *gin.Context) {
 
 	ct.ID = uint(id)
 	if err := h.service.UpdateClientType(&ct); err != nil {
+		if errors.Is(err, service.ErrInvalidClientFilterRule) {
+			response.BadRequest(c, err.Error())
+			return
+		}
 		response.InternalError(c, "更新客户端类型失败: "+err.Error())
 		return
 	}
@@ -261,6 +272,10 @@ func (h *ClientFilterHandler) CreateRule(c *gin.Context) {
 	}
 
 	if err := h.service.CreateRule(&rule); err != nil {
+		if errors.Is(err, service.ErrInvalidClientFilterRule) {
+			response.BadRequest(c, err.Error())
+			return
+		}
 		response.InternalError(c, "创建规则失败: "+err.Error())
 		return
 	}
@@ -284,6 +299,10 @@ func (h *ClientFilterHandler) UpdateRule(c *gin.Context) {
 
 	rule.ID = uint(id)
 	if err := h.service.UpdateRule(&rule); err != nil {
+		if errors.Is(err, service.ErrInvalidClientFilterRule) {
+			response.BadRequest(c, err.Error())
+			return
+		}
 		response.InternalError(c, "更新规则失败: "+err.Error())
 		return
 	}
@@ -340,10 +359,143 @@ func (h *ClientFilterHandler) TestValidation(c *gin.Context) {
 		return
 	}
 
-	result := h.service.ValidateRequest(&reqCtx)
+	result := h.service.TestRequest(&reqCtx)
 	response.Success(c, result)
 }
 
+// TestExpressionRequest 表达式试运行请求
+type TestExpressionRequest struct {
+	Expression string                 `json:"expression" binding:"required"`
+	Request    service.RequestContext `json:"request"` // 样例请求
+}
+
+// TestExpression 对样例请求试运行表达式
+func (h *ClientFilterHandler) TestExpression(c *gin.Context) {
+	var req TestExpressionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "无效的请求数据")
+		return
+	}
+
+	result, err := h.service.TestExpression(req.Expression, &req.Request)
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+	response.Success(c, result)
+}
+
+// ==================== 规则统计 ====================
+
+// GetRuleStats 获取规则匹配统计
+func (h *ClientFilterHandler) GetRuleStats(c *gin.Context) {
+	response.Success(c, h.service.GetRuleStats())
+}
+
+// ResetRuleStats 重置规则匹配统计
+func (h *ClientFilterHandler) ResetRuleStats(c *gin.Context) {
+	h.service.ResetRuleStats()
+	response.Success(c, nil)
+}
+
+// ==================== 规则集管理 ====================
+
+// ListRuleSets 获取所有规则集
+func (h *ClientFilterHandler) ListRuleSets(c *gin.Context) {
+	sets, err := h.service.ListRuleSets()
+	if err != nil {
+		response.InternalError(c, "获取规则集失败: "+err.Error())
+		return
+	}
+
+	response.Success(c, sets)
+}
+
+// GetRuleSet 获取单个规则集
+func (h *ClientFilterHandler) GetRuleSet(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "无效的 ID")
+		return
+	}
+
+	set, err := h.service.GetRuleSet(uint(id))
+	if err != nil {
+		response.NotFound(c, "规则集不存在")
+		return
+	}
+
+	response.Success(c, set)
+}
+
+// CreateRuleSet 创建规则集
+func (h *ClientFilterHandler) CreateRuleSet(c *gin.Context) {
+	var set model.ClientRuleSet
+	if err := c.ShouldBindJSON(&set); err != nil {
+		response.BadRequest(c, "无效的数据")
+		return
+	}
+
+	if set.Name == "" {
+		response.BadRequest(c, "name 不能为空")
+		return
+	}
+
+	if err := h.service.CreateRuleSet(&set); err != nil {
+		if errors.Is(err, service.ErrInvalidClientFilterRule) {
+			response.BadRequest(c, err.Error())
+			return
+		}
+		response.InternalError(c, "创建规则集失败: "+err.Error())
+		return
+	}
+
+	response.Created(c, set)
+}
+
+// UpdateRuleSet 更新规则集
+func (h *ClientFilterHandler) UpdateRuleSet(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "无效的 ID")
+		return
+	}
+
+	var set model.ClientRuleSet
+	if err := c.ShouldBindJSON(&set); err != nil {
+		response.BadRequest(c, "无效的数据")
+		return
+	}
+
+	set.ID = uint(id)
+	if err := h.service.UpdateRuleSet(&set); err != nil {
+		if errors.Is(err, service.ErrInvalidClientFilterRule) {
+			response.BadRequest(c, err.Error())
+			return
+		}
+		response.InternalError(c, "更新规则集失败: "+err.Error())
+		return
+	}
+
+	response.Success(c, set)
+}
+
+// DeleteRuleSet 删除规则集
+func (h *ClientFilterHandler) DeleteRuleSet(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "无效的 ID")
+		return
+	}
+
+	if err := h.service.DeleteRuleSet(uint(id)); err != nil {
+		response.InternalError(c, "删除规则集失败: "+err.Error())
+		return
+	}
+
+	response.Success(c, nil)
+}
+
 // ReloadCache 重新加载缓存
 func (h *ClientFilterHandler) ReloadCache(c *gin.Context) {
 	if err := h.service.ReloadCache(); err != nil {
@@ -0,0 +1,107 @@
+/*
+ * 文件作用：客户端指纹分析管理处理器
+ * 负责功能：
+ *   - API Key 已知客户端指纹、按小时的指纹统计查询
+ *   - 指纹异常告警查询与确认
+ * 重要程度：⭐⭐⭐ 一般（安全分析）
+ * 依赖模块：service, repository
+ */
+package handler
+
+import (
+	"strconv"
+
+	"cli-proxy/internal/repository"
+	"cli-proxy/internal/service"
+	"cli-proxy/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ClientFingerprintHandler 客户端指纹分析管理处理器
+type ClientFingerprintHandler struct {
+	service *service.ClientFingerprintService
+}
+
+// NewClientFingerprintHandler 创建客户端指纹分析管理处理器
+func NewClientFingerprintHandler() *ClientFingerprintHandler {
+	return &ClientFingerprintHandler{service: service.GetClientFingerprintService()}
+}
+
+// ListFingerprints Key 的已知客户端指纹
+// GET /api/admin/api-keys/:id/fingerprints
+func (h *ClientFingerprintHandler) ListFingerprints(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "无效的ID")
+		return
+	}
+	fps, err := h.service.ListFingerprints(uint(id))
+	if err != nil {
+		response.InternalError(c, err.Error())
+		return
+	}
+	response.Success(c, fps)
+}
+
+// GetStats Key 的指纹小时统计
+// GET /api/admin/api-keys/:id/fingerprint-stats?dimension=client_type|user_agent|os|ip&hours=24
+func (h *ClientFingerprintHandler) GetStats(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "无效的ID")
+		return
+	}
+	hours, _ := strconv.Atoi(c.DefaultQuery("hours", "24"))
+	if hours < 1 || hours > 24*90 {
+		hours = 24
+	}
+	stats, err := h.service.GetStats(uint(id), c.Query("dimension"), hours)
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+	response.Success(c, stats)
+}
+
+// ListAlerts 指纹告警列表
+// GET /api/admin/fingerprint-alerts?page=&page_size=&api_key_id=&alert_type=&acknowledged=true|false
+func (h *ClientFingerprintHandler) ListAlerts(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+	filter := &repository.FingerprintAlertFilter{AlertType: c.Query("alert_type")}
+	if keyID, err := strconv.ParseUint(c.Query("api_key_id"), 10, 32); err == nil {
+		filter.APIKeyID = uint(keyID)
+	}
+	if ack, err := strconv.ParseBool(c.Query("acknowledged")); err == nil {
+		filter.Acknowledged = &ack
+	}
+
+	alerts, total, err := h.service.ListAlerts(filter, page, pageSize)
+	if err != nil {
+		response.InternalError(c, err.Error())
+		return
+	}
+	response.SuccessWithPagination(c, alerts, total, page, pageSize)
+}
+
+// AcknowledgeAlert 确认告警
+// PUT /api/admin/fingerprint-alerts/:id/ack
+func (h *ClientFingerprintHandler) AcknowledgeAlert(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "无效的ID")
+		return
+	}
+	if err := h.service.AcknowledgeAlert(uint(id), c.GetString("username")); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+	response.Success(c, nil)
+}
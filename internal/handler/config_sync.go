@@ -0,0 +1,148 @@
+/*
+ * 文件作用：配置备份/恢复处理器，提供声明式配置的导出、计划和应用接口
+ * 负责功能：
+ *   - 导出运行时配置为 YAML 文件
+ *   - 对比上传的 YAML 与数据库生成变更计划
+ *   - 应用 YAML 配置并通知相关组件
+ * 重要程度：⭐⭐⭐ 一般（运维功能）
+ * 依赖模块：service, configsync
+ */
+package handler
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"cli-proxy/internal/configsync"
+	"cli-proxy/internal/service"
+	"cli-proxy/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxConfigSyncBody 配置文件最大大小
+const maxConfigSyncBody = 10 << 20
+
+type ConfigSyncHandler struct {
+	service *service.ConfigSyncService
+}
+
+func NewConfigSyncHandler() *ConfigSyncHandler {
+	return &ConfigSyncHandler{
+		service: service.GetConfigSyncService(),
+	}
+}
+
+// Sections 获取可同步的配置分区
+func (h *ConfigSyncHandler) Sections(c *gin.Context) {
+	response.Success(c, gin.H{"sections": configsync.SectionNames()})
+}
+
+// Export 导出配置
+// 查询参数: sections（逗号分隔，默认全部）、omit_secrets（true 时敏感字段导出为空）
+func (h *ConfigSyncHandler) Export(c *gin.Context) {
+	opts := configsync.ExportOptions{
+		OmitSecrets: c.Query("omit_secrets") == "true",
+	}
+	if sections := c.Query("sections"); sections != "" {
+		for _, name := range strings.Split(sections, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				opts.Sections = append(opts.Sections, name)
+			}
+		}
+	}
+
+	data, err := h.service.Export(opts)
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	filename := fmt.Sprintf("cli-proxy-config-%s.yaml", time.Now().Format("20060102-150405"))
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+	c.Data(http.StatusOK, "application/x-yaml; charset=utf-8", data)
+}
+
+// Plan 生成变更计划（请求体为 YAML 配置，不修改数据库）
+func (h *ConfigSyncHandler) Plan(c *gin.Context) {
+	data, ok := h.readDocument(c)
+	if !ok {
+		return
+	}
+	plan, err := h.service.Plan(data, configsync.Options{Prune: c.Query("prune") == "true"})
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+	response.Success(c, gin.H{
+		"changes": plan.Changes,
+		"summary": plan.Summary,
+		"text":    plan.String(),
+	})
+}
+
+// Apply 应用配置（请求体为 YAML 配置）
+func (h *ConfigSyncHandler) Apply(c *gin.Context) {
+	data, ok := h.readDocument(c)
+	if !ok {
+		return
+	}
+	plan, err := h.service.Apply(data, configsync.Options{Prune: c.Query("prune") == "true"})
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	// 系统配置变更需通知相关组件（健康检查间隔等）
+	if keys := plan.ChangedKeys("system_configs"); len(keys) > 0 {
+		configService := service.GetConfigService()
+		changed := make(map[string]string, len(keys))
+		for _, key := range keys {
+			changed[key] = configService.GetString(key)
+		}
+		(&ConfigHandler{configService: configService}).notifyConfigChange(changed)
+	}
+
+	response.Success(c, gin.H{
+		"changes": plan.Changes,
+		"summary": plan.Summary,
+		"text":    plan.String(),
+	})
+}
+
+// readDocument 读取请求体中的 YAML 配置（支持 multipart 上传的 file 字段）
+func (h *ConfigSyncHandler) readDocument(c *gin.Context) ([]byte, bool) {
+	var reader io.Reader = c.Request.Body
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		fileHeader, err := c.FormFile("file")
+		if err != nil {
+			response.BadRequest(c, "请上传配置文件")
+			return nil, false
+		}
+		file, err := fileHeader.Open()
+		if err != nil {
+			response.BadRequest(c, "读取配置文件失败")
+			return nil, false
+		}
+		defer file.Close()
+		reader = file
+	}
+
+	data, err := io.ReadAll(io.LimitReader(reader, maxConfigSyncBody+1))
+	if err != nil {
+		response.BadRequest(c, "读取配置文件失败")
+		return nil, false
+	}
+	if len(data) > maxConfigSyncBody {
+		response.BadRequest(c, "配置文件过大")
+		return nil, false
+	}
+	if len(strings.TrimSpace(string(data))) == 0 {
+		response.BadRequest(c, "配置内容为空")
+		return nil, false
+	}
+	return data, true
+}
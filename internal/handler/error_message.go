@@ -6,12 +6,15 @@
  *   - 错误消息启用/禁用
  *   - 默认消息初始化
  *   - 缓存刷新
+ *   - 多语言/模板消息预览
  * 重要程度：⭐⭐⭐ 一般（错误处理增强）
  * 依赖模块：service, model
  */
 package handler
 
 import (
+	"errors"
+
 	"cli-proxy/internal/model"
 	"cli-proxy/internal/service"
 	"cli-proxy/pkg/response"
@@ -98,9 +101,10 @@ func (h *ErrorMessageHandler) Get(c *gin.Context) {
 
 // UpdateRequest 更新请求
 type UpdateErrorMessageRequest struct {
-	CustomMessage string `json:"custom_message" binding:"required"`
-	Enabled       bool   `json:"enabled"`
-	Description   string `json:"description"`
+	CustomMessage string  `json:"custom_message" binding:"required"`
+	Translations  *string `json:"translations"` // 多语言消息 JSON（不传则保留原值）
+	Enabled       bool    `json:"enabled"`
+	Description   string  `json:"description"`
 }
 
 // Update 更新错误消息配置
@@ -126,7 +130,11 @@ func (h *ErrorMessageHandler) Update(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.Update(uint(id), req.CustomMessage, req.Enabled, req.Description); err != nil {
+	if err := h.service.Update(uint(id), req.CustomMessage, req.Enabled, req.Description, req.Translations); err != nil {
+		if errors.Is(err, service.ErrInvalidErrorMessage) {
+			response.BadRequest(c, err.Error())
+			return
+		}
 		response.InternalError(c, "更新失败: "+err.Error())
 		return
 	}
@@ -192,6 +200,7 @@ type CreateErrorMessageRequest struct {
 	Code          int    `json:"code" binding:"required"`
 	ErrorType     string `json:"error_type" binding:"required"`
 	CustomMessage string `json:"custom_message" binding:"required"`
+	Translations  string `json:"translations"` // 多语言消息 JSON，如 {"en":"...","ja":"..."}
 	Enabled       bool   `json:"enabled"`
 	Description   string `json:"description"`
 }
@@ -216,11 +225,16 @@ func (h *ErrorMessageHandler) Create(c *gin.Context) {
 		Code:          req.Code,
 		ErrorType:     req.ErrorType,
 		CustomMessage: req.CustomMessage,
+		Translations:  req.Translations,
 		Enabled:       req.Enabled,
 		Description:   req.Description,
 	}
 
 	if err := h.service.Create(msg); err != nil {
+		if errors.Is(err, service.ErrInvalidErrorMessage) {
+			response.BadRequest(c, err.Error())
+			return
+		}
 		response.InternalError(c, "创建失败: "+err.Error())
 		return
 	}
@@ -290,3 +304,35 @@ func (h *ErrorMessageHandler) DisableAll(c *gin.Context) {
 		"message":  "已禁用所有错误消息配置",
 	})
 }
+
+// Preview 预览本地化错误消息
+// @Summary 预览指定语言与模板数据下的错误消息
+// @Tags 管理员-错误消息
+// @Security Bearer
+// @Accept json
+// @Produce json
+// @Param body body service.PreviewErrorMessageRequest true "预览参数"
+// @Success 200 {object} response.Response
+// @Router /api/admin/error-messages/preview [post]
+func (h *ErrorMessageHandler) Preview(c *gin.Context) {
+	var req service.PreviewErrorMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	result, err := h.service.Preview(&req)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidErrorMessage) {
+			response.BadRequest(c, err.Error())
+			return
+		}
+		response.InternalError(c, "预览失败: "+err.Error())
+		return
+	}
+	response.Success(c, gin.H{
+		"locales":         result.Locales,
+		"message":         result.Message,
+		"template_fields": model.ErrorMessageTemplateFields,
+	})
+}
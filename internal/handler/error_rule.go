@@ -5,12 +5,14 @@
  *   - 规则启用/禁用
  *   - 默认规则重置
  *   - 规则缓存刷新
+ *   - 规则样例测试
  * 重要程度：⭐⭐⭐ 一般（错误处理增强）
  * 依赖模块：service, errormatch
  */
 package handler
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -43,6 +45,13 @@ func (h *ErrorRuleHandler) Create(c *gin.Context) {
 
 	rule, err := h.service.Create(&req)
 	if err != nil {
+		if errors.Is(err, service.ErrInvalidErrorRule) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": err.Error(),
+			})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code":    500,
 			"message": "创建失败: " + err.Error(),
@@ -108,6 +117,13 @@ func (h *ErrorRuleHandler) Update(c *gin.Context) {
 
 	rule, err := h.service.Update(uint(id), &req)
 	if err != nil {
+		if errors.Is(err, service.ErrInvalidErrorRule) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": err.Error(),
+			})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code":    500,
 			"message": "更新失败: " + err.Error(),
@@ -174,6 +190,35 @@ func (h *ErrorRuleHandler) List(c *gin.Context) {
 	})
 }
 
+// Test 用错误样例测试规则（不保存，不影响账户状态）
+func (h *ErrorRuleHandler) Test(c *gin.Context) {
+	var req service.TestRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	results, err := h.service.Test(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    0,
+		"message": "success",
+		"data": gin.H{
+			"items": results,
+		},
+	})
+}
+
 // ResetToDefault 重置为默认规则
 func (h *ErrorRuleHandler) ResetToDefault(c *gin.Context) {
 	if err := h.service.ResetToDefault(); err != nil {
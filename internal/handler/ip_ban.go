@@ -0,0 +1,108 @@
+/*
+ * 文件作用：IP 封禁管理处理器
+ * 负责功能：
+ *   - 封禁记录查询（生效/过期/已解除，手动/自动）
+ *   - 手动封禁、解封
+ *   - 检查指定 IP 当前是否被封禁、查看本次请求解析出的客户端 IP
+ * 重要程度：⭐⭐⭐ 一般（安全管理）
+ * 依赖模块：service, repository, middleware
+ */
+package handler
+
+import (
+	"net"
+	"strconv"
+
+	"cli-proxy/internal/middleware"
+	"cli-proxy/internal/repository"
+	"cli-proxy/internal/service"
+	"cli-proxy/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IPBanHandler IP 封禁管理处理器
+type IPBanHandler struct {
+	ipGuard *service.IPGuardService
+}
+
+// NewIPBanHandler 创建 IP 封禁管理处理器
+func NewIPBanHandler() *IPBanHandler {
+	return &IPBanHandler{ipGuard: service.GetIPGuardService()}
+}
+
+// List 封禁记录列表
+// GET /api/admin/ip-bans?page=&page_size=&status=active|expired|revoked&source=manual|auto&cidr=
+func (h *IPBanHandler) List(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+	filter := &repository.IPBanFilter{
+		Status: c.Query("status"),
+		Source: c.Query("source"),
+		CIDR:   c.Query("cidr"),
+	}
+
+	bans, total, err := h.ipGuard.List(filter, page, pageSize)
+	if err != nil {
+		response.InternalError(c, err.Error())
+		return
+	}
+	response.SuccessWithPagination(c, bans, total, page, pageSize)
+}
+
+// Create 手动封禁
+// POST /api/admin/ip-bans {cidr, reason, duration_minutes}
+func (h *IPBanHandler) Create(c *gin.Context) {
+	var req service.BanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "请求参数无效: "+err.Error())
+		return
+	}
+	ban, err := h.ipGuard.Ban(&req, c.GetString("username"))
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+	response.Success(c, ban)
+}
+
+// Revoke 解除封禁
+// DELETE /api/admin/ip-bans/:id
+func (h *IPBanHandler) Revoke(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "无效的ID")
+		return
+	}
+	if err := h.ipGuard.Unban(uint(id), c.GetString("username")); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+	response.Success(c, nil)
+}
+
+// Check 检查 IP 是否被封禁（不计入命中次数），并返回本次请求解析出的客户端 IP
+// GET /api/admin/ip-bans/check?ip=
+func (h *IPBanHandler) Check(c *gin.Context) {
+	ip := c.Query("ip")
+	if ip != "" && net.ParseIP(ip) == nil {
+		response.BadRequest(c, "无效的 IP")
+		return
+	}
+	result := gin.H{
+		"your_ip":      c.ClientIP(),
+		"remote_addr":  c.RemoteIP(),
+		"your_country": middleware.ClientCountry(c),
+	}
+	if ip != "" {
+		result["ip"] = ip
+		result["bans"] = h.ipGuard.ActiveBansFor(ip)
+	}
+	response.Success(c, result)
+}
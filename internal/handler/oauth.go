@@ -22,6 +22,7 @@ import (
 	"net"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -717,7 +718,7 @@ func dialWithProxy(network, addr string, proxyConfig *ProxyConfig) (net.Conn, er
 		return dialer.Dial(network, addr)
 	case "http", "https":
 		// HTTP 代理使用 CONNECT 方法
-		proxyAddr := fmt.Sprintf("%s:%d", proxyConfig.Host, proxyConfig.Port)
+		proxyAddr := net.JoinHostPort(proxyConfig.Host, strconv.Itoa(proxyConfig.Port))
 		conn, err := net.DialTimeout("tcp", proxyAddr, 30*time.Second)
 		if err != nil {
 			return nil, err
@@ -17,15 +17,18 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"io"
+	"math"
 	"net/http"
 	"regexp"
 	"strconv"
 	"strings"
 	"time"
 
+	"cli-proxy/internal/middleware"
 	"cli-proxy/internal/model"
 	"cli-proxy/internal/proxy/adapter"
 	"cli-proxy/internal/proxy/scheduler"
+	"cli-proxy/internal/ratelimit"
 	"cli-proxy/internal/repository"
 	"cli-proxy/internal/service"
 	"cli-proxy/pkg/logger"
@@ -116,6 +119,9 @@ func (h *OpenAIResponsesHandler) HandleResponses(c *gin.Context) {
 		return
 	}
 
+	// 请求遮蔽（API Key 开启时，转发上游前替换敏感信息）
+	rawBody = maskRequestBody(c, rawBody)
+
 	// 解析请求体获取基本信息
 	var reqBody map[string]interface{}
 	if err := json.Unmarshal(rawBody, &reqBody); err != nil {
@@ -152,6 +158,7 @@ func (h *OpenAIResponsesHandler) HandleResponses(c *gin.Context) {
 	if s, ok := reqBody["stream"].(bool); ok {
 		isStream = s
 	}
+	middleware.PublishRequestStart(c, originalModel, isStream)
 
 	// 检测是否为 Codex CLI 请求（参考 claude-relay）
 	userAgent := c.GetHeader("User-Agent")
@@ -206,6 +213,17 @@ func (h *OpenAIResponsesHandler) HandleResponses(c *gin.Context) {
 
 	log.Info("选中账户 - ID: %d, Name: %s, BaseURL: %s", account.ID, account.Name, account.BaseURL)
 
+	// 账户 Token 额度不足直接返回 429（该接口不经过调度重试，实际用量在 recordUsage 中扣减）
+	estimate, _ := middleware.GetTokenEstimate(c)
+	if ok, wait := ratelimit.Default().Check(ratelimit.AccountKey(account.ID), scheduler.AccountTokenLimits(account), estimate); !ok {
+		waitSeconds := int(math.Ceil(wait.Seconds()))
+		log.Warn("账户 Token 额度不足 | AccountID: %d | 需等待: %v", account.ID, wait)
+		c.Header("Retry-After", strconv.Itoa(waitSeconds))
+		c.Header("Retry-After-Ms", strconv.FormatInt(wait.Milliseconds(), 10))
+		response.CustomError(c, http.StatusTooManyRequests, model.ErrorTypeRateLimit, "上游账户 Token 额度不足，请稍后重试")
+		return
+	}
+
 	// 构建目标 URL: baseURL + path
 	// 参考 claude-relay: const targetUrl = `${fullAccount.baseApi}${req.path}`
 	baseURL := account.BaseURL
@@ -539,6 +557,7 @@ done:
 
 	// 记录使用统计（使用倍率后的 token）
 	if ratedInputTokens > 0 || ratedOutputTokens > 0 {
+		debitAccountTokens(account, inputTokens+cacheCreationTokens, outputTokens)
 		h.recordUsage(c, userID, apiKeyID, account.ID, actualModel, ratedInputTokens, ratedOutputTokens, ratedCacheReadTokens, ratedCacheCreationTokens)
 	}
 }
@@ -701,6 +720,7 @@ func (h *OpenAIResponsesHandler) handleNormalResponse(c *gin.Context, resp *http
 
 	// 记录使用统计（使用倍率后的 token）
 	if ratedInputTokens > 0 || ratedOutputTokens > 0 {
+		debitAccountTokens(account, inputTokens+cacheCreationTokens, outputTokens)
 		h.recordUsage(c, userID, apiKeyID, account.ID, actualModel, ratedInputTokens, ratedOutputTokens, ratedCacheReadTokens, ratedCacheCreationTokens)
 	}
 
@@ -758,11 +778,23 @@ func (h *OpenAIResponsesHandler) applyRateToSSEChunk(chunk []byte, rate float64)
 	return []byte(content)
 }
 
+// debitAccountTokens 按实际用量扣减账户 Token 额度
+func debitAccountTokens(account *model.Account, inputTokens, outputTokens int) {
+	ratelimit.Default().Adjust(ratelimit.AccountKey(account.ID), scheduler.AccountTokenLimits(account),
+		ratelimit.Usage{}, ratelimit.Usage{Input: inputTokens, Output: outputTokens})
+}
+
 // recordUsage 记录使用量到 Redis 和 MySQL
 func (h *OpenAIResponsesHandler) recordUsage(c *gin.Context, userID, apiKeyID, accountID uint, modelName string, inputTokens, outputTokens, cacheReadTokens, cacheCreationTokens int) {
 	log := logger.GetLogger("openai-responses")
 	log.Info("Usage - User: %d, APIKey: %d, Account: %d, Model: %s, Input: %d, Output: %d, CacheRead: %d, CacheCreation: %d",
 		userID, apiKeyID, accountID, modelName, inputTokens, outputTokens, cacheReadTokens, cacheCreationTokens)
+	middleware.SetRequestUsage(c, accountID, &adapter.StreamResult{
+		InputTokens:              inputTokens,
+		OutputTokens:             outputTokens,
+		CacheReadInputTokens:     cacheReadTokens,
+		CacheCreationInputTokens: cacheCreationTokens,
+	})
 
 	ctx := context.Background()
 
@@ -803,12 +835,13 @@ func (h *OpenAIResponsesHandler) recordUsage(c *gin.Context, userID, apiKeyID, a
 		c.Request.URL.Path,
 		c.ClientIP(),
 		c.GetHeader("User-Agent"),
-		"",
+		c.GetHeader("Session_id"),
 	)
 
 	// 设置用户信息
 	keyID := apiKeyID
 	requestLog.APIKeyID = &keyID
+	requestLog.ClientType = clientTypeFromContext(c)
 
 	// 使用 CompleteLogFull 完成日志记录（会自动调用 LogRequest 写入 MySQL）
 	CompleteLogFull(requestLog, true, 200, "",
@@ -25,6 +25,8 @@ import (
 	"strings"
 	"time"
 
+	"cli-proxy/internal/errormatch"
+	"cli-proxy/internal/middleware"
 	"cli-proxy/internal/model"
 	"cli-proxy/internal/proxy/adapter"
 	"cli-proxy/internal/proxy/scheduler"
@@ -167,9 +169,124 @@ func (h *ProxyHandler) getUserInfo(c *gin.Context) (userID, apiKeyID uint, clien
 // createRetryRequest 创建带用户信息的重试请求
 func (h *ProxyHandler) createRetryRequest(c *gin.Context) *scheduler.RetryableRequest {
 	userID, apiKeyID, clientIP, userAgent := h.getUserInfo(c)
-	return scheduler.NewRetryableRequest(h.scheduler, h.retryConfig).
+	retryReq := scheduler.NewRetryableRequest(h.scheduler, h.retryConfig).
 		WithSessionID(h.getSessionID(c)).
 		WithUserInfo(userID, apiKeyID, clientIP, userAgent)
+	c.Set(middleware.RetryRequestCtxKey, retryReq)
+	if estimate, ok := middleware.GetTokenEstimate(c); ok {
+		retryReq.WithTokenEstimate(estimate)
+	}
+
+	// API Key 开启对冲请求时，落败一路的部分用量单独记录
+	if key, ok := c.Get("api_key"); ok {
+		if k, ok := key.(*model.APIKey); ok {
+			if k.HedgeDelayMs > 0 {
+				retryReq.WithHedging(time.Duration(k.HedgeDelayMs)*time.Millisecond, h.hedgeLoserRecorder(c))
+			}
+			retryReq.WithQueue(k.QueuePriority, k.QueueWeight, sseKeepAlive(c))
+		}
+	}
+	return retryReq
+}
+
+// sseKeepAlive 流式请求排队期间发送 SSE 注释行，防止客户端或中间代理因长时间无数据断开
+// 只在流式执行时调用，此时 SSE 响应头已经写出
+func sseKeepAlive(c *gin.Context) func() {
+	return func() {
+		c.Writer.Write([]byte(": ping\n\n"))
+		c.Writer.Flush()
+	}
+}
+
+// executeStream 执行流式请求；API Key 开启中断续传时，中途失败会续传到其他账户并拼接到同一条流
+func (h *ProxyHandler) executeStream(
+	c *gin.Context,
+	retryReq *scheduler.RetryableRequest,
+	modelName string,
+	req *adapter.Request,
+	format string,
+	writer io.Writer,
+) (*scheduler.StreamExecuteResult, error) {
+	send := func(ctx context.Context, account *model.Account, sendReq *adapter.Request, w io.Writer) (*adapter.StreamResult, error) {
+		adp := adapter.Get(account.Type)
+		if adp == nil {
+			return nil, adapter.ErrNoAdapter
+		}
+		return adp.SendStream(ctx, account, sendReq, w)
+	}
+
+	if key, ok := c.Get("api_key"); ok {
+		if k, ok := key.(*model.APIKey); ok && k.StreamResume {
+			return retryReq.ExecuteStreamWithResume(c.Request.Context(), modelName, req, format, send, writer)
+		}
+	}
+
+	return retryReq.ExecuteStreamWithRetry(
+		c.Request.Context(),
+		modelName,
+		func(ctx context.Context, account *model.Account, w io.Writer) (*adapter.StreamResult, error) {
+			return send(ctx, account, req, w)
+		},
+		writer,
+	)
+}
+
+// maskRequestBody API Key 开启请求遮蔽时，转发上游前按脱敏规则替换请求内容中的敏感信息
+func maskRequestBody(c *gin.Context, rawBody []byte) []byte {
+	key, ok := c.Get("api_key")
+	if !ok {
+		return rawBody
+	}
+	if k, ok := key.(*model.APIKey); !ok || !k.MaskRequestBody {
+		return rawBody
+	}
+
+	masked, changed := service.GetRedactionService().MaskRequestBody(rawBody)
+	if !changed {
+		return rawBody
+	}
+	logger.GetLogger("proxy").Debug("请求内容已遮蔽 | 原长度: %d | 新长度: %d", len(rawBody), len(masked))
+	return masked
+}
+
+// optimizePromptCache API Key 开启 Prompt Caching 优化时，为 Claude 请求体自动插入 cache_control 断点
+func optimizePromptCache(c *gin.Context, req *adapter.Request) {
+	key, ok := c.Get("api_key")
+	if !ok {
+		return
+	}
+	if k, ok := key.(*model.APIKey); !ok || !k.PromptCacheOptimize {
+		return
+	}
+
+	body, added, err := adapter.OptimizePromptCache(req.RawBody)
+	if err != nil || added == 0 {
+		return
+	}
+	req.RawBody = body
+	logger.GetLogger("proxy").Debug("Prompt Caching 优化 | 新增断点: %d | Model: %s", added, req.Model)
+}
+
+// hedgeLoserRecorder 返回对冲落败回调：将被取消一路已消耗的用量单独记录为一条失败日志
+func (h *ProxyHandler) hedgeLoserRecorder(c *gin.Context) scheduler.HedgeLoserFunc {
+	return func(account *model.Account, modelName string, usage *adapter.StreamResult, err error) {
+		if usage == nil || usage.InputTokens+usage.OutputTokens+usage.CacheCreationInputTokens+usage.CacheReadInputTokens == 0 {
+			return
+		}
+		errMsg := "hedged request cancelled"
+		if err != nil && !errors.Is(err, context.Canceled) {
+			errMsg = err.Error()
+		}
+
+		var requestBody []byte
+		if rb, ok := c.Get("request_body"); ok {
+			requestBody = rb.([]byte)
+		}
+		h.recordUsage(c, modelName, usage, true, requestBody, nil, 0, account.ID, func(requestLog *model.RequestLog) {
+			requestLog.Success = false
+			requestLog.Error = "[hedge loser] " + errMsg
+		})
+	}
 }
 
 // checkModelEnabled 检查模型是否启用
@@ -213,9 +330,7 @@ func (h *ProxyHandler) handleOpenAINonStreamWithRetry(c *gin.Context, req *adapt
 	)
 
 	if err != nil {
-		// 根据错误类型返回自定义错误
-		errorType, statusCode := getProxyErrorTypeAndCode(err)
-		response.CustomError(c, statusCode, errorType, err.Error())
+		writeProxyError(c, err)
 		return
 	}
 
@@ -267,6 +382,10 @@ func (h *ProxyHandler) handleOpenAINonStreamWithRetry(c *gin.Context, req *adapt
 
 	// 记录使用统计（使用原始模型名）
 	h.recordNonStreamUsage(c, originalModel, resp, requestBody, responseBody, 200, result.AccountID)
+	storeCachedResponse(c, originalModel, false, "application/json; charset=utf-8", responseBody, &adapter.StreamResult{
+		InputTokens:  resp.InputTokens,
+		OutputTokens: resp.OutputTokens,
+	})
 
 	// 返回 OpenAI 格式（使用倍率后的 token）
 	c.JSON(http.StatusOK, gin.H{
@@ -313,8 +432,11 @@ func (h *ProxyHandler) handleOpenAIStreamWithRetry(c *gin.Context, req *adapter.
 		}
 	}
 
+	// 开启响应缓存时捕获完整输出用于回放
+	capture := newResponseCapture(c, writer)
+
 	// 使用 RateWriter 包装 writer，在写入时修改 token 值
-	rateWriter := NewRateWriter(writer, priceRate)
+	rateWriter := NewRateWriter(capture, priceRate)
 
 	// 使用 TailWriter 捕获末尾 2KB 响应（包装 RateWriter）
 	tailWriter := adapter.NewTailWriter(rateWriter, 2048)
@@ -326,28 +448,14 @@ func (h *ProxyHandler) handleOpenAIStreamWithRetry(c *gin.Context, req *adapter.
 		modelName = accountType + "," + req.Model
 	}
 
-	result, err := retryReq.ExecuteStreamWithRetry(
-		c.Request.Context(),
-		modelName,
-		func(ctx context.Context, account *model.Account, w io.Writer) (*adapter.StreamResult, error) {
-			adp := adapter.Get(account.Type)
-			if adp == nil {
-				return nil, adapter.ErrNoAdapter
-			}
-			return adp.SendStream(ctx, account, req, w)
-		},
-		tailWriter,
-	)
+	result, err := h.executeStream(c, retryReq, modelName, req, adapter.ResumeFormatOpenAI, tailWriter)
 
 	if err != nil {
-		errEvent := map[string]interface{}{
-			"error": map[string]string{
-				"message": err.Error(),
-				"type":    "api_error",
-			},
+		// 已输出部分内容时记录已产生的用量，API Key Token 预扣按实际用量修正而不是退回
+		if result != nil && result.Result != nil {
+			middleware.SetRequestUsage(c, result.AccountID, result.Result)
 		}
-		data, _ := json.Marshal(errEvent)
-		writer.Write([]byte("data: " + string(data) + "\n\n"))
+		writeStreamError(c, writer, err)
 		return
 	}
 
@@ -362,10 +470,14 @@ func (h *ProxyHandler) handleOpenAIStreamWithRetry(c *gin.Context, req *adapter.
 
 	// 记录使用统计（使用原始模型名）
 	if result != nil && result.Result != nil {
+		middleware.SetRequestUsage(c, result.AccountID, result.Result)
 		h.recordUsage(c, originalModel, result.Result, true, requestBody, responseTail, 200, result.AccountID)
 	}
 
-	writer.Write([]byte("data: [DONE]\n\n"))
+	capture.Write([]byte("data: [DONE]\n\n"))
+	if result != nil {
+		storeCachedResponse(c, originalModel, true, "text/event-stream", capture.Bytes(), result.Result)
+	}
 }
 
 // Claude 非流式响应（带重试）
@@ -391,29 +503,13 @@ func (h *ProxyHandler) handleClaudeNonStreamWithRetry(c *gin.Context, req *adapt
 	)
 
 	if err != nil {
-		// 使用自定义错误消息
-		errorType, statusCode := getProxyErrorTypeAndCode(err)
-		customMsg, _ := getCustomErrorMessage(errorType, err.Error())
-		c.JSON(statusCode, gin.H{
-			"type": "error",
-			"error": gin.H{
-				"type":    "api_error",
-				"message": customMsg,
-			},
-		})
+		writeProxyError(c, err)
 		return
 	}
 
 	resp := result.Response
 	if resp.Error != nil {
-		customMsg, _ := getCustomErrorMessage(model.ErrorTypeBadRequest, resp.Error.Message)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"type": "error",
-			"error": gin.H{
-				"type":    resp.Error.Type,
-				"message": customMsg,
-			},
-		})
+		response.CustomError(c, http.StatusBadRequest, model.ErrorTypeBadRequest, resp.Error.Message)
 		return
 	}
 
@@ -451,6 +547,10 @@ func (h *ProxyHandler) handleClaudeNonStreamWithRetry(c *gin.Context, req *adapt
 
 	// 记录使用统计（使用原始模型名）
 	h.recordNonStreamUsage(c, originalModel, resp, requestBody, responseBody, 200, result.AccountID)
+	storeCachedResponse(c, originalModel, false, "application/json; charset=utf-8", responseBody, &adapter.StreamResult{
+		InputTokens:  resp.InputTokens,
+		OutputTokens: resp.OutputTokens,
+	})
 
 	// 更新账号用量状态（从响应头获取）
 	h.updateAccountUsageStatus(result.AccountID, resp.Headers)
@@ -495,8 +595,11 @@ func (h *ProxyHandler) handleClaudeStreamWithRetry(c *gin.Context, req *adapter.
 	log := logger.GetLogger("proxy")
 	log.Debug("Claude Stream 倍率 | Rate: %.2f | Model: %s", priceRate, req.Model)
 
+	// 开启响应缓存时捕获完整输出用于回放
+	capture := newResponseCapture(c, writer)
+
 	// 使用 RateWriter 包装 writer，在写入时修改 token 值
-	rateWriter := NewRateWriter(writer, priceRate)
+	rateWriter := NewRateWriter(capture, priceRate)
 
 	// 使用 TailWriter 捕获末尾 2KB 响应（包装 RateWriter）
 	tailWriter := adapter.NewTailWriter(rateWriter, 2048)
@@ -508,29 +611,14 @@ func (h *ProxyHandler) handleClaudeStreamWithRetry(c *gin.Context, req *adapter.
 		modelName = accountType + "," + req.Model
 	}
 
-	result, err := retryReq.ExecuteStreamWithRetry(
-		c.Request.Context(),
-		modelName,
-		func(ctx context.Context, account *model.Account, w io.Writer) (*adapter.StreamResult, error) {
-			adp := adapter.Get(account.Type)
-			if adp == nil {
-				return nil, adapter.ErrNoAdapter
-			}
-			return adp.SendStream(ctx, account, req, w)
-		},
-		tailWriter,
-	)
+	result, err := h.executeStream(c, retryReq, modelName, req, adapter.ResumeFormatClaude, tailWriter)
 
 	if err != nil {
-		writer.Write([]byte("event: error\n"))
-		errData, _ := json.Marshal(gin.H{
-			"type": "error",
-			"error": gin.H{
-				"type":    "api_error",
-				"message": err.Error(),
-			},
-		})
-		writer.Write([]byte("data: " + string(errData) + "\n\n"))
+		// 已输出部分内容时记录已产生的用量，API Key Token 预扣按实际用量修正而不是退回
+		if result != nil && result.Result != nil {
+			middleware.SetRequestUsage(c, result.AccountID, result.Result)
+		}
+		writeStreamError(c, writer, err)
 		return
 	}
 
@@ -545,9 +633,11 @@ func (h *ProxyHandler) handleClaudeStreamWithRetry(c *gin.Context, req *adapter.
 
 	// 记录使用统计（使用原始模型名）
 	if result != nil && result.Result != nil {
+		middleware.SetRequestUsage(c, result.AccountID, result.Result)
 		h.recordUsage(c, originalModel, result.Result, true, requestBody, responseTail, 200, result.AccountID)
 		// 更新账号用量状态（从响应头获取）
 		h.updateAccountUsageStatus(result.AccountID, result.Result.Headers)
+		storeCachedResponse(c, originalModel, true, "text/event-stream", capture.Bytes(), result.Result)
 	}
 }
 
@@ -579,6 +669,9 @@ func (h *ProxyHandler) ClaudeMessages(c *gin.Context) {
 		return
 	}
 
+	// 请求遮蔽（API Key 开启时，转发上游前替换敏感信息）
+	rawBody = maskRequestBody(c, rawBody)
+
 	log := logger.GetLogger("proxy")
 	log.Debug("ClaudeMessages 原始请求体 | 长度: %d | 前500字符: %s", len(rawBody), truncateForLog(string(rawBody), 500))
 
@@ -612,6 +705,7 @@ func (h *ProxyHandler) ClaudeMessages(c *gin.Context) {
 	// 4. 强制使用 Claude 平台（不自动检测）
 	accountType := "claude"
 	actualModel := scheduler.GetActualModel(basic.Model) // 去掉可能的 "type," 前缀
+	middleware.PublishRequestStart(c, actualModel, basic.Stream)
 
 	// 4.1 API Key 平台/模型权限检查
 	if !enforceAPIKeyAccess(c, model.PlatformClaude, actualModel) {
@@ -631,6 +725,14 @@ func (h *ProxyHandler) ClaudeMessages(c *gin.Context) {
 		Headers: clientHeaders,
 	}
 
+	// 7. 响应缓存（客户端通过请求头开启）
+	if h.serveCachedResponse(c, req, actualModel) {
+		return
+	}
+
+	// 8. Prompt Caching 优化（API Key 开启时自动插入 cache_control 断点）
+	optimizePromptCache(c, req)
+
 	if req.Stream {
 		h.handleClaudeStreamWithRetry(c, req, accountType, actualModel)
 	} else {
@@ -652,6 +754,9 @@ func (h *ProxyHandler) OpenAIChatCompletions(c *gin.Context) {
 		return
 	}
 
+	// 请求遮蔽（API Key 开启时，转发上游前替换敏感信息）
+	rawBody = maskRequestBody(c, rawBody)
+
 	var req adapter.Request
 	if err := json.Unmarshal(rawBody, &req); err != nil {
 		response.CustomBadRequest(c, err.Error())
@@ -667,6 +772,7 @@ func (h *ProxyHandler) OpenAIChatCompletions(c *gin.Context) {
 	// 强制使用 OpenAI 平台（不自动检测）
 	accountType := "openai"
 	actualModel := scheduler.GetActualModel(req.Model) // 去掉可能的 "type," 前缀
+	middleware.PublishRequestStart(c, actualModel, req.Stream)
 
 	// API Key 平台/模型权限检查
 	if !enforceAPIKeyAccess(c, model.PlatformOpenAI, actualModel) {
@@ -681,6 +787,11 @@ func (h *ProxyHandler) OpenAIChatCompletions(c *gin.Context) {
 		return
 	}
 
+	// 响应缓存（客户端通过请求头开启）
+	if h.serveCachedResponse(c, &req, actualModel) {
+		return
+	}
+
 	if req.Stream {
 		h.handleOpenAIStreamWithRetry(c, &req, accountType, actualModel)
 	} else {
@@ -727,6 +838,9 @@ func (h *ProxyHandler) GeminiChat(c *gin.Context) {
 		return
 	}
 
+	// 请求遮蔽（API Key 开启时，转发上游前替换敏感信息）
+	rawBody = maskRequestBody(c, rawBody)
+
 	var req adapter.Request
 	if err := json.Unmarshal(rawBody, &req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -749,6 +863,7 @@ func (h *ProxyHandler) GeminiChat(c *gin.Context) {
 
 	// 保存原始模型名（不再做全局模型映射，只在账号级别映射）
 	originalModel := req.Model
+	middleware.PublishRequestStart(c, originalModel, req.Stream)
 
 	// API Key 平台/模型权限检查
 	if !enforceAPIKeyAccess(c, model.PlatformGemini, originalModel) {
@@ -783,25 +898,13 @@ func (h *ProxyHandler) handleGeminiNonStream(c *gin.Context, req *adapter.Reques
 	)
 
 	if err != nil {
-		c.JSON(http.StatusBadGateway, gin.H{
-			"error": gin.H{
-				"code":    502,
-				"message": err.Error(),
-				"status":  "UNAVAILABLE",
-			},
-		})
+		writeProxyError(c, err)
 		return
 	}
 
 	resp := result.Response
 	if resp.Error != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": gin.H{
-				"code":    400,
-				"message": resp.Error.Message,
-				"status":  resp.Error.Type,
-			},
-		})
+		response.CustomError(c, http.StatusBadRequest, model.ErrorTypeBadRequest, resp.Error.Message)
 		return
 	}
 
@@ -905,14 +1008,11 @@ func (h *ProxyHandler) handleGeminiStream(c *gin.Context, req *adapter.Request,
 	)
 
 	if err != nil {
-		errData, _ := json.Marshal(gin.H{
-			"error": gin.H{
-				"code":    502,
-				"message": err.Error(),
-				"status":  "UNAVAILABLE",
-			},
-		})
-		writer.Write([]byte("data: " + string(errData) + "\n\n"))
+		// 已输出部分内容时记录已产生的用量，API Key Token 预扣按实际用量修正而不是退回
+		if result != nil && result.Result != nil {
+			middleware.SetRequestUsage(c, result.AccountID, result.Result)
+		}
+		writeStreamError(c, writer, err)
 		return
 	}
 
@@ -927,6 +1027,7 @@ func (h *ProxyHandler) handleGeminiStream(c *gin.Context, req *adapter.Request,
 
 	// 记录使用统计（使用原始模型名）
 	if result != nil && result.Result != nil {
+		middleware.SetRequestUsage(c, result.AccountID, result.Result)
 		h.recordUsage(c, originalModel, result.Result, true, requestBody, responseTail, 200, result.AccountID)
 	}
 }
@@ -1040,12 +1141,12 @@ func (h *ProxyHandler) fetchClaudeOAuthUsage(account *model.Account) (*repositor
 
 	// 获取 HTTP 客户端（优先使用账户代理，否则使用默认代理）
 	var client *http.Client
-	if account.Proxy != nil && account.Proxy.Enabled {
-		// 账户有配置代理，使用账户代理
+	if accountProxy := adapter.ResolveAccountProxy(account); accountProxy != nil {
+		// 账户有配置代理或代理池，使用账户代理
 		client = adapter.GetHTTPClient(account)
 		log.DebugZ("OAuth Usage API 使用账户代理",
 			logger.Uint("account_id", account.ID),
-			logger.String("proxy_name", account.Proxy.Name),
+			logger.String("proxy_name", accountProxy.Name),
 		)
 	} else {
 		// 账户没有代理，尝试使用默认代理
@@ -1109,7 +1210,8 @@ func (h *ProxyHandler) fetchClaudeOAuthUsage(account *model.Account) (*repositor
 }
 
 // recordUsage 记录使用统计（异步执行）
-func (h *ProxyHandler) recordUsage(c *gin.Context, modelName string, usage *adapter.StreamResult, isStream bool, requestBody []byte, responseBody []byte, upstreamStatusCode int, accountID uint) {
+// decorators 用于在保存前调整请求日志（如标记对冲落败）
+func (h *ProxyHandler) recordUsage(c *gin.Context, modelName string, usage *adapter.StreamResult, isStream bool, requestBody []byte, responseBody []byte, upstreamStatusCode int, accountID uint, decorators ...func(*model.RequestLog)) {
 	log := logger.GetLogger("proxy")
 
 	// 从 context 获取 API Key 信息
@@ -1132,6 +1234,21 @@ func (h *ProxyHandler) recordUsage(c *gin.Context, modelName string, usage *adap
 		return
 	}
 
+	// API Key 开启"不记录内容"时仅保留元数据
+	if apiKey, ok := c.Get("api_key"); ok {
+		if key, ok := apiKey.(*model.APIKey); ok && key.NoLogBodies {
+			requestBody, responseBody = nil, nil
+		}
+	}
+
+	// 响应缓存状态（由 serveCachedResponse 设置）
+	cacheStatus := c.GetString("response_cache_status")
+	cacheKey := c.GetString("response_cache_key")
+
+	// 会话与客户端类型（用于日志检索和同会话请求关联）
+	sessionID := h.getSessionID(c)
+	clientType := clientTypeFromContext(c)
+
 	// 应用倍率到 token（用于日志记录和费用计算）
 	ratedInputTokens := int(float64(usage.InputTokens) * priceRate)
 	ratedOutputTokens := int(float64(usage.OutputTokens) * priceRate)
@@ -1180,6 +1297,8 @@ func (h *ProxyHandler) recordUsage(c *gin.Context, modelName string, usage *adap
 			Path:                     c.Request.URL.Path,
 			RequestIP:                c.ClientIP(),
 			UserAgent:                c.GetHeader("User-Agent"),
+			SessionID:                sessionID,
+			ClientType:               clientType,
 			InputTokens:              ratedInputTokens,
 			OutputTokens:             ratedOutputTokens,
 			CacheCreationInputTokens: ratedCacheCreationTokens,
@@ -1193,6 +1312,8 @@ func (h *ProxyHandler) recordUsage(c *gin.Context, modelName string, usage *adap
 			Success:                  true,
 			StatusCode:               200,
 			UpstreamStatusCode:       upstreamStatusCode,
+			CacheStatus:              cacheStatus,
+			CacheKey:                 cacheKey,
 			CreatedAt:                time.Now(),
 		}
 
@@ -1213,6 +1334,10 @@ func (h *ProxyHandler) recordUsage(c *gin.Context, modelName string, usage *adap
 			}
 		}
 
+		for _, decorate := range decorators {
+			decorate(requestLog)
+		}
+
 		// 直接保存请求日志到数据库
 		LogRequest(requestLog)
 
@@ -1275,6 +1400,7 @@ func (h *ProxyHandler) recordNonStreamUsage(c *gin.Context, modelName string, re
 		InputTokens:  resp.InputTokens,
 		OutputTokens: resp.OutputTokens,
 	}
+	middleware.SetRequestUsage(c, accountID, usage)
 	h.recordUsage(c, modelName, usage, false, requestBody, responseBody, upstreamStatusCode, accountID)
 }
 
@@ -1300,6 +1426,11 @@ func getProxyErrorTypeAndCode(err error) (string, int) {
 		}
 	}
 
+	// 账户并发全满（未排队、排队已满或排队超时）
+	if errors.Is(err, scheduler.ErrAccountConcurrencyFull) || errors.Is(err, scheduler.ErrQueueFull) || errors.Is(err, scheduler.ErrQueueTimeout) {
+		return model.ErrorTypeAccountConcurrency, http.StatusTooManyRequests
+	}
+
 	errMsg := err.Error()
 	errMsgLower := strings.ToLower(errMsg)
 
@@ -1346,11 +1477,24 @@ func getProxyErrorTypeAndCode(err error) (string, int) {
 	}
 }
 
-// getCustomErrorMessage 获取自定义错误消息
-func getCustomErrorMessage(errorType, originalError string) (string, bool) {
-	errorMsgService := service.GetErrorMessageService()
-	customMessage, shouldLog := errorMsgService.GetCustomMessage(errorType, originalError)
-	return customMessage, shouldLog
+// writeProxyError 按调用方协议返回代理错误（错误规则指定的客户端提示优先，否则使用本地化的自定义消息）
+func writeProxyError(c *gin.Context, err error) {
+	errorType, statusCode := getProxyErrorTypeAndCode(err)
+	if msg, ok := errormatch.ClientMessage(err); ok {
+		response.ProtocolError(c, statusCode, errorType, msg, err.Error())
+		return
+	}
+	response.CustomError(c, statusCode, errorType, err.Error())
+}
+
+// writeStreamError 流式响应头已发送后按调用方协议输出 SSE 错误事件，消息选择规则同 writeProxyError
+func writeStreamError(c *gin.Context, w io.Writer, err error) {
+	errorType, statusCode := getProxyErrorTypeAndCode(err)
+	if msg, ok := errormatch.ClientMessage(err); ok {
+		response.ProtocolStreamError(c, w, statusCode, errorType, msg, err.Error())
+		return
+	}
+	response.CustomStreamError(c, w, statusCode, errorType, err.Error())
 }
 
 // truncateForLog 截断字符串用于日志
@@ -6,25 +6,20 @@
  *   - 代理启用/禁用
  *   - 默认代理设置
  *   - 代理连通性测试
+ *   - 代理出口 IP 探测
  * 重要程度：⭐⭐⭐ 一般（代理配置管理）
  * 依赖模块：service, model
  */
 package handler
 
 import (
-	"context"
-	"fmt"
-	"net"
 	"net/http"
-	"net/url"
 	"strconv"
-	"time"
 
 	"cli-proxy/internal/model"
 	"cli-proxy/internal/service"
 
 	"github.com/gin-gonic/gin"
-	"golang.org/x/net/proxy"
 )
 
 // ListProxyConfigs 获取代理配置列表
@@ -114,6 +109,15 @@ func UpdateProxyConfig(c *gin.Context) {
 
 	proxy.ID = uint(id)
 	proxy.CreatedAt = existing.CreatedAt
+	if proxy.PoolID == nil {
+		// 未指定时保留所属代理池（代理池成员通过代理池接口管理）
+		proxy.PoolID = existing.PoolID
+	}
+	// 出口 IP 由探测维护：代理地址未变时保留，变更后清空等待重新探测
+	proxy.EgressIP, proxy.EgressCountry, proxy.EgressCheckedAt = "", "", nil
+	if proxy.Type == existing.Type && proxy.Host == existing.Host && proxy.Port == existing.Port && proxy.Username == existing.Username {
+		proxy.EgressIP, proxy.EgressCountry, proxy.EgressCheckedAt = existing.EgressIP, existing.EgressCountry, existing.EgressCheckedAt
+	}
 
 	if err := service.GetProxyService().Update(&proxy); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -245,128 +249,68 @@ func TestProxyConnectivity(c *gin.Context) {
 		req.Type = "http"
 	}
 
-	start := time.Now()
-
-	// 测试目标 URL（使用 Google 或 Cloudflare 来测试代理，更稳定）
-	testURLs := []string{
-		"https://www.google.com/generate_204",
-		"https://cp.cloudflare.com/",
-		"https://www.gstatic.com/generate_204",
-	}
-
-	var err error
-	var resp *http.Response
-	var lastErr error
-
-	for _, testURL := range testURLs {
-		switch req.Type {
-		case "socks5":
-			resp, err = testSocks5Proxy(req.Host, req.Port, req.Username, req.Password, testURL)
-		case "http", "https":
-			resp, err = testHTTPProxy(req.Type, req.Host, req.Port, req.Username, req.Password, testURL)
-		default:
-			c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的代理类型: " + req.Type})
-			return
-		}
-
-		if err == nil && resp != nil {
-			// 204 No Content 或 200 OK 都表示成功
-			if resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusOK {
-				resp.Body.Close()
-				latency := int(time.Since(start).Milliseconds())
-
-				// 如果有 ID，保存测试结果到数据库
-				if req.ID > 0 {
-					service.GetProxyService().UpdateTestStatus(req.ID, "success", latency, "")
-				}
-
-				c.JSON(http.StatusOK, gin.H{
-					"success": true,
-					"message": "连接成功",
-					"latency": latency,
-				})
-				return
-			}
-			resp.Body.Close()
-		}
-		if err != nil {
-			lastErr = err
-		}
+	switch req.Type {
+	case model.ProxyTypeSOCKS5, model.ProxyTypeHTTP, model.ProxyTypeHTTPS:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的代理类型: " + req.Type})
+		return
 	}
 
-	latency := int(time.Since(start).Milliseconds())
-
-	if lastErr != nil {
+	latency, err := service.GetProxyService().Probe(&model.Proxy{
+		Type:     req.Type,
+		Host:     req.Host,
+		Port:     req.Port,
+		Username: req.Username,
+		Password: req.Password,
+	})
+	if err != nil {
 		// 如果有 ID，保存测试失败结果到数据库
 		if req.ID > 0 {
-			service.GetProxyService().UpdateTestStatus(req.ID, "failed", latency, lastErr.Error())
+			service.GetProxyService().UpdateTestStatus(req.ID, model.ProxyTestStatusFailed, latency, err.Error())
 		}
 
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
-			"error":   lastErr.Error(),
+			"error":   err.Error(),
 			"latency": latency,
 		})
 		return
 	}
 
-	// 如果有 ID，保存测试失败结果到数据库
+	// 如果有 ID，保存测试结果到数据库
 	if req.ID > 0 {
-		service.GetProxyService().UpdateTestStatus(req.ID, "failed", latency, "无法连接到测试目标")
+		service.GetProxyService().UpdateTestStatus(req.ID, model.ProxyTestStatusSuccess, latency, "")
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"success": false,
-		"error":   "无法连接到测试目标",
+		"success": true,
+		"message": "连接成功",
 		"latency": latency,
 	})
 }
 
-// testHTTPProxy 测试 HTTP/HTTPS 代理
-func testHTTPProxy(proxyType, host string, port int, username, password, testURL string) (*http.Response, error) {
-	proxyURL := fmt.Sprintf("%s://%s:%d", proxyType, host, port)
-	if username != "" && password != "" {
-		proxyURL = fmt.Sprintf("%s://%s:%s@%s:%d", proxyType, username, password, host, port)
-	}
-
-	proxyURLParsed, err := url.Parse(proxyURL)
+// DiscoverProxyEgress 立即经代理探测出口 IP 与国家/地区
+func DiscoverProxyEgress(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		return nil, fmt.Errorf("解析代理 URL 失败: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的 ID"})
+		return
 	}
 
-	client := &http.Client{
-		Transport: &http.Transport{
-			Proxy: http.ProxyURL(proxyURLParsed),
-		},
-		Timeout: 10 * time.Second,
+	proxy, err := service.GetProxyService().GetByID(uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
-
-	return client.Get(testURL)
-}
-
-// testSocks5Proxy 测试 SOCKS5 代理
-func testSocks5Proxy(host string, port int, username, password, testURL string) (*http.Response, error) {
-	var auth *proxy.Auth
-	if username != "" && password != "" {
-		auth = &proxy.Auth{
-			User:     username,
-			Password: password,
-		}
+	if proxy == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "代理不存在"})
+		return
 	}
 
-	dialer, err := proxy.SOCKS5("tcp", fmt.Sprintf("%s:%d", host, port), auth, proxy.Direct)
+	info, err := service.GetAccountEgressService().DiscoverProxyEgress(proxy)
 	if err != nil {
-		return nil, fmt.Errorf("创建 SOCKS5 代理失败: %v", err)
-	}
-
-	client := &http.Client{
-		Transport: &http.Transport{
-			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
-				return dialer.Dial(network, addr)
-			},
-		},
-		Timeout: 10 * time.Second,
+		c.JSON(http.StatusBadGateway, gin.H{"error": "探测出口 IP 失败: " + err.Error()})
+		return
 	}
-
-	return client.Get(testURL)
+	c.JSON(http.StatusOK, gin.H{"data": info})
 }
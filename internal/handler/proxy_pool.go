@@ -0,0 +1,178 @@
+/*
+ * 文件作用：代理池处理器，管理代理池及池内代理
+ * 负责功能：
+ *   - 代理池列表、详情、创建、更新、删除
+ *   - 代理池运行状态查询（可用代理、账户分配、连续失败）
+ *   - 立即检测池内代理连通性
+ * 重要程度：⭐⭐⭐ 一般（代理池管理）
+ * 依赖模块：service, model
+ */
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"cli-proxy/internal/model"
+	"cli-proxy/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ProxyPoolRequest 创建/更新代理池请求
+type ProxyPoolRequest struct {
+	Name             string `json:"name" binding:"required"`
+	Enabled          *bool  `json:"enabled"`
+	AllowCrossRegion *bool  `json:"allow_cross_region"` // 池内没有账户出口地区的代理时允许分配其他地区的代理
+	Remark           string `json:"remark"`
+	ProxyIDs         []uint `json:"proxy_ids"` // 池内代理 ID（更新时为空表示不修改成员，传 [] 清空）
+}
+
+// ListProxyPools 获取代理池列表（含池内代理）
+func ListProxyPools(c *gin.Context) {
+	pools, err := service.GetProxyPoolService().List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"items": pools, "total": len(pools)})
+}
+
+// GetProxyPool 获取单个代理池
+func GetProxyPool(c *gin.Context) {
+	pool, ok := loadProxyPool(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, pool)
+}
+
+// CreateProxyPool 创建代理池
+func CreateProxyPool(c *gin.Context) {
+	var req ProxyPoolRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	pool := &model.ProxyPool{Name: req.Name, Enabled: true, Remark: req.Remark}
+	if req.Enabled != nil {
+		pool.Enabled = *req.Enabled
+	}
+	if req.AllowCrossRegion != nil {
+		pool.AllowCrossRegion = *req.AllowCrossRegion
+	}
+	if err := service.GetProxyPoolService().Create(pool, req.ProxyIDs); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	created, err := service.GetProxyPoolService().GetByID(pool.ID)
+	if err != nil || created == nil {
+		c.JSON(http.StatusOK, pool)
+		return
+	}
+	c.JSON(http.StatusOK, created)
+}
+
+// UpdateProxyPool 更新代理池
+func UpdateProxyPool(c *gin.Context) {
+	pool, ok := loadProxyPool(c)
+	if !ok {
+		return
+	}
+
+	var req ProxyPoolRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	pool.Name = req.Name
+	pool.Remark = req.Remark
+	if req.Enabled != nil {
+		pool.Enabled = *req.Enabled
+	}
+	if req.AllowCrossRegion != nil {
+		pool.AllowCrossRegion = *req.AllowCrossRegion
+	}
+	if err := service.GetProxyPoolService().Update(pool, req.ProxyIDs); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	updated, err := service.GetProxyPoolService().GetByID(pool.ID)
+	if err != nil || updated == nil {
+		c.JSON(http.StatusOK, pool)
+		return
+	}
+	c.JSON(http.StatusOK, updated)
+}
+
+// DeleteProxyPool 删除代理池（池内代理保留，仅移出代理池）
+func DeleteProxyPool(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的 ID"})
+		return
+	}
+
+	if err := service.GetProxyPoolService().Delete(uint(id)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+// GetProxyPoolStatus 获取代理池运行状态
+func GetProxyPoolStatus(c *gin.Context) {
+	pool, ok := loadProxyPool(c)
+	if !ok {
+		return
+	}
+
+	status := service.GetProxyPoolService().Status(pool.ID)
+	if status == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "代理池尚未加载，请稍后重试"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": status})
+}
+
+// CheckProxyPool 立即检测池内代理连通性
+func CheckProxyPool(c *gin.Context) {
+	pool, ok := loadProxyPool(c)
+	if !ok {
+		return
+	}
+
+	status, err := service.GetProxyHealthCheckService().CheckPool(pool.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": status})
+}
+
+// loadProxyPool 解析路径 ID 并加载代理池，失败时已写入响应
+func loadProxyPool(c *gin.Context) (*model.ProxyPool, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的 ID"})
+		return nil, false
+	}
+
+	pool, err := service.GetProxyPoolService().GetByID(uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if pool == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "代理池不存在"})
+		return nil, false
+	}
+	return pool, true
+}
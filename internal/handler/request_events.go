@@ -0,0 +1,152 @@
+/*
+ * 文件作用：代理请求实时事件流处理器
+ * 负责功能：
+ *   - SSE 推送请求开始/结束事件（支持服务端过滤）
+ *   - 新连接回看最近几分钟的事件，断线重连按 Last-Event-ID 续传
+ *   - 最近事件快照查询
+ * 重要程度：⭐⭐⭐ 一般（运维观测）
+ * 依赖模块：events
+ */
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"cli-proxy/internal/events"
+	"cli-proxy/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// 默认回看时长
+	defaultEventBacklog = 5 * time.Minute
+	// 最大回看时长
+	maxEventBacklog = 30 * time.Minute
+	// SSE 心跳间隔（防止代理/负载均衡断开空闲连接）
+	eventHeartbeatInterval = 15 * time.Second
+)
+
+// RequestEventHandler 请求事件流处理器
+type RequestEventHandler struct {
+	hub *events.Hub
+}
+
+// NewRequestEventHandler 创建请求事件流处理器
+func NewRequestEventHandler() *RequestEventHandler {
+	return &RequestEventHandler{hub: events.GetHub()}
+}
+
+// parseEventFilter 解析过滤参数和回看起点
+func parseEventFilter(c *gin.Context) (events.Filter, time.Time) {
+	f := events.Filter{
+		Type:       c.Query("type"),
+		Model:      c.Query("model"),
+		ErrorsOnly: c.Query("errors_only") == "true",
+	}
+	if id, _ := strconv.ParseUint(c.Query("api_key_id"), 10, 32); id > 0 {
+		f.APIKeyID = uint(id)
+	}
+	if id, _ := strconv.ParseUint(c.Query("account_id"), 10, 32); id > 0 {
+		f.AccountID = uint(id)
+	}
+	f.MinLatency, _ = strconv.ParseInt(c.Query("min_latency"), 10, 64)
+
+	backlog := defaultEventBacklog
+	if s := c.Query("backlog"); s != "" {
+		if seconds, err := strconv.Atoi(s); err == nil && seconds >= 0 {
+			backlog = time.Duration(seconds) * time.Second
+		}
+	}
+	if backlog > maxEventBacklog {
+		backlog = maxEventBacklog
+	}
+	return f, time.Now().Add(-backlog)
+}
+
+// Stream SSE 推送请求事件
+// GET /api/admin/events/requests?type=&api_key_id=&account_id=&model=&errors_only=&min_latency=&backlog=秒
+func (h *RequestEventHandler) Stream(c *gin.Context) {
+	filter, since := parseEventFilter(c)
+
+	// 断线重连：只补发 Last-Event-ID 之后的事件
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+	afterSeq, _ := strconv.ParseUint(lastEventID, 10, 64)
+	if afterSeq > 0 {
+		since = time.Time{}
+	}
+
+	sub, backlog := h.hub.Subscribe(filter, since, afterSeq)
+	defer h.hub.Unsubscribe(sub)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	w := c.Writer
+	writeEvent := func(e *events.RequestEvent) error {
+		data, _ := json.Marshal(e)
+		_, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.Seq, e.Type, data)
+		return err
+	}
+
+	for i := range backlog {
+		if writeEvent(&backlog[i]) != nil {
+			return
+		}
+	}
+	w.Flush()
+
+	heartbeat := time.NewTicker(eventHeartbeatInterval)
+	defer heartbeat.Stop()
+	ctx := c.Request.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			w.Flush()
+		case e, ok := <-sub.C:
+			if !ok {
+				// 消费过慢被断开，客户端可按 Last-Event-ID 重连
+				fmt.Fprint(w, "event: disconnected\ndata: {\"reason\":\"slow consumer\"}\n\n")
+				w.Flush()
+				return
+			}
+			if dropped := sub.Dropped(); dropped > 0 {
+				fmt.Fprintf(w, "event: lagged\ndata: {\"dropped\":%d}\n\n", dropped)
+			}
+			if writeEvent(&e) != nil {
+				return
+			}
+			// 批量写出缓冲区中已有的事件后再刷新
+			for n := len(sub.C); n > 0; n-- {
+				e, ok = <-sub.C
+				if !ok || writeEvent(&e) != nil {
+					break
+				}
+			}
+			w.Flush()
+		}
+	}
+}
+
+// Recent 最近事件快照
+// GET /api/admin/events/requests/recent（参数同 Stream）
+func (h *RequestEventHandler) Recent(c *gin.Context) {
+	filter, since := parseEventFilter(c)
+	response.Success(c, gin.H{
+		"events":      h.hub.Recent(filter, since),
+		"subscribers": h.hub.SubscriberCount(),
+	})
+}
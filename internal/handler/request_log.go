@@ -1,24 +1,30 @@
 /*
  * 文件作用：请求日志处理器，提供API请求日志的查询和统计
  * 负责功能：
- *   - 请求日志列表查询（分页、筛选）
+ *   - 请求日志列表查询（分页/游标分页、结构化筛选、全文检索）
+ *   - 同会话请求关联查询
  *   - 请求汇总统计
  *   - 账户负载统计
  *   - 按时间范围查询
+ *   - 日志详情（含请求/响应体）
+ *   - 保留策略任务状态、手动触发与归档文件下载
  * 重要程度：⭐⭐⭐ 一般（日志查询功能）
- * 依赖模块：repository
+ * 依赖模块：repository, service
  */
 package handler
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
 
 	"cli-proxy/internal/repository"
+	"cli-proxy/internal/service"
 	"cli-proxy/pkg/response"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type RequestLogHandler struct {
@@ -32,42 +38,86 @@ func NewRequestLogHandler() *RequestLogHandler {
 }
 
 // List 获取请求日志列表
+// GET /api/admin/logs?q=&session_id=&client_type=&status_code=&upstream_status=&min_duration=&max_tokens=&min_cost=...
+// 传 before_id 时为游标分页（不统计总数），响应中的 next_before_id 用于获取下一页
 func (h *RequestLogHandler) List(c *gin.Context) {
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 200 {
+		pageSize = 20
+	}
+
+	filter := parseRequestLogFilter(c)
+	logs, total, err := h.repo.List(filter, page, pageSize)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, err.Error())
+		return
+	}
 
-	filters := make(map[string]interface{})
+	if filter.BeforeID > 0 {
+		var next uint
+		if len(logs) == pageSize {
+			next = logs[len(logs)-1].ID
+		}
+		response.Success(c, gin.H{
+			"items":          logs,
+			"page_size":      pageSize,
+			"next_before_id": next,
+		})
+		return
+	}
+	response.SuccessWithPagination(c, logs, total, page, pageSize)
+}
 
+// parseRequestLogFilter 解析日志筛选参数（无效参数忽略）
+func parseRequestLogFilter(c *gin.Context) *repository.RequestLogFilter {
+	f := &repository.RequestLogFilter{
+		Platform:    c.Query("platform"),
+		Model:       c.Query("model"),
+		SessionID:   c.Query("session_id"),
+		ClientType:  c.Query("client_type"),
+		RequestIP:   c.Query("request_ip"),
+		CacheStatus: c.Query("cache_status"),
+		Query:       c.Query("q"),
+	}
 	if accountID, _ := strconv.ParseUint(c.Query("account_id"), 10, 32); accountID > 0 {
-		filters["account_id"] = uint(accountID)
+		f.AccountID = uint(accountID)
+	}
+	if apiKeyID, _ := strconv.ParseUint(c.Query("api_key_id"), 10, 32); apiKeyID > 0 {
+		f.APIKeyID = uint(apiKeyID)
 	}
-	if platform := c.Query("platform"); platform != "" {
-		filters["platform"] = platform
+	if beforeID, _ := strconv.ParseUint(c.Query("before_id"), 10, 32); beforeID > 0 {
+		f.BeforeID = uint(beforeID)
 	}
-	if model := c.Query("model"); model != "" {
-		filters["model"] = model
+	if replayOfID, _ := strconv.ParseUint(c.Query("replay_of_id"), 10, 32); replayOfID > 0 {
+		f.ReplayOfID = uint(replayOfID)
 	}
 	if success := c.Query("success"); success != "" {
-		filters["success"] = success == "true"
+		v := success == "true"
+		f.Success = &v
 	}
+	f.StatusCode, _ = strconv.Atoi(c.Query("status_code"))
+	f.UpstreamStatus, _ = strconv.Atoi(c.Query("upstream_status"))
+	f.MinDuration, _ = strconv.ParseInt(c.Query("min_duration"), 10, 64)
+	f.MaxDuration, _ = strconv.ParseInt(c.Query("max_duration"), 10, 64)
+	f.MinTokens, _ = strconv.Atoi(c.Query("min_tokens"))
+	f.MaxTokens, _ = strconv.Atoi(c.Query("max_tokens"))
+	f.MinCost, _ = strconv.ParseFloat(c.Query("min_cost"), 64)
+	f.MaxCost, _ = strconv.ParseFloat(c.Query("max_cost"), 64)
 	if startTime := c.Query("start_time"); startTime != "" {
 		if t, err := time.Parse(time.RFC3339, startTime); err == nil {
-			filters["start_time"] = t
+			f.StartTime = t
 		}
 	}
 	if endTime := c.Query("end_time"); endTime != "" {
 		if t, err := time.Parse(time.RFC3339, endTime); err == nil {
-			filters["end_time"] = t
+			f.EndTime = t
 		}
 	}
-
-	logs, total, err := h.repo.List(page, pageSize, filters)
-	if err != nil {
-		response.Error(c, http.StatusInternalServerError, err.Error())
-		return
-	}
-
-	response.SuccessWithPagination(c, logs, total, page, pageSize)
+	return f
 }
 
 // GetSummary 获取请求统计摘要
@@ -121,3 +171,110 @@ func (h *RequestLogHandler) GetAccountLoadStats(c *gin.Context) {
 
 	response.Success(c, stats)
 }
+
+// Get 获取请求日志详情（含请求/响应体）
+// GET /api/admin/logs/:id
+func (h *RequestLogHandler) Get(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "无效的日志 ID")
+		return
+	}
+	log, err := h.repo.GetByID(uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.NotFound(c, "日志不存在")
+			return
+		}
+		response.InternalError(c, err.Error())
+		return
+	}
+	response.Success(c, log)
+}
+
+// GetSession 获取与指定日志同一会话的请求
+// GET /api/admin/logs/:id/session
+func (h *RequestLogHandler) GetSession(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "无效的日志 ID")
+		return
+	}
+	log, err := h.repo.GetByID(uint(id))
+	if err != nil {
+		response.NotFound(c, "日志不存在")
+		return
+	}
+	if log.SessionID == "" {
+		response.Success(c, gin.H{"session_id": "", "items": []interface{}{}})
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "200"))
+	if limit < 1 || limit > 1000 {
+		limit = 200
+	}
+	logs, err := h.repo.ListSession(log.SessionID, limit)
+	if err != nil {
+		response.InternalError(c, err.Error())
+		return
+	}
+	response.Success(c, gin.H{"session_id": log.SessionID, "items": logs})
+}
+
+// RetentionStatus 获取保留策略配置、分区与清理任务进度
+// GET /api/admin/logs/retention
+func (h *RequestLogHandler) RetentionStatus(c *gin.Context) {
+	cfg := service.GetConfigService()
+	retention := service.GetLogRetentionService()
+	partitioned, partitions, err := retention.PartitionStatus()
+	if err != nil {
+		response.InternalError(c, err.Error())
+		return
+	}
+	response.Success(c, gin.H{
+		"config": gin.H{
+			"body_retention_days": cfg.GetRequestLogBodyRetentionDays(),
+			"retention_days":      cfg.GetRequestLogRetentionDays(),
+			"compress_bodies":     cfg.GetRequestLogCompressBodies(),
+			"archive_enabled":     cfg.GetRequestLogArchiveEnabled(),
+			"archive_dir":         cfg.GetRequestLogArchiveDir(),
+			"partition_enabled":   cfg.GetRequestLogPartitionEnabled(),
+			"interval_minutes":    int(cfg.GetRequestLogRetentionInterval().Minutes()),
+		},
+		"partitioned": partitioned,
+		"partitions":  partitions,
+		"progress":    retention.Progress(),
+	})
+}
+
+// RunRetention 立即执行一次保留策略
+// POST /api/admin/logs/retention/run
+func (h *RequestLogHandler) RunRetention(c *gin.Context) {
+	if !service.GetLogRetentionService().Trigger() {
+		response.Error(c, http.StatusConflict, "清理任务正在执行中")
+		return
+	}
+	response.Success(c, gin.H{"triggered": true})
+}
+
+// ListArchives 列出归档文件
+// GET /api/admin/logs/archives
+func (h *RequestLogHandler) ListArchives(c *gin.Context) {
+	files, err := service.GetLogRetentionService().ListArchives()
+	if err != nil {
+		response.InternalError(c, err.Error())
+		return
+	}
+	response.Success(c, gin.H{"archives": files})
+}
+
+// DownloadArchive 下载归档文件
+// GET /api/admin/logs/archives/:name
+func (h *RequestLogHandler) DownloadArchive(c *gin.Context) {
+	path, err := service.GetLogRetentionService().ArchivePath(c.Param("name"))
+	if err != nil {
+		response.NotFound(c, err.Error())
+		return
+	}
+	c.FileAttachment(path, c.Param("name"))
+}
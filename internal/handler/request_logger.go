@@ -3,9 +3,11 @@
  * 负责功能：
  *   - 请求日志异步写入
  *   - 日志对象构建
+ *   - 落库前敏感信息脱敏
+ *   - 全文检索文本写入
  *   - 单例模式延迟初始化
  * 重要程度：⭐⭐⭐ 一般（日志记录）
- * 依赖模块：model, repository
+ * 依赖模块：model, repository, service
  */
 package handler
 
@@ -17,6 +19,9 @@ import (
 
 	"cli-proxy/internal/model"
 	"cli-proxy/internal/repository"
+	"cli-proxy/internal/service"
+
+	"github.com/gin-gonic/gin"
 )
 
 // RequestLogger 请求日志记录器
@@ -39,9 +44,33 @@ func getRequestLogger() *RequestLogger {
 	return defaultRequestLogger
 }
 
-// LogRequest 记录请求
+// LogRequest 记录请求（落库前按系统配置脱敏、压缩请求/响应体，并写入可检索文本）
 func LogRequest(log *model.RequestLog) {
-	go getRequestLogger().repo.Create(log)
+	go saveRequestLog(log)
+}
+
+// saveRequestLog 同步写入请求日志（需要拿到日志 ID 时直接调用）
+func saveRequestLog(log *model.RequestLog) error {
+	service.GetRedactionService().RedactLog(log)
+	searchText := model.BuildRequestLogSearchText(log)
+	if service.GetConfigService().GetRequestLogCompressBodies() {
+		log.CompressBodies()
+	}
+	repo := getRequestLogger().repo
+	if err := repo.Create(log); err != nil {
+		return err
+	}
+	return repo.SaveSearchText(log.ID, log.CreatedAt, searchText)
+}
+
+// clientTypeFromContext 获取客户端过滤中间件识别出的客户端类型
+func clientTypeFromContext(c *gin.Context) string {
+	if v, ok := c.Get("client_type"); ok {
+		if clientType, ok := v.(string); ok {
+			return clientType
+		}
+	}
+	return ""
 }
 
 // BuildRequestLog 构建请求日志
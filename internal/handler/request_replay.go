@@ -0,0 +1,410 @@
+/*
+ * 文件作用：请求重放处理器，管理员调试时重新执行已记录的请求
+ * 负责功能：
+ *   - 从请求日志还原请求头和请求体（脱敏过的请求体需显式确认才重放）
+ *   - 指定账户/模型重新发送（不走调度与会话粘性）
+ *   - 流式结果可通过 SSE 实时返回
+ *   - 重放结果记录为新日志并关联原日志，不计入 API Key 用量
+ * 重要程度：⭐⭐⭐ 一般（调试工具）
+ * 依赖模块：repository, scheduler, adapter, service, redact
+ */
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"cli-proxy/internal/model"
+	"cli-proxy/internal/proxy/adapter"
+	"cli-proxy/internal/proxy/scheduler"
+	"cli-proxy/internal/redact"
+	"cli-proxy/internal/repository"
+	"cli-proxy/internal/service"
+	"cli-proxy/pkg/logger"
+	"cli-proxy/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 重放响应最多保留的字节数
+const replayMaxResponseBytes = 1 << 20
+
+// RequestReplayHandler 请求重放处理器
+type RequestReplayHandler struct {
+	scheduler      *scheduler.Scheduler
+	repo           *repository.RequestLogRepository
+	pricingService *service.PricingService
+}
+
+// NewRequestReplayHandler 创建请求重放处理器
+func NewRequestReplayHandler() *RequestReplayHandler {
+	return &RequestReplayHandler{
+		scheduler:      scheduler.GetScheduler(),
+		repo:           repository.NewRequestLogRepository(),
+		pricingService: service.NewPricingService(),
+	}
+}
+
+// ReplayRequest 重放请求参数
+type ReplayRequest struct {
+	AccountID uint   `json:"account_id"` // 指定账户，0 表示按正常调度选择
+	Model     string `json:"model"`      // 覆盖模型，为空时使用原请求模型
+	Stream    *bool  `json:"stream"`     // 覆盖是否流式，为空时沿用原请求
+	SSE       bool   `json:"sse"`        // 流式请求是否以 SSE 实时返回上游原始输出
+	// AllowRedacted 原请求体含脱敏标记时仍然重放（发给上游的是脱敏后的内容，与原请求不同）
+	AllowRedacted bool `json:"allow_redacted"`
+}
+
+// ReplayResult 重放结果
+type ReplayResult struct {
+	LogID        uint    `json:"log_id"`
+	AccountID    uint    `json:"account_id"`
+	Model        string  `json:"model"`
+	Stream       bool    `json:"stream"`
+	Success      bool    `json:"success"`
+	StatusCode   int     `json:"status_code"`
+	Error        string  `json:"error,omitempty"`
+	Response     string  `json:"response,omitempty"`
+	InputTokens  int     `json:"input_tokens"`
+	OutputTokens int     `json:"output_tokens"`
+	TotalCost    float64 `json:"total_cost"`
+	Duration     int64   `json:"duration"`
+	Warning      string  `json:"warning,omitempty"`
+}
+
+// limitedBuffer 最多保留 max 字节的缓冲区（超出部分丢弃但不报错）
+type limitedBuffer struct {
+	buf bytes.Buffer
+	max int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if remain := b.max - b.buf.Len(); remain > 0 {
+		if len(p) > remain {
+			b.buf.Write(p[:remain])
+		} else {
+			b.buf.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+// replayWriter SSE 模式下同时写给管理员并保留副本
+type replayWriter struct {
+	w    gin.ResponseWriter
+	copy *limitedBuffer
+}
+
+func (r *replayWriter) Write(p []byte) (int, error) {
+	r.copy.Write(p)
+	return r.w.Write(p)
+}
+
+func (r *replayWriter) Flush() {
+	r.w.Flush()
+}
+
+// Replay 重放请求
+// POST /api/admin/logs/:id/replay
+func (h *RequestReplayHandler) Replay(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "无效的日志 ID")
+		return
+	}
+	var req ReplayRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			response.BadRequest(c, "参数错误: "+err.Error())
+			return
+		}
+	}
+
+	original, err := h.repo.GetByID(uint(id))
+	if err != nil {
+		response.NotFound(c, "日志不存在")
+		return
+	}
+	warning, err := checkReplayable(original, &req)
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+	if warning != "" {
+		logger.GetLogger("proxy").Warn("重放含脱敏标记的请求 | 原日志: %d | 管理员 IP: %s", original.ID, c.ClientIP())
+	}
+
+	adapterReq, accountType, err := buildReplayRequest(original, &req)
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	retryConfig := scheduler.DefaultRetryConfig
+	retryConfig.MaxRetries = 0
+	retryReq := scheduler.NewRetryableRequest(h.scheduler, &retryConfig).
+		WithUserInfo(0, 0, c.ClientIP(), "admin-replay").
+		WithOriginalModel(adapterReq.Model)
+	if req.AccountID > 0 {
+		retryReq.WithForcedAccount(req.AccountID)
+	}
+	modelName := adapterReq.Model
+	if accountType != "" {
+		modelName = accountType + "," + adapterReq.Model
+	}
+
+	result := &ReplayResult{Model: adapterReq.Model, Stream: adapterReq.Stream, Warning: warning}
+	captured := &limitedBuffer{max: replayMaxResponseBytes}
+	usage := &adapter.StreamResult{}
+	startTime := time.Now()
+	sse := adapterReq.Stream && req.SSE
+
+	if adapterReq.Stream {
+		var writer io.Writer = captured
+		if sse {
+			c.Header("Content-Type", "text/event-stream")
+			c.Header("Cache-Control", "no-cache")
+			c.Header("Connection", "keep-alive")
+			c.Header("X-Accel-Buffering", "no")
+			c.Writer.Flush()
+			writer = &replayWriter{w: c.Writer, copy: captured}
+		}
+		streamResult, execErr := retryReq.ExecuteStreamWithRetry(c.Request.Context(), modelName,
+			func(ctx context.Context, account *model.Account, w io.Writer) (*adapter.StreamResult, error) {
+				adp := adapter.Get(account.Type)
+				if adp == nil {
+					return nil, adapter.ErrNoAdapter
+				}
+				return adp.SendStream(ctx, account, adapterReq, w)
+			}, writer)
+		err = execErr
+		if streamResult != nil {
+			result.AccountID = streamResult.AccountID
+			if streamResult.Result != nil {
+				usage = streamResult.Result
+			}
+		}
+	} else {
+		execResult, execErr := retryReq.ExecuteWithRetry(c.Request.Context(), modelName,
+			func(ctx context.Context, account *model.Account) (*adapter.Response, error) {
+				adp := adapter.Get(account.Type)
+				if adp == nil {
+					return nil, adapter.ErrNoAdapter
+				}
+				return adp.Send(ctx, account, adapterReq)
+			})
+		err = execErr
+		if execResult != nil {
+			result.AccountID = execResult.AccountID
+			if resp := execResult.Response; resp != nil {
+				usage.InputTokens, usage.OutputTokens = resp.InputTokens, resp.OutputTokens
+				body, _ := json.Marshal(resp)
+				captured.Write(body)
+				if resp.Error != nil && err == nil {
+					err = fmt.Errorf("%s: %s", resp.Error.Type, resp.Error.Message)
+				}
+			}
+		}
+	}
+	if result.AccountID == 0 {
+		result.AccountID = req.AccountID
+	}
+
+	result.Duration = time.Since(startTime).Milliseconds()
+	result.Response = captured.buf.String()
+	result.InputTokens, result.OutputTokens = usage.InputTokens, usage.OutputTokens
+	result.Success = err == nil
+	result.StatusCode = http.StatusOK
+	upstreamStatus := 0
+	if err != nil {
+		result.Error = err.Error()
+		_, result.StatusCode = getProxyErrorTypeAndCode(err)
+		var upstreamErr *adapter.UpstreamError
+		if errors.As(err, &upstreamErr) {
+			upstreamStatus = upstreamErr.StatusCode
+		}
+	} else {
+		upstreamStatus = http.StatusOK
+	}
+
+	result.LogID, result.TotalCost = h.recordReplay(original, adapterReq, result, usage, upstreamStatus, startTime)
+
+	if sse {
+		// 流结束后追加一条重放汇总事件，便于前端关联新日志
+		summary := *result
+		summary.Response = ""
+		data, _ := json.Marshal(summary)
+		fmt.Fprintf(c.Writer, "event: replay_result\ndata: %s\n\n", data)
+		c.Writer.Flush()
+		return
+	}
+
+	response.Success(c, gin.H{
+		"original": gin.H{
+			"id":                   original.ID,
+			"account_id":           original.AccountID,
+			"model":                original.Model,
+			"success":              original.Success,
+			"status_code":          original.StatusCode,
+			"upstream_status_code": original.UpstreamStatusCode,
+			"error":                original.Error,
+			"upstream_error":       original.UpstreamError,
+			"response":             original.ResponseBody,
+			"input_tokens":         original.InputTokens,
+			"output_tokens":        original.OutputTokens,
+			"total_cost":           original.TotalCost,
+			"duration":             original.Duration,
+		},
+		"replay": result,
+	})
+}
+
+// replayRedactedWarning 显式确认重放含脱敏标记的请求时返回的提示
+const replayRedactedWarning = "原请求体含脱敏标记，本次重放发送的是脱敏后的内容，结果可能与原请求不同"
+
+// checkReplayable 校验日志是否可以重放；含脱敏标记的请求体需显式设置 allow_redacted，并返回提示
+func checkReplayable(original *model.RequestLog, req *ReplayRequest) (string, error) {
+	if original.RequestBody == "" {
+		return "", errors.New("原请求体未记录或已被清理，无法重放")
+	}
+	if strings.HasSuffix(original.RequestBody, "...[truncated]") {
+		return "", errors.New("原请求体记录时已截断，无法重放")
+	}
+	if redact.HasMarker(original.RequestBody) {
+		if !req.AllowRedacted {
+			return "", errors.New("原请求体记录时已脱敏，重放内容与原请求不同；确认重放请设置 allow_redacted")
+		}
+		return replayRedactedWarning, nil
+	}
+	return "", nil
+}
+
+// buildReplayRequest 从日志还原适配器请求，返回请求和调度用的账户类型前缀
+func buildReplayRequest(original *model.RequestLog, req *ReplayRequest) (*adapter.Request, string, error) {
+	var body map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(original.RequestBody), &body); err != nil {
+		return nil, "", fmt.Errorf("原请求体不是有效的 JSON: %v", err)
+	}
+
+	modelName := original.Model
+	if raw, ok := body["model"]; ok {
+		var m string
+		if json.Unmarshal(raw, &m) == nil && m != "" {
+			modelName = scheduler.GetActualModel(m)
+		}
+	}
+	if req.Model != "" {
+		modelName = req.Model
+		body["model"], _ = json.Marshal(modelName)
+	}
+	if modelName == "" {
+		return nil, "", fmt.Errorf("无法确定请求模型")
+	}
+
+	stream := false
+	if raw, ok := body["stream"]; ok {
+		json.Unmarshal(raw, &stream)
+	}
+	if req.Stream != nil {
+		stream = *req.Stream
+		body["stream"], _ = json.Marshal(stream)
+	}
+
+	rawBody, err := json.Marshal(body)
+	if err != nil {
+		return nil, "", err
+	}
+
+	headers := make(map[string]string)
+	if original.RequestHeaders != "" {
+		json.Unmarshal([]byte(original.RequestHeaders), &headers)
+	}
+
+	adapterReq := &adapter.Request{
+		Model:   modelName,
+		Stream:  stream,
+		RawBody: rawBody,
+		Headers: headers,
+		Path:    original.Path,
+	}
+	// OpenAI/Gemini 适配器需要解析后的消息；Claude 直接透传原始请求体
+	if original.Platform != model.PlatformClaude {
+		var parsed adapter.Request
+		if json.Unmarshal(rawBody, &parsed) == nil {
+			parsed.Model, parsed.Stream = modelName, stream
+			parsed.RawBody, parsed.Headers, parsed.Path = rawBody, headers, original.Path
+			adapterReq = &parsed
+		}
+	}
+
+	accountType := ""
+	switch original.Platform {
+	case model.PlatformClaude, model.PlatformOpenAI, model.PlatformGemini:
+		accountType = original.Platform
+	}
+	return adapterReq, accountType, nil
+}
+
+// recordReplay 记录重放日志（关联原日志，不计入 API Key 用量），返回日志 ID 和费用
+func (h *RequestReplayHandler) recordReplay(original *model.RequestLog, req *adapter.Request, result *ReplayResult,
+	usage *adapter.StreamResult, upstreamStatus int, startTime time.Time) (uint, float64) {
+	cost, err := h.pricingService.CalculateCost(context.Background(), req.Model, &service.TokenUsage{
+		InputTokens:              usage.InputTokens,
+		OutputTokens:             usage.OutputTokens,
+		CacheCreationInputTokens: usage.CacheCreationInputTokens,
+		CacheReadInputTokens:     usage.CacheReadInputTokens,
+	}, 1.0)
+	if err != nil {
+		cost = &service.CostBreakdown{}
+	}
+
+	originalID := original.ID
+	replayLog := &model.RequestLog{
+		AccountID:                result.AccountID,
+		Platform:                 original.Platform,
+		Model:                    req.Model,
+		Endpoint:                 original.Endpoint,
+		Method:                   original.Method,
+		Path:                     original.Path,
+		RequestIP:                original.RequestIP,
+		UserAgent:                "admin-replay",
+		ClientType:               original.ClientType,
+		RequestHeaders:           original.RequestHeaders,
+		RequestBody:              string(req.RawBody),
+		InputTokens:              usage.InputTokens,
+		OutputTokens:             usage.OutputTokens,
+		CacheCreationInputTokens: usage.CacheCreationInputTokens,
+		CacheReadInputTokens:     usage.CacheReadInputTokens,
+		TotalTokens:              usage.InputTokens + usage.OutputTokens + usage.CacheCreationInputTokens + usage.CacheReadInputTokens,
+		InputCost:                cost.InputCost,
+		OutputCost:               cost.OutputCost,
+		CacheCreateCost:          cost.CacheCreateCost,
+		CacheReadCost:            cost.CacheReadCost,
+		TotalCost:                cost.TotalCost,
+		StatusCode:               result.StatusCode,
+		Success:                  result.Success,
+		Error:                    truncateForLog(result.Error, 990),
+		Duration:                 result.Duration,
+		UpstreamStatusCode:       upstreamStatus,
+		ReplayOfID:               &originalID,
+		CreatedAt:                startTime,
+	}
+	if len(result.Response) > 65536 {
+		replayLog.ResponseBody = result.Response[:65536] + "...[truncated]"
+	} else {
+		replayLog.ResponseBody = result.Response
+	}
+	if err := saveRequestLog(replayLog); err != nil {
+		logger.GetLogger("proxy").Error("记录重放日志失败 | 原日志: %d | 错误: %v", original.ID, err)
+		return 0, cost.TotalCost
+	}
+	return replayLog.ID, cost.TotalCost
+}
@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"encoding/json"
+	"testing"
+
+	"cli-proxy/internal/model"
+)
+
+func TestCheckReplayable(t *testing.T) {
+	cases := []struct {
+		name        string
+		body        string
+		allow       bool
+		wantErr     bool
+		wantWarning bool
+	}{
+		{"plain", `{"model":"m","messages":[]}`, false, false, false},
+		{"empty", "", false, true, false},
+		{"truncated", `{"model":"m"...[truncated]`, false, true, false},
+		{"redacted refused", `{"model":"m","messages":[{"role":"user","content":"key [REDACTED:APIKEY]"}]}`, false, true, false},
+		{"redacted allowed", `{"model":"m","messages":[{"role":"user","content":"key [REDACTED:APIKEY]"}]}`, true, false, true},
+	}
+	for _, tc := range cases {
+		warning, err := checkReplayable(&model.RequestLog{RequestBody: tc.body}, &ReplayRequest{AllowRedacted: tc.allow})
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", tc.name, err, tc.wantErr)
+		}
+		if (warning != "") != tc.wantWarning {
+			t.Errorf("%s: warning = %q, want warning %v", tc.name, warning, tc.wantWarning)
+		}
+	}
+}
+
+func TestBuildReplayRequest(t *testing.T) {
+	original := &model.RequestLog{
+		Platform:       model.PlatformClaude,
+		Model:          "claude-sonnet",
+		Path:           "/v1/messages",
+		RequestBody:    `{"model":"claude-sonnet","stream":true,"messages":[{"role":"user","content":"hi"}]}`,
+		RequestHeaders: `{"anthropic-version":"2023-06-01"}`,
+	}
+
+	req, accountType, err := buildReplayRequest(original, &ReplayRequest{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if accountType != model.PlatformClaude || req.Model != "claude-sonnet" || !req.Stream ||
+		req.Path != "/v1/messages" || req.Headers["anthropic-version"] != "2023-06-01" {
+		t.Fatalf("unexpected request: %+v (%s)", req, accountType)
+	}
+
+	// 覆盖模型与流式设置会同步写回请求体
+	stream := false
+	req, _, err = buildReplayRequest(original, &ReplayRequest{Model: "claude-opus", Stream: &stream})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var body map[string]interface{}
+	json.Unmarshal(req.RawBody, &body)
+	if req.Model != "claude-opus" || req.Stream || body["model"] != "claude-opus" || body["stream"] != false {
+		t.Fatalf("overrides not applied: %+v %s", req, req.RawBody)
+	}
+
+	if _, _, err := buildReplayRequest(&model.RequestLog{RequestBody: "not json"}, &ReplayRequest{}); err == nil {
+		t.Fatal("expected invalid json error")
+	}
+	if _, _, err := buildReplayRequest(&model.RequestLog{RequestBody: `{"messages":[]}`}, &ReplayRequest{}); err == nil {
+		t.Fatal("expected missing model error")
+	}
+}
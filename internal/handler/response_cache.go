@@ -0,0 +1,266 @@
+/*
+ * 文件作用：代理请求的响应缓存（精确匹配，非语义缓存）
+ * 负责功能：
+ *   - 通过 X-Response-Cache 请求头按请求开启
+ *   - 规范化请求体（模型、消息、工具、采样参数）计算缓存键
+ *   - 命中时直接回放响应（流式请求按原始 SSE 帧回放），按零费用记录
+ *   - 未命中时捕获完整响应写入缓存（流式响应须正常结束且不含错误事件）
+ * 重要程度：⭐⭐⭐ 一般（可选功能）
+ * 依赖模块：cache, config, adapter
+ */
+package handler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"cli-proxy/internal/cache"
+	"cli-proxy/internal/config"
+	"cli-proxy/internal/proxy/adapter"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// ResponseCacheHeader 开启响应缓存的请求头：on/true/1 仅缓存确定性请求（temperature=0），force 不检查采样参数
+	ResponseCacheHeader = "X-Response-Cache"
+	// ResponseCacheTTLHeader 自定义缓存时间（秒），不超过系统配置的 TTL
+	ResponseCacheTTLHeader = "X-Response-Cache-TTL"
+
+	responseCacheHit  = "hit"
+	responseCacheMiss = "miss"
+)
+
+// responseCacheVolatileFields 不影响输出内容、不参与缓存键计算的字段
+var responseCacheVolatileFields = []string{"stream", "stream_options", "metadata", "user"}
+
+// responseCacheKey 计算请求的缓存键，请求不满足缓存条件时返回空字符串
+func responseCacheKey(c *gin.Context, rawBody []byte, stream bool) string {
+	mode := strings.ToLower(strings.TrimSpace(c.GetHeader(ResponseCacheHeader)))
+	switch mode {
+	case "on", "true", "1", "force":
+	default:
+		return ""
+	}
+
+	var body map[string]interface{}
+	if err := json.Unmarshal(rawBody, &body); err != nil {
+		return ""
+	}
+	if mode != "force" && !isDeterministicRequest(body) {
+		return ""
+	}
+	for _, field := range responseCacheVolatileFields {
+		delete(body, field)
+	}
+
+	// map 序列化时键有序，得到规范化的请求表示
+	normalized, err := json.Marshal(body)
+	if err != nil {
+		return ""
+	}
+	apiKeyID, _ := c.Get("api_key_id")
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%v|%s|%t|", apiKeyID, c.Request.URL.Path, stream) + string(normalized)))
+	return hex.EncodeToString(sum[:])
+}
+
+// isDeterministicRequest 判断请求是否为确定性采样（temperature 显式为 0）
+func isDeterministicRequest(body map[string]interface{}) bool {
+	temperature, ok := body["temperature"].(float64)
+	return ok && temperature == 0
+}
+
+// responseCacheTTL 获取本次请求的缓存 TTL
+func responseCacheTTL(c *gin.Context) time.Duration {
+	ttl := config.Cfg.Cache.GetResponseCacheTTL()
+	if custom, err := strconv.Atoi(c.GetHeader(ResponseCacheTTLHeader)); err == nil && custom > 0 && custom < ttl {
+		ttl = custom
+	}
+	return time.Duration(ttl) * time.Second
+}
+
+// serveCachedResponse 查询响应缓存，命中时直接回放并返回 true
+// 未命中时在 context 中记录缓存键，供请求完成后写入缓存
+func (h *ProxyHandler) serveCachedResponse(c *gin.Context, req *adapter.Request, modelName string) bool {
+	key := responseCacheKey(c, req.RawBody, req.Stream)
+	if key == "" {
+		return false
+	}
+	c.Set("response_cache_key", key)
+
+	entry, ok := cache.GetResponseCache().Get(key)
+	if !ok {
+		c.Set("response_cache_status", responseCacheMiss)
+		c.Header(ResponseCacheHeader, responseCacheMiss)
+		return false
+	}
+	c.Set("response_cache_status", responseCacheHit)
+	c.Header(ResponseCacheHeader, responseCacheHit)
+
+	if entry.Stream {
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		c.Header("X-Accel-Buffering", "no")
+		c.Status(entry.StatusCode)
+		c.Writer.Write(entry.Body)
+		c.Writer.Flush()
+	} else {
+		c.Data(entry.StatusCode, entry.ContentType, entry.Body)
+	}
+
+	var requestBody []byte
+	if rb, ok := c.Get("request_body"); ok {
+		requestBody = rb.([]byte)
+	}
+	logBody := entry.Body
+	if entry.Stream && len(logBody) > 2048 {
+		logBody = logBody[len(logBody)-2048:]
+	}
+	// 命中缓存不产生上游消耗，按零用量零费用记录
+	h.recordUsage(c, modelName, &adapter.StreamResult{}, entry.Stream, requestBody, logBody, 0, 0)
+	return true
+}
+
+// storeCachedResponse 将成功的响应写入缓存（仅在本次请求开启了响应缓存且未命中时生效）
+// 流式响应被截断或包含错误事件时不缓存，避免在 TTL 内向所有客户端回放不完整的输出
+func storeCachedResponse(c *gin.Context, modelName string, stream bool, contentType string, body []byte, usage *adapter.StreamResult) {
+	key := c.GetString("response_cache_key")
+	if key == "" || c.GetString("response_cache_status") != responseCacheMiss || len(body) == 0 {
+		return
+	}
+	if len(body) > config.Cfg.Cache.GetResponseCacheMaxEntryKB()*1024 {
+		return
+	}
+	if stream && !isCompleteStream(body) {
+		return
+	}
+
+	var apiKeyID uint
+	if id, ok := c.Get("api_key_id"); ok {
+		apiKeyID, _ = id.(uint)
+	}
+	now := time.Now()
+	entry := &cache.ResponseCacheEntry{
+		Key:         key,
+		APIKeyID:    apiKeyID,
+		Model:       modelName,
+		Path:        c.Request.URL.Path,
+		Stream:      stream,
+		StatusCode:  http.StatusOK,
+		ContentType: contentType,
+		Body:        append([]byte(nil), body...),
+		CreatedAt:   now,
+		ExpireAt:    now.Add(responseCacheTTL(c)),
+	}
+	if usage != nil {
+		entry.InputTokens = usage.InputTokens
+		entry.OutputTokens = usage.OutputTokens
+	}
+	cache.GetResponseCache().Set(entry)
+}
+
+// isCompleteStream 判断捕获的 SSE 流是否正常结束且不含错误事件：
+// Claude 格式以 message_stop 事件结束；OpenAI 格式包含 finish_reason 且以 [DONE] 结束
+func isCompleteStream(body []byte) bool {
+	var last string
+	finished := false
+	for _, line := range strings.Split(string(body), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.HasPrefix(line, "event:") {
+			if strings.TrimSpace(strings.TrimPrefix(line, "event:")) == "error" {
+				return false
+			}
+			continue
+		}
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "" {
+			continue
+		}
+		last = data
+		if data == "[DONE]" {
+			continue
+		}
+
+		var event struct {
+			Type    string          `json:"type"`
+			Error   json.RawMessage `json:"error"`
+			Choices []struct {
+				FinishReason *string `json:"finish_reason"`
+			} `json:"choices"`
+		}
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			continue
+		}
+		if event.Type == "error" || (len(event.Error) > 0 && string(event.Error) != "null") {
+			return false
+		}
+		if event.Type == "message_stop" {
+			finished = true
+			last = event.Type
+		}
+		for _, choice := range event.Choices {
+			if choice.FinishReason != nil && *choice.FinishReason != "" {
+				finished = true
+			}
+		}
+	}
+	return finished && (last == "[DONE]" || last == "message_stop")
+}
+
+// responseCapture 流式响应捕获写入器：透传给客户端，开启响应缓存时同时保留完整输出
+type responseCapture struct {
+	w        io.Writer
+	enabled  bool
+	overflow bool
+	limit    int
+	buf      bytes.Buffer
+}
+
+// newResponseCapture 创建流式响应捕获写入器（本次请求未开启响应缓存时只透传）
+func newResponseCapture(c *gin.Context, w io.Writer) *responseCapture {
+	return &responseCapture{
+		w:       w,
+		enabled: c.GetString("response_cache_status") == responseCacheMiss,
+		limit:   config.Cfg.Cache.GetResponseCacheMaxEntryKB() * 1024,
+	}
+}
+
+// Write 实现 io.Writer 接口
+func (rc *responseCapture) Write(p []byte) (int, error) {
+	if rc.enabled && !rc.overflow {
+		if rc.buf.Len()+len(p) > rc.limit {
+			rc.overflow = true
+			rc.buf.Reset()
+		} else {
+			rc.buf.Write(p)
+		}
+	}
+	return rc.w.Write(p)
+}
+
+// Flush 实现 http.Flusher 接口
+func (rc *responseCapture) Flush() {
+	if f, ok := rc.w.(interface{ Flush() }); ok {
+		f.Flush()
+	}
+}
+
+// Bytes 返回捕获的完整输出，未开启或超出大小上限时返回 nil
+func (rc *responseCapture) Bytes() []byte {
+	if !rc.enabled || rc.overflow {
+		return nil
+	}
+	return rc.buf.Bytes()
+}
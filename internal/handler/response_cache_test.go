@@ -0,0 +1,141 @@
+package handler
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"cli-proxy/internal/cache"
+	"cli-proxy/internal/config"
+	"cli-proxy/internal/proxy/adapter"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	claudeCompleteStream = "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_1\"}}\n\n" +
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"hi\"}}\n\n" +
+		"event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"end_turn\"}}\n\n" +
+		"event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n"
+	openAICompleteStream = "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"hi\"},\"finish_reason\":null}]}\n\n" +
+		"data: {\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"stop\"}]}\n\n" +
+		"data: [DONE]\n\n"
+)
+
+func newResponseCacheTestContext(t *testing.T, header string) *gin.Context {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	prev := config.Cfg
+	config.Cfg = &config.Config{}
+	t.Cleanup(func() { config.Cfg = prev })
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+	if header != "" {
+		c.Request.Header.Set(ResponseCacheHeader, header)
+	}
+	c.Set("api_key_id", uint(7))
+	return c
+}
+
+func TestIsCompleteStream(t *testing.T) {
+	cases := []struct {
+		name string
+		body string
+		want bool
+	}{
+		{"claude complete", claudeCompleteStream, true},
+		{"openai complete", openAICompleteStream, true},
+		{"claude truncated", strings.SplitAfter(claudeCompleteStream, "\n\n")[1], false},
+		{"claude error event", strings.Replace(claudeCompleteStream, "event: message_delta", "event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\"}}\n\nevent: message_delta", 1), false},
+		{"openai without finish_reason", "data: {\"choices\":[{\"delta\":{\"content\":\"hi\"},\"finish_reason\":null}]}\n\ndata: [DONE]\n\n", false},
+		{"openai without done", strings.TrimSuffix(openAICompleteStream, "data: [DONE]\n\n"), false},
+		{"openai error chunk", "data: {\"error\":{\"message\":\"upstream failed\"}}\n\n" + openAICompleteStream, false},
+		{"empty", "", false},
+	}
+	for _, tc := range cases {
+		if got := isCompleteStream([]byte(tc.body)); got != tc.want {
+			t.Errorf("%s: isCompleteStream = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestResponseCacheKey(t *testing.T) {
+	body := []byte(`{"model":"m","temperature":0,"messages":[{"role":"user","content":"hi"}],"stream":true,"metadata":{"user_id":"u"}}`)
+
+	if key := responseCacheKey(newResponseCacheTestContext(t, ""), body, true); key != "" {
+		t.Fatal("expected no key without cache header")
+	}
+	c := newResponseCacheTestContext(t, "on")
+	key := responseCacheKey(c, body, true)
+	if key == "" {
+		t.Fatal("expected key for deterministic request")
+	}
+
+	// 易变字段不影响缓存键，流式与非流式分开缓存
+	same := []byte(`{"messages":[{"role":"user","content":"hi"}],"temperature":0,"model":"m"}`)
+	if responseCacheKey(c, same, true) != key {
+		t.Fatal("expected volatile fields ignored")
+	}
+	if responseCacheKey(c, same, false) == key {
+		t.Fatal("expected stream flag in key")
+	}
+
+	// 非确定性请求仅 force 模式缓存
+	sampled := []byte(`{"model":"m","temperature":0.7,"messages":[]}`)
+	if responseCacheKey(c, sampled, false) != "" {
+		t.Fatal("expected sampled request skipped")
+	}
+	if responseCacheKey(newResponseCacheTestContext(t, "force"), sampled, false) == "" {
+		t.Fatal("expected force mode to cache sampled request")
+	}
+}
+
+func TestStoreCachedResponseSkipsIncompleteStream(t *testing.T) {
+	c := newResponseCacheTestContext(t, "on")
+	store := func(key, body string) bool {
+		c.Set("response_cache_key", key)
+		c.Set("response_cache_status", responseCacheMiss)
+		storeCachedResponse(c, "m", true, "text/event-stream", []byte(body), &adapter.StreamResult{})
+		_, ok := cache.GetResponseCache().Get(key)
+		cache.GetResponseCache().Delete(key)
+		return ok
+	}
+
+	if !store("response-cache-test-complete", claudeCompleteStream) {
+		t.Fatal("expected complete stream cached")
+	}
+	if store("response-cache-test-truncated", strings.SplitAfter(claudeCompleteStream, "\n\n")[0]) {
+		t.Fatal("expected truncated stream not cached")
+	}
+	if store("response-cache-test-error", claudeCompleteStream+"event: error\ndata: {\"type\":\"error\"}\n\n") {
+		t.Fatal("expected stream with error event not cached")
+	}
+}
+
+func TestResponseCapture(t *testing.T) {
+	c := newResponseCacheTestContext(t, "on")
+	c.Set("response_cache_status", responseCacheMiss)
+
+	var out bytes.Buffer
+	rc := newResponseCapture(c, &out)
+	rc.limit = 8
+	rc.Write([]byte("data: "))
+	if string(rc.Bytes()) != "data: " {
+		t.Fatalf("unexpected capture: %q", rc.Bytes())
+	}
+	// 超出上限后放弃捕获，但继续透传
+	rc.Write([]byte("0123456789"))
+	if rc.Bytes() != nil || out.String() != "data: 0123456789" {
+		t.Fatalf("unexpected overflow state: %q %q", rc.Bytes(), out.String())
+	}
+
+	// 未开启响应缓存时只透传
+	plain := newResponseCapture(newResponseCacheTestContext(t, ""), &out)
+	plain.Write([]byte("x"))
+	if plain.Bytes() != nil {
+		t.Fatal("expected no capture when cache disabled")
+	}
+}
@@ -33,8 +33,11 @@ func RegisterRoutes(r *gin.Engine) {
 	accountHandler := NewAccountHandler()
 	proxyHandler := NewProxyHandler()
 	requestLogHandler := NewRequestLogHandler()
+	requestReplayHandler := NewRequestReplayHandler()
 	oauthHandler := NewOAuthHandler()
 	usageHandler := NewUsageHandler()
+	usageReportHandler := NewUsageReportHandler()
+	accountAnalyticsHandler := NewAccountAnalyticsHandler()
 	cacheHandler := NewCacheHandler()
 	operationLogHandler := NewOperationLogHandler()
 
@@ -73,9 +76,14 @@ func RegisterRoutes(r *gin.Engine) {
 
 	// ========== 代理转发接口 (需要 API Key 认证) ==========
 	proxyGroup := r.Group("")
+	proxyGroup.Use(middleware.IPFilter())              // 全局 IP 封禁
 	proxyGroup.Use(middleware.APIKeyAuth())
+	proxyGroup.Use(middleware.RequestEvents())       // 实时请求事件
 	proxyGroup.Use(middleware.ClientFilter())        // 客户端过滤
+	proxyGroup.Use(middleware.ClientFingerprint())   // 客户端指纹统计与异常检测
 	proxyGroup.Use(middleware.CheckAllowedClients()) // API Key 客户端限制检查
+	proxyGroup.Use(middleware.APIKeyTokenLimit())    // API Key Token 限流（TPM/ITPM/OTPM）
+	proxyGroup.Use(middleware.APIKeyConcurrency())   // API Key 并发限制（放在最后，被拒绝的请求不占槽位）
 	{
 		// ========== 按平台区分的路由 ==========
 		// Claude 平台 - 使用 Claude 原生格式
@@ -108,6 +116,7 @@ func RegisterRoutes(r *gin.Engine) {
 
 	// ========== API Key 自助查询接口 (需要 API Key 认证) ==========
 	keyGroup := r.Group("/api/key")
+	keyGroup.Use(middleware.IPFilter())
 	keyGroup.Use(middleware.APIKeyAuth())
 	{
 		keyGroup.GET("/info", apiKeyHandler.GetMyInfo)           // 查询当前 Key 信息
@@ -123,6 +132,7 @@ func RegisterRoutes(r *gin.Engine) {
 	admin.Use(middleware.AdminRequired())
 	{
 		// API Key 管理（管理员直接管理）
+		clientFingerprintHandler := NewClientFingerprintHandler()
 		apiKeys := admin.Group("/api-keys")
 		{
 			apiKeys.GET("", apiKeyHandler.AdminListAll)
@@ -132,8 +142,18 @@ func RegisterRoutes(r *gin.Engine) {
 			apiKeys.PUT("/:id", apiKeyHandler.AdminUpdate)
 			apiKeys.DELETE("/:id", apiKeyHandler.AdminDelete)
 			apiKeys.PUT("/:id/toggle", apiKeyHandler.AdminToggleStatus)
+			apiKeys.PUT("/:id/status", apiKeyHandler.AdminSetStatus) // 设置为指定状态（active/disabled）
 			apiKeys.GET("/:id/logs", apiKeyHandler.AdminGetAPIKeyLogs)
 			apiKeys.GET("/:id/usage", usageHandler.GetAPIKeyUsage)
+			apiKeys.GET("/:id/fingerprints", clientFingerprintHandler.ListFingerprints) // 已知客户端指纹
+			apiKeys.GET("/:id/fingerprint-stats", clientFingerprintHandler.GetStats)    // 客户端指纹小时统计
+		}
+
+		// 客户端指纹异常告警
+		fingerprintAlerts := admin.Group("/fingerprint-alerts")
+		{
+			fingerprintAlerts.GET("", clientFingerprintHandler.ListAlerts)
+			fingerprintAlerts.PUT("/:id/ack", clientFingerprintHandler.AcknowledgeAlert)
 		}
 
 		// 使用统计
@@ -144,6 +164,24 @@ func RegisterRoutes(r *gin.Engine) {
 			usage.GET("/models", usageHandler.GetModelSummary)          // 模型汇总
 			usage.GET("/api-keys", usageHandler.GetAPIKeyUsageSummary)  // 各 API Key 使用汇总
 			usage.GET("/records", usageHandler.GetAllUsageRecords)      // 所有使用记录
+			usage.GET("/prompt-cache", usageHandler.GetPromptCacheReport) // Prompt Caching 命中率与节省
+
+			// 用量导出与周期报表
+			usage.GET("/export", usageReportHandler.ExportUsage)                       // 流式导出 CSV/XLSX/JSON
+			usage.GET("/reports", usageReportHandler.ListReports)                      // 报表列表
+			usage.POST("/reports/generate", usageReportHandler.GenerateReports)        // 手动生成报表
+			usage.GET("/reports/:id", usageReportHandler.GetReport)                    // 报表详情
+			usage.GET("/reports/:id/download", usageReportHandler.DownloadReport)      // 下载报表
+			usage.POST("/reports/:id/deliver", usageReportHandler.DeliverReport)       // 推送报表到 Webhook
+			usage.DELETE("/reports/:id", usageReportHandler.DeleteReport)              // 删除报表
+		}
+
+		// 账户成本与盈利分析
+		analytics := admin.Group("/analytics")
+		{
+			analytics.GET("/accounts", accountAnalyticsHandler.GetAccounts) // 各账户成本、收入与利润
+			analytics.GET("/groups", accountAnalyticsHandler.GetGroups)     // 各分组成本、收入与利润
+			analytics.GET("/trend", accountAnalyticsHandler.GetTrend)       // 成本与收入趋势
 		}
 
 		// 模型价格查询
@@ -167,7 +205,15 @@ func RegisterRoutes(r *gin.Engine) {
 			// 用量查询相关操作
 			accounts.GET("/:id/usage", accountHandler.FetchUsage)         // 查询单个账户用量
 			accounts.POST("/batch-usage", accountHandler.BatchFetchUsage) // 批量同步所有账户用量
+
+			// 批量导入/导出
+			accounts.POST("/import", accountHandler.ImportAccounts) // 批量导入（JSON/JSONL/CSV/凭证包）
+			accounts.POST("/export", accountHandler.ExportAccounts) // 导出口令加密的凭证包
+
+			// 出口 IP
+			accounts.GET("/:id/egress", GetAccountEgress) // 账户当前出口 IP 与最近变更
 		}
+		admin.GET("/egress-changes", ListEgressChanges) // 出口 IP 变更记录
 
 		// 健康检测服务管理
 		healthCheck := admin.Group("/health-check")
@@ -203,6 +249,21 @@ func RegisterRoutes(r *gin.Engine) {
 			logs.GET("", requestLogHandler.List)
 			logs.GET("/summary", requestLogHandler.GetSummary)
 			logs.GET("/account-load", requestLogHandler.GetAccountLoadStats)
+			logs.GET("/retention", requestLogHandler.RetentionStatus)
+			logs.POST("/retention/run", requestLogHandler.RunRetention)
+			logs.GET("/archives", requestLogHandler.ListArchives)
+			logs.GET("/archives/:name", requestLogHandler.DownloadArchive)
+			logs.GET("/:id", requestLogHandler.Get)
+			logs.GET("/:id/session", requestLogHandler.GetSession)
+			logs.POST("/:id/replay", requestReplayHandler.Replay)
+		}
+
+		// 实时请求事件流
+		requestEventHandler := NewRequestEventHandler()
+		eventsGroup := admin.Group("/events")
+		{
+			eventsGroup.GET("/requests", requestEventHandler.Stream)
+			eventsGroup.GET("/requests/recent", requestEventHandler.Recent)
 		}
 
 		// 操作日志
@@ -251,6 +312,11 @@ func RegisterRoutes(r *gin.Engine) {
 			cache.GET("/unavailable", cacheHandler.ListUnavailableAccounts)  // 列出不可用账户
 			cache.POST("/clear", cacheHandler.ClearCache)                    // 按类型清理缓存
 			cache.DELETE("/api-keys/:id", cacheHandler.ClearAPIKeyCache)     // 清理 API Key 缓存
+			cache.GET("/api-keys/concurrency", cacheHandler.ListAPIKeyConcurrency)           // API Key 实时并发
+			cache.DELETE("/api-keys/:id/concurrency", cacheHandler.ResetAPIKeyConcurrency)   // 重置 API Key 并发计数
+			cache.GET("/responses", cacheHandler.GetResponseCache)                // 响应缓存统计和条目
+			cache.DELETE("/responses", cacheHandler.PurgeResponseCache)           // 按条件清理响应缓存
+			cache.DELETE("/responses/:key", cacheHandler.DeleteResponseCacheEntry) // 删除单条响应缓存
 			cache.GET("/config", cacheHandler.GetCacheConfig)                // 获取缓存配置
 			cache.PUT("/config", cacheHandler.UpdateCacheConfig)             // 更新缓存配置
 		}
@@ -275,6 +341,16 @@ func RegisterRoutes(r *gin.Engine) {
 			configs.PUT("", configHandler.Update)                           // 更新配置
 		}
 
+		// 配置备份/恢复（声明式同步）
+		configSyncHandler := NewConfigSyncHandler()
+		configSync := admin.Group("/config-sync")
+		{
+			configSync.GET("/sections", configSyncHandler.Sections) // 可同步的分区
+			configSync.GET("/export", configSyncHandler.Export)     // 导出 YAML
+			configSync.POST("/plan", configSyncHandler.Plan)        // 生成变更计划
+			configSync.POST("/apply", configSyncHandler.Apply)      // 应用配置
+		}
+
 		// 代理配置管理
 		proxyConfigs := admin.Group("/proxy-configs")
 		{
@@ -289,6 +365,19 @@ func RegisterRoutes(r *gin.Engine) {
 			proxyConfigs.DELETE("/:id", DeleteProxyConfig)            // 删除代理
 			proxyConfigs.PUT("/:id/toggle", ToggleProxyConfigEnabled) // 切换启用状态
 			proxyConfigs.PUT("/:id/default", SetDefaultProxyConfig)   // 设置为默认代理
+			proxyConfigs.POST("/:id/egress", DiscoverProxyEgress)     // 探测代理出口 IP
+		}
+
+		// 代理池管理
+		proxyPools := admin.Group("/proxy-pools")
+		{
+			proxyPools.GET("", ListProxyPools)                // 获取代理池列表
+			proxyPools.POST("", CreateProxyPool)              // 创建代理池
+			proxyPools.GET("/:id", GetProxyPool)              // 获取单个代理池
+			proxyPools.PUT("/:id", UpdateProxyPool)           // 更新代理池（含成员）
+			proxyPools.DELETE("/:id", DeleteProxyPool)        // 删除代理池
+			proxyPools.GET("/:id/status", GetProxyPoolStatus) // 代理池运行状态
+			proxyPools.POST("/:id/check", CheckProxyPool)     // 立即检测池内代理
 		}
 
 		// 网关配置管理（xyrt 专用）
@@ -320,6 +409,7 @@ func RegisterRoutes(r *gin.Engine) {
 			monitor.GET("/accounts", monitorHandler.GetAccountStats) // 账号统计
 			monitor.GET("/api-keys", monitorHandler.GetAPIKeyStats)  // API Key 统计
 			monitor.GET("/today", monitorHandler.GetTodayUsageStats) // 今日使用统计
+			monitor.GET("/queue", monitorHandler.GetQueueStats)      // 账户池排队统计
 		}
 
 		// 错误消息管理
@@ -335,6 +425,7 @@ func RegisterRoutes(r *gin.Engine) {
 			errorMessages.PUT("/:id/toggle", errorMsgHandler.ToggleEnabled)
 			errorMessages.POST("/init", errorMsgHandler.InitDefault)
 			errorMessages.POST("/refresh", errorMsgHandler.RefreshCache)
+			errorMessages.POST("/preview", errorMsgHandler.Preview) // 预览多语言/模板消息
 			errorMessages.PUT("/enable-all", errorMsgHandler.EnableAll)
 			errorMessages.PUT("/disable-all", errorMsgHandler.DisableAll)
 		}
@@ -348,6 +439,18 @@ func RegisterRoutes(r *gin.Engine) {
 			sysLogs.GET("/tail", systemLogHandler.TailFile)         // 查看日志末尾
 			sysLogs.GET("/download", systemLogHandler.DownloadFile) // 下载日志文件
 			sysLogs.DELETE("/file", systemLogHandler.DeleteFile)    // 删除日志文件
+			sysLogs.GET("/query", systemLogHandler.Query)           // 查询结构化日志索引
+			sysLogs.GET("/stats", systemLogHandler.Stats)           // 索引、保留策略和投递状态
+		}
+
+		// IP 封禁管理
+		ipBanHandler := NewIPBanHandler()
+		ipBans := admin.Group("/ip-bans")
+		{
+			ipBans.GET("", ipBanHandler.List)            // 封禁记录（含已过期/已解除）
+			ipBans.POST("", ipBanHandler.Create)         // 手动封禁
+			ipBans.GET("/check", ipBanHandler.Check)     // 检查 IP 封禁状态
+			ipBans.DELETE("/:id", ipBanHandler.Revoke)   // 解除封禁
 		}
 
 		// 客户端过滤管理
@@ -359,6 +462,7 @@ func RegisterRoutes(r *gin.Engine) {
 			clientFilter.PUT("/config", clientFilterHandler.UpdateConfig)
 			clientFilter.POST("/reload", clientFilterHandler.ReloadCache)
 			clientFilter.POST("/test", clientFilterHandler.TestValidation)
+			clientFilter.POST("/expressions/test", clientFilterHandler.TestExpression)
 
 			// 客户端类型管理
 			clientTypes := clientFilter.Group("/client-types")
@@ -376,11 +480,23 @@ func RegisterRoutes(r *gin.Engine) {
 			{
 				rules.GET("", clientFilterHandler.ListRules)
 				rules.POST("", clientFilterHandler.CreateRule)
+				rules.GET("/stats", clientFilterHandler.GetRuleStats)
+				rules.DELETE("/stats", clientFilterHandler.ResetRuleStats)
 				rules.GET("/:id", clientFilterHandler.GetRule)
 				rules.PUT("/:id", clientFilterHandler.UpdateRule)
 				rules.DELETE("/:id", clientFilterHandler.DeleteRule)
 				rules.PUT("/:id/toggle", clientFilterHandler.ToggleRule)
 			}
+
+			// 规则集（可在表达式中通过 ruleset("key") 引用）
+			ruleSets := clientFilter.Group("/rule-sets")
+			{
+				ruleSets.GET("", clientFilterHandler.ListRuleSets)
+				ruleSets.POST("", clientFilterHandler.CreateRuleSet)
+				ruleSets.GET("/:id", clientFilterHandler.GetRuleSet)
+				ruleSets.PUT("/:id", clientFilterHandler.UpdateRuleSet)
+				ruleSets.DELETE("/:id", clientFilterHandler.DeleteRuleSet)
+			}
 		}
 
 		// 错误规则管理
@@ -394,6 +510,7 @@ func RegisterRoutes(r *gin.Engine) {
 			errorRules.DELETE("/:id", errorRuleHandler.Delete)
 			errorRules.POST("/reset", errorRuleHandler.ResetToDefault)
 			errorRules.POST("/refresh", errorRuleHandler.RefreshCache)
+			errorRules.POST("/test", errorRuleHandler.Test)
 			errorRules.PUT("/enable-all", errorRuleHandler.EnableAll)
 			errorRules.PUT("/disable-all", errorRuleHandler.DisableAll)
 		}
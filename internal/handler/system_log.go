@@ -7,8 +7,10 @@
  *   - 日志文件下载
  *   - 日志文件删除
  *   - JSON日志格式解析
+ *   - 结构化日志索引查询（级别/模块/request_id/account_id/时间/关键字）
+ *   - 保留策略与外部投递状态
  * 重要程度：⭐⭐ 辅助（运维调试功能）
- * 依赖模块：无
+ * 依赖模块：logger
  */
 package handler
 
@@ -23,6 +25,7 @@ import (
 	"strings"
 	"time"
 
+	"cli-proxy/pkg/logger"
 	"cli-proxy/pkg/response"
 
 	"github.com/gin-gonic/gin"
@@ -663,3 +666,45 @@ func tailFile(filePath string, n int) ([]string, error) {
 
 	return lines[len(lines)-n:], nil
 }
+
+// Query 查询内存索引中的结构化日志（最新在前，按 before_seq 游标翻页）
+// GET /api/admin/system-logs/query?level=WARN,ERROR&module=&request_id=&account_id=&start_time=&end_time=&q=&before_seq=&limit=
+func (h *SystemLogHandler) Query(c *gin.Context) {
+	q := logger.Query{
+		Module:    c.Query("module"),
+		RequestID: c.Query("request_id"),
+		AccountID: c.Query("account_id"),
+		Text:      c.Query("q"),
+	}
+	if level := c.Query("level"); level != "" {
+		q.Levels = strings.Split(level, ",")
+	}
+	if start := c.Query("start_time"); start != "" {
+		if t, err := time.Parse(time.RFC3339, start); err == nil {
+			q.Since = t
+		}
+	}
+	if end := c.Query("end_time"); end != "" {
+		if t, err := time.Parse(time.RFC3339, end); err == nil {
+			q.Until = t
+		}
+	}
+	q.BeforeSeq, _ = strconv.ParseUint(c.Query("before_seq"), 10, 64)
+	q.Limit, _ = strconv.Atoi(c.DefaultQuery("limit", "100"))
+
+	response.Success(c, logger.QueryLogs(q))
+}
+
+// Stats 日志索引、保留策略和外部投递状态
+// GET /api/admin/system-logs/stats
+func (h *SystemLogHandler) Stats(c *gin.Context) {
+	defaultPolicy, modulePolicies := logger.GetRetention()
+	response.Success(c, gin.H{
+		"modules": logger.GetStore().Modules(),
+		"retention": gin.H{
+			"default": defaultPolicy,
+			"modules": modulePolicies,
+		},
+		"shipping": logger.GetShipStats(),
+	})
+}
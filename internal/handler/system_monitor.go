@@ -7,6 +7,7 @@
  *   - MySQL连接统计
  *   - 账号/API Key 数量统计
  *   - 今日使用量统计
+ *   - 账户池排队统计
  * 重要程度：⭐⭐⭐ 一般（运维监控功能）
  * 依赖模块：service
  */
@@ -118,3 +119,15 @@ func (h *SystemMonitorHandler) GetTodayUsageStats(c *gin.Context) {
 	stats := h.monitorService.GetTodayUsageStats(c.Request.Context())
 	response.Success(c, stats)
 }
+
+// GetQueueStats 获取账户池排队统计
+// @Summary 获取账户池排队统计
+// @Description 获取各账户池排队深度、各 API Key 排队数、平均/最长等待时间、超时和拒绝次数
+// @Tags 系统监控
+// @Produce json
+// @Success 200 {object} response.Response{data=scheduler.QueueStats}
+// @Router /api/admin/monitor/queue [get]
+func (h *SystemMonitorHandler) GetQueueStats(c *gin.Context) {
+	stats := h.monitorService.GetQueueStats()
+	response.Success(c, stats)
+}
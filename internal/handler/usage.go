@@ -154,6 +154,34 @@ func (h *UsageHandler) GetAPIKeyUsageSummary(c *gin.Context) {
 	})
 }
 
+// GetPromptCacheReport 获取各 API Key 的 Prompt Caching 命中率和节省费用
+func (h *UsageHandler) GetPromptCacheReport(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	startDate := c.Query("start_date")
+	endDate := c.Query("end_date")
+
+	if startDate == "" {
+		startDate = time.Now().AddDate(0, 0, -30).Format("2006-01-02")
+	}
+	if endDate == "" {
+		endDate = time.Now().Format("2006-01-02")
+	}
+	apiKeyID, _ := strconv.ParseUint(c.Query("api_key_id"), 10, 32)
+
+	reports, err := h.usageService.GetPromptCacheReport(ctx, h.pricingService, uint(apiKeyID), startDate, endDate)
+	if err != nil {
+		response.InternalError(c, err.Error())
+		return
+	}
+
+	response.Success(c, gin.H{
+		"start_date": startDate,
+		"end_date":   endDate,
+		"api_keys":   reports,
+	})
+}
+
 // GetAllUsageRecords 获取所有使用记录
 func (h *UsageHandler) GetAllUsageRecords(c *gin.Context) {
 	ctx := c.Request.Context()
@@ -0,0 +1,249 @@
+/*
+ * 文件作用：用量导出与周期报表处理器
+ * 负责功能：
+ *   - 用量数据流式导出（CSV/XLSX/JSON）
+ *   - 周期报表列表、详情、手动生成、下载
+ *   - 报表 Webhook 手动推送
+ * 重要程度：⭐⭐⭐ 一般（账单报表）
+ * 依赖模块：service, export, repository
+ */
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"cli-proxy/internal/export"
+	"cli-proxy/internal/model"
+	"cli-proxy/internal/repository"
+	"cli-proxy/internal/service"
+	"cli-proxy/pkg/logger"
+	"cli-proxy/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UsageReportHandler 用量导出与周期报表处理器
+type UsageReportHandler struct {
+	exportService *service.UsageExportService
+	reportService *service.UsageReportService
+}
+
+// NewUsageReportHandler 创建用量导出与周期报表处理器
+func NewUsageReportHandler() *UsageReportHandler {
+	return &UsageReportHandler{
+		exportService: service.NewUsageExportService(),
+		reportService: service.GetUsageReportService(),
+	}
+}
+
+// validDate 校验 YYYY-MM-DD 日期（空值视为有效）
+func validDate(date string) bool {
+	if date == "" {
+		return true
+	}
+	_, err := time.Parse("2006-01-02", date)
+	return err == nil
+}
+
+// ExportUsage 流式导出用量数据
+// GET /api/admin/usage/export?dataset=daily|records&format=csv|xlsx|json&api_key_id=&model=&platform=&start_date=&end_date=
+func (h *UsageReportHandler) ExportUsage(c *gin.Context) {
+	dataset := c.DefaultQuery("dataset", service.UsageDatasetDaily)
+	if dataset != service.UsageDatasetDaily && dataset != service.UsageDatasetRecords {
+		response.BadRequest(c, "dataset 只支持 daily 或 records")
+		return
+	}
+	format, err := export.ParseFormat(c.Query("format"))
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	apiKeyID, _ := strconv.ParseUint(c.Query("api_key_id"), 10, 32)
+	filter := repository.UsageExportFilter{
+		APIKeyID:  uint(apiKeyID),
+		Model:     c.Query("model"),
+		Platform:  c.Query("platform"),
+		StartDate: c.Query("start_date"),
+		EndDate:   c.Query("end_date"),
+	}
+	if !validDate(filter.StartDate) || !validDate(filter.EndDate) {
+		response.BadRequest(c, "日期格式应为 YYYY-MM-DD")
+		return
+	}
+
+	filename := fmt.Sprintf("usage-%s-%s.%s", dataset, time.Now().Format("20060102-150405"), format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+	c.Header("Content-Type", export.ContentType(format))
+	c.Status(http.StatusOK)
+
+	// 响应头已发出，导出中途出错时中断连接，避免客户端拿到看似完整的截断文件
+	if err := h.exportService.Export(c.Writer, dataset, format, filter); err != nil {
+		logger.GetLogger("usage_report").Error("用量导出失败: %v", err)
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// ListReports 报表列表
+// GET /api/admin/usage/reports?page=&page_size=&api_key_id=&period=
+func (h *UsageReportHandler) ListReports(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+	apiKeyID, _ := strconv.ParseUint(c.Query("api_key_id"), 10, 32)
+
+	reports, total, err := h.reportService.ListReports(page, pageSize, uint(apiKeyID), c.Query("period"))
+	if err != nil {
+		response.InternalError(c, err.Error())
+		return
+	}
+	response.SuccessWithPagination(c, reports, total, page, pageSize)
+}
+
+// GetReport 报表详情（含模型明细）
+// GET /api/admin/usage/reports/:id
+func (h *UsageReportHandler) GetReport(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "无效的报表 ID")
+		return
+	}
+	report, content, err := h.reportService.GetReport(uint(id))
+	if err != nil {
+		response.NotFound(c, "报表不存在")
+		return
+	}
+	response.Success(c, gin.H{
+		"report":  report,
+		"content": content,
+	})
+}
+
+// GenerateReportRequest 手动生成报表请求
+type GenerateReportRequest struct {
+	Period    string `json:"period" binding:"required"` // weekly/monthly/custom
+	StartDate string `json:"start_date"`                // custom 必填；weekly/monthly 为空时取上一个完整周期
+	EndDate   string `json:"end_date"`
+	APIKeyID  uint   `json:"api_key_id"` // 0 表示范围内有用量的所有 API Key
+	Deliver   bool   `json:"deliver"`    // 生成后立即推送 Webhook
+}
+
+// GenerateReports 手动生成报表（已存在则覆盖）
+// POST /api/admin/usage/reports/generate
+func (h *UsageReportHandler) GenerateReports(c *gin.Context) {
+	var req GenerateReportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	switch req.Period {
+	case model.ReportPeriodWeekly, model.ReportPeriodMonthly:
+		if req.StartDate == "" && req.EndDate == "" {
+			req.StartDate, req.EndDate, _ = service.ReportPeriodRange(req.Period, time.Now())
+		}
+	case model.ReportPeriodCustom:
+	default:
+		response.BadRequest(c, "period 只支持 weekly、monthly 或 custom")
+		return
+	}
+	if req.StartDate == "" || req.EndDate == "" || !validDate(req.StartDate) || !validDate(req.EndDate) || req.StartDate > req.EndDate {
+		response.BadRequest(c, "请提供有效的 start_date 和 end_date（YYYY-MM-DD）")
+		return
+	}
+
+	ctx := c.Request.Context()
+	result := gin.H{
+		"period":     req.Period,
+		"start_date": req.StartDate,
+		"end_date":   req.EndDate,
+	}
+	if req.APIKeyID > 0 {
+		report, err := h.reportService.Generate(ctx, req.APIKeyID, req.Period, req.StartDate, req.EndDate)
+		if err != nil {
+			response.BadRequest(c, err.Error())
+			return
+		}
+		result["generated"] = 1
+		result["reports"] = []*model.UsageReport{report}
+	} else {
+		generated, err := h.reportService.GenerateForPeriod(ctx, req.Period, req.StartDate, req.EndDate, false)
+		if err != nil {
+			response.InternalError(c, err.Error())
+			return
+		}
+		result["generated"] = generated
+	}
+
+	if req.Deliver {
+		go h.reportService.DeliverPending(context.Background())
+	}
+	response.Success(c, result)
+}
+
+// DownloadReport 下载报表
+// GET /api/admin/usage/reports/:id/download?format=json|csv|xlsx
+func (h *UsageReportHandler) DownloadReport(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "无效的报表 ID")
+		return
+	}
+	format, err := export.ParseFormat(c.DefaultQuery("format", export.FormatJSON))
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+	report, content, err := h.reportService.GetReport(uint(id))
+	if err != nil {
+		response.NotFound(c, "报表不存在")
+		return
+	}
+
+	filename := fmt.Sprintf("usage-report-%d-%s-%s.%s", report.APIKeyID, report.Period, report.PeriodStart, format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+	c.Header("Content-Type", export.ContentType(format))
+	c.Status(http.StatusOK)
+	if err := h.reportService.Render(c.Writer, report, content, format); err != nil {
+		logger.GetLogger("usage_report").Error("报表 %d 下载失败: %v", report.ID, err)
+	}
+}
+
+// DeliverReport 立即推送报表到 Webhook
+// POST /api/admin/usage/reports/:id/deliver
+func (h *UsageReportHandler) DeliverReport(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "无效的报表 ID")
+		return
+	}
+	if err := h.reportService.Deliver(c.Request.Context(), uint(id)); err != nil {
+		response.BadRequest(c, "推送失败: "+err.Error())
+		return
+	}
+	response.Success(c, gin.H{"message": "推送成功"})
+}
+
+// DeleteReport 删除报表
+// DELETE /api/admin/usage/reports/:id
+func (h *UsageReportHandler) DeleteReport(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "无效的报表 ID")
+		return
+	}
+	if err := h.reportService.DeleteReport(uint(id)); err != nil {
+		response.InternalError(c, err.Error())
+		return
+	}
+	response.Success(c, nil)
+}
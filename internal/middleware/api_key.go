@@ -4,6 +4,7 @@
  *   - API Key 解析（支持多种Header格式）
  *   - API Key 有效性验证
  *   - API Key 信息注入上下文
+ *   - API Key 并发限制（可排队等待）
  *   - 请求日志记录
  * 重要程度：⭐⭐⭐⭐⭐ 核心（代理认证核心）
  * 依赖模块：service, model
@@ -11,6 +12,7 @@
 package middleware
 
 import (
+	"context"
 	"strconv"
 	"strings"
 	"time"
@@ -29,6 +31,7 @@ func APIKeyAuth() gin.HandlerFunc {
 	configService := service.GetConfigService()
 	usageService := service.NewUsageService()
 	apiKeyRateLimiter := service.GetAPIKeyRateLimiter()
+	ipGuard := service.GetIPGuardService()
 	log := logger.GetLogger("auth")
 
 	return func(c *gin.Context) {
@@ -43,6 +46,7 @@ func APIKeyAuth() gin.HandlerFunc {
 
 		if apiKey == "" {
 			log.Debug("API Key 认证失败 | IP: %s | 原因: 缺少API Key", c.ClientIP())
+			ipGuard.RecordAuthFailure(c.ClientIP())
 			response.CustomUnauthorizedAbort(c, model.ErrorTypeAuthFailed, "缺少 API Key，请在 Authorization 或 x-api-key header 中提供")
 			return
 		}
@@ -58,10 +62,18 @@ func APIKeyAuth() gin.HandlerFunc {
 			log.Debug("API Key 认证失败 | IP: %s | Key: %s... | 原因: %v", c.ClientIP(), maskAPIKey(apiKey), err)
 			// 根据错误内容确定错误类型
 			errorType := getAPIKeyErrorType(err.Error())
+			ipGuard.RecordAuthFailure(c.ClientIP())
 			response.CustomUnauthorizedAbort(c, errorType, err.Error())
 			return
 		}
 
+		// 来源 IP/地区限制
+		if ok, reason := ipGuard.CheckAPIKeySource(key, c.ClientIP(), ClientCountry(c)); !ok {
+			log.Info("API Key 来源受限 | KeyID: %d | IP: %s | 原因: %s", key.ID, c.ClientIP(), reason)
+			response.CustomForbiddenAbort(c, model.ErrorTypeIPBlocked, reason)
+			return
+		}
+
 		// 限流/额度检查（跳过自助查询等非代理接口）
 		if shouldEnforceAPIKeyLimits(c) {
 			// 频率限制（每分钟请求数）
@@ -132,6 +144,34 @@ func shouldEnforceAPIKeyLimits(c *gin.Context) bool {
 	return true
 }
 
+// APIKeyConcurrency API Key 并发限制中间件（需在 APIKeyAuth 之后）
+// 并发已满时按 Key 配置排队等待，超时仍无空闲槽位则返回 429
+func APIKeyConcurrency() gin.HandlerFunc {
+	cacheService := service.NewCacheService()
+	log := logger.GetLogger("auth")
+
+	return func(c *gin.Context) {
+		key := GetAPIKey(c)
+		if key == nil || key.MaxConcurrency <= 0 || !shouldEnforceAPIKeyLimits(c) {
+			c.Next()
+			return
+		}
+
+		wait := time.Duration(key.ConcurrencyWaitMs) * time.Millisecond
+		acquired, current, _ := cacheService.AcquireAPIKeyConcurrency(c.Request.Context(), key.ID, key.MaxConcurrency, wait)
+		if !acquired {
+			log.Info("API Key 并发超限 | KeyID: %d | 当前: %d | 限制: %d", key.ID, current, key.MaxConcurrency)
+			c.Header("Retry-After", "1")
+			response.CustomTooManyRequestsAbort(c, model.ErrorTypeUserConcurrencyLimit, "并发请求过多，请稍后重试")
+			return
+		}
+		// 流式响应在 c.Next() 返回时已写完，此时释放槽位
+		defer cacheService.ReleaseAPIKeyConcurrency(context.Background(), key.ID)
+
+		c.Next()
+	}
+}
+
 // maskAPIKey 遮蔽API Key用于日志
 func maskAPIKey(key string) string {
 	if len(key) <= 8 {
@@ -0,0 +1,170 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"cli-proxy/internal/config"
+	"cli-proxy/internal/model"
+	"cli-proxy/internal/repository"
+	"cli-proxy/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	config.Cfg = &config.Config{}
+
+	dir, _ := os.MkdirTemp("", "middleware-test")
+	logger.Init(dir, logger.LevelWarn)
+
+	// 错误消息配置从数据库加载：用不连接数据库的 DryRun 实例，查询结果为空
+	repository.DB, _ = gorm.Open(mysql.New(mysql.Config{DSN: "test:test@tcp(127.0.0.1:0)/test", SkipInitializeWithVersion: true}), &gorm.Config{DryRun: true})
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// newConcurrencyTestRouter API Key 固定为 key，handler 为业务处理
+func newConcurrencyTestRouter(key *model.APIKey, handler gin.HandlerFunc) *gin.Engine {
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("api_key", key)
+		c.Next()
+	}, APIKeyConcurrency())
+	r.POST("/v1/messages", handler)
+	return r
+}
+
+func serveConcurrencyTest(r *gin.Engine, ctx context.Context) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", nil).WithContext(ctx)
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestAPIKeyConcurrencyRejectsWhenFull(t *testing.T) {
+	key := &model.APIKey{ID: 9001, MaxConcurrency: 1, ConcurrencyWaitMs: 30}
+	entered := make(chan struct{})
+	finish := make(chan struct{})
+	r := newConcurrencyTestRouter(key, func(c *gin.Context) {
+		if c.Query("block") == "" {
+			c.Status(http.StatusOK)
+			return
+		}
+		close(entered)
+		<-finish
+		c.Status(http.StatusOK)
+	})
+
+	done := make(chan int)
+	go func() {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/messages?block=1", nil))
+		done <- w.Code
+	}()
+	<-entered
+
+	// 排队等待超时后返回 429
+	start := time.Now()
+	w := serveConcurrencyTest(r, context.Background())
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "1" {
+		t.Fatalf("expected 429 with Retry-After, got %d %v", w.Code, w.Header())
+	}
+	if time.Since(start) < 30*time.Millisecond {
+		t.Fatal("expected request to wait for a slot before 429")
+	}
+
+	// 第一个请求结束后释放槽位
+	close(finish)
+	if code := <-done; code != http.StatusOK {
+		t.Fatalf("expected first request ok, got %d", code)
+	}
+	if w := serveConcurrencyTest(r, context.Background()); w.Code != http.StatusOK {
+		t.Fatalf("expected slot released, got %d", w.Code)
+	}
+}
+
+func TestAPIKeyConcurrencyWaitsForSlot(t *testing.T) {
+	key := &model.APIKey{ID: 9002, MaxConcurrency: 1, ConcurrencyWaitMs: 2000}
+	entered := make(chan struct{}, 1)
+	finish := make(chan struct{})
+	r := newConcurrencyTestRouter(key, func(c *gin.Context) {
+		entered <- struct{}{}
+		<-finish
+		c.Status(http.StatusOK)
+	})
+
+	done := make(chan int, 2)
+	for i := 0; i < 2; i++ {
+		go func() { done <- serveConcurrencyTest(r, context.Background()).Code }()
+	}
+	<-entered
+	select {
+	case <-entered:
+		t.Fatal("second request should queue while the slot is taken")
+	case <-time.After(30 * time.Millisecond):
+	}
+
+	// 释放后排队的请求获得槽位
+	finish <- struct{}{}
+	<-entered
+	finish <- struct{}{}
+	for i := 0; i < 2; i++ {
+		if code := <-done; code != http.StatusOK {
+			t.Fatalf("expected queued request ok, got %d", code)
+		}
+	}
+}
+
+func TestAPIKeyConcurrencyReleasesOnClientCancel(t *testing.T) {
+	key := &model.APIKey{ID: 9003, MaxConcurrency: 1}
+	entered := make(chan struct{}, 1)
+	r := newConcurrencyTestRouter(key, func(c *gin.Context) {
+		select {
+		case entered <- struct{}{}:
+		default:
+		}
+		if c.Query("wait") != "" {
+			<-c.Request.Context().Done()
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/messages?wait=1", nil).WithContext(ctx))
+		close(done)
+	}()
+	<-entered
+	if w := serveConcurrencyTest(r, context.Background()); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 while slot is held, got %d", w.Code)
+	}
+
+	// 客户端断开后处理结束，槽位随之释放
+	cancel()
+	<-done
+	if w := serveConcurrencyTest(r, context.Background()); w.Code != http.StatusOK {
+		t.Fatalf("expected slot released after client cancel, got %d", w.Code)
+	}
+}
+
+func TestAPIKeyConcurrencyUnlimited(t *testing.T) {
+	r := newConcurrencyTestRouter(&model.APIKey{ID: 9004}, func(c *gin.Context) { c.Status(http.StatusOK) })
+	for i := 0; i < 3; i++ {
+		if w := serveConcurrencyTest(r, context.Background()); w.Code != http.StatusOK {
+			t.Fatalf("expected unlimited key to pass, got %d", w.Code)
+		}
+	}
+}
@@ -4,7 +4,7 @@
  *   - 客户端类型识别（Claude Code/Cursor/Cline等）
  *   - 过滤规则匹配
  *   - 请求体解析（提取model字段）
- *   - 验证结果日志记录
+ *   - 验证结果日志记录（含影子模式未通过的规则）
  *   - API Key客户端限制检查
  * 重要程度：⭐⭐⭐⭐ 重要（安全过滤）
  * 依赖模块：service, model, cache
@@ -62,6 +62,7 @@ func ClientFilter() gin.HandlerFunc {
 			log.Warn("客户端验证失败 | IP: %s | 类型: %s | 原因: %s | UA: %s",
 				c.ClientIP(), result.ClientType, result.Details["reason"], reqCtx.UserAgent)
 		}
+		logShadowFailures(log, c, result)
 
 		// 将验证结果存储到 Context
 		c.Set("client_filter_result", result)
@@ -85,6 +86,12 @@ func buildRequestContext(c *gin.Context) *service.RequestContext {
 		Path:      c.Request.URL.Path,
 		Headers:   make(map[string]string),
 		Body:      make(map[string]interface{}),
+		Method:    c.Request.Method,
+		ClientIP:  c.ClientIP(),
+	}
+	if apiKey := GetAPIKey(c); apiKey != nil {
+		ctx.APIKeyID = apiKey.ID
+		ctx.APIKeyName = apiKey.Name
 	}
 
 	// 收集所有请求头
@@ -97,15 +104,13 @@ func buildRequestContext(c *gin.Context) *service.RequestContext {
 	// 解析请求体（仅 POST/PUT/PATCH）
 	if c.Request.Method == "POST" || c.Request.Method == "PUT" || c.Request.Method == "PATCH" {
 		if c.Request.ContentLength > utils.MaxRequestBodyBytes {
-			response.Error(c, http.StatusRequestEntityTooLarge, "请求体过大")
-			c.Abort()
+			response.CustomErrorAbort(c, http.StatusRequestEntityTooLarge, model.ErrorTypeBadRequest, "request body too large")
 			return ctx
 		}
 		bodyBytes, err := utils.ReadAllWithLimit(c.Request.Body, utils.MaxRequestBodyBytes)
 		if err != nil {
 			if err == utils.ErrBodyTooLarge {
-				response.Error(c, http.StatusRequestEntityTooLarge, "请求体过大")
-				c.Abort()
+				response.CustomErrorAbort(c, http.StatusRequestEntityTooLarge, model.ErrorTypeBadRequest, "request body too large")
 			}
 			return ctx
 		}
@@ -124,6 +129,14 @@ func buildRequestContext(c *gin.Context) *service.RequestContext {
 	return ctx
 }
 
+// logShadowFailures 记录影子模式下未通过的规则（不影响放行）
+func logShadowFailures(log *logger.Logger, c *gin.Context, result *service.ValidationResult) {
+	for _, rule := range result.ShadowFailures {
+		log.Info("影子规则未通过 | IP: %s | 类型: %s | 规则: %s (%s) | 原因: %s",
+			c.ClientIP(), result.ClientType, rule.RuleName, rule.RuleKey, rule.Message)
+	}
+}
+
 // buildErrorMessage 构建错误消息
 func buildErrorMessage(result *service.ValidationResult) string {
 	msg := "客户端验证失败"
@@ -186,6 +199,7 @@ func ClientFilterOptional() gin.HandlerFunc {
 			log.Info("客户端验证警告（非阻塞）| IP: %s | 类型: %s | 原因: %s",
 				c.ClientIP(), result.ClientType, result.Details["reason"])
 		}
+		logShadowFailures(log, c, result)
 
 		// 将验证结果存储到 Context
 		c.Set("client_filter_result", result)
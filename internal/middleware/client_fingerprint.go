@@ -0,0 +1,71 @@
+/*
+ * 文件作用：客户端指纹采集中间件
+ * 负责功能：
+ *   - 采集已认证请求的客户端类型、UA、操作系统、来源 IP
+ *   - 提交到客户端指纹分析服务（异步统计与异常检测）
+ * 重要程度：⭐⭐ 辅助（安全分析）
+ * 依赖模块：service
+ */
+package middleware
+
+import (
+	"strings"
+
+	"cli-proxy/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ClientFingerprint 客户端指纹采集中间件（放在客户端过滤之后，复用其识别结果）
+func ClientFingerprint() gin.HandlerFunc {
+	fingerprintService := service.GetClientFingerprintService()
+	configService := service.GetConfigService()
+	filterService := service.GetClientFilterService()
+
+	return func(c *gin.Context) {
+		apiKey := GetAPIKey(c)
+		if apiKey == nil || !configService.GetFingerprintEnabled() {
+			c.Next()
+			return
+		}
+
+		// 客户端过滤未启用时仅按请求头识别（不解析请求体，依赖请求体的识别表达式不会命中）
+		clientType := GetClientType(c)
+		if clientType == "" {
+			headers := make(map[string]string, len(c.Request.Header))
+			for key, values := range c.Request.Header {
+				if len(values) > 0 {
+					headers[strings.ToLower(key)] = values[0]
+				}
+			}
+			clientType = filterService.IdentifyClient(&service.RequestContext{
+				UserAgent:  c.GetHeader("User-Agent"),
+				Headers:    headers,
+				Path:       c.Request.URL.Path,
+				Method:     c.Request.Method,
+				ClientIP:   c.ClientIP(),
+				APIKeyID:   apiKey.ID,
+				APIKeyName: apiKey.Name,
+			})
+		}
+
+		fingerprintService.Observe(&service.FingerprintObservation{
+			APIKeyID:   apiKey.ID,
+			APIKeyName: apiKey.Name,
+			ClientType: clientType,
+			UserAgent:  c.GetHeader("User-Agent"),
+			OS:         requestOS(c),
+			IP:         c.ClientIP(),
+		})
+
+		c.Next()
+	}
+}
+
+// requestOS 从请求头获取客户端操作系统（Stainless SDK 头优先，其次 Client Hints）
+func requestOS(c *gin.Context) string {
+	if os := c.GetHeader("X-Stainless-Os"); os != "" {
+		return os
+	}
+	return strings.Trim(c.GetHeader("Sec-Ch-Ua-Platform"), `"`)
+}
@@ -0,0 +1,92 @@
+/*
+ * 文件作用：客户端真实 IP 与来源国家解析
+ * 负责功能：
+ *   - 配置 Gin 可信代理（只有可信代理转发的 X-Forwarded-For 等头部才被采信，防止伪造）
+ *   - 读取可信代理提供的国家代码头部
+ *   - 全局 IP 封禁拦截中间件
+ * 重要程度：⭐⭐⭐⭐ 重要（安全防护）
+ * 依赖模块：service, model
+ */
+package middleware
+
+import (
+	"net"
+	"strings"
+	"sync"
+
+	"cli-proxy/internal/model"
+	"cli-proxy/internal/service"
+	"cli-proxy/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	clientIPMu    sync.RWMutex
+	trustedNets   []*net.IPNet
+	countryHeader = "CF-IPCountry"
+)
+
+// ConfigureClientIP 配置可信代理、客户端 IP 头部和国家代码头部（启动时调用）
+func ConfigureClientIP(r *gin.Engine, trustedProxies, remoteIPHeaders []string, country string) error {
+	nets, err := service.ParseIPList(strings.Join(trustedProxies, ","))
+	if err != nil {
+		return err
+	}
+	if err := r.SetTrustedProxies(trustedProxies); err != nil {
+		return err
+	}
+	r.RemoteIPHeaders = remoteIPHeaders
+
+	clientIPMu.Lock()
+	defer clientIPMu.Unlock()
+	trustedNets = nets
+	if country != "" {
+		countryHeader = country
+	}
+	return nil
+}
+
+// isTrustedRemote 直连地址是否为可信代理
+func isTrustedRemote(c *gin.Context) bool {
+	ip := net.ParseIP(c.RemoteIP())
+	if ip == nil {
+		return false
+	}
+	clientIPMu.RLock()
+	defer clientIPMu.RUnlock()
+	for _, n := range trustedNets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientCountry 获取来源国家代码（仅采信可信代理提供的头部，未知时返回空）
+func ClientCountry(c *gin.Context) string {
+	if !isTrustedRemote(c) {
+		return ""
+	}
+	clientIPMu.RLock()
+	header := countryHeader
+	clientIPMu.RUnlock()
+	country := strings.ToUpper(strings.TrimSpace(c.GetHeader(header)))
+	// Cloudflare 对未知/Tor 来源使用 XX/T1
+	if country == "XX" || country == "T1" {
+		return ""
+	}
+	return country
+}
+
+// IPFilter 全局 IP 封禁拦截（放在 API Key 认证之前）
+func IPFilter() gin.HandlerFunc {
+	ipGuard := service.GetIPGuardService()
+	return func(c *gin.Context) {
+		if ipGuard.CheckBanned(c.ClientIP()) {
+			response.CustomForbiddenAbort(c, model.ErrorTypeIPBlocked, "IP 已被封禁")
+			return
+		}
+		c.Next()
+	}
+}
@@ -16,7 +16,6 @@ import (
 	"encoding/hex"
 	"io"
 	"net/http"
-	"strings"
 	"time"
 
 	"cli-proxy/pkg/logger"
@@ -51,28 +50,9 @@ func (w *responseBodyWriter) Write(b []byte) (int, error) {
 	return n, err
 }
 
-// getRealClientIP 获取真实客户端IP（支持代理）
+// getRealClientIP 获取真实客户端IP
+// 只有直连地址是可信代理时才采信 X-Forwarded-For 等头部（见 ConfigureClientIP），避免伪造
 func getRealClientIP(c *gin.Context) string {
-	// 优先检查 X-Forwarded-For
-	if xff := c.GetHeader("X-Forwarded-For"); xff != "" {
-		// X-Forwarded-For 可能包含多个IP，取第一个
-		ips := strings.Split(xff, ",")
-		if len(ips) > 0 {
-			return strings.TrimSpace(ips[0])
-		}
-	}
-
-	// 其次检查 X-Real-IP
-	if xri := c.GetHeader("X-Real-IP"); xri != "" {
-		return xri
-	}
-
-	// 检查 CF-Connecting-IP (Cloudflare)
-	if cfip := c.GetHeader("CF-Connecting-IP"); cfip != "" {
-		return cfip
-	}
-
-	// 最后使用 Gin 的 ClientIP
 	return c.ClientIP()
 }
 
@@ -14,6 +14,7 @@ package middleware
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"io"
 	"regexp"
 	"strconv"
@@ -76,6 +77,8 @@ func init() {
 		{regexp.MustCompile(`^/api/admin/accounts/(\d+)$`), model.ModuleAccount, model.ActionUpdate, getPathID, nil, getAccountNameByID, descUpdateAccount},
 		{regexp.MustCompile(`^/api/admin/accounts/(\d+)$`), model.ModuleAccount, model.ActionDelete, getPathID, nil, getAccountNameByID, descDeleteAccount},
 		{regexp.MustCompile(`^/api/admin/accounts/(\d+)/status$`), model.ModuleAccount, model.ActionUpdate, getPathID, nil, getAccountNameByID, descUpdateAccountStatus},
+		{regexp.MustCompile(`^/api/admin/accounts/import$`), model.ModuleAccount, model.ActionImport, nil, nil, nil, descImportAccounts},
+		{regexp.MustCompile(`^/api/admin/accounts/export$`), model.ModuleAccount, model.ActionExport, nil, nil, nil, descExportAccounts},
 
 		// 账户分组
 		{regexp.MustCompile(`^/api/admin/account-groups$`), model.ModuleGroup, model.ActionCreate, nil, getGroupName, nil, descCreateGroup},
@@ -89,6 +92,7 @@ func init() {
 		{regexp.MustCompile(`^/api/admin/api-keys/(\d+)$`), model.ModuleAPIKey, model.ActionUpdate, getPathID, nil, getAPIKeyNameByID, descUpdateAPIKey},
 		{regexp.MustCompile(`^/api/admin/api-keys/(\d+)$`), model.ModuleAPIKey, model.ActionDelete, getPathID, nil, getAPIKeyNameByID, descDeleteAPIKey},
 		{regexp.MustCompile(`^/api/admin/api-keys/(\d+)/toggle$`), model.ModuleAPIKey, model.ActionUpdate, getPathID, nil, getAPIKeyNameByID, descToggleAPIKey},
+		{regexp.MustCompile(`^/api/admin/api-keys/(\d+)/status$`), model.ModuleAPIKey, model.ActionUpdate, getPathID, nil, getAPIKeyNameByID, descSetAPIKeyStatus},
 
 		// 模型管理
 		{regexp.MustCompile(`^/api/admin/models$`), model.ModuleModel, model.ActionCreate, nil, getModelName, nil, descCreateModel},
@@ -101,12 +105,21 @@ func init() {
 		// 配置管理
 		{regexp.MustCompile(`^/api/admin/configs$`), model.ModuleConfig, model.ActionUpdate, nil, nil, nil, descUpdateConfig},
 		{regexp.MustCompile(`^/api/admin/configs/sync/trigger$`), model.ModuleConfig, model.ActionSync, nil, nil, nil, descTriggerSync},
+		{regexp.MustCompile(`^/api/admin/config-sync/apply$`), model.ModuleConfig, model.ActionImport, nil, nil, nil, descApplyConfigSync},
 		{regexp.MustCompile(`^/api/admin/cache/config$`), model.ModuleCache, model.ActionUpdate, nil, nil, nil, descUpdateCacheConfig},
 
+		// 周期用量报表
+		{regexp.MustCompile(`^/api/admin/usage/reports/generate$`), model.ModuleSystem, model.ActionCreate, nil, nil, nil, descGenerateUsageReports},
+		{regexp.MustCompile(`^/api/admin/usage/reports/(\d+)/deliver$`), model.ModuleSystem, model.ActionSync, getPathID, nil, nil, descDeliverUsageReport},
+		{regexp.MustCompile(`^/api/admin/usage/reports/(\d+)$`), model.ModuleSystem, model.ActionDelete, getPathID, nil, nil, descDeleteUsageReport},
+
 		// 缓存管理
+		{regexp.MustCompile(`^/api/admin/logs/(\d+)/replay$`), model.ModuleSystem, model.ActionTest, getPathID, nil, nil, descReplayRequestLog},
+		{regexp.MustCompile(`^/api/admin/logs/retention/run$`), model.ModuleSystem, model.ActionClear, nil, nil, nil, descRunLogRetention},
 		{regexp.MustCompile(`^/api/admin/cache/clear$`), model.ModuleCache, model.ActionClear, nil, nil, nil, descClearCache},
 		{regexp.MustCompile(`^/api/admin/cache/sessions/(.+)$`), model.ModuleCache, model.ActionDelete, nil, nil, nil, descRemoveSession},
 		{regexp.MustCompile(`^/api/admin/cache/api-keys/(\d+)$`), model.ModuleCache, model.ActionClear, getPathID, nil, getAPIKeyNameByID, descClearAPIKeyCache},
+		{regexp.MustCompile(`^/api/admin/cache/api-keys/(\d+)/concurrency$`), model.ModuleCache, model.ActionClear, getPathID, nil, getAPIKeyNameByID, descResetAPIKeyConcurrency},
 		{regexp.MustCompile(`^/api/admin/accounts/(\d+)/cache/sessions$`), model.ModuleCache, model.ActionClear, getPathID, nil, getAccountNameByID, descClearAccountSessions},
 		{regexp.MustCompile(`^/api/admin/accounts/(\d+)/cache/unavailable$`), model.ModuleCache, model.ActionUpdate, getPathID, nil, getAccountNameByID, descMarkAccountUnavailable},
 		{regexp.MustCompile(`^/api/admin/accounts/(\d+)/cache/concurrency$`), model.ModuleCache, model.ActionUpdate, getPathID, nil, getAccountNameByID, descSetConcurrency},
@@ -119,6 +132,15 @@ func init() {
 		{regexp.MustCompile(`^/api/admin/proxy-configs/(\d+)/default$`), model.ModuleProxy, model.ActionUpdate, getPathID, nil, getProxyNameByID, descSetDefaultProxy},
 		{regexp.MustCompile(`^/api/admin/proxy-configs/default$`), model.ModuleProxy, model.ActionDelete, nil, nil, nil, descClearDefaultProxy},
 		{regexp.MustCompile(`^/api/admin/proxy-configs/test$`), model.ModuleProxy, model.ActionTest, nil, nil, nil, descTestProxy},
+		{regexp.MustCompile(`^/api/admin/proxy-configs/(\d+)/egress$`), model.ModuleProxy, model.ActionTest, getPathID, nil, getProxyNameByID, descDiscoverProxyEgress},
+		{regexp.MustCompile(`^/api/admin/proxy-pools$`), model.ModuleProxy, model.ActionCreate, nil, getProxyName, nil, descCreateProxyPool},
+		{regexp.MustCompile(`^/api/admin/proxy-pools/(\d+)$`), model.ModuleProxy, model.ActionUpdate, getPathID, nil, getProxyPoolNameByID, descUpdateProxyPool},
+		{regexp.MustCompile(`^/api/admin/proxy-pools/(\d+)$`), model.ModuleProxy, model.ActionDelete, getPathID, nil, getProxyPoolNameByID, descDeleteProxyPool},
+		{regexp.MustCompile(`^/api/admin/proxy-pools/(\d+)/check$`), model.ModuleProxy, model.ActionTest, getPathID, nil, getProxyPoolNameByID, descCheckProxyPool},
+
+		// IP 封禁
+		{regexp.MustCompile(`^/api/admin/ip-bans$`), model.ModuleSystem, model.ActionCreate, nil, getIPBanCIDR, nil, descCreateIPBan},
+		{regexp.MustCompile(`^/api/admin/ip-bans/(\d+)$`), model.ModuleSystem, model.ActionDelete, getPathID, nil, nil, descRevokeIPBan},
 
 		// OAuth
 		{regexp.MustCompile(`^/api/admin/oauth/generate-url$`), model.ModuleAccount, model.ActionCreate, nil, nil, nil, descGenerateOAuthURL},
@@ -273,6 +295,18 @@ func getProxyNameByID(id uint) string {
 	return proxy.Name
 }
 
+func getProxyPoolNameByID(id uint) string {
+	if repository.DB == nil {
+		return ""
+	}
+	// 使用 Unscoped 包括软删除的记录
+	var pool model.ProxyPool
+	if err := repository.DB.Unscoped().First(&pool, id).Error; err != nil {
+		return ""
+	}
+	return pool.Name
+}
+
 // 描述函数
 func descLogin(c *gin.Context, body map[string]interface{}) string {
 	return "管理员登录"
@@ -285,6 +319,57 @@ func descCreateAccount(c *gin.Context, body map[string]interface{}) string {
 	return "创建账户"
 }
 
+func descImportAccounts(c *gin.Context, body map[string]interface{}) string {
+	if dryRun, ok := body["dry_run"].(bool); ok && dryRun {
+		return "批量导入账户（试运行）"
+	}
+	return "批量导入账户"
+}
+
+func descExportAccounts(c *gin.Context, body map[string]interface{}) string {
+	return "导出账户凭证包"
+}
+
+func descApplyConfigSync(c *gin.Context, body map[string]interface{}) string {
+	if c.Query("prune") == "true" {
+		return "应用声明式配置（删除多余条目）"
+	}
+	return "应用声明式配置"
+}
+
+func descGenerateUsageReports(c *gin.Context, body map[string]interface{}) string {
+	period, _ := body["period"].(string)
+	desc := "生成用量报表: " + period
+	if start, ok := body["start_date"].(string); ok && start != "" {
+		end, _ := body["end_date"].(string)
+		desc += " (" + start + " ~ " + end + ")"
+	}
+	return desc
+}
+
+func descDeliverUsageReport(c *gin.Context, body map[string]interface{}) string {
+	return "推送用量报表 #" + c.Param("id")
+}
+
+func descDeleteUsageReport(c *gin.Context, body map[string]interface{}) string {
+	return "删除用量报表 #" + c.Param("id")
+}
+
+func descReplayRequestLog(c *gin.Context, body map[string]interface{}) string {
+	desc := "重放请求日志 #" + c.Param("id")
+	if accountID, ok := body["account_id"].(float64); ok && accountID > 0 {
+		desc += fmt.Sprintf("（指定账户 #%d）", int(accountID))
+	}
+	if m, ok := body["model"].(string); ok && m != "" {
+		desc += "（模型 " + m + "）"
+	}
+	return desc
+}
+
+func descRunLogRetention(c *gin.Context, body map[string]interface{}) string {
+	return "执行请求日志清理"
+}
+
 func descUpdateAccount(c *gin.Context, body map[string]interface{}) string {
 	return "更新账户 #" + c.Param("id")
 }
@@ -343,6 +428,13 @@ func descToggleAPIKey(c *gin.Context, body map[string]interface{}) string {
 	return "切换 API Key #" + c.Param("id") + " 状态"
 }
 
+func descSetAPIKeyStatus(c *gin.Context, body map[string]interface{}) string {
+	if status, ok := body["status"].(string); ok {
+		return "设置 API Key #" + c.Param("id") + " 状态为 " + status
+	}
+	return "设置 API Key #" + c.Param("id") + " 状态"
+}
+
 func descCreateModel(c *gin.Context, body map[string]interface{}) string {
 	if name, ok := body["name"].(string); ok {
 		return "创建模型: " + name
@@ -397,6 +489,10 @@ func descClearAPIKeyCache(c *gin.Context, body map[string]interface{}) string {
 	return "清理 API Key #" + c.Param("id") + " 缓存"
 }
 
+func descResetAPIKeyConcurrency(c *gin.Context, body map[string]interface{}) string {
+	return "重置 API Key #" + c.Param("id") + " 并发计数"
+}
+
 func descClearAccountSessions(c *gin.Context, body map[string]interface{}) string {
 	return "清理账户 #" + c.Param("id") + " 会话"
 }
@@ -440,6 +536,29 @@ func descTestProxy(c *gin.Context, body map[string]interface{}) string {
 	return "测试代理连通性"
 }
 
+func descCreateProxyPool(c *gin.Context, body map[string]interface{}) string {
+	if name, ok := body["name"].(string); ok {
+		return "创建代理池: " + name
+	}
+	return "创建代理池"
+}
+
+func descUpdateProxyPool(c *gin.Context, body map[string]interface{}) string {
+	return "更新代理池 #" + c.Param("id")
+}
+
+func descDeleteProxyPool(c *gin.Context, body map[string]interface{}) string {
+	return "删除代理池 #" + c.Param("id")
+}
+
+func descCheckProxyPool(c *gin.Context, body map[string]interface{}) string {
+	return "检测代理池 #" + c.Param("id") + " 连通性"
+}
+
+func descDiscoverProxyEgress(c *gin.Context, body map[string]interface{}) string {
+	return "探测代理 #" + c.Param("id") + " 出口 IP"
+}
+
 func descGenerateOAuthURL(c *gin.Context, body map[string]interface{}) string {
 	if platform, ok := body["platform"].(string); ok {
 		return "生成 " + platform + " OAuth 授权链接"
@@ -461,8 +580,30 @@ func descCookieAuth(c *gin.Context, body map[string]interface{}) string {
 	return "Cookie 认证"
 }
 
+func getIPBanCIDR(c *gin.Context, body map[string]interface{}) string {
+	if cidr, ok := body["cidr"].(string); ok {
+		return cidr
+	}
+	return ""
+}
+
+func descCreateIPBan(c *gin.Context, body map[string]interface{}) string {
+	cidr, _ := body["cidr"].(string)
+	if minutes, ok := body["duration_minutes"].(float64); ok && minutes > 0 {
+		return fmt.Sprintf("封禁 IP: %s（%d 分钟）", cidr, int(minutes))
+	}
+	return fmt.Sprintf("永久封禁 IP: %s", cidr)
+}
+
+func descRevokeIPBan(c *gin.Context, body map[string]interface{}) string {
+	return fmt.Sprintf("解除 IP 封禁 #%d", getPathID(c))
+}
+
 // 敏感字段脱敏
-var sensitiveFields = []string{"password", "token", "secret", "api_key", "session_key", "access_token", "refresh_token"}
+var sensitiveFields = []string{"password", "passphrase", "token", "secret", "api_key", "session_key", "access_token", "refresh_token"}
+
+// 批量数据字段（如账户导入文件内容），只记录大小
+var bulkPayloadFields = map[string]bool{"data": true}
 
 func sanitizeBody(body map[string]interface{}) map[string]interface{} {
 	sanitized := make(map[string]interface{})
@@ -475,6 +616,10 @@ func sanitizeBody(body map[string]interface{}) map[string]interface{} {
 				break
 			}
 		}
+		if str, ok := v.(string); ok && bulkPayloadFields[lowerK] {
+			sanitized[k] = fmt.Sprintf("[%d bytes]", len(str))
+			continue
+		}
 		if isSensitive {
 			if str, ok := v.(string); ok {
 				if strings.Contains(lowerK, "password") {
@@ -535,7 +680,7 @@ func OperationLogger() gin.HandlerFunc {
 			m := &routeMappings[i]
 			if m.PathPattern.MatchString(path) {
 				// 检查方法是否匹配
-				if (method == "POST" && (m.Action == model.ActionCreate || m.Action == model.ActionLogin || m.Action == model.ActionSync || m.Action == model.ActionClear || m.Action == model.ActionTest)) ||
+				if (method == "POST" && (m.Action == model.ActionCreate || m.Action == model.ActionLogin || m.Action == model.ActionSync || m.Action == model.ActionClear || m.Action == model.ActionTest || m.Action == model.ActionImport || m.Action == model.ActionExport)) ||
 					(method == "PUT" && (m.Action == model.ActionUpdate || m.Action == model.ActionEnable || m.Action == model.ActionDisable)) ||
 					(method == "DELETE" && (m.Action == model.ActionDelete || m.Action == model.ActionClear)) {
 					mapping = m
@@ -4,6 +4,7 @@
  *   - 捕获panic
  *   - 记录错误堆栈
  *   - 返回500错误响应
+ *   - http.ErrAbortHandler 继续上抛，由 net/http 中断连接
  * 重要程度：⭐⭐⭐⭐ 重要（服务稳定性保障）
  * 依赖模块：logger
  */
@@ -24,6 +25,10 @@ func Recovery() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		defer func() {
 			if err := recover(); err != nil {
+				// 处理器主动中断响应（如流式导出中途出错），交给 net/http 直接断开连接
+				if err == http.ErrAbortHandler {
+					panic(err)
+				}
 				log.Error("PANIC | %v | Stack: %s", err, debug.Stack())
 				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
 					"code":    500,
@@ -0,0 +1,128 @@
+/*
+ * 文件作用：代理请求实时事件上报中间件
+ * 负责功能：
+ *   - 请求开始事件（由处理器解析出模型后调用 PublishRequestStart）
+ *   - 请求结束事件（状态码、Token、耗时、重试次数、账户）
+ * 重要程度：⭐⭐⭐ 一般（运维观测）
+ * 依赖模块：events, model, adapter
+ */
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"cli-proxy/internal/events"
+	"cli-proxy/internal/model"
+	"cli-proxy/internal/proxy/adapter"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Context 中的事件相关字段名
+const (
+	requestEventStartKey   = "request_event_start"
+	requestEventModelKey   = "request_event_model"
+	requestEventStreamKey  = "request_event_stream"
+	requestEventAccountKey = "request_event_account_id"
+	requestEventUsageKey   = "request_event_usage"
+	// RetryRequestCtxKey 处理器保存的重试请求（用于读取重试次数和账户）
+	RetryRequestCtxKey = "retry_request"
+)
+
+// retryStats 重试请求的执行统计
+type retryStats interface {
+	Stats() (attempts int, lastAccountID uint)
+}
+
+// RequestEvents 代理请求事件中间件（需放在 APIKeyAuth 之后）
+func RequestEvents() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodPost {
+			c.Next()
+			return
+		}
+		c.Set(requestEventStartKey, time.Now())
+		c.Next()
+		publishRequestFinish(c)
+	}
+}
+
+// PublishRequestStart 发布请求开始事件（处理器解析出模型后调用）
+func PublishRequestStart(c *gin.Context, modelName string, stream bool) {
+	c.Set(requestEventModelKey, modelName)
+	c.Set(requestEventStreamKey, stream)
+	e := baseRequestEvent(c)
+	e.Type = events.RequestStart
+	events.GetHub().Publish(e)
+}
+
+// SetRequestUsage 记录本次请求实际使用的账户和用量（结束事件使用）
+func SetRequestUsage(c *gin.Context, accountID uint, usage *adapter.StreamResult) {
+	c.Set(requestEventAccountKey, accountID)
+	if usage != nil {
+		c.Set(requestEventUsageKey, *usage)
+	}
+}
+
+// baseRequestEvent 构建事件公共字段
+func baseRequestEvent(c *gin.Context) events.RequestEvent {
+	e := events.RequestEvent{
+		RequestID: GetRequestID(c),
+		Method:    c.Request.Method,
+		Path:      c.Request.URL.Path,
+		ClientIP:  c.ClientIP(),
+		Model:     c.GetString(requestEventModelKey),
+		Stream:    c.GetBool(requestEventStreamKey),
+	}
+	if v, ok := c.Get("api_key"); ok {
+		if key, ok := v.(*model.APIKey); ok {
+			e.APIKeyID = key.ID
+			e.APIKeyName = key.Name
+		}
+	}
+	return e
+}
+
+// publishRequestFinish 发布请求结束事件
+func publishRequestFinish(c *gin.Context) {
+	e := baseRequestEvent(c)
+	e.Type = events.RequestFinish
+	e.Status = c.Writer.Status()
+	e.Success = e.Status < http.StatusBadRequest
+	e.CacheStatus = c.GetString("response_cache_status")
+	if start, ok := c.Get(requestEventStartKey); ok {
+		e.LatencyMs = time.Since(start.(time.Time)).Milliseconds()
+	}
+	if !e.Success {
+		if len(c.Errors) > 0 {
+			e.Error = c.Errors.Last().Error()
+		} else {
+			e.Error = http.StatusText(e.Status)
+		}
+	}
+
+	if v, ok := c.Get(RetryRequestCtxKey); ok {
+		if stats, ok := v.(retryStats); ok {
+			attempts, lastAccountID := stats.Stats()
+			if attempts > 1 {
+				e.Retries = attempts - 1
+			}
+			e.AccountID = lastAccountID
+		}
+	}
+	if v, ok := c.Get(requestEventAccountKey); ok {
+		if accountID, ok := v.(uint); ok && accountID > 0 {
+			e.AccountID = accountID
+		}
+	}
+	if v, ok := c.Get(requestEventUsageKey); ok {
+		if usage, ok := v.(adapter.StreamResult); ok {
+			e.InputTokens = usage.InputTokens
+			e.OutputTokens = usage.OutputTokens
+			e.CacheReadTokens = usage.CacheReadInputTokens
+			e.CacheCreationTokens = usage.CacheCreationInputTokens
+		}
+	}
+	events.GetHub().Publish(e)
+}
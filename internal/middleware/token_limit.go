@@ -0,0 +1,162 @@
+/*
+ * 文件作用：API Key 的 Token 限流中间件（TPM / ITPM / OTPM）
+ * 负责功能：
+ *   - 转发前估算请求输入 Token，按 API Key 的令牌桶检查并预扣
+ *   - 请求完成后按实际用量修正，失败时退回预扣
+ *   - 输出与 Anthropic / OpenAI SDK 兼容的 x-ratelimit-* / anthropic-ratelimit-* / retry-after 响应头
+ *   - 预估值写入上下文，供调度器按账户 Token 限额选号
+ * 重要程度：⭐⭐⭐⭐ 重要
+ * 依赖模块：ratelimit, service, model
+ */
+package middleware
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"cli-proxy/internal/model"
+	"cli-proxy/internal/proxy/adapter"
+	"cli-proxy/internal/proxy/scheduler"
+	"cli-proxy/internal/ratelimit"
+	"cli-proxy/internal/service"
+	"cli-proxy/pkg/logger"
+	"cli-proxy/pkg/response"
+	"cli-proxy/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TokenEstimateCtxKey 上下文中保存的请求 Token 预估（ratelimit.Usage）
+const TokenEstimateCtxKey = "token_estimate"
+
+// APIKeyTokenLimits API Key 的每分钟 Token 限额
+func APIKeyTokenLimits(key *model.APIKey) ratelimit.Limits {
+	return ratelimit.Limits{TPM: key.TPMLimit, ITPM: key.ITPMLimit, OTPM: key.OTPMLimit}
+}
+
+// GetTokenEstimate 获取请求 Token 预估
+func GetTokenEstimate(c *gin.Context) (ratelimit.Usage, bool) {
+	if v, ok := c.Get(TokenEstimateCtxKey); ok {
+		if estimate, ok := v.(ratelimit.Usage); ok {
+			return estimate, true
+		}
+	}
+	return ratelimit.Usage{}, false
+}
+
+// APIKeyTokenLimit API Key Token 限流中间件（需在 APIKeyAuth 之后、APIKeyConcurrency 之前）
+func APIKeyTokenLimit() gin.HandlerFunc {
+	limiter := ratelimit.Default()
+	requestLimiter := service.GetAPIKeyRateLimiter()
+	log := logger.GetLogger("auth")
+
+	return func(c *gin.Context) {
+		key := GetAPIKey(c)
+		if key == nil || c.Request.Method != http.MethodPost || !shouldEnforceAPIKeyLimits(c) {
+			c.Next()
+			return
+		}
+
+		bodyBytes, err := utils.ReadAllWithLimit(c.Request.Body, utils.MaxRequestBodyBytes)
+		if err != nil {
+			if err == utils.ErrBodyTooLarge {
+				response.CustomErrorAbort(c, http.StatusRequestEntityTooLarge, model.ErrorTypeBadRequest, "request body too large")
+				return
+			}
+			// 读取失败时请求体已被部分消费，不能继续交给后续处理器
+			response.CustomErrorAbort(c, http.StatusBadRequest, model.ErrorTypeInvalidRequest, "failed to read request body")
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
+
+		estimate := ratelimit.Usage{Input: ratelimit.EstimateInputTokens(bodyBytes)}
+		c.Set(TokenEstimateCtxKey, estimate)
+
+		limits := APIKeyTokenLimits(key)
+		bucketKey := ratelimit.APIKeyKey(key.ID)
+		decision := limiter.Reserve(bucketKey, limits, estimate)
+		setRateLimitHeaders(c, key, requestLimiter, decision.Status)
+
+		if !decision.Allowed {
+			waitSeconds := int(math.Ceil(decision.RetryAfter.Seconds()))
+			log.Info("API Key Token 超限 | KeyID: %d | 限额: %s | 预估输入: %d | 需等待: %v",
+				key.ID, decision.Exceeded, estimate.Input, decision.RetryAfter)
+			c.Header("Retry-After", strconv.Itoa(waitSeconds))
+			c.Header("Retry-After-Ms", strconv.FormatInt(decision.RetryAfter.Milliseconds(), 10))
+			response.CustomTooManyRequestsAbort(c, model.ErrorTypeRateLimit,
+				fmt.Sprintf("Token 用量超过每分钟限制（%s），请 %d 秒后再试", strings.ToUpper(decision.Exceeded), waitSeconds))
+			return
+		}
+
+		c.Next()
+
+		if !limits.Enabled() {
+			return
+		}
+		// 成功请求按实际用量修正；没有用量（失败）则退回预扣
+		if v, ok := c.Get(requestEventUsageKey); ok {
+			if result, ok := v.(adapter.StreamResult); ok {
+				limiter.Adjust(bucketKey, limits, estimate, scheduler.UsageFromResult(result))
+				return
+			}
+		}
+		limiter.Refund(bucketKey, limits, estimate)
+	}
+}
+
+// setRateLimitHeaders 写入限流状态响应头（Claude 接口使用 anthropic-ratelimit-*，其余使用 OpenAI 的 x-ratelimit-*）
+func setRateLimitHeaders(c *gin.Context, key *model.APIKey, requestLimiter *service.RateLimiter, status ratelimit.Status) {
+	requests := ratelimit.BucketStatus{}
+	if key.RateLimit > 0 {
+		remaining, resetSeconds := requestLimiter.Status("apikey:"+strconv.FormatUint(uint64(key.ID), 10), key.RateLimit, 1)
+		requests = ratelimit.BucketStatus{
+			Limit:     key.RateLimit,
+			Remaining: remaining,
+			Reset:     time.Duration(resetSeconds) * time.Second,
+		}
+	}
+
+	if strings.HasPrefix(c.Request.URL.Path, "/claude/") {
+		now := time.Now().UTC()
+		setAnthropicHeaders(c, "requests", requests, now)
+		setAnthropicHeaders(c, "tokens", status.Tokens, now)
+		setAnthropicHeaders(c, "input-tokens", status.InputTokens, now)
+		setAnthropicHeaders(c, "output-tokens", status.OutputTokens, now)
+		return
+	}
+
+	setOpenAIHeaders(c, "requests", requests)
+	// OpenAI 只有单一 tokens 维度：优先总 Token 限额，未设置时使用输入 Token 限额
+	tokens := status.Tokens
+	if tokens.Limit == 0 {
+		tokens = status.InputTokens
+	}
+	setOpenAIHeaders(c, "tokens", tokens)
+}
+
+// setAnthropicHeaders anthropic-ratelimit-<name>-limit/remaining/reset（reset 为 RFC 3339 时间）
+func setAnthropicHeaders(c *gin.Context, name string, s ratelimit.BucketStatus, now time.Time) {
+	if s.Limit <= 0 {
+		return
+	}
+	prefix := "anthropic-ratelimit-" + name
+	c.Header(prefix+"-limit", strconv.Itoa(s.Limit))
+	c.Header(prefix+"-remaining", strconv.Itoa(s.Remaining))
+	c.Header(prefix+"-reset", now.Add(s.Reset).Format(time.RFC3339))
+}
+
+// setOpenAIHeaders x-ratelimit-limit/remaining/reset-<name>（reset 为 Go duration 格式，如 6m0s）
+func setOpenAIHeaders(c *gin.Context, name string, s ratelimit.BucketStatus) {
+	if s.Limit <= 0 {
+		return
+	}
+	c.Header("x-ratelimit-limit-"+name, strconv.Itoa(s.Limit))
+	c.Header("x-ratelimit-remaining-"+name, strconv.Itoa(s.Remaining))
+	c.Header("x-ratelimit-reset-"+name, s.Reset.Round(time.Millisecond).String())
+}
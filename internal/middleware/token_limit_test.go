@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"cli-proxy/internal/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// failingReader 读取时总是返回错误
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) { return 0, errors.New("connection reset") }
+
+func TestAPIKeyTokenLimitAbortsOnBodyReadError(t *testing.T) {
+	key := &model.APIKey{ID: 9101, TPMLimit: 1000}
+	reached := false
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("api_key", key)
+		c.Next()
+	}, APIKeyTokenLimit())
+	handler := func(c *gin.Context) {
+		reached = true
+		c.Status(http.StatusOK)
+	}
+	r.POST("/claude/v1/messages", handler)
+	r.POST("/openai/v1/chat/completions", handler)
+
+	cases := []struct {
+		path  string
+		check func(body map[string]interface{}) bool
+	}{
+		{"/claude/v1/messages", func(body map[string]interface{}) bool {
+			errObj, _ := body["error"].(map[string]interface{})
+			return body["type"] == "error" && errObj["type"] == "invalid_request_error"
+		}},
+		{"/openai/v1/chat/completions", func(body map[string]interface{}) bool {
+			errObj, _ := body["error"].(map[string]interface{})
+			return errObj["type"] == "invalid_request_error" && errObj["code"] == model.ErrorTypeInvalidRequest
+		}},
+	}
+	for _, tc := range cases {
+		reached = false
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, tc.path, failingReader{}))
+
+		if reached {
+			t.Fatalf("%s: handler must not run after body read error", tc.path)
+		}
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", tc.path, w.Code)
+		}
+		var body map[string]interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || !tc.check(body) {
+			t.Fatalf("%s: unexpected error body %s", tc.path, w.Body.String())
+		}
+	}
+}
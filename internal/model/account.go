@@ -98,11 +98,19 @@ type Account struct {
 	// 通用配置
 	BaseURL        string  `gorm:"size:200" json:"base_url,omitempty"`        // 自定义 Base URL
 	ProxyID        *uint   `gorm:"index" json:"proxy_id,omitempty"`           // 关联的代理 ID
+	ProxyPoolID    *uint   `gorm:"index" json:"proxy_pool_id,omitempty"`      // 关联的代理池 ID（优先于 ProxyID）
 	ModelMapping   string  `gorm:"type:text" json:"model_mapping,omitempty"`  // 模型映射 JSON
 	AllowedModels  string  `gorm:"type:text" json:"allowed_models,omitempty"` // 允许的模型列表
 	MaxConcurrency int     `gorm:"default:5" json:"max_concurrency"`          // 最大并发数
+	TPMLimit       int     `gorm:"default:0" json:"tpm_limit"`                // 每分钟总 Token 限制（0=不限）
+	ITPMLimit      int     `gorm:"default:0" json:"itpm_limit"`               // 每分钟输入 Token 限制（0=不限）
+	OTPMLimit      int     `gorm:"default:0" json:"otpm_limit"`               // 每分钟输出 Token 限制（0=不限）
 	DailyBudget    float64 `gorm:"default:0" json:"daily_budget"`             // 每日预算（美元），0 表示不限制
 
+	// 成本模型（用于盈利分析）
+	MonthlyCost float64 `gorm:"default:0" json:"monthly_cost"` // 固定月成本（美元，如订阅费），按天分摊
+	CostRate    float64 `gorm:"default:0" json:"cost_rate"`    // 按量成本系数（相对模型官方定价，API 账号通常为 1，订阅账号为 0）
+
 	// 关联对象
 	Gateway *Gateway `gorm:"foreignKey:GatewayID" json:"gateway,omitempty"` // 网关配置
 	Proxy   *Proxy   `gorm:"foreignKey:ProxyID" json:"proxy,omitempty"`     // 代理配置
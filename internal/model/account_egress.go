@@ -0,0 +1,45 @@
+/*
+ * 文件作用：账户上游出口 IP 数据模型
+ * 负责功能：
+ *   - 账户最近一次使用的出口 IP、国家/地区和代理
+ *   - 账户出口 IP 变更记录（是否跨地区）
+ * 重要程度：⭐⭐⭐ 一般（账户风控）
+ * 依赖模块：无
+ */
+package model
+
+import "time"
+
+// AccountEgress 账户当前出口 IP（每个账户一行）
+type AccountEgress struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	AccountID   uint      `gorm:"uniqueIndex" json:"account_id"`
+	IP          string    `gorm:"size:64" json:"ip"`
+	Country     string    `gorm:"size:8" json:"country"`         // 国家/地区代码（探测服务未返回时为空）
+	ProxyID     uint      `gorm:"default:0" json:"proxy_id"`     // 使用的代理 ID（0 表示直连）
+	ChangeCount int       `gorm:"default:0" json:"change_count"` // 出口 IP 累计变更次数
+	FirstSeenAt time.Time `json:"first_seen_at"`                 // 当前出口 IP 首次出现时间
+	LastSeenAt  time.Time `gorm:"index" json:"last_seen_at"`     // 当前出口 IP 最近出现时间
+}
+
+func (AccountEgress) TableName() string {
+	return "account_egresses"
+}
+
+// AccountEgressChange 账户出口 IP 变更记录
+type AccountEgressChange struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	AccountID   uint      `gorm:"index" json:"account_id"`
+	OldIP       string    `gorm:"size:64" json:"old_ip"`
+	OldCountry  string    `gorm:"size:8" json:"old_country"`
+	OldProxyID  uint      `json:"old_proxy_id"`
+	NewIP       string    `gorm:"size:64" json:"new_ip"`
+	NewCountry  string    `gorm:"size:8" json:"new_country"`
+	NewProxyID  uint      `json:"new_proxy_id"`
+	CrossRegion bool      `gorm:"index" json:"cross_region"` // 国家/地区是否变化
+	CreatedAt   time.Time `gorm:"index" json:"created_at"`
+}
+
+func (AccountEgressChange) TableName() string {
+	return "account_egress_changes"
+}
@@ -3,8 +3,9 @@
  * 负责功能：
  *   - API Key基础信息（名称、状态、描述）
  *   - Key哈希存储
- *   - 权限控制（平台、模型、客户端）
+ *   - 权限控制（平台、模型、客户端、来源 IP/国家）
  *   - 限制配置（频率、每日限制、月额度）
+ *   - 对冲请求配置（延迟敏感场景）
  *   - Key生成和验证方法
  * 重要程度：⭐⭐⭐⭐ 重要（核心数据结构）
  * 依赖模块：gorm
@@ -22,6 +23,8 @@ import (
 
 const (
 	APIKeyPrefix = "sk-" // API Key 前缀
+
+	APIKeyStatusSuspended = "suspended" // 客户端指纹异常被自动暂停（管理员确认后手动恢复）
 )
 
 // APIKey API 密钥模型
@@ -38,12 +41,40 @@ type APIKey struct {
 	AllowedModels    string `gorm:"type:text" json:"allowed_models,omitempty"`     // 允许的模型列表 (逗号分隔)
 	BlockedModels    string `gorm:"type:text" json:"blocked_models,omitempty"`     // 禁止的模型列表 (逗号分隔)
 	AllowedClients   string `gorm:"size:200" json:"allowed_clients,omitempty"`     // 允许的客户端类型 (逗号分隔, 如: claude_code,codex_cli)
+	AllowedIPs       string `gorm:"type:text" json:"allowed_ips,omitempty"`        // 允许的来源 IP/CIDR (逗号或换行分隔，为空不限制)
+	AllowedCountries string `gorm:"size:200" json:"allowed_countries,omitempty"`   // 允许的来源国家代码 (逗号分隔, 如: CN,HK)
+	BlockedCountries string `gorm:"size:200" json:"blocked_countries,omitempty"`   // 禁止的来源国家代码 (逗号分隔)
 
 	// 限制配置
 	RateLimit     int        `gorm:"default:0" json:"rate_limit"`                // 每分钟请求限制（0=不限）
 	DailyLimit    int        `gorm:"default:0" json:"daily_limit"`               // 每日请求限制 (0=不限)
 	MonthlyQuota  float64    `gorm:"type:decimal(10,2);default:0" json:"monthly_quota"` // 月额度 (美元，0=不限)
 	ExpiresAt     *time.Time `json:"expires_at,omitempty"`                       // 过期时间
+	MaxConcurrency    int `gorm:"default:0" json:"max_concurrency"`     // 最大并发请求数（0=不限）
+	ConcurrencyWaitMs int `gorm:"default:0" json:"concurrency_wait_ms"` // 并发已满时排队等待时长（毫秒，0=立即返回 429）
+
+	// Token 限流（令牌桶，按每分钟 Token 数）
+	TPMLimit  int `gorm:"default:0" json:"tpm_limit"`  // 每分钟总 Token 限制（0=不限）
+	ITPMLimit int `gorm:"default:0" json:"itpm_limit"` // 每分钟输入 Token 限制（0=不限）
+	OTPMLimit int `gorm:"default:0" json:"otpm_limit"` // 每分钟输出 Token 限制（0=不限）
+
+	// 错误提示语言（为空时按请求的 Accept-Language 选择，如: en, zh-CN, ja）
+	PreferredLocale string `gorm:"size:20" json:"preferred_locale"`
+
+	// 账户池排队（所有账户并发已满时）
+	QueuePriority int `gorm:"default:0" json:"queue_priority"` // 排队优先级（越大越先出队）
+	QueueWeight   int `gorm:"default:1" json:"queue_weight"`   // 同优先级内的排队权重（轮询时每轮可出队的请求数）
+
+	// 延迟优化
+	HedgeDelayMs int `gorm:"default:0" json:"hedge_delay_ms"` // 对冲请求延迟（毫秒，首个账户超时未响应时并行请求另一账户，0=关闭）
+	StreamResume bool `gorm:"default:false" json:"stream_resume"` // 流式中断续传（中途失败时转到其他账户续写）
+
+	// 成本优化
+	PromptCacheOptimize bool `gorm:"default:false" json:"prompt_cache_optimize"` // 自动为 Claude 请求插入 cache_control 断点
+
+	// 隐私保护
+	NoLogBodies     bool `gorm:"default:false" json:"no_log_bodies"`     // 不记录请求/响应体（仅保留元数据）
+	MaskRequestBody bool `gorm:"default:false" json:"mask_request_body"` // 转发上游前按脱敏规则遮蔽请求内容
 
 	// 统计字段
 	RequestCount   int64      `gorm:"default:0" json:"request_count"`            // 总请求次数
@@ -2,7 +2,9 @@
  * 文件作用：客户端过滤数据模型，定义客户端识别和过滤规则
  * 负责功能：
  *   - 客户端类型定义（Claude Code、Codex、Gemini等）
- *   - 过滤规则配置（UA、Header、Body检查）
+ *   - 过滤规则配置（UA、Header、Body检查、表达式）
+ *   - 规则执行模式（拦截 / 影子模式仅记录）
+ *   - 可复用规则集（命名表达式）
  *   - 全局过滤配置
  *   - 预定义规则模板
  * 重要程度：⭐⭐⭐⭐ 重要（安全过滤数据结构）
@@ -21,6 +23,7 @@ type ClientType struct {
 	Icon        string    `gorm:"size:10" json:"icon"`                            // 图标 emoji
 	Enabled     bool      `gorm:"default:true" json:"enabled"`                    // 是否启用
 	Priority    int       `gorm:"default:0" json:"priority"`                      // 优先级（用于排序）
+	MatchExpression string `gorm:"type:text" json:"match_expression"`            // 识别表达式（为空时按 user_agent 规则识别）
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 }
@@ -36,6 +39,8 @@ type ClientFilterRule struct {
 	RuleType     string    `gorm:"size:20;not null" json:"rule_type"`             // 规则类型: header, body, user_agent, path
 	Pattern      string    `gorm:"size:500" json:"pattern"`                       // 匹配模式（正则或固定值）
 	FieldPath    string    `gorm:"size:200" json:"field_path"`                    // 字段路径（如 headers.x-app, body.metadata.user_id）
+	Expression   string    `gorm:"type:text" json:"expression"`                   // 过滤表达式（rule_type=expression 时使用）
+	Mode         string    `gorm:"size:20;default:enforce" json:"mode"`           // 执行模式: enforce=拦截, shadow=仅记录不拦截
 	Enabled      bool      `gorm:"default:true" json:"enabled"`                   // 是否启用此规则
 	Required     bool      `gorm:"default:true" json:"required"`                  // 是否必须通过（false=警告但不拦截）
 	Priority     int       `gorm:"default:0" json:"priority"`                     // 规则优先级
@@ -82,8 +87,27 @@ const (
 	RuleTypeBody      = "body"         // 请求体检查
 	RuleTypePath      = "path"         // 路径检查
 	RuleTypeCustom    = "custom"       // 自定义检查（需要特殊处理）
+	RuleTypeExpression = "expression"  // 表达式检查（见 filterexpr 语法）
 )
 
+// 规则执行模式
+const (
+	RuleModeEnforce = "enforce" // 未通过时按 Required 拦截或警告
+	RuleModeShadow  = "shadow"  // 影子模式：只记录统计与日志，不拦截
+)
+
+// ClientRuleSet 可复用规则集（命名表达式，规则和识别表达式中通过 ruleset("key") 引用）
+type ClientRuleSet struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	SetKey      string    `gorm:"size:50;uniqueIndex;not null" json:"set_key"` // 规则集标识，如 codex_cli
+	Name        string    `gorm:"size:100;not null" json:"name"`               // 显示名称
+	Description string    `gorm:"size:500" json:"description"`                 // 描述
+	Expression  string    `gorm:"type:text;not null" json:"expression"`        // 表达式
+	Enabled     bool      `gorm:"default:true" json:"enabled"`                 // 是否启用（禁用后引用结果为 false）
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
 // 预定义的规则标识
 const (
 	// Claude Code 规则
@@ -307,3 +331,48 @@ var DefaultGeminiCLIRules = []ClientFilterRule{
 		Priority:    90,
 	},
 }
+
+// DefaultClientRuleSets 默认规则集（与内置规则等价，可在表达式规则中组合使用）
+var DefaultClientRuleSets = []ClientRuleSet{
+	{
+		SetKey:      "claude_code_simple",
+		Name:        "Claude Code（简单模式）",
+		Description: "宽松 UA + x-app / anthropic-version / anthropic-beta 头 + 系统提示词相似度 + metadata.user_id 格式",
+		Expression: `user_agent.matches("^claude-cli/\\d+\\.\\d+\\.\\d+") && has(headers["x-app"]) && has(headers["anthropic-version"]) ` +
+			`&& has(headers["anthropic-beta"]) && claudeCodeSystemPrompt(body.system) ` +
+			`&& body.metadata.user_id.matches("^user_[a-fA-F0-9]{64}_account__session_[\\w-]+$")`,
+		Enabled: true,
+	},
+	{
+		SetKey:      "claude_code_strict",
+		Name:        "Claude Code（严格模式）",
+		Description: "完整 UA 格式 + x-app / anthropic-version / x-stainless-os 头 + 系统提示词相似度 + metadata.user_id 格式",
+		Expression: `user_agent.matches("^claude-cli/(\\d+\\.\\d+\\.\\d+)\\s*\\(external,\\s*(cli|claude-vscode|sdk-ts|sdk-cli)(?:,\\s*agent-sdk/[\\w.\\-]+)?\\)$") ` +
+			`&& has(headers["x-app"]) && has(headers["anthropic-version"]) && has(headers["x-stainless-os"]) ` +
+			`&& claudeCodeSystemPrompt(body.system) && body.metadata.user_id.matches("^user_[a-fA-F0-9]{64}_account__session_[\\w-]+$")`,
+		Enabled: true,
+	},
+	{
+		SetKey:      "codex_cli",
+		Name:        "Codex CLI",
+		Description: "codex_vscode / codex_cli_rs UA，originator 与 UA 一致，session_id 长度 > 20，instructions 前缀",
+		Expression: `user_agent.matches("^(codex_vscode|codex_cli_rs)/[\\d.]+") && headers["originator"].matches("^(codex_vscode|codex_cli_rs)$") ` +
+			`&& user_agent.startsWith(headers["originator"]) && size(headers["session_id"]) > 20 ` +
+			`&& body.instructions.startsWith("You are Codex")`,
+		Enabled: true,
+	},
+	{
+		SetKey:      "gemini_cli",
+		Name:        "Gemini CLI",
+		Description: "GeminiCLI UA 且请求 /gemini 路径",
+		Expression:  `user_agent.matches("^GeminiCLI/v?[\\d.]+") && path.startsWith("/gemini")`,
+		Enabled:     true,
+	},
+	{
+		SetKey:      "cursor",
+		Name:        "Cursor",
+		Description: "Cursor IDE（按 UA 识别）",
+		Expression:  `user_agent.matches("(?i)^cursor/")`,
+		Enabled:     true,
+	},
+}
@@ -0,0 +1,89 @@
+/*
+ * 文件作用：客户端指纹统计与异常告警数据模型
+ * 负责功能：
+ *   - 按 API Key、小时聚合的客户端类型 / UA 版本 / 操作系统 / IP 请求数
+ *   - API Key 已知客户端指纹（首次/最近出现时间、请求数）
+ *   - 指纹异常告警（新客户端指纹、IP 过多、疑似共享或泄露）
+ * 重要程度：⭐⭐⭐ 一般（安全分析）
+ * 依赖模块：无
+ */
+package model
+
+import "time"
+
+// 指纹统计维度
+const (
+	FingerprintDimClientType = "client_type" // 识别出的客户端类型
+	FingerprintDimUserAgent  = "user_agent"  // UA 产品/版本（如 claude-cli/1.0.33）
+	FingerprintDimOS         = "os"          // 操作系统（X-Stainless-Os 等请求头）
+	FingerprintDimIP         = "ip"          // 来源 IP
+)
+
+// FingerprintDimensions 所有统计维度
+var FingerprintDimensions = []string{FingerprintDimClientType, FingerprintDimUserAgent, FingerprintDimOS, FingerprintDimIP}
+
+// 指纹告警类型
+const (
+	FingerprintAlertNewClient = "new_fingerprint" // Key 出现新的客户端指纹
+	FingerprintAlertManyIPs   = "many_ips"        // 时间窗口内来源 IP 过多
+	FingerprintAlertSharedKey = "shared_key"      // 时间窗口内多个客户端指纹同时活跃，疑似共享或泄露
+)
+
+// 指纹告警级别
+const (
+	FingerprintSeverityWarning  = "warning"
+	FingerprintSeverityCritical = "critical" // 开启自动暂停时会暂停 Key
+)
+
+// ClientFingerprintStat 客户端指纹小时统计（每个 Key、小时、维度、取值一行）
+type ClientFingerprintStat struct {
+	ID           uint      `gorm:"primarykey" json:"id"`
+	APIKeyID     uint      `gorm:"uniqueIndex:idx_fp_stat,priority:1" json:"api_key_id"`
+	Bucket       time.Time `gorm:"uniqueIndex:idx_fp_stat,priority:2;index" json:"bucket"`      // 小时起点
+	Dimension    string    `gorm:"size:20;uniqueIndex:idx_fp_stat,priority:3" json:"dimension"` // client_type/user_agent/os/ip
+	Value        string    `gorm:"size:191;uniqueIndex:idx_fp_stat,priority:4" json:"value"`
+	RequestCount int64     `gorm:"default:0" json:"request_count"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+func (ClientFingerprintStat) TableName() string {
+	return "client_fingerprint_stats"
+}
+
+// APIKeyFingerprint API Key 已知客户端指纹（客户端类型 + UA 版本 + 操作系统）
+type APIKeyFingerprint struct {
+	ID           uint      `gorm:"primarykey" json:"id"`
+	APIKeyID     uint      `gorm:"uniqueIndex:idx_key_fingerprint,priority:1" json:"api_key_id"`
+	Fingerprint  string    `gorm:"size:32;uniqueIndex:idx_key_fingerprint,priority:2" json:"fingerprint"`
+	ClientType   string    `gorm:"size:50" json:"client_type"`
+	UserAgent    string    `gorm:"size:191" json:"user_agent"` // UA 产品/版本
+	OS           string    `gorm:"column:os;size:50" json:"os"`
+	FirstIP      string    `gorm:"size:64" json:"first_ip"`
+	LastIP       string    `gorm:"size:64" json:"last_ip"`
+	RequestCount int64     `gorm:"default:0" json:"request_count"`
+	FirstSeenAt  time.Time `json:"first_seen_at"`
+	LastSeenAt   time.Time `gorm:"index" json:"last_seen_at"`
+}
+
+func (APIKeyFingerprint) TableName() string {
+	return "api_key_fingerprints"
+}
+
+// FingerprintAlert 客户端指纹异常告警
+type FingerprintAlert struct {
+	ID             uint       `gorm:"primarykey" json:"id"`
+	APIKeyID       uint       `gorm:"index" json:"api_key_id"`
+	APIKeyName     string     `gorm:"size:100" json:"api_key_name"`
+	AlertType      string     `gorm:"size:30;index" json:"alert_type"` // new_fingerprint/many_ips/shared_key
+	Severity       string     `gorm:"size:20" json:"severity"`         // warning/critical
+	Message        string     `gorm:"size:500" json:"message"`
+	Detail         string     `gorm:"type:text" json:"detail,omitempty"` // JSON：触发时的指纹、IP 列表等
+	Suspended      bool       `gorm:"default:false" json:"suspended"`    // 是否因此自动暂停了 Key
+	AcknowledgedAt *time.Time `json:"acknowledged_at,omitempty"`
+	AcknowledgedBy string     `gorm:"size:100" json:"acknowledged_by,omitempty"`
+	CreatedAt      time.Time  `gorm:"index" json:"created_at"`
+}
+
+func (FingerprintAlert) TableName() string {
+	return "fingerprint_alerts"
+}
@@ -72,3 +72,13 @@ type APIKeyUsageSummary struct {
 	TotalTokens   int64   `json:"total_tokens"`
 	TotalCost     float64 `json:"total_cost"`
 }
+
+// PromptCacheUsage Prompt Caching 使用汇总（按 API Key + 模型）
+type PromptCacheUsage struct {
+	APIKeyID                 uint   `json:"api_key_id"`
+	Model                    string `json:"model"`
+	RequestCount             int64  `json:"request_count"`
+	InputTokens              int64  `json:"input_tokens"`
+	CacheCreationInputTokens int64  `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int64  `json:"cache_read_input_tokens"`
+}
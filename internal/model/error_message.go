@@ -5,19 +5,25 @@
  *   - 自定义错误消息配置
  *   - 默认错误消息模板
  *   - 原始/自定义消息映射
+ *   - 多语言消息（按语言标签选择）与模板占位符
  * 重要程度：⭐⭐⭐ 一般（错误消息数据结构）
  * 依赖模块：无
  */
 package model
 
-import "time"
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
 
 // ErrorMessage 错误消息自定义配置
 type ErrorMessage struct {
 	ID              uint      `gorm:"primarykey" json:"id"`
 	Code            int       `gorm:"not null;index" json:"code"`                     // HTTP 状态码: 400/401/403/429/500/502/503
 	ErrorType       string    `gorm:"size:50;uniqueIndex;not null" json:"error_type"` // 错误类型标识
-	CustomMessage   string    `gorm:"size:500;not null" json:"custom_message"`        // 自定义返回消息
+	CustomMessage   string    `gorm:"size:500;not null" json:"custom_message"`        // 自定义返回消息（默认语言，支持模板占位符）
+	Translations    string    `gorm:"type:text" json:"translations"`                  // 多语言消息 JSON，如 {"en":"...","ja":"..."}
 	OriginalMessage string    `gorm:"-" json:"original_message"`                      // 原始默认消息（不存数据库）
 	Enabled         bool      `gorm:"default:true" json:"enabled"`                    // 是否启用自定义消息
 	Description     string    `gorm:"size:200" json:"description"`                    // 说明（给管理员看）
@@ -185,3 +191,82 @@ func GetOriginalMessage(errorType string) string {
 func (m *ErrorMessage) FillOriginalMessage() {
 	m.OriginalMessage = GetOriginalMessage(m.ErrorType)
 }
+
+// ErrorMessageTemplateFields 自定义消息可用的模板占位符（Go template 语法，如 {{.RetryAfter}}）
+var ErrorMessageTemplateFields = map[string]string{
+	"RetryAfter":     "建议重试等待秒数（来自 Retry-After，未知时为 0）",
+	"QuotaRemaining": "剩余额度（请求数或 Token 数，未知时为空）",
+	"Model":          "请求的模型名称",
+	"RequestID":      "请求 ID",
+	"Status":         "HTTP 状态码",
+	"ErrorType":      "错误类型标识",
+}
+
+// DefaultErrorTranslations 默认错误消息的多语言版本（key: 错误类型 -> 语言 -> 消息）
+var DefaultErrorTranslations = map[string]map[string]string{
+	ErrorTypeBadRequest:           {"en": "Invalid request parameters"},
+	ErrorTypeInvalidModel:         {"en": "Invalid model name: {{.Model}}"},
+	ErrorTypeInvalidRequest:       {"en": "Malformed request body"},
+	ErrorTypeAuthFailed:           {"en": "Authentication failed"},
+	ErrorTypeKeyDisabled:          {"en": "This API key has been disabled"},
+	ErrorTypeKeyExpired:           {"en": "This API key has expired"},
+	ErrorTypeKeyInvalid:           {"en": "Invalid API key"},
+	ErrorTypeForbidden:            {"en": "Access denied"},
+	ErrorTypeClientNotAllowed:     {"en": "This client is not allowed"},
+	ErrorTypePlatformForbid:       {"en": "Access to this platform is not allowed"},
+	ErrorTypeModelForbidden:       {"en": "Access to model {{.Model}} is not allowed"},
+	ErrorTypePackageExpired:       {"en": "Your subscription has expired, please renew"},
+	ErrorTypeQuotaExceeded:        {"en": "Quota exhausted"},
+	ErrorTypeDailyLimit:           {"en": "Daily limit reached, please try again tomorrow"},
+	ErrorTypeMonthlyQuota:         {"en": "Monthly quota reached, please try again next month"},
+	ErrorTypeIPBlocked:            {"en": "Access denied"},
+	ErrorTypeRateLimit:            {"en": "Too many requests, please retry in {{.RetryAfter}} seconds"},
+	ErrorTypeUserConcurrencyLimit: {"en": "Too many concurrent requests, please retry later"},
+	ErrorTypeAccountConcurrency:   {"en": "The service is busy, please retry later"},
+	ErrorTypeInternalError:        {"en": "Internal server error (request {{.RequestID}})"},
+	ErrorTypeUpstreamError:        {"en": "Upstream service is temporarily unavailable"},
+	ErrorTypeUpstreamTimeout:      {"en": "Request timed out, please retry"},
+	ErrorTypeUpstreamRateLimit:    {"en": "The service is busy, please retry later"},
+	ErrorTypeUpstreamAuthFailed:   {"en": "Upstream authentication failed"},
+	ErrorTypeUpstreamForbidden:    {"en": "Upstream permission denied"},
+	ErrorTypeTokenRefreshFailed:   {"en": "Service temporarily unavailable"},
+	ErrorTypeAllAccountsFailed:    {"en": "Service temporarily unavailable, please retry later"},
+	ErrorTypeUnsupportedModel:     {"en": "Model {{.Model}} is not supported"},
+	ErrorTypeNoAvailableAccount:   {"en": "Service temporarily unavailable, please retry later"},
+	ErrorTypeMaintenanceMode:      {"en": "The service is under maintenance, please try again later"},
+	ErrorTypeServiceUnavailable:   {"en": "Service temporarily unavailable"},
+}
+
+// DefaultTranslationsJSON 错误类型的默认多语言消息 JSON（无默认翻译返回空字符串）
+func DefaultTranslationsJSON(errorType string) string {
+	translations, ok := DefaultErrorTranslations[errorType]
+	if !ok {
+		return ""
+	}
+	data, _ := json.Marshal(translations)
+	return string(data)
+}
+
+// ParseTranslations 解析多语言消息（语言标签统一为小写）
+func (m *ErrorMessage) ParseTranslations() (map[string]string, error) {
+	if strings.TrimSpace(m.Translations) == "" {
+		return nil, nil
+	}
+	var raw map[string]string
+	if err := json.Unmarshal([]byte(m.Translations), &raw); err != nil {
+		return nil, err
+	}
+	translations := make(map[string]string, len(raw))
+	for locale, text := range raw {
+		locale = NormalizeLocale(locale)
+		if locale != "" && text != "" {
+			translations[locale] = text
+		}
+	}
+	return translations, nil
+}
+
+// NormalizeLocale 规范化语言标签（小写，下划线替换为连字符），如 zh_CN -> zh-cn
+func NormalizeLocale(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+}
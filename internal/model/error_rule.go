@@ -2,8 +2,9 @@
  * 文件作用：错误规则数据模型，定义上游错误匹配和处理规则
  * 负责功能：
  *   - 错误匹配规则定义
- *   - HTTP状态码/关键词匹配
+ *   - HTTP状态码/状态码范围/关键词/正则/JSON 路径/响应头/账户类型匹配
  *   - 账户状态转换配置
+ *   - 命中后的处理动作（冷却时长、是否换号重试、返回客户端的提示）
  *   - 默认错误规则模板
  * 重要程度：⭐⭐⭐ 一般（错误规则数据结构）
  * 依赖模块：无
@@ -13,18 +14,40 @@ package model
 import "time"
 
 // ErrorRule 错误匹配规则
+// 所有条件之间为「与」关系，空条件表示不限制
 type ErrorRule struct {
-	ID             uint      `json:"id" gorm:"primaryKey"`
-	HTTPStatusCode int       `json:"http_status_code" gorm:"index;comment:HTTP状态码，0表示任意"`
-	Keyword        string    `json:"keyword" gorm:"size:255;comment:错误关键词，空表示任意"`
-	TargetStatus   string    `json:"target_status" gorm:"size:50;not null;comment:目标账户状态"`
-	Priority       int       `json:"priority" gorm:"default:0;comment:优先级，越大越先匹配"`
-	Enabled        bool      `json:"enabled" gorm:"default:true"`
-	Description    string    `json:"description" gorm:"size:500;comment:规则描述"`
-	CreatedAt      time.Time `json:"created_at"`
-	UpdatedAt      time.Time `json:"updated_at"`
+	ID             uint   `json:"id" gorm:"primaryKey"`
+	HTTPStatusCode int    `json:"http_status_code" gorm:"index;comment:HTTP状态码，0表示任意"`
+	StatusCodes    string `json:"status_codes" gorm:"size:100;comment:状态码列表/范围，如 500-599,529，空表示任意"`
+	Keyword        string `json:"keyword" gorm:"size:255;comment:错误关键词，空表示任意"`
+	MessageRegex   string `json:"message_regex" gorm:"size:500;comment:错误信息正则（不区分大小写），空表示任意"`
+	JSONPath       string `json:"json_path" gorm:"size:100;comment:错误 JSON 字段路径，如 error.type"`
+	JSONValue      string `json:"json_value" gorm:"size:255;comment:JSON 字段取值（逗号分隔任一匹配），空表示字段存在即可"`
+	HeaderName     string `json:"header_name" gorm:"size:100;comment:响应头名称"`
+	HeaderValue    string `json:"header_value" gorm:"size:255;comment:响应头取值（逗号分隔任一匹配），空表示存在即可"`
+	AccountTypes   string `json:"account_types" gorm:"size:255;comment:限定账户类型（逗号分隔），空表示任意"`
+	Platforms      string `json:"platforms" gorm:"size:100;comment:限定平台（逗号分隔），空表示任意"`
+	TargetStatus   string `json:"target_status" gorm:"size:50;not null;comment:目标账户状态"`
+
+	// 命中后的处理动作
+	CooldownSeconds int    `json:"cooldown_seconds" gorm:"default:0;comment:限流冷却时长（秒），0 表示默认 1 小时"`
+	RetryAction     string `json:"retry_action" gorm:"size:20;comment:重试动作: 空=按默认规则, switch=换号重试, none=不重试"`
+	ClientMessage   string `json:"client_message" gorm:"size:500;comment:返回客户端的错误提示，空表示使用默认"`
+
+	Priority    int       `json:"priority" gorm:"default:0;comment:优先级，越大越先匹配"`
+	Enabled     bool      `json:"enabled" gorm:"default:true"`
+	Description string    `json:"description" gorm:"size:500;comment:规则描述"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
 }
 
+// 重试动作常量
+const (
+	RetryActionDefault = ""       // 按重试配置判断
+	RetryActionSwitch  = "switch" // 换其他账户重试
+	RetryActionNone    = "none"   // 不重试，直接返回错误
+)
+
 // 目标状态常量
 const (
 	TargetStatusInvalid     = "invalid"      // 账户失效，自动禁用
@@ -50,6 +73,9 @@ var DefaultErrorRules = []ErrorRule{
 	{HTTPStatusCode: 403, Keyword: "permission denied", TargetStatus: TargetStatusRateLimited, Priority: 90, Enabled: true, Description: "HTTP 403 权限被拒（临时）"},
 	{HTTPStatusCode: 403, Keyword: "", TargetStatus: TargetStatusRateLimited, Priority: 80, Enabled: true, Description: "HTTP 403 其他错误（临时）"},
 
+	// 订阅账户额度用尽（响应头标识，按官方恢复时间由限流逻辑处理）
+	{HTTPStatusCode: 429, HeaderName: "anthropic-ratelimit-unified-status", HeaderValue: "rejected", TargetStatus: TargetStatusRateLimited, RetryAction: RetryActionSwitch, Priority: 110, Enabled: true, Description: "订阅额度用尽（unified 限流）"},
+
 	// 其他 HTTP 状态码
 	{HTTPStatusCode: 429, Keyword: "", TargetStatus: TargetStatusRateLimited, Priority: 100, Enabled: true, Description: "HTTP 429 限流"},
 	{HTTPStatusCode: 529, Keyword: "", TargetStatus: TargetStatusOverloaded, Priority: 100, Enabled: true, Description: "HTTP 529 过载"},
@@ -0,0 +1,58 @@
+/*
+ * 文件作用：IP 封禁数据模型
+ * 负责功能：
+ *   - 全局 IP/CIDR 封禁记录（手动封禁、认证失败自动封禁）
+ *   - 封禁有效期、命中统计
+ *   - 解封审计（保留解封时间和操作人）
+ * 重要程度：⭐⭐⭐ 一般（安全防护）
+ * 依赖模块：无
+ */
+package model
+
+import "time"
+
+// 封禁来源
+const (
+	IPBanSourceManual = "manual" // 管理员手动封禁
+	IPBanSourceAuto   = "auto"   // 认证失败过多自动封禁
+)
+
+// 封禁状态（查询用，不落库）
+const (
+	IPBanStatusActive  = "active"
+	IPBanStatusExpired = "expired"
+	IPBanStatusRevoked = "revoked"
+)
+
+// IPBan IP 封禁记录
+type IPBan struct {
+	ID        uint       `gorm:"primarykey" json:"id"`
+	CIDR      string     `gorm:"column:cidr;size:64;index;not null" json:"cidr"` // 单个 IP 或 CIDR
+	Reason    string     `gorm:"size:255" json:"reason"`
+	Source    string     `gorm:"size:20;index;default:manual" json:"source"` // manual/auto
+	ExpiresAt *time.Time `gorm:"index" json:"expires_at,omitempty"`          // 为空表示永久
+	HitCount  int64      `gorm:"default:0" json:"hit_count"`                 // 封禁期间被拦截的请求数
+	LastHitAt *time.Time `json:"last_hit_at,omitempty"`
+	CreatedBy string     `gorm:"size:100" json:"created_by"` // 操作人（自动封禁为 system）
+	RevokedAt *time.Time `gorm:"index" json:"revoked_at,omitempty"`
+	RevokedBy string     `gorm:"size:100" json:"revoked_by,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+
+	State string `gorm:"-" json:"status"` // 查询时填充：active/expired/revoked
+}
+
+func (IPBan) TableName() string {
+	return "ip_bans"
+}
+
+// Status 当前状态
+func (b *IPBan) Status() string {
+	if b.RevokedAt != nil {
+		return IPBanStatusRevoked
+	}
+	if b.ExpiresAt != nil && !b.ExpiresAt.After(time.Now()) {
+		return IPBanStatusExpired
+	}
+	return IPBanStatusActive
+}
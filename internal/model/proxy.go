@@ -5,6 +5,8 @@
  *   - 认证配置
  *   - 测试状态记录
  *   - 默认代理标记
+ *   - 所属代理池
+ *   - 出口 IP 与国家/地区
  * 重要程度：⭐⭐⭐ 一般（代理数据结构）
  * 依赖模块：gorm
  */
@@ -19,25 +21,35 @@ import (
 
 // Proxy 代理配置
 type Proxy struct {
-	ID          uint           `gorm:"primaryKey" json:"id"`
-	Name        string         `gorm:"size:100;not null" json:"name"`              // 代理名称
-	Type        string         `gorm:"size:20;not null;default:http" json:"type"`  // 代理类型: http, https, socks5
-	Host        string         `gorm:"size:200;not null" json:"host"`              // 代理主机
-	Port        int            `gorm:"not null" json:"port"`                       // 代理端口
-	Username    string         `gorm:"size:100" json:"username,omitempty"`         // 认证用户名
-	Password    string         `gorm:"size:100" json:"password,omitempty"`         // 认证密码
-	Enabled     bool           `gorm:"default:true" json:"enabled"`                // 是否启用
-	IsDefault   bool           `gorm:"default:false" json:"is_default"`            // 是否为默认代理（用于OAuth认证）
-	TestStatus  string         `gorm:"size:20" json:"test_status"`                 // 测试状态: success, failed, 空表示未测试
-	TestLatency int            `gorm:"default:0" json:"test_latency"`              // 测试延迟(ms)
-	TestError   string         `gorm:"size:500" json:"test_error,omitempty"`       // 测试错误信息
-	LastTestAt  *time.Time     `json:"last_test_at,omitempty"`                     // 最后测试时间
-	Remark      string         `gorm:"size:500" json:"remark,omitempty"`           // 备注
-	CreatedAt   time.Time      `json:"created_at"`
-	UpdatedAt   time.Time      `json:"updated_at"`
-	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+	ID              uint           `gorm:"primaryKey" json:"id"`
+	Name            string         `gorm:"size:100;not null" json:"name"`             // 代理名称
+	Type            string         `gorm:"size:20;not null;default:http" json:"type"` // 代理类型: http, https, socks5
+	Host            string         `gorm:"size:200;not null" json:"host"`             // 代理主机
+	Port            int            `gorm:"not null" json:"port"`                      // 代理端口
+	Username        string         `gorm:"size:100" json:"username,omitempty"`        // 认证用户名
+	Password        string         `gorm:"size:100" json:"password,omitempty"`        // 认证密码
+	Enabled         bool           `gorm:"default:true" json:"enabled"`               // 是否启用
+	IsDefault       bool           `gorm:"default:false" json:"is_default"`           // 是否为默认代理（用于OAuth认证）
+	PoolID          *uint          `gorm:"index" json:"pool_id,omitempty"`            // 所属代理池 ID
+	TestStatus      string         `gorm:"size:20" json:"test_status"`                // 测试状态: success, failed, 空表示未测试
+	TestLatency     int            `gorm:"default:0" json:"test_latency"`             // 测试延迟(ms)
+	TestError       string         `gorm:"size:500" json:"test_error,omitempty"`      // 测试错误信息
+	LastTestAt      *time.Time     `json:"last_test_at,omitempty"`                    // 最后测试时间
+	EgressIP        string         `gorm:"size:64" json:"egress_ip,omitempty"`        // 经该代理访问外网时的出口 IP（探测得到）
+	EgressCountry   string         `gorm:"size:8" json:"egress_country,omitempty"`    // 出口 IP 所在国家/地区代码
+	EgressCheckedAt *time.Time     `json:"egress_checked_at,omitempty"`               // 最后探测出口 IP 的时间
+	Remark          string         `gorm:"size:500" json:"remark,omitempty"`          // 备注
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
+// 代理测试状态
+const (
+	ProxyTestStatusSuccess = "success"
+	ProxyTestStatusFailed  = "failed"
+)
+
 // ProxyType 代理类型常量
 const (
 	ProxyTypeHTTP   = "http"
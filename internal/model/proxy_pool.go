@@ -0,0 +1,37 @@
+/*
+ * 文件作用：代理池数据模型，将多个代理编组供账户轮换使用
+ * 负责功能：
+ *   - 代理池基本信息（名称、启用状态）
+ *   - 池内代理成员（Proxy.PoolID 关联）
+ *   - 跨地区分配开关
+ * 重要程度：⭐⭐⭐ 一般（代理数据结构）
+ * 依赖模块：gorm
+ */
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ProxyPool 代理池
+// 绑定代理池的账户按账户粘性分配池内一个健康代理，连接失败时自动切换到池内其他代理
+type ProxyPool struct {
+	ID        uint           `gorm:"primaryKey" json:"id"`
+	Name      string         `gorm:"size:100;not null" json:"name"`    // 代理池名称
+	Enabled   bool           `gorm:"default:true" json:"enabled"`      // 是否启用（禁用后账户回退到单独配置的代理）
+	Remark    string         `gorm:"size:500" json:"remark,omitempty"` // 备注
+
+	// AllowCrossRegion 池内没有账户出口地区的启用代理时允许分配其他地区的代理（默认拒绝，避免账户出口跨地区切换）
+	AllowCrossRegion bool `gorm:"default:false" json:"allow_cross_region"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	Proxies []Proxy `gorm:"foreignKey:PoolID" json:"proxies,omitempty"` // 池内代理
+}
+
+func (ProxyPool) TableName() string {
+	return "proxy_pools"
+}
@@ -6,6 +6,8 @@
  *   - 费用记录
  *   - 请求/响应详情（可选）
  *   - 错误信息记录
+ *   - 响应缓存命中情况
+ *   - 请求/响应体压缩存储
  * 重要程度：⭐⭐⭐ 一般（日志数据结构）
  * 依赖模块：gorm
  */
@@ -14,6 +16,8 @@ package model
 import (
 	"time"
 
+	"cli-proxy/pkg/utils"
+
 	"gorm.io/gorm"
 )
 
@@ -32,6 +36,7 @@ type RequestLog struct {
 	RequestIP  string `gorm:"size:50" json:"request_ip"`                // 请求IP
 	UserAgent  string `gorm:"size:500" json:"user_agent,omitempty"`     // User-Agent
 	SessionID  string `gorm:"size:100;index" json:"session_id,omitempty"` // 会话ID
+	ClientType string `gorm:"size:50;index" json:"client_type,omitempty"` // 客户端类型（客户端过滤识别结果）
 
 	// 完整请求/响应记录
 	RequestHeaders  string `gorm:"type:text" json:"request_headers,omitempty"`   // 请求头 JSON
@@ -63,21 +68,41 @@ type RequestLog struct {
 	Duration   int64  `gorm:"default:0" json:"duration"`           // 请求耗时(毫秒)
 
 	// 上游响应信息
-	UpstreamStatusCode int    `gorm:"default:0" json:"upstream_status_code"`       // 上游HTTP状态码
+	UpstreamStatusCode int    `gorm:"default:0;index" json:"upstream_status_code"` // 上游HTTP状态码
 	UpstreamError      string `gorm:"size:2000" json:"upstream_error,omitempty"`   // 上游错误信息
 
+	// 响应缓存
+	CacheStatus string `gorm:"size:10;index" json:"cache_status,omitempty"` // 响应缓存状态：hit/miss，未开启缓存时为空
+	CacheKey    string `gorm:"size:64" json:"cache_key,omitempty"`          // 响应缓存键
+
+	// 管理员重放
+	ReplayOfID *uint `gorm:"index" json:"replay_of_id,omitempty"` // 重放来源日志ID（非空表示这是一次重放，不计入 API Key 用量）
+
 	// 时间戳
-	CreatedAt time.Time      `gorm:"index" json:"created_at"`
+	CreatedAt time.Time      `gorm:"index;not null" json:"created_at"` // 分区键，必须非空
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 
-	// 关联
-	Account *Account `gorm:"foreignKey:AccountID" json:"account,omitempty"`
+	// 关联（不建外键约束，分区表不支持外键）
+	Account *Account `gorm:"foreignKey:AccountID;constraint:-" json:"account,omitempty"`
 }
 
 func (r *RequestLog) TableName() string {
 	return "request_logs"
 }
 
+// CompressBodies 压缩请求/响应体（落库前调用，短内容保持原样）
+func (r *RequestLog) CompressBodies() {
+	r.RequestBody = utils.CompressText(r.RequestBody)
+	r.ResponseBody = utils.CompressText(r.ResponseBody)
+}
+
+// AfterFind 查询后解压请求/响应体
+func (r *RequestLog) AfterFind(tx *gorm.DB) error {
+	r.RequestBody = utils.DecompressText(r.RequestBody)
+	r.ResponseBody = utils.DecompressText(r.ResponseBody)
+	return nil
+}
+
 // RequestLogSummary 请求日志摘要统计
 type RequestLogSummary struct {
 	TotalRequests            int64   `json:"total_requests"`
@@ -0,0 +1,124 @@
+/*
+ * 文件作用：请求日志全文检索数据模型
+ * 负责功能：
+ *   - 请求日志可检索文本（独立表，分区表不支持 FULLTEXT 索引）
+ *   - 从请求/响应体中提取对话文本
+ * 重要程度：⭐⭐⭐ 一般（日志检索）
+ * 依赖模块：无
+ */
+package model
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// 可检索文本最大长度（字节）
+const RequestLogSearchMaxLen = 16 * 1024
+
+// RequestLogSearch 请求日志可检索文本，与 request_logs 一对一
+type RequestLogSearch struct {
+	LogID     uint      `gorm:"primaryKey;autoIncrement:false" json:"log_id"`
+	CreatedAt time.Time `gorm:"index;not null" json:"created_at"` // 与日志创建时间一致，用于按时间清理
+	Content   string    `gorm:"type:mediumtext" json:"content"`   // FULLTEXT 索引在迁移时单独创建
+}
+
+func (RequestLogSearch) TableName() string {
+	return "request_log_searches"
+}
+
+// 提取文本时跳过的字段（图片、签名等非对话内容）
+var searchSkipKeys = map[string]bool{
+	"data":        true,
+	"signature":   true,
+	"image_url":   true,
+	"source":      true,
+	"id":          true,
+	"type":        true,
+	"role":        true,
+	"model":       true,
+	"tool_use_id": true,
+}
+
+// BuildRequestLogSearchText 生成日志的可检索文本：错误信息 + 请求/响应中的对话文本
+// 需在脱敏之后、压缩之前调用
+func BuildRequestLogSearchText(log *RequestLog) string {
+	var b strings.Builder
+	appendSearchText(&b, log.Error)
+	appendSearchText(&b, log.UpstreamError)
+	appendBodyText(&b, log.RequestBody)
+	appendBodyText(&b, log.ResponseBody)
+	return truncateUTF8(strings.TrimSpace(b.String()), RequestLogSearchMaxLen)
+}
+
+// appendBodyText 从 JSON 或 SSE 响应体中提取文本，无法解析时按原文追加
+func appendBodyText(b *strings.Builder, body string) {
+	if body == "" || b.Len() >= RequestLogSearchMaxLen {
+		return
+	}
+	var v interface{}
+	if json.Unmarshal([]byte(body), &v) == nil {
+		collectJSONText(b, v)
+		return
+	}
+	// SSE：逐行解析 data: {...}
+	parsed := false
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		if json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))), &v) == nil {
+			collectJSONText(b, v)
+			parsed = true
+		}
+	}
+	if !parsed {
+		appendSearchText(b, body)
+	}
+}
+
+// collectJSONText 递归收集 JSON 中的字符串值
+func collectJSONText(b *strings.Builder, v interface{}) {
+	if b.Len() >= RequestLogSearchMaxLen {
+		return
+	}
+	switch val := v.(type) {
+	case string:
+		appendSearchText(b, val)
+	case []interface{}:
+		for _, item := range val {
+			collectJSONText(b, item)
+		}
+	case map[string]interface{}:
+		for key, item := range val {
+			if searchSkipKeys[key] {
+				continue
+			}
+			collectJSONText(b, item)
+		}
+	}
+}
+
+func appendSearchText(b *strings.Builder, s string) {
+	s = strings.TrimSpace(s)
+	if s == "" || b.Len() >= RequestLogSearchMaxLen {
+		return
+	}
+	if b.Len() > 0 {
+		b.WriteByte('\n')
+	}
+	b.WriteString(s)
+}
+
+// truncateUTF8 按字节截断且不破坏多字节字符
+func truncateUTF8(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	for max > 0 && (s[max]&0xC0) == 0x80 {
+		max--
+	}
+	return s[:max]
+}
@@ -0,0 +1,36 @@
+package model
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestBuildRequestLogSearchText(t *testing.T) {
+	log := &RequestLog{
+		UpstreamError: "overloaded_error",
+		RequestBody: `{"model":"claude-sonnet","messages":[{"role":"user","content":[` +
+			`{"type":"text","text":"如何配置代理池"},{"type":"image","source":{"data":"iVBORw0KGgo"}}]}]}`,
+		ResponseBody: "[stream tail] event: content_block_delta\n" +
+			`data: {"type":"content_block_delta","delta":{"type":"text_delta","text":"在系统设置中添加"}}` + "\n",
+	}
+	text := BuildRequestLogSearchText(log)
+	for _, want := range []string{"overloaded_error", "如何配置代理池", "在系统设置中添加"} {
+		if !strings.Contains(text, want) {
+			t.Fatalf("search text missing %q: %q", want, text)
+		}
+	}
+	for _, unwanted := range []string{"iVBORw0KGgo", "claude-sonnet", "text_delta"} {
+		if strings.Contains(text, unwanted) {
+			t.Fatalf("search text should not contain %q: %q", unwanted, text)
+		}
+	}
+}
+
+func TestBuildRequestLogSearchTextTruncates(t *testing.T) {
+	log := &RequestLog{RequestBody: strings.Repeat("日志", RequestLogSearchMaxLen)}
+	text := BuildRequestLogSearchText(log)
+	if len(text) > RequestLogSearchMaxLen || !utf8.ValidString(text) {
+		t.Fatalf("unexpected truncation: len=%d", len(text))
+	}
+}
@@ -35,6 +35,12 @@ const (
 	// 计费相关
 	ConfigGlobalPriceRate = "global_price_rate" // 全局价格倍率
 
+	// 用量报表相关
+	ConfigUsageReportEnabled       = "usage_report_enabled"        // 是否自动生成周期用量报表
+	ConfigUsageReportPeriods       = "usage_report_periods"        // 报表周期（weekly,monthly）
+	ConfigUsageReportWebhookURL    = "usage_report_webhook_url"    // 报表推送 Webhook 地址
+	ConfigUsageReportWebhookSecret = "usage_report_webhook_secret" // Webhook 签名密钥
+
 	// 会话相关
 	ConfigSessionTTL = "session_ttl" // 会话粘性 TTL（分钟）
 
@@ -52,6 +58,28 @@ const (
 	ConfigLoginRateLimitEnable = "login_rate_limit_enable" // 是否启用登录频率限制
 	ConfigLoginRateLimitCount  = "login_rate_limit_count"  // 登录频率限制次数
 	ConfigLoginRateLimitWindow = "login_rate_limit_window" // 登录频率限制时间窗口（分钟）
+	ConfigIPAutoBanEnabled     = "ip_auto_ban_enabled"     // 代理认证失败过多时是否自动封禁 IP
+	ConfigIPAutoBanThreshold   = "ip_auto_ban_threshold"   // 时间窗口内认证失败次数阈值
+	ConfigIPAutoBanWindow      = "ip_auto_ban_window"      // 认证失败统计时间窗口（分钟）
+	ConfigIPAutoBanDuration    = "ip_auto_ban_duration"    // 自动封禁时长（分钟）
+
+	// 客户端指纹分析
+	ConfigFingerprintEnabled       = "fingerprint_enabled"        // 是否统计客户端指纹并检测异常
+	ConfigFingerprintWindow        = "fingerprint_window"         // 异常检测时间窗口（分钟）
+	ConfigFingerprintMaxIPs        = "fingerprint_max_ips"        // 窗口内不同来源 IP 数阈值
+	ConfigFingerprintMaxClients    = "fingerprint_max_clients"    // 窗口内同时活跃的客户端指纹数阈值
+	ConfigFingerprintAlertNew      = "fingerprint_alert_new"      // 出现新客户端指纹时是否告警
+	ConfigFingerprintAutoSuspend   = "fingerprint_auto_suspend"   // 严重告警时是否自动暂停 Key
+	ConfigFingerprintWebhookURL    = "fingerprint_webhook_url"    // 告警推送 Webhook 地址
+	ConfigFingerprintRetentionDays = "fingerprint_retention_days" // 小时统计保留天数
+
+	// 代理池与代理健康检查
+	ConfigProxyHealthCheckEnabled  = "proxy_health_check_enabled"  // 是否定期测试代理连通性
+	ConfigProxyHealthCheckInterval = "proxy_health_check_interval" // 代理连通性测试间隔（分钟）
+	ConfigProxyFailureThreshold    = "proxy_failure_threshold"     // 池内代理连续连接失败多少次后临时排除
+	ConfigProxyFailureCooldown     = "proxy_failure_cooldown"      // 临时排除时长（分钟）
+	ConfigProxyEgressCheckEnabled  = "proxy_egress_check_enabled"  // 是否探测代理出口 IP 并跟踪账户出口 IP
+	ConfigProxyEgressEchoURL       = "proxy_egress_echo_url"       // 出口 IP 回显服务地址
 
 	// 账号健康检查相关
 	ConfigAccountHealthCheckEnabled  = "account_health_check_enabled"  // 是否启用账号健康检查
@@ -93,24 +121,99 @@ const (
 	ConfigSyncOpenAIEnabled    = "sync_openai_enabled"     // 是否同步 OpenAI 账户
 	ConfigSyncGeminiEnabled    = "sync_gemini_enabled"     // 是否同步 Gemini 账户
 
+	// 请求日志保留与归档
+	ConfigRequestLogBodyRetentionDays = "request_log_body_retention_days" // 请求/响应体保留天数
+	ConfigRequestLogRetentionDays     = "request_log_retention_days"      // 日志元数据保留天数
+	ConfigRequestLogCompressBodies    = "request_log_compress_bodies"     // 是否压缩存储请求/响应体
+	ConfigRequestLogArchiveEnabled    = "request_log_archive_enabled"     // 删除前是否归档
+	ConfigRequestLogArchiveDir        = "request_log_archive_dir"         // 归档目录
+	ConfigRequestLogPartitionEnabled  = "request_log_partition_enabled"   // 是否按月分区
+	ConfigRequestLogRetentionInterval = "request_log_retention_interval"  // 清理任务间隔（分钟）
+
+	// 系统日志保留与投递
+	ConfigSystemLogMaxSizeMB       = "system_log_max_size_mb"      // 单个日志文件最大体积（MB）
+	ConfigSystemLogMaxBackups      = "system_log_max_backups"      // 保留的轮转文件数
+	ConfigSystemLogMaxAgeDays      = "system_log_max_age_days"     // 轮转文件保留天数
+	ConfigSystemLogModuleRetention = "system_log_module_retention" // 按模块覆盖的保留策略（JSON）
+	ConfigSystemLogShipType        = "system_log_ship_type"        // 投递类型：loki/http/syslog，为空不投递
+	ConfigSystemLogShipURL         = "system_log_ship_url"         // 投递地址
+	ConfigSystemLogShipLabels      = "system_log_ship_labels"      // 投递附加标签（JSON 对象）
+	ConfigSystemLogShipMinLevel    = "system_log_ship_min_level"   // 最低投递级别
+
+	// 请求排队（账户并发全满时）
+	ConfigRequestQueueEnabled      = "request_queue_enabled"       // 是否排队等待（关闭时立即返回错误）
+	ConfigRequestQueueMaxWait      = "request_queue_max_wait"      // 单个请求最长排队时间（秒）
+	ConfigRequestQueueMaxDepth     = "request_queue_max_depth"     // 每个账户池最多排队请求数
+	ConfigRequestQueuePingInterval = "request_queue_ping_interval" // 流式请求排队心跳间隔（秒）
+
+	// 隐私脱敏配置
+	ConfigRedactEnabled     = "redact_enabled"      // 是否在日志落库前脱敏
+	ConfigRedactDetectors   = "redact_detectors"    // 启用的内置检测器（逗号分隔）
+	ConfigRedactCustomRules = "redact_custom_rules" // 自定义正则规则（JSON 数组）
 )
 
 // 默认配置
 var DefaultConfigs = []SystemConfig{
 	// 计费配置
 	{Key: ConfigGlobalPriceRate, Value: "1", Type: "float", Desc: "全局价格倍率（1=原价，0=免费，2=2倍），用户倍率为1时使用此值", Category: "billing"},
+	{Key: ConfigUsageReportEnabled, Value: "false", Type: "bool", Desc: "是否自动为每个 API Key 生成周期用量报表（周期结束后生成）", Category: "billing"},
+	{Key: ConfigUsageReportPeriods, Value: "monthly", Type: "string", Desc: "自动报表周期（逗号分隔）：weekly, monthly", Category: "billing"},
+	{Key: ConfigUsageReportWebhookURL, Value: "", Type: "string", Desc: "报表生成后推送的 Webhook 地址（为空不推送）", Category: "billing"},
+	{Key: ConfigUsageReportWebhookSecret, Value: "", Type: "string", Desc: "Webhook 签名密钥（HMAC-SHA256，签名放在 X-Signature 请求头）", Category: "billing"},
 	// 会话配置
 	{Key: ConfigSessionTTL, Value: "30", Type: "int", Desc: "会话粘性过期时间（分钟）", Category: "session"},
 	{Key: ConfigSyncEnabled, Value: "true", Type: "bool", Desc: "是否启用使用记录同步", Category: "sync"},
 	{Key: ConfigSyncInterval, Value: "5", Type: "int", Desc: "使用记录同步间隔（分钟）", Category: "sync"},
 	{Key: ConfigRecordRetentionDays, Value: "30", Type: "int", Desc: "Redis 使用记录保留天数", Category: "record"},
 	{Key: ConfigRecordMaxCount, Value: "1000", Type: "int", Desc: "Redis 每用户最大记录数", Category: "record"},
+	// 请求日志保留与归档
+	{Key: ConfigRequestLogBodyRetentionDays, Value: "7", Type: "int", Desc: "请求/响应体及请求头保留天数（超期后清空，仅保留元数据），0 表示不清理", Category: "request_log"},
+	{Key: ConfigRequestLogRetentionDays, Value: "90", Type: "int", Desc: "请求日志保留天数（超期后归档并删除），0 表示永久保留", Category: "request_log"},
+	{Key: ConfigRequestLogCompressBodies, Value: "true", Type: "bool", Desc: "是否以 gzip 压缩存储请求/响应体", Category: "request_log"},
+	{Key: ConfigRequestLogArchiveEnabled, Value: "true", Type: "bool", Desc: "删除过期日志前是否归档到本地 JSONL.gz 文件", Category: "request_log"},
+	{Key: ConfigRequestLogArchiveDir, Value: "data/archive/request_logs", Type: "string", Desc: "请求日志归档目录", Category: "request_log"},
+	{Key: ConfigRequestLogPartitionEnabled, Value: "false", Type: "bool", Desc: "是否将请求日志表按月分区（开启后过期分区整体删除，首次转换大表耗时较长）", Category: "request_log"},
+	{Key: ConfigRequestLogRetentionInterval, Value: "60", Type: "int", Desc: "请求日志清理任务执行间隔（分钟）", Category: "request_log"},
+	// 系统日志保留与投递
+	{Key: ConfigSystemLogMaxSizeMB, Value: "100", Type: "int", Desc: "系统日志单个文件最大体积（MB），超过后轮转", Category: "system_log"},
+	{Key: ConfigSystemLogMaxBackups, Value: "30", Type: "int", Desc: "系统日志保留的轮转文件数", Category: "system_log"},
+	{Key: ConfigSystemLogMaxAgeDays, Value: "90", Type: "int", Desc: "系统日志轮转文件保留天数", Category: "system_log"},
+	{Key: ConfigSystemLogModuleRetention, Value: "{}", Type: "json", Desc: "按模块覆盖的保留策略（JSON 对象，如 {\"proxy\":{\"max_size_mb\":200,\"max_backups\":10,\"max_age_days\":30}}）", Category: "system_log"},
+	{Key: ConfigSystemLogShipType, Value: "", Type: "string", Desc: "系统日志投递类型：loki, http, syslog（为空不投递）", Category: "system_log"},
+	{Key: ConfigSystemLogShipURL, Value: "", Type: "string", Desc: "投递地址（loki: http://host:3100，http: 接收 JSON 数组的地址，syslog: udp://host:514 或 tcp://host:601）", Category: "system_log"},
+	{Key: ConfigSystemLogShipLabels, Value: "{}", Type: "json", Desc: "投递附加标签（JSON 对象，如 {\"env\":\"prod\"}）", Category: "system_log"},
+	{Key: ConfigSystemLogShipMinLevel, Value: "info", Type: "string", Desc: "最低投递级别：debug, info, warn, error", Category: "system_log"},
+	// 请求排队
+	{Key: ConfigRequestQueueEnabled, Value: "true", Type: "bool", Desc: "所有可用账户并发已满时排队等待空闲槽位（关闭时立即返回 429）", Category: "queue"},
+	{Key: ConfigRequestQueueMaxWait, Value: "30", Type: "int", Desc: "单个请求最长排队时间（秒），超时返回 429", Category: "queue"},
+	{Key: ConfigRequestQueueMaxDepth, Value: "200", Type: "int", Desc: "每个账户池（平台/账户类型）最多排队请求数，超出立即返回 429", Category: "queue"},
+	{Key: ConfigRequestQueuePingInterval, Value: "10", Type: "int", Desc: "流式请求排队期间发送 SSE 心跳注释的间隔（秒），0 表示不发送", Category: "queue"},
 	// 安全配置
 	{Key: ConfigCaptchaEnabled, Value: "true", Type: "bool", Desc: "是否启用登录验证码", Category: "security"},
 	{Key: ConfigCaptchaRateLimit, Value: "10", Type: "int", Desc: "验证码获取频率限制（次/分钟）", Category: "security"},
 	{Key: ConfigLoginRateLimitEnable, Value: "true", Type: "bool", Desc: "是否启用登录频率限制", Category: "security"},
 	{Key: ConfigLoginRateLimitCount, Value: "3", Type: "int", Desc: "登录频率限制次数", Category: "security"},
 	{Key: ConfigLoginRateLimitWindow, Value: "5", Type: "int", Desc: "登录频率限制时间窗口（分钟）", Category: "security"},
+	{Key: ConfigIPAutoBanEnabled, Value: "true", Type: "bool", Desc: "代理接口 API Key 认证失败过多时自动临时封禁来源 IP", Category: "security"},
+	{Key: ConfigIPAutoBanThreshold, Value: "20", Type: "int", Desc: "自动封禁阈值：时间窗口内认证失败次数", Category: "security"},
+	{Key: ConfigIPAutoBanWindow, Value: "5", Type: "int", Desc: "自动封禁统计时间窗口（分钟，最大 30）", Category: "security"},
+	{Key: ConfigIPAutoBanDuration, Value: "60", Type: "int", Desc: "自动封禁时长（分钟）", Category: "security"},
+	// 客户端指纹分析
+	{Key: ConfigFingerprintEnabled, Value: "true", Type: "bool", Desc: "按 API Key 统计客户端类型、UA 版本、操作系统和来源 IP，并检测异常", Category: "fingerprint"},
+	{Key: ConfigFingerprintWindow, Value: "60", Type: "int", Desc: "异常检测时间窗口（分钟）", Category: "fingerprint"},
+	{Key: ConfigFingerprintMaxIPs, Value: "10", Type: "int", Desc: "窗口内同一 Key 的不同来源 IP 数超过该值时告警（0 表示不检测）", Category: "fingerprint"},
+	{Key: ConfigFingerprintMaxClients, Value: "3", Type: "int", Desc: "窗口内同一 Key 同时活跃的客户端指纹数超过该值时判定为疑似共享/泄露（0 表示不检测）", Category: "fingerprint"},
+	{Key: ConfigFingerprintAlertNew, Value: "true", Type: "bool", Desc: "Key 出现此前未见过的客户端指纹时告警", Category: "fingerprint"},
+	{Key: ConfigFingerprintAutoSuspend, Value: "false", Type: "bool", Desc: "IP 过多或疑似共享/泄露时自动暂停 Key（需管理员手动恢复）", Category: "fingerprint"},
+	{Key: ConfigFingerprintWebhookURL, Value: "", Type: "string", Desc: "指纹告警推送的 Webhook 地址（为空不推送）", Category: "fingerprint"},
+	{Key: ConfigFingerprintRetentionDays, Value: "30", Type: "int", Desc: "客户端指纹小时统计保留天数", Category: "fingerprint"},
+	// 代理池与代理健康检查
+	{Key: ConfigProxyHealthCheckEnabled, Value: "true", Type: "bool", Desc: "定期测试所有启用代理的连通性，测试失败的代理不参与代理池分配", Category: "proxy"},
+	{Key: ConfigProxyHealthCheckInterval, Value: "5", Type: "int", Desc: "代理连通性测试间隔（分钟）", Category: "proxy"},
+	{Key: ConfigProxyFailureThreshold, Value: "3", Type: "int", Desc: "代理池内代理连续连接失败达到该次数后临时排除（每次失败都会将账户切换到其他代理）", Category: "proxy"},
+	{Key: ConfigProxyFailureCooldown, Value: "10", Type: "int", Desc: "连接失败代理的临时排除时长（分钟），连通性测试成功后提前恢复", Category: "proxy"},
+	{Key: ConfigProxyEgressCheckEnabled, Value: "false", Type: "bool", Desc: "随代理健康检查探测各代理及直连的出口 IP 与国家/地区，记录账户出口 IP，变化时告警，改绑到其他地区的代理需确认", Category: "proxy"},
+	{Key: ConfigProxyEgressEchoURL, Value: "https://ipinfo.io/json", Type: "string", Desc: "出口 IP 回显服务地址（返回 JSON 的 ip/country 字段或纯文本 IP，可指向本地服务）", Category: "proxy"},
 	// 账号健康检查配置
 	{Key: ConfigAccountHealthCheckEnabled, Value: "false", Type: "bool", Desc: "是否启用账号健康检查", Category: "health_check"},
 	{Key: ConfigAccountHealthCheckInterval, Value: "5", Type: "int", Desc: "账号健康检查间隔（分钟）", Category: "health_check"},
@@ -143,4 +246,8 @@ var DefaultConfigs = []SystemConfig{
 	{Key: ConfigSyncClaudeEnabled, Value: "true", Type: "bool", Desc: "是否同步 Claude OAuth 账户用量", Category: "usage_sync"},
 	{Key: ConfigSyncOpenAIEnabled, Value: "true", Type: "bool", Desc: "是否同步 OpenAI Codex 用量", Category: "usage_sync"},
 	{Key: ConfigSyncGeminiEnabled, Value: "true", Type: "bool", Desc: "是否同步 Gemini 账户用量", Category: "usage_sync"},
+	// 隐私脱敏配置
+	{Key: ConfigRedactEnabled, Value: "false", Type: "bool", Desc: "是否在请求日志落库前脱敏（邮箱、手机号、银行卡号、API Key、身份证号等）", Category: "privacy"},
+	{Key: ConfigRedactDetectors, Value: "email,phone,credit_card,api_key,cn_id", Type: "string", Desc: "启用的内置检测器（逗号分隔）：email, phone, credit_card, api_key, cn_id", Category: "privacy"},
+	{Key: ConfigRedactCustomRules, Value: "[]", Type: "json", Desc: "自定义脱敏规则（JSON 数组，元素字段：name, pattern, replacement）", Category: "privacy"},
 }
@@ -0,0 +1,62 @@
+/*
+ * 文件作用：周期用量报表数据模型，存储按 API Key 生成的周报/月报
+ * 负责功能：
+ *   - 报表周期与汇总指标
+ *   - 完整报表内容（JSON，含模型明细）
+ *   - Webhook 推送状态
+ * 重要程度：⭐⭐⭐ 一般（账单报表）
+ * 依赖模块：gorm
+ */
+package model
+
+import (
+	"time"
+)
+
+// 报表周期
+const (
+	ReportPeriodWeekly  = "weekly"
+	ReportPeriodMonthly = "monthly"
+	ReportPeriodCustom  = "custom" // 手动指定日期范围
+)
+
+// 推送状态
+const (
+	ReportDeliveryNone      = ""          // 未配置推送
+	ReportDeliveryPending   = "pending"   // 待推送
+	ReportDeliveryDelivered = "delivered" // 已推送
+	ReportDeliveryFailed    = "failed"    // 推送失败
+)
+
+// UsageReport 周期用量报表
+// 每个 API Key 每个周期一条记录
+type UsageReport struct {
+	ID          uint   `gorm:"primarykey" json:"id"`
+	APIKeyID    uint   `gorm:"uniqueIndex:idx_report_key_period,priority:1" json:"api_key_id"`     // API Key ID
+	APIKeyName  string `gorm:"size:100" json:"api_key_name"`                                       // API Key 名称（生成时快照）
+	Period      string `gorm:"size:10;uniqueIndex:idx_report_key_period,priority:2" json:"period"` // 周期: weekly/monthly/custom
+	PeriodStart string `gorm:"size:10;uniqueIndex:idx_report_key_period,priority:3" json:"period_start"`
+	PeriodEnd   string `gorm:"size:10;uniqueIndex:idx_report_key_period,priority:4" json:"period_end"`
+
+	// 汇总指标（便于列表展示和排序，完整内容见 Content）
+	RequestCount   int64   `gorm:"default:0" json:"request_count"`
+	TotalTokens    int64   `gorm:"default:0" json:"total_tokens"`
+	TotalCost      float64 `gorm:"type:decimal(14,6);default:0" json:"total_cost"`       // 实际费用（已计算倍率）
+	BaseCost       float64 `gorm:"type:decimal(14,6);default:0" json:"base_cost"`        // 按模型定价计算的原价
+	EffectiveRate  float64 `gorm:"type:decimal(10,4);default:0" json:"effective_rate"`   // 实际费用 / 原价
+	CacheSavedCost float64 `gorm:"type:decimal(14,6);default:0" json:"cache_saved_cost"` // Prompt Caching 节省费用
+
+	Content string `gorm:"type:longtext" json:"-"` // 完整报表 JSON
+
+	// Webhook 推送
+	DeliveryStatus string     `gorm:"size:20;index" json:"delivery_status"`
+	DeliveryError  string     `gorm:"size:500" json:"delivery_error,omitempty"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (r *UsageReport) TableName() string {
+	return "usage_reports"
+}
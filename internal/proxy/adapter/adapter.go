@@ -17,6 +17,8 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net/http"
+	"strings"
 
 	"cli-proxy/internal/model"
 )
@@ -25,10 +27,11 @@ var (
 	ErrNoAdapter = errors.New("no adapter found for account type")
 )
 
-// UpstreamError 上游错误（包含状态码）
+// UpstreamError 上游错误（包含状态码和响应头）
 type UpstreamError struct {
 	StatusCode int
 	Message    string
+	Headers    map[string]string // 上游响应头（键为小写，用于错误规则匹配）
 }
 
 func (e *UpstreamError) Error() string {
@@ -43,6 +46,20 @@ func NewUpstreamError(statusCode int, message string) *UpstreamError {
 	}
 }
 
+// NewUpstreamErrorWithHeaders 创建带响应头的上游错误
+func NewUpstreamErrorWithHeaders(statusCode int, message string, header http.Header) *UpstreamError {
+	e := NewUpstreamError(statusCode, message)
+	if len(header) > 0 {
+		e.Headers = make(map[string]string, len(header))
+		for k, v := range header {
+			if len(v) > 0 {
+				e.Headers[strings.ToLower(k)] = v[0]
+			}
+		}
+	}
+	return e
+}
+
 // Request 统一请求结构
 type Request struct {
 	Model       string        `json:"model"`
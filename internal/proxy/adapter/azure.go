@@ -160,7 +160,7 @@ func (a *AzureOpenAIAdapter) SendStream(ctx context.Context, account *model.Acco
 	if resp.StatusCode != http.StatusOK {
 		respBody, _ := ReadResponseBody(resp)
 		log.Error("Azure OpenAI Stream API 错误 - StatusCode: %d, Body: %s", resp.StatusCode, string(respBody))
-		return nil, NewUpstreamError(resp.StatusCode, string(respBody))
+		return nil, NewUpstreamErrorWithHeaders(resp.StatusCode, string(respBody), resp.Header)
 	}
 
 	log.Debug("Azure OpenAI Stream 响应状态码: %d, 开始接收流式数据", resp.StatusCode)
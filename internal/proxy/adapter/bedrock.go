@@ -218,7 +218,7 @@ func (a *BedrockAdapter) SendStream(ctx context.Context, account *model.Account,
 	if resp.StatusCode != http.StatusOK {
 		respBody, _ := ReadResponseBody(resp)
 		log.Error("Bedrock Stream API 错误 - StatusCode: %d, Body: %s", resp.StatusCode, string(respBody))
-		return nil, NewUpstreamError(resp.StatusCode, string(respBody))
+		return nil, NewUpstreamErrorWithHeaders(resp.StatusCode, string(respBody), resp.Header)
 	}
 
 	log.Debug("Bedrock Stream 响应状态码: %d, 开始接收流式数据", resp.StatusCode)
@@ -120,7 +120,7 @@ func (a *ClaudeAdapter) doSendWithRetry(ctx context.Context, account *model.Acco
 			}
 		}
 
-		return nil, NewUpstreamError(resp.StatusCode, errStr)
+		return nil, NewUpstreamErrorWithHeaders(resp.StatusCode, errStr, resp.Header)
 	}
 
 	// 解析响应提取 usage 信息
@@ -197,7 +197,7 @@ func (a *ClaudeAdapter) doSendStreamWithRetry(ctx context.Context, account *mode
 
 		// 发送 SSE 错误事件给客户端
 		a.sendSSEError(writer, fmt.Sprintf("upstream_error_%d", resp.StatusCode), errStr)
-		return nil, NewUpstreamError(resp.StatusCode, errStr)
+		return nil, NewUpstreamErrorWithHeaders(resp.StatusCode, errStr, resp.Header)
 	}
 
 	// 透传 SSE 流并解析 usage
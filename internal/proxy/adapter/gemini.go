@@ -213,7 +213,7 @@ func (a *GeminiAdapter) SendStream(ctx context.Context, account *model.Account,
 		log.Error("Gemini Stream API 错误 - StatusCode: %d, Body: %s", resp.StatusCode, string(respBody))
 		// 发送 SSE 错误事件给客户端
 		a.sendSSEError(writer, fmt.Sprintf("upstream_error_%d", resp.StatusCode), string(respBody))
-		return nil, NewUpstreamError(resp.StatusCode, string(respBody))
+		return nil, NewUpstreamErrorWithHeaders(resp.StatusCode, string(respBody), resp.Header)
 	}
 
 	log.Info("Gemini Stream 开始传输 | StatusCode: %d | AccountID: %d", resp.StatusCode, account.ID)
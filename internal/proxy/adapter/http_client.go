@@ -4,11 +4,11 @@
  *   - 全局HTTP客户端（普通/流式）
  *   - 代理客户端缓存（避免重复创建）
  *   - Chrome TLS指纹支持（绕过TLS检测）
- *   - SOCKS5/HTTP代理支持
+ *   - SOCKS5/HTTP代理支持（账户代理或代理池分配的代理）
  *   - gzip响应自动解压
  *   - 连接池参数配置
  * 重要程度：⭐⭐⭐⭐⭐ 核心（所有上游请求的基础）
- * 依赖模块：model, logger
+ * 依赖模块：model, proxypool, egress, logger
  */
 package adapter
 
@@ -23,11 +23,14 @@ import (
 	"net"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"cli-proxy/internal/egress"
 	"cli-proxy/internal/model"
+	"cli-proxy/internal/proxypool"
 	"cli-proxy/pkg/logger"
 	"cli-proxy/pkg/utils"
 
@@ -276,23 +279,67 @@ func createStreamProxyClient(proxyURLStr string) *http.Client {
 }
 
 // GetEffectiveProxy 获取生效的代理 URL
-// 如果账户关联了代理，则返回代理 URL，否则返回空（直连）
+// 如果账户关联了代理池或代理，则返回代理 URL，否则返回空（直连）
 func GetEffectiveProxy(account *model.Account) string {
 	if account == nil {
 		return ""
 	}
 
+	if p := EffectiveAccountProxy(account); p != nil {
+		return p.GetURL()
+	}
+
+	return ""
+}
+
+// EffectiveAccountProxy 获取账户请求上游时实际使用的代理，直连时返回 nil
+func EffectiveAccountProxy(account *model.Account) *model.Proxy {
+	if account == nil {
+		return nil
+	}
+
 	// openai-responses 的 xyrt 或配置了网关时强制直连，忽略代理
 	if account.Type == model.AccountTypeOpenAIResponses && (account.AuthType == "xyrt" || account.GatewayURL != "") {
-		return ""
+		return nil
 	}
 
-	// 检查账户是否关联了代理
+	return ResolveAccountProxy(account)
+}
+
+// ResolveAccountProxy 获取账户当前使用的代理
+// 绑定了代理池时由代理池按账户粘性分配（限定账户已知出口地区的代理），代理池不可用时回退到账户单独配置的代理；
+// 代理池因没有同地区代理拒绝分配时不回退（调度器通过 EgressRegionBlocked 跳过该账户）
+func ResolveAccountProxy(account *model.Account) *model.Proxy {
+	if account == nil {
+		return nil
+	}
+	if account.ProxyPoolID != nil {
+		region := egress.GetTracker().Country(account.ID)
+		manager := proxypool.GetManager()
+		if p := manager.Select(account.ID, *account.ProxyPoolID, region); p != nil {
+			return p
+		}
+		if region != "" && manager.RegionBlocked(*account.ProxyPoolID, region) {
+			return nil
+		}
+	}
 	if account.Proxy != nil && account.Proxy.Enabled {
-		return account.Proxy.GetURL()
+		return account.Proxy
 	}
+	return nil
+}
 
-	return ""
+// EgressRegionBlocked 账户绑定的代理池内没有其出口地区的代理且不允许跨地区分配（此时不应调度该账户）
+func EgressRegionBlocked(account *model.Account) bool {
+	if account == nil || account.ProxyPoolID == nil {
+		return false
+	}
+	// 强制直连的账户不使用代理池
+	if account.Type == model.AccountTypeOpenAIResponses && (account.AuthType == "xyrt" || account.GatewayURL != "") {
+		return false
+	}
+	region := egress.GetTracker().Country(account.ID)
+	return region != "" && proxypool.GetManager().RegionBlocked(*account.ProxyPoolID, region)
 }
 
 // createProxyClient 创建带代理的 HTTP 客户端
@@ -385,13 +432,13 @@ type ProxyConfig struct {
 // 用于需要绕过 TLS 指纹检测的场景（如 chatgpt.com, claude.ai）
 func GetChromeTLSClient(account *model.Account) *http.Client {
 	var proxyConfig *ProxyConfig
-	if account != nil && account.Proxy != nil && account.Proxy.Enabled {
+	if p := ResolveAccountProxy(account); p != nil {
 		proxyConfig = &ProxyConfig{
-			Type:     account.Proxy.Type,
-			Host:     account.Proxy.Host,
-			Port:     account.Proxy.Port,
-			Username: account.Proxy.Username,
-			Password: account.Proxy.Password,
+			Type:     p.Type,
+			Host:     p.Host,
+			Port:     p.Port,
+			Username: p.Username,
+			Password: p.Password,
 		}
 	}
 	return createChromeTLSClient(proxyConfig)
@@ -451,7 +498,7 @@ func dialWithProxy(network, addr string, proxyConfig *ProxyConfig) (net.Conn, er
 		return dialer.Dial(network, addr)
 	case "http", "https":
 		// HTTP 代理使用 CONNECT 方法
-		proxyAddr := fmt.Sprintf("%s:%d", proxyConfig.Host, proxyConfig.Port)
+		proxyAddr := net.JoinHostPort(proxyConfig.Host, strconv.Itoa(proxyConfig.Port))
 		conn, err := net.DialTimeout("tcp", proxyAddr, 30*time.Second)
 		if err != nil {
 			return nil, err
@@ -302,7 +302,7 @@ func (a *OpenAIResponsesAdapter) setRequestHeaders(httpReq *http.Request, accoun
 func (a *OpenAIResponsesAdapter) handleErrorResponse(resp *http.Response, account *model.Account, log *logger.Logger) (*StreamResult, error) {
 	respBody, _ := ReadResponseBody(resp)
 	log.Error("OpenAI Responses API 错误 - StatusCode: %d, Body: %s", resp.StatusCode, string(respBody))
-	return nil, NewUpstreamError(resp.StatusCode, string(respBody))
+	return nil, NewUpstreamErrorWithHeaders(resp.StatusCode, string(respBody), resp.Header)
 }
 
 // extractCodexUsageFromHeaders 提取 Codex 使用量响应头
@@ -0,0 +1,182 @@
+/*
+ * 文件作用：Prompt Caching 优化器，为 Claude 格式请求自动插入 cache_control 断点
+ * 负责功能：
+ *   - 在工具定义、系统提示词和稳定的对话前缀上放置断点
+ *   - 遵守单请求最多 4 个断点的限制，保留客户端自带的断点
+ * 重要程度：⭐⭐⭐ 一般（可选功能，按 API Key 开启）
+ * 依赖模块：无
+ */
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// MaxCacheBreakpoints Claude 单个请求允许的 cache_control 断点数量上限
+const MaxCacheBreakpoints = 4
+
+// cacheableBlockTypes 可以携带 cache_control 的内容块类型（thinking 类块不支持）
+var cacheableBlockTypes = map[string]bool{
+	"text":        true,
+	"image":       true,
+	"document":    true,
+	"tool_use":    true,
+	"tool_result": true,
+}
+
+// OptimizePromptCache 为 Claude 请求体插入 cache_control 断点
+// 放置优先级：工具定义末尾 → 系统提示词末尾 → 最后一条消息（写入本轮前缀）→ 上一条用户消息（命中上一轮前缀）
+// 返回改写后的请求体和新增的断点数；无需改写时原样返回
+func OptimizePromptCache(raw []byte) ([]byte, int, error) {
+	var body map[string]interface{}
+	if err := json.Unmarshal(raw, &body); err != nil {
+		return raw, 0, fmt.Errorf("parse request body: %w", err)
+	}
+
+	remaining := MaxCacheBreakpoints - countCacheBreakpoints(body)
+	if remaining <= 0 {
+		return raw, 0, nil
+	}
+
+	added := 0
+	mark := func(block map[string]interface{}) {
+		if added >= remaining || block == nil {
+			return
+		}
+		if _, ok := block["cache_control"]; ok {
+			return
+		}
+		block["cache_control"] = map[string]interface{}{"type": "ephemeral"}
+		added++
+	}
+
+	// 1. 工具定义（放在最后一个工具上即可缓存全部工具）
+	if tools, ok := body["tools"].([]interface{}); ok && len(tools) > 0 {
+		if tool, ok := tools[len(tools)-1].(map[string]interface{}); ok && !hasCacheControlIn(tools) {
+			mark(tool)
+		}
+	}
+
+	// 2. 系统提示词
+	switch system := body["system"].(type) {
+	case string:
+		if system != "" && added < remaining {
+			block := map[string]interface{}{"type": "text", "text": system}
+			mark(block)
+			body["system"] = []interface{}{block}
+		}
+	case []interface{}:
+		if !hasCacheControlIn(system) {
+			mark(lastCacheableBlock(system))
+		}
+	}
+
+	// 3. 对话前缀：最后一条消息和上一条用户消息
+	messages, _ := body["messages"].([]interface{})
+	if len(messages) > 0 {
+		mark(messageCacheBlock(messages[len(messages)-1]))
+		for i := len(messages) - 2; i >= 0; i-- {
+			if msg, ok := messages[i].(map[string]interface{}); ok && msg["role"] == "user" {
+				mark(messageCacheBlock(msg))
+				break
+			}
+		}
+	}
+
+	if added == 0 {
+		return raw, 0, nil
+	}
+	out, err := json.Marshal(body)
+	if err != nil {
+		return raw, 0, err
+	}
+	return out, added, nil
+}
+
+// messageCacheBlock 获取消息中可放置断点的最后一个内容块（字符串内容会转换为文本块）
+func messageCacheBlock(m interface{}) map[string]interface{} {
+	msg, ok := m.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	switch content := msg["content"].(type) {
+	case string:
+		if content == "" {
+			return nil
+		}
+		block := map[string]interface{}{"type": "text", "text": content}
+		msg["content"] = []interface{}{block}
+		return block
+	case []interface{}:
+		if hasCacheControlIn(content) {
+			return nil
+		}
+		return lastCacheableBlock(content)
+	}
+	return nil
+}
+
+// lastCacheableBlock 获取最后一个可携带 cache_control 的非空内容块
+func lastCacheableBlock(blocks []interface{}) map[string]interface{} {
+	for i := len(blocks) - 1; i >= 0; i-- {
+		block, ok := blocks[i].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		blockType, _ := block["type"].(string)
+		if !cacheableBlockTypes[blockType] {
+			continue
+		}
+		if blockType == "text" {
+			if text, _ := block["text"].(string); text == "" {
+				continue
+			}
+		}
+		return block
+	}
+	return nil
+}
+
+// hasCacheControlIn 判断一组块中是否已有断点
+func hasCacheControlIn(blocks []interface{}) bool {
+	for _, b := range blocks {
+		if block, ok := b.(map[string]interface{}); ok {
+			if _, ok := block["cache_control"]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// countCacheBreakpoints 统计请求中已有的断点数量
+func countCacheBreakpoints(body map[string]interface{}) int {
+	count := 0
+	countIn := func(blocks []interface{}) {
+		for _, b := range blocks {
+			if block, ok := b.(map[string]interface{}); ok {
+				if _, ok := block["cache_control"]; ok {
+					count++
+				}
+			}
+		}
+	}
+
+	if tools, ok := body["tools"].([]interface{}); ok {
+		countIn(tools)
+	}
+	if system, ok := body["system"].([]interface{}); ok {
+		countIn(system)
+	}
+	if messages, ok := body["messages"].([]interface{}); ok {
+		for _, m := range messages {
+			if msg, ok := m.(map[string]interface{}); ok {
+				if content, ok := msg["content"].([]interface{}); ok {
+					countIn(content)
+				}
+			}
+		}
+	}
+	return count
+}
@@ -0,0 +1,71 @@
+package adapter
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestOptimizePromptCachePlacesBreakpoints(t *testing.T) {
+	raw := []byte(`{
+		"model": "claude-sonnet",
+		"system": "You are a helpful assistant.",
+		"tools": [{"name": "a"}, {"name": "b"}],
+		"messages": [
+			{"role": "user", "content": "first"},
+			{"role": "assistant", "content": "reply"},
+			{"role": "user", "content": [{"type": "text", "text": "second"}]}
+		]
+	}`)
+
+	out, added, err := OptimizePromptCache(raw)
+	if err != nil {
+		t.Fatalf("optimize: %v", err)
+	}
+	if added != MaxCacheBreakpoints {
+		t.Fatalf("expected %d breakpoints, got %d", MaxCacheBreakpoints, added)
+	}
+
+	var body map[string]interface{}
+	if err := json.Unmarshal(out, &body); err != nil {
+		t.Fatalf("parse output: %v", err)
+	}
+	if countCacheBreakpoints(body) != MaxCacheBreakpoints {
+		t.Fatalf("unexpected breakpoint count in %s", out)
+	}
+	tools := body["tools"].([]interface{})
+	if _, ok := tools[1].(map[string]interface{})["cache_control"]; !ok {
+		t.Fatal("last tool should carry a breakpoint")
+	}
+	first := body["messages"].([]interface{})[0].(map[string]interface{})
+	if content, ok := first["content"].([]interface{}); !ok || !hasCacheControlIn(content) {
+		t.Fatal("previous user turn should carry a breakpoint")
+	}
+}
+
+func TestOptimizePromptCacheRespectsLimit(t *testing.T) {
+	raw := []byte(`{
+		"system": [
+			{"type": "text", "text": "a", "cache_control": {"type": "ephemeral"}},
+			{"type": "text", "text": "b", "cache_control": {"type": "ephemeral"}},
+			{"type": "text", "text": "c", "cache_control": {"type": "ephemeral"}}
+		],
+		"messages": [
+			{"role": "user", "content": "one"},
+			{"role": "assistant", "content": "two"},
+			{"role": "user", "content": "three"}
+		]
+	}`)
+
+	out, added, err := OptimizePromptCache(raw)
+	if err != nil {
+		t.Fatalf("optimize: %v", err)
+	}
+	if added != 1 {
+		t.Fatalf("expected 1 breakpoint, got %d", added)
+	}
+	var body map[string]interface{}
+	json.Unmarshal(out, &body)
+	if countCacheBreakpoints(body) != MaxCacheBreakpoints {
+		t.Fatalf("total breakpoints should not exceed the limit: %s", out)
+	}
+}
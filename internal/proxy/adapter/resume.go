@@ -0,0 +1,530 @@
+/*
+ * 文件作用：流式中断续传，记录已输出的助手文本并在中断后拼接续写流
+ * 负责功能：
+ *   - ResumeWriter 包装客户端写入器，跟踪已输出文本、内容块索引和完成状态
+ *   - 扣留中途出现的上游错误事件，由调用方决定续传或失败
+ *   - 构建续写请求（Claude：assistant 预填充；OpenAI：追加续写提示）
+ *   - 续写流拼接：去掉重复的 message_start/角色块，修正内容块索引和 usage
+ * 重要程度：⭐⭐⭐ 一般（可选功能，默认关闭）
+ * 依赖模块：无
+ */
+package adapter
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// 续传支持的流格式
+const (
+	ResumeFormatClaude = "claude" // Anthropic Messages SSE
+	ResumeFormatOpenAI = "openai" // OpenAI Chat Completions SSE
+)
+
+// openAIContinuePrompt OpenAI 格式续写提示（不支持 assistant 预填充）
+const openAIContinuePrompt = "Continue exactly where you stopped. Do not repeat any text you have already written."
+
+// ErrNotResumable 当前进度无法续传
+var ErrNotResumable = errors.New("stream is not resumable")
+
+// ProgressWriter 可报告本次尝试是否已向客户端输出内容的写入器
+// 重试逻辑据此判断能否原样重试（已输出内容时原样重试会导致重复输出）
+type ProgressWriter interface {
+	io.Writer
+	BeginAttempt()
+	AttemptStarted() bool
+}
+
+// ResumeWriter 流式续传写入器
+// 按行解析上游 SSE，原样转发给客户端，同时记录续传所需的状态；
+// 进入续写模式后对续写流做拼接改写，使客户端看到的是一条连续的流
+type ResumeWriter struct {
+	mu     sync.Mutex
+	w      io.Writer
+	format string
+
+	pending   []byte // 未完整的行
+	heldEvent string // Claude：等待对应 data 行的 event 行
+	dropBlank bool   // 上一条事件被丢弃，跳过其后的空行
+	lastBlank bool   // 客户端收到的最后一行是否为空行（事件边界）
+
+	started        bool // 是否已向客户端输出过内容
+	attemptStarted bool // 本次尝试是否已向客户端输出过内容
+	text           strings.Builder
+	blockCount     int    // 客户端已见到的内容块数量
+	openIndex      int    // 未结束的内容块索引，-1 表示无
+	nonText        bool   // 输出过非文本内容（tool_use/thinking 等），无法续传
+	finished       bool   // 已输出结束事件
+	messageID      string // OpenAI：首个 chunk 的 id，续写时沿用
+	upstreamErr    error  // 扣留的上游错误事件
+
+	continuing        bool
+	indexOffset       int
+	mergeFirst        bool // 续写的第一个文本块合并到客户端未结束的文本块
+	priorOutputTokens int
+}
+
+// NewResumeWriter 创建续传写入器，format 为 ResumeFormatClaude 或 ResumeFormatOpenAI
+func NewResumeWriter(w io.Writer, format string) *ResumeWriter {
+	return &ResumeWriter{
+		w:         w,
+		format:    format,
+		openIndex: -1,
+		lastBlank: true,
+	}
+}
+
+// Write 实现 io.Writer 接口
+func (rw *ResumeWriter) Write(p []byte) (int, error) {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+
+	rw.pending = append(rw.pending, p...)
+	for {
+		idx := bytes.IndexByte(rw.pending, '\n')
+		if idx < 0 {
+			break
+		}
+		line := strings.TrimSuffix(string(rw.pending[:idx]), "\r")
+		rw.pending = rw.pending[idx+1:]
+		if err := rw.processLine(line); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush 实现 http.Flusher 接口
+func (rw *ResumeWriter) Flush() {
+	if f, ok := rw.w.(interface{ Flush() }); ok {
+		f.Flush()
+	}
+}
+
+// BeginAttempt 开始一次新的上游尝试，丢弃上一次尝试残留的半行数据
+func (rw *ResumeWriter) BeginAttempt() {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	rw.attemptStarted = false
+	rw.pending = nil
+	rw.heldEvent = ""
+	rw.dropBlank = false
+	rw.upstreamErr = nil
+}
+
+// AttemptStarted 本次尝试是否已向客户端输出内容
+func (rw *ResumeWriter) AttemptStarted() bool {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	return rw.attemptStarted
+}
+
+// Started 是否已向客户端输出过内容
+func (rw *ResumeWriter) Started() bool {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	return rw.started
+}
+
+// Err 返回本次尝试中被扣留的上游错误事件（上游以错误事件结束流时适配器不会返回错误）
+func (rw *ResumeWriter) Err() error {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	return rw.upstreamErr
+}
+
+// Text 已输出的助手文本
+func (rw *ResumeWriter) Text() string {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	return rw.text.String()
+}
+
+// Resumable 当前进度是否可以续传：已开始输出、仅包含文本、尚未结束
+func (rw *ResumeWriter) Resumable() bool {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	return rw.resumableLocked()
+}
+
+func (rw *ResumeWriter) resumableLocked() bool {
+	if rw.format != ResumeFormatClaude && rw.format != ResumeFormatOpenAI {
+		return false
+	}
+	return rw.started && !rw.nonText && !rw.finished
+}
+
+// SetPriorOutputTokens 设置此前各次尝试已产生的输出 token 数，续写流的 usage 会加上该值
+func (rw *ResumeWriter) SetPriorOutputTokens(tokens int) {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	rw.priorOutputTokens = tokens
+}
+
+// Continue 基于原始请求构建续写请求，并进入续写模式
+func (rw *ResumeWriter) Continue(orig *Request) (*Request, error) {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+
+	if !rw.resumableLocked() {
+		return nil, ErrNotResumable
+	}
+
+	next := *orig
+	prefix := strings.TrimRight(rw.text.String(), " \t\r\n")
+	if prefix != "" {
+		switch rw.format {
+		case ResumeFormatClaude:
+			body, err := buildClaudePrefillBody(orig.RawBody, prefix)
+			if err != nil {
+				return nil, err
+			}
+			next.RawBody = body
+		case ResumeFormatOpenAI:
+			messages := make([]Message, 0, len(orig.Messages)+2)
+			messages = append(messages, orig.Messages...)
+			messages = append(messages,
+				Message{Role: "assistant", Content: prefix},
+				Message{Role: "user", Content: openAIContinuePrompt},
+			)
+			next.Messages = messages
+		}
+	}
+
+	// 客户端停在事件中间时补一个空行，避免续写事件与上一事件粘连
+	if !rw.lastBlank {
+		if _, err := rw.w.Write([]byte("\n")); err != nil {
+			return nil, err
+		}
+		rw.lastBlank = true
+	}
+
+	rw.continuing = true
+	rw.mergeFirst = rw.format == ResumeFormatClaude && rw.openIndex >= 0
+	if rw.mergeFirst {
+		rw.indexOffset = rw.openIndex
+	} else {
+		rw.indexOffset = rw.blockCount
+	}
+	rw.pending = nil
+	rw.heldEvent = ""
+	rw.dropBlank = false
+	rw.upstreamErr = nil
+	return &next, nil
+}
+
+// buildClaudePrefillBody 在 Claude 请求体末尾追加 assistant 预填充
+// 原请求最后一条已是 assistant 消息（客户端预填充）时直接拼接在其后
+func buildClaudePrefillBody(raw []byte, prefix string) ([]byte, error) {
+	var body map[string]interface{}
+	if err := json.Unmarshal(raw, &body); err != nil {
+		return nil, fmt.Errorf("parse request body: %w", err)
+	}
+	messages, _ := body["messages"].([]interface{})
+
+	if n := len(messages); n > 0 {
+		if last, ok := messages[n-1].(map[string]interface{}); ok && last["role"] == "assistant" {
+			switch content := last["content"].(type) {
+			case string:
+				last["content"] = content + prefix
+			case []interface{}:
+				last["content"] = append(content, map[string]interface{}{"type": "text", "text": prefix})
+			default:
+				last["content"] = prefix
+			}
+			return json.Marshal(body)
+		}
+	}
+
+	body["messages"] = append(messages, map[string]interface{}{
+		"role":    "assistant",
+		"content": prefix,
+	})
+	return json.Marshal(body)
+}
+
+// processLine 处理一行 SSE 数据
+func (rw *ResumeWriter) processLine(line string) error {
+	switch {
+	case strings.HasPrefix(line, "event:"):
+		if rw.format == ResumeFormatClaude {
+			rw.heldEvent = line
+			return nil
+		}
+		return rw.emit(line+"\n", false)
+	case strings.HasPrefix(line, "data:"):
+		event := rw.heldEvent
+		rw.heldEvent = ""
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		out, keep, err := rw.rewriteData(data)
+		if err != nil {
+			return err
+		}
+		if !keep {
+			rw.dropBlank = true
+			return nil
+		}
+		rw.dropBlank = false
+		if out != data {
+			line = "data: " + out
+		}
+		if event != "" {
+			line = event + "\n" + line
+		}
+		return rw.emit(line+"\n", true)
+	case line == "":
+		if rw.dropBlank {
+			rw.dropBlank = false
+			return nil
+		}
+		if rw.heldEvent != "" {
+			event := rw.heldEvent
+			rw.heldEvent = ""
+			return rw.emit(event+"\n\n", false)
+		}
+		return rw.emit("\n", false)
+	default:
+		// SSE 注释（心跳）等
+		return rw.emit(line+"\n", false)
+	}
+}
+
+// emit 向客户端写出数据，content 表示是否为有效内容（心跳等不计入）
+func (rw *ResumeWriter) emit(s string, content bool) error {
+	if _, err := rw.w.Write([]byte(s)); err != nil {
+		return err
+	}
+	rw.lastBlank = s == "\n" || strings.HasSuffix(s, "\n\n")
+	if content {
+		rw.started = true
+		rw.attemptStarted = true
+	}
+	return nil
+}
+
+// rewriteData 解析 data 内容，更新状态并按需改写；keep=false 表示丢弃该事件
+func (rw *ResumeWriter) rewriteData(data string) (string, bool, error) {
+	if data == "[DONE]" {
+		rw.finished = true
+		return data, true, nil
+	}
+	var event map[string]interface{}
+	if err := json.Unmarshal([]byte(data), &event); err != nil {
+		return data, true, nil
+	}
+	if rw.format == ResumeFormatOpenAI {
+		return rw.rewriteOpenAIChunk(data, event)
+	}
+	return rw.rewriteClaudeEvent(data, event)
+}
+
+// rewriteClaudeEvent 处理 Claude SSE 事件
+func (rw *ResumeWriter) rewriteClaudeEvent(data string, event map[string]interface{}) (string, bool, error) {
+	changed := false
+	switch event["type"] {
+	case "error":
+		rw.upstreamErr = upstreamErrorFromEvent(event)
+		return "", false, nil
+
+	case "message_start":
+		if rw.continuing {
+			return "", false, nil
+		}
+
+	case "content_block_start":
+		index := jsonInt(event["index"])
+		block, _ := event["content_block"].(map[string]interface{})
+		blockType, _ := block["type"].(string)
+
+		if rw.continuing && rw.mergeFirst && index == 0 {
+			rw.mergeFirst = false
+			if blockType == "text" {
+				// 续写的首个文本块接在客户端未结束的文本块之后
+				return "", false, nil
+			}
+			// 续写以非文本块开头：先结束客户端未结束的块，再追加到末尾
+			if err := rw.emitClaudeBlockStop(rw.openIndex); err != nil {
+				return "", false, err
+			}
+			rw.openIndex = -1
+			rw.indexOffset = rw.blockCount
+		}
+
+		if blockType != "text" {
+			rw.nonText = true
+		} else if text, ok := block["text"].(string); ok {
+			rw.text.WriteString(text)
+		}
+		mapped := rw.mapIndex(index)
+		if mapped != index {
+			event["index"] = mapped
+			changed = true
+		}
+		rw.openIndex = mapped
+		if mapped+1 > rw.blockCount {
+			rw.blockCount = mapped + 1
+		}
+
+	case "content_block_delta":
+		index := jsonInt(event["index"])
+		if delta, ok := event["delta"].(map[string]interface{}); ok && delta["type"] == "text_delta" {
+			if text, ok := delta["text"].(string); ok {
+				rw.text.WriteString(text)
+			}
+		}
+		if mapped := rw.mapIndex(index); mapped != index {
+			event["index"] = mapped
+			changed = true
+		}
+
+	case "content_block_stop":
+		index := jsonInt(event["index"])
+		mapped := rw.mapIndex(index)
+		if mapped != index {
+			event["index"] = mapped
+			changed = true
+		}
+		if mapped == rw.openIndex {
+			rw.openIndex = -1
+		}
+
+	case "message_delta":
+		if rw.continuing && rw.priorOutputTokens > 0 {
+			if usage, ok := event["usage"].(map[string]interface{}); ok {
+				usage["output_tokens"] = jsonInt(usage["output_tokens"]) + rw.priorOutputTokens
+				changed = true
+			}
+		}
+
+	case "message_stop":
+		rw.finished = true
+	}
+
+	if !changed {
+		return data, true, nil
+	}
+	out, err := json.Marshal(event)
+	if err != nil {
+		return data, true, nil
+	}
+	return string(out), true, nil
+}
+
+// emitClaudeBlockStop 补发内容块结束事件
+func (rw *ResumeWriter) emitClaudeBlockStop(index int) error {
+	return rw.emit(fmt.Sprintf("event: content_block_stop\ndata: {\"type\":\"content_block_stop\",\"index\":%d}\n\n", index), true)
+}
+
+// rewriteOpenAIChunk 处理 OpenAI Chat Completions chunk
+func (rw *ResumeWriter) rewriteOpenAIChunk(data string, chunk map[string]interface{}) (string, bool, error) {
+	if _, ok := chunk["error"]; ok {
+		rw.upstreamErr = upstreamErrorFromEvent(chunk)
+		return "", false, nil
+	}
+
+	changed := false
+	if id, _ := chunk["id"].(string); id != "" {
+		if rw.messageID == "" {
+			rw.messageID = id
+		} else if rw.continuing && id != rw.messageID {
+			chunk["id"] = rw.messageID
+			changed = true
+		}
+	}
+
+	choices, _ := chunk["choices"].([]interface{})
+	for _, c := range choices {
+		choice, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if reason, ok := choice["finish_reason"].(string); ok && reason != "" {
+			rw.finished = true
+		}
+		delta, ok := choice["delta"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if _, ok := delta["tool_calls"]; ok {
+			rw.nonText = true
+		}
+		if _, ok := delta["function_call"]; ok {
+			rw.nonText = true
+		}
+		content, _ := delta["content"].(string)
+		rw.text.WriteString(content)
+
+		if rw.continuing {
+			if _, ok := delta["role"]; ok {
+				// 续写流的角色块对客户端是重复的
+				if content == "" && len(delta) <= 2 && choice["finish_reason"] == nil {
+					return "", false, nil
+				}
+				delete(delta, "role")
+				changed = true
+			}
+		}
+	}
+
+	if rw.continuing && rw.priorOutputTokens > 0 {
+		if usage, ok := chunk["usage"].(map[string]interface{}); ok {
+			completion := jsonInt(usage["completion_tokens"]) + rw.priorOutputTokens
+			usage["completion_tokens"] = completion
+			if _, ok := usage["total_tokens"]; ok {
+				usage["total_tokens"] = jsonInt(usage["prompt_tokens"]) + completion
+			}
+			changed = true
+		}
+	}
+
+	if !changed {
+		return data, true, nil
+	}
+	out, err := json.Marshal(chunk)
+	if err != nil {
+		return data, true, nil
+	}
+	return string(out), true, nil
+}
+
+// mapIndex 将续写流的内容块索引映射为客户端索引
+func (rw *ResumeWriter) mapIndex(index int) int {
+	if !rw.continuing {
+		return index
+	}
+	return index + rw.indexOffset
+}
+
+// upstreamErrorFromEvent 从错误事件中提取上游错误
+func upstreamErrorFromEvent(event map[string]interface{}) error {
+	message := "upstream stream error"
+	if errObj, ok := event["error"].(map[string]interface{}); ok {
+		errType, _ := errObj["type"].(string)
+		msg, _ := errObj["message"].(string)
+		switch {
+		case errType != "" && msg != "":
+			message = errType + ": " + msg
+		case msg != "":
+			message = msg
+		case errType != "":
+			message = errType
+		}
+	}
+	return NewUpstreamError(500, message)
+}
+
+// jsonInt 将 JSON 数字转换为 int
+func jsonInt(v interface{}) int {
+	switch n := v.(type) {
+	case float64:
+		return int(n)
+	case int:
+		return n
+	}
+	return 0
+}
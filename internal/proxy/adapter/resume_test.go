@@ -0,0 +1,80 @@
+package adapter
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestResumeWriterClaudeSplice(t *testing.T) {
+	var out bytes.Buffer
+	rw := NewResumeWriter(&out, ResumeFormatClaude)
+
+	rw.BeginAttempt()
+	rw.Write([]byte("event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_1\"}}\n\n"))
+	rw.Write([]byte("event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":0,\"content_block\":{\"type\":\"text\",\"text\":\"\"}}\n\n"))
+	rw.Write([]byte("event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"Hello wor\"}}\n\n"))
+	rw.Write([]byte("event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\",\"message\":\"Overloaded\"}}\n\n"))
+
+	if rw.Err() == nil {
+		t.Fatal("upstream error event should be held")
+	}
+	if strings.Contains(out.String(), "overloaded_error") {
+		t.Fatal("upstream error event should not reach the client")
+	}
+	if !rw.Resumable() {
+		t.Fatal("text-only stream should be resumable")
+	}
+
+	orig := &Request{RawBody: []byte(`{"model":"claude","messages":[{"role":"user","content":"hi"}],"stream":true}`)}
+	next, err := rw.Continue(orig)
+	if err != nil {
+		t.Fatalf("continue: %v", err)
+	}
+	var body struct {
+		Messages []map[string]interface{} `json:"messages"`
+	}
+	if err := json.Unmarshal(next.RawBody, &body); err != nil {
+		t.Fatalf("parse continuation body: %v", err)
+	}
+	if n := len(body.Messages); n != 2 || body.Messages[1]["role"] != "assistant" || body.Messages[1]["content"] != "Hello wor" {
+		t.Fatalf("unexpected prefill %v", body.Messages)
+	}
+
+	rw.SetPriorOutputTokens(3)
+	rw.BeginAttempt()
+	before := out.Len()
+	rw.Write([]byte("event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_2\"}}\n\n"))
+	rw.Write([]byte("event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":0,\"content_block\":{\"type\":\"text\",\"text\":\"\"}}\n\n"))
+	rw.Write([]byte("event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"ld\"}}\n\n"))
+	rw.Write([]byte("event: content_block_stop\ndata: {\"type\":\"content_block_stop\",\"index\":0}\n\n"))
+	rw.Write([]byte("event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":1,\"content_block\":{\"type\":\"text\",\"text\":\"\"}}\n\n"))
+	rw.Write([]byte("event: message_delta\ndata: {\"type\":\"message_delta\",\"usage\":{\"output_tokens\":2}}\n\n"))
+
+	spliced := out.String()[before:]
+	if strings.Contains(spliced, "message_start") || strings.Contains(spliced, `"index":0,"content_block"`) {
+		t.Fatalf("duplicate start events should be dropped: %q", spliced)
+	}
+	if !strings.Contains(spliced, `"index":1,"content_block"`) {
+		t.Fatalf("second continuation block should follow the merged block: %q", spliced)
+	}
+	if !strings.Contains(spliced, `"output_tokens":5`) {
+		t.Fatalf("usage should include prior output: %q", spliced)
+	}
+	if rw.Text() != "Hello world" {
+		t.Fatalf("unexpected text %q", rw.Text())
+	}
+}
+
+func TestResumeWriterToolUseNotResumable(t *testing.T) {
+	rw := NewResumeWriter(&bytes.Buffer{}, ResumeFormatClaude)
+	rw.BeginAttempt()
+	rw.Write([]byte("event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":0,\"content_block\":{\"type\":\"tool_use\"}}\n\n"))
+	if rw.Resumable() {
+		t.Fatal("tool_use output should not be resumable")
+	}
+	if !rw.AttemptStarted() {
+		t.Fatal("attempt should be marked as started")
+	}
+}
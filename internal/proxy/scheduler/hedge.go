@@ -0,0 +1,379 @@
+/*
+ * 文件作用：对冲请求（Hedged Request），降低延迟敏感 Key 的尾延迟
+ * 负责功能：
+ *   - 首个账户超过指定时间未产出首字节时，向另一账户发起第二路请求
+ *   - 首个写出有效数据的一路胜出，另一路通过 context 取消
+ *   - 落败一路的并发槽位释放、Token 预扣结算与部分用量回调（单独计费）
+ * 重要程度：⭐⭐⭐ 一般（可选功能，默认关闭）
+ * 依赖模块：cache, model, adapter
+ */
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"cli-proxy/internal/cache"
+	"cli-proxy/internal/model"
+	"cli-proxy/internal/proxy/adapter"
+	"cli-proxy/pkg/logger"
+)
+
+// HedgeLoserFunc 对冲落败回调，用于单独记录被取消一路的部分用量
+// usage 可能为 nil（上游尚未返回任何 usage 信息）
+type HedgeLoserFunc func(account *model.Account, modelName string, usage *adapter.StreamResult, err error)
+
+// WithHedging 启用对冲请求
+// delay 为首个账户无响应多久后发起第二路请求，<=0 表示关闭
+func (r *RetryableRequest) WithHedging(delay time.Duration, onLoser HedgeLoserFunc) *RetryableRequest {
+	r.HedgeDelay = delay
+	r.OnHedgeLoser = onLoser
+	return r
+}
+
+// hedgeAttempt 对冲请求中的单路尝试
+type hedgeAttempt struct {
+	account  *model.Account
+	proxyID  uint         // 发起请求时使用的出口代理（直连为 egress.DirectProxyID）
+	writer   *hedgeWriter // 流式请求时使用
+	cancel   context.CancelFunc
+	done     chan struct{}
+	response *adapter.Response
+	result   *adapter.StreamResult
+	err      error
+}
+
+// failed 判断该路尝试是否失败
+func (a *hedgeAttempt) failed() bool {
+	return a.err != nil || (a.response != nil && a.response.Error != nil)
+}
+
+// usage 获取该路尝试已产生的用量
+func (a *hedgeAttempt) usage() *adapter.StreamResult {
+	if a.result != nil {
+		return a.result
+	}
+	return responseUsage(a.response)
+}
+
+// hedgeRace 对冲竞速状态：首个写出有效数据（或成功完成）的尝试胜出
+type hedgeRace struct {
+	mu     sync.Mutex
+	dst    io.Writer
+	winner *hedgeAttempt
+	won    chan struct{}
+}
+
+func newHedgeRace(dst io.Writer) *hedgeRace {
+	return &hedgeRace{
+		dst: dst,
+		won: make(chan struct{}),
+	}
+}
+
+// claim 尝试让 a 成为胜出者，返回 a 是否为胜出者
+func (h *hedgeRace) claim(a *hedgeAttempt) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.winner == nil {
+		h.winner = a
+		close(h.won)
+		return true
+	}
+	return h.winner == a
+}
+
+// isWinner 判断 a 是否已胜出
+func (h *hedgeRace) isWinner(a *hedgeAttempt) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.winner == a
+}
+
+// hedgeWriter 单路尝试的写入器
+// 胜出前的心跳和错误事件不参与竞速，直接丢弃；落败一路的输出全部丢弃
+type hedgeWriter struct {
+	race    *hedgeRace
+	attempt *hedgeAttempt
+}
+
+// Write 实现 io.Writer 接口
+func (w *hedgeWriter) Write(p []byte) (int, error) {
+	if w.race.isWinner(w.attempt) {
+		return w.race.dst.Write(p)
+	}
+	if isHedgeNeutralChunk(p) {
+		return len(p), nil
+	}
+	if w.race.claim(w.attempt) {
+		return w.race.dst.Write(p)
+	}
+	return len(p), nil
+}
+
+// Flush 实现 http.Flusher 接口（仅胜出一路刷新）
+func (w *hedgeWriter) Flush() {
+	if !w.race.isWinner(w.attempt) {
+		return
+	}
+	if f, ok := w.race.dst.(interface{ Flush() }); ok {
+		f.Flush()
+	}
+}
+
+// isHedgeNeutralChunk 判断数据块是否为不参与竞速的内容（SSE 注释/心跳、错误事件）
+func isHedgeNeutralChunk(p []byte) bool {
+	trimmed := bytes.TrimLeft(p, "\r\n")
+	if len(trimmed) == 0 {
+		return true
+	}
+	switch {
+	case bytes.HasPrefix(trimmed, []byte(":")):
+		return true
+	case bytes.HasPrefix(trimmed, []byte("event: error")):
+		return true
+	case bytes.HasPrefix(trimmed, []byte(`data: {"type":"error"`)):
+		return true
+	case bytes.HasPrefix(trimmed, []byte(`data: {"error"`)):
+		return true
+	}
+	return false
+}
+
+// awaitHedgeWinner 等待两路尝试决出胜负
+// 胜出条件：先写出有效数据，或先成功完成；两路都失败时以第一路为准
+func awaitHedgeWinner(race *hedgeRace, first, second *hedgeAttempt) (winner, loser *hedgeAttempt) {
+	firstDone, secondDone := first.done, second.done
+	for {
+		select {
+		case <-race.won:
+			if race.isWinner(first) {
+				return first, second
+			}
+			return second, first
+		case <-firstDone:
+			firstDone = nil
+			if !first.failed() && race.claim(first) {
+				return first, second
+			}
+			if secondDone == nil {
+				return first, second
+			}
+		case <-secondDone:
+			secondDone = nil
+			if !second.failed() && race.claim(second) {
+				return second, first
+			}
+			if firstDone == nil {
+				return first, second
+			}
+		}
+	}
+}
+
+// executeHedged 非流式对冲执行：primary 超过 HedgeDelay 未返回时向另一账户发起第二路请求，先成功者胜出
+// primary 的并发槽位由调用方负责释放；返回账户（胜出一路）的 Token 预扣由调用方结算
+func (r *RetryableRequest) executeHedged(
+	ctx context.Context,
+	modelName string,
+	primary *model.Account,
+	execFunc func(ctx context.Context, account *model.Account) (*adapter.Response, error),
+) (*model.Account, uint, *adapter.Response, error) {
+	race := newHedgeRace(nil)
+	start := func(account *model.Account) *hedgeAttempt {
+		return r.startHedgeAttempt(ctx, account, race, func(attemptCtx context.Context, a *hedgeAttempt) {
+			a.response, a.err = execFunc(attemptCtx, account)
+		})
+	}
+
+	winner := r.runHedgeRace(ctx, modelName, primary, race, start)
+	return winner.account, winner.proxyID, winner.response, winner.err
+}
+
+// executeStreamHedged 流式对冲执行：primary 超过 HedgeDelay 未写出首字节时向另一账户发起第二路请求，先写出数据者胜出
+// primary 的并发槽位由调用方负责释放；返回账户（胜出一路）的 Token 预扣由调用方结算
+func (r *RetryableRequest) executeStreamHedged(
+	ctx context.Context,
+	modelName string,
+	primary *model.Account,
+	execFunc func(ctx context.Context, account *model.Account, writer io.Writer) (*adapter.StreamResult, error),
+	writer io.Writer,
+) (*model.Account, uint, *adapter.StreamResult, error) {
+	race := newHedgeRace(writer)
+	start := func(account *model.Account) *hedgeAttempt {
+		return r.startHedgeAttempt(ctx, account, race, func(attemptCtx context.Context, a *hedgeAttempt) {
+			a.result, a.err = execFunc(attemptCtx, account, a.writer)
+		})
+	}
+
+	winner := r.runHedgeRace(ctx, modelName, primary, race, start)
+	return winner.account, winner.proxyID, winner.result, winner.err
+}
+
+// startHedgeAttempt 启动单路尝试
+func (r *RetryableRequest) startHedgeAttempt(ctx context.Context, account *model.Account, race *hedgeRace, run func(ctx context.Context, a *hedgeAttempt)) *hedgeAttempt {
+	attemptCtx, cancel := context.WithCancel(ctx)
+	a := &hedgeAttempt{
+		account: account,
+		proxyID: dispatchProxyID(account),
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+	a.writer = &hedgeWriter{race: race, attempt: a}
+	go func() {
+		defer close(a.done)
+		run(attemptCtx, a)
+	}()
+	return a
+}
+
+// runHedgeRace 执行对冲竞速，返回胜出的一路（两路均已结束）
+// 落败一路的 Token 预扣在此按其已产生的用量结算，胜出一路的预扣留给调用方
+func (r *RetryableRequest) runHedgeRace(
+	ctx context.Context,
+	modelName string,
+	primary *model.Account,
+	race *hedgeRace,
+	start func(account *model.Account) *hedgeAttempt,
+) *hedgeAttempt {
+	log := logger.GetLogger("scheduler")
+
+	first := start(primary)
+	defer first.cancel()
+
+	timer := time.NewTimer(r.HedgeDelay)
+	defer timer.Stop()
+
+	select {
+	case <-first.done:
+		return first
+	case <-race.won:
+		<-first.done
+		return first
+	case <-timer.C:
+	}
+
+	hedgeAccount, release := r.acquireHedgeAccount(ctx, modelName, primary)
+	if hedgeAccount == nil {
+		<-first.done
+		return first
+	}
+	defer release()
+
+	log.InfoZ("发起对冲请求",
+		logger.String("model", modelName),
+		logger.Uint("primary_account_id", primary.ID),
+		logger.Uint("hedge_account_id", hedgeAccount.ID),
+		logger.String("hedge_account_name", hedgeAccount.Name),
+		logger.Duration("hedge_delay", r.HedgeDelay),
+		logger.Uint("api_key_id", r.APIKeyID),
+	)
+
+	second := start(hedgeAccount)
+	defer second.cancel()
+
+	winner, loser := awaitHedgeWinner(race, first, second)
+	loser.cancel()
+	<-winner.done
+	<-loser.done
+
+	log.InfoZ("对冲请求决出胜负",
+		logger.String("model", modelName),
+		logger.Uint("winner_account_id", winner.account.ID),
+		logger.Uint("loser_account_id", loser.account.ID),
+		logger.Bool("winner_failed", winner.failed()),
+		logger.Uint("api_key_id", r.APIKeyID),
+	)
+
+	if usage := loser.usage(); usage != nil {
+		r.settleAccountTokens(loser.account, UsageFromResult(*usage))
+	} else {
+		r.refundAccountTokens(loser.account)
+	}
+
+	r.bindSessionAccount(ctx, modelName, winner.account)
+	if r.OnHedgeLoser != nil {
+		originalModel := r.OriginalModel
+		if originalModel == "" {
+			originalModel = GetActualModel(modelName)
+		}
+		r.OnHedgeLoser(loser.account, originalModel, loser.usage(), loser.err)
+	}
+	return winner
+}
+
+// acquireHedgeAccount 选择对冲账户，预扣其 Token 额度并获取并发槽位
+// 返回的 release 用于释放对冲账户的并发槽位（Token 预扣由 runHedgeRace 结算）；无可用账户时返回 nil
+func (r *RetryableRequest) acquireHedgeAccount(ctx context.Context, modelName string, primary *model.Account) (*model.Account, func()) {
+	log := logger.GetLogger("scheduler")
+
+	// 临时将 primary 标记为已尝试，确保对冲账户不同于 primary
+	wasTried := r.triedAccounts[primary.ID]
+	r.triedAccounts[primary.ID] = true
+	account, err := r.selectNextAccount(ctx, modelName)
+	if !wasTried {
+		delete(r.triedAccounts, primary.ID)
+	}
+	if err != nil || account == nil {
+		log.DebugZ("无可用对冲账户",
+			logger.String("model", modelName),
+			logger.Uint("primary_account_id", primary.ID),
+		)
+		return nil, nil
+	}
+	if !r.reserveAccountTokens(account) {
+		return nil, nil
+	}
+
+	sessionCache := r.Scheduler.GetSessionCache()
+	if sessionCache == nil {
+		return account, func() {}
+	}
+
+	limit := account.MaxConcurrency
+	if limit <= 0 {
+		limit = 5 // 默认值
+	}
+	acquired, _, err := sessionCache.AcquireConcurrencyWithLimit(ctx, account.ID, limit)
+	if err != nil {
+		// 与主流程一致：缓存错误不阻止请求
+		acquired = true
+	}
+	if !acquired {
+		r.refundAccountTokens(account)
+		log.DebugZ("对冲账户并发已满，放弃对冲",
+			logger.Uint("account_id", account.ID),
+			logger.Int("limit", limit),
+		)
+		return nil, nil
+	}
+
+	return account, func() {
+		sessionCache.ReleaseConcurrency(context.Background(), account.ID)
+		GetFairQueue().NotifyReleased(account)
+	}
+}
+
+// bindSessionAccount 将会话绑定到指定账户（对冲胜出后修正会话粘性）
+func (r *RetryableRequest) bindSessionAccount(ctx context.Context, modelName string, account *model.Account) {
+	if r.SessionID == "" || account == nil {
+		return
+	}
+	sessionCache := r.Scheduler.GetSessionCache()
+	if sessionCache == nil {
+		return
+	}
+	sessionCache.SetSessionBinding(ctx, &cache.SessionBinding{
+		SessionID: r.SessionID,
+		AccountID: account.ID,
+		Platform:  account.Platform,
+		Model:     modelName,
+		UserID:    r.UserID,
+		APIKeyID:  r.APIKeyID,
+		ClientIP:  r.ClientIP,
+		UserAgent: r.UserAgent,
+	})
+}
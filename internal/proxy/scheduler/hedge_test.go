@@ -0,0 +1,86 @@
+package scheduler
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"cli-proxy/internal/proxy/adapter"
+	"cli-proxy/internal/ratelimit"
+)
+
+func newTestHedgeAttempt(race *hedgeRace) *hedgeAttempt {
+	a := &hedgeAttempt{done: make(chan struct{})}
+	a.writer = &hedgeWriter{race: race, attempt: a}
+	return a
+}
+
+func TestHedgeWriterFirstDataWins(t *testing.T) {
+	var dst bytes.Buffer
+	race := newHedgeRace(&dst)
+	first := newTestHedgeAttempt(race)
+	second := newTestHedgeAttempt(race)
+
+	// 心跳和错误事件不参与竞速
+	first.writer.Write([]byte(": keepalive\n\n"))
+	first.writer.Write([]byte("event: error\ndata: {\"type\":\"error\"}\n\n"))
+	if race.winner != nil {
+		t.Fatal("neutral chunks should not claim the race")
+	}
+
+	second.writer.Write([]byte("event: message_start\n"))
+	first.writer.Write([]byte("event: message_start\n"))
+	second.writer.Write([]byte("data: {}\n"))
+
+	if !race.isWinner(second) {
+		t.Fatal("second attempt should win the race")
+	}
+	if got := dst.String(); got != "event: message_start\ndata: {}\n" {
+		t.Fatalf("unexpected output %q", got)
+	}
+}
+
+func TestAwaitHedgeWinner(t *testing.T) {
+	race := newHedgeRace(nil)
+	first := newTestHedgeAttempt(race)
+	second := newTestHedgeAttempt(race)
+
+	first.err = errors.New("connection reset")
+	close(first.done)
+	close(second.done)
+
+	winner, loser := awaitHedgeWinner(race, first, second)
+	if winner != second || loser != first {
+		t.Fatal("successful attempt should win when the other failed")
+	}
+
+	race = newHedgeRace(nil)
+	first = newTestHedgeAttempt(race)
+	second = newTestHedgeAttempt(race)
+	first.err = errors.New("first failed")
+	second.err = errors.New("second failed")
+	close(first.done)
+	close(second.done)
+
+	winner, _ = awaitHedgeWinner(race, first, second)
+	if winner != first {
+		t.Fatal("first attempt should be reported when both failed")
+	}
+}
+
+func TestHedgeAttemptUsage(t *testing.T) {
+	a := newTestHedgeAttempt(newHedgeRace(nil))
+	if a.usage() != nil {
+		t.Fatal("expected nil usage before any response")
+	}
+
+	// 流式与非流式按同一口径换算限流用量
+	a.response = &adapter.Response{InputTokens: 10, OutputTokens: 5}
+	if got := UsageFromResult(*a.usage()); got != (ratelimit.Usage{Input: 10, Output: 5}) {
+		t.Fatalf("unexpected response usage %+v", got)
+	}
+	a.result = &adapter.StreamResult{InputTokens: 10, OutputTokens: 5, CacheCreationInputTokens: 3, CacheReadInputTokens: 100}
+	if got := UsageFromResult(*a.usage()); got != (ratelimit.Usage{Input: 13, Output: 5}) {
+		t.Fatalf("unexpected stream usage %+v", got)
+	}
+}
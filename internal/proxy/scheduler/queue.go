@@ -0,0 +1,614 @@
+/*
+ * 文件作用：账户池饱和时的公平排队
+ * 负责功能：
+ *   - 有界等待队列（每个账户池最大排队数、单请求最长等待时间）
+ *   - 优先级分层（高优先级 API Key 的请求先出队）
+ *   - 同一优先级内按 API Key 加权赤字轮询（DRR），防止单个 Key 占满队列
+ *   - 并发槽位释放时唤醒排队请求，兜底定时唤醒（槽位过期、账户恢复）
+ *   - 队列深度、等待时长统计
+ * 重要程度：⭐⭐⭐⭐ 重要（高峰期削峰，避免直接报错）
+ * 依赖模块：model, logger
+ */
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"cli-proxy/internal/model"
+	"cli-proxy/pkg/logger"
+)
+
+var (
+	ErrQueueFull    = errors.New("request queue is full")
+	ErrQueueTimeout = errors.New("request queue wait timed out")
+)
+
+// QueueConfig 排队配置
+type QueueConfig struct {
+	Enabled      bool          // 是否启用排队（关闭时账户并发全满立即返回错误）
+	MaxWait      time.Duration // 单个请求最长排队时间
+	MaxDepth     int           // 每个账户池最多排队请求数
+	PingInterval time.Duration // 流式请求排队期间发送 SSE 心跳注释的间隔
+}
+
+// DefaultQueueConfig 默认排队配置
+var DefaultQueueConfig = QueueConfig{
+	Enabled:      true,
+	MaxWait:      30 * time.Second,
+	MaxDepth:     200,
+	PingInterval: 10 * time.Second,
+}
+
+// queueRecheck 兜底唤醒间隔：槽位 TTL 过期、账户恢复等不会产生释放通知，定时唤醒队首请求重试
+const queueRecheck = time.Second
+
+// QueueTicket 排队请求的身份信息
+type QueueTicket struct {
+	Pool     string // 账户池（账户类型/平台，强制账户时为 account:<id>）
+	APIKeyID uint
+	Priority int       // 优先级（越大越先出队）
+	Weight   int       // 同优先级内的权重（DRR 每轮可出队的请求数）
+	Since    time.Time // 首次排队时间（再次排队时沿用，用于统计等待时长）
+}
+
+// queueWaiter 一个排队中的请求
+type queueWaiter struct {
+	ticket     QueueTicket
+	ready      chan struct{}
+	dispatched bool
+	enqueuedAt time.Time
+}
+
+// queueFlow 同一 API Key 在某优先级中的排队请求
+type queueFlow struct {
+	weight  int
+	deficit int
+	waiters []*queueWaiter
+}
+
+// queueTier 一个优先级层，层内按 API Key 轮询
+type queueTier struct {
+	flows  map[uint]*queueFlow
+	ring   []uint // 有排队请求的 API Key（轮询顺序）
+	cursor int
+}
+
+// queuePool 一个账户池的排队状态
+type queuePool struct {
+	tiers        map[int]*queueTier
+	depth        int
+	lastDispatch time.Time
+}
+
+// queueCounters 累计统计
+type queueCounters struct {
+	enqueued   int64
+	dispatched int64
+	timeouts   int64
+	rejected   int64
+	canceled   int64
+	waitTotal  time.Duration
+	waitMax    time.Duration
+}
+
+// FairQueue 公平排队队列
+type FairQueue struct {
+	mu       sync.Mutex
+	cfg      QueueConfig
+	pools    map[string]*queuePool
+	depth    int
+	counters queueCounters
+}
+
+var (
+	fairQueue     *FairQueue
+	fairQueueOnce sync.Once
+)
+
+// GetFairQueue 获取全局排队队列
+func GetFairQueue() *FairQueue {
+	fairQueueOnce.Do(func() {
+		fairQueue = newFairQueue(DefaultQueueConfig)
+		go fairQueue.recheckLoop()
+	})
+	return fairQueue
+}
+
+func newFairQueue(cfg QueueConfig) *FairQueue {
+	return &FairQueue{
+		cfg:   cfg,
+		pools: make(map[string]*queuePool),
+	}
+}
+
+// Configure 更新排队配置（对之后进入队列的请求生效）
+func (q *FairQueue) Configure(cfg QueueConfig) {
+	if cfg.MaxDepth <= 0 {
+		cfg.MaxDepth = DefaultQueueConfig.MaxDepth
+	}
+	if cfg.MaxWait <= 0 {
+		cfg.MaxWait = DefaultQueueConfig.MaxWait
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.cfg = cfg
+}
+
+// Config 获取当前排队配置
+func (q *FairQueue) Config() QueueConfig {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.cfg
+}
+
+// Depth 所有账户池的排队总数
+func (q *FairQueue) Depth() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.depth
+}
+
+// PoolDepth 指定账户池的排队数
+func (q *FairQueue) PoolDepth(pool string) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if p := q.pools[pool]; p != nil {
+		return p.depth
+	}
+	return 0
+}
+
+// Wait 在账户池中排队，直到被唤醒（返回 nil）、超过 deadline、ctx 取消或队列已满
+// requeue 表示被唤醒后仍未拿到槽位的请求再次排队，放回所在 API Key 的队首且不受深度限制
+// keepAlive 不为空时每隔 PingInterval 调用一次（流式请求用于发送心跳）
+func (q *FairQueue) Wait(ctx context.Context, ticket QueueTicket, deadline time.Time, requeue bool, keepAlive func()) error {
+	if ticket.Weight <= 0 {
+		ticket.Weight = 1
+	}
+	if ticket.Since.IsZero() {
+		ticket.Since = time.Now()
+	}
+	w := &queueWaiter{ticket: ticket, ready: make(chan struct{}, 1), enqueuedAt: ticket.Since}
+
+	q.mu.Lock()
+	cfg := q.cfg
+	if !requeue && q.poolLocked(ticket.Pool).depth >= cfg.MaxDepth {
+		q.counters.rejected++
+		q.mu.Unlock()
+		return ErrQueueFull
+	}
+	q.enqueueLocked(w, requeue)
+	q.mu.Unlock()
+
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	var ping <-chan time.Time
+	if keepAlive != nil && cfg.PingInterval > 0 {
+		ticker := time.NewTicker(cfg.PingInterval)
+		defer ticker.Stop()
+		ping = ticker.C
+	}
+
+	for {
+		select {
+		case <-w.ready:
+			return nil
+		case <-ping:
+			keepAlive()
+		case <-timer.C:
+			q.leave(w, true)
+			return ErrQueueTimeout
+		case <-ctx.Done():
+			q.leave(w, false)
+			return ctx.Err()
+		}
+	}
+}
+
+// Signal 账户池有槽位释放，唤醒一个排队请求
+func (q *FairQueue) Signal(pool string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.dispatchLocked(pool)
+}
+
+// NotifyReleased 账户并发槽位释放后唤醒可使用该账户的排队请求
+func (q *FairQueue) NotifyReleased(account *model.Account) {
+	if account == nil || q.Depth() == 0 {
+		return
+	}
+	q.Signal(account.Platform)
+	if account.Type != account.Platform {
+		q.Signal(account.Type)
+	}
+	q.Signal(forcedQueuePool(account.ID))
+}
+
+// forcedQueuePool 强制指定账户的请求所在的账户池
+func forcedQueuePool(accountID uint) string {
+	return fmt.Sprintf("account:%d", accountID)
+}
+
+func (q *FairQueue) poolLocked(name string) *queuePool {
+	p := q.pools[name]
+	if p == nil {
+		p = &queuePool{tiers: make(map[int]*queueTier)}
+		q.pools[name] = p
+	}
+	return p
+}
+
+func (q *FairQueue) enqueueLocked(w *queueWaiter, front bool) {
+	p := q.poolLocked(w.ticket.Pool)
+	tier := p.tiers[w.ticket.Priority]
+	if tier == nil {
+		tier = &queueTier{flows: make(map[uint]*queueFlow)}
+		p.tiers[w.ticket.Priority] = tier
+	}
+	flow := tier.flows[w.ticket.APIKeyID]
+	if flow == nil {
+		flow = &queueFlow{}
+		tier.flows[w.ticket.APIKeyID] = flow
+		tier.ring = append(tier.ring, w.ticket.APIKeyID)
+	}
+	flow.weight = w.ticket.Weight
+	if front {
+		flow.waiters = append([]*queueWaiter{w}, flow.waiters...)
+	} else {
+		flow.waiters = append(flow.waiters, w)
+		q.counters.enqueued++
+	}
+	p.depth++
+	q.depth++
+}
+
+// dispatchLocked 从账户池取出下一个请求并唤醒：先取最高优先级层，层内按 DRR 轮询 API Key
+func (q *FairQueue) dispatchLocked(pool string) bool {
+	p := q.pools[pool]
+	if p == nil || p.depth == 0 {
+		return false
+	}
+
+	top, found := 0, false
+	for priority, tier := range p.tiers {
+		if len(tier.ring) > 0 && (!found || priority > top) {
+			top, found = priority, true
+		}
+	}
+	if !found {
+		return false
+	}
+	tier := p.tiers[top]
+
+	if tier.cursor >= len(tier.ring) {
+		tier.cursor = 0
+	}
+	apiKeyID := tier.ring[tier.cursor]
+	flow := tier.flows[apiKeyID]
+	if flow.deficit <= 0 {
+		flow.deficit += flow.weight
+	}
+	w := flow.waiters[0]
+	flow.waiters = flow.waiters[1:]
+	flow.deficit--
+
+	if len(flow.waiters) == 0 {
+		q.removeFlowLocked(p, top, tier, tier.cursor)
+	} else if flow.deficit <= 0 {
+		tier.cursor++
+	}
+
+	p.depth--
+	q.depth--
+	p.lastDispatch = time.Now()
+	w.dispatched = true
+	w.ready <- struct{}{}
+
+	waited := time.Since(w.enqueuedAt)
+	q.counters.dispatched++
+	q.counters.waitTotal += waited
+	if waited > q.counters.waitMax {
+		q.counters.waitMax = waited
+	}
+	return true
+}
+
+// removeFlowLocked 移除已无排队请求的 API Key（赤字清零，重新入队时从头计算）
+func (q *FairQueue) removeFlowLocked(p *queuePool, priority int, tier *queueTier, idx int) {
+	delete(tier.flows, tier.ring[idx])
+	tier.ring = append(tier.ring[:idx], tier.ring[idx+1:]...)
+	if tier.cursor > idx {
+		tier.cursor--
+	}
+	if len(tier.ring) == 0 {
+		delete(p.tiers, priority)
+	}
+}
+
+// leave 请求放弃排队（超时或客户端断开）；若已被唤醒但未使用，把唤醒机会转给下一个请求
+func (q *FairQueue) leave(w *queueWaiter, timeout bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if timeout {
+		q.counters.timeouts++
+	} else {
+		q.counters.canceled++
+	}
+
+	if w.dispatched {
+		q.dispatchLocked(w.ticket.Pool)
+		return
+	}
+
+	p := q.pools[w.ticket.Pool]
+	if p == nil {
+		return
+	}
+	tier := p.tiers[w.ticket.Priority]
+	if tier == nil {
+		return
+	}
+	flow := tier.flows[w.ticket.APIKeyID]
+	if flow == nil {
+		return
+	}
+	for i, waiter := range flow.waiters {
+		if waiter != w {
+			continue
+		}
+		flow.waiters = append(flow.waiters[:i], flow.waiters[i+1:]...)
+		p.depth--
+		q.depth--
+		if len(flow.waiters) == 0 {
+			for idx, id := range tier.ring {
+				if id == w.ticket.APIKeyID {
+					q.removeFlowLocked(p, w.ticket.Priority, tier, idx)
+					break
+				}
+			}
+		}
+		return
+	}
+}
+
+// recheckLoop 兜底唤醒：账户池有排队但超过 queueRecheck 未出队时唤醒一个请求重试
+func (q *FairQueue) recheckLoop() {
+	ticker := time.NewTicker(queueRecheck)
+	defer ticker.Stop()
+	for range ticker.C {
+		q.mu.Lock()
+		for name, p := range q.pools {
+			if p.depth == 0 {
+				delete(q.pools, name)
+				continue
+			}
+			if time.Since(p.lastDispatch) >= queueRecheck {
+				q.dispatchLocked(name)
+			}
+		}
+		q.mu.Unlock()
+	}
+}
+
+// ==================== 统计 ====================
+
+// QueueKeyStats 单个 API Key 的排队情况
+type QueueKeyStats struct {
+	APIKeyID uint `json:"api_key_id"`
+	Priority int  `json:"priority"`
+	Waiting  int  `json:"waiting"`
+}
+
+// QueuePoolStats 单个账户池的排队情况
+type QueuePoolStats struct {
+	Pool         string          `json:"pool"`
+	Depth        int             `json:"depth"`
+	OldestWaitMs int64           `json:"oldest_wait_ms"`
+	Keys         []QueueKeyStats `json:"keys"`
+}
+
+// QueueStats 排队统计
+type QueueStats struct {
+	Enabled     bool             `json:"enabled"`
+	MaxDepth    int              `json:"max_depth"`
+	MaxWaitMs   int64            `json:"max_wait_ms"`
+	Depth       int              `json:"depth"`
+	Enqueued    int64            `json:"enqueued"`
+	Dispatched  int64            `json:"dispatched"`
+	Timeouts    int64            `json:"timeouts"`
+	Rejected    int64            `json:"rejected"`
+	Canceled    int64            `json:"canceled"`
+	AvgWaitMs   int64            `json:"avg_wait_ms"`
+	MaxWaitedMs int64            `json:"max_waited_ms"`
+	Pools       []QueuePoolStats `json:"pools"`
+}
+
+// Stats 获取排队统计（账户池按排队数降序）
+func (q *FairQueue) Stats() QueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now()
+	stats := QueueStats{
+		Enabled:     q.cfg.Enabled,
+		MaxDepth:    q.cfg.MaxDepth,
+		MaxWaitMs:   q.cfg.MaxWait.Milliseconds(),
+		Depth:       q.depth,
+		Enqueued:    q.counters.enqueued,
+		Dispatched:  q.counters.dispatched,
+		Timeouts:    q.counters.timeouts,
+		Rejected:    q.counters.rejected,
+		Canceled:    q.counters.canceled,
+		MaxWaitedMs: q.counters.waitMax.Milliseconds(),
+		Pools:       make([]QueuePoolStats, 0, len(q.pools)),
+	}
+	if q.counters.dispatched > 0 {
+		stats.AvgWaitMs = (q.counters.waitTotal / time.Duration(q.counters.dispatched)).Milliseconds()
+	}
+
+	for name, p := range q.pools {
+		if p.depth == 0 {
+			continue
+		}
+		ps := QueuePoolStats{Pool: name, Depth: p.depth, Keys: make([]QueueKeyStats, 0)}
+		for priority, tier := range p.tiers {
+			for _, apiKeyID := range tier.ring {
+				flow := tier.flows[apiKeyID]
+				ps.Keys = append(ps.Keys, QueueKeyStats{APIKeyID: apiKeyID, Priority: priority, Waiting: len(flow.waiters)})
+				for _, w := range flow.waiters {
+					if waited := now.Sub(w.enqueuedAt).Milliseconds(); waited > ps.OldestWaitMs {
+						ps.OldestWaitMs = waited
+					}
+				}
+			}
+		}
+		sort.Slice(ps.Keys, func(i, j int) bool {
+			if ps.Keys[i].Priority != ps.Keys[j].Priority {
+				return ps.Keys[i].Priority > ps.Keys[j].Priority
+			}
+			return ps.Keys[i].Waiting > ps.Keys[j].Waiting
+		})
+		stats.Pools = append(stats.Pools, ps)
+	}
+	sort.Slice(stats.Pools, func(i, j int) bool {
+		return stats.Pools[i].Depth > stats.Pools[j].Depth
+	})
+	return stats
+}
+
+// ==================== 请求排队 ====================
+
+// queueState 单次请求的排队状态
+type queueState struct {
+	pool     string
+	since    time.Time
+	deadline time.Time
+	full     map[uint]bool // 本轮并发已满的账户
+	requeue  bool          // 已被唤醒过（再次排队时放回队首）
+	woken    bool          // 刚被唤醒，拿到槽位后接力唤醒下一个请求
+}
+
+// WithQueue 设置排队优先级和权重；keepAlive 在流式请求排队期间定期调用（用于发送 SSE 心跳）
+func (r *RetryableRequest) WithQueue(priority, weight int, keepAlive func()) *RetryableRequest {
+	r.QueuePriority = priority
+	r.QueueWeight = weight
+	r.keepAlive = keepAlive
+	return r
+}
+
+// queuePool 请求所属的账户池
+func (r *RetryableRequest) queuePool(modelName string) string {
+	if r.ForcedAccountID > 0 {
+		return forcedQueuePool(r.ForcedAccountID)
+	}
+	if accountType := DetectAccountType(modelName); accountType != "" {
+		return accountType
+	}
+	return DetectPlatform(GetActualModel(modelName))
+}
+
+// admitQueue 账户池已有请求在排队时，新请求先排到队尾，避免抢走刚释放给排队请求的槽位
+func (r *RetryableRequest) admitQueue(ctx context.Context, modelName string, stream bool) error {
+	q := GetFairQueue()
+	if q.Depth() == 0 || !q.Config().Enabled {
+		return nil
+	}
+	if q.PoolDepth(r.queuePool(modelName)) == 0 {
+		return nil
+	}
+	return r.awaitQueue(ctx, modelName, stream)
+}
+
+// noteConcurrencyFull 记录并发已满的账户（本轮不再选择）
+func (r *RetryableRequest) noteConcurrencyFull(accountID uint) {
+	if r.queue == nil {
+		r.queue = &queueState{}
+	}
+	if r.queue.full == nil {
+		r.queue.full = make(map[uint]bool)
+	}
+	r.queue.full[accountID] = true
+	r.triedAccounts[accountID] = true
+}
+
+// isSaturated 选中的账户本轮已确认并发已满，说明可选账户都已满（选择器只在全部尝试过后才会回头）
+func (r *RetryableRequest) isSaturated(account *model.Account) bool {
+	return r.queue != nil && r.queue.full[account.ID]
+}
+
+// onSlotAcquired 拿到槽位；若是被唤醒的请求，接力唤醒下一个（可能有多个槽位同时空出）
+func (r *RetryableRequest) onSlotAcquired() {
+	if r.queue == nil || !r.queue.woken {
+		return
+	}
+	r.queue.woken = false
+	GetFairQueue().Signal(r.queue.pool)
+}
+
+// awaitQueue 排队等待槽位释放；返回 nil 表示已被唤醒，可重新选择账户
+func (r *RetryableRequest) awaitQueue(ctx context.Context, modelName string, stream bool) error {
+	log := logger.GetLogger("scheduler")
+	q := GetFairQueue()
+	cfg := q.Config()
+	if !cfg.Enabled {
+		return ErrAccountConcurrencyFull
+	}
+
+	if r.queue == nil {
+		r.queue = &queueState{}
+	}
+	st := r.queue
+	if st.pool == "" {
+		st.pool = r.queuePool(modelName)
+	}
+	if st.deadline.IsZero() {
+		st.since = time.Now()
+		st.deadline = st.since.Add(cfg.MaxWait)
+	}
+
+	var keepAlive func()
+	if stream {
+		keepAlive = r.keepAlive
+	}
+	ticket := QueueTicket{
+		Pool:     st.pool,
+		APIKeyID: r.APIKeyID,
+		Priority: r.QueuePriority,
+		Weight:   r.QueueWeight,
+		Since:    st.since,
+	}
+
+	if !st.requeue {
+		log.InfoZ("账户池并发已满，进入排队",
+			logger.String("pool", st.pool),
+			logger.String("model", modelName),
+			logger.Uint("api_key_id", r.APIKeyID),
+			logger.Int("priority", r.QueuePriority),
+			logger.Int("queue_depth", q.PoolDepth(st.pool)),
+		)
+	}
+	if err := q.Wait(ctx, ticket, st.deadline, st.requeue, keepAlive); err != nil {
+		log.WarnZ("排队结束-未获得槽位",
+			logger.String("pool", st.pool),
+			logger.Uint("api_key_id", r.APIKeyID),
+			logger.Duration("waited", time.Since(st.since)),
+			logger.Err(err),
+		)
+		return err
+	}
+
+	// 被唤醒：之前并发已满的账户重新参与选择
+	for accountID := range st.full {
+		delete(r.triedAccounts, accountID)
+	}
+	st.full = nil
+	st.requeue = true
+	st.woken = true
+	return nil
+}
@@ -0,0 +1,169 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// enqueueTest 在后台排队，返回出队结果通道
+func enqueueTest(q *FairQueue, ticket QueueTicket, wait time.Duration) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		done <- q.Wait(context.Background(), ticket, time.Now().Add(wait), false, nil)
+	}()
+	return done
+}
+
+// waitDepth 等待队列达到指定深度（排队在后台 goroutine 中进行）
+func waitDepth(t *testing.T, q *FairQueue, pool string, depth int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for q.PoolDepth(pool) != depth {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected depth %d, got %d", depth, q.PoolDepth(pool))
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestFairQueueRoundRobinAcrossKeys(t *testing.T) {
+	q := newFairQueue(DefaultQueueConfig)
+	var order []uint
+	results := make(map[uint][]<-chan error)
+
+	// Key 1 先排 4 个，Key 2 后排 2 个：出队应交替进行而不是先清空 Key 1
+	for _, key := range []uint{1, 1, 1, 1, 2, 2} {
+		results[key] = append(results[key], enqueueTest(q, QueueTicket{Pool: "claude", APIKeyID: key}, time.Minute))
+		waitDepth(t, q, "claude", len(results[1])+len(results[2]))
+	}
+
+	for i := 0; i < 6; i++ {
+		q.Signal("claude")
+		order = append(order, dispatchedKey(t, results))
+	}
+	want := []uint{1, 2, 1, 2, 1, 1}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("unexpected dispatch order %v, want %v", order, want)
+		}
+	}
+}
+
+func TestFairQueueWeightAndPriority(t *testing.T) {
+	q := newFairQueue(DefaultQueueConfig)
+	results := make(map[uint][]<-chan error)
+	tickets := []QueueTicket{
+		{Pool: "openai", APIKeyID: 1, Weight: 2},
+		{Pool: "openai", APIKeyID: 1, Weight: 2},
+		{Pool: "openai", APIKeyID: 1, Weight: 2},
+		{Pool: "openai", APIKeyID: 2, Weight: 1},
+		{Pool: "openai", APIKeyID: 2, Weight: 1},
+		{Pool: "openai", APIKeyID: 3, Priority: 1},
+	}
+	for i, ticket := range tickets {
+		results[ticket.APIKeyID] = append(results[ticket.APIKeyID], enqueueTest(q, ticket, time.Minute))
+		waitDepth(t, q, "openai", i+1)
+	}
+
+	var order []uint
+	for range tickets {
+		q.Signal("openai")
+		order = append(order, dispatchedKey(t, results))
+	}
+	// 高优先级的 Key 3 最先出队；Key 1 权重 2，每轮出队两个
+	want := []uint{3, 1, 1, 2, 1, 2}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("unexpected dispatch order %v, want %v", order, want)
+		}
+	}
+}
+
+func TestFairQueueDepthLimitAndTimeout(t *testing.T) {
+	q := newFairQueue(QueueConfig{Enabled: true, MaxWait: time.Minute, MaxDepth: 1})
+	first := enqueueTest(q, QueueTicket{Pool: "gemini", APIKeyID: 1}, 50*time.Millisecond)
+	waitDepth(t, q, "gemini", 1)
+
+	err := q.Wait(context.Background(), QueueTicket{Pool: "gemini", APIKeyID: 2}, time.Now().Add(time.Minute), false, nil)
+	if !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("expected ErrQueueFull, got %v", err)
+	}
+	if err := <-first; !errors.Is(err, ErrQueueTimeout) {
+		t.Fatalf("expected ErrQueueTimeout, got %v", err)
+	}
+	if q.PoolDepth("gemini") != 0 {
+		t.Fatalf("expected timed out request to leave the queue, depth %d", q.PoolDepth("gemini"))
+	}
+	stats := q.Stats()
+	if stats.Rejected != 1 || stats.Timeouts != 1 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestFairQueueCanceledWaiterPassesSignal(t *testing.T) {
+	q := newFairQueue(DefaultQueueConfig)
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		first <- q.Wait(ctx, QueueTicket{Pool: "claude", APIKeyID: 1}, time.Now().Add(time.Minute), false, nil)
+	}()
+	waitDepth(t, q, "claude", 1)
+	second := enqueueTest(q, QueueTicket{Pool: "claude", APIKeyID: 2}, time.Minute)
+	waitDepth(t, q, "claude", 2)
+
+	cancel()
+	if err := <-first; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	waitDepth(t, q, "claude", 1)
+	q.Signal("claude")
+	select {
+	case err := <-second:
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("second waiter was not dispatched")
+	}
+}
+
+func TestFairQueueKeepAlive(t *testing.T) {
+	q := newFairQueue(QueueConfig{Enabled: true, MaxWait: time.Minute, MaxDepth: 10, PingInterval: 10 * time.Millisecond})
+	pings := 0
+	err := q.Wait(context.Background(), QueueTicket{Pool: "claude", APIKeyID: 1}, time.Now().Add(55*time.Millisecond), false, func() { pings++ })
+	if !errors.Is(err, ErrQueueTimeout) {
+		t.Fatalf("expected ErrQueueTimeout, got %v", err)
+	}
+	if pings < 3 {
+		t.Fatalf("expected keepalive pings while waiting, got %d", pings)
+	}
+}
+
+// dispatchedKey 找出刚被唤醒的请求所属的 API Key
+func dispatchedKey(t *testing.T, results map[uint][]<-chan error) uint {
+	t.Helper()
+	deadline := time.After(time.Second)
+	for {
+		for key, chans := range results {
+			for i, ch := range chans {
+				select {
+				case err := <-ch:
+					if err != nil {
+						t.Fatalf("unexpected error: %v", err)
+					}
+					results[key] = append(chans[:i:i], chans[i+1:]...)
+					return key
+				default:
+				}
+			}
+		}
+		select {
+		case <-deadline:
+			t.Fatal("no waiter was dispatched")
+		default:
+			time.Sleep(time.Millisecond)
+		}
+	}
+}
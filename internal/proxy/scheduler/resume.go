@@ -0,0 +1,107 @@
+/*
+ * 文件作用：流式中断续传（Mid-stream Failover）
+ * 负责功能：
+ *   - 流式响应中途失败时，将请求连同已输出的文本转发到其他账户续写
+ *   - 续写流拼接到同一条客户端 SSE 流中，合并各次尝试的用量
+ * 重要程度：⭐⭐⭐ 一般（可选功能，默认关闭）
+ * 依赖模块：adapter, model
+ */
+package scheduler
+
+import (
+	"context"
+	"io"
+
+	"cli-proxy/internal/model"
+	"cli-proxy/internal/proxy/adapter"
+	"cli-proxy/pkg/logger"
+)
+
+// ResumeExecFunc 续传模式的流式执行函数，req 为本次应发送的请求（原始请求或续写请求）
+type ResumeExecFunc func(ctx context.Context, account *model.Account, req *adapter.Request, writer io.Writer) (*adapter.StreamResult, error)
+
+// ExecuteStreamWithResume 带中断续传的流式执行
+// format 为 adapter.ResumeFormatClaude 或 adapter.ResumeFormatOpenAI；
+// 中途失败且已输出内容仅为文本时，最多续传 Config.MaxStreamResumes 次
+func (r *RetryableRequest) ExecuteStreamWithResume(
+	ctx context.Context,
+	modelName string,
+	req *adapter.Request,
+	format string,
+	execFunc ResumeExecFunc,
+	writer io.Writer,
+) (*StreamExecuteResult, error) {
+	log := logger.GetLogger("scheduler")
+	rw := adapter.NewResumeWriter(writer, format)
+	merged := &adapter.StreamResult{}
+	current := req
+	var lastAccountID uint
+
+	for resumes := 0; ; resumes++ {
+		sendReq := current
+		result, err := r.ExecuteStreamWithRetry(ctx, modelName, func(ctx context.Context, account *model.Account, w io.Writer) (*adapter.StreamResult, error) {
+			return execFunc(ctx, account, sendReq, w)
+		}, rw)
+
+		var accountID uint
+		if result != nil {
+			accountID = result.AccountID
+			lastAccountID = accountID
+			mergeStreamUsage(merged, result.Result)
+		}
+		// 上游以错误事件结束流时适配器不返回错误，需从写入器取出
+		if err == nil {
+			err = rw.Err()
+		}
+		if err == nil {
+			return &StreamExecuteResult{
+				Result:    merged,
+				AccountID: accountID,
+			}, nil
+		}
+
+		// 失败时返回各次尝试已产生的用量，供调用方按实际用量修正 Token 预扣
+		partial := &StreamExecuteResult{Result: merged, AccountID: lastAccountID}
+		if resumes >= r.Config.MaxStreamResumes || !rw.Resumable() || ctx.Err() != nil {
+			return partial, err
+		}
+
+		if accountID > 0 {
+			r.triedAccounts[accountID] = true
+		}
+		rw.SetPriorOutputTokens(merged.OutputTokens)
+		next, contErr := rw.Continue(req)
+		if contErr != nil {
+			log.WarnZ("构建续写请求失败",
+				logger.String("model", modelName),
+				logger.Uint("api_key_id", r.APIKeyID),
+				logger.Err(contErr),
+			)
+			return partial, err
+		}
+
+		log.WarnZ("流式响应中途失败，续传到其他账户",
+			logger.String("model", modelName),
+			logger.Uint("failed_account_id", accountID),
+			logger.Uint("api_key_id", r.APIKeyID),
+			logger.Int("resume", resumes+1),
+			logger.Int("emitted_chars", len(rw.Text())),
+			logger.String("error", err.Error()),
+		)
+		current = next
+	}
+}
+
+// mergeStreamUsage 将 src 的用量累加到 dst，响应头以最后一次为准
+func mergeStreamUsage(dst, src *adapter.StreamResult) {
+	if src == nil {
+		return
+	}
+	dst.InputTokens += src.InputTokens
+	dst.OutputTokens += src.OutputTokens
+	dst.CacheCreationInputTokens += src.CacheCreationInputTokens
+	dst.CacheReadInputTokens += src.CacheReadInputTokens
+	if src.Headers != nil {
+		dst.Headers = src.Headers
+	}
+}
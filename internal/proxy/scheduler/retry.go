@@ -3,8 +3,9 @@
  * 负责功能：
  *   - 请求重试配置（次数、延迟、退避系数）
  *   - 账户切换重试（失败后尝试其他账户）
- *   - 并发控制（账户并发限制）
+ *   - 并发控制（账户并发限制，全满时进入公平排队）
  *   - 可重试错误判断（连接错误、限流等）
+ *   - 代理池出口代理故障切换（网络层错误时切换代理）
  *   - 流式/非流式请求重试
  * 重要程度：⭐⭐⭐⭐⭐ 核心（保证请求可靠性）
  * 依赖模块：cache, model, adapter
@@ -16,11 +17,16 @@ import (
 	"errors"
 	"io"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"cli-proxy/internal/cache"
+	"cli-proxy/internal/egress"
+	"cli-proxy/internal/errormatch"
 	"cli-proxy/internal/model"
 	"cli-proxy/internal/proxy/adapter"
+	"cli-proxy/internal/proxypool"
+	"cli-proxy/internal/ratelimit"
 	"cli-proxy/pkg/logger"
 )
 
@@ -38,6 +44,7 @@ type RetryConfig struct {
 	RetryableErrors   []string      // 可重试的错误类型
 	SwitchOnRateLimit bool          // 限流时是否切换账户
 	SwitchOnError     bool          // 错误时是否切换账户
+	MaxStreamResumes  int           // 流式中断续传最大次数（仅对开启续传的请求生效）
 }
 
 // DefaultRetryConfig 默认重试配置
@@ -48,6 +55,7 @@ var DefaultRetryConfig = RetryConfig{
 	RetryableErrors:   []string{"timeout", "connection", "403", "429", "529", "503", "502"},
 	SwitchOnRateLimit: true,
 	SwitchOnError:     true,
+	MaxStreamResumes:  2,
 }
 
 // RetryableRequest 可重试的请求
@@ -61,8 +69,28 @@ type RetryableRequest struct {
 	UserAgent     string // 客户端User-Agent
 	OriginalModel string // 原始模型名（映射前），用于 AllowedModels 检查
 
+	// 强制使用的账户（管理员重放请求时指定），设置后跳过调度和会话粘性
+	ForcedAccountID uint
+
+	// 对冲请求（可选）：首个账户超过 HedgeDelay 未响应时向另一账户并行发起请求
+	HedgeDelay   time.Duration
+	OnHedgeLoser HedgeLoserFunc
+
+	// 排队（可选账户并发全满时）：优先级、权重和流式请求排队期间的心跳
+	QueuePriority int
+	QueueWeight   int
+	keepAlive     func()
+	queue         *queueState
+
+	// 请求 Token 预估（用于账户 Token 限额预扣，完成后按实际用量修正）
+	tokenEstimate ratelimit.Usage
+
 	// 已尝试的账户 ID，避免重复使用
 	triedAccounts map[uint]bool
+
+	// 执行统计（实时事件上报用）：实际发起的上游请求次数和最后使用的账户
+	attempts      int
+	lastAccountID uint
 }
 
 // NewRetryableRequest 创建可重试请求
@@ -100,6 +128,26 @@ func (r *RetryableRequest) WithOriginalModel(originalModel string) *RetryableReq
 	return r
 }
 
+// WithForcedAccount 强制使用指定账户（不检查启用状态和模型限制，用于管理员调试重放）
+func (r *RetryableRequest) WithForcedAccount(accountID uint) *RetryableRequest {
+	r.ForcedAccountID = accountID
+	return r
+}
+
+// forcedAccount 获取强制指定的账户
+func (r *RetryableRequest) forcedAccount() (*model.Account, error) {
+	acc, err := r.Scheduler.repo.GetByID(r.ForcedAccountID)
+	if err != nil || acc == nil {
+		return nil, ErrNoAvailableAccount
+	}
+	return acc, nil
+}
+
+// Stats 返回实际发起的上游请求次数和最后使用的账户 ID
+func (r *RetryableRequest) Stats() (attempts int, lastAccountID uint) {
+	return r.attempts, r.lastAccountID
+}
+
 // ExecuteResult 执行结果
 type ExecuteResult struct {
 	Response  *adapter.Response
@@ -132,6 +180,11 @@ func (r *RetryableRequest) ExecuteWithRetry(
 		logger.Int("max_retries", r.Config.MaxRetries),
 	)
 
+	// 已有请求在排队时先排队，保证先到先得
+	if err := r.admitQueue(ctx, modelName, false); err != nil {
+		return nil, err
+	}
+
 	for attempt := 0; attempt <= r.Config.MaxRetries; attempt++ {
 		// 选择账户（允许重试同一账户）
 		account, err := r.selectNextAccountAllowRetry(ctx, modelName, accountFailures)
@@ -161,6 +214,22 @@ func (r *RetryableRequest) ExecuteWithRetry(
 			return nil, err
 		}
 
+		// 可选账户并发都已满：排队等待槽位释放（排队不消耗重试次数）
+		if r.isSaturated(account) {
+			if err := r.awaitQueue(ctx, modelName, false); err != nil {
+				return nil, err
+			}
+			attempt--
+			continue
+		}
+
+		// 账户 Token 额度不足：视同并发已满，换其他账户或排队等待额度恢复
+		if !r.reserveAccountTokens(account) {
+			r.noteConcurrencyFull(account.ID)
+			attempt--
+			continue
+		}
+
 		// 尝试获取并发槽位
 		sessionCache := r.Scheduler.GetSessionCache()
 		var acquired bool
@@ -185,16 +254,24 @@ func (r *RetryableRequest) ExecuteWithRetry(
 					logger.String("account_name", account.Name),
 					logger.Int("limit", concurrencyLimit),
 				)
-				// 标记该账户已尝试，选择下一个
-				r.triedAccounts[account.ID] = true
+				// 标记该账户并发已满，选择下一个（不消耗重试次数）
+				r.refundAccountTokens(account)
+				r.noteConcurrencyFull(account.ID)
+				attempt--
 				continue
 			}
+			r.onSlotAcquired()
 		}
 
-		// 确保释放并发槽位
+		r.attempts++
+		r.lastAccountID = account.ID
+
+		// 确保释放并发槽位（对冲胜出时 account 会被替换为胜出账户，这里固定释放原账户）
+		slotAccount := account
 		releaseConcurrency := func() {
 			if sessionCache != nil && acquired {
-				sessionCache.ReleaseConcurrency(ctx, account.ID)
+				sessionCache.ReleaseConcurrency(ctx, slotAccount.ID)
+				GetFairQueue().NotifyReleased(slotAccount)
 			}
 		}
 
@@ -212,12 +289,21 @@ func (r *RetryableRequest) ExecuteWithRetry(
 		)
 
 		// 执行请求
-		resp, err := execFunc(ctx, account)
+		var resp *adapter.Response
+		var proxyID uint
+		if r.HedgeDelay > 0 {
+			account, proxyID, resp, err = r.executeHedged(ctx, modelName, account, execFunc)
+		} else {
+			proxyID = dispatchProxyID(account)
+			resp, err = execFunc(ctx, account)
+		}
 
 		if err == nil && resp.Error == nil {
 			// 成功
 			releaseConcurrency()
+			r.settleAccountTokens(account, UsageFromResult(*responseUsage(resp)))
 			r.Scheduler.MarkAccountSuccess(account.ID)
+			r.reportProxyResult(account, proxyID, nil)
 			log.InfoZ("代理请求成功",
 				logger.String("model", modelName),
 				logger.Uint("account_id", account.ID),
@@ -238,8 +324,9 @@ func (r *RetryableRequest) ExecuteWithRetry(
 			}, nil
 		}
 
-		// 释放并发槽位
+		// 释放并发槽位，退回 Token 预扣
 		releaseConcurrency()
+		r.refundAccountTokens(account)
 
 		// 记录错误（但不立即标记账户状态）
 		actualErr := err
@@ -251,6 +338,7 @@ func (r *RetryableRequest) ExecuteWithRetry(
 		lastAccount = account
 		lastResp = resp
 		accountFailures[account.ID]++
+		r.reportProxyResult(account, proxyID, err)
 
 		log.WarnZ("请求失败，准备重试",
 			logger.Int("attempt", attempt+1),
@@ -267,7 +355,7 @@ func (r *RetryableRequest) ExecuteWithRetry(
 		)
 
 		// 判断是否可以重试
-		if !r.isRetryable(actualErr) {
+		if !r.ruleRetryable(account, actualErr, r.isRetryable(actualErr)) {
 			// 不可重试的错误，立即标记并返回
 			r.Scheduler.MarkAccountError(account.ID, account.Type, actualErr)
 			log.ErrorZ("代理请求失败-不可重试错误",
@@ -284,7 +372,7 @@ func (r *RetryableRequest) ExecuteWithRetry(
 			return &ExecuteResult{
 				Response:  resp,
 				AccountID: account.ID,
-			}, actualErr
+			}, r.withClientMessage(account, actualErr)
 		}
 
 		// 如果有多个账户，标记当前账户已尝试，下次优先选其他账户
@@ -321,7 +409,7 @@ func (r *RetryableRequest) ExecuteWithRetry(
 	return &ExecuteResult{
 		Response:  lastResp,
 		AccountID: lastAccount.ID,
-	}, lastErr
+	}, r.withClientMessage(lastAccount, lastErr)
 }
 
 // StreamExecuteResult 流式执行结果
@@ -346,6 +434,13 @@ func (r *RetryableRequest) ExecuteStreamWithRetry(
 	// 记录每个账户的失败次数
 	accountFailures := make(map[uint]int)
 
+	// 跟踪每次尝试是否已向客户端输出：续传写入器自行报告，其他写入器按写出字节数判断
+	progress, ok := writer.(adapter.ProgressWriter)
+	if !ok {
+		counter := &byteProgressWriter{w: writer}
+		writer, progress = counter, counter
+	}
+
 	// 记录流式请求开始
 	log.InfoZ("流式代理请求开始",
 		logger.String("model", modelName),
@@ -356,6 +451,11 @@ func (r *RetryableRequest) ExecuteStreamWithRetry(
 		logger.Int("max_retries", r.Config.MaxRetries),
 	)
 
+	// 已有请求在排队时先排队，保证先到先得
+	if err := r.admitQueue(ctx, modelName, true); err != nil {
+		return nil, err
+	}
+
 	for attempt := 0; attempt <= r.Config.MaxRetries; attempt++ {
 		// 选择账户（允许重试同一账户）
 		account, err := r.selectNextAccountAllowRetry(ctx, modelName, accountFailures)
@@ -383,6 +483,22 @@ func (r *RetryableRequest) ExecuteStreamWithRetry(
 			return nil, err
 		}
 
+		// 可选账户并发都已满：排队等待槽位释放（排队不消耗重试次数）
+		if r.isSaturated(account) {
+			if err := r.awaitQueue(ctx, modelName, true); err != nil {
+				return nil, err
+			}
+			attempt--
+			continue
+		}
+
+		// 账户 Token 额度不足：视同并发已满，换其他账户或排队等待额度恢复
+		if !r.reserveAccountTokens(account) {
+			r.noteConcurrencyFull(account.ID)
+			attempt--
+			continue
+		}
+
 		// 尝试获取并发槽位
 		sessionCache := r.Scheduler.GetSessionCache()
 		var acquired bool
@@ -407,16 +523,24 @@ func (r *RetryableRequest) ExecuteStreamWithRetry(
 					logger.String("account_name", account.Name),
 					logger.Int("limit", concurrencyLimit),
 				)
-				// 标记该账户已尝试，选择下一个
-				r.triedAccounts[account.ID] = true
+				// 标记该账户并发已满，选择下一个（不消耗重试次数）
+				r.refundAccountTokens(account)
+				r.noteConcurrencyFull(account.ID)
+				attempt--
 				continue
 			}
+			r.onSlotAcquired()
 		}
 
-		// 确保释放并发槽位
+		r.attempts++
+		r.lastAccountID = account.ID
+
+		// 确保释放并发槽位（对冲胜出时 account 会被替换为胜出账户，这里固定释放原账户）
+		slotAccount := account
 		releaseConcurrency := func() {
 			if sessionCache != nil && acquired {
-				sessionCache.ReleaseConcurrency(ctx, account.ID)
+				sessionCache.ReleaseConcurrency(ctx, slotAccount.ID)
+				GetFairQueue().NotifyReleased(slotAccount)
 			}
 		}
 
@@ -434,11 +558,23 @@ func (r *RetryableRequest) ExecuteStreamWithRetry(
 		)
 
 		// 执行流式请求
-		result, err := execFunc(ctx, account, writer)
+		progress.BeginAttempt()
+		var result *adapter.StreamResult
+		var proxyID uint
+		if r.HedgeDelay > 0 {
+			account, proxyID, result, err = r.executeStreamHedged(ctx, modelName, account, execFunc, writer)
+		} else {
+			proxyID = dispatchProxyID(account)
+			result, err = execFunc(ctx, account, writer)
+		}
 
 		if err == nil {
 			releaseConcurrency()
+			if result != nil {
+				r.settleAccountTokens(account, UsageFromResult(*result))
+			}
 			r.Scheduler.MarkAccountSuccess(account.ID)
+			r.reportProxyResult(account, proxyID, nil)
 			log.InfoZ("流式代理请求成功",
 				logger.String("model", modelName),
 				logger.Uint("account_id", account.ID),
@@ -461,13 +597,19 @@ func (r *RetryableRequest) ExecuteStreamWithRetry(
 			}, nil
 		}
 
-		// 释放并发槽位
+		// 释放并发槽位；已向客户端输出内容时按已产生的用量结算，否则退回 Token 预扣
 		releaseConcurrency()
+		if progress.AttemptStarted() && result != nil {
+			r.settleAccountTokens(account, UsageFromResult(*result))
+		} else {
+			r.refundAccountTokens(account)
+		}
 
 		// 记录错误（但不立即标记账户状态）
 		lastErr = err
 		lastAccount = account
 		accountFailures[account.ID]++
+		r.reportProxyResult(account, proxyID, err)
 
 		log.WarnZ("流式请求失败，准备重试",
 			logger.Int("attempt", attempt+1),
@@ -483,9 +625,24 @@ func (r *RetryableRequest) ExecuteStreamWithRetry(
 			logger.Duration("exec_duration", time.Since(execStart)),
 		)
 
-		// 流式请求一旦开始就不应该重试（因为可能已经写入部分数据）
-		// 除非是在连接阶段就失败了
-		if !r.isConnectionError(err) {
+		// 已向客户端输出部分内容时原样重试会重复输出（错误规则的切换/重试动作也不再生效），交由调用方处理（如中断续传）
+		if progress.AttemptStarted() {
+			r.Scheduler.MarkAccountError(account.ID, account.Type, err)
+			log.WarnZ("流式请求中途失败",
+				logger.String("model", modelName),
+				logger.Uint("account_id", account.ID),
+				logger.String("account_name", account.Name),
+				logger.Uint("api_key_id", r.APIKeyID),
+				logger.String("error", err.Error()),
+			)
+			return &StreamExecuteResult{
+				Result:    result,
+				AccountID: account.ID,
+			}, r.withClientMessage(account, err)
+		}
+
+		// 尚未向客户端输出任何数据，可以按错误规则或连接错误重试
+		if !r.ruleRetryable(account, err, r.isConnectionError(err)) {
 			// 不可重试的错误，立即标记并返回
 			r.Scheduler.MarkAccountError(account.ID, account.Type, err)
 			log.ErrorZ("流式代理请求失败-不可重试错误",
@@ -499,7 +656,7 @@ func (r *RetryableRequest) ExecuteStreamWithRetry(
 				logger.Duration("duration", time.Since(startTime)),
 				logger.Int("attempts", attempt+1),
 			)
-			return nil, err
+			return nil, r.withClientMessage(account, err)
 		}
 
 		r.triedAccounts[account.ID] = true
@@ -531,13 +688,17 @@ func (r *RetryableRequest) ExecuteStreamWithRetry(
 		logger.Int("attempts", r.Config.MaxRetries+1),
 	)
 
-	return nil, lastErr
+	return nil, r.withClientMessage(lastAccount, lastErr)
 }
 
 // selectNextAccount 选择下一个可用账户
 func (r *RetryableRequest) selectNextAccount(ctx context.Context, modelName string) (*model.Account, error) {
 	log := logger.GetLogger("scheduler")
 
+	if r.ForcedAccountID > 0 {
+		return r.forcedAccount()
+	}
+
 	// 检测是否指定了账户类型
 	accountType := DetectAccountType(modelName)
 	actualModel := GetActualModel(modelName)
@@ -589,6 +750,12 @@ func (r *RetryableRequest) selectNextAccount(ctx context.Context, modelName stri
 						sessionValid = false
 					}
 
+					if sessionValid && adapter.EgressRegionBlocked(acc) {
+						log.Info("会话粘性账户出口地区不可用，移除绑定 - SessionID: %s, 账户ID: %d", r.SessionID, acc.ID)
+						sessionCache.RemoveSessionBinding(ctx, r.SessionID)
+						sessionValid = false
+					}
+
 					if sessionValid {
 						sessionCache.UpdateSessionLastUsed(ctx, r.SessionID)
 						log.Info("会话粘性命中 - SessionID: %s, 账户ID: %d, 名称: %s", r.SessionID, acc.ID, acc.Name)
@@ -685,6 +852,11 @@ func (r *RetryableRequest) selectNextAccount(ctx context.Context, modelName stri
 				acc.ID, acc.Name, acc.Status, acc.LastError)
 			continue
 		}
+		// 跳过代理池内没有其出口地区代理的账户（不允许跨地区切换出口）
+		if adapter.EgressRegionBlocked(acc) {
+			log.Debug("跳过出口地区不可用账户 - ID: %d, 名称: %s", acc.ID, acc.Name)
+			continue
+		}
 		// 如果配置了切换，跳过限流和过载的账户
 		if r.Config.SwitchOnRateLimit {
 			if acc.Status == model.AccountStatusRateLimited || acc.Status == model.AccountStatusOverloaded {
@@ -739,6 +911,10 @@ func (r *RetryableRequest) selectNextAccount(ctx context.Context, modelName stri
 func (r *RetryableRequest) selectNextAccountAllowRetry(ctx context.Context, modelName string, accountFailures map[uint]int) (*model.Account, error) {
 	log := logger.GetLogger("scheduler")
 
+	if r.ForcedAccountID > 0 {
+		return r.forcedAccount()
+	}
+
 	// 检测是否指定了账户类型
 	accountType := DetectAccountType(modelName)
 	actualModel := GetActualModel(modelName)
@@ -790,6 +966,12 @@ func (r *RetryableRequest) selectNextAccountAllowRetry(ctx context.Context, mode
 						sessionValid = false
 					}
 
+					if sessionValid && adapter.EgressRegionBlocked(acc) {
+						log.Info("会话粘性账户出口地区不可用，移除绑定 - SessionID: %s, 账户ID: %d", r.SessionID, acc.ID)
+						sessionCache.RemoveSessionBinding(ctx, r.SessionID)
+						sessionValid = false
+					}
+
 					if sessionValid {
 						sessionCache.UpdateSessionLastUsed(ctx, r.SessionID)
 						log.Info("会话粘性命中 - SessionID: %s, 账户ID: %d, 名称: %s", r.SessionID, acc.ID, acc.Name)
@@ -878,6 +1060,10 @@ func (r *RetryableRequest) selectNextAccountAllowRetry(ctx context.Context, mode
 		if acc.Status == model.AccountStatusInvalid {
 			continue
 		}
+		// 跳过代理池内没有其出口地区代理的账户（不允许跨地区切换出口）
+		if adapter.EgressRegionBlocked(acc) {
+			continue
+		}
 
 		// 收集所有有效账户
 		allValid = append(allValid, acc)
@@ -960,6 +1146,22 @@ func (r *RetryableRequest) isRetryable(err error) bool {
 	return false
 }
 
+// ruleRetryable 命中的错误规则指定了重试动作时以规则为准，否则使用默认判断
+func (r *RetryableRequest) ruleRetryable(account *model.Account, err error, fallback bool) bool {
+	switch errormatch.GetErrorRuleMatcher().MatchError(err, account).RetryAction() {
+	case model.RetryActionSwitch:
+		return true
+	case model.RetryActionNone:
+		return false
+	}
+	return fallback
+}
+
+// withClientMessage 命中的错误规则指定了客户端提示时附加到错误上
+func (r *RetryableRequest) withClientMessage(account *model.Account, err error) error {
+	return errormatch.WithClientMessage(err, errormatch.GetErrorRuleMatcher().MatchError(err, account))
+}
+
 // isConnectionError 判断是否是连接错误或可重试的上游错误（流式请求开始前的错误）
 // 也包括 SSE 首个事件就是错误的情况（此时尚未向客户端写入数据）
 // 注意：此方法用于流式请求，在连接阶段遇到的错误应该和非流式请求一样可以重试
@@ -973,28 +1175,12 @@ func (r *RetryableRequest) isConnectionError(err error) bool {
 		return false
 	}
 
-	errStr := strings.ToLower(err.Error())
-
 	// 1. 网络/连接错误
-	connectionErrors := []string{
-		"connection refused",
-		"connection reset",
-		"no such host",
-		"timeout",
-		"dial",
-		"network",
-		"eof",
-		"broken pipe",
-		"i/o timeout",
-		"tls handshake",
-		"certificate",
+	if isNetworkError(err) {
+		return true
 	}
 
-	for _, connErr := range connectionErrors {
-		if strings.Contains(errStr, connErr) {
-			return true
-		}
-	}
+	errStr := strings.ToLower(err.Error())
 
 	// 2. HTTP 状态码（各平台通用）
 	// 这些状态码通常表示临时性问题或账号问题，切换账号可能解决
@@ -1185,3 +1371,112 @@ func (cb *CircuitBreaker) RecordFailure() {
 func (cb *CircuitBreaker) GetState() CircuitState {
 	return cb.state
 }
+
+// isNetworkError 判断是否是网络/连接层错误（与上游返回的业务错误区分，用于出口代理故障切换）
+func isNetworkError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	errStr := strings.ToLower(err.Error())
+	connectionErrors := []string{
+		"connection refused",
+		"connection reset",
+		"no such host",
+		"timeout",
+		"dial",
+		"network",
+		"eof",
+		"broken pipe",
+		"i/o timeout",
+		"tls handshake",
+		"certificate",
+	}
+
+	for _, connErr := range connectionErrors {
+		if strings.Contains(errStr, connErr) {
+			return true
+		}
+	}
+	return false
+}
+
+// reportProxyResult 上报出口代理结果（proxyID 为发起请求时使用的代理）：成功时记录账户出口 IP；
+// 绑定代理池的账户网络层错误时切换到池内其他代理，成功时清零该代理的失败计数
+func (r *RetryableRequest) reportProxyResult(account *model.Account, proxyID uint, err error) {
+	if account == nil {
+		return
+	}
+	if err == nil {
+		observeEgress(account.ID, proxyID)
+	}
+	if account.ProxyPoolID == nil || proxyID == egress.DirectProxyID {
+		return
+	}
+	manager := proxypool.GetManager()
+	if err == nil {
+		manager.ReportSuccess(proxyID)
+		return
+	}
+	if !isNetworkError(err) {
+		return
+	}
+	failedID, nextID := manager.ReportFailure(account.ID, proxyID, err.Error())
+	if failedID != 0 && failedID != nextID {
+		logger.GetLogger("scheduler").WarnZ("出口代理连接失败，已切换代理",
+			logger.Uint("account_id", account.ID),
+			logger.Uint("proxy_pool_id", *account.ProxyPoolID),
+			logger.Uint("failed_proxy_id", failedID),
+			logger.Uint("next_proxy_id", nextID),
+			logger.String("error", err.Error()),
+		)
+	}
+}
+
+// dispatchProxyID 发起请求时账户使用的出口代理 ID（直连为 egress.DirectProxyID）
+// 需在请求发出时记录：请求结束后再解析可能拿到已被其他请求切换后的代理
+func dispatchProxyID(account *model.Account) uint {
+	if p := adapter.EffectiveAccountProxy(account); p != nil {
+		return p.ID
+	}
+	return egress.DirectProxyID
+}
+
+// observeEgress 记录账户本次请求使用的出口（未开启出口 IP 检查时忽略）
+func observeEgress(accountID, proxyID uint) {
+	tracker := egress.GetTracker()
+	if !tracker.Enabled() {
+		return
+	}
+	tracker.Observe(accountID, proxyID)
+}
+
+// byteProgressWriter 按写出字节数报告本次尝试是否已向客户端输出的写入器（未启用续传时使用）
+type byteProgressWriter struct {
+	w       io.Writer
+	written atomic.Int64
+}
+
+// Write 实现 io.Writer 接口
+func (p *byteProgressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	p.written.Add(int64(n))
+	return n, err
+}
+
+// Flush 实现 http.Flusher 接口
+func (p *byteProgressWriter) Flush() {
+	if f, ok := p.w.(interface{ Flush() }); ok {
+		f.Flush()
+	}
+}
+
+// BeginAttempt 开始新的一次尝试
+func (p *byteProgressWriter) BeginAttempt() {
+	p.written.Store(0)
+}
+
+// AttemptStarted 本次尝试是否已写出数据
+func (p *byteProgressWriter) AttemptStarted() bool {
+	return p.written.Load() > 0
+}
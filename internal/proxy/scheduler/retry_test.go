@@ -0,0 +1,43 @@
+package scheduler
+
+import (
+	"bytes"
+	"testing"
+
+	"cli-proxy/internal/proxy/adapter"
+)
+
+type flushRecorder struct {
+	bytes.Buffer
+	flushes int
+}
+
+func (f *flushRecorder) Flush() { f.flushes++ }
+
+func TestByteProgressWriter(t *testing.T) {
+	var dst flushRecorder
+	var w adapter.ProgressWriter = &byteProgressWriter{w: &dst}
+
+	w.BeginAttempt()
+	if w.AttemptStarted() {
+		t.Fatal("new attempt should not be started")
+	}
+	w.Write(nil)
+	if w.AttemptStarted() {
+		t.Fatal("empty write should not start the attempt")
+	}
+	// 任何写出的字节（包括心跳）都视为已向客户端输出，之后不能再切换账户重试
+	w.Write([]byte(": keepalive\n\n"))
+	if !w.AttemptStarted() {
+		t.Fatal("attempt should be started after writing bytes")
+	}
+	w.(interface{ Flush() }).Flush()
+	if dst.String() != ": keepalive\n\n" || dst.flushes != 1 {
+		t.Fatalf("unexpected passthrough: %q, %d flushes", dst.String(), dst.flushes)
+	}
+
+	w.BeginAttempt()
+	if w.AttemptStarted() {
+		t.Fatal("BeginAttempt should reset progress")
+	}
+}
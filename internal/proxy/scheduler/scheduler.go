@@ -25,7 +25,6 @@ import (
 	"cli-proxy/internal/cache"
 	"cli-proxy/internal/errormatch"
 	"cli-proxy/internal/model"
-	"cli-proxy/internal/proxy/adapter"
 	"cli-proxy/internal/repository"
 	"cli-proxy/pkg/logger"
 )
@@ -627,16 +626,15 @@ func (s *Scheduler) MarkAccountErrorWithReset(accountID uint, accountType string
 		return
 	}
 
-	// 1. 首先尝试从 UpstreamError 获取 HTTP 状态码（类型断言）
-	var httpStatusCode int
-	var upstreamErr *adapter.UpstreamError
-	if errors.As(err, &upstreamErr) {
-		httpStatusCode = upstreamErr.StatusCode
-	}
+	// 1. 首先尝试从 UpstreamError 获取 HTTP 状态码和响应头（类型断言）
+	ec := errormatch.NewErrorContext(err, nil)
+	ec.AccountType = accountType
+	ec.Platform = model.GetPlatformByType(accountType)
+	httpStatusCode := ec.StatusCode
 
-	// 2. 使用错误规则匹配器进行匹配（先匹配状态码，再匹配关键词）
+	// 2. 使用错误规则匹配器进行匹配（状态码、关键词、正则、JSON 路径、响应头等）
 	matcher := errormatch.GetErrorRuleMatcher()
-	result := matcher.Match(httpStatusCode, errMsg)
+	result := matcher.MatchContext(ec)
 
 	if result.Matched {
 		// 匹配到规则，使用规则指定的目标状态
@@ -648,9 +646,9 @@ func (s *Scheduler) MarkAccountErrorWithReset(accountID uint, accountType string
 				accountID, httpStatusCode, result.Rule.ID, status, result.Rule.Description)
 		}
 
-		// 如果是限流状态，设置恢复时间
+		// 如果是限流状态，按规则冷却时长设置恢复时间（上游明确返回的恢复时间优先）
 		if status == model.AccountStatusRateLimited && resetAt == nil {
-			defaultReset := time.Now().Add(1 * time.Hour)
+			defaultReset := time.Now().Add(result.Cooldown())
 			resetAt = &defaultReset
 		}
 	}
@@ -15,6 +15,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -369,7 +370,7 @@ func (m *TokenManager) refreshXyrtToken(ctx context.Context, account *model.Acco
 		errMsg := fmt.Sprintf("xyrt token refresh failed: HTTP %d - %s", resp.StatusCode, string(body))
 		getXyrtLog().Error("[xyrt] Token 刷新失败 | AccountID: %d | %s", account.ID, errMsg)
 		m.repo.MarkAsTokenExpired(account.ID, errMsg)
-		return fmt.Errorf(errMsg)
+		return errors.New(errMsg)
 	}
 
 	// 解析响应
@@ -0,0 +1,84 @@
+/*
+ * 文件作用：账户级 Token 限流（TPM / ITPM / OTPM）
+ * 负责功能：
+ *   - 转发前按请求预估输入 Token 检查并预扣账户令牌桶
+ *   - 额度不足的账户视同并发已满：换其他账户或排队等待额度恢复
+ *   - 请求成功按实际用量修正，失败退回预扣
+ * 重要程度：⭐⭐⭐⭐ 重要（避免把上游账户的 Token 配额打满触发 429）
+ * 依赖模块：ratelimit, model, logger
+ */
+package scheduler
+
+import (
+	"cli-proxy/internal/model"
+	"cli-proxy/internal/proxy/adapter"
+	"cli-proxy/internal/ratelimit"
+	"cli-proxy/pkg/logger"
+)
+
+// WithTokenEstimate 设置请求 Token 预估（用于账户 Token 限额）
+func (r *RetryableRequest) WithTokenEstimate(estimate ratelimit.Usage) *RetryableRequest {
+	r.tokenEstimate = estimate
+	return r
+}
+
+// AccountTokenLimits 账户的每分钟 Token 限额
+func AccountTokenLimits(account *model.Account) ratelimit.Limits {
+	return ratelimit.Limits{TPM: account.TPMLimit, ITPM: account.ITPMLimit, OTPM: account.OTPMLimit}
+}
+
+// UsageFromResult 从上游实际用量换算限流用量（缓存读取不计入输入限额，与 Anthropic 计算方式一致）
+func UsageFromResult(result adapter.StreamResult) ratelimit.Usage {
+	return ratelimit.Usage{
+		Input:  result.InputTokens + result.CacheCreationInputTokens,
+		Output: result.OutputTokens,
+	}
+}
+
+// responseUsage 非流式响应的用量（与流式结果同一口径）
+func responseUsage(resp *adapter.Response) *adapter.StreamResult {
+	if resp == nil {
+		return nil
+	}
+	return &adapter.StreamResult{
+		InputTokens:  resp.InputTokens,
+		OutputTokens: resp.OutputTokens,
+	}
+}
+
+// reserveAccountTokens 按预估预扣账户 Token 额度；额度不足返回 false
+func (r *RetryableRequest) reserveAccountTokens(account *model.Account) bool {
+	limits := AccountTokenLimits(account)
+	if !limits.Enabled() {
+		return true
+	}
+	decision := ratelimit.Default().Reserve(ratelimit.AccountKey(account.ID), limits, r.tokenEstimate)
+	if !decision.Allowed {
+		logger.GetLogger("scheduler").WarnZ("账户 Token 额度不足",
+			logger.Uint("account_id", account.ID),
+			logger.String("account_name", account.Name),
+			logger.String("exceeded", decision.Exceeded),
+			logger.Int("estimate_input", r.tokenEstimate.Input),
+			logger.Duration("retry_after", decision.RetryAfter),
+		)
+	}
+	return decision.Allowed
+}
+
+// settleAccountTokens 请求成功后按实际用量修正预扣
+func (r *RetryableRequest) settleAccountTokens(account *model.Account, actual ratelimit.Usage) {
+	limits := AccountTokenLimits(account)
+	if !limits.Enabled() {
+		return
+	}
+	ratelimit.Default().Adjust(ratelimit.AccountKey(account.ID), limits, r.tokenEstimate, actual)
+}
+
+// refundAccountTokens 请求未执行或失败时退回预扣
+func (r *RetryableRequest) refundAccountTokens(account *model.Account) {
+	limits := AccountTokenLimits(account)
+	if !limits.Enabled() {
+		return
+	}
+	ratelimit.Default().Refund(ratelimit.AccountKey(account.ID), limits, r.tokenEstimate)
+}
@@ -0,0 +1,485 @@
+/*
+ * 文件作用：代理池分配管理器，为绑定代理池的账户分配出口代理
+ * 负责功能：
+ *   - 代理池及成员缓存（启动时加载，配置变更或健康检查后刷新）
+ *   - 按账户粘性分配池内健康代理（分配数少、延迟低者优先）
+ *   - 连接失败时自动切换到池内其他代理，连续失败达到阈值后临时排除
+ *   - 账户出口地区已知时只在同地区代理间分配与切换（代理池未允许跨地区时拒绝跨地区分配）
+ *   - 健康检查结果同步（测试失败的代理不参与分配）
+ *   - 代理池运行状态快照
+ * 重要程度：⭐⭐⭐⭐ 重要（出口代理可用性）
+ * 依赖模块：model, repository, logger
+ */
+package proxypool
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"cli-proxy/internal/model"
+	"cli-proxy/internal/repository"
+	"cli-proxy/pkg/logger"
+)
+
+// Settings 故障排除配置
+type Settings struct {
+	FailureThreshold int           // 连续连接失败多少次后临时排除代理
+	Cooldown         time.Duration // 排除时长
+}
+
+// DefaultSettings 默认故障排除配置
+var DefaultSettings = Settings{
+	FailureThreshold: 3,
+	Cooldown:         10 * time.Minute,
+}
+
+// assignment 账户当前分配的代理
+type assignment struct {
+	poolID  uint
+	proxyID uint
+	region  string // 分配时的账户出口地区（为空表示不限）
+}
+
+// proxyState 代理运行时健康状态
+type proxyState struct {
+	failures      int       // 连续连接失败次数
+	excludedUntil time.Time // 临时排除截止时间
+	lastError     string
+}
+
+// Manager 代理池分配管理器
+type Manager struct {
+	repo *repository.ProxyPoolRepository
+	now  func() time.Time
+
+	mu          sync.Mutex
+	settings    Settings
+	pools       map[uint]*model.ProxyPool
+	states      map[uint]*proxyState
+	assignments map[uint]assignment // 账户 ID → 分配
+	blocked     map[uint]string     // 账户 ID → 因池内没有其出口地区的代理而拒绝分配的地区（用于只告警一次）
+}
+
+var (
+	defaultManager *Manager
+	managerOnce    sync.Once
+)
+
+// GetManager 获取代理池分配管理器单例
+func GetManager() *Manager {
+	managerOnce.Do(func() {
+		defaultManager = newManager(repository.NewProxyPoolRepository())
+		defaultManager.Refresh()
+	})
+	return defaultManager
+}
+
+func newManager(repo *repository.ProxyPoolRepository) *Manager {
+	return &Manager{
+		repo:        repo,
+		now:         time.Now,
+		settings:    DefaultSettings,
+		pools:       make(map[uint]*model.ProxyPool),
+		states:      make(map[uint]*proxyState),
+		assignments: make(map[uint]assignment),
+		blocked:     make(map[uint]string),
+	}
+}
+
+// Refresh 从数据库重新加载代理池及成员
+func (m *Manager) Refresh() {
+	log := logger.GetLogger("proxy")
+	pools, err := m.repo.List()
+	if err != nil {
+		log.Error("刷新代理池缓存失败: %v", err)
+		return
+	}
+	m.setPools(pools)
+	log.Info("代理池缓存已刷新，共 %d 个代理池", len(pools))
+}
+
+// setPools 替换代理池缓存，保留仍在池内的分配和健康状态
+func (m *Manager) setPools(pools []model.ProxyPool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.pools = make(map[uint]*model.ProxyPool, len(pools))
+	members := make(map[uint]bool)
+	for i := range pools {
+		m.pools[pools[i].ID] = &pools[i]
+		for _, p := range pools[i].Proxies {
+			members[p.ID] = true
+		}
+	}
+	for id := range m.states {
+		if !members[id] {
+			delete(m.states, id)
+		}
+	}
+	for accountID, a := range m.assignments {
+		if m.member(a.poolID, a.proxyID) == nil {
+			delete(m.assignments, accountID)
+		}
+	}
+}
+
+// SetSettings 更新故障排除配置
+func (m *Manager) SetSettings(settings Settings) {
+	if settings.FailureThreshold <= 0 {
+		settings.FailureThreshold = DefaultSettings.FailureThreshold
+	}
+	if settings.Cooldown <= 0 {
+		settings.Cooldown = DefaultSettings.Cooldown
+	}
+	m.mu.Lock()
+	m.settings = settings
+	m.mu.Unlock()
+}
+
+// Select 为账户选择代理池内的出口代理
+// 已分配且仍可用时保持不变（粘性）；池内没有可用代理时保留原分配或选择最早恢复的代理，不会退回直连。
+// region 为账户已知的出口国家/地区，只在同地区（及出口地区未知）的代理中分配；
+// 池内没有该地区的启用代理时，除非代理池允许跨地区分配，否则拒绝分配并返回 nil（见 RegionBlocked）。
+// 代理池不存在、已禁用或没有启用的成员时返回 nil
+func (m *Manager) Select(accountID, poolID uint, region string) *model.Proxy {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	pool := m.pools[poolID]
+	if pool == nil || !pool.Enabled {
+		return nil
+	}
+	now := m.now()
+
+	filter, ok := poolRegion(pool, region)
+	if !ok {
+		m.blockRegion(accountID, pool, region)
+		return nil
+	}
+	delete(m.blocked, accountID)
+	if a, ok := m.assignments[accountID]; ok && a.poolID == poolID {
+		if p := m.member(poolID, a.proxyID); p != nil && m.available(p, now) && inRegion(p, filter) {
+			proxy := *p
+			return &proxy
+		}
+	}
+
+	p := m.pick(pool, accountID, 0, filter, now)
+	if p == nil {
+		delete(m.assignments, accountID)
+		return nil
+	}
+	if filter == "" && region != "" && p.EgressCountry != "" && p.EgressCountry != region {
+		logger.GetLogger("proxy").Warn("代理池允许跨地区分配，账户出口切换到其他地区 | 账户: %d | 代理池: %s | 代理: %s | 地区: %s → %s",
+			accountID, pool.Name, p.Name, region, p.EgressCountry)
+	}
+	m.assignments[accountID] = assignment{poolID: poolID, proxyID: p.ID, region: filter}
+	proxy := *p
+	return &proxy
+}
+
+// RegionBlocked 代理池内是否没有 region 地区（或出口地区未知）的启用代理且不允许跨地区分配
+// 此时 Select 拒绝为该地区的账户分配代理，调度器不应使用该账户；代理池不存在或已禁用时返回 false
+func (m *Manager) RegionBlocked(poolID uint, region string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	pool := m.pools[poolID]
+	if pool == nil || !pool.Enabled {
+		return false
+	}
+	_, ok := poolRegion(pool, region)
+	return !ok
+}
+
+// blockRegion 拒绝跨地区分配：释放账户原分配，同一账户同一地区只告警一次（调用方需持有锁）
+func (m *Manager) blockRegion(accountID uint, pool *model.ProxyPool, region string) {
+	delete(m.assignments, accountID)
+	if m.blocked[accountID] == region {
+		return
+	}
+	m.blocked[accountID] = region
+	logger.GetLogger("proxy").Warn("代理池内没有账户出口地区的可用代理，拒绝跨地区分配 | 账户: %d | 代理池: %s | 地区: %s（如需切换请开启代理池 allow_cross_region 或重置账户出口）",
+		accountID, pool.Name, region)
+}
+
+// ReportFailure 报告账户经 proxyID（发起请求时使用的代理）连接失败，并将账户切换到池内其他可用代理（限定在分配时的出口地区内）
+// proxyID 与账户当前分配不一致时视为过期报告（分配已被其他请求切换），直接忽略并返回 0, 0。
+// 返回失败的代理 ID 和切换后的代理 ID（没有其他可用代理时与失败代理相同）
+func (m *Manager) ReportFailure(accountID, proxyID uint, reason string) (failedID, nextID uint) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	a, ok := m.assignments[accountID]
+	if !ok || a.proxyID != proxyID {
+		return 0, 0
+	}
+	now := m.now()
+	st := m.state(a.proxyID)
+	st.failures++
+	st.lastError = reason
+	if st.failures >= m.settings.FailureThreshold {
+		st.excludedUntil = now.Add(m.settings.Cooldown)
+		st.failures = 0
+	}
+
+	pool := m.pools[a.poolID]
+	if pool == nil {
+		delete(m.assignments, accountID)
+		return a.proxyID, 0
+	}
+	next := m.pick(pool, accountID, a.proxyID, a.region, now)
+	if next == nil || !m.available(next, now) {
+		// 没有其他可用代理：保留原分配
+		return a.proxyID, a.proxyID
+	}
+	m.assignments[accountID] = assignment{poolID: a.poolID, proxyID: next.ID, region: a.region}
+	return a.proxyID, next.ID
+}
+
+// ReportSuccess 报告经 proxyID 的请求成功，清零该代理的连续失败计数
+func (m *Manager) ReportSuccess(proxyID uint) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if st := m.states[proxyID]; st != nil {
+		st.failures = 0
+	}
+}
+
+// UpdateHealth 同步代理连通性测试结果，测试成功时解除临时排除
+func (m *Manager) UpdateHealth(proxyID uint, status string, latency int, errMsg string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	found := false
+	for _, pool := range m.pools {
+		for i := range pool.Proxies {
+			if pool.Proxies[i].ID == proxyID {
+				pool.Proxies[i].TestStatus = status
+				pool.Proxies[i].TestLatency = latency
+				pool.Proxies[i].TestError = errMsg
+				found = true
+			}
+		}
+	}
+	if found && status == model.ProxyTestStatusSuccess {
+		delete(m.states, proxyID)
+	}
+}
+
+// UpdateEgress 同步代理出口 IP 探测结果
+func (m *Manager) UpdateEgress(proxyID uint, ip, country string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, pool := range m.pools {
+		for i := range pool.Proxies {
+			if pool.Proxies[i].ID == proxyID {
+				pool.Proxies[i].EgressIP = ip
+				pool.Proxies[i].EgressCountry = country
+			}
+		}
+	}
+}
+
+// Release 移除账户的代理分配（账户改绑或删除时调用）
+func (m *Manager) Release(accountID uint) {
+	m.mu.Lock()
+	delete(m.assignments, accountID)
+	delete(m.blocked, accountID)
+	m.mu.Unlock()
+}
+
+// MemberStatus 池内代理运行状态
+type MemberStatus struct {
+	ProxyID       uint       `json:"proxy_id"`
+	Name          string     `json:"name"`
+	Enabled       bool       `json:"enabled"`
+	Available     bool       `json:"available"` // 是否参与分配
+	TestStatus    string     `json:"test_status"`
+	TestLatency   int        `json:"test_latency"`
+	Accounts      int        `json:"accounts"` // 当前分配的账户数
+	Failures      int        `json:"failures"` // 连续连接失败次数
+	ExcludedUntil *time.Time `json:"excluded_until,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+}
+
+// PoolStatus 代理池运行状态
+type PoolStatus struct {
+	PoolID      uint           `json:"pool_id"`
+	Name        string         `json:"name"`
+	Enabled     bool           `json:"enabled"`
+	Available   int            `json:"available"`   // 可用代理数
+	Assignments map[uint]uint  `json:"assignments"` // 账户 ID → 代理 ID
+	Members     []MemberStatus `json:"members"`
+}
+
+// Status 获取代理池运行状态快照，代理池不存在时返回 nil
+func (m *Manager) Status(poolID uint) *PoolStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	pool := m.pools[poolID]
+	if pool == nil {
+		return nil
+	}
+	now := m.now()
+	status := &PoolStatus{
+		PoolID:      pool.ID,
+		Name:        pool.Name,
+		Enabled:     pool.Enabled,
+		Assignments: make(map[uint]uint),
+		Members:     make([]MemberStatus, 0, len(pool.Proxies)),
+	}
+	counts := make(map[uint]int)
+	for accountID, a := range m.assignments {
+		if a.poolID == poolID {
+			status.Assignments[accountID] = a.proxyID
+			counts[a.proxyID]++
+		}
+	}
+	for i := range pool.Proxies {
+		p := &pool.Proxies[i]
+		ms := MemberStatus{
+			ProxyID:     p.ID,
+			Name:        p.Name,
+			Enabled:     p.Enabled,
+			Available:   m.available(p, now),
+			TestStatus:  p.TestStatus,
+			TestLatency: p.TestLatency,
+			Accounts:    counts[p.ID],
+		}
+		if st := m.states[p.ID]; st != nil {
+			ms.Failures = st.failures
+			ms.LastError = st.lastError
+			if now.Before(st.excludedUntil) {
+				until := st.excludedUntil
+				ms.ExcludedUntil = &until
+			}
+		}
+		if ms.Available {
+			status.Available++
+		}
+		status.Members = append(status.Members, ms)
+	}
+	return status
+}
+
+// pick 从池内选择代理：排除 skipID 和其他地区的代理，优先可用代理中分配账户数最少、延迟最低者；
+// 没有可用代理时返回最早恢复的启用代理（调用方需持有锁）
+func (m *Manager) pick(pool *model.ProxyPool, accountID, skipID uint, region string, now time.Time) *model.Proxy {
+	counts := make(map[uint]int)
+	for id, a := range m.assignments {
+		if id != accountID && a.poolID == pool.ID {
+			counts[a.proxyID]++
+		}
+	}
+
+	var available, fallback []*model.Proxy
+	for i := range pool.Proxies {
+		p := &pool.Proxies[i]
+		if !p.Enabled || p.ID == skipID || !inRegion(p, region) {
+			continue
+		}
+		if m.available(p, now) {
+			available = append(available, p)
+		} else {
+			fallback = append(fallback, p)
+		}
+	}
+
+	if len(available) > 0 {
+		sort.SliceStable(available, func(i, j int) bool {
+			a, b := available[i], available[j]
+			if counts[a.ID] != counts[b.ID] {
+				return counts[a.ID] < counts[b.ID]
+			}
+			if latencyRank(a) != latencyRank(b) {
+				return latencyRank(a) < latencyRank(b)
+			}
+			return a.ID < b.ID
+		})
+		return available[0]
+	}
+
+	if a, ok := m.assignments[accountID]; ok && a.poolID == pool.ID && a.proxyID != skipID {
+		if p := m.member(pool.ID, a.proxyID); p != nil && p.Enabled {
+			return p
+		}
+	}
+	if len(fallback) == 0 {
+		if skipID != 0 {
+			return m.member(pool.ID, skipID)
+		}
+		return nil
+	}
+	sort.SliceStable(fallback, func(i, j int) bool {
+		return m.state(fallback[i].ID).excludedUntil.Before(m.state(fallback[j].ID).excludedUntil)
+	})
+	return fallback[0]
+}
+
+// available 代理是否可参与分配：已启用、最近一次测试未失败且不在临时排除期内（调用方需持有锁）
+func (m *Manager) available(p *model.Proxy, now time.Time) bool {
+	if !p.Enabled || p.TestStatus == model.ProxyTestStatusFailed {
+		return false
+	}
+	st := m.states[p.ID]
+	return st == nil || !now.Before(st.excludedUntil)
+}
+
+// member 查找池内代理（调用方需持有锁）
+func (m *Manager) member(poolID, proxyID uint) *model.Proxy {
+	pool := m.pools[poolID]
+	if pool == nil {
+		return nil
+	}
+	for i := range pool.Proxies {
+		if pool.Proxies[i].ID == proxyID {
+			return &pool.Proxies[i]
+		}
+	}
+	return nil
+}
+
+// state 获取或创建代理健康状态（调用方需持有锁）
+func (m *Manager) state(proxyID uint) *proxyState {
+	st := m.states[proxyID]
+	if st == nil {
+		st = &proxyState{}
+		m.states[proxyID] = st
+	}
+	return st
+}
+
+// poolRegion 返回用于筛选的地区及是否允许分配：池内有该地区（或出口地区未知）的启用代理时限定该地区；
+// 没有时仅在代理池允许跨地区分配时不限地区，否则拒绝分配
+func poolRegion(pool *model.ProxyPool, region string) (string, bool) {
+	if region == "" {
+		return "", true
+	}
+	for i := range pool.Proxies {
+		if pool.Proxies[i].Enabled && inRegion(&pool.Proxies[i], region) {
+			return region, true
+		}
+	}
+	if pool.AllowCrossRegion {
+		return "", true
+	}
+	return region, false
+}
+
+// inRegion 代理是否属于指定地区（不限地区或代理出口地区未知时视为属于）
+func inRegion(p *model.Proxy, region string) bool {
+	return region == "" || p.EgressCountry == "" || p.EgressCountry == region
+}
+
+// latencyRank 排序用延迟，未测试的代理排在已测试代理之后
+func latencyRank(p *model.Proxy) int {
+	if p.TestStatus == "" || p.TestLatency <= 0 {
+		return int(^uint(0) >> 1)
+	}
+	return p.TestLatency
+}
@@ -0,0 +1,217 @@
+package proxypool
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"cli-proxy/internal/model"
+	"cli-proxy/pkg/logger"
+)
+
+func TestMain(m *testing.M) {
+	dir, _ := os.MkdirTemp("", "proxypool-test")
+	logger.Init(dir, logger.LevelWarn)
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func newTestManager(now *time.Time, proxies ...model.Proxy) *Manager {
+	m := newManager(nil)
+	m.now = func() time.Time { return *now }
+	m.setPools([]model.ProxyPool{{ID: 1, Name: "residential", Enabled: true, Proxies: proxies}})
+	return m
+}
+
+func TestSelectStickyAndBalanced(t *testing.T) {
+	now := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	m := newTestManager(&now,
+		model.Proxy{ID: 1, Enabled: true, TestStatus: model.ProxyTestStatusSuccess, TestLatency: 300},
+		model.Proxy{ID: 2, Enabled: true, TestStatus: model.ProxyTestStatusSuccess, TestLatency: 100},
+		model.Proxy{ID: 3, Enabled: true, TestStatus: model.ProxyTestStatusFailed, TestLatency: 50},
+		model.Proxy{ID: 4, Enabled: false},
+	)
+
+	// 延迟低者优先，分配数少者优先，测试失败和禁用的代理不参与
+	if p := m.Select(100, 1, ""); p == nil || p.ID != 2 {
+		t.Fatalf("expected proxy 2, got %+v", p)
+	}
+	if p := m.Select(101, 1, ""); p == nil || p.ID != 1 {
+		t.Fatalf("expected proxy 1 for second account, got %+v", p)
+	}
+	for i := 0; i < 3; i++ {
+		if p := m.Select(100, 1, ""); p.ID != 2 {
+			t.Fatalf("expected sticky proxy 2, got %d", p.ID)
+		}
+	}
+
+	// 不存在或禁用的代理池返回 nil
+	if p := m.Select(100, 9, ""); p != nil {
+		t.Fatalf("expected nil for unknown pool")
+	}
+	m.pools[1].Enabled = false
+	if p := m.Select(100, 1, ""); p != nil {
+		t.Fatalf("expected nil for disabled pool")
+	}
+}
+
+func TestFailoverAndExclusion(t *testing.T) {
+	now := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	m := newTestManager(&now,
+		model.Proxy{ID: 1, Enabled: true, TestStatus: model.ProxyTestStatusSuccess, TestLatency: 100},
+		model.Proxy{ID: 2, Enabled: true, TestStatus: model.ProxyTestStatusSuccess, TestLatency: 200},
+	)
+	m.SetSettings(Settings{FailureThreshold: 2, Cooldown: 5 * time.Minute})
+
+	m.Select(100, 1, "")
+	m.Select(101, 1, "")
+
+	// 连接失败立即切换，但未达阈值不排除
+	if failed, next := m.ReportFailure(100, 1, "connection refused"); failed != 1 || next != 2 {
+		t.Fatalf("expected failover 1 -> 2, got %d -> %d", failed, next)
+	}
+	if p := m.Select(100, 1, ""); p.ID != 2 {
+		t.Fatalf("expected account moved to proxy 2, got %d", p.ID)
+	}
+	if st := m.Status(1); st.Available != 2 || st.Members[0].Failures != 1 {
+		t.Fatalf("unexpected status: %+v", st)
+	}
+
+	// 达到阈值后排除，即使其他代理分配的账户更多也不再分配被排除的代理
+	m.assignments[102] = assignment{poolID: 1, proxyID: 1}
+	m.ReportFailure(102, 1, "i/o timeout")
+	if p := m.Select(104, 1, ""); p.ID != 2 {
+		t.Fatalf("expected excluded proxy skipped, got %d", p.ID)
+	}
+	if st := m.Status(1); st.Available != 1 || st.Members[0].ExcludedUntil == nil {
+		t.Fatalf("expected proxy 1 excluded, got %+v", st.Members[0])
+	}
+
+	// 所有代理都不可用时保留原分配，不退回直连
+	m.UpdateHealth(2, model.ProxyTestStatusFailed, 0, "dial tcp: timeout")
+	if p := m.Select(100, 1, ""); p == nil || p.ID != 2 {
+		t.Fatalf("expected sticky proxy during outage, got %+v", p)
+	}
+	if failed, next := m.ReportFailure(100, 2, "eof"); failed != 2 || next != 2 {
+		t.Fatalf("expected no failover target, got %d -> %d", failed, next)
+	}
+
+	// 冷却结束后恢复；健康检查成功立即恢复
+	now = now.Add(6 * time.Minute)
+	if p := m.Select(103, 1, ""); p == nil || p.ID != 1 {
+		t.Fatalf("expected proxy 1 after cooldown, got %+v", p)
+	}
+	m.UpdateHealth(2, model.ProxyTestStatusSuccess, 150, "")
+	if st := m.Status(1); st.Available != 2 {
+		t.Fatalf("expected both proxies available, got %+v", st)
+	}
+}
+
+func TestSetPoolsPrunesRemovedMembers(t *testing.T) {
+	now := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	m := newTestManager(&now,
+		model.Proxy{ID: 1, Enabled: true},
+		model.Proxy{ID: 2, Enabled: true},
+	)
+	m.Select(100, 1, "")
+	m.ReportFailure(100, 1, "connection reset")
+
+	m.setPools([]model.ProxyPool{{ID: 1, Enabled: true, Proxies: []model.Proxy{{ID: 3, Enabled: true}}}})
+	if len(m.assignments) != 0 || len(m.states) != 0 {
+		t.Fatalf("expected assignments and states pruned, got %v %v", m.assignments, m.states)
+	}
+	if p := m.Select(100, 1, ""); p == nil || p.ID != 3 {
+		t.Fatalf("expected new member, got %+v", p)
+	}
+}
+
+func TestSelectAndFailoverStayInRegion(t *testing.T) {
+	now := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	m := newTestManager(&now,
+		model.Proxy{ID: 1, Enabled: true, EgressCountry: "US", TestStatus: model.ProxyTestStatusSuccess, TestLatency: 300},
+		model.Proxy{ID: 2, Enabled: true, EgressCountry: "JP", TestStatus: model.ProxyTestStatusSuccess, TestLatency: 100},
+		model.Proxy{ID: 3, Enabled: true, EgressCountry: "US", TestStatus: model.ProxyTestStatusSuccess, TestLatency: 200},
+	)
+
+	// 已知地区时只分配同地区代理
+	if p := m.Select(100, 1, "US"); p == nil || p.ID != 3 {
+		t.Fatalf("expected US proxy 3, got %+v", p)
+	}
+	// 切换时不跨地区
+	if _, next := m.ReportFailure(100, 3, "connection reset"); next != 1 {
+		t.Fatalf("expected failover to US proxy 1, got %d", next)
+	}
+	// 池内没有该地区代理时拒绝跨地区分配
+	if p := m.Select(101, 1, "DE"); p != nil {
+		t.Fatalf("expected cross-region pick refused, got %+v", p)
+	}
+	// 分配的代理出口地区变化后重新分配
+	m.UpdateEgress(1, "203.0.113.9", "JP")
+	if p := m.Select(100, 1, "US"); p == nil || p.ID != 3 {
+		t.Fatalf("expected reassignment to US proxy 3, got %+v", p)
+	}
+}
+
+func TestSelectRefusesCrossRegionWhenSameRegionDisabled(t *testing.T) {
+	now := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	proxies := []model.Proxy{
+		{ID: 1, Enabled: true, EgressCountry: "US", TestStatus: model.ProxyTestStatusSuccess},
+		{ID: 2, Enabled: true, EgressCountry: "JP", TestStatus: model.ProxyTestStatusSuccess},
+	}
+	m := newTestManager(&now, proxies...)
+	if p := m.Select(100, 1, "US"); p == nil || p.ID != 1 {
+		t.Fatalf("expected US proxy 1, got %+v", p)
+	}
+
+	// 同地区成员全部禁用：不分配其他地区的代理
+	proxies[0].Enabled = false
+	m.setPools([]model.ProxyPool{{ID: 1, Name: "residential", Enabled: true, Proxies: proxies}})
+	if p := m.Select(100, 1, "US"); p != nil {
+		t.Fatalf("expected no cross-region assignment, got %+v", p)
+	}
+	if !m.RegionBlocked(1, "US") || m.RegionBlocked(1, "JP") || m.RegionBlocked(1, "") {
+		t.Fatal("unexpected RegionBlocked result")
+	}
+	if _, ok := m.assignments[100]; ok {
+		t.Fatal("expected previous assignment released")
+	}
+
+	// 代理池显式允许跨地区时才分配其他地区的代理
+	m.setPools([]model.ProxyPool{{ID: 1, Name: "residential", Enabled: true, AllowCrossRegion: true, Proxies: proxies}})
+	if m.RegionBlocked(1, "US") {
+		t.Fatal("expected override to unblock region")
+	}
+	if p := m.Select(100, 1, "US"); p == nil || p.ID != 2 {
+		t.Fatalf("expected cross-region proxy 2 with override, got %+v", p)
+	}
+}
+
+func TestStaleProxyReportsIgnored(t *testing.T) {
+	now := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	m := newTestManager(&now,
+		model.Proxy{ID: 1, Enabled: true, TestStatus: model.ProxyTestStatusSuccess, TestLatency: 100},
+		model.Proxy{ID: 2, Enabled: true, TestStatus: model.ProxyTestStatusSuccess, TestLatency: 200},
+	)
+	m.Select(100, 1, "")
+
+	// 两个并发请求都经代理 1 失败：第一个切换到代理 2，第二个是过期报告，不能再把账户从代理 2 切走
+	if failed, next := m.ReportFailure(100, 1, "connection reset"); failed != 1 || next != 2 {
+		t.Fatalf("expected failover 1 -> 2, got %d -> %d", failed, next)
+	}
+	if failed, next := m.ReportFailure(100, 1, "connection reset"); failed != 0 || next != 0 {
+		t.Fatalf("expected stale report ignored, got %d -> %d", failed, next)
+	}
+	if p := m.Select(100, 1, ""); p.ID != 2 {
+		t.Fatalf("expected account kept on proxy 2, got %d", p.ID)
+	}
+	if st := m.Status(1); st.Members[0].Failures != 1 || st.Members[1].Failures != 0 {
+		t.Fatalf("unexpected failure counts: %+v", st.Members)
+	}
+
+	// 成功清零的是请求实际经过的代理
+	m.ReportSuccess(1)
+	if st := m.Status(1); st.Members[0].Failures != 0 {
+		t.Fatalf("expected proxy 1 failures cleared, got %+v", st.Members[0])
+	}
+}
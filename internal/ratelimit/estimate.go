@@ -0,0 +1,103 @@
+/*
+ * 文件作用：请求输入 Token 预估
+ * 负责功能：
+ *   - 转发前按请求体粗略估算输入 Token（用于令牌桶预扣，完成后按实际用量修正）
+ *   - ASCII 文本约 4 字符 1 Token，CJK 等非 ASCII 字符约 1 字符 1 Token
+ *   - 内联图片（base64）按固定 Token 数计算，避免按字符数严重高估
+ * 重要程度：⭐⭐⭐ 一般
+ * 依赖模块：无
+ */
+package ratelimit
+
+import (
+	"encoding/json"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// imageTokenEstimate 单张内联图片的预估 Token 数
+	imageTokenEstimate = 1600
+	// inlineDataThreshold 超过该长度且无空白的字符串视为 base64 内联数据
+	inlineDataThreshold = 1024
+)
+
+// skippedFields 不计入预估的请求字段（参数类字段，不会成为模型输入）
+var skippedFields = map[string]bool{
+	"model":       true,
+	"max_tokens":  true,
+	"temperature": true,
+	"top_p":       true,
+	"top_k":       true,
+	"stream":      true,
+	"metadata":    true,
+}
+
+// EstimateInputTokens 估算请求体的输入 Token 数
+func EstimateInputTokens(body []byte) int {
+	if len(body) == 0 {
+		return 0
+	}
+
+	var root interface{}
+	if err := json.Unmarshal(body, &root); err != nil {
+		return estimateText(string(body))
+	}
+
+	if obj, ok := root.(map[string]interface{}); ok {
+		total := 0
+		for key, value := range obj {
+			if skippedFields[key] {
+				continue
+			}
+			total += estimateValue(value)
+		}
+		return total
+	}
+	return estimateValue(root)
+}
+
+func estimateValue(value interface{}) int {
+	switch v := value.(type) {
+	case string:
+		if isInlineData(v) {
+			return imageTokenEstimate
+		}
+		return estimateText(v)
+	case []interface{}:
+		total := 0
+		for _, item := range v {
+			total += estimateValue(item)
+		}
+		return total
+	case map[string]interface{}:
+		total := 0
+		for _, item := range v {
+			total += estimateValue(item)
+		}
+		return total
+	default:
+		return 0
+	}
+}
+
+// isInlineData 是否为 base64 内联数据（data URL 或超长无空白字符串）
+func isInlineData(s string) bool {
+	if strings.HasPrefix(s, "data:") && strings.Contains(s, ";base64,") {
+		return true
+	}
+	return len(s) > inlineDataThreshold && !strings.ContainsAny(s, " \n\t")
+}
+
+// estimateText 估算一段文本的 Token 数
+func estimateText(s string) int {
+	ascii, other := 0, 0
+	for _, r := range s {
+		if r < utf8.RuneSelf {
+			ascii++
+		} else {
+			other++
+		}
+	}
+	return (ascii+3)/4 + other
+}
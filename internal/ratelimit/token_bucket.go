@@ -0,0 +1,325 @@
+/*
+ * 文件作用：按 Token 数的令牌桶限流（TPM / ITPM / OTPM）
+ * 负责功能：
+ *   - 每个对象（API Key / 账户）维护总 Token、输入 Token、输出 Token 三个令牌桶
+ *   - 令牌桶容量为每分钟限额，按 限额/60 每秒匀速补充
+ *   - 转发前按预估输入 Token 检查并扣减，完成后按实际用量修正（允许透支）
+ *   - 请求失败时退回预估扣减
+ *   - 剩余额度、恢复时间查询（用于 x-ratelimit-* 响应头）
+ * 重要程度：⭐⭐⭐⭐ 重要（防止单个 Key 或账户在短时间内耗尽上游 Token 配额）
+ * 依赖模块：无
+ */
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// bucketIdleTTL 令牌桶空闲（已回满且无访问）多久后回收
+const bucketIdleTTL = 10 * time.Minute
+
+// Limits 每分钟 Token 限额（0=不限）
+type Limits struct {
+	TPM  int // 每分钟总 Token（输入+输出）
+	ITPM int // 每分钟输入 Token
+	OTPM int // 每分钟输出 Token
+}
+
+// Enabled 是否设置了任一限额
+func (l Limits) Enabled() bool {
+	return l.TPM > 0 || l.ITPM > 0 || l.OTPM > 0
+}
+
+// Usage Token 用量
+type Usage struct {
+	Input  int
+	Output int
+}
+
+// Total 总 Token 数
+func (u Usage) Total() int {
+	return u.Input + u.Output
+}
+
+// BucketStatus 单个令牌桶状态
+type BucketStatus struct {
+	Limit     int           // 每分钟限额（0=不限）
+	Remaining int           // 当前剩余 Token（透支时为 0）
+	Reset     time.Duration // 回满所需时间
+}
+
+// Status 一个对象的全部令牌桶状态
+type Status struct {
+	Tokens       BucketStatus
+	InputTokens  BucketStatus
+	OutputTokens BucketStatus
+}
+
+// Decision 预留结果
+type Decision struct {
+	Allowed    bool
+	RetryAfter time.Duration // 被拒绝时，额度恢复到可放行所需时间
+	Exceeded   string        // 被拒绝时超出的限额: tpm / itpm / otpm
+	Status     Status
+}
+
+// bucket 单个令牌桶（tokens 可为负数，表示实际用量超出预估后的透支）
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// refill 按经过时间补充令牌
+func (b *bucket) refill(limit int, now time.Time) {
+	if limit <= 0 {
+		return
+	}
+	if b.last.IsZero() {
+		b.tokens = float64(limit)
+		b.last = now
+		return
+	}
+	elapsed := now.Sub(b.last).Seconds()
+	if elapsed > 0 {
+		b.tokens = math.Min(float64(limit), b.tokens+elapsed*float64(limit)/60)
+		b.last = now
+	}
+}
+
+// wait 余额达到 need 所需时间
+func (b *bucket) wait(limit int, need float64) time.Duration {
+	if limit <= 0 || b.tokens >= need {
+		return 0
+	}
+	seconds := (need - b.tokens) * 60 / float64(limit)
+	return time.Duration(math.Ceil(seconds*1000)) * time.Millisecond
+}
+
+func (b *bucket) status(limit int) BucketStatus {
+	if limit <= 0 {
+		return BucketStatus{}
+	}
+	remaining := int(math.Floor(b.tokens))
+	if remaining < 0 {
+		remaining = 0
+	}
+	return BucketStatus{
+		Limit:     limit,
+		Remaining: remaining,
+		Reset:     b.wait(limit, float64(limit)),
+	}
+}
+
+// bucketSet 一个对象的三个令牌桶
+type bucketSet struct {
+	total  bucket
+	input  bucket
+	output bucket
+	used   time.Time
+}
+
+func (s *bucketSet) refill(limits Limits, now time.Time) {
+	s.total.refill(limits.TPM, now)
+	s.input.refill(limits.ITPM, now)
+	s.output.refill(limits.OTPM, now)
+	s.used = now
+}
+
+func (s *bucketSet) status(limits Limits) Status {
+	return Status{
+		Tokens:       s.total.status(limits.TPM),
+		InputTokens:  s.input.status(limits.ITPM),
+		OutputTokens: s.output.status(limits.OTPM),
+	}
+}
+
+// Limiter Token 限流器
+type Limiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucketSet
+	now     func() time.Time
+}
+
+var (
+	defaultLimiter     *Limiter
+	defaultLimiterOnce sync.Once
+)
+
+// Default 获取全局 Token 限流器（API Key 与账户共用，按 key 前缀区分）
+func Default() *Limiter {
+	defaultLimiterOnce.Do(func() {
+		defaultLimiter = NewLimiter()
+		go defaultLimiter.cleanupLoop()
+	})
+	return defaultLimiter
+}
+
+// NewLimiter 创建 Token 限流器
+func NewLimiter() *Limiter {
+	return &Limiter{
+		buckets: make(map[string]*bucketSet),
+		now:     time.Now,
+	}
+}
+
+// APIKeyKey API Key 的令牌桶 key
+func APIKeyKey(id uint) string {
+	return fmt.Sprintf("apikey:%d", id)
+}
+
+// AccountKey 账户的令牌桶 key
+func AccountKey(id uint) string {
+	return fmt.Sprintf("account:%d", id)
+}
+
+func (l *Limiter) getLocked(key string) *bucketSet {
+	set, ok := l.buckets[key]
+	if !ok {
+		set = &bucketSet{}
+		l.buckets[key] = set
+	}
+	return set
+}
+
+// need 放行所需余额：至少 1 个 Token，且不超过桶容量（超大请求等桶回满后放行，避免永远饿死）
+func need(estimate, limit int) float64 {
+	n := estimate
+	if n > limit {
+		n = limit
+	}
+	if n < 1 {
+		n = 1
+	}
+	return float64(n)
+}
+
+// Reserve 按预估用量检查额度，放行时立即扣减预估值
+func (l *Limiter) Reserve(key string, limits Limits, estimate Usage) Decision {
+	if !limits.Enabled() {
+		return Decision{Allowed: true}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	set := l.getLocked(key)
+	set.refill(limits, l.now())
+
+	checks := []struct {
+		name     string
+		b        *bucket
+		limit    int
+		estimate int
+	}{
+		{"tpm", &set.total, limits.TPM, estimate.Total()},
+		{"itpm", &set.input, limits.ITPM, estimate.Input},
+		{"otpm", &set.output, limits.OTPM, estimate.Output},
+	}
+
+	decision := Decision{Allowed: true}
+	for _, c := range checks {
+		if c.limit <= 0 {
+			continue
+		}
+		if wait := c.b.wait(c.limit, need(c.estimate, c.limit)); wait > decision.RetryAfter {
+			decision.Allowed = false
+			decision.RetryAfter = wait
+			decision.Exceeded = c.name
+		}
+	}
+
+	if decision.Allowed {
+		set.total.tokens -= float64(estimate.Total())
+		set.input.tokens -= float64(estimate.Input)
+		set.output.tokens -= float64(estimate.Output)
+	}
+	decision.Status = set.status(limits)
+	return decision
+}
+
+// Adjust 按实际用量修正预估扣减（实际多于预估时继续扣减，可透支；少于预估时退回差额）
+func (l *Limiter) Adjust(key string, limits Limits, estimate, actual Usage) {
+	if !limits.Enabled() {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	set := l.getLocked(key)
+	set.refill(limits, l.now())
+	set.total.tokens = adjustTokens(set.total.tokens, limits.TPM, actual.Total()-estimate.Total())
+	set.input.tokens = adjustTokens(set.input.tokens, limits.ITPM, actual.Input-estimate.Input)
+	set.output.tokens = adjustTokens(set.output.tokens, limits.OTPM, actual.Output-estimate.Output)
+}
+
+func adjustTokens(tokens float64, limit, delta int) float64 {
+	if limit <= 0 {
+		return tokens
+	}
+	return math.Min(float64(limit), tokens-float64(delta))
+}
+
+// Refund 请求失败时退回预估扣减
+func (l *Limiter) Refund(key string, limits Limits, estimate Usage) {
+	l.Adjust(key, limits, estimate, Usage{})
+}
+
+// Check 检查当前额度是否足以放行预估用量（不扣减），不足时返回恢复所需时间
+func (l *Limiter) Check(key string, limits Limits, estimate Usage) (bool, time.Duration) {
+	if !limits.Enabled() {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	set := l.getLocked(key)
+	set.refill(limits, l.now())
+	wait := set.total.wait(limits.TPM, need(estimate.Total(), limits.TPM))
+	if w := set.input.wait(limits.ITPM, need(estimate.Input, limits.ITPM)); w > wait {
+		wait = w
+	}
+	if w := set.output.wait(limits.OTPM, need(estimate.Output, limits.OTPM)); w > wait {
+		wait = w
+	}
+	return wait == 0, wait
+}
+
+// Peek 查询当前额度状态（不扣减）
+func (l *Limiter) Peek(key string, limits Limits) Status {
+	if !limits.Enabled() {
+		return Status{}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	set := l.getLocked(key)
+	set.refill(limits, l.now())
+	return set.status(limits)
+}
+
+// cleanupLoop 定期回收空闲令牌桶
+func (l *Limiter) cleanupLoop() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for range ticker.C {
+		l.cleanup()
+	}
+}
+
+func (l *Limiter) cleanup() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	for key, set := range l.buckets {
+		if now.Sub(set.used) > bucketIdleTTL {
+			delete(l.buckets, key)
+		}
+	}
+}
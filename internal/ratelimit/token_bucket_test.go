@@ -0,0 +1,120 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+// newTestLimiter 创建使用可控时钟的限流器
+func newTestLimiter() (*Limiter, *time.Time) {
+	now := time.Unix(1700000000, 0)
+	l := NewLimiter()
+	l.now = func() time.Time { return now }
+	return l, &now
+}
+
+func TestReserveDebitsAndRefills(t *testing.T) {
+	l, now := newTestLimiter()
+	limits := Limits{TPM: 600}
+
+	d := l.Reserve("k", limits, Usage{Input: 500})
+	if !d.Allowed || d.Status.Tokens.Remaining != 100 {
+		t.Fatalf("expected allowed with 100 remaining, got %+v", d)
+	}
+
+	// 余额 100 不足以放行 200
+	d = l.Reserve("k", limits, Usage{Input: 200})
+	if d.Allowed || d.Exceeded != "tpm" {
+		t.Fatalf("expected tpm rejection, got %+v", d)
+	}
+	// 600/分钟 = 10/秒，缺 100 需要 10 秒
+	if d.RetryAfter != 10*time.Second {
+		t.Fatalf("expected retry after 10s, got %v", d.RetryAfter)
+	}
+
+	*now = now.Add(10 * time.Second)
+	if d = l.Reserve("k", limits, Usage{Input: 200}); !d.Allowed {
+		t.Fatalf("expected allowed after refill, got %+v", d)
+	}
+}
+
+func TestReserveOversizedRequestWaitsForFullBucket(t *testing.T) {
+	l, now := newTestLimiter()
+	limits := Limits{ITPM: 100}
+
+	// 超过桶容量的请求在桶满时放行，避免永远无法通过
+	if d := l.Reserve("k", limits, Usage{Input: 1000}); !d.Allowed {
+		t.Fatalf("expected oversized request allowed on full bucket, got %+v", d)
+	}
+	// 透支 900，按 100/分钟约 9 分钟后还清
+	d := l.Reserve("k", limits, Usage{Input: 1})
+	if d.Allowed {
+		t.Fatalf("expected rejection while in debt")
+	}
+	*now = now.Add(9*time.Minute + time.Second)
+	if d = l.Reserve("k", limits, Usage{Input: 1}); !d.Allowed {
+		t.Fatalf("expected allowed once debt is repaid, got %+v", d)
+	}
+}
+
+func TestAdjustCorrectsEstimate(t *testing.T) {
+	l, _ := newTestLimiter()
+	limits := Limits{TPM: 1000, ITPM: 1000, OTPM: 300}
+	estimate := Usage{Input: 400}
+
+	l.Reserve("k", limits, estimate)
+	l.Adjust("k", limits, estimate, Usage{Input: 100, Output: 250})
+
+	s := l.Peek("k", limits)
+	if s.Tokens.Remaining != 650 || s.InputTokens.Remaining != 900 || s.OutputTokens.Remaining != 50 {
+		t.Fatalf("unexpected status after adjust: %+v", s)
+	}
+
+	// OTPM 余额 50 仍可放行（输出预估为 0 时只要求余额至少 1）
+	if d := l.Reserve("k", limits, Usage{Input: 10}); !d.Allowed {
+		t.Fatalf("expected allowed, got %+v", d)
+	}
+	l.Adjust("k", limits, Usage{Input: 10}, Usage{Input: 10, Output: 100})
+	if d := l.Reserve("k", limits, Usage{Input: 10}); d.Allowed || d.Exceeded != "otpm" {
+		t.Fatalf("expected otpm rejection, got %+v", d)
+	}
+}
+
+func TestRefundRestoresEstimate(t *testing.T) {
+	l, _ := newTestLimiter()
+	limits := Limits{TPM: 1000}
+
+	l.Reserve("k", limits, Usage{Input: 800})
+	l.Refund("k", limits, Usage{Input: 800})
+	if s := l.Peek("k", limits); s.Tokens.Remaining != 1000 || s.Tokens.Reset != 0 {
+		t.Fatalf("expected full bucket after refund, got %+v", s)
+	}
+}
+
+func TestUnlimitedAlwaysAllowed(t *testing.T) {
+	l, _ := newTestLimiter()
+	if d := l.Reserve("k", Limits{}, Usage{Input: 1 << 30}); !d.Allowed {
+		t.Fatalf("expected unlimited to allow")
+	}
+	if ok, _ := l.Check("k", Limits{}, Usage{Input: 1 << 30}); !ok {
+		t.Fatalf("expected unlimited to be available")
+	}
+}
+
+func TestEstimateInputTokens(t *testing.T) {
+	body := []byte(`{"model":"claude-sonnet-4","max_tokens":1024,"messages":[{"role":"user","content":"hello world, how are you?"}]}`)
+	// "user"(1) + 25 个 ASCII 字符(7)
+	if got := EstimateInputTokens(body); got != 8 {
+		t.Fatalf("expected 8, got %d", got)
+	}
+
+	cjk := []byte(`{"messages":[{"role":"user","content":"你好世界"}]}`)
+	if got := EstimateInputTokens(cjk); got != 5 {
+		t.Fatalf("expected 5, got %d", got)
+	}
+
+	image := []byte(`{"messages":[{"role":"user","content":[{"type":"image_url","image_url":{"url":"data:image/png;base64,iVBORw0KGgo="}}]}]}`)
+	if got := EstimateInputTokens(image); got < imageTokenEstimate {
+		t.Fatalf("expected image to count at least %d tokens, got %d", imageTokenEstimate, got)
+	}
+}
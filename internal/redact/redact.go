@@ -0,0 +1,248 @@
+/*
+ * 文件作用：敏感信息脱敏引擎，对请求/响应内容中的个人信息和凭据进行遮蔽
+ * 负责功能：
+ *   - 内置检测器：邮箱、手机号、银行卡号（Luhn 校验）、API Key、身份证号（校验位）
+ *   - 自定义正则规则
+ *   - 纯文本脱敏（日志落库前）与 JSON 字符串值脱敏（请求转发前）
+ * 重要程度：⭐⭐⭐ 一般（合规功能）
+ * 依赖模块：无
+ */
+package redact
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// 内置检测器名称
+const (
+	DetectorAPIKey     = "api_key"
+	DetectorEmail      = "email"
+	DetectorCNID       = "cn_id"
+	DetectorCreditCard = "credit_card"
+	DetectorPhone      = "phone"
+)
+
+// AllDetectors 全部内置检测器（按应用顺序：先匹配特征更明显的，避免被数字类规则截断）
+var AllDetectors = []string{DetectorAPIKey, DetectorEmail, DetectorCNID, DetectorCreditCard, DetectorPhone}
+
+// Rule 自定义脱敏规则
+type Rule struct {
+	Name        string `json:"name"`
+	Pattern     string `json:"pattern"`
+	Replacement string `json:"replacement,omitempty"` // 为空时使用 [REDACTED:<NAME>]
+}
+
+// matcher 编译后的规则
+type matcher struct {
+	name        string
+	re          *regexp.Regexp
+	validate    func(match string) bool // 可选的二次校验（如 Luhn），返回 false 时保留原文
+	replacement string
+}
+
+// builtinDetectors 内置检测器定义
+var builtinDetectors = map[string]*matcher{
+	DetectorAPIKey: {
+		name: DetectorAPIKey,
+		re:   regexp.MustCompile(`\b(?:sk-(?:ant-|proj-)?[A-Za-z0-9_\-]{20,}|AKIA[0-9A-Z]{16}|AIza[0-9A-Za-z_\-]{35}|gh[pousr]_[A-Za-z0-9]{36,}|xox[abprs]-[A-Za-z0-9\-]{10,})`),
+	},
+	DetectorEmail: {
+		name: DetectorEmail,
+		re:   regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9\-]+(?:\.[A-Za-z0-9\-]+)*\.[A-Za-z]{2,}`),
+	},
+	DetectorCNID: {
+		name:     DetectorCNID,
+		re:       regexp.MustCompile(`\b[1-9]\d{5}(?:18|19|20)\d{2}(?:0[1-9]|1[0-2])(?:0[1-9]|[12]\d|3[01])\d{3}[\dXx]\b`),
+		validate: validCNID,
+	},
+	DetectorCreditCard: {
+		name:     DetectorCreditCard,
+		re:       regexp.MustCompile(`\b\d(?:[ \-]?\d){12,18}\b`),
+		validate: validLuhn,
+	},
+	DetectorPhone: {
+		name: DetectorPhone,
+		re:   regexp.MustCompile(`(?:\+?86[ \-]?)?\b1[3-9]\d{9}\b|\+[1-9]\d{0,2}[ \-]?\(?\d{2,4}\)?[ \-]?\d{3,4}[ \-]?\d{3,4}\b`),
+	},
+}
+
+// Redactor 脱敏器（并发安全，构建后只读）
+type Redactor struct {
+	matchers []*matcher
+}
+
+// New 根据检测器名称和自定义规则构建脱敏器
+// 未知的检测器名称会被忽略；自定义规则的正则无效时返回错误
+func New(detectors []string, rules []Rule) (*Redactor, error) {
+	enabled := make(map[string]bool, len(detectors))
+	for _, name := range detectors {
+		enabled[strings.TrimSpace(name)] = true
+	}
+
+	r := &Redactor{}
+	for _, name := range AllDetectors {
+		if enabled[name] {
+			r.matchers = append(r.matchers, builtinDetectors[name])
+		}
+	}
+	for _, rule := range rules {
+		if rule.Pattern == "" {
+			continue
+		}
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: %w", rule.Name, err)
+		}
+		name := rule.Name
+		if name == "" {
+			name = "custom"
+		}
+		r.matchers = append(r.matchers, &matcher{name: name, re: re, replacement: rule.Replacement})
+	}
+	return r, nil
+}
+
+// MarkerPrefix 默认脱敏标记前缀（完整标记为 [REDACTED:<NAME>]）
+const MarkerPrefix = "[REDACTED:"
+
+// HasMarker 文本中是否包含默认脱敏标记
+func HasMarker(s string) bool {
+	return strings.Contains(s, MarkerPrefix)
+}
+
+// Empty 是否没有任何规则
+func (r *Redactor) Empty() bool {
+	return r == nil || len(r.matchers) == 0
+}
+
+// Redact 对文本进行脱敏
+func (r *Redactor) Redact(s string) string {
+	if r.Empty() || s == "" {
+		return s
+	}
+	for _, m := range r.matchers {
+		replacement := m.replacement
+		if replacement == "" {
+			replacement = MarkerPrefix + strings.ToUpper(m.name) + "]"
+		}
+		if m.validate == nil {
+			s = m.re.ReplaceAllLiteralString(s, replacement)
+			continue
+		}
+		s = m.re.ReplaceAllStringFunc(s, func(match string) string {
+			if m.validate(match) {
+				return replacement
+			}
+			return match
+		})
+	}
+	return s
+}
+
+// jsonSkipKeys 请求转发前脱敏时跳过的字段（二进制数据、模型名等非自然语言内容）
+var jsonSkipKeys = map[string]bool{
+	"data":      true,
+	"url":       true,
+	"image_url": true,
+	"signature": true,
+	"model":     true,
+	"id":        true,
+}
+
+// RedactJSON 对 JSON 中的字符串值进行脱敏（跳过图片数据等字段），用于请求转发前的遮蔽
+// 返回是否发生了修改；解析失败时原样返回
+func (r *Redactor) RedactJSON(raw []byte) ([]byte, bool) {
+	if r.Empty() {
+		return raw, false
+	}
+	var body interface{}
+	if err := json.Unmarshal(raw, &body); err != nil {
+		return raw, false
+	}
+	changed := false
+	body = r.redactValue(body, &changed)
+	if !changed {
+		return raw, false
+	}
+	out, err := json.Marshal(body)
+	if err != nil {
+		return raw, false
+	}
+	return out, true
+}
+
+// redactValue 递归脱敏 JSON 值
+func (r *Redactor) redactValue(v interface{}, changed *bool) interface{} {
+	switch val := v.(type) {
+	case string:
+		redacted := r.Redact(val)
+		if redacted != val {
+			*changed = true
+		}
+		return redacted
+	case []interface{}:
+		for i := range val {
+			val[i] = r.redactValue(val[i], changed)
+		}
+		return val
+	case map[string]interface{}:
+		for k, item := range val {
+			if jsonSkipKeys[k] {
+				continue
+			}
+			val[k] = r.redactValue(item, changed)
+		}
+		return val
+	}
+	return v
+}
+
+// validLuhn 银行卡号 Luhn 校验
+func validLuhn(s string) bool {
+	digits := make([]int, 0, 19)
+	for _, c := range s {
+		if c >= '0' && c <= '9' {
+			digits = append(digits, int(c-'0'))
+		}
+	}
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := digits[i]
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// cnIDWeights 身份证号校验位权重
+var cnIDWeights = []int{7, 9, 10, 5, 8, 4, 2, 1, 6, 3, 7, 9, 10, 5, 8, 4, 2}
+
+// validCNID 18 位身份证号校验位校验
+func validCNID(s string) bool {
+	if len(s) != 18 {
+		return false
+	}
+	sum := 0
+	for i := 0; i < 17; i++ {
+		sum += int(s[i]-'0') * cnIDWeights[i]
+	}
+	check := "10X98765432"[sum%11]
+	last := s[17]
+	if last == 'x' {
+		last = 'X'
+	}
+	return last == check
+}
@@ -64,6 +64,11 @@ func (r *AccountRepository) ClearProxyID(id uint) error {
 	return r.db.Exec("UPDATE accounts SET proxy_id = NULL WHERE id = ?", id).Error
 }
 
+// ClearProxyPoolID 清除账户的代理池 ID
+func (r *AccountRepository) ClearProxyPoolID(id uint) error {
+	return r.db.Exec("UPDATE accounts SET proxy_pool_id = NULL WHERE id = ?", id).Error
+}
+
 func (r *AccountRepository) Delete(id uint) error {
 	return r.db.Delete(&model.Account{}, id).Error
 }
@@ -92,6 +97,13 @@ func (r *AccountRepository) List(page, pageSize int, platform, status string) ([
 	return accounts, total, nil
 }
 
+// GetAll 获取全部账户（含禁用账户，用于导入去重和导出）
+func (r *AccountRepository) GetAll() ([]model.Account, error) {
+	var accounts []model.Account
+	err := r.db.Preload("Groups").Order("id ASC").Find(&accounts).Error
+	return accounts, err
+}
+
 func (r *AccountRepository) GetByPlatform(platform string) ([]model.Account, error) {
 	var accounts []model.Account
 	err := r.db.Where("platform = ? AND enabled = ? AND status = ?",
@@ -0,0 +1,114 @@
+/*
+ * 文件作用：账户出口 IP 数据仓库
+ * 负责功能：
+ *   - 账户当前出口 IP 写入（UPSERT）、查询、删除
+ *   - 出口 IP 变更记录写入与分页筛选
+ *   - 代理出口 IP 探测结果保存与加载
+ * 重要程度：⭐⭐⭐ 一般（账户风控）
+ * 依赖模块：model, gorm
+ */
+package repository
+
+import (
+	"time"
+
+	"cli-proxy/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// AccountEgressRepository 账户出口 IP 仓库
+type AccountEgressRepository struct {
+	db *gorm.DB
+}
+
+// NewAccountEgressRepository 创建账户出口 IP 仓库
+func NewAccountEgressRepository() *AccountEgressRepository {
+	return &AccountEgressRepository{db: DB}
+}
+
+// EgressChangeFilter 出口 IP 变更记录筛选条件
+type EgressChangeFilter struct {
+	AccountID   uint
+	CrossRegion bool // 仅跨地区变更
+}
+
+// ListAll 获取所有账户的当前出口 IP
+func (r *AccountEgressRepository) ListAll() ([]model.AccountEgress, error) {
+	var records []model.AccountEgress
+	err := r.db.Find(&records).Error
+	return records, err
+}
+
+// GetByAccountID 获取账户当前出口 IP，不存在时返回 nil
+func (r *AccountEgressRepository) GetByAccountID(accountID uint) (*model.AccountEgress, error) {
+	var records []model.AccountEgress
+	if err := r.db.Where("account_id = ?", accountID).Limit(1).Find(&records).Error; err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	return &records[0], nil
+}
+
+// Upsert 写入账户当前出口 IP
+func (r *AccountEgressRepository) Upsert(record *model.AccountEgress) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "account_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"ip":            record.IP,
+			"country":       record.Country,
+			"proxy_id":      record.ProxyID,
+			"change_count":  record.ChangeCount,
+			"first_seen_at": record.FirstSeenAt,
+			"last_seen_at":  record.LastSeenAt,
+		}),
+	}).Create(record).Error
+}
+
+// DeleteByAccountID 删除账户当前出口 IP（变更记录保留）
+func (r *AccountEgressRepository) DeleteByAccountID(accountID uint) error {
+	return r.db.Where("account_id = ?", accountID).Delete(&model.AccountEgress{}).Error
+}
+
+// CreateChange 写入出口 IP 变更记录
+func (r *AccountEgressRepository) CreateChange(change *model.AccountEgressChange) error {
+	return r.db.Create(change).Error
+}
+
+// ListChanges 分页查询出口 IP 变更记录（最新在前）
+func (r *AccountEgressRepository) ListChanges(f *EgressChangeFilter, page, pageSize int) ([]model.AccountEgressChange, int64, error) {
+	query := r.db.Model(&model.AccountEgressChange{})
+	if f.AccountID != 0 {
+		query = query.Where("account_id = ?", f.AccountID)
+	}
+	if f.CrossRegion {
+		query = query.Where("cross_region = ?", true)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var changes []model.AccountEgressChange
+	err := query.Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&changes).Error
+	return changes, total, err
+}
+
+// ListProxyEgress 获取已探测到出口 IP 的代理
+func (r *AccountEgressRepository) ListProxyEgress() ([]model.Proxy, error) {
+	var proxies []model.Proxy
+	err := r.db.Select("id", "egress_ip", "egress_country").Where("egress_ip <> ''").Find(&proxies).Error
+	return proxies, err
+}
+
+// SaveProxyEgress 保存代理出口 IP 探测结果
+func (r *AccountEgressRepository) SaveProxyEgress(proxyID uint, ip, country string, checkedAt time.Time) error {
+	return r.db.Model(&model.Proxy{}).Where("id = ?", proxyID).Updates(map[string]interface{}{
+		"egress_ip":         ip,
+		"egress_country":    country,
+		"egress_checked_at": checkedAt,
+	}).Error
+}
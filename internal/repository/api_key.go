@@ -89,6 +89,12 @@ func (r *APIKeyRepository) Update(key *model.APIKey) error {
 	return r.db.Save(key).Error
 }
 
+// UpdateStatusIf 仅当当前状态为 from 时更新状态，返回是否更新
+func (r *APIKeyRepository) UpdateStatusIf(id uint, from, to string) (bool, error) {
+	result := r.db.Model(&model.APIKey{}).Where("id = ? AND status = ?", id, from).Update("status", to)
+	return result.RowsAffected > 0, result.Error
+}
+
 // Delete 删除 API Key
 func (r *APIKeyRepository) Delete(id uint) error {
 	return r.db.Delete(&model.APIKey{}, id).Error
@@ -68,12 +68,13 @@ func (r *ClientFilterRepository) ListEnabledClientTypes() ([]model.ClientType, e
 // UpdateClientType 更新客户端类型
 func (r *ClientFilterRepository) UpdateClientType(ct *model.ClientType) error {
 	return r.db.Model(&model.ClientType{}).Where("id = ?", ct.ID).Updates(map[string]interface{}{
-		"client_id":   ct.ClientID,
-		"name":        ct.Name,
-		"description": ct.Description,
-		"icon":        ct.Icon,
-		"enabled":     ct.Enabled,
-		"priority":    ct.Priority,
+		"client_id":        ct.ClientID,
+		"name":             ct.Name,
+		"description":      ct.Description,
+		"icon":             ct.Icon,
+		"enabled":          ct.Enabled,
+		"priority":         ct.Priority,
+		"match_expression": ct.MatchExpression,
 	}).Error
 }
 
@@ -142,6 +143,8 @@ func (r *ClientFilterRepository) UpdateRule(rule *model.ClientFilterRule) error
 		"enabled":     rule.Enabled,
 		"required":    rule.Required,
 		"priority":    rule.Priority,
+		"expression":  rule.Expression,
+		"mode":        rule.Mode,
 	}).Error
 }
 
@@ -155,6 +158,63 @@ func (r *ClientFilterRepository) DeleteRulesByClientTypeID(clientTypeID uint) er
 	return r.db.Where("client_type_id = ?", clientTypeID).Delete(&model.ClientFilterRule{}).Error
 }
 
+// ==================== ClientRuleSet ====================
+
+// ListRuleSets 获取所有规则集
+func (r *ClientFilterRepository) ListRuleSets() ([]model.ClientRuleSet, error) {
+	var sets []model.ClientRuleSet
+	err := r.db.Order("set_key ASC").Find(&sets).Error
+	return sets, err
+}
+
+// GetRuleSetByID 根据 ID 获取规则集
+func (r *ClientFilterRepository) GetRuleSetByID(id uint) (*model.ClientRuleSet, error) {
+	var set model.ClientRuleSet
+	err := r.db.First(&set, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &set, nil
+}
+
+// CreateRuleSet 创建规则集
+func (r *ClientFilterRepository) CreateRuleSet(set *model.ClientRuleSet) error {
+	return r.db.Create(set).Error
+}
+
+// UpdateRuleSet 更新规则集
+func (r *ClientFilterRepository) UpdateRuleSet(set *model.ClientRuleSet) error {
+	return r.db.Model(&model.ClientRuleSet{}).Where("id = ?", set.ID).Updates(map[string]interface{}{
+		"set_key":     set.SetKey,
+		"name":        set.Name,
+		"description": set.Description,
+		"expression":  set.Expression,
+		"enabled":     set.Enabled,
+	}).Error
+}
+
+// DeleteRuleSet 删除规则集
+func (r *ClientFilterRepository) DeleteRuleSet(id uint) error {
+	return r.db.Delete(&model.ClientRuleSet{}, id).Error
+}
+
+// initDefaultRuleSets 补充缺失的默认规则集（按 set_key，不覆盖已有修改）
+func (r *ClientFilterRepository) initDefaultRuleSets() error {
+	for _, set := range model.DefaultClientRuleSets {
+		var count int64
+		if err := r.db.Model(&model.ClientRuleSet{}).Where("set_key = ?", set.SetKey).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+		if err := r.db.Create(&set).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // ==================== ClientFilterConfig ====================
 
 // GetConfig 获取全局配置（只有一条记录）
@@ -197,8 +257,12 @@ func (r *ClientFilterRepository) SaveConfig(config *model.ClientFilterConfig) er
 
 // ==================== 初始化默认数据 ====================
 
-// InitDefaultData 初始化默认的客户端类型和规则
+// InitDefaultData 初始化默认的客户端类型、规则和规则集
 func (r *ClientFilterRepository) InitDefaultData() error {
+	if err := r.initDefaultRuleSets(); err != nil {
+		return err
+	}
+
 	// 检查是否已有数据
 	var count int64
 	r.db.Model(&model.ClientType{}).Count(&count)
@@ -0,0 +1,148 @@
+/*
+ * 文件作用：客户端指纹统计与告警数据仓库
+ * 负责功能：
+ *   - 小时统计批量累加（UPSERT）、按时间范围查询、过期清理
+ *   - API Key 已知指纹批量写入与查询
+ *   - 指纹告警创建、分页筛选、确认
+ * 重要程度：⭐⭐⭐ 一般（安全分析）
+ * 依赖模块：model, gorm
+ */
+package repository
+
+import (
+	"time"
+
+	"cli-proxy/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ClientFingerprintRepository 客户端指纹仓库
+type ClientFingerprintRepository struct {
+	db *gorm.DB
+}
+
+// NewClientFingerprintRepository 创建客户端指纹仓库
+func NewClientFingerprintRepository() *ClientFingerprintRepository {
+	return &ClientFingerprintRepository{db: DB}
+}
+
+// FingerprintAlertFilter 告警筛选条件
+type FingerprintAlertFilter struct {
+	APIKeyID     uint
+	AlertType    string
+	Acknowledged *bool // 为空不过滤
+}
+
+// IncrementStats 批量累加小时统计
+func (r *ClientFingerprintRepository) IncrementStats(stats []model.ClientFingerprintStat) error {
+	now := time.Now()
+	for i := range stats {
+		stats[i].UpdatedAt = now
+		err := r.db.Clauses(clause.OnConflict{
+			Columns: []clause.Column{
+				{Name: "api_key_id"},
+				{Name: "bucket"},
+				{Name: "dimension"},
+				{Name: "value"},
+			},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"request_count": gorm.Expr("request_count + ?", stats[i].RequestCount),
+				"updated_at":    now,
+			}),
+		}).Create(&stats[i]).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListStats 查询 Key 在时间范围内的小时统计（dimension 为空时返回所有维度）
+func (r *ClientFingerprintRepository) ListStats(apiKeyID uint, dimension string, since time.Time) ([]model.ClientFingerprintStat, error) {
+	query := r.db.Where("api_key_id = ? AND bucket >= ?", apiKeyID, since)
+	if dimension != "" {
+		query = query.Where("dimension = ?", dimension)
+	}
+	var stats []model.ClientFingerprintStat
+	err := query.Order("bucket ASC, request_count DESC").Find(&stats).Error
+	return stats, err
+}
+
+// DeleteStatsBefore 删除早于指定时间的小时统计
+func (r *ClientFingerprintRepository) DeleteStatsBefore(before time.Time) (int64, error) {
+	result := r.db.Where("bucket < ?", before).Delete(&model.ClientFingerprintStat{})
+	return result.RowsAffected, result.Error
+}
+
+// UpsertFingerprints 批量写入已知指纹（已存在时累加请求数并更新最近出现时间和 IP）
+func (r *ClientFingerprintRepository) UpsertFingerprints(fps []model.APIKeyFingerprint) error {
+	for i := range fps {
+		err := r.db.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "api_key_id"}, {Name: "fingerprint"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"request_count": gorm.Expr("request_count + ?", fps[i].RequestCount),
+				"last_seen_at":  fps[i].LastSeenAt,
+				"last_ip":       fps[i].LastIP,
+			}),
+		}).Create(&fps[i]).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListFingerprintKeys 获取 Key 的所有已知指纹哈希
+func (r *ClientFingerprintRepository) ListFingerprintKeys(apiKeyID uint) ([]string, error) {
+	var keys []string
+	err := r.db.Model(&model.APIKeyFingerprint{}).Where("api_key_id = ?", apiKeyID).Pluck("fingerprint", &keys).Error
+	return keys, err
+}
+
+// ListFingerprints 获取 Key 的已知指纹（最近出现的在前）
+func (r *ClientFingerprintRepository) ListFingerprints(apiKeyID uint) ([]model.APIKeyFingerprint, error) {
+	var fps []model.APIKeyFingerprint
+	err := r.db.Where("api_key_id = ?", apiKeyID).Order("last_seen_at DESC").Find(&fps).Error
+	return fps, err
+}
+
+// CreateAlert 创建告警
+func (r *ClientFingerprintRepository) CreateAlert(alert *model.FingerprintAlert) error {
+	return r.db.Create(alert).Error
+}
+
+// ListAlerts 分页查询告警（最新在前）
+func (r *ClientFingerprintRepository) ListAlerts(f *FingerprintAlertFilter, page, pageSize int) ([]model.FingerprintAlert, int64, error) {
+	query := r.db.Model(&model.FingerprintAlert{})
+	if f.APIKeyID != 0 {
+		query = query.Where("api_key_id = ?", f.APIKeyID)
+	}
+	if f.AlertType != "" {
+		query = query.Where("alert_type = ?", f.AlertType)
+	}
+	if f.Acknowledged != nil {
+		if *f.Acknowledged {
+			query = query.Where("acknowledged_at IS NOT NULL")
+		} else {
+			query = query.Where("acknowledged_at IS NULL")
+		}
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var alerts []model.FingerprintAlert
+	err := query.Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&alerts).Error
+	return alerts, total, err
+}
+
+// AcknowledgeAlert 确认告警（已确认的不重复更新）
+func (r *ClientFingerprintRepository) AcknowledgeAlert(id uint, operator string) (int64, error) {
+	result := r.db.Model(&model.FingerprintAlert{}).
+		Where("id = ? AND acknowledged_at IS NULL", id).
+		Updates(map[string]interface{}{"acknowledged_at": time.Now(), "acknowledged_by": operator})
+	return result.RowsAffected, result.Error
+}
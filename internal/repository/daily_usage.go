@@ -259,3 +259,101 @@ func (r *DailyUsageRepository) GetDailySummaryAll(startDate, endDate string) ([]
 
 	return summaries, err
 }
+
+// GetPromptCacheUsage 获取 Prompt Caching 使用汇总（按 API Key + 模型分组）
+// apiKeyID 为 0 表示所有 API Key
+func (r *DailyUsageRepository) GetPromptCacheUsage(apiKeyID uint, startDate, endDate string) ([]model.PromptCacheUsage, error) {
+	var usages []model.PromptCacheUsage
+
+	query := r.db.Model(&model.DailyUsage{})
+	if apiKeyID > 0 {
+		query = query.Where("api_key_id = ?", apiKeyID)
+	}
+	if startDate != "" {
+		query = query.Where("date >= ?", startDate)
+	}
+	if endDate != "" {
+		query = query.Where("date <= ?", endDate)
+	}
+
+	err := query.Select("api_key_id, model, SUM(request_count) as request_count, SUM(input_tokens) as input_tokens, " +
+		"SUM(cache_creation_input_tokens) as cache_creation_input_tokens, SUM(cache_read_input_tokens) as cache_read_input_tokens").
+		Group("api_key_id, model").
+		Order("api_key_id").
+		Scan(&usages).Error
+
+	return usages, err
+}
+
+// UsageExportFilter 用量导出筛选条件
+type UsageExportFilter struct {
+	APIKeyID  uint   // 0 表示全部
+	Model     string // 模型名
+	Platform  string // 平台（daily_usage 无平台字段，按模型定价表中的平台匹配）
+	StartDate string // YYYY-MM-DD
+	EndDate   string // YYYY-MM-DD（包含）
+}
+
+// StreamDailyUsage 按筛选条件逐行读取每日使用记录（游标读取，不一次性加载到内存）
+func (r *DailyUsageRepository) StreamDailyUsage(filter UsageExportFilter, fn func(usage *model.DailyUsage) error) error {
+	query := r.db.Model(&model.DailyUsage{})
+	if filter.APIKeyID > 0 {
+		query = query.Where("api_key_id = ?", filter.APIKeyID)
+	}
+	if filter.Model != "" {
+		query = query.Where("model = ?", filter.Model)
+	}
+	if filter.Platform != "" {
+		query = query.Where("model IN (?)", r.db.Model(&model.AIModel{}).Select("name").Where("platform = ?", filter.Platform))
+	}
+	if filter.StartDate != "" {
+		query = query.Where("date >= ?", filter.StartDate)
+	}
+	if filter.EndDate != "" {
+		query = query.Where("date <= ?", filter.EndDate)
+	}
+
+	rows, err := query.Order("date ASC, api_key_id ASC, model ASC").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var usage model.DailyUsage
+		if err := r.db.ScanRows(rows, &usage); err != nil {
+			return err
+		}
+		if err := fn(&usage); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// GetAPIKeyModelBreakdown 获取 API Key 在日期范围内按模型汇总的完整用量（含缓存 Token 和分项费用）
+func (r *DailyUsageRepository) GetAPIKeyModelBreakdown(apiKeyID uint, startDate, endDate string) ([]model.DailyUsage, error) {
+	var usages []model.DailyUsage
+	err := r.db.Model(&model.DailyUsage{}).
+		Select("api_key_id, model, SUM(request_count) as request_count, SUM(input_tokens) as input_tokens, "+
+			"SUM(output_tokens) as output_tokens, SUM(cache_creation_input_tokens) as cache_creation_input_tokens, "+
+			"SUM(cache_read_input_tokens) as cache_read_input_tokens, SUM(total_tokens) as total_tokens, "+
+			"SUM(input_cost) as input_cost, SUM(output_cost) as output_cost, SUM(cache_create_cost) as cache_create_cost, "+
+			"SUM(cache_read_cost) as cache_read_cost, SUM(total_cost) as total_cost").
+		Where("api_key_id = ? AND date >= ? AND date <= ?", apiKeyID, startDate, endDate).
+		Group("api_key_id, model").
+		Order("total_cost DESC").
+		Scan(&usages).Error
+	return usages, err
+}
+
+// GetActiveAPIKeyIDs 获取日期范围内有使用记录的 API Key
+func (r *DailyUsageRepository) GetActiveAPIKeyIDs(startDate, endDate string) ([]uint, error) {
+	var ids []uint
+	err := r.db.Model(&model.DailyUsage{}).
+		Where("date >= ? AND date <= ? AND request_count > 0", startDate, endDate).
+		Distinct().
+		Order("api_key_id").
+		Pluck("api_key_id", &ids).Error
+	return ids, err
+}
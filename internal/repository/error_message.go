@@ -113,13 +113,20 @@ func (r *ErrorMessageRepository) DisableAll() (int64, error) {
 	return result.RowsAffected, result.Error
 }
 
-// InitDefaultData 初始化默认数据
+// InitDefaultData 初始化默认数据（已存在的记录只补充缺失的多语言消息）
 func (r *ErrorMessageRepository) InitDefaultData() error {
 	for _, msg := range model.DefaultErrorMessages {
+		msg.Translations = model.DefaultTranslationsJSON(msg.ErrorType)
+
 		var existing model.ErrorMessage
 		result := r.db.Where("error_type = ?", msg.ErrorType).First(&existing)
 		if result.Error == nil {
-			// 已存在，跳过
+			// 已存在，不覆盖管理员的修改
+			if existing.Translations == "" && msg.Translations != "" {
+				if err := r.db.Model(&existing).UpdateColumn("translations", msg.Translations).Error; err != nil {
+					return err
+				}
+			}
 			continue
 		}
 		if err := r.db.Create(&msg).Error; err != nil {
@@ -0,0 +1,108 @@
+/*
+ * 文件作用：IP 封禁数据仓库
+ * 负责功能：
+ *   - 封禁记录创建、解封（保留记录用于审计）
+ *   - 生效中封禁查询（用于内存缓存）
+ *   - 封禁记录分页筛选
+ *   - 命中计数批量累加
+ * 重要程度：⭐⭐⭐ 一般（安全防护）
+ * 依赖模块：model, gorm
+ */
+package repository
+
+import (
+	"time"
+
+	"cli-proxy/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// IPBanRepository IP 封禁仓库
+type IPBanRepository struct {
+	db *gorm.DB
+}
+
+// NewIPBanRepository 创建 IP 封禁仓库
+func NewIPBanRepository() *IPBanRepository {
+	return &IPBanRepository{db: DB}
+}
+
+// IPBanFilter 封禁记录筛选条件
+type IPBanFilter struct {
+	Status string // active/expired/revoked，为空不过滤
+	Source string // manual/auto
+	CIDR   string // 模糊匹配
+}
+
+// Create 创建封禁记录
+func (r *IPBanRepository) Create(ban *model.IPBan) error {
+	return r.db.Create(ban).Error
+}
+
+// GetByID 根据 ID 获取封禁记录
+func (r *IPBanRepository) GetByID(id uint) (*model.IPBan, error) {
+	var ban model.IPBan
+	if err := r.db.First(&ban, id).Error; err != nil {
+		return nil, err
+	}
+	return &ban, nil
+}
+
+// Revoke 解封（记录解封时间和操作人）
+func (r *IPBanRepository) Revoke(id uint, revokedBy string) error {
+	now := time.Now()
+	return r.db.Model(&model.IPBan{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Updates(map[string]interface{}{"revoked_at": now, "revoked_by": revokedBy}).Error
+}
+
+// ListActive 获取所有生效中的封禁
+func (r *IPBanRepository) ListActive() ([]model.IPBan, error) {
+	var bans []model.IPBan
+	err := r.db.Where("revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", time.Now()).
+		Order("id ASC").Find(&bans).Error
+	return bans, err
+}
+
+// List 分页查询封禁记录（最新在前）
+func (r *IPBanRepository) List(f *IPBanFilter, page, pageSize int) ([]model.IPBan, int64, error) {
+	query := r.db.Model(&model.IPBan{})
+	now := time.Now()
+	switch f.Status {
+	case model.IPBanStatusActive:
+		query = query.Where("revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", now)
+	case model.IPBanStatusExpired:
+		query = query.Where("revoked_at IS NULL AND expires_at <= ?", now)
+	case model.IPBanStatusRevoked:
+		query = query.Where("revoked_at IS NOT NULL")
+	}
+	if f.Source != "" {
+		query = query.Where("source = ?", f.Source)
+	}
+	if f.CIDR != "" {
+		query = query.Where("cidr LIKE ?", "%"+f.CIDR+"%")
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var bans []model.IPBan
+	err := query.Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&bans).Error
+	return bans, total, err
+}
+
+// AddHits 批量累加命中次数
+func (r *IPBanRepository) AddHits(hits map[uint]int64, lastHitAt time.Time) error {
+	for id, n := range hits {
+		err := r.db.Model(&model.IPBan{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"hit_count":   gorm.Expr("hit_count + ?", n),
+			"last_hit_at": lastHitAt,
+		}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -16,15 +16,18 @@ import (
 )
 
 func AutoMigrate() error {
-	return DB.AutoMigrate(
+	err := DB.AutoMigrate(
 		&model.Proxy{},
-		&model.Gateway{}, // xyrt 网关配置
+		&model.ProxyPool{}, // 代理池
+		&model.Gateway{},   // xyrt 网关配置
 		&model.Account{},
 		&model.AccountGroup{},
 		&model.RequestLog{},
+		&model.RequestLogSearch{}, // 请求日志全文检索
 		&model.AIModel{},
 		&model.APIKey{},
 		&model.DailyUsage{},
+		&model.UsageReport{}, // 周期用量报表
 		&model.SystemConfig{},
 		&model.UsageRecord{},
 		&model.OperationLog{},
@@ -32,6 +35,7 @@ func AutoMigrate() error {
 		&model.ClientType{},
 		&model.ClientFilterRule{},
 		&model.ClientFilterConfig{},
+		&model.ClientRuleSet{},
 		// 错误消息配置
 		&model.ErrorMessage{},
 		// 错误规则配置
@@ -40,7 +44,20 @@ func AutoMigrate() error {
 		&model.ModelMapping{},
 		// 管理员配置
 		&model.AdminConfig{},
+		// IP 封禁
+		&model.IPBan{},
+		// 客户端指纹统计与告警
+		&model.ClientFingerprintStat{},
+		&model.APIKeyFingerprint{},
+		&model.FingerprintAlert{},
+		// 账户出口 IP
+		&model.AccountEgress{},
+		&model.AccountEgressChange{},
 	)
+	if err != nil {
+		return err
+	}
+	return EnsureRequestLogSearchIndex()
 }
 
 // InitDefaultConfigs 初始化默认系统配置
@@ -0,0 +1,87 @@
+/*
+ * 文件作用：代理池数据访问层
+ * 负责功能：
+ *   - 代理池创建、查询、更新、删除
+ *   - 池内代理成员设置
+ *   - 启用代理池及成员加载（供代理分配使用）
+ * 重要程度：⭐⭐⭐ 一般（代理池管理）
+ * 依赖模块：model, gorm
+ */
+package repository
+
+import (
+	"cli-proxy/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// ProxyPoolRepository 代理池数据访问层
+type ProxyPoolRepository struct {
+	db *gorm.DB
+}
+
+// NewProxyPoolRepository 创建代理池仓库实例
+func NewProxyPoolRepository() *ProxyPoolRepository {
+	return &ProxyPoolRepository{db: DB}
+}
+
+// Create 创建代理池
+func (r *ProxyPoolRepository) Create(pool *model.ProxyPool) error {
+	return r.db.Omit("Proxies").Create(pool).Error
+}
+
+// GetByID 根据 ID 获取代理池（含池内代理）
+func (r *ProxyPoolRepository) GetByID(id uint) (*model.ProxyPool, error) {
+	var pool model.ProxyPool
+	err := r.db.Preload("Proxies", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id ASC")
+	}).First(&pool, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &pool, nil
+}
+
+// Update 更新代理池基本信息（不修改成员）
+func (r *ProxyPoolRepository) Update(pool *model.ProxyPool) error {
+	return r.db.Omit("Proxies").Save(pool).Error
+}
+
+// Delete 删除代理池（软删除），同时将池内代理移出
+func (r *ProxyPoolRepository) Delete(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.Proxy{}).Where("pool_id = ?", id).Update("pool_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&model.ProxyPool{}, id).Error
+	})
+}
+
+// List 获取所有代理池（含池内代理）
+func (r *ProxyPoolRepository) List() ([]model.ProxyPool, error) {
+	var pools []model.ProxyPool
+	err := r.db.Preload("Proxies", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id ASC")
+	}).Order("id ASC").Find(&pools).Error
+	return pools, err
+}
+
+// SetMembers 设置池内代理（替换原有成员，代理同一时间只属于一个池）
+func (r *ProxyPoolRepository) SetMembers(poolID uint, proxyIDs []uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.Proxy{}).Where("pool_id = ?", poolID).Update("pool_id", nil).Error; err != nil {
+			return err
+		}
+		if len(proxyIDs) == 0 {
+			return nil
+		}
+		return tx.Model(&model.Proxy{}).Where("id IN ?", proxyIDs).Update("pool_id", poolID).Error
+	})
+}
+
+// CountAccounts 统计绑定该代理池的账户数
+func (r *ProxyPoolRepository) CountAccounts(poolID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&model.Account{}).Where("proxy_pool_id = ?", poolID).Count(&count).Error
+	return count, err
+}
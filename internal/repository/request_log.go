@@ -30,40 +30,13 @@ func (r *RequestLogRepository) Create(log *model.RequestLog) error {
 	return r.db.Create(log).Error
 }
 
-func (r *RequestLogRepository) List(page, pageSize int, filters map[string]interface{}) ([]model.RequestLog, int64, error) {
-	var logs []model.RequestLog
-	var total int64
-
-	query := r.db.Model(&model.RequestLog{})
-
-	if accountID, ok := filters["account_id"].(uint); ok && accountID > 0 {
-		query = query.Where("account_id = ?", accountID)
-	}
-	if platform, ok := filters["platform"].(string); ok && platform != "" {
-		query = query.Where("platform = ?", platform)
-	}
-	if modelName, ok := filters["model"].(string); ok && modelName != "" {
-		query = query.Where("model LIKE ?", "%"+modelName+"%")
-	}
-	if success, ok := filters["success"].(bool); ok {
-		query = query.Where("success = ?", success)
-	}
-	if startTime, ok := filters["start_time"].(time.Time); ok {
-		query = query.Where("created_at >= ?", startTime)
-	}
-	if endTime, ok := filters["end_time"].(time.Time); ok {
-		query = query.Where("created_at <= ?", endTime)
-	}
-
-	query.Count(&total)
-
-	offset := (page - 1) * pageSize
-	err := query.Preload("Account").Offset(offset).Limit(pageSize).Order("created_at DESC").Find(&logs).Error
-	if err != nil {
-		return nil, 0, err
+// GetByID 获取日志详情（含请求/响应体）
+func (r *RequestLogRepository) GetByID(id uint) (*model.RequestLog, error) {
+	var log model.RequestLog
+	if err := r.db.Preload("Account").First(&log, id).Error; err != nil {
+		return nil, err
 	}
-
-	return logs, total, nil
+	return &log, nil
 }
 
 func (r *RequestLogRepository) GetSummary(startTime, endTime time.Time) (*model.RequestLogSummary, error) {
@@ -190,3 +163,42 @@ func (r *RequestLogRepository) GetAccountsTotalCost(accountIDs []uint) (map[uint
 
 	return costMap, nil
 }
+
+// AccountDailyModelUsage 账户按天、按模型聚合的请求用量（盈利分析用）
+type AccountDailyModelUsage struct {
+	AccountID                uint    `json:"account_id"`
+	Date                     string  `json:"date"`
+	Model                    string  `json:"model"`
+	RequestCount             int64   `json:"request_count"`
+	SuccessCount             int64   `json:"success_count"`
+	InputTokens              int64   `json:"input_tokens"`
+	OutputTokens             int64   `json:"output_tokens"`
+	CacheCreationInputTokens int64   `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int64   `json:"cache_read_input_tokens"`
+	BilledCost               float64 `json:"billed_cost"` // 已计算倍率后向用户收取的费用
+}
+
+// GetAccountDailyModelUsage 获取时间范围内各账户按天、按模型聚合的用量
+// accountID 为 0 表示所有账户
+func (r *RequestLogRepository) GetAccountDailyModelUsage(startTime, endTime time.Time, accountID uint) ([]AccountDailyModelUsage, error) {
+	var results []AccountDailyModelUsage
+	query := r.db.Model(&model.RequestLog{}).
+		Select(`
+			account_id,
+			DATE_FORMAT(created_at, '%Y-%m-%d') as date,
+			model,
+			COUNT(*) as request_count,
+			SUM(CASE WHEN success = true THEN 1 ELSE 0 END) as success_count,
+			COALESCE(SUM(input_tokens), 0) as input_tokens,
+			COALESCE(SUM(output_tokens), 0) as output_tokens,
+			COALESCE(SUM(cache_creation_input_tokens), 0) as cache_creation_input_tokens,
+			COALESCE(SUM(cache_read_input_tokens), 0) as cache_read_input_tokens,
+			COALESCE(SUM(total_cost), 0) as billed_cost
+		`).
+		Where("created_at >= ? AND created_at < ?", startTime, endTime)
+	if accountID > 0 {
+		query = query.Where("account_id = ?", accountID)
+	}
+	err := query.Group("account_id, date, model").Order("date ASC").Scan(&results).Error
+	return results, err
+}
@@ -0,0 +1,180 @@
+/*
+ * 文件作用：请求日志保留策略相关的数据库操作
+ * 负责功能：
+ *   - 分批读取/删除过期日志
+ *   - 清空过期请求/响应体
+ *   - MySQL 按月 RANGE 分区的转换、扩展与删除
+ * 重要程度：⭐⭐⭐ 一般（日志存储维护）
+ * 依赖模块：model, gorm
+ */
+package repository
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"cli-proxy/internal/model"
+)
+
+// RequestLogPartition 请求日志表分区信息
+type RequestLogPartition struct {
+	Name       string    `json:"name"`
+	UpperBound time.Time `json:"upper_bound"` // 分区上界（不含），MAXVALUE 分区为零值
+	Rows       int64     `json:"rows"`        // 估算行数（information_schema 统计值）
+}
+
+// ListExpiredAfter 按 ID 顺序分页读取 before 之前的日志（含软删除记录）
+func (r *RequestLogRepository) ListExpiredAfter(before time.Time, afterID uint, limit int) ([]model.RequestLog, error) {
+	var logs []model.RequestLog
+	err := r.db.Unscoped().
+		Where("created_at < ? AND id > ?", before, afterID).
+		Order("id ASC").
+		Limit(limit).
+		Find(&logs).Error
+	return logs, err
+}
+
+// HardDeleteByIDs 物理删除日志
+func (r *RequestLogRepository) HardDeleteByIDs(ids []uint) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	result := r.db.Unscoped().Where("id IN ?", ids).Delete(&model.RequestLog{})
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if err := r.db.Where("log_id IN ?", ids).Delete(&model.RequestLogSearch{}).Error; err != nil {
+		return result.RowsAffected, err
+	}
+	return result.RowsAffected, nil
+}
+
+// PurgeSearchText 删除 before 之前的可检索文本（随请求/响应体一起过期），每次最多处理 limit 条
+func (r *RequestLogRepository) PurgeSearchText(before time.Time, limit int) (int64, error) {
+	result := r.db.Exec("DELETE FROM request_log_searches WHERE created_at < ? LIMIT ?", before, limit)
+	return result.RowsAffected, result.Error
+}
+
+// PurgeBodies 清空 before 之前日志的请求/响应体和请求头，每次最多处理 limit 条
+func (r *RequestLogRepository) PurgeBodies(before time.Time, limit int) (int64, error) {
+	result := r.db.Exec(`UPDATE request_logs
+		SET request_body = '', response_body = '', request_headers = '', response_headers = ''
+		WHERE created_at < ?
+		  AND (request_body <> '' OR response_body <> '' OR request_headers <> '' OR response_headers <> '')
+		LIMIT ?`, before, limit)
+	return result.RowsAffected, result.Error
+}
+
+// ========== 分区 ==========
+
+// RequestLogPartitionName 月分区名：p202610
+func RequestLogPartitionName(month time.Time) string {
+	return "p" + month.Format("200601")
+}
+
+// parsePartitionUpperBound 根据分区名计算上界（下月 1 日）
+func parsePartitionUpperBound(name string) (time.Time, bool) {
+	month, err := time.ParseInLocation("200601", strings.TrimPrefix(name, "p"), time.Local)
+	if err != nil || !strings.HasPrefix(name, "p") {
+		return time.Time{}, false
+	}
+	return month.AddDate(0, 1, 0), true
+}
+
+// partitionDefinition 生成月分区定义
+func partitionDefinition(month time.Time) string {
+	upper := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.Local).AddDate(0, 1, 0)
+	return fmt.Sprintf("PARTITION %s VALUES LESS THAN (TO_DAYS('%s'))", RequestLogPartitionName(month), upper.Format("2006-01-02"))
+}
+
+// IsRequestLogPartitioned 请求日志表是否已分区
+func (r *RequestLogRepository) IsRequestLogPartitioned() (bool, error) {
+	var count int64
+	err := r.db.Raw(`SELECT COUNT(*) FROM information_schema.PARTITIONS
+		WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'request_logs' AND PARTITION_NAME IS NOT NULL`).
+		Scan(&count).Error
+	return count > 0, err
+}
+
+// ListRequestLogPartitions 列出请求日志表分区
+func (r *RequestLogRepository) ListRequestLogPartitions() ([]RequestLogPartition, error) {
+	var rows []struct {
+		PartitionName string
+		TableRows     int64
+	}
+	err := r.db.Raw(`SELECT PARTITION_NAME AS partition_name, TABLE_ROWS AS table_rows FROM information_schema.PARTITIONS
+		WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'request_logs' AND PARTITION_NAME IS NOT NULL
+		ORDER BY PARTITION_ORDINAL_POSITION`).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	partitions := make([]RequestLogPartition, 0, len(rows))
+	for _, row := range rows {
+		p := RequestLogPartition{Name: row.PartitionName, Rows: row.TableRows}
+		p.UpperBound, _ = parsePartitionUpperBound(row.PartitionName)
+		partitions = append(partitions, p)
+	}
+	return partitions, nil
+}
+
+// ConvertRequestLogToPartitioned 将请求日志表转换为按月分区表
+// 分区表要求分区键包含在主键中且不支持外键，因此先删除外键、把主键改为 (id, created_at)
+// months 为需要创建的月份（升序），另建 pmax 分区兜底
+func (r *RequestLogRepository) ConvertRequestLogToPartitioned(months []time.Time) error {
+	var foreignKeys []string
+	err := r.db.Raw(`SELECT CONSTRAINT_NAME FROM information_schema.TABLE_CONSTRAINTS
+		WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'request_logs' AND CONSTRAINT_TYPE = 'FOREIGN KEY'`).
+		Scan(&foreignKeys).Error
+	if err != nil {
+		return err
+	}
+	for _, fk := range foreignKeys {
+		if err := r.db.Exec("ALTER TABLE request_logs DROP FOREIGN KEY `" + fk + "`").Error; err != nil {
+			return fmt.Errorf("删除外键 %s 失败: %w", fk, err)
+		}
+	}
+
+	if err := r.db.Exec("ALTER TABLE request_logs DROP PRIMARY KEY, ADD PRIMARY KEY (id, created_at)").Error; err != nil {
+		return fmt.Errorf("修改主键失败: %w", err)
+	}
+
+	defs := make([]string, 0, len(months)+1)
+	for _, month := range months {
+		defs = append(defs, partitionDefinition(month))
+	}
+	defs = append(defs, "PARTITION pmax VALUES LESS THAN MAXVALUE")
+	sql := "ALTER TABLE request_logs PARTITION BY RANGE (TO_DAYS(created_at)) (" + strings.Join(defs, ", ") + ")"
+	if err := r.db.Exec(sql).Error; err != nil {
+		return fmt.Errorf("创建分区失败: %w", err)
+	}
+	return nil
+}
+
+// AddRequestLogPartitions 从 pmax 中拆分出新的月分区
+func (r *RequestLogRepository) AddRequestLogPartitions(months []time.Time) error {
+	if len(months) == 0 {
+		return nil
+	}
+	defs := make([]string, 0, len(months)+1)
+	for _, month := range months {
+		defs = append(defs, partitionDefinition(month))
+	}
+	defs = append(defs, "PARTITION pmax VALUES LESS THAN MAXVALUE")
+	return r.db.Exec("ALTER TABLE request_logs REORGANIZE PARTITION pmax INTO (" + strings.Join(defs, ", ") + ")").Error
+}
+
+// DropRequestLogPartition 删除分区（分区内数据一并删除）
+func (r *RequestLogRepository) DropRequestLogPartition(name string) error {
+	if _, ok := parsePartitionUpperBound(name); !ok {
+		return fmt.Errorf("无效的分区名: %s", name)
+	}
+	return r.db.Exec("ALTER TABLE request_logs DROP PARTITION " + name).Error
+}
+
+// GetOldestRequestLogTime 最早一条日志的时间（无日志时返回零值）
+func (r *RequestLogRepository) GetOldestRequestLogTime() (time.Time, error) {
+	var log model.RequestLog
+	err := r.db.Unscoped().Select("id, created_at").Order("created_at ASC").Limit(1).Find(&log).Error
+	return log.CreatedAt, err
+}
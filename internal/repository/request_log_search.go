@@ -0,0 +1,193 @@
+/*
+ * 文件作用：请求日志检索相关的数据库操作
+ * 负责功能：
+ *   - 结构化筛选条件（会话、客户端、状态码、耗时/Token/费用区间）
+ *   - 基于 FULLTEXT 索引的全文检索
+ *   - 游标分页（大表避免 OFFSET 和 COUNT）
+ *   - 同会话请求查询
+ * 重要程度：⭐⭐⭐ 一般（日志检索）
+ * 依赖模块：model, gorm
+ */
+package repository
+
+import (
+	"strings"
+	"time"
+
+	"cli-proxy/internal/model"
+
+	"gorm.io/gorm"
+)
+
+const requestLogSearchIndex = "idx_request_log_searches_content"
+
+// RequestLogFilter 请求日志筛选条件（零值表示不筛选）
+type RequestLogFilter struct {
+	AccountID      uint
+	APIKeyID       uint
+	Platform       string
+	Model          string // 模糊匹配
+	Success        *bool
+	SessionID      string
+	ClientType     string
+	RequestIP      string
+	CacheStatus    string
+	StatusCode     int
+	UpstreamStatus int
+	MinDuration    int64 // 毫秒
+	MaxDuration    int64
+	MinTokens      int
+	MaxTokens      int
+	MinCost        float64
+	MaxCost        float64
+	StartTime      time.Time
+	EndTime        time.Time
+	Query          string // 全文检索：请求/响应体中的对话文本与错误信息
+	BeforeID       uint   // 游标分页：只返回 ID 小于该值的日志
+	ReplayOfID     uint   // 只返回该日志的重放记录
+}
+
+// EnsureRequestLogSearchIndex 创建可检索文本的 FULLTEXT 索引
+// 优先使用 ngram 分词（支持中文），数据库不支持时退回默认分词
+func EnsureRequestLogSearchIndex() error {
+	if DB.Migrator().HasIndex(&model.RequestLogSearch{}, requestLogSearchIndex) {
+		return nil
+	}
+	err := DB.Exec("CREATE FULLTEXT INDEX " + requestLogSearchIndex + " ON request_log_searches (content) WITH PARSER ngram").Error
+	if err != nil {
+		err = DB.Exec("CREATE FULLTEXT INDEX " + requestLogSearchIndex + " ON request_log_searches (content)").Error
+	}
+	return err
+}
+
+// SaveSearchText 保存日志的可检索文本
+func (r *RequestLogRepository) SaveSearchText(logID uint, createdAt time.Time, content string) error {
+	if content == "" {
+		return nil
+	}
+	return r.db.Create(&model.RequestLogSearch{LogID: logID, CreatedAt: createdAt, Content: content}).Error
+}
+
+// buildBooleanQuery 将用户输入转换为 BOOLEAN MODE 查询：每个词必须出现，按短语匹配，去除运算符
+func buildBooleanQuery(q string) string {
+	replacer := strings.NewReplacer(`"`, " ", "+", " ", "-", " ", "*", " ", "@", " ", "<", " ", ">", " ",
+		"(", " ", ")", " ", "~", " ")
+	terms := strings.Fields(replacer.Replace(q))
+	for i, term := range terms {
+		terms[i] = `+"` + term + `"`
+	}
+	return strings.Join(terms, " ")
+}
+
+// applyFilter 应用筛选条件
+func (r *RequestLogRepository) applyFilter(query *gorm.DB, f *RequestLogFilter) *gorm.DB {
+	if f.AccountID > 0 {
+		query = query.Where("request_logs.account_id = ?", f.AccountID)
+	}
+	if f.APIKeyID > 0 {
+		query = query.Where("request_logs.api_key_id = ?", f.APIKeyID)
+	}
+	if f.Platform != "" {
+		query = query.Where("request_logs.platform = ?", f.Platform)
+	}
+	if f.Model != "" {
+		query = query.Where("request_logs.model LIKE ?", "%"+f.Model+"%")
+	}
+	if f.Success != nil {
+		query = query.Where("request_logs.success = ?", *f.Success)
+	}
+	if f.SessionID != "" {
+		query = query.Where("request_logs.session_id = ?", f.SessionID)
+	}
+	if f.ClientType != "" {
+		query = query.Where("request_logs.client_type = ?", f.ClientType)
+	}
+	if f.RequestIP != "" {
+		query = query.Where("request_logs.request_ip = ?", f.RequestIP)
+	}
+	if f.CacheStatus != "" {
+		query = query.Where("request_logs.cache_status = ?", f.CacheStatus)
+	}
+	if f.StatusCode > 0 {
+		query = query.Where("request_logs.status_code = ?", f.StatusCode)
+	}
+	if f.UpstreamStatus > 0 {
+		query = query.Where("request_logs.upstream_status_code = ?", f.UpstreamStatus)
+	}
+	if f.MinDuration > 0 {
+		query = query.Where("request_logs.duration >= ?", f.MinDuration)
+	}
+	if f.MaxDuration > 0 {
+		query = query.Where("request_logs.duration <= ?", f.MaxDuration)
+	}
+	if f.MinTokens > 0 {
+		query = query.Where("request_logs.total_tokens >= ?", f.MinTokens)
+	}
+	if f.MaxTokens > 0 {
+		query = query.Where("request_logs.total_tokens <= ?", f.MaxTokens)
+	}
+	if f.MinCost > 0 {
+		query = query.Where("request_logs.total_cost >= ?", f.MinCost)
+	}
+	if f.MaxCost > 0 {
+		query = query.Where("request_logs.total_cost <= ?", f.MaxCost)
+	}
+	if !f.StartTime.IsZero() {
+		query = query.Where("request_logs.created_at >= ?", f.StartTime)
+	}
+	if !f.EndTime.IsZero() {
+		query = query.Where("request_logs.created_at <= ?", f.EndTime)
+	}
+	if f.BeforeID > 0 {
+		query = query.Where("request_logs.id < ?", f.BeforeID)
+	}
+	if f.ReplayOfID > 0 {
+		query = query.Where("request_logs.replay_of_id = ?", f.ReplayOfID)
+	}
+	if q := buildBooleanQuery(f.Query); q != "" {
+		// 先走全文索引取候选 ID，再与其他条件组合
+		search := r.db.Model(&model.RequestLogSearch{}).Select("log_id").
+			Where("MATCH(content) AGAINST(? IN BOOLEAN MODE)", q)
+		if !f.StartTime.IsZero() {
+			search = search.Where("created_at >= ?", f.StartTime)
+		}
+		if !f.EndTime.IsZero() {
+			search = search.Where("created_at <= ?", f.EndTime)
+		}
+		query = query.Where("request_logs.id IN (?)", search)
+	}
+	return query
+}
+
+// List 按条件检索日志（列表不含请求/响应体，详情通过 GetByID 获取）
+// BeforeID > 0 时为游标分页，不统计总数（total 返回 -1），适合千万级数据翻页
+func (r *RequestLogRepository) List(f *RequestLogFilter, page, pageSize int) ([]model.RequestLog, int64, error) {
+	var logs []model.RequestLog
+	total := int64(-1)
+
+	query := r.applyFilter(r.db.Model(&model.RequestLog{}), f)
+	if f.BeforeID == 0 {
+		if err := query.Count(&total).Error; err != nil {
+			return nil, 0, err
+		}
+		query = query.Offset((page - 1) * pageSize)
+	}
+
+	err := query.Preload("Account").
+		Omit("request_body", "response_body", "request_headers", "response_headers").
+		Order("request_logs.id DESC").Limit(pageSize).Find(&logs).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return logs, total, nil
+}
+
+// ListSession 获取同一会话的请求（按时间正序，不含请求/响应体）
+func (r *RequestLogRepository) ListSession(sessionID string, limit int) ([]model.RequestLog, error) {
+	var logs []model.RequestLog
+	err := r.db.Where("session_id = ?", sessionID).
+		Preload("Account").
+		Omit("request_body", "response_body", "request_headers", "response_headers").
+		Order("id ASC").Limit(limit).Find(&logs).Error
+	return logs, err
+}
@@ -190,3 +190,42 @@ func (r *UsageRecordRepository) GetAllRecords(offset, limit int, startDate, endD
 	err := query.Order("request_time DESC").Offset(offset).Limit(limit).Find(&records).Error
 	return records, total, err
 }
+
+// StreamRecords 按筛选条件逐行读取使用记录（游标读取，不一次性加载到内存）
+func (r *UsageRecordRepository) StreamRecords(filter UsageExportFilter, fn func(record *model.UsageRecord) error) error {
+	query := r.db.Model(&model.UsageRecord{})
+	if filter.APIKeyID > 0 {
+		query = query.Where("api_key_id = ?", filter.APIKeyID)
+	}
+	if filter.Model != "" {
+		query = query.Where("model = ?", filter.Model)
+	}
+	if filter.Platform != "" {
+		query = query.Where("platform = ?", filter.Platform)
+	}
+	if filter.StartDate != "" {
+		start, _ := time.ParseInLocation("2006-01-02", filter.StartDate, time.Local)
+		query = query.Where("request_time >= ?", start)
+	}
+	if filter.EndDate != "" {
+		end, _ := time.ParseInLocation("2006-01-02", filter.EndDate, time.Local)
+		query = query.Where("request_time < ?", end.Add(24*time.Hour))
+	}
+
+	rows, err := query.Order("request_time ASC, id ASC").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var record model.UsageRecord
+		if err := r.db.ScanRows(rows, &record); err != nil {
+			return err
+		}
+		if err := fn(&record); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,117 @@
+/*
+ * 文件作用：周期用量报表数据仓库
+ * 负责功能：
+ *   - 报表保存（同一 API Key 同一周期重复生成时覆盖）
+ *   - 报表列表/详情查询
+ *   - 推送状态更新
+ * 重要程度：⭐⭐⭐ 一般（账单报表）
+ * 依赖模块：model, gorm
+ */
+package repository
+
+import (
+	"time"
+
+	"cli-proxy/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type UsageReportRepository struct {
+	db *gorm.DB
+}
+
+func NewUsageReportRepository() *UsageReportRepository {
+	return &UsageReportRepository{db: DB}
+}
+
+// Save 保存报表（同一 API Key、周期、日期范围已存在时覆盖内容并重置推送状态）
+func (r *UsageReportRepository) Save(report *model.UsageReport) error {
+	err := r.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "api_key_id"}, {Name: "period"}, {Name: "period_start"}, {Name: "period_end"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"api_key_name", "request_count", "total_tokens", "total_cost", "base_cost", "effective_rate",
+			"cache_saved_cost", "content", "delivery_status", "delivery_error", "delivered_at", "updated_at",
+		}),
+	}).Create(report).Error
+	if err != nil {
+		return err
+	}
+	// MySQL 冲突更新时不会回填 ID，重新查询
+	if report.ID == 0 {
+		return r.db.Where("api_key_id = ? AND period = ? AND period_start = ? AND period_end = ?",
+			report.APIKeyID, report.Period, report.PeriodStart, report.PeriodEnd).First(report).Error
+	}
+	return nil
+}
+
+// Exists 报表是否已生成
+func (r *UsageReportRepository) Exists(apiKeyID uint, period, periodStart, periodEnd string) (bool, error) {
+	var count int64
+	err := r.db.Model(&model.UsageReport{}).
+		Where("api_key_id = ? AND period = ? AND period_start = ? AND period_end = ?", apiKeyID, period, periodStart, periodEnd).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// GetByID 获取报表（含完整内容）
+func (r *UsageReportRepository) GetByID(id uint) (*model.UsageReport, error) {
+	var report model.UsageReport
+	if err := r.db.First(&report, id).Error; err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// List 分页查询报表（不含完整内容）
+func (r *UsageReportRepository) List(page, pageSize int, apiKeyID uint, period string) ([]model.UsageReport, int64, error) {
+	var reports []model.UsageReport
+	var total int64
+
+	query := r.db.Model(&model.UsageReport{})
+	if apiKeyID > 0 {
+		query = query.Where("api_key_id = ?", apiKeyID)
+	}
+	if period != "" {
+		query = query.Where("period = ?", period)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Omit("content").
+		Order("period_start DESC, total_cost DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&reports).Error
+	return reports, total, err
+}
+
+// ListPendingDelivery 获取待推送/推送失败的报表
+func (r *UsageReportRepository) ListPendingDelivery(limit int) ([]model.UsageReport, error) {
+	var reports []model.UsageReport
+	err := r.db.Where("delivery_status IN ?", []string{model.ReportDeliveryPending, model.ReportDeliveryFailed}).
+		Order("id ASC").
+		Limit(limit).
+		Find(&reports).Error
+	return reports, err
+}
+
+// UpdateDelivery 更新推送状态
+func (r *UsageReportRepository) UpdateDelivery(id uint, status, errMsg string) error {
+	updates := map[string]interface{}{
+		"delivery_status": status,
+		"delivery_error":  errMsg,
+	}
+	if status == model.ReportDeliveryDelivered {
+		now := time.Now()
+		updates["delivered_at"] = &now
+	}
+	return r.db.Model(&model.UsageReport{}).Where("id = ?", id).Updates(updates).Error
+}
+
+// Delete 删除报表
+func (r *UsageReportRepository) Delete(id uint) error {
+	return r.db.Delete(&model.UsageReport{}, id).Error
+}
@@ -18,6 +18,7 @@ import (
 
 	"cli-proxy/internal/model"
 	"cli-proxy/internal/proxy/scheduler"
+	"cli-proxy/internal/proxypool"
 	"cli-proxy/internal/repository"
 	"cli-proxy/pkg/logger"
 )
@@ -71,6 +72,9 @@ type CreateAccountRequest struct {
 	Priority            int    `json:"priority"`
 	Weight              int    `json:"weight"`
 	MaxConcurrency      int    `json:"max_concurrency"`
+	TPMLimit            int    `json:"tpm_limit"`  // 每分钟总 Token 限制（0=不限）
+	ITPMLimit           int    `json:"itpm_limit"` // 每分钟输入 Token 限制（0=不限）
+	OTPMLimit           int    `json:"otpm_limit"` // 每分钟输出 Token 限制（0=不限）
 	APIKey              string `json:"api_key"`
 	APISecret           string `json:"api_secret"`
 	AccessToken         string `json:"access_token"`
@@ -90,6 +94,10 @@ type CreateAccountRequest struct {
 	ModelMapping        string `json:"model_mapping"`
 	AllowedModels       string `json:"allowed_models"`
 	ProxyID             *uint  `json:"proxy_id"`
+	ProxyPoolID         *uint  `json:"proxy_pool_id"` // 代理池 ID（优先于 proxy_id）
+	// 成本模型
+	MonthlyCost float64 `json:"monthly_cost"` // 固定月成本（美元）
+	CostRate    float64 `json:"cost_rate"`    // 按量成本系数（相对官方定价）
 	// xyrt 授权相关
 	GatewayID        *uint  `json:"gateway_id"`         // 网关 ID
 	GatewayURL       string `json:"gateway_url"`        // xyrt 网关地址
@@ -103,6 +111,9 @@ type UpdateAccountRequest struct {
 	Priority            *int   `json:"priority"`
 	Weight              *int   `json:"weight"`
 	MaxConcurrency      *int   `json:"max_concurrency"`
+	TPMLimit            *int   `json:"tpm_limit"`  // 每分钟总 Token 限制（0=不限）
+	ITPMLimit           *int   `json:"itpm_limit"` // 每分钟输入 Token 限制（0=不限）
+	OTPMLimit           *int   `json:"otpm_limit"` // 每分钟输出 Token 限制（0=不限）
 	Status              string `json:"status"`
 	APIKey              string `json:"api_key"`
 	APISecret           string `json:"api_secret"`
@@ -125,8 +136,14 @@ type UpdateAccountRequest struct {
 	AllowedModels       string `json:"allowed_models"`
 	ProxyID             *uint  `json:"proxy_id"`
 	ClearProxy          bool   `json:"clear_proxy"`          // 是否清除代理（设置为 true 时清空 proxy_id）
+	ProxyPoolID         *uint  `json:"proxy_pool_id"`        // 代理池 ID
+	ClearProxyPool      bool   `json:"clear_proxy_pool"`     // 是否解绑代理池（设置为 true 时清空 proxy_pool_id）
+	ForceRegionChange   bool   `json:"force_region_change"`  // 确认改绑到出口地区不同的代理
 	ClearModelMapping   bool   `json:"clear_model_mapping"`  // 是否清除模型映射
 	ClearAllowedModels  bool   `json:"clear_allowed_models"` // 是否清除允许的模型列表
+	// 成本模型
+	MonthlyCost *float64 `json:"monthly_cost"` // 固定月成本（美元）
+	CostRate    *float64 `json:"cost_rate"`    // 按量成本系数（相对官方定价）
 	// xyrt 授权相关
 	GatewayID        *uint  `json:"gateway_id"`         // 网关 ID
 	ClearGateway     bool   `json:"clear_gateway"`      // 是否清除网关
@@ -140,10 +157,31 @@ type UpdateAccountRequest struct {
 func (s *AccountService) Create(req *CreateAccountRequest) (*model.Account, error) {
 	getAccountLog().Info("[account] 创建账户请求 | Name: %s | Type: %s | Platform: %s", req.Name, req.Type, model.GetPlatformByType(req.Type))
 
+	account, err := buildAccount(req)
+	if err != nil {
+		getAccountLog().Info("[account] 创建账户失败 | Name: %s | 原因: 无效的账户类型", req.Name)
+		return nil, err
+	}
+
+	if err := s.repo.Create(account); err != nil {
+		getAccountLog().Error("[account] 创建账户失败 | Name: %s | 原因: %v", req.Name, err)
+		return nil, err
+	}
+
+	// 刷新调度器缓存
+	scheduler.GetScheduler().Refresh()
+
+	s.refreshNewXyrtAccount(account)
+
+	getAccountLog().Info("[account] 创建账户成功 | AccountID: %d | Name: %s | Type: %s", account.ID, account.Name, account.Type)
+	return account, nil
+}
+
+// buildAccount 由创建请求构造账户（平台、默认值、网关地址、认证类型），单个创建与批量导入共用
+func buildAccount(req *CreateAccountRequest) (*model.Account, error) {
 	// 验证账户类型
 	platform := model.GetPlatformByType(req.Type)
 	if platform == "" {
-		getAccountLog().Info("[account] 创建账户失败 | Name: %s | 原因: 无效的账户类型", req.Name)
 		return nil, errors.New("invalid account type")
 	}
 
@@ -156,6 +194,9 @@ func (s *AccountService) Create(req *CreateAccountRequest) (*model.Account, erro
 		Priority:            req.Priority,
 		Weight:              req.Weight,
 		MaxConcurrency:      req.MaxConcurrency,
+		TPMLimit:            normalizeTokenLimit(req.TPMLimit),
+		ITPMLimit:           normalizeTokenLimit(req.ITPMLimit),
+		OTPMLimit:           normalizeTokenLimit(req.OTPMLimit),
 		APIKey:              req.APIKey,
 		APISecret:           req.APISecret,
 		AccessToken:         req.AccessToken,
@@ -175,6 +216,9 @@ func (s *AccountService) Create(req *CreateAccountRequest) (*model.Account, erro
 		ModelMapping:        req.ModelMapping,
 		AllowedModels:       req.AllowedModels,
 		ProxyID:             req.ProxyID,
+		ProxyPoolID:         req.ProxyPoolID,
+		MonthlyCost:         req.MonthlyCost,
+		CostRate:            req.CostRate,
 		// xyrt 授权相关
 		GatewayID:        req.GatewayID,
 		GatewayURL:       req.GatewayURL,
@@ -211,15 +255,11 @@ func (s *AccountService) Create(req *CreateAccountRequest) (*model.Account, erro
 		account.MaxConcurrency = 5 // 默认并发限制
 	}
 
-	if err := s.repo.Create(account); err != nil {
-		getAccountLog().Error("[account] 创建账户失败 | Name: %s | 原因: %v", req.Name, err)
-		return nil, err
-	}
-
-	// 刷新调度器缓存
-	scheduler.GetScheduler().Refresh()
+	return account, nil
+}
 
-	// 如果是 xyrt 类型，立即触发一次 token 刷新
+// refreshNewXyrtAccount 新建的 xyrt 账户立即触发一次 token 刷新
+func (s *AccountService) refreshNewXyrtAccount(account *model.Account) {
 	// 注意：需要重新从数据库获取账户，因为 BeforeSave 钩子会加密 XyrtRefreshToken
 	// 而 AfterFind 钩子会解密，这样才能获取正确的明文 token
 	if account.Type == model.AccountTypeOpenAIResponses && account.AuthType == "xyrt" {
@@ -243,9 +283,6 @@ func (s *AccountService) Create(req *CreateAccountRequest) (*model.Account, erro
 			}
 		}(account.ID)
 	}
-
-	getAccountLog().Info("[account] 创建账户成功 | AccountID: %d | Name: %s | Type: %s", account.ID, account.Name, account.Type)
-	return account, nil
 }
 
 func (s *AccountService) GetByID(id uint) (*model.Account, error) {
@@ -273,6 +310,21 @@ func (s *AccountService) Update(id uint, req *UpdateAccountRequest) (*model.Acco
 	if req.MaxConcurrency != nil {
 		account.MaxConcurrency = *req.MaxConcurrency
 	}
+	if req.TPMLimit != nil {
+		account.TPMLimit = normalizeTokenLimit(*req.TPMLimit)
+	}
+	if req.ITPMLimit != nil {
+		account.ITPMLimit = normalizeTokenLimit(*req.ITPMLimit)
+	}
+	if req.OTPMLimit != nil {
+		account.OTPMLimit = normalizeTokenLimit(*req.OTPMLimit)
+	}
+	if req.MonthlyCost != nil {
+		account.MonthlyCost = *req.MonthlyCost
+	}
+	if req.CostRate != nil {
+		account.CostRate = *req.CostRate
+	}
 	if req.Status != "" {
 		account.Status = req.Status
 	}
@@ -367,6 +419,7 @@ func (s *AccountService) Update(id uint, req *UpdateAccountRequest) (*model.Acco
 		)
 	}
 	// 处理代理：ClearProxy 优先级高于 ProxyID
+	oldProxyID, oldProxyPoolID := account.ProxyID, account.ProxyPoolID
 	clearProxyAfterUpdate := false
 	if req.ClearProxy {
 		clearProxyAfterUpdate = true
@@ -374,6 +427,24 @@ func (s *AccountService) Update(id uint, req *UpdateAccountRequest) (*model.Acco
 	} else if req.ProxyID != nil {
 		account.ProxyID = req.ProxyID
 	}
+	// 处理代理池：ClearProxyPool 优先级高于 ProxyPoolID
+	clearProxyPoolAfterUpdate := false
+	if req.ClearProxyPool {
+		clearProxyPoolAfterUpdate = true
+		account.ProxyPoolID = nil
+	} else if req.ProxyPoolID != nil {
+		account.ProxyPoolID = req.ProxyPoolID
+	}
+	// 出口代理变更时校验出口地区，地区不同需显式确认
+	regionChanged := false
+	if !sameUintPtr(oldProxyID, account.ProxyID) || !sameUintPtr(oldProxyPoolID, account.ProxyPoolID) {
+		if err := GetAccountEgressService().CheckRegion(account); err != nil {
+			if !errors.Is(err, ErrEgressRegionMismatch) || !req.ForceRegionChange {
+				return nil, err
+			}
+			regionChanged = true
+		}
+	}
 
 	if err := s.repo.Update(account); err != nil {
 		return nil, err
@@ -386,6 +457,22 @@ func (s *AccountService) Update(id uint, req *UpdateAccountRequest) (*model.Acco
 		}
 		account.ProxyID = nil
 	}
+	if clearProxyPoolAfterUpdate {
+		if err := s.repo.ClearProxyPoolID(id); err != nil {
+			return nil, err
+		}
+	}
+	if clearProxyPoolAfterUpdate || req.ProxyPoolID != nil {
+		// 代理池绑定变更后重新分配出口代理
+		proxypool.GetManager().Release(id)
+	}
+	if regionChanged {
+		// 已确认切换地区：清除原出口记录，按新出口重新记录
+		if err := GetAccountEgressService().Reset(id); err != nil {
+			getAccountLog().Warn("[account] 清除账户出口记录失败 | AccountID: %d | 原因: %v", id, err)
+		}
+		getAccountLog().Info("[account] 账户已确认切换出口地区 | AccountID: %d", id)
+	}
 
 	// 刷新调度器缓存
 	scheduler.GetScheduler().Refresh()
@@ -416,12 +503,21 @@ func (s *AccountService) Update(id uint, req *UpdateAccountRequest) (*model.Acco
 	return account, nil
 }
 
+// sameUintPtr 两个可选 ID 是否相同
+func sameUintPtr(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
 func (s *AccountService) Delete(id uint) error {
 	getAccountLog().Info("[account] 删除账户请求 | AccountID: %d", id)
 	if err := s.repo.Delete(id); err != nil {
 		getAccountLog().Error("[account] 删除账户失败 | AccountID: %d | 原因: %v", id, err)
 		return err
 	}
+	proxypool.GetManager().Release(id)
 
 	// 刷新调度器缓存
 	scheduler.GetScheduler().Refresh()
@@ -0,0 +1,452 @@
+/*
+ * 文件作用：账户成本与盈利分析服务
+ * 负责功能：
+ *   - 按账户成本模型（固定月成本 + 按量成本系数）计算实际成本
+ *   - 对比向用户收取的费用（已计算倍率）与成本，得出利润和利润率
+ *   - 账户/分组维度的利用率与每次成功请求成本
+ *   - 按天/周/月的成本与收入趋势
+ * 重要程度：⭐⭐⭐ 一般（运营分析）
+ * 依赖模块：repository, model, pricing
+ */
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"cli-proxy/internal/model"
+	"cli-proxy/internal/repository"
+)
+
+// 趋势粒度
+const (
+	AnalyticsGranularityDay   = "day"
+	AnalyticsGranularityWeek  = "week"
+	AnalyticsGranularityMonth = "month"
+)
+
+// ProfitMetrics 成本与收入指标
+type ProfitMetrics struct {
+	RequestCount   int64   `json:"request_count"`
+	SuccessCount   int64   `json:"success_count"`
+	SuccessRate    float64 `json:"success_rate"`     // 成功请求占比
+	TotalTokens    int64   `json:"total_tokens"`     // 输入 + 输出 + 缓存 Token
+	ListCost       float64 `json:"list_cost"`        // 按模型官方定价计算的费用
+	BilledRevenue  float64 `json:"billed_revenue"`   // 向用户收取的费用（已计算倍率）
+	FixedCost      float64 `json:"fixed_cost"`       // 固定成本（月成本按天分摊）
+	VariableCost   float64 `json:"variable_cost"`    // 按量成本（官方定价 × 成本系数）
+	TotalCost      float64 `json:"total_cost"`       // 固定成本 + 按量成本
+	Profit         float64 `json:"profit"`           // 收入 - 成本
+	Margin         float64 `json:"margin"`           // 利润 / 收入
+	CostPerSuccess float64 `json:"cost_per_success"` // 每次成功请求的成本
+}
+
+// addUsage 累加一条按模型聚合的用量
+func (m *ProfitMetrics) addUsage(u *repository.AccountDailyModelUsage, aiModel *model.AIModel, costRate float64) {
+	m.RequestCount += u.RequestCount
+	m.SuccessCount += u.SuccessCount
+	m.TotalTokens += u.InputTokens + u.OutputTokens + u.CacheCreationInputTokens + u.CacheReadInputTokens
+	m.BilledRevenue += u.BilledCost
+	if aiModel == nil {
+		return
+	}
+	listCost := (float64(u.InputTokens)*aiModel.InputPrice +
+		float64(u.OutputTokens)*aiModel.OutputPrice +
+		float64(u.CacheCreationInputTokens)*aiModel.CacheCreatePrice +
+		float64(u.CacheReadInputTokens)*aiModel.CacheReadPrice) / 1000000
+	m.ListCost += listCost
+	m.VariableCost += listCost * costRate
+}
+
+// merge 合并另一组指标（比率字段需重新 finalize）
+func (m *ProfitMetrics) merge(o *ProfitMetrics) {
+	m.RequestCount += o.RequestCount
+	m.SuccessCount += o.SuccessCount
+	m.TotalTokens += o.TotalTokens
+	m.ListCost += o.ListCost
+	m.BilledRevenue += o.BilledRevenue
+	m.FixedCost += o.FixedCost
+	m.VariableCost += o.VariableCost
+}
+
+// finalize 计算汇总与比率字段
+func (m *ProfitMetrics) finalize() {
+	m.TotalCost = m.FixedCost + m.VariableCost
+	m.Profit = m.BilledRevenue - m.TotalCost
+	m.SuccessRate, m.Margin, m.CostPerSuccess = 0, 0, 0
+	if m.RequestCount > 0 {
+		m.SuccessRate = float64(m.SuccessCount) / float64(m.RequestCount)
+	}
+	if m.BilledRevenue > 0 {
+		m.Margin = m.Profit / m.BilledRevenue
+	}
+	if m.SuccessCount > 0 {
+		m.CostPerSuccess = m.TotalCost / float64(m.SuccessCount)
+	}
+}
+
+// AccountProfitability 单个账户的成本与盈利
+type AccountProfitability struct {
+	AccountID           uint     `json:"account_id"`
+	AccountName         string   `json:"account_name"`
+	Type                string   `json:"type"`
+	Platform            string   `json:"platform"`
+	Status              string   `json:"status"`
+	MonthlyCost         float64  `json:"monthly_cost"`
+	CostRate            float64  `json:"cost_rate"`
+	ActiveDays          int      `json:"active_days"`                     // 范围内有请求的天数
+	Utilization         float64  `json:"utilization"`                     // 活跃天数 / 范围天数
+	TrafficShare        float64  `json:"traffic_share"`                   // 请求量占全部账户的比例
+	SevenDayUtilization *float64 `json:"seven_day_utilization,omitempty"` // 订阅账号 7 天窗口用量（当前快照）
+	ProfitMetrics
+}
+
+// GroupProfitability 账户分组的成本与盈利
+type GroupProfitability struct {
+	GroupID        uint    `json:"group_id"`
+	GroupName      string  `json:"group_name"`
+	Platform       string  `json:"platform,omitempty"`
+	AccountCount   int     `json:"account_count"`
+	ActiveAccounts int     `json:"active_accounts"` // 范围内有请求的账户数
+	Utilization    float64 `json:"utilization"`     // 成员账户平均利用率
+	TrafficShare   float64 `json:"traffic_share"`
+	ProfitMetrics
+}
+
+// ProfitTrendPoint 趋势数据点
+type ProfitTrendPoint struct {
+	Period string `json:"period"` // day: YYYY-MM-DD；week: 周一日期；month: YYYY-MM
+	ProfitMetrics
+}
+
+// AccountAnalyticsService 账户成本与盈利分析服务
+type AccountAnalyticsService struct {
+	logRepo     *repository.RequestLogRepository
+	accountRepo *repository.AccountRepository
+	groupRepo   *repository.AccountGroupRepository
+	pricing     *PricingService
+}
+
+func NewAccountAnalyticsService() *AccountAnalyticsService {
+	return &AccountAnalyticsService{
+		logRepo:     repository.NewRequestLogRepository(),
+		accountRepo: repository.NewAccountRepository(),
+		groupRepo:   repository.NewAccountGroupRepository(),
+		pricing:     NewPricingService(),
+	}
+}
+
+// analyticsRange 日期范围（end 为包含的最后一天）
+type analyticsRange struct {
+	start time.Time
+	end   time.Time
+}
+
+// parseAnalyticsRange 解析 YYYY-MM-DD 日期范围，默认最近 30 天
+func parseAnalyticsRange(startDate, endDate string) (analyticsRange, error) {
+	today := time.Now()
+	today = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.Local)
+	r := analyticsRange{start: today.AddDate(0, 0, -29), end: today}
+	if startDate != "" {
+		t, err := time.ParseInLocation("2006-01-02", startDate, time.Local)
+		if err != nil {
+			return r, fmt.Errorf("start_date 格式应为 YYYY-MM-DD")
+		}
+		r.start = t
+	}
+	if endDate != "" {
+		t, err := time.ParseInLocation("2006-01-02", endDate, time.Local)
+		if err != nil {
+			return r, fmt.Errorf("end_date 格式应为 YYYY-MM-DD")
+		}
+		r.end = t
+	}
+	if r.end.Before(r.start) {
+		return r, fmt.Errorf("end_date 不能早于 start_date")
+	}
+	if r.end.Sub(r.start) > 366*24*time.Hour {
+		return r, fmt.Errorf("日期范围不能超过一年")
+	}
+	return r, nil
+}
+
+// days 范围内的天数
+func (r analyticsRange) days() int {
+	return int(r.end.Sub(r.start).Hours()/24+0.5) + 1
+}
+
+// eachDay 遍历范围内的每一天
+func (r analyticsRange) eachDay(fn func(day time.Time)) {
+	for d := r.start; !d.After(r.end); d = d.AddDate(0, 0, 1) {
+		fn(d)
+	}
+}
+
+// dailyFixedCost 某天分摊的固定成本（账户创建之前不计）
+func dailyFixedCost(monthlyCost float64, day time.Time, createdAt time.Time) float64 {
+	if monthlyCost <= 0 {
+		return 0
+	}
+	created := time.Date(createdAt.Year(), createdAt.Month(), createdAt.Day(), 0, 0, 0, 0, day.Location())
+	if !createdAt.IsZero() && day.Before(created) {
+		return 0
+	}
+	daysInMonth := time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, day.Location()).Day()
+	return monthlyCost / float64(daysInMonth)
+}
+
+// periodKey 计算日期所属的趋势周期
+func periodKey(day time.Time, granularity string) string {
+	switch granularity {
+	case AnalyticsGranularityWeek:
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7)).Format("2006-01-02")
+	case AnalyticsGranularityMonth:
+		return day.Format("2006-01")
+	}
+	return day.Format("2006-01-02")
+}
+
+// priceLookup 带缓存的模型定价查询
+func (s *AccountAnalyticsService) priceLookup(ctx context.Context) func(string) *model.AIModel {
+	prices := make(map[string]*model.AIModel)
+	return func(name string) *model.AIModel {
+		aiModel, cached := prices[name]
+		if !cached {
+			aiModel, _ = s.pricing.GetModelPricing(ctx, name)
+			prices[name] = aiModel
+		}
+		return aiModel
+	}
+}
+
+// accountProfitability 计算所有账户（含已删除但有请求记录的账户）的成本与盈利
+func (s *AccountAnalyticsService) accountProfitability(ctx context.Context, r analyticsRange) ([]*AccountProfitability, map[uint][]model.AccountGroup, error) {
+	accounts, err := s.accountRepo.GetAll()
+	if err != nil {
+		return nil, nil, err
+	}
+	usages, err := s.logRepo.GetAccountDailyModelUsage(r.start, r.end.AddDate(0, 0, 1), 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rows := make(map[uint]*AccountProfitability, len(accounts))
+	groups := make(map[uint][]model.AccountGroup, len(accounts))
+	createdAt := make(map[uint]time.Time, len(accounts))
+	for i := range accounts {
+		acc := &accounts[i]
+		rows[acc.ID] = &AccountProfitability{
+			AccountID:           acc.ID,
+			AccountName:         acc.Name,
+			Type:                acc.Type,
+			Platform:            acc.Platform,
+			Status:              acc.Status,
+			MonthlyCost:         acc.MonthlyCost,
+			CostRate:            acc.CostRate,
+			SevenDayUtilization: acc.SevenDayUtilization,
+		}
+		groups[acc.ID] = acc.Groups
+		createdAt[acc.ID] = acc.CreatedAt
+	}
+
+	priceOf := s.priceLookup(ctx)
+	activeDays := make(map[uint]map[string]bool)
+	var totalRequests int64
+	for i := range usages {
+		u := &usages[i]
+		row, ok := rows[u.AccountID]
+		if !ok {
+			row = &AccountProfitability{AccountID: u.AccountID, AccountName: fmt.Sprintf("#%d（已删除）", u.AccountID)}
+			rows[u.AccountID] = row
+		}
+		row.addUsage(u, priceOf(u.Model), row.CostRate)
+		if activeDays[u.AccountID] == nil {
+			activeDays[u.AccountID] = make(map[string]bool)
+		}
+		activeDays[u.AccountID][u.Date] = true
+		totalRequests += u.RequestCount
+	}
+
+	days := r.days()
+	result := make([]*AccountProfitability, 0, len(rows))
+	for id, row := range rows {
+		if row.MonthlyCost > 0 {
+			r.eachDay(func(day time.Time) {
+				row.FixedCost += dailyFixedCost(row.MonthlyCost, day, createdAt[id])
+			})
+		}
+		row.ActiveDays = len(activeDays[id])
+		row.Utilization = float64(row.ActiveDays) / float64(days)
+		if totalRequests > 0 {
+			row.TrafficShare = float64(row.RequestCount) / float64(totalRequests)
+		}
+		row.finalize()
+		result = append(result, row)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Profit != result[j].Profit {
+			return result[i].Profit > result[j].Profit
+		}
+		return result[i].AccountID < result[j].AccountID
+	})
+	return result, groups, nil
+}
+
+// GetAccountProfitability 各账户的成本、收入与利润（platform 为空表示全部平台）
+func (s *AccountAnalyticsService) GetAccountProfitability(ctx context.Context, startDate, endDate, platform string) ([]*AccountProfitability, *ProfitMetrics, error) {
+	r, err := parseAnalyticsRange(startDate, endDate)
+	if err != nil {
+		return nil, nil, err
+	}
+	rows, _, err := s.accountProfitability(ctx, r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	filtered := make([]*AccountProfitability, 0, len(rows))
+	totals := &ProfitMetrics{}
+	for _, row := range rows {
+		if platform != "" && row.Platform != platform {
+			continue
+		}
+		filtered = append(filtered, row)
+		totals.merge(&row.ProfitMetrics)
+	}
+	totals.finalize()
+	return filtered, totals, nil
+}
+
+// GetGroupProfitability 各账户分组的成本、收入与利润
+// 账户可属于多个分组，此时会同时计入每个分组
+func (s *AccountAnalyticsService) GetGroupProfitability(ctx context.Context, startDate, endDate string) ([]*GroupProfitability, error) {
+	r, err := parseAnalyticsRange(startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+	rows, accountGroups, err := s.accountProfitability(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	allGroups, err := s.groupRepo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*GroupProfitability, 0, len(allGroups))
+	byID := make(map[uint]*GroupProfitability, len(allGroups))
+	for _, g := range allGroups {
+		gp := &GroupProfitability{GroupID: g.ID, GroupName: g.Name, Platform: g.Platform}
+		byID[g.ID] = gp
+		result = append(result, gp)
+	}
+
+	for _, row := range rows {
+		for _, g := range accountGroups[row.AccountID] {
+			gp, ok := byID[g.ID]
+			if !ok {
+				continue
+			}
+			gp.AccountCount++
+			if row.RequestCount > 0 {
+				gp.ActiveAccounts++
+			}
+			gp.Utilization += row.Utilization
+			gp.TrafficShare += row.TrafficShare
+			gp.merge(&row.ProfitMetrics)
+		}
+	}
+	for _, gp := range result {
+		if gp.AccountCount > 0 {
+			gp.Utilization /= float64(gp.AccountCount)
+		}
+		gp.finalize()
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Profit > result[j].Profit })
+	return result, nil
+}
+
+// GetProfitTrend 成本与收入趋势
+// accountID 和 groupID 都为 0 时统计全部账户；指定 groupID 时统计分组内所有账户
+func (s *AccountAnalyticsService) GetProfitTrend(ctx context.Context, startDate, endDate, granularity string, accountID, groupID uint) ([]*ProfitTrendPoint, error) {
+	r, err := parseAnalyticsRange(startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+	switch granularity {
+	case "":
+		granularity = AnalyticsGranularityDay
+	case AnalyticsGranularityDay, AnalyticsGranularityWeek, AnalyticsGranularityMonth:
+	default:
+		return nil, fmt.Errorf("granularity 只支持 day、week 或 month")
+	}
+
+	// 参与统计的账户
+	var accounts []model.Account
+	switch {
+	case accountID > 0:
+		acc, err := s.accountRepo.GetByID(accountID)
+		if err != nil {
+			return nil, fmt.Errorf("账户不存在")
+		}
+		accounts = []model.Account{*acc}
+	case groupID > 0:
+		if accounts, err = s.groupRepo.GetAccountsByGroup(groupID); err != nil {
+			return nil, err
+		}
+	default:
+		if accounts, err = s.accountRepo.GetAll(); err != nil {
+			return nil, err
+		}
+	}
+	costRates := make(map[uint]float64, len(accounts))
+	for _, acc := range accounts {
+		costRates[acc.ID] = acc.CostRate
+	}
+
+	points := make(map[string]*ProfitTrendPoint)
+	order := make([]string, 0)
+	point := func(key string) *ProfitTrendPoint {
+		p, ok := points[key]
+		if !ok {
+			p = &ProfitTrendPoint{Period: key}
+			points[key] = p
+			order = append(order, key)
+		}
+		return p
+	}
+
+	// 固定成本按天分摊到所属周期
+	r.eachDay(func(day time.Time) {
+		p := point(periodKey(day, granularity))
+		for _, acc := range accounts {
+			p.FixedCost += dailyFixedCost(acc.MonthlyCost, day, acc.CreatedAt)
+		}
+	})
+
+	usages, err := s.logRepo.GetAccountDailyModelUsage(r.start, r.end.AddDate(0, 0, 1), accountID)
+	if err != nil {
+		return nil, err
+	}
+	priceOf := s.priceLookup(ctx)
+	for i := range usages {
+		u := &usages[i]
+		costRate, ok := costRates[u.AccountID]
+		if !ok && (accountID > 0 || groupID > 0) {
+			continue
+		}
+		day, err := time.ParseInLocation("2006-01-02", u.Date, time.Local)
+		if err != nil {
+			continue
+		}
+		point(periodKey(day, granularity)).addUsage(u, priceOf(u.Model), costRate)
+	}
+
+	result := make([]*ProfitTrendPoint, 0, len(order))
+	for _, key := range order {
+		points[key].finalize()
+		result = append(result, points[key])
+	}
+	return result, nil
+}
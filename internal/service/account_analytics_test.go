@@ -0,0 +1,63 @@
+package service
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"cli-proxy/internal/model"
+	"cli-proxy/internal/repository"
+)
+
+func TestProfitMetrics(t *testing.T) {
+	price := &model.AIModel{InputPrice: 3, OutputPrice: 15}
+	var m ProfitMetrics
+	m.addUsage(&repository.AccountDailyModelUsage{
+		RequestCount: 10, SuccessCount: 8, InputTokens: 1000000, OutputTokens: 100000, BilledCost: 6,
+	}, price, 0.5)
+	m.addUsage(&repository.AccountDailyModelUsage{RequestCount: 2, SuccessCount: 2, InputTokens: 10, BilledCost: 0.5}, nil, 0.5)
+	m.FixedCost = 1
+	m.finalize()
+
+	// 官方定价 = 3 + 1.5 = 4.5，按量成本 = 4.5 × 0.5
+	if math.Abs(m.ListCost-4.5) > 1e-9 || math.Abs(m.VariableCost-2.25) > 1e-9 {
+		t.Fatalf("list/variable cost = %v/%v", m.ListCost, m.VariableCost)
+	}
+	if math.Abs(m.TotalCost-3.25) > 1e-9 || math.Abs(m.Profit-3.25) > 1e-9 || math.Abs(m.Margin-0.5) > 1e-9 {
+		t.Fatalf("unexpected totals: %+v", m)
+	}
+	if math.Abs(m.CostPerSuccess-0.325) > 1e-9 || math.Abs(m.SuccessRate-10.0/12) > 1e-9 {
+		t.Fatalf("unexpected ratios: %+v", m)
+	}
+}
+
+func TestDailyFixedCost(t *testing.T) {
+	created := time.Date(2026, 2, 10, 15, 0, 0, 0, time.Local)
+	if c := dailyFixedCost(28, time.Date(2026, 2, 9, 0, 0, 0, 0, time.Local), created); c != 0 {
+		t.Fatalf("cost before creation should be 0, got %v", c)
+	}
+	if c := dailyFixedCost(28, time.Date(2026, 2, 10, 0, 0, 0, 0, time.Local), created); c != 1 {
+		t.Fatalf("february daily cost should be 1, got %v", c)
+	}
+	if c := dailyFixedCost(31, time.Date(2026, 3, 1, 0, 0, 0, 0, time.Local), time.Time{}); c != 1 {
+		t.Fatalf("march daily cost should be 1, got %v", c)
+	}
+}
+
+func TestPeriodKeyAndRange(t *testing.T) {
+	day := time.Date(2026, 10, 18, 0, 0, 0, 0, time.Local) // 周日
+	if k := periodKey(day, AnalyticsGranularityWeek); k != "2026-10-12" {
+		t.Fatalf("week key = %s", k)
+	}
+	if k := periodKey(day, AnalyticsGranularityMonth); k != "2026-10" {
+		t.Fatalf("month key = %s", k)
+	}
+
+	r, err := parseAnalyticsRange("2026-10-01", "2026-10-31")
+	if err != nil || r.days() != 31 {
+		t.Fatalf("range days = %d, %v", r.days(), err)
+	}
+	if _, err := parseAnalyticsRange("2026-10-31", "2026-10-01"); err == nil {
+		t.Fatal("expected error for reversed range")
+	}
+}
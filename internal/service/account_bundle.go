@@ -0,0 +1,645 @@
+/*
+ * 文件作用：账户批量导入/导出服务
+ * 负责功能：
+ *   - 批量导入（JSON / JSONL / CSV / 加密凭证包），逐行校验
+ *   - 试运行（dry-run）、重复凭证检测、自动分组
+ *   - 导入后可选立即健康检测
+ *   - 口令加密的凭证包导出（用于跨部署迁移账户）
+ * 重要程度：⭐⭐⭐ 一般（运维功能）
+ * 依赖模块：repository, model, utils
+ */
+package service
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cli-proxy/internal/model"
+	"cli-proxy/internal/proxy/scheduler"
+	"cli-proxy/pkg/utils"
+)
+
+// 导入格式
+const (
+	AccountImportFormatJSON   = "json"
+	AccountImportFormatJSONL  = "jsonl"
+	AccountImportFormatCSV    = "csv"
+	AccountImportFormatBundle = "bundle"
+)
+
+// 导入行处理结果
+const (
+	AccountImportStatusCreated   = "created"   // 已创建
+	AccountImportStatusValid     = "valid"     // 校验通过（试运行）
+	AccountImportStatusDuplicate = "duplicate" // 与已有账户或本批次重复
+	AccountImportStatusInvalid   = "invalid"   // 校验失败
+	AccountImportStatusFailed    = "failed"    // 写入失败
+)
+
+const (
+	accountBundleFormat      = "cli-proxy-accounts"
+	accountBundleVersion     = 1
+	accountBundleMinPassLen  = 8
+	accountImportCheckWorker = 5 // 导入后健康检测并发数
+)
+
+// accountCredentialRequirements 各账户类型的凭证要求：外层为"且"，内层为"或"
+var accountCredentialRequirements = map[string][][]string{
+	model.AccountTypeClaudeOfficial:  {{"session_key", "refresh_token", "access_token"}},
+	model.AccountTypeClaudeConsole:   {{"api_key"}},
+	model.AccountTypeBedrock:         {{"aws_access_key"}, {"aws_secret_key"}},
+	model.AccountTypeOpenAI:          {{"api_key"}},
+	model.AccountTypeOpenAIResponses: {{"refresh_token", "access_token", "session_key", "xyrt_refresh_token"}},
+	model.AccountTypeAzureOpenAI:     {{"api_key"}, {"azure_endpoint"}},
+	model.AccountTypeGemini:          {{"refresh_token", "access_token"}},
+	model.AccountTypeGeminiAPI:       {{"api_key"}},
+	model.AccountTypeDroid:           {{"api_key", "refresh_token", "access_token"}},
+}
+
+// AccountImportRow 导入行（字段与创建账户请求一致）
+type AccountImportRow struct {
+	CreateAccountRequest
+	Enabled *bool  `json:"enabled"` // 未指定时默认启用
+	Group   string `json:"group"`   // 分组名称（多个用逗号分隔），不存在时自动创建
+}
+
+// credential 按 JSON 字段名获取凭证值
+func (r *AccountImportRow) credential(field string) string {
+	switch field {
+	case "session_key":
+		return r.SessionKey
+	case "refresh_token":
+		return r.RefreshToken
+	case "access_token":
+		return r.AccessToken
+	case "api_key":
+		return r.APIKey
+	case "aws_access_key":
+		return r.AWSAccessKey
+	case "aws_secret_key":
+		return r.AWSSecretKey
+	case "azure_endpoint":
+		return r.AzureEndpoint
+	case "xyrt_refresh_token":
+		return r.XyrtRefreshToken
+	}
+	return ""
+}
+
+// ImportAccountsRequest 批量导入请求
+type ImportAccountsRequest struct {
+	Format      string `json:"format"` // json, jsonl, csv, bundle（为空时自动识别）
+	Data        string `json:"data" binding:"required"`
+	Passphrase  string `json:"passphrase"`   // bundle 格式的解密口令
+	DryRun      bool   `json:"dry_run"`      // 只校验不写入
+	GroupID     uint   `json:"group_id"`     // 未在行内指定分组时使用的分组
+	HealthCheck bool   `json:"health_check"` // 创建后立即健康检测
+}
+
+// AccountImportRowResult 单行导入结果
+type AccountImportRowResult struct {
+	Row           int    `json:"row"` // 从 1 开始
+	Name          string `json:"name"`
+	Type          string `json:"type"`
+	Status        string `json:"status"`
+	Error         string `json:"error,omitempty"`
+	AccountID     uint   `json:"account_id,omitempty"`
+	DuplicateOf   uint   `json:"duplicate_of,omitempty"` // 重复的已有账户 ID（本批次内重复时为 0）
+	DuplicateRow  int    `json:"duplicate_row,omitempty"`
+	GroupIDs      []uint `json:"group_ids,omitempty"`
+	Healthy       *bool  `json:"healthy,omitempty"`
+	HealthMessage string `json:"health_message,omitempty"`
+}
+
+// ImportAccountsResult 批量导入结果
+type ImportAccountsResult struct {
+	DryRun     bool                     `json:"dry_run"`
+	Total      int                      `json:"total"`
+	Created    int                      `json:"created"`
+	Valid      int                      `json:"valid"`
+	Duplicates int                      `json:"duplicates"`
+	Invalid    int                      `json:"invalid"`
+	Failed     int                      `json:"failed"`
+	Rows       []AccountImportRowResult `json:"rows"`
+}
+
+// ExportAccountsRequest 导出请求
+type ExportAccountsRequest struct {
+	IDs        []uint `json:"ids"`      // 指定账户（为空时按条件导出）
+	Platform   string `json:"platform"` // 按平台过滤
+	GroupID    uint   `json:"group_id"` // 按分组过滤
+	Passphrase string `json:"passphrase" binding:"required"`
+}
+
+// AccountBundle 加密的账户凭证包
+type AccountBundle struct {
+	Format     string                    `json:"format"`
+	Version    int                       `json:"version"`
+	ExportedAt time.Time                 `json:"exported_at"`
+	Count      int                       `json:"count"`
+	Encryption *utils.PassphraseEnvelope `json:"encryption"`
+}
+
+// ImportAccounts 批量导入账户
+func (s *AccountService) ImportAccounts(req *ImportAccountsRequest) (*ImportAccountsResult, error) {
+	rows, err := parseAccountImportRows(req.Format, []byte(req.Data), req.Passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := s.repo.GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("load accounts: %w", err)
+	}
+	known := make(map[string]uint, len(existing))
+	for i := range existing {
+		if fp := accountFingerprint(accountToImportRow(&existing[i])); fp != "" {
+			known[fp] = existing[i].ID
+		}
+	}
+
+	groups, err := s.groupRepo.GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("load groups: %w", err)
+	}
+	groupsByName := make(map[string]*model.AccountGroup, len(groups))
+	for i := range groups {
+		groupsByName[groups[i].Name] = &groups[i]
+	}
+
+	result := &ImportAccountsResult{DryRun: req.DryRun, Total: len(rows)}
+	batch := make(map[string]int) // fingerprint -> row
+	var created []int             // result.Rows 下标
+
+	for i := range rows {
+		row := &rows[i]
+		rr := AccountImportRowResult{Row: i + 1, Name: row.Name, Type: row.Type}
+
+		if err := normalizeAccountImportRow(row); err != nil {
+			rr.Status = AccountImportStatusInvalid
+			rr.Error = err.Error()
+			result.Invalid++
+			result.Rows = append(result.Rows, rr)
+			continue
+		}
+		rr.Name = row.Name
+
+		fp := accountFingerprint(row)
+		if id, ok := known[fp]; ok {
+			rr.Status = AccountImportStatusDuplicate
+			rr.DuplicateOf = id
+			result.Duplicates++
+			result.Rows = append(result.Rows, rr)
+			continue
+		}
+		if prev, ok := batch[fp]; ok {
+			rr.Status = AccountImportStatusDuplicate
+			rr.DuplicateRow = prev
+			result.Duplicates++
+			result.Rows = append(result.Rows, rr)
+			continue
+		}
+		batch[fp] = rr.Row
+
+		if req.DryRun {
+			rr.Status = AccountImportStatusValid
+			result.Valid++
+			result.Rows = append(result.Rows, rr)
+			continue
+		}
+
+		account, err := s.createImportedAccount(&row.CreateAccountRequest)
+		if err != nil {
+			rr.Status = AccountImportStatusFailed
+			rr.Error = err.Error()
+			result.Failed++
+			result.Rows = append(result.Rows, rr)
+			continue
+		}
+		rr.Status = AccountImportStatusCreated
+		rr.AccountID = account.ID
+		result.Created++
+
+		for _, group := range s.resolveImportGroups(row, account.Platform, req.GroupID, groupsByName, groups) {
+			if err := s.groupRepo.AddAccount(group, account.ID); err != nil {
+				getAccountLog().Warn("[account] 导入账户加入分组失败 | AccountID: %d | GroupID: %d | 原因: %v", account.ID, group, err)
+				continue
+			}
+			rr.GroupIDs = append(rr.GroupIDs, group)
+		}
+
+		result.Rows = append(result.Rows, rr)
+		created = append(created, len(result.Rows)-1)
+	}
+
+	if result.Created > 0 {
+		scheduler.GetScheduler().Refresh()
+	}
+	if req.HealthCheck && len(created) > 0 {
+		runImportHealthChecks(result, created)
+	}
+
+	getAccountLog().Info("[account] 批量导入完成 | DryRun: %v | 总数: %d | 创建: %d | 重复: %d | 无效: %d | 失败: %d",
+		req.DryRun, result.Total, result.Created, result.Duplicates, result.Invalid, result.Failed)
+	return result, nil
+}
+
+// createImportedAccount 创建导入的账户（不逐个刷新调度器，由批量导入结束后统一刷新）
+func (s *AccountService) createImportedAccount(req *CreateAccountRequest) (*model.Account, error) {
+	account, err := buildAccount(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.Create(account); err != nil {
+		return nil, err
+	}
+	s.refreshNewXyrtAccount(account)
+	return account, nil
+}
+
+// resolveImportGroups 确定导入账户的分组：行内分组名 → 请求指定分组 → 平台默认分组
+func (s *AccountService) resolveImportGroups(row *AccountImportRow, platform string, fallback uint, byName map[string]*model.AccountGroup, all []model.AccountGroup) []uint {
+	var ids []uint
+	for _, name := range splitAndTrim(row.Group) {
+		group, ok := byName[name]
+		if !ok {
+			group = &model.AccountGroup{Name: name, Platform: platform}
+			if err := s.groupRepo.Create(group); err != nil {
+				getAccountLog().Warn("[account] 导入时创建分组失败 | Group: %s | 原因: %v", name, err)
+				continue
+			}
+			byName[name] = group
+		}
+		ids = append(ids, group.ID)
+	}
+	if len(ids) > 0 {
+		return ids
+	}
+	if fallback > 0 {
+		return []uint{fallback}
+	}
+	for _, group := range all {
+		if group.IsDefault && (group.Platform == "" || group.Platform == platform) {
+			return []uint{group.ID}
+		}
+	}
+	return nil
+}
+
+// runImportHealthChecks 对新建账户并发执行健康检测，结果写回导入结果
+func runImportHealthChecks(result *ImportAccountsResult, indexes []int) {
+	healthCheckService := GetAccountHealthCheckService()
+	sem := make(chan struct{}, accountImportCheckWorker)
+	var wg sync.WaitGroup
+	for _, idx := range indexes {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(rr *AccountImportRowResult) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			healthy, msg := healthCheckService.TriggerSingleCheck(rr.AccountID)
+			rr.Healthy = &healthy
+			rr.HealthMessage = msg
+		}(&result.Rows[idx])
+	}
+	wg.Wait()
+}
+
+// ExportAccounts 导出账户为口令加密的凭证包
+func (s *AccountService) ExportAccounts(req *ExportAccountsRequest) (*AccountBundle, error) {
+	if len(req.Passphrase) < accountBundleMinPassLen {
+		return nil, fmt.Errorf("口令长度至少 %d 位", accountBundleMinPassLen)
+	}
+
+	accounts, err := s.repo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[uint]bool, len(req.IDs))
+	for _, id := range req.IDs {
+		ids[id] = true
+	}
+
+	rows := make([]AccountImportRow, 0, len(accounts))
+	for i := range accounts {
+		account := &accounts[i]
+		if len(ids) > 0 && !ids[account.ID] {
+			continue
+		}
+		if req.Platform != "" && account.Platform != req.Platform {
+			continue
+		}
+		if req.GroupID > 0 && !accountInGroup(account, req.GroupID) {
+			continue
+		}
+		rows = append(rows, *accountToImportRow(account))
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("没有符合条件的账户")
+	}
+
+	plain, err := json.Marshal(rows)
+	if err != nil {
+		return nil, err
+	}
+	envelope, err := utils.EncryptWithPassphrase(plain, req.Passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	getAccountLog().Info("[account] 导出账户凭证包 | 数量: %d", len(rows))
+	return &AccountBundle{
+		Format:     accountBundleFormat,
+		Version:    accountBundleVersion,
+		ExportedAt: time.Now(),
+		Count:      len(rows),
+		Encryption: envelope,
+	}, nil
+}
+
+// accountInGroup 判断账户是否属于指定分组
+func accountInGroup(account *model.Account, groupID uint) bool {
+	for _, group := range account.Groups {
+		if group.ID == groupID {
+			return true
+		}
+	}
+	return false
+}
+
+// accountToImportRow 将账户转换为导入行（导出和去重共用）
+func accountToImportRow(account *model.Account) *AccountImportRow {
+	enabled := account.Enabled
+	groupNames := make([]string, 0, len(account.Groups))
+	for _, group := range account.Groups {
+		groupNames = append(groupNames, group.Name)
+	}
+	return &AccountImportRow{
+		CreateAccountRequest: CreateAccountRequest{
+			Name:                account.Name,
+			Type:                account.Type,
+			Priority:            account.Priority,
+			Weight:              account.Weight,
+			MaxConcurrency:      account.MaxConcurrency,
+			TPMLimit:            account.TPMLimit,
+			ITPMLimit:           account.ITPMLimit,
+			OTPMLimit:           account.OTPMLimit,
+			MonthlyCost:         account.MonthlyCost,
+			CostRate:            account.CostRate,
+			APIKey:              account.APIKey,
+			APISecret:           account.APISecret,
+			AccessToken:         account.AccessToken,
+			RefreshToken:        account.RefreshToken,
+			SessionKey:          account.SessionKey,
+			OrganizationID:      account.OrganizationID,
+			SubscriptionLevel:   account.SubscriptionLevel,
+			OpusAccess:          account.OpusAccess,
+			AWSAccessKey:        account.AWSAccessKey,
+			AWSSecretKey:        account.AWSSecretKey,
+			AWSRegion:           account.AWSRegion,
+			AWSSessionToken:     account.AWSSessionToken,
+			AzureEndpoint:       account.AzureEndpoint,
+			AzureDeploymentName: account.AzureDeploymentName,
+			AzureAPIVersion:     account.AzureAPIVersion,
+			BaseURL:             account.BaseURL,
+			ModelMapping:        account.ModelMapping,
+			AllowedModels:       account.AllowedModels,
+			GatewayURL:          account.GatewayURL,
+			AuthType:            account.AuthType,
+			XyrtRefreshToken:    account.XyrtRefreshToken,
+		},
+		Enabled: &enabled,
+		Group:   strings.Join(groupNames, ","),
+	}
+}
+
+// normalizeAccountImportRow 校验导入行并填充默认值
+func normalizeAccountImportRow(row *AccountImportRow) error {
+	row.Type = strings.TrimSpace(row.Type)
+	requirements, ok := accountCredentialRequirements[row.Type]
+	if !ok {
+		return fmt.Errorf("无效的账户类型: %q", row.Type)
+	}
+	for _, anyOf := range requirements {
+		satisfied := false
+		for _, field := range anyOf {
+			if strings.TrimSpace(row.credential(field)) != "" {
+				satisfied = true
+				break
+			}
+		}
+		if !satisfied {
+			return fmt.Errorf("缺少凭证字段: %s", strings.Join(anyOf, " 或 "))
+		}
+	}
+
+	if strings.TrimSpace(row.Name) == "" {
+		row.Name = fmt.Sprintf("%s-%s", row.Type, accountFingerprint(row)[:8])
+	}
+	row.CreateAccountRequest.Enabled = row.Enabled == nil || *row.Enabled
+	if row.Priority <= 0 {
+		row.Priority = 50
+	}
+	if row.Weight <= 0 {
+		row.Weight = 100
+	}
+	if row.MaxConcurrency <= 0 {
+		row.MaxConcurrency = 5
+	}
+	return nil
+}
+
+// accountFingerprint 计算账户凭证指纹（类型 + 主凭证），用于重复检测
+func accountFingerprint(row *AccountImportRow) string {
+	for _, field := range []string{"session_key", "refresh_token", "api_key", "aws_access_key", "xyrt_refresh_token", "access_token"} {
+		if value := strings.TrimSpace(row.credential(field)); value != "" {
+			sum := sha256.Sum256([]byte(row.Type + "|" + field + "|" + value))
+			return hex.EncodeToString(sum[:])
+		}
+	}
+	return ""
+}
+
+// parseAccountImportRows 解析导入数据
+func parseAccountImportRows(format string, data []byte, passphrase string) ([]AccountImportRow, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, errors.New("导入数据为空")
+	}
+	if format == "" {
+		format = detectAccountImportFormat(data)
+	}
+
+	switch strings.ToLower(format) {
+	case AccountImportFormatJSON:
+		var rows []AccountImportRow
+		if data[0] == '{' {
+			var wrapper struct {
+				Accounts []AccountImportRow `json:"accounts"`
+			}
+			if err := json.Unmarshal(data, &wrapper); err != nil {
+				return nil, fmt.Errorf("JSON 解析失败: %w", err)
+			}
+			return wrapper.Accounts, nil
+		}
+		if err := json.Unmarshal(data, &rows); err != nil {
+			return nil, fmt.Errorf("JSON 解析失败: %w", err)
+		}
+		return rows, nil
+
+	case AccountImportFormatJSONL:
+		var rows []AccountImportRow
+		for i, line := range bytes.Split(data, []byte("\n")) {
+			line = bytes.TrimSpace(line)
+			if len(line) == 0 {
+				continue
+			}
+			var row AccountImportRow
+			if err := json.Unmarshal(line, &row); err != nil {
+				return nil, fmt.Errorf("第 %d 行 JSON 解析失败: %w", i+1, err)
+			}
+			rows = append(rows, row)
+		}
+		return rows, nil
+
+	case AccountImportFormatCSV:
+		return parseAccountImportCSV(data)
+
+	case AccountImportFormatBundle:
+		var bundle AccountBundle
+		if err := json.Unmarshal(data, &bundle); err != nil {
+			return nil, fmt.Errorf("凭证包解析失败: %w", err)
+		}
+		if bundle.Format != accountBundleFormat || bundle.Encryption == nil {
+			return nil, errors.New("不是有效的账户凭证包")
+		}
+		if bundle.Version > accountBundleVersion {
+			return nil, fmt.Errorf("不支持的凭证包版本: %d", bundle.Version)
+		}
+		plain, err := utils.DecryptWithPassphrase(bundle.Encryption, passphrase)
+		if err != nil {
+			return nil, err
+		}
+		var rows []AccountImportRow
+		if err := json.Unmarshal(plain, &rows); err != nil {
+			return nil, fmt.Errorf("凭证包内容解析失败: %w", err)
+		}
+		return rows, nil
+	}
+	return nil, fmt.Errorf("不支持的导入格式: %s", format)
+}
+
+// detectAccountImportFormat 自动识别导入格式
+func detectAccountImportFormat(data []byte) string {
+	switch data[0] {
+	case '[':
+		return AccountImportFormatJSON
+	case '{':
+		var probe struct {
+			Format   string          `json:"format"`
+			Accounts json.RawMessage `json:"accounts"`
+		}
+		if json.Unmarshal(data, &probe) == nil {
+			if probe.Format == accountBundleFormat {
+				return AccountImportFormatBundle
+			}
+			if probe.Accounts != nil {
+				return AccountImportFormatJSON
+			}
+		}
+		return AccountImportFormatJSONL
+	}
+	return AccountImportFormatCSV
+}
+
+// accountImportCSVInts / accountImportCSVBools CSV 中需要类型转换的列
+var (
+	accountImportCSVInts  = map[string]bool{"priority": true, "weight": true, "max_concurrency": true, "tpm_limit": true, "itpm_limit": true, "otpm_limit": true, "proxy_id": true, "proxy_pool_id": true, "gateway_id": true}
+	accountImportCSVBools = map[string]bool{"enabled": true, "opus_access": true}
+)
+
+// parseAccountImportCSV 解析 CSV（首行为列名，列名与 JSON 字段名一致）
+func parseAccountImportCSV(data []byte) ([]AccountImportRow, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("CSV 列名读取失败: %w", err)
+	}
+	for i := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(header[i], "\ufeff")))
+	}
+
+	var rows []AccountImportRow
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 行 CSV 解析失败: %w", line, err)
+		}
+
+		fields := make(map[string]interface{}, len(header))
+		for i, value := range record {
+			if i >= len(header) || header[i] == "" {
+				continue
+			}
+			value = strings.TrimSpace(value)
+			if value == "" {
+				continue
+			}
+			key := header[i]
+			switch {
+			case accountImportCSVInts[key]:
+				n, err := strconv.Atoi(value)
+				if err != nil {
+					return nil, fmt.Errorf("第 %d 行 %s 不是整数: %q", line, key, value)
+				}
+				fields[key] = n
+			case accountImportCSVBools[key]:
+				b, err := strconv.ParseBool(value)
+				if err != nil {
+					return nil, fmt.Errorf("第 %d 行 %s 不是布尔值: %q", line, key, value)
+				}
+				fields[key] = b
+			default:
+				fields[key] = value
+			}
+		}
+		if len(fields) == 0 {
+			continue
+		}
+
+		raw, _ := json.Marshal(fields)
+		var row AccountImportRow
+		if err := json.Unmarshal(raw, &row); err != nil {
+			return nil, fmt.Errorf("第 %d 行转换失败: %w", line, err)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// splitAndTrim 按逗号拆分并去除空白项
+func splitAndTrim(s string) []string {
+	var parts []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
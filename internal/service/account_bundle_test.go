@@ -0,0 +1,109 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+
+	"cli-proxy/internal/model"
+	"cli-proxy/pkg/utils"
+)
+
+func TestParseAccountImportRowsFormats(t *testing.T) {
+	csvData := "name,type,session_key,priority,enabled,group\n" +
+		"a1,claude-official,sk-ant-sid01-aaa,80,false,team-a\n" +
+		",claude-official,sk-ant-sid01-bbb,,,\n"
+	rows, err := parseAccountImportRows("", []byte(csvData), "")
+	if err != nil {
+		t.Fatalf("parse csv: %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(rows))
+	}
+	if rows[0].Priority != 80 || rows[0].Enabled == nil || *rows[0].Enabled || rows[0].Group != "team-a" {
+		t.Fatalf("unexpected first row: %+v", rows[0])
+	}
+
+	jsonl := `{"type":"openai","api_key":"sk-1"}
+{"type":"bedrock","aws_access_key":"AKIA1","aws_secret_key":"secret","aws_region":"us-east-1"}`
+	rows, err = parseAccountImportRows("", []byte(jsonl), "")
+	if err != nil || len(rows) != 2 {
+		t.Fatalf("parse jsonl: %v (%d rows)", err, len(rows))
+	}
+
+	rows, err = parseAccountImportRows("", []byte("type,api_key,tpm_limit,itpm_limit,otpm_limit\nopenai,sk-2,90000,60000,30000\n"), "")
+	if err != nil || len(rows) != 1 || rows[0].TPMLimit != 90000 || rows[0].ITPMLimit != 60000 || rows[0].OTPMLimit != 30000 {
+		t.Fatalf("parse csv token limits: %v %+v", err, rows)
+	}
+
+	rows, err = parseAccountImportRows("", []byte(`{"accounts":[{"type":"gemini-api","api_key":"AIza1"}]}`), "")
+	if err != nil || len(rows) != 1 || rows[0].APIKey != "AIza1" {
+		t.Fatalf("parse json wrapper: %v %+v", err, rows)
+	}
+}
+
+func TestNormalizeAccountImportRow(t *testing.T) {
+	row := &AccountImportRow{CreateAccountRequest: CreateAccountRequest{Type: model.AccountTypeClaudeOfficial, SessionKey: "sk-ant-sid01-aaa"}}
+	if err := normalizeAccountImportRow(row); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if row.Name == "" || !row.CreateAccountRequest.Enabled || row.Priority != 50 || row.MaxConcurrency != 5 {
+		t.Fatalf("defaults not applied: %+v", row)
+	}
+
+	bad := &AccountImportRow{CreateAccountRequest: CreateAccountRequest{Type: model.AccountTypeBedrock, AWSAccessKey: "AKIA1"}}
+	if err := normalizeAccountImportRow(bad); err == nil {
+		t.Fatal("expected missing secret key error")
+	}
+	unknown := &AccountImportRow{CreateAccountRequest: CreateAccountRequest{Type: "nope", APIKey: "x"}}
+	if err := normalizeAccountImportRow(unknown); err == nil {
+		t.Fatal("expected invalid type error")
+	}
+}
+
+func TestAccountFingerprintDedup(t *testing.T) {
+	a := &AccountImportRow{CreateAccountRequest: CreateAccountRequest{Name: "a", Type: "openai", APIKey: "sk-1"}}
+	b := &AccountImportRow{CreateAccountRequest: CreateAccountRequest{Name: "b", Type: "openai", APIKey: " sk-1 "}}
+	c := &AccountImportRow{CreateAccountRequest: CreateAccountRequest{Name: "c", Type: "claude-console", APIKey: "sk-1"}}
+	if accountFingerprint(a) != accountFingerprint(b) {
+		t.Fatal("same credential should produce the same fingerprint")
+	}
+	if accountFingerprint(a) == accountFingerprint(c) {
+		t.Fatal("different account types should not collide")
+	}
+}
+
+func TestParseAccountBundle(t *testing.T) {
+	plain, _ := json.Marshal([]AccountImportRow{{CreateAccountRequest: CreateAccountRequest{Name: "x", Type: "openai", APIKey: "sk-1"}}})
+	env, err := utils.EncryptWithPassphrase(plain, "passphrase-1")
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	data, _ := json.Marshal(AccountBundle{Format: accountBundleFormat, Version: accountBundleVersion, Count: 1, Encryption: env})
+
+	rows, err := parseAccountImportRows("", data, "passphrase-1")
+	if err != nil || len(rows) != 1 || rows[0].APIKey != "sk-1" {
+		t.Fatalf("parse bundle: %v %+v", err, rows)
+	}
+	if _, err := parseAccountImportRows("", data, "wrong"); err == nil {
+		t.Fatal("expected wrong passphrase error")
+	}
+}
+
+func TestAccountToImportRowTokenLimits(t *testing.T) {
+	row := accountToImportRow(&model.Account{Name: "x", Type: "openai", APIKey: "sk-1", TPMLimit: 90000, ITPMLimit: 60000, OTPMLimit: 30000})
+	if row.TPMLimit != 90000 || row.ITPMLimit != 60000 || row.OTPMLimit != 30000 {
+		t.Fatalf("token limits not exported: %+v", row.CreateAccountRequest)
+	}
+}
+
+func TestBuildAccountSharedByCreateAndImport(t *testing.T) {
+	row := &AccountImportRow{CreateAccountRequest: CreateAccountRequest{Type: model.AccountTypeOpenAIResponses, XyrtRefreshToken: "rt-1", TPMLimit: 1000}}
+	account, err := buildAccount(&row.CreateAccountRequest)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if account.Platform == "" || account.AuthType != "xyrt" || account.TPMLimit != 1000 ||
+		account.Priority != 50 || account.Weight != 100 || account.MaxConcurrency != 5 {
+		t.Fatalf("unexpected account: %+v", account)
+	}
+}
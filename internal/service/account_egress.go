@@ -0,0 +1,172 @@
+/*
+ * 文件作用：账户出口 IP 服务，处理出口 IP 探测与账户出口地区校验
+ * 负责功能：
+ *   - 经代理（或直连）请求回显服务探测出口 IP 与国家/地区
+ *   - 保存代理出口信息并同步到出口跟踪器与代理池
+ *   - 账户改绑代理/代理池时的出口地区校验
+ *   - 账户当前出口与变更记录查询
+ * 重要程度：⭐⭐⭐ 一般（账户风控）
+ * 依赖模块：repository, model, egress, proxypool, logger
+ */
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"cli-proxy/internal/egress"
+	"cli-proxy/internal/model"
+	"cli-proxy/internal/proxypool"
+	"cli-proxy/internal/repository"
+	"cli-proxy/pkg/logger"
+)
+
+// egressDiscoverTimeout 单次出口 IP 探测超时
+const egressDiscoverTimeout = 15 * time.Second
+
+// ErrEgressRegionMismatch 账户改绑到出口地区不同的代理（需显式确认）
+var ErrEgressRegionMismatch = errors.New("目标代理的出口地区与账户当前出口地区不一致")
+
+var (
+	accountEgressService     *AccountEgressService
+	accountEgressServiceOnce sync.Once
+)
+
+// AccountEgressService 账户出口 IP 服务
+type AccountEgressService struct {
+	repo *repository.AccountEgressRepository
+	log  *logger.Logger
+}
+
+// GetAccountEgressService 获取账户出口 IP 服务单例
+func GetAccountEgressService() *AccountEgressService {
+	accountEgressServiceOnce.Do(func() {
+		accountEgressService = &AccountEgressService{
+			repo: repository.NewAccountEgressRepository(),
+			log:  logger.GetLogger("proxy"),
+		}
+	})
+	return accountEgressService
+}
+
+// DiscoverProxyEgress 经代理探测出口 IP，保存结果并同步到出口跟踪器与代理池
+func (s *AccountEgressService) DiscoverProxyEgress(p *model.Proxy) (egress.Info, error) {
+	client, err := newProbeClient(p)
+	if err != nil {
+		return egress.Info{}, err
+	}
+	info, err := s.discover(client)
+	if err != nil {
+		return egress.Info{}, err
+	}
+
+	if err := s.repo.SaveProxyEgress(p.ID, info.IP, info.Country, time.Now()); err != nil {
+		return info, err
+	}
+	egress.GetTracker().SetProxyEgress(p.ID, info)
+	proxypool.GetManager().UpdateEgress(p.ID, info.IP, info.Country)
+
+	if p.EgressIP != "" && (p.EgressIP != info.IP || p.EgressCountry != info.Country) {
+		s.log.WarnZ("代理出口 IP 发生变化",
+			logger.Uint("proxy_id", p.ID),
+			logger.String("proxy_name", p.Name),
+			logger.String("old_ip", p.EgressIP),
+			logger.String("old_country", p.EgressCountry),
+			logger.String("new_ip", info.IP),
+			logger.String("new_country", info.Country),
+		)
+	}
+	return info, nil
+}
+
+// DiscoverDirectEgress 探测直连出口 IP（仅保存在内存中）
+func (s *AccountEgressService) DiscoverDirectEgress() (egress.Info, error) {
+	info, err := s.discover(&http.Client{Timeout: egressDiscoverTimeout})
+	if err != nil {
+		return egress.Info{}, err
+	}
+	egress.GetTracker().SetProxyEgress(egress.DirectProxyID, info)
+	return info, nil
+}
+
+// discover 通过 client 请求配置的回显服务
+func (s *AccountEgressService) discover(client *http.Client) (egress.Info, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), egressDiscoverTimeout)
+	defer cancel()
+	return egress.Discover(ctx, client, GetConfigService().GetProxyEgressEchoURL())
+}
+
+// CheckRegion 校验账户（已应用新的代理/代理池绑定）的目标出口地区与当前记录的出口地区是否一致
+// 未开启出口跟踪、账户出口地区未知或目标出口地区未知时视为一致；
+// 代理池只要有同地区（或出口地区未知）的启用代理即视为一致（分配时优先同地区代理）
+func (s *AccountEgressService) CheckRegion(account *model.Account) error {
+	region := egress.GetTracker().Country(account.ID)
+	if region == "" {
+		return nil
+	}
+
+	var target string
+	switch {
+	case account.ProxyPoolID != nil:
+		pool, err := GetProxyPoolService().GetByID(*account.ProxyPoolID)
+		if err != nil || pool == nil {
+			return err
+		}
+		for _, p := range pool.Proxies {
+			if !p.Enabled {
+				continue
+			}
+			if p.EgressCountry == "" || p.EgressCountry == region {
+				return nil
+			}
+			target = p.EgressCountry
+		}
+	case account.ProxyID != nil:
+		p, err := GetProxyService().GetByID(*account.ProxyID)
+		if err != nil || p == nil {
+			return err
+		}
+		target = p.EgressCountry
+	default:
+		info, _ := egress.GetTracker().ProxyEgress(egress.DirectProxyID)
+		target = info.Country
+	}
+
+	if target != "" && target != region {
+		return fmt.Errorf("%w（当前 %s，目标 %s），确认切换请设置 force_region_change", ErrEgressRegionMismatch, region, target)
+	}
+	return nil
+}
+
+// Reset 清除账户当前出口记录（下次请求时重新记录）
+func (s *AccountEgressService) Reset(accountID uint) error {
+	return egress.GetTracker().Reset(accountID)
+}
+
+// Get 获取账户当前出口及最近的变更记录，未记录时 current 为 nil
+func (s *AccountEgressService) Get(accountID uint, limit int) (*model.AccountEgress, []model.AccountEgressChange, error) {
+	current, err := s.repo.GetByAccountID(accountID)
+	if err != nil {
+		return nil, nil, err
+	}
+	changes, _, err := s.repo.ListChanges(&repository.EgressChangeFilter{AccountID: accountID}, 1, limit)
+	if err != nil {
+		return nil, nil, err
+	}
+	return current, changes, nil
+}
+
+// ListChanges 分页查询出口 IP 变更记录
+func (s *AccountEgressService) ListChanges(filter *repository.EgressChangeFilter, page, pageSize int) ([]model.AccountEgressChange, int64, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+	return s.repo.ListChanges(filter, page, pageSize)
+}
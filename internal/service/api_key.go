@@ -12,6 +12,7 @@ package service
 
 import (
 	"errors"
+	"fmt"
 	"strings"
 	"sync"
 	"time"
@@ -57,18 +58,95 @@ func normalizeCreateAPIKeyInput(req *CreateAPIKeyRequest) (int, string) {
 	return rateLimit, allowedPlatforms
 }
 
+// normalizeHedgeDelay 规范化对冲延迟（负数视为关闭）
+func normalizeHedgeDelay(delayMs int) int {
+	if delayMs < 0 {
+		return 0
+	}
+	return delayMs
+}
+
+// maxConcurrencyWaitMs 并发排队等待上限（排队只用于削峰，过长会占住客户端连接）
+const maxConcurrencyWaitMs = 60000
+
+// 排队权重上限
+const maxQueueWeight = 100
+
+// normalizeQueueWeight 规范化排队权重（未设置时为 1）
+func normalizeQueueWeight(weight int) int {
+	if weight <= 0 {
+		return 1
+	}
+	if weight > maxQueueWeight {
+		return maxQueueWeight
+	}
+	return weight
+}
+
+// normalizeTokenLimit 规范化每分钟 Token 限制（负数视为不限）
+func normalizeTokenLimit(limit int) int {
+	if limit < 0 {
+		return 0
+	}
+	return limit
+}
+
+// validatePreferredLocale 校验错误提示语言标签（如 en、zh-CN、pt-BR）
+func validatePreferredLocale(locale string) error {
+	locale = model.NormalizeLocale(locale)
+	if len(locale) > 20 {
+		return fmt.Errorf("无效的语言标签: %s", locale)
+	}
+	for _, r := range locale {
+		if !(r >= 'a' && r <= 'z') && !(r >= '0' && r <= '9') && r != '-' {
+			return fmt.Errorf("无效的语言标签: %s", locale)
+		}
+	}
+	return nil
+}
+
+// normalizeConcurrency 规范化并发限制与排队等待时长（负数视为不限/不排队）
+func normalizeConcurrency(maxConcurrency, waitMs int) (int, int) {
+	if maxConcurrency < 0 {
+		maxConcurrency = 0
+	}
+	if waitMs < 0 {
+		waitMs = 0
+	}
+	if waitMs > maxConcurrencyWaitMs {
+		waitMs = maxConcurrencyWaitMs
+	}
+	return maxConcurrency, waitMs
+}
+
 // CreateAPIKeyRequest 创建 API Key 请求
 type CreateAPIKeyRequest struct {
-	Name             string     `json:"name" binding:"required"`
-	Description      string     `json:"description"`       // 描述用途
-	AllowedPlatforms string     `json:"allowed_platforms"` // 允许的平台
-	AllowedModels    string     `json:"allowed_models"`    // 允许的模型
-	BlockedModels    string     `json:"blocked_models"`    // 禁止的模型
-	AllowedClients   string     `json:"allowed_clients"`   // 允许的客户端
-	RateLimit        int        `json:"rate_limit"`        // 每分钟请求限制
-	DailyLimit       int        `json:"daily_limit"`       // 每日请求限制
-	MonthlyQuota     float64    `json:"monthly_quota"`     // 月额度
-	ExpiresAt        *time.Time `json:"expires_at"`        // 过期时间
+	Name                string     `json:"name" binding:"required"`
+	Description         string     `json:"description"`           // 描述用途
+	AllowedPlatforms    string     `json:"allowed_platforms"`     // 允许的平台
+	AllowedModels       string     `json:"allowed_models"`        // 允许的模型
+	BlockedModels       string     `json:"blocked_models"`        // 禁止的模型
+	AllowedClients      string     `json:"allowed_clients"`       // 允许的客户端
+	AllowedIPs          string     `json:"allowed_ips"`           // 允许的来源 IP/CIDR
+	AllowedCountries    string     `json:"allowed_countries"`     // 允许的来源国家代码
+	BlockedCountries    string     `json:"blocked_countries"`     // 禁止的来源国家代码
+	RateLimit           int        `json:"rate_limit"`            // 每分钟请求限制
+	DailyLimit          int        `json:"daily_limit"`           // 每日请求限制
+	MonthlyQuota        float64    `json:"monthly_quota"`         // 月额度
+	MaxConcurrency      int        `json:"max_concurrency"`       // 最大并发请求数
+	ConcurrencyWaitMs   int        `json:"concurrency_wait_ms"`   // 并发已满时排队等待时长（毫秒）
+	TPMLimit            int        `json:"tpm_limit"`             // 每分钟总 Token 限制
+	ITPMLimit           int        `json:"itpm_limit"`            // 每分钟输入 Token 限制
+	OTPMLimit           int        `json:"otpm_limit"`            // 每分钟输出 Token 限制
+	PreferredLocale     string     `json:"preferred_locale"`      // 错误提示语言
+	QueuePriority       int        `json:"queue_priority"`        // 账户池排队优先级
+	QueueWeight         int        `json:"queue_weight"`          // 账户池排队权重
+	ExpiresAt           *time.Time `json:"expires_at"`            // 过期时间
+	HedgeDelayMs        int        `json:"hedge_delay_ms"`        // 对冲请求延迟（毫秒）
+	StreamResume        bool       `json:"stream_resume"`         // 流式中断续传
+	PromptCacheOptimize bool       `json:"prompt_cache_optimize"` // 自动插入 cache_control 断点
+	NoLogBodies         bool       `json:"no_log_bodies"`         // 不记录请求/响应体
+	MaskRequestBody     bool       `json:"mask_request_body"`     // 转发前遮蔽请求内容
 }
 
 // CreateAPIKeyResponse 创建 API Key 响应 (只在创建时返回完整 key)
@@ -83,6 +161,13 @@ type CreateAPIKeyResponse struct {
 func (s *APIKeyService) Create(req *CreateAPIKeyRequest) (*CreateAPIKeyResponse, error) {
 	getAPIKeyLog().Info("[apikey] 创建 API Key 请求 | Name: %s", req.Name)
 
+	if err := ValidateAPIKeySourceRules(req.AllowedIPs, req.AllowedCountries, req.BlockedCountries); err != nil {
+		return nil, err
+	}
+	if err := validatePreferredLocale(req.PreferredLocale); err != nil {
+		return nil, err
+	}
+
 	// 生成新的 API Key
 	key, hash, prefix, err := model.GenerateAPIKey()
 	if err != nil {
@@ -92,21 +177,38 @@ func (s *APIKeyService) Create(req *CreateAPIKeyRequest) (*CreateAPIKeyResponse,
 
 	// 设置默认值（0 表示不限制）
 	rateLimit, allowedPlatforms := normalizeCreateAPIKeyInput(req)
+	maxConcurrency, concurrencyWaitMs := normalizeConcurrency(req.MaxConcurrency, req.ConcurrencyWaitMs)
 
 	apiKey := &model.APIKey{
-		Name:             req.Name,
-		Description:      req.Description,
-		KeyHash:          hash,
-		KeyPrefix:        prefix,
-		Status:           "active",
-		AllowedPlatforms: allowedPlatforms,
-		AllowedModels:    req.AllowedModels,
-		BlockedModels:    req.BlockedModels,
-		AllowedClients:   req.AllowedClients,
-		RateLimit:        rateLimit,
-		DailyLimit:       req.DailyLimit,
-		MonthlyQuota:     req.MonthlyQuota,
-		ExpiresAt:        req.ExpiresAt,
+		Name:                req.Name,
+		Description:         req.Description,
+		KeyHash:             hash,
+		KeyPrefix:           prefix,
+		Status:              "active",
+		AllowedPlatforms:    allowedPlatforms,
+		AllowedModels:       req.AllowedModels,
+		BlockedModels:       req.BlockedModels,
+		AllowedClients:      req.AllowedClients,
+		AllowedIPs:          strings.TrimSpace(req.AllowedIPs),
+		AllowedCountries:    strings.ToUpper(strings.TrimSpace(req.AllowedCountries)),
+		BlockedCountries:    strings.ToUpper(strings.TrimSpace(req.BlockedCountries)),
+		RateLimit:           rateLimit,
+		DailyLimit:          req.DailyLimit,
+		MonthlyQuota:        req.MonthlyQuota,
+		MaxConcurrency:      maxConcurrency,
+		ConcurrencyWaitMs:   concurrencyWaitMs,
+		TPMLimit:            normalizeTokenLimit(req.TPMLimit),
+		ITPMLimit:           normalizeTokenLimit(req.ITPMLimit),
+		OTPMLimit:           normalizeTokenLimit(req.OTPMLimit),
+		PreferredLocale:     model.NormalizeLocale(req.PreferredLocale),
+		QueuePriority:       req.QueuePriority,
+		QueueWeight:         normalizeQueueWeight(req.QueueWeight),
+		ExpiresAt:           req.ExpiresAt,
+		HedgeDelayMs:        normalizeHedgeDelay(req.HedgeDelayMs),
+		StreamResume:        req.StreamResume,
+		PromptCacheOptimize: req.PromptCacheOptimize,
+		NoLogBodies:         req.NoLogBodies,
+		MaskRequestBody:     req.MaskRequestBody,
 	}
 
 	if err := s.repo.Create(apiKey); err != nil {
@@ -160,11 +262,7 @@ func (s *APIKeyService) ToggleStatus(id uint) (*model.APIKey, error) {
 	}
 
 	oldStatus := key.Status
-	if key.Status == "active" {
-		key.Status = "disabled"
-	} else {
-		key.Status = "active"
-	}
+	key.Status = toggledAPIKeyStatus(key.Status)
 
 	if err := s.repo.Update(key); err != nil {
 		getAPIKeyLog().Error("[apikey] 切换 API Key 状态失败 | KeyID: %d | 原因: 更新失败: %v", id, err)
@@ -175,6 +273,48 @@ func (s *APIKeyService) ToggleStatus(id uint) (*model.APIKey, error) {
 	return key, nil
 }
 
+// SetStatus 将 API Key 设置为指定状态（active / disabled），状态相同时不更新
+// 与 ToggleStatus 不同，结果不依赖当前状态：禁用已被暂停（suspended）的 Key 不会将其恢复
+func (s *APIKeyService) SetStatus(id uint, status string) (*model.APIKey, error) {
+	key, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	oldStatus := key.Status
+	changed, err := applyAPIKeyStatus(key, status)
+	if err != nil || !changed {
+		return key, err
+	}
+	if err := s.repo.Update(key); err != nil {
+		getAPIKeyLog().Error("[apikey] 设置 API Key 状态失败 | KeyID: %d | 原因: 更新失败: %v", id, err)
+		return nil, err
+	}
+
+	getAPIKeyLog().Info("[apikey] 设置 API Key 状态成功 | KeyID: %d | %s -> %s", id, oldStatus, key.Status)
+	return key, nil
+}
+
+// toggledAPIKeyStatus 切换后的状态：启用中的 Key 禁用，已禁用或已暂停的 Key 恢复启用
+func toggledAPIKeyStatus(status string) string {
+	if status == "active" {
+		return "disabled"
+	}
+	return "active"
+}
+
+// applyAPIKeyStatus 校验并设置目标状态，返回状态是否变化
+func applyAPIKeyStatus(key *model.APIKey, status string) (bool, error) {
+	if status != "active" && status != "disabled" {
+		return false, errors.New("无效的状态，仅支持 active 或 disabled")
+	}
+	if key.Status == status {
+		return false, nil
+	}
+	key.Status = status
+	return true, nil
+}
+
 // ValidateKey 验证 API Key 并返回 Key 信息
 func (s *APIKeyService) ValidateKey(keyStr string) (*model.APIKey, error) {
 	hash := model.HashAPIKey(keyStr)
@@ -187,6 +327,9 @@ func (s *APIKeyService) ValidateKey(keyStr string) (*model.APIKey, error) {
 		if key.Status == "disabled" {
 			return nil, errors.New("API Key 已被禁用")
 		}
+		if key.Status == model.APIKeyStatusSuspended {
+			return nil, errors.New("API Key 因异常使用已被暂停，请联系管理员")
+		}
 		if key.IsExpired() {
 			return nil, errors.New("API Key 已过期")
 		}
@@ -203,21 +346,44 @@ func (s *APIKeyService) IncrementUsage(id uint, tokens int64, cost float64) erro
 
 // UpdateAPIKeyRequest 更新 API Key 请求
 type UpdateAPIKeyRequest struct {
-	Name             string     `json:"name"`
-	Description      string     `json:"description"`
-	AllowedPlatforms string     `json:"allowed_platforms"`
-	AllowedModels    string     `json:"allowed_models"`
-	BlockedModels    string     `json:"blocked_models"`
-	AllowedClients   string     `json:"allowed_clients"`
-	RateLimit        int        `json:"rate_limit"`
-	DailyLimit       int        `json:"daily_limit"`
-	MonthlyQuota     float64    `json:"monthly_quota"`
-	ExpiresAt        *time.Time `json:"expires_at"`
-	Status           string     `json:"status"`
+	Name                string     `json:"name"`
+	Description         string     `json:"description"`
+	AllowedPlatforms    string     `json:"allowed_platforms"`
+	AllowedModels       string     `json:"allowed_models"`
+	BlockedModels       string     `json:"blocked_models"`
+	AllowedClients      string     `json:"allowed_clients"`
+	AllowedIPs          string     `json:"allowed_ips"`
+	AllowedCountries    string     `json:"allowed_countries"`
+	BlockedCountries    string     `json:"blocked_countries"`
+	RateLimit           int        `json:"rate_limit"`
+	DailyLimit          int        `json:"daily_limit"`
+	MonthlyQuota        float64    `json:"monthly_quota"`
+	MaxConcurrency      int        `json:"max_concurrency"`
+	ConcurrencyWaitMs   int        `json:"concurrency_wait_ms"`
+	TPMLimit            int        `json:"tpm_limit"`
+	ITPMLimit           int        `json:"itpm_limit"`
+	OTPMLimit           int        `json:"otpm_limit"`
+	PreferredLocale     string     `json:"preferred_locale"`
+	QueuePriority       int        `json:"queue_priority"`
+	QueueWeight         int        `json:"queue_weight"`
+	ExpiresAt           *time.Time `json:"expires_at"`
+	HedgeDelayMs        int        `json:"hedge_delay_ms"`
+	StreamResume        bool       `json:"stream_resume"`
+	PromptCacheOptimize bool       `json:"prompt_cache_optimize"`
+	NoLogBodies         bool       `json:"no_log_bodies"`
+	MaskRequestBody     bool       `json:"mask_request_body"`
+	Status              string     `json:"status"`
 }
 
 // Update 更新 API Key
 func (s *APIKeyService) Update(id uint, req *UpdateAPIKeyRequest) (*model.APIKey, error) {
+	if err := ValidateAPIKeySourceRules(req.AllowedIPs, req.AllowedCountries, req.BlockedCountries); err != nil {
+		return nil, err
+	}
+	if err := validatePreferredLocale(req.PreferredLocale); err != nil {
+		return nil, err
+	}
+
 	key, err := s.repo.GetByID(id)
 	if err != nil {
 		return nil, err
@@ -237,6 +403,9 @@ func (s *APIKeyService) Update(id uint, req *UpdateAPIKeyRequest) (*model.APIKey
 	key.AllowedModels = req.AllowedModels
 	key.BlockedModels = req.BlockedModels
 	key.AllowedClients = req.AllowedClients
+	key.AllowedIPs = strings.TrimSpace(req.AllowedIPs)
+	key.AllowedCountries = strings.ToUpper(strings.TrimSpace(req.AllowedCountries))
+	key.BlockedCountries = strings.ToUpper(strings.TrimSpace(req.BlockedCountries))
 
 	if req.RateLimit < 0 {
 		key.RateLimit = 0
@@ -245,7 +414,19 @@ func (s *APIKeyService) Update(id uint, req *UpdateAPIKeyRequest) (*model.APIKey
 	}
 	key.DailyLimit = req.DailyLimit
 	key.MonthlyQuota = req.MonthlyQuota
+	key.MaxConcurrency, key.ConcurrencyWaitMs = normalizeConcurrency(req.MaxConcurrency, req.ConcurrencyWaitMs)
+	key.TPMLimit = normalizeTokenLimit(req.TPMLimit)
+	key.ITPMLimit = normalizeTokenLimit(req.ITPMLimit)
+	key.OTPMLimit = normalizeTokenLimit(req.OTPMLimit)
+	key.PreferredLocale = model.NormalizeLocale(req.PreferredLocale)
+	key.QueuePriority = req.QueuePriority
+	key.QueueWeight = normalizeQueueWeight(req.QueueWeight)
 	key.ExpiresAt = req.ExpiresAt
+	key.HedgeDelayMs = normalizeHedgeDelay(req.HedgeDelayMs)
+	key.StreamResume = req.StreamResume
+	key.PromptCacheOptimize = req.PromptCacheOptimize
+	key.NoLogBodies = req.NoLogBodies
+	key.MaskRequestBody = req.MaskRequestBody
 
 	if req.Status != "" {
 		key.Status = req.Status
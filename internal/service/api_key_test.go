@@ -1,6 +1,10 @@
 package service
 
-import "testing"
+import (
+	"testing"
+
+	"cli-proxy/internal/model"
+)
 
 func TestNormalizeCreateAPIKeyInput(t *testing.T) {
 	rate, platforms := normalizeCreateAPIKeyInput(&CreateAPIKeyRequest{
@@ -25,3 +29,27 @@ func TestNormalizeCreateAPIKeyInput(t *testing.T) {
 		t.Fatalf("expected platforms openai, got %q", platforms)
 	}
 }
+
+func TestAPIKeyStatusChanges(t *testing.T) {
+	// 禁用被自动暂停的 Key：结果为 disabled，不会像切换接口那样恢复为 active
+	key := &model.APIKey{Status: model.APIKeyStatusSuspended}
+	if changed, err := applyAPIKeyStatus(key, "disabled"); err != nil || !changed || key.Status != "disabled" {
+		t.Fatalf("expected suspended key disabled, got %q, %v, %v", key.Status, changed, err)
+	}
+	if changed, err := applyAPIKeyStatus(key, "disabled"); err != nil || changed {
+		t.Fatalf("expected no change for same status, got %v, %v", changed, err)
+	}
+	if _, err := applyAPIKeyStatus(key, model.APIKeyStatusSuspended); err == nil || key.Status != "disabled" {
+		t.Fatal("expected invalid target status rejected")
+	}
+
+	for current, want := range map[string]string{
+		"active":                    "disabled",
+		"disabled":                  "active",
+		model.APIKeyStatusSuspended: "active",
+	} {
+		if got := toggledAPIKeyStatus(current); got != want {
+			t.Errorf("toggle %q = %q, want %q", current, got, want)
+		}
+	}
+}
@@ -4,8 +4,9 @@
  *   - 缓存统计信息获取
  *   - 会话缓存管理
  *   - 账户缓存管理
- *   - 并发计数管理
+ *   - 并发计数管理（账户、API Key）
  *   - 不可用账户标记管理
+ *   - 响应缓存管理
  * 重要程度：⭐⭐⭐⭐ 重要（缓存管理核心）
  * 依赖模块：cache, repository, model
  */
@@ -140,6 +141,69 @@ func (s *CacheService) ResetAccountConcurrency(ctx context.Context, accountID ui
 	return s.sessionCache.ResetAccountConcurrency(ctx, accountID)
 }
 
+// ==================== API Key 并发控制 ====================
+
+// AcquireAPIKeyConcurrency 获取 API Key 并发槽位，已满时最多排队等待 wait
+func (s *CacheService) AcquireAPIKeyConcurrency(ctx context.Context, apiKeyID uint, limit int, wait time.Duration) (bool, int64, error) {
+	return s.sessionCache.AcquireAPIKeyConcurrency(ctx, apiKeyID, limit, wait)
+}
+
+// ReleaseAPIKeyConcurrency 释放 API Key 并发槽位
+func (s *CacheService) ReleaseAPIKeyConcurrency(ctx context.Context, apiKeyID uint) error {
+	return s.sessionCache.ReleaseAPIKeyConcurrency(ctx, apiKeyID)
+}
+
+// ResetAPIKeyConcurrency 重置 API Key 并发计数（用于清理异常残留的槽位）
+func (s *CacheService) ResetAPIKeyConcurrency(ctx context.Context, apiKeyID uint) error {
+	return s.sessionCache.ResetAPIKeyConcurrency(ctx, apiKeyID)
+}
+
+// APIKeyConcurrencyInfo API Key 实时并发信息
+type APIKeyConcurrencyInfo struct {
+	APIKeyID       uint   `json:"api_key_id"`
+	Name           string `json:"name"`
+	KeyPrefix      string `json:"key_prefix"`
+	InFlight       int64  `json:"in_flight"`       // 进行中的请求数
+	Waiting        int64  `json:"waiting"`         // 排队等待的请求数
+	MaxConcurrency int    `json:"max_concurrency"` // 并发上限（0=不限）
+}
+
+// ListAPIKeyConcurrency 列出当前有进行中或排队请求的 API Key
+func (s *CacheService) ListAPIKeyConcurrency(ctx context.Context) ([]APIKeyConcurrencyInfo, error) {
+	stats := s.sessionCache.ListAPIKeyConcurrency()
+	ids := make([]uint, 0, len(stats))
+	for _, stat := range stats {
+		ids = append(ids, stat.APIKeyID)
+	}
+
+	keyMap := make(map[uint]model.APIKey, len(ids))
+	if len(ids) > 0 {
+		keys, err := repository.NewAPIKeyRepository().GetByIDs(ids)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			keyMap[key.ID] = key
+		}
+	}
+
+	result := make([]APIKeyConcurrencyInfo, 0, len(stats))
+	for _, stat := range stats {
+		info := APIKeyConcurrencyInfo{
+			APIKeyID: stat.APIKeyID,
+			InFlight: stat.InFlight,
+			Waiting:  stat.Waiting,
+		}
+		if key, ok := keyMap[stat.APIKeyID]; ok {
+			info.Name = key.Name
+			info.KeyPrefix = key.KeyPrefix
+			info.MaxConcurrency = key.MaxConcurrency
+		}
+		result = append(result, info)
+	}
+	return result, nil
+}
+
 // ==================== 缓存管理统计 ====================
 
 // CacheStats 缓存统计信息
@@ -186,6 +250,7 @@ const (
 	ClearCacheUsage       ClearCacheType = "usage" // 不再使用，数据在 MySQL
 	ClearCacheCost        ClearCacheType = "cost"  // 不再使用，数据在 MySQL
 	ClearCacheConcurrency ClearCacheType = "concurrency"
+	ClearCacheResponses   ClearCacheType = "responses"
 )
 
 // ClearCacheResult 清理结果
@@ -202,7 +267,7 @@ func (s *CacheService) ClearCache(ctx context.Context, cacheType ClearCacheType)
 	switch cacheType {
 	case ClearCacheAll:
 		cleared := s.memoryCache.ClearAll()
-		result.DeletedCount = int64(cleared["sessions"] + cleared["unavailable"])
+		result.DeletedCount = int64(cleared["sessions"] + cleared["unavailable"] + cleared["responses"])
 		return result, nil
 
 	case ClearCacheSessions:
@@ -215,6 +280,11 @@ func (s *CacheService) ClearCache(ctx context.Context, cacheType ClearCacheType)
 		result.DeletedCount = int64(count)
 		return result, nil
 
+	case ClearCacheResponses:
+		count := s.memoryCache.Responses.ClearAll()
+		result.DeletedCount = int64(count)
+		return result, nil
+
 	case ClearCacheUsage, ClearCacheCost:
 		// 使用量和费用数据已经在 MySQL 中，这里不需要清理
 		result.DeletedCount = 0
@@ -234,12 +304,37 @@ func (s *CacheService) ClearCache(ctx context.Context, cacheType ClearCacheType)
 
 // ClearAPIKeyCache 清理指定 API Key 的缓存
 func (s *CacheService) ClearAPIKeyCache(ctx context.Context, apiKeyID uint) (*ClearCacheResult, error) {
-	// API Key 缓存数据现在在 MySQL，这里只返回空结果
+	// API Key 用量数据现在在 MySQL，这里只清理该 Key 的响应缓存
 	result := &ClearCacheResult{Type: "apikey"}
-	result.DeletedCount = 0
+	result.DeletedCount = int64(s.memoryCache.Responses.Purge(apiKeyID, ""))
 	return result, nil
 }
 
+// ==================== 响应缓存管理 ====================
+
+// ResponseCacheEntry 响应缓存条目（复用 cache 包的定义）
+type ResponseCacheEntry = cache.ResponseCacheEntry
+
+// GetResponseCacheStats 获取响应缓存统计
+func (s *CacheService) GetResponseCacheStats() cache.ResponseCacheStats {
+	return s.memoryCache.Responses.Stats()
+}
+
+// ListResponseCache 列出响应缓存条目，apiKeyID 为 0 / model 为空表示不限
+func (s *CacheService) ListResponseCache(apiKeyID uint, model string, offset, limit int) ([]ResponseCacheEntry, int) {
+	return s.memoryCache.Responses.List(apiKeyID, model, offset, limit)
+}
+
+// DeleteResponseCache 删除指定响应缓存条目
+func (s *CacheService) DeleteResponseCache(key string) bool {
+	return s.memoryCache.Responses.Delete(key)
+}
+
+// PurgeResponseCache 按 API Key / 模型清理响应缓存
+func (s *CacheService) PurgeResponseCache(apiKeyID uint, model string) int {
+	return s.memoryCache.Responses.Purge(apiKeyID, model)
+}
+
 // ==================== 会话列表查询 ====================
 
 // SimpleAccountInfo 简单账号信息
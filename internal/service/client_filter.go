@@ -5,9 +5,9 @@
  *   - 过滤规则管理
  *   - 请求验证（Header/Body匹配）
  *   - 正则表达式缓存
- *   - 验证结果生成
+ *   - 验证结果生成（影子模式规则只记录不拦截）
  * 重要程度：⭐⭐⭐⭐ 重要（安全过滤核心）
- * 依赖模块：repository, model, logger
+ * 依赖模块：repository, model, filterexpr, logger
  */
 package service
 
@@ -18,6 +18,7 @@ import (
 	"strings"
 	"sync"
 
+	"cli-proxy/internal/filterexpr"
 	"cli-proxy/internal/model"
 	"cli-proxy/internal/repository"
 	"cli-proxy/pkg/logger"
@@ -27,6 +28,8 @@ import (
 type ClientFilterService struct {
 	repo   *repository.ClientFilterRepository
 	cache  *clientFilterCache
+	env    *filterexpr.Env    // 表达式编译环境
+	stats  *ruleStatsRecorder // 规则匹配统计
 	logger *logger.Logger
 }
 
@@ -38,6 +41,9 @@ type clientFilterCache struct {
 	rules       map[uint][]model.ClientFilterRule // key: client_type_id
 	regexCache  map[string]*regexp.Regexp         // 正则表达式缓存
 	regexMu     sync.RWMutex                      // 正则缓存专用锁
+	ruleSets    map[string]*compiledRuleSet       // key: set_key
+	ruleExprs   map[uint]compiledExpr             // 表达式规则，key: rule_id
+	matchExprs  map[uint]compiledExpr             // 客户端识别表达式，key: client_type_id
 }
 
 // ValidationResult 验证结果
@@ -49,6 +55,8 @@ type ValidationResult struct {
 	FailedRules  []RuleMatchResult `json:"failed_rules"`  // 失败的规则
 	Warnings     []string          `json:"warnings"`      // 警告信息
 	Details      map[string]string `json:"details"`       // 详细信息
+	// ShadowFailures 影子模式下未通过的规则（不影响放行，用于上线前观察）
+	ShadowFailures []RuleMatchResult `json:"shadow_failures"`
 }
 
 // RuleMatchResult 规则匹配结果
@@ -62,14 +70,23 @@ type RuleMatchResult struct {
 	Required    bool   `json:"required"`
 	Passed      bool   `json:"passed"`
 	Message     string `json:"message,omitempty"`
+	Mode        string `json:"mode,omitempty"`  // enforce / shadow
+	Error       string `json:"error,omitempty"` // 表达式编译/求值错误
 }
 
 // RequestContext 请求上下文（用于验证）
 type RequestContext struct {
-	UserAgent string                 `json:"user_agent"`
-	Headers   map[string]string      `json:"headers"`
-	Path      string                 `json:"path"`
-	Body      map[string]interface{} `json:"body"`
+	UserAgent  string                 `json:"user_agent"`
+	Headers    map[string]string      `json:"headers"`
+	Path       string                 `json:"path"`
+	Body       map[string]interface{} `json:"body"`
+	Method     string                 `json:"method"`
+	ClientIP   string                 `json:"ip"`
+	APIKeyID   uint                   `json:"api_key_id"`
+	APIKeyName string                 `json:"api_key_name"`
+
+	act    *filterexpr.Activation // 表达式求值上下文（按需创建）
+	dryRun bool                   // 试运行，不计入规则统计
 }
 
 var (
@@ -88,7 +105,9 @@ func GetClientFilterService() *ClientFilterService {
 				rules:       make(map[uint][]model.ClientFilterRule),
 				regexCache:  make(map[string]*regexp.Regexp),
 			},
+			stats: newRuleStatsRecorder(),
 		}
+		clientFilterService.env = clientFilterService.newExprEnv()
 		// 加载初始数据
 		clientFilterService.ReloadCache()
 	})
@@ -130,11 +149,19 @@ func (s *ClientFilterService) ReloadCache() error {
 		s.cache.rules[ct.ID] = rules
 	}
 
+	// 加载规则集并编译表达式
+	sets, err := s.repo.ListRuleSets()
+	if err != nil {
+		s.logger.Error("加载规则集失败: %v", err)
+		return err
+	}
+	s.compileCacheLocked(sets)
+
 	// 清空正则缓存（会重新编译）
 	s.cache.regexCache = make(map[string]*regexp.Regexp)
 
-	s.logger.Info("客户端过滤缓存已重新加载 | 客户端类型: %d | 规则总数: %d",
-		len(s.cache.clientTypes), s.countTotalRules())
+	s.logger.Info("客户端过滤缓存已重新加载 | 客户端类型: %d | 规则总数: %d | 规则集: %d",
+		len(s.cache.clientTypes), s.countTotalRules(), len(sets))
 
 	return nil
 }
@@ -164,11 +191,12 @@ func (s *ClientFilterService) GetConfig() *model.ClientFilterConfig {
 // ValidateRequest 验证请求
 func (s *ClientFilterService) ValidateRequest(ctx *RequestContext) *ValidationResult {
 	result := &ValidationResult{
-		Allowed:      true,
-		MatchedRules: make([]RuleMatchResult, 0),
-		FailedRules:  make([]RuleMatchResult, 0),
-		Warnings:     make([]string, 0),
-		Details:      make(map[string]string),
+		Allowed:        true,
+		MatchedRules:   make([]RuleMatchResult, 0),
+		FailedRules:    make([]RuleMatchResult, 0),
+		Warnings:       make([]string, 0),
+		Details:        make(map[string]string),
+		ShadowFailures: make([]RuleMatchResult, 0),
 	}
 
 	s.cache.RLock()
@@ -215,18 +243,31 @@ func (s *ClientFilterService) ValidateRequest(ctx *RequestContext) *ValidationRe
 	return result
 }
 
+// TestRequest 试运行验证（不计入规则统计）
+func (s *ClientFilterService) TestRequest(ctx *RequestContext) *ValidationResult {
+	ctx.dryRun = true
+	return s.ValidateRequest(ctx)
+}
+
+// IdentifyClient 仅识别客户端类型（不执行规则验证、不计入统计），未识别返回 unknown
+func (s *ClientFilterService) IdentifyClient(ctx *RequestContext) string {
+	if ct := s.identifyClientType(ctx); ct != nil {
+		return ct.ClientID
+	}
+	return model.ClientIDUnknown
+}
+
 // identifyClientType 识别客户端类型
 func (s *ClientFilterService) identifyClientType(ctx *RequestContext) *model.ClientType {
+	// 按优先级排序的客户端类型（在锁外匹配：表达式中的 ruleset() 会再次读取缓存）
 	s.cache.RLock()
-	defer s.cache.RUnlock()
-
-	// 按优先级排序的客户端类型
 	var sortedTypes []*model.ClientType
 	for _, ct := range s.cache.clientTypes {
 		if ct.Enabled {
 			sortedTypes = append(sortedTypes, ct)
 		}
 	}
+	s.cache.RUnlock()
 
 	// 按优先级排序（高优先级优先）
 	for i := 0; i < len(sortedTypes); i++ {
@@ -249,8 +290,17 @@ func (s *ClientFilterService) identifyClientType(ctx *RequestContext) *model.Cli
 
 // matchClientType 检查是否匹配特定客户端类型
 func (s *ClientFilterService) matchClientType(ctx *RequestContext, ct *model.ClientType) bool {
+	// 配置了识别表达式时优先使用表达式
+	if configured, matched := s.matchExpression(ctx, ct); configured {
+		return matched
+	}
+
 	// 根据过滤模式使用不同的 User-Agent 匹配规则
+	s.cache.RLock()
 	config := s.cache.config
+	rules := s.cache.rules[ct.ID]
+	s.cache.RUnlock()
+
 	filterMode := model.FilterModeSimple
 	if config != nil && config.FilterMode != "" {
 		filterMode = config.FilterMode
@@ -268,7 +318,6 @@ func (s *ClientFilterService) matchClientType(ctx *RequestContext, ct *model.Cli
 	}
 
 	// 其他客户端使用数据库中定义的规则
-	rules := s.cache.rules[ct.ID]
 	if len(rules) == 0 {
 		return false
 	}
@@ -295,36 +344,47 @@ func (s *ClientFilterService) validateRules(ctx *RequestContext, ct *model.Clien
 		filterMode = config.FilterMode
 	}
 
-	// Claude Code 使用内置规则集，根据模式选择
-	if ct.ClientID == model.ClientIDClaudeCode {
+	s.cache.RLock()
+	rules := s.cache.rules[ct.ID]
+	s.cache.RUnlock()
+
+	// Claude Code 未配置表达式规则时使用内置规则集，根据模式选择
+	if ct.ClientID == model.ClientIDClaudeCode && !hasExpressionRules(rules) {
 		s.validateClaudeCodeRules(ctx, filterMode, result)
 		return
 	}
 
 	// 其他客户端使用数据库中的规则
-	s.cache.RLock()
-	rules := s.cache.rules[ct.ID]
-	s.cache.RUnlock()
-
 	for _, rule := range rules {
 		if !rule.Enabled {
 			continue
 		}
 
 		ruleResult := s.validateSingleRule(ctx, &rule)
+		blocked := false
 
-		if ruleResult.Passed {
+		switch {
+		case ruleResult.Passed:
 			result.MatchedRules = append(result.MatchedRules, ruleResult)
-		} else {
+		case ruleResult.Mode == model.RuleModeShadow:
+			// 影子模式：只记录，不拦截
+			result.ShadowFailures = append(result.ShadowFailures, ruleResult)
+			result.Warnings = append(result.Warnings, "影子规则未通过: "+rule.RuleName)
+		default:
 			result.FailedRules = append(result.FailedRules, ruleResult)
 
 			if rule.Required {
+				blocked = true
 				result.Allowed = false
 				result.Details["reason"] = "规则验证失败: " + rule.RuleName
 			} else {
 				result.Warnings = append(result.Warnings, "可选规则未通过: "+rule.RuleName)
 			}
 		}
+
+		if !ctx.dryRun {
+			s.stats.record(ruleStatKey(rule.ID, ct.ClientID, rule.RuleKey), rule.ID, ct.ClientID, &ruleResult, blocked)
+		}
 	}
 }
 
@@ -455,6 +515,7 @@ func (s *ClientFilterService) validateClaudeCodeRules(ctx *RequestContext, filte
 			Required:    rule.Required,
 			Passed:      passed,
 			Message:     message,
+			Mode:        model.RuleModeEnforce,
 		}
 
 		if passed {
@@ -466,6 +527,10 @@ func (s *ClientFilterService) validateClaudeCodeRules(ctx *RequestContext, filte
 				result.Details["reason"] = "规则验证失败: " + rule.Name
 			}
 		}
+
+		if !ctx.dryRun {
+			s.stats.record(ruleStatKey(0, model.ClientIDClaudeCode, rule.Key), 0, model.ClientIDClaudeCode, &ruleResult, !passed && rule.Required)
+		}
 	}
 }
 
@@ -479,6 +544,10 @@ func (s *ClientFilterService) validateSingleRule(ctx *RequestContext, rule *mode
 		FieldPath: rule.FieldPath,
 		Required:  rule.Required,
 		Passed:    false,
+		Mode:      rule.Mode,
+	}
+	if result.Mode == "" {
+		result.Mode = model.RuleModeEnforce
 	}
 
 	switch rule.RuleType {
@@ -510,6 +579,9 @@ func (s *ClientFilterService) validateSingleRule(ctx *RequestContext, rule *mode
 
 	case model.RuleTypeCustom:
 		result.Passed = s.validateCustomRule(ctx, rule, &result)
+
+	case model.RuleTypeExpression:
+		result.Passed = s.evalExpressionRule(ctx, rule, &result)
 	}
 
 	// 如果 custom 规则已设置详细消息，不覆盖
@@ -770,6 +842,9 @@ func (s *ClientFilterService) GetClientType(id uint) (*model.ClientType, error)
 
 // UpdateClientType 更新客户端类型
 func (s *ClientFilterService) UpdateClientType(ct *model.ClientType) error {
+	if err := s.validateClientType(ct); err != nil {
+		return err
+	}
 	if err := s.repo.UpdateClientType(ct); err != nil {
 		return err
 	}
@@ -793,6 +868,9 @@ func (s *ClientFilterService) GetRule(id uint) (*model.ClientFilterRule, error)
 
 // UpdateRule 更新规则
 func (s *ClientFilterService) UpdateRule(rule *model.ClientFilterRule) error {
+	if err := s.normalizeRule(rule); err != nil {
+		return err
+	}
 	if err := s.repo.UpdateRule(rule); err != nil {
 		return err
 	}
@@ -801,6 +879,9 @@ func (s *ClientFilterService) UpdateRule(rule *model.ClientFilterRule) error {
 
 // CreateRule 创建规则
 func (s *ClientFilterService) CreateRule(rule *model.ClientFilterRule) error {
+	if err := s.normalizeRule(rule); err != nil {
+		return err
+	}
 	if err := s.repo.CreateRule(rule); err != nil {
 		return err
 	}
@@ -825,6 +906,9 @@ func (s *ClientFilterService) SaveConfig(config *model.ClientFilterConfig) error
 
 // CreateClientType 创建客户端类型
 func (s *ClientFilterService) CreateClientType(ct *model.ClientType) error {
+	if err := s.validateClientType(ct); err != nil {
+		return err
+	}
 	if err := s.repo.CreateClientType(ct); err != nil {
 		return err
 	}
@@ -0,0 +1,386 @@
+/*
+ * 文件作用：客户端过滤表达式规则、可复用规则集与规则匹配统计
+ * 负责功能：
+ *   - 表达式编译环境（ruleset() 引用规则集、claudeCodeSystemPrompt() 系统提示词相似度）
+ *   - 请求上下文转换为表达式变量（headers、body、key、ip、path、method、user_agent）
+ *   - 规则 / 规则集 / 客户端识别表达式的编译缓存与校验
+ *   - 按规则统计求值次数、通过、未通过、拦截、出错（含影子模式）
+ *   - 规则集管理与表达式试运行
+ * 重要程度：⭐⭐⭐⭐ 重要（客户端过滤规则引擎）
+ * 依赖模块：filterexpr, model, repository
+ */
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"cli-proxy/internal/filterexpr"
+	"cli-proxy/internal/model"
+)
+
+// ErrInvalidClientFilterRule 客户端过滤规则无效（表达式语法错误、模式错误等）
+var ErrInvalidClientFilterRule = errors.New("invalid client filter rule")
+
+// compiledExpr 编译后的表达式（编译失败时记录错误，求值时按未通过处理）
+type compiledExpr struct {
+	program *filterexpr.Program
+	err     error
+}
+
+// compiledRuleSet 编译后的规则集
+type compiledRuleSet struct {
+	set model.ClientRuleSet
+	compiledExpr
+}
+
+// newExprEnv 创建表达式编译环境
+func (s *ClientFilterService) newExprEnv() *filterexpr.Env {
+	return filterexpr.NewEnv(map[string]filterexpr.Func{
+		// ruleset("key") 求值指定规则集（禁用的规则集结果为 false）
+		"ruleset": func(act *filterexpr.Activation, args []any) (any, error) {
+			if len(args) != 1 {
+				return nil, fmt.Errorf("需要 1 个参数")
+			}
+			key, _ := args[0].(string)
+			s.cache.RLock()
+			cs := s.cache.ruleSets[key]
+			s.cache.RUnlock()
+			if cs == nil {
+				return nil, fmt.Errorf("规则集 %q 不存在", key)
+			}
+			if !cs.set.Enabled {
+				return false, nil
+			}
+			if cs.err != nil {
+				return nil, fmt.Errorf("规则集 %q 编译失败: %v", key, cs.err)
+			}
+			return cs.program.EvalBool(act)
+		},
+		// claudeCodeSystemPrompt(body.system) 系统提示词与 Claude Code 模板的相似度达到阈值（无系统提示词时为 true）
+		"claudeCodeSystemPrompt": func(_ *filterexpr.Activation, args []any) (any, error) {
+			if len(args) != 1 {
+				return nil, fmt.Errorf("需要 1 个参数")
+			}
+			ctx := &RequestContext{Body: map[string]interface{}{"system": args[0]}}
+			return s.validateClaudeCodeSystemPrompt(ctx, &RuleMatchResult{}), nil
+		},
+	})
+}
+
+// compileExpr 编译表达式
+func (s *ClientFilterService) compileExpr(src string) compiledExpr {
+	program, err := s.env.Compile(src)
+	return compiledExpr{program: program, err: err}
+}
+
+// compileCacheLocked 编译规则、规则集和客户端识别表达式（调用方持有写锁）
+func (s *ClientFilterService) compileCacheLocked(sets []model.ClientRuleSet) {
+	s.cache.ruleSets = make(map[string]*compiledRuleSet, len(sets))
+	for _, set := range sets {
+		s.cache.ruleSets[set.SetKey] = &compiledRuleSet{set: set, compiledExpr: s.compileExpr(set.Expression)}
+	}
+
+	s.cache.matchExprs = make(map[uint]compiledExpr)
+	for _, ct := range s.cache.clientTypes {
+		if strings.TrimSpace(ct.MatchExpression) != "" {
+			s.cache.matchExprs[ct.ID] = s.compileExpr(ct.MatchExpression)
+		}
+	}
+
+	s.cache.ruleExprs = make(map[uint]compiledExpr)
+	for _, rules := range s.cache.rules {
+		for _, rule := range rules {
+			if rule.RuleType == model.RuleTypeExpression {
+				s.cache.ruleExprs[rule.ID] = s.compileExpr(rule.Expression)
+			}
+		}
+	}
+}
+
+// activation 请求的表达式求值上下文（按需创建，同一请求内复用）
+func (ctx *RequestContext) activation() *filterexpr.Activation {
+	if ctx.act == nil {
+		ctx.act = filterexpr.NewActivation(ctx.exprVars())
+	}
+	return ctx.act
+}
+
+// exprVars 请求上下文转换为表达式变量（请求头名统一小写）
+func (ctx *RequestContext) exprVars() filterexpr.Vars {
+	headers := make(map[string]any, len(ctx.Headers))
+	for k, v := range ctx.Headers {
+		headers[strings.ToLower(k)] = v
+	}
+	userAgent := ctx.UserAgent
+	if userAgent == "" {
+		userAgent, _ = headers["user-agent"].(string)
+	}
+
+	vars := filterexpr.Vars{
+		"headers":    headers,
+		"body":       map[string]any(ctx.Body),
+		"user_agent": userAgent,
+		"path":       ctx.Path,
+		"method":     ctx.Method,
+		"ip":         ctx.ClientIP,
+		"key":        nil,
+	}
+	if ctx.APIKeyID != 0 {
+		vars["key"] = map[string]any{"id": ctx.APIKeyID, "name": ctx.APIKeyName}
+	}
+	return vars
+}
+
+// evalExpressionRule 求值表达式规则
+func (s *ClientFilterService) evalExpressionRule(ctx *RequestContext, rule *model.ClientFilterRule, result *RuleMatchResult) bool {
+	s.cache.RLock()
+	expr, ok := s.cache.ruleExprs[rule.ID]
+	s.cache.RUnlock()
+	if !ok {
+		// 未进入缓存的规则（如测试中的临时规则）即时编译
+		expr = s.compileExpr(rule.Expression)
+	}
+
+	result.Pattern = rule.Expression
+	if expr.err != nil {
+		result.Error = "表达式编译失败: " + expr.err.Error()
+		result.Message = result.Error
+		return false
+	}
+	passed, err := expr.program.EvalBool(ctx.activation())
+	if err != nil {
+		result.Error = err.Error()
+		result.Message = "表达式求值出错: " + err.Error()
+		return false
+	}
+	return passed
+}
+
+// matchExpression 按识别表达式判断客户端类型（返回是否配置了识别表达式）
+func (s *ClientFilterService) matchExpression(ctx *RequestContext, ct *model.ClientType) (configured, matched bool) {
+	s.cache.RLock()
+	expr, ok := s.cache.matchExprs[ct.ID]
+	s.cache.RUnlock()
+	if !ok {
+		return false, false
+	}
+	if expr.err != nil {
+		return true, false
+	}
+	passed, err := expr.program.EvalBool(ctx.activation())
+	return true, err == nil && passed
+}
+
+// hasExpressionRules 是否配置了启用的表达式规则（Claude Code 配置后使用数据库规则代替内置规则集）
+func hasExpressionRules(rules []model.ClientFilterRule) bool {
+	for _, rule := range rules {
+		if rule.Enabled && rule.RuleType == model.RuleTypeExpression {
+			return true
+		}
+	}
+	return false
+}
+
+// ==================== 校验 ====================
+
+// validateExpression 校验表达式语法
+func (s *ClientFilterService) validateExpression(src string) error {
+	if _, err := s.env.Compile(src); err != nil {
+		return fmt.Errorf("%w: 表达式错误: %v", ErrInvalidClientFilterRule, err)
+	}
+	return nil
+}
+
+// normalizeRule 校验并规范化过滤规则
+func (s *ClientFilterService) normalizeRule(rule *model.ClientFilterRule) error {
+	switch rule.Mode {
+	case "":
+		rule.Mode = model.RuleModeEnforce
+	case model.RuleModeEnforce, model.RuleModeShadow:
+	default:
+		return fmt.Errorf("%w: 无效的执行模式 %q（可选 enforce / shadow）", ErrInvalidClientFilterRule, rule.Mode)
+	}
+	if rule.RuleType == model.RuleTypeExpression {
+		return s.validateExpression(rule.Expression)
+	}
+	return nil
+}
+
+// validateClientType 校验客户端识别表达式
+func (s *ClientFilterService) validateClientType(ct *model.ClientType) error {
+	if strings.TrimSpace(ct.MatchExpression) == "" {
+		return nil
+	}
+	return s.validateExpression(ct.MatchExpression)
+}
+
+// ==================== 规则集管理 ====================
+
+// ListRuleSets 获取所有规则集
+func (s *ClientFilterService) ListRuleSets() ([]model.ClientRuleSet, error) {
+	return s.repo.ListRuleSets()
+}
+
+// GetRuleSet 获取规则集
+func (s *ClientFilterService) GetRuleSet(id uint) (*model.ClientRuleSet, error) {
+	return s.repo.GetRuleSetByID(id)
+}
+
+// CreateRuleSet 创建规则集
+func (s *ClientFilterService) CreateRuleSet(set *model.ClientRuleSet) error {
+	if strings.TrimSpace(set.SetKey) == "" {
+		return fmt.Errorf("%w: set_key 不能为空", ErrInvalidClientFilterRule)
+	}
+	if err := s.validateExpression(set.Expression); err != nil {
+		return err
+	}
+	if err := s.repo.CreateRuleSet(set); err != nil {
+		return err
+	}
+	return s.ReloadCache()
+}
+
+// UpdateRuleSet 更新规则集
+func (s *ClientFilterService) UpdateRuleSet(set *model.ClientRuleSet) error {
+	if strings.TrimSpace(set.SetKey) == "" {
+		return fmt.Errorf("%w: set_key 不能为空", ErrInvalidClientFilterRule)
+	}
+	if err := s.validateExpression(set.Expression); err != nil {
+		return err
+	}
+	if err := s.repo.UpdateRuleSet(set); err != nil {
+		return err
+	}
+	return s.ReloadCache()
+}
+
+// DeleteRuleSet 删除规则集
+func (s *ClientFilterService) DeleteRuleSet(id uint) error {
+	if err := s.repo.DeleteRuleSet(id); err != nil {
+		return err
+	}
+	return s.ReloadCache()
+}
+
+// ExpressionTestResult 表达式试运行结果
+type ExpressionTestResult struct {
+	Passed bool   `json:"passed"`
+	Error  string `json:"error,omitempty"` // 求值错误（如类型不匹配、规则集不存在）
+}
+
+// TestExpression 对样例请求试运行表达式（不计入统计）
+func (s *ClientFilterService) TestExpression(expression string, ctx *RequestContext) (*ExpressionTestResult, error) {
+	program, err := s.env.Compile(expression)
+	if err != nil {
+		return nil, fmt.Errorf("%w: 表达式错误: %v", ErrInvalidClientFilterRule, err)
+	}
+	passed, err := program.EvalBool(ctx.activation())
+	if err != nil {
+		return &ExpressionTestResult{Error: err.Error()}, nil
+	}
+	return &ExpressionTestResult{Passed: passed}, nil
+}
+
+// ==================== 规则统计 ====================
+
+// RuleStats 规则匹配统计（进程内累计，重启或重置后清零）
+type RuleStats struct {
+	StatKey      string     `json:"stat_key"` // rule:<id> 或 builtin:<client_id>:<rule_key>
+	RuleID       uint       `json:"rule_id,omitempty"`
+	RuleKey      string     `json:"rule_key"`
+	RuleName     string     `json:"rule_name"`
+	ClientType   string     `json:"client_type"`
+	Mode         string     `json:"mode"`
+	Evaluated    int64      `json:"evaluated"` // 求值次数
+	Passed       int64      `json:"passed"`    // 通过次数
+	Failed       int64      `json:"failed"`    // 未通过次数（含出错）
+	Blocked      int64      `json:"blocked"`   // 因该规则拒绝请求的次数（影子模式恒为 0）
+	Errors       int64      `json:"errors"`    // 表达式编译/求值出错次数
+	LastFailedAt *time.Time `json:"last_failed_at,omitempty"`
+	LastFailure  string     `json:"last_failure,omitempty"` // 最近一次未通过的原因
+	Since        time.Time  `json:"since"`                  // 统计开始时间
+}
+
+// ruleStatsRecorder 规则统计
+type ruleStatsRecorder struct {
+	mu    sync.Mutex
+	stats map[string]*RuleStats
+}
+
+func newRuleStatsRecorder() *ruleStatsRecorder {
+	return &ruleStatsRecorder{stats: make(map[string]*RuleStats)}
+}
+
+// record 记录一次规则求值
+func (r *ruleStatsRecorder) record(statKey string, ruleID uint, clientType string, res *RuleMatchResult, blocked bool) {
+	now := time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	st, ok := r.stats[statKey]
+	if !ok {
+		st = &RuleStats{StatKey: statKey, RuleID: ruleID, Since: now}
+		r.stats[statKey] = st
+	}
+	st.RuleKey = res.RuleKey
+	st.RuleName = res.RuleName
+	st.ClientType = clientType
+	st.Mode = res.Mode
+	st.Evaluated++
+	if res.Passed {
+		st.Passed++
+		return
+	}
+	st.Failed++
+	if blocked {
+		st.Blocked++
+	}
+	if res.Error != "" {
+		st.Errors++
+	}
+	st.LastFailedAt = &now
+	st.LastFailure = truncateString(res.Message, 200)
+}
+
+// snapshot 统计快照（按 stat_key 排序）
+func (r *ruleStatsRecorder) snapshot() []RuleStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	list := make([]RuleStats, 0, len(r.stats))
+	for _, st := range r.stats {
+		list = append(list, *st)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].StatKey < list[j].StatKey })
+	return list
+}
+
+func (r *ruleStatsRecorder) reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stats = make(map[string]*RuleStats)
+}
+
+// ruleStatKey 规则统计 key
+func ruleStatKey(ruleID uint, clientType, ruleKey string) string {
+	if ruleID != 0 {
+		return fmt.Sprintf("rule:%d", ruleID)
+	}
+	return "builtin:" + clientType + ":" + ruleKey
+}
+
+// GetRuleStats 获取规则匹配统计
+func (s *ClientFilterService) GetRuleStats() []RuleStats {
+	return s.stats.snapshot()
+}
+
+// ResetRuleStats 重置规则匹配统计
+func (s *ClientFilterService) ResetRuleStats() {
+	s.stats.reset()
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+
+	"cli-proxy/internal/model"
+)
+
+func newTestClientFilterService(types []model.ClientType, rules []model.ClientFilterRule) *ClientFilterService {
+	s := &ClientFilterService{
+		cache: &clientFilterCache{
+			config:      &model.ClientFilterConfig{FilterEnabled: true, DefaultAllow: true, FilterMode: model.FilterModeSimple},
+			clientTypes: make(map[string]*model.ClientType),
+			rules:       make(map[uint][]model.ClientFilterRule),
+			regexCache:  make(map[string]*regexp.Regexp),
+		},
+		stats: newRuleStatsRecorder(),
+	}
+	s.env = s.newExprEnv()
+	for i := range types {
+		s.cache.clientTypes[types[i].ClientID] = &types[i]
+	}
+	for _, rule := range rules {
+		s.cache.rules[rule.ClientTypeID] = append(s.cache.rules[rule.ClientTypeID], rule)
+	}
+	s.compileCacheLocked(model.DefaultClientRuleSets)
+	return s
+}
+
+func codexRequest() *RequestContext {
+	return &RequestContext{
+		UserAgent: "codex_cli_rs/0.20.0 (Mac OS 14.5.0; arm64)",
+		Headers: map[string]string{
+			"Originator": "codex_cli_rs",
+			"session_id": "0198f0a1-2b3c-4d5e-8f90-abcdef123456",
+		},
+		Path:       "/openai/responses",
+		Method:     "POST",
+		ClientIP:   "10.0.0.8",
+		APIKeyID:   3,
+		APIKeyName: "ci",
+		Body:       map[string]interface{}{"instructions": "You are Codex, based on GPT-5."},
+	}
+}
+
+func TestDefaultRuleSetsCompile(t *testing.T) {
+	s := newTestClientFilterService(nil, nil)
+	for key, cs := range s.cache.ruleSets {
+		if cs.err != nil {
+			t.Fatalf("default rule set %s: %v", key, cs.err)
+		}
+	}
+}
+
+func TestExpressionRulesAndShadowMode(t *testing.T) {
+	types := []model.ClientType{{
+		ID: 1, ClientID: model.ClientIDCodexCLI, Name: "Codex", Enabled: true, Priority: 10,
+		MatchExpression: `user_agent.startsWith("codex_")`,
+	}}
+	rules := []model.ClientFilterRule{
+		{ID: 11, ClientTypeID: 1, RuleKey: "codex_set", RuleName: "Codex 规则集", RuleType: model.RuleTypeExpression,
+			Expression: `ruleset("codex_cli") && key.name == "ci"`, Required: true, Enabled: true, Mode: model.RuleModeEnforce},
+		{ID: 12, ClientTypeID: 1, RuleKey: "office_ip", RuleName: "办公网段", RuleType: model.RuleTypeExpression,
+			Expression: `inCIDR(ip, "192.168.0.0/16")`, Required: true, Enabled: true, Mode: model.RuleModeShadow},
+	}
+	s := newTestClientFilterService(types, rules)
+
+	result := s.ValidateRequest(codexRequest())
+	if !result.Allowed || result.ClientType != model.ClientIDCodexCLI {
+		t.Fatalf("expected codex request allowed, got %+v", result)
+	}
+	if len(result.MatchedRules) != 1 || len(result.ShadowFailures) != 1 || len(result.FailedRules) != 0 {
+		t.Fatalf("unexpected rule results: %+v", result)
+	}
+
+	// 规则集不满足（缺少 session_id）时必选规则拦截
+	req := codexRequest()
+	delete(req.Headers, "session_id")
+	if result := s.ValidateRequest(req); result.Allowed {
+		t.Fatalf("expected request blocked, got %+v", result)
+	}
+
+	// 试运行不计入统计
+	s.TestRequest(codexRequest())
+
+	stats := map[string]RuleStats{}
+	for _, st := range s.GetRuleStats() {
+		stats[st.StatKey] = st
+	}
+	if st := stats["rule:11"]; st.Evaluated != 2 || st.Passed != 1 || st.Blocked != 1 {
+		t.Fatalf("unexpected enforce stats: %+v", st)
+	}
+	if st := stats["rule:12"]; st.Evaluated != 2 || st.Failed != 2 || st.Blocked != 0 || st.Mode != model.RuleModeShadow {
+		t.Fatalf("unexpected shadow stats: %+v", st)
+	}
+
+	s.ResetRuleStats()
+	if len(s.GetRuleStats()) != 0 {
+		t.Fatalf("expected stats reset")
+	}
+}
+
+func TestNormalizeRuleAndTestExpression(t *testing.T) {
+	s := newTestClientFilterService(nil, nil)
+
+	rule := &model.ClientFilterRule{RuleType: model.RuleTypeExpression, Expression: `has(headers["x-app"]`}
+	if err := s.normalizeRule(rule); !errors.Is(err, ErrInvalidClientFilterRule) {
+		t.Fatalf("expected invalid rule error, got %v", err)
+	}
+	rule = &model.ClientFilterRule{RuleType: model.RuleTypeHeader, Mode: "report"}
+	if err := s.normalizeRule(rule); !errors.Is(err, ErrInvalidClientFilterRule) {
+		t.Fatalf("expected invalid mode error, got %v", err)
+	}
+	rule = &model.ClientFilterRule{RuleType: model.RuleTypeExpression, Expression: `method == "POST"`}
+	if err := s.normalizeRule(rule); err != nil || rule.Mode != model.RuleModeEnforce {
+		t.Fatalf("expected default enforce mode, got %q %v", rule.Mode, err)
+	}
+
+	res, err := s.TestExpression(`ruleset("codex_cli") && key.id == 3`, codexRequest())
+	if err != nil || !res.Passed {
+		t.Fatalf("expected expression to pass, got %+v %v", res, err)
+	}
+	res, err = s.TestExpression(`ruleset("missing")`, codexRequest())
+	if err != nil || res.Passed || res.Error == "" {
+		t.Fatalf("expected evaluation error, got %+v %v", res, err)
+	}
+}
@@ -0,0 +1,641 @@
+/*
+ * 文件作用：客户端指纹分析服务
+ * 负责功能：
+ *   - 异步收集每个请求的客户端指纹（客户端类型、UA 版本、操作系统、来源 IP）
+ *   - 按 API Key、小时聚合统计并定期批量写入
+ *   - 异常检测：新客户端指纹、窗口内 IP 过多、多个指纹同时活跃（疑似共享/泄露）
+ *   - 告警落库、Webhook 推送，可选自动暂停 Key
+ *   - 统计查询、告警确认
+ * 重要程度：⭐⭐⭐ 一般（安全分析）
+ * 依赖模块：repository, model, logger
+ */
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"cli-proxy/internal/model"
+	"cli-proxy/internal/repository"
+	"cli-proxy/pkg/logger"
+)
+
+const (
+	// 观测队列容量（满时丢弃，不阻塞请求）
+	fingerprintQueueSize = 4096
+	// 统计写入间隔（查询结果最多延迟该时长）
+	fingerprintFlushInterval = time.Minute
+	// 过期统计清理间隔
+	fingerprintCleanupInterval = time.Hour
+	// 告警详情中最多列出的 IP / 指纹数
+	fingerprintDetailLimit = 20
+)
+
+// FingerprintConfig 客户端指纹异常检测配置
+type FingerprintConfig struct {
+	Window        time.Duration // 检测时间窗口（同时也是同类告警的冷却时间）
+	MaxIPs        int           // 窗口内不同 IP 数阈值（0=不检测）
+	MaxClients    int           // 窗口内同时活跃的指纹数阈值（0=不检测）
+	AlertNew      bool          // 新指纹告警
+	AutoSuspend   bool          // 严重告警时自动暂停 Key
+	WebhookURL    string
+	RetentionDays int
+}
+
+// FingerprintObservation 一次请求的客户端指纹
+type FingerprintObservation struct {
+	APIKeyID   uint
+	APIKeyName string
+	ClientType string
+	UserAgent  string // 原始 User-Agent（统计时只保留产品/版本部分）
+	OS         string
+	IP         string
+	Time       time.Time
+}
+
+// clientFingerprint 归一化后的客户端指纹
+type clientFingerprint struct {
+	Hash       string `json:"fingerprint"`
+	ClientType string `json:"client_type"`
+	UserAgent  string `json:"user_agent"`
+	OS         string `json:"os,omitempty"`
+}
+
+func (f *clientFingerprint) String() string {
+	s := f.ClientType + " " + f.UserAgent
+	if f.OS != "" {
+		s += " (" + f.OS + ")"
+	}
+	return s
+}
+
+// fingerprint 归一化：UA 只保留第一个产品/版本段（如 claude-cli/1.0.33），与客户端类型、操作系统一起计算哈希
+func (o *FingerprintObservation) fingerprint() clientFingerprint {
+	ua := strings.TrimSpace(o.UserAgent)
+	if i := strings.IndexAny(ua, " \t"); i > 0 {
+		ua = ua[:i]
+	}
+	f := clientFingerprint{
+		ClientType: limitBytes(o.ClientType, 50),
+		UserAgent:  limitBytes(ua, 191),
+		OS:         limitBytes(strings.TrimSpace(o.OS), 50),
+	}
+	if f.ClientType == "" {
+		f.ClientType = model.ClientIDUnknown
+	}
+	sum := sha256.Sum256([]byte(f.ClientType + "\x00" + f.UserAgent + "\x00" + f.OS))
+	f.Hash = hex.EncodeToString(sum[:16])
+	return f
+}
+
+// limitBytes 按字节截断（不截断多字节字符）
+func limitBytes(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// ============ 异常检测 ============
+
+// fingerprintFinding 检测到的异常
+type fingerprintFinding struct {
+	AlertType string
+	Severity  string
+	Message   string
+	Detail    map[string]interface{}
+}
+
+// keyActivity 单个 Key 在检测窗口内的活动
+type keyActivity struct {
+	known    map[string]bool               // 已知指纹（首次出现时从数据库加载）
+	ips      map[string]time.Time          // IP → 最近出现时间
+	clients  map[string]*activeFingerprint // 指纹哈希 → 最近活动
+	alerted  map[string]time.Time          // 告警类型 → 上次告警时间
+	lastSeen time.Time
+}
+
+type activeFingerprint struct {
+	fp       clientFingerprint
+	lastSeen time.Time
+}
+
+// fingerprintDetector 异常检测器（仅由后台协程访问，无需加锁）
+type fingerprintDetector struct {
+	keys map[uint]*keyActivity
+}
+
+func newFingerprintDetector() *fingerprintDetector {
+	return &fingerprintDetector{keys: make(map[uint]*keyActivity)}
+}
+
+// observe 记录一次观测并返回触发的异常；loadKnown 在 Key 首次出现时加载其历史指纹
+func (d *fingerprintDetector) observe(obs *FingerprintObservation, fp clientFingerprint, cfg FingerprintConfig,
+	loadKnown func(apiKeyID uint) map[string]bool) []fingerprintFinding {
+	now := obs.Time
+	act, ok := d.keys[obs.APIKeyID]
+	if !ok {
+		act = &keyActivity{
+			known:   loadKnown(obs.APIKeyID),
+			ips:     make(map[string]time.Time),
+			clients: make(map[string]*activeFingerprint),
+			alerted: make(map[string]time.Time),
+		}
+		if act.known == nil {
+			act.known = make(map[string]bool)
+		}
+		d.keys[obs.APIKeyID] = act
+	}
+	act.lastSeen = now
+	act.prune(now, cfg.Window)
+
+	var findings []fingerprintFinding
+
+	// 新指纹（Key 的第一个指纹不告警）
+	if !act.known[fp.Hash] {
+		hadKnown := len(act.known) > 0
+		act.known[fp.Hash] = true
+		if hadKnown && cfg.AlertNew {
+			findings = append(findings, fingerprintFinding{
+				AlertType: model.FingerprintAlertNewClient,
+				Severity:  model.FingerprintSeverityWarning,
+				Message:   fmt.Sprintf("出现新的客户端指纹: %s（IP: %s）", fp.String(), obs.IP),
+				Detail:    map[string]interface{}{"fingerprint": fp, "ip": obs.IP, "known_count": len(act.known) - 1},
+			})
+		}
+	}
+
+	if obs.IP != "" {
+		act.ips[obs.IP] = now
+	}
+	if c, ok := act.clients[fp.Hash]; ok {
+		c.lastSeen = now
+	} else {
+		act.clients[fp.Hash] = &activeFingerprint{fp: fp, lastSeen: now}
+	}
+
+	// 窗口内 IP 过多
+	if cfg.MaxIPs > 0 && len(act.ips) > cfg.MaxIPs && act.cooledDown(model.FingerprintAlertManyIPs, now, cfg.Window) {
+		findings = append(findings, fingerprintFinding{
+			AlertType: model.FingerprintAlertManyIPs,
+			Severity:  model.FingerprintSeverityCritical,
+			Message:   fmt.Sprintf("%d 分钟内出现 %d 个不同来源 IP（阈值 %d）", int(cfg.Window.Minutes()), len(act.ips), cfg.MaxIPs),
+			Detail:    map[string]interface{}{"ips": act.ipList(), "ip_count": len(act.ips)},
+		})
+	}
+
+	// 多个指纹同时活跃：疑似共享或泄露
+	if cfg.MaxClients > 0 && len(act.clients) > cfg.MaxClients && act.cooledDown(model.FingerprintAlertSharedKey, now, cfg.Window) {
+		findings = append(findings, fingerprintFinding{
+			AlertType: model.FingerprintAlertSharedKey,
+			Severity:  model.FingerprintSeverityCritical,
+			Message: fmt.Sprintf("%d 分钟内 %d 个客户端指纹同时活跃（阈值 %d），疑似 Key 被共享或泄露",
+				int(cfg.Window.Minutes()), len(act.clients), cfg.MaxClients),
+			Detail: map[string]interface{}{"fingerprints": act.clientList(), "ip_count": len(act.ips)},
+		})
+	}
+
+	return findings
+}
+
+// reset 清空 Key 的窗口活动（保留已知指纹），用于暂停后避免恢复时立即重复告警
+func (d *fingerprintDetector) reset(apiKeyID uint) {
+	if act, ok := d.keys[apiKeyID]; ok {
+		act.ips = make(map[string]time.Time)
+		act.clients = make(map[string]*activeFingerprint)
+	}
+}
+
+// prune 清理窗口外的活动，移除长时间无请求的 Key（下次出现时重新加载已知指纹）
+func (d *fingerprintDetector) prune(now time.Time, window time.Duration) {
+	for id, act := range d.keys {
+		if now.Sub(act.lastSeen) > window {
+			delete(d.keys, id)
+			continue
+		}
+		act.prune(now, window)
+	}
+}
+
+func (a *keyActivity) prune(now time.Time, window time.Duration) {
+	for ip, t := range a.ips {
+		if now.Sub(t) > window {
+			delete(a.ips, ip)
+		}
+	}
+	for h, c := range a.clients {
+		if now.Sub(c.lastSeen) > window {
+			delete(a.clients, h)
+		}
+	}
+}
+
+// cooledDown 同类告警在冷却时间内只触发一次
+func (a *keyActivity) cooledDown(alertType string, now time.Time, cooldown time.Duration) bool {
+	if last, ok := a.alerted[alertType]; ok && now.Sub(last) < cooldown {
+		return false
+	}
+	a.alerted[alertType] = now
+	return true
+}
+
+func (a *keyActivity) ipList() []string {
+	ips := make([]string, 0, len(a.ips))
+	for ip := range a.ips {
+		ips = append(ips, ip)
+	}
+	sort.Strings(ips)
+	if len(ips) > fingerprintDetailLimit {
+		ips = ips[:fingerprintDetailLimit]
+	}
+	return ips
+}
+
+func (a *keyActivity) clientList() []clientFingerprint {
+	list := make([]clientFingerprint, 0, len(a.clients))
+	for _, c := range a.clients {
+		list = append(list, c.fp)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Hash < list[j].Hash })
+	if len(list) > fingerprintDetailLimit {
+		list = list[:fingerprintDetailLimit]
+	}
+	return list
+}
+
+// ============ 服务 ============
+
+type fingerprintStatKey struct {
+	apiKeyID  uint
+	bucket    time.Time
+	dimension string
+	value     string
+}
+
+type fingerprintKey struct {
+	apiKeyID uint
+	hash     string
+}
+
+// ClientFingerprintService 客户端指纹分析服务
+type ClientFingerprintService struct {
+	repo          *repository.ClientFingerprintRepository
+	apiKeyRepo    *repository.APIKeyRepository
+	configService *ConfigService
+	httpClient    *http.Client
+	log           *logger.Logger
+
+	queue   chan *FingerprintObservation
+	dropped atomic.Int64 // 队列满被丢弃的观测数
+
+	// 以下字段仅由后台协程访问
+	detector     *fingerprintDetector
+	pendingStats map[fingerprintStatKey]int64
+	pendingFps   map[fingerprintKey]*model.APIKeyFingerprint
+	lastCleanup  time.Time
+
+	stopChan chan struct{}
+	doneChan chan struct{}
+	running  atomic.Bool
+	runMu    sync.Mutex
+}
+
+var (
+	clientFingerprintService     *ClientFingerprintService
+	clientFingerprintServiceOnce sync.Once
+)
+
+// GetClientFingerprintService 获取客户端指纹分析服务单例
+func GetClientFingerprintService() *ClientFingerprintService {
+	clientFingerprintServiceOnce.Do(func() {
+		clientFingerprintService = &ClientFingerprintService{
+			repo:          repository.NewClientFingerprintRepository(),
+			apiKeyRepo:    repository.NewAPIKeyRepository(),
+			configService: GetConfigService(),
+			httpClient:    &http.Client{Timeout: 10 * time.Second},
+			log:           logger.GetLogger("fingerprint"),
+			queue:         make(chan *FingerprintObservation, fingerprintQueueSize),
+			detector:      newFingerprintDetector(),
+			pendingStats:  make(map[fingerprintStatKey]int64),
+			pendingFps:    make(map[fingerprintKey]*model.APIKeyFingerprint),
+		}
+	})
+	return clientFingerprintService
+}
+
+// Start 启动后台处理协程
+func (s *ClientFingerprintService) Start() {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+	if s.running.Load() {
+		return
+	}
+	s.stopChan = make(chan struct{})
+	s.doneChan = make(chan struct{})
+	s.running.Store(true)
+	go s.run(s.stopChan, s.doneChan)
+}
+
+// Stop 停止后台协程（处理完队列中剩余的观测并写入统计）
+func (s *ClientFingerprintService) Stop() {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+	if !s.running.Load() {
+		return
+	}
+	s.running.Store(false)
+	close(s.stopChan)
+	<-s.doneChan
+}
+
+// Observe 提交一次观测（不阻塞，队列满或服务未启动时丢弃）
+func (s *ClientFingerprintService) Observe(obs *FingerprintObservation) {
+	if !s.running.Load() {
+		return
+	}
+	if obs.Time.IsZero() {
+		obs.Time = time.Now()
+	}
+	select {
+	case s.queue <- obs:
+	default:
+		if s.dropped.Add(1)%1000 == 1 {
+			s.log.Warn("客户端指纹观测队列已满，丢弃观测 | 累计丢弃: %d", s.dropped.Load())
+		}
+	}
+}
+
+func (s *ClientFingerprintService) run(stop, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(fingerprintFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case obs := <-s.queue:
+			s.process(obs)
+		case now := <-ticker.C:
+			s.flush()
+			cfg := s.configService.GetFingerprintConfig()
+			s.detector.prune(now, cfg.Window)
+			if now.Sub(s.lastCleanup) >= fingerprintCleanupInterval {
+				s.lastCleanup = now
+				s.cleanup(now, cfg.RetentionDays)
+			}
+		case <-stop:
+			for {
+				select {
+				case obs := <-s.queue:
+					s.process(obs)
+				default:
+					s.flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// process 累计统计并执行异常检测
+func (s *ClientFingerprintService) process(obs *FingerprintObservation) {
+	fp := obs.fingerprint()
+	bucket := obs.Time.Truncate(time.Hour)
+
+	for dim, value := range map[string]string{
+		model.FingerprintDimClientType: fp.ClientType,
+		model.FingerprintDimUserAgent:  fp.UserAgent,
+		model.FingerprintDimOS:         fp.OS,
+		model.FingerprintDimIP:         obs.IP,
+	} {
+		if value == "" {
+			continue
+		}
+		s.pendingStats[fingerprintStatKey{obs.APIKeyID, bucket, dim, limitBytes(value, 191)}]++
+	}
+
+	key := fingerprintKey{obs.APIKeyID, fp.Hash}
+	pending, ok := s.pendingFps[key]
+	if !ok {
+		pending = &model.APIKeyFingerprint{
+			APIKeyID:    obs.APIKeyID,
+			Fingerprint: fp.Hash,
+			ClientType:  fp.ClientType,
+			UserAgent:   fp.UserAgent,
+			OS:          fp.OS,
+			FirstIP:     obs.IP,
+			FirstSeenAt: obs.Time,
+		}
+		s.pendingFps[key] = pending
+	}
+	pending.RequestCount++
+	pending.LastSeenAt = obs.Time
+	pending.LastIP = obs.IP
+
+	cfg := s.configService.GetFingerprintConfig()
+	for _, f := range s.detector.observe(obs, fp, cfg, s.loadKnown) {
+		s.raiseAlert(obs, f, cfg)
+	}
+}
+
+// loadKnown 加载 Key 的已知指纹（包括尚未写入数据库的）
+func (s *ClientFingerprintService) loadKnown(apiKeyID uint) map[string]bool {
+	known := make(map[string]bool)
+	hashes, err := s.repo.ListFingerprintKeys(apiKeyID)
+	if err != nil {
+		s.log.Warn("加载已知客户端指纹失败 | KeyID: %d | Err: %v", apiKeyID, err)
+	}
+	for _, h := range hashes {
+		known[h] = true
+	}
+	for k := range s.pendingFps {
+		if k.apiKeyID == apiKeyID {
+			known[k.hash] = true
+		}
+	}
+	return known
+}
+
+// flush 写入累计的统计和指纹
+func (s *ClientFingerprintService) flush() {
+	if len(s.pendingStats) > 0 {
+		stats := make([]model.ClientFingerprintStat, 0, len(s.pendingStats))
+		for k, n := range s.pendingStats {
+			stats = append(stats, model.ClientFingerprintStat{
+				APIKeyID: k.apiKeyID, Bucket: k.bucket, Dimension: k.dimension, Value: k.value, RequestCount: n,
+			})
+		}
+		s.pendingStats = make(map[fingerprintStatKey]int64)
+		if err := s.repo.IncrementStats(stats); err != nil {
+			s.log.Warn("写入客户端指纹统计失败: %v", err)
+		}
+	}
+
+	if len(s.pendingFps) > 0 {
+		fps := make([]model.APIKeyFingerprint, 0, len(s.pendingFps))
+		for _, fp := range s.pendingFps {
+			fps = append(fps, *fp)
+		}
+		s.pendingFps = make(map[fingerprintKey]*model.APIKeyFingerprint)
+		if err := s.repo.UpsertFingerprints(fps); err != nil {
+			s.log.Warn("写入客户端指纹失败: %v", err)
+		}
+	}
+}
+
+// cleanup 清理过期的小时统计
+func (s *ClientFingerprintService) cleanup(now time.Time, retentionDays int) {
+	deleted, err := s.repo.DeleteStatsBefore(now.AddDate(0, 0, -retentionDays))
+	if err != nil {
+		s.log.Warn("清理客户端指纹统计失败: %v", err)
+		return
+	}
+	if deleted > 0 {
+		s.log.Info("已清理过期客户端指纹统计 | 行数: %d | 保留天数: %d", deleted, retentionDays)
+	}
+}
+
+// raiseAlert 记录告警，严重告警按配置自动暂停 Key，并推送 Webhook
+func (s *ClientFingerprintService) raiseAlert(obs *FingerprintObservation, f fingerprintFinding, cfg FingerprintConfig) {
+	detail, _ := json.Marshal(f.Detail)
+	alert := &model.FingerprintAlert{
+		APIKeyID:   obs.APIKeyID,
+		APIKeyName: obs.APIKeyName,
+		AlertType:  f.AlertType,
+		Severity:   f.Severity,
+		Message:    limitBytes(f.Message, 500),
+		Detail:     string(detail),
+	}
+
+	if cfg.AutoSuspend && f.Severity == model.FingerprintSeverityCritical {
+		suspended, err := s.apiKeyRepo.UpdateStatusIf(obs.APIKeyID, "active", model.APIKeyStatusSuspended)
+		if err != nil {
+			s.log.Error("自动暂停 API Key 失败 | KeyID: %d | Err: %v", obs.APIKeyID, err)
+		} else if suspended {
+			alert.Suspended = true
+			s.detector.reset(obs.APIKeyID)
+			s.log.Warn("API Key 已自动暂停 | KeyID: %d | 原因: %s", obs.APIKeyID, f.Message)
+		}
+	}
+
+	if err := s.repo.CreateAlert(alert); err != nil {
+		s.log.Error("记录客户端指纹告警失败 | KeyID: %d | Err: %v", obs.APIKeyID, err)
+	}
+	s.log.Warn("客户端指纹告警 | KeyID: %d (%s) | 类型: %s | %s", obs.APIKeyID, obs.APIKeyName, f.AlertType, f.Message)
+
+	if cfg.WebhookURL != "" {
+		go s.deliver(cfg.WebhookURL, alert)
+	}
+}
+
+// deliver 以 POST JSON 推送告警
+func (s *ClientFingerprintService) deliver(url string, alert *model.FingerprintAlert) {
+	body, _ := json.Marshal(alert)
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		s.log.Warn("推送客户端指纹告警失败: %v", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Alert-ID", strconv.FormatUint(uint64(alert.ID), 10))
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		s.log.Warn("推送客户端指纹告警失败 | AlertID: %d | Err: %v", alert.ID, err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
+		s.log.Warn("推送客户端指纹告警失败 | AlertID: %d | Webhook 返回 %d: %s", alert.ID, resp.StatusCode, bytes.TrimSpace(msg))
+	}
+}
+
+// ============ 查询 ============
+
+// FingerprintValueCount 维度取值的请求数
+type FingerprintValueCount struct {
+	Value        string `json:"value"`
+	RequestCount int64  `json:"request_count"`
+}
+
+// FingerprintStatsResult Key 的指纹统计
+type FingerprintStatsResult struct {
+	Since   time.Time                          `json:"since"`
+	Buckets []model.ClientFingerprintStat      `json:"buckets"` // 按小时的明细
+	Totals  map[string][]FingerprintValueCount `json:"totals"`  // 各维度汇总（请求数降序）
+}
+
+// GetStats 查询 Key 最近若干小时的指纹统计（最近一分钟内的请求可能尚未写入）
+func (s *ClientFingerprintService) GetStats(apiKeyID uint, dimension string, hours int) (*FingerprintStatsResult, error) {
+	if dimension != "" && !containsString(model.FingerprintDimensions, dimension) {
+		return nil, fmt.Errorf("无效的统计维度: %s", dimension)
+	}
+	since := time.Now().Add(-time.Duration(hours) * time.Hour).Truncate(time.Hour)
+	buckets, err := s.repo.ListStats(apiKeyID, dimension, since)
+	if err != nil {
+		return nil, err
+	}
+
+	sums := make(map[string]map[string]int64)
+	for _, b := range buckets {
+		if sums[b.Dimension] == nil {
+			sums[b.Dimension] = make(map[string]int64)
+		}
+		sums[b.Dimension][b.Value] += b.RequestCount
+	}
+	totals := make(map[string][]FingerprintValueCount, len(sums))
+	for dim, values := range sums {
+		list := make([]FingerprintValueCount, 0, len(values))
+		for v, n := range values {
+			list = append(list, FingerprintValueCount{Value: v, RequestCount: n})
+		}
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].RequestCount != list[j].RequestCount {
+				return list[i].RequestCount > list[j].RequestCount
+			}
+			return list[i].Value < list[j].Value
+		})
+		totals[dim] = list
+	}
+
+	return &FingerprintStatsResult{Since: since, Buckets: buckets, Totals: totals}, nil
+}
+
+// ListFingerprints 获取 Key 的已知客户端指纹
+func (s *ClientFingerprintService) ListFingerprints(apiKeyID uint) ([]model.APIKeyFingerprint, error) {
+	return s.repo.ListFingerprints(apiKeyID)
+}
+
+// ListAlerts 分页查询告警
+func (s *ClientFingerprintService) ListAlerts(f *repository.FingerprintAlertFilter, page, pageSize int) ([]model.FingerprintAlert, int64, error) {
+	return s.repo.ListAlerts(f, page, pageSize)
+}
+
+// AcknowledgeAlert 确认告警
+func (s *ClientFingerprintService) AcknowledgeAlert(id uint, operator string) error {
+	updated, err := s.repo.AcknowledgeAlert(id, operator)
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return errors.New("告警不存在或已确认")
+	}
+	return nil
+}
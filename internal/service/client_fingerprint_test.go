@@ -0,0 +1,94 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"cli-proxy/internal/model"
+)
+
+func TestFingerprintNormalize(t *testing.T) {
+	a := (&FingerprintObservation{ClientType: "claude_code", UserAgent: "claude-cli/1.0.33 (external, cli)", OS: "MacOS"}).fingerprint()
+	b := (&FingerprintObservation{ClientType: "claude_code", UserAgent: "claude-cli/1.0.33 (external, sdk-ts)", OS: "MacOS"}).fingerprint()
+	if a.UserAgent != "claude-cli/1.0.33" || a.Hash != b.Hash {
+		t.Fatalf("expected same fingerprint for same product/version, got %+v / %+v", a, b)
+	}
+	c := (&FingerprintObservation{ClientType: "claude_code", UserAgent: "claude-cli/1.0.34", OS: "MacOS"}).fingerprint()
+	if c.Hash == a.Hash {
+		t.Fatalf("expected different fingerprint for new version")
+	}
+	if u := (&FingerprintObservation{}).fingerprint(); u.ClientType != model.ClientIDUnknown {
+		t.Fatalf("expected unknown client type, got %q", u.ClientType)
+	}
+	if got := limitBytes("你好世界", 7); got != "你好" {
+		t.Fatalf("limitBytes = %q", got)
+	}
+}
+
+func TestFingerprintDetector(t *testing.T) {
+	cfg := FingerprintConfig{Window: time.Hour, MaxIPs: 3, MaxClients: 3, AlertNew: true}
+	known := map[uint]map[string]bool{}
+	loadKnown := func(id uint) map[string]bool { return known[id] }
+
+	d := newFingerprintDetector()
+	now := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	observe := func(keyID uint, ua, ip string, at time.Time) []fingerprintFinding {
+		obs := &FingerprintObservation{APIKeyID: keyID, ClientType: "claude_code", UserAgent: ua, OS: "Linux", IP: ip, Time: at}
+		return d.observe(obs, obs.fingerprint(), cfg, loadKnown)
+	}
+	types := func(findings []fingerprintFinding) []string {
+		var list []string
+		for _, f := range findings {
+			list = append(list, f.AlertType)
+		}
+		return list
+	}
+
+	// 第一个指纹不告警，重复出现不告警
+	if f := observe(1, "claude-cli/1.0.1", "10.0.0.1", now); len(f) != 0 {
+		t.Fatalf("unexpected findings: %v", types(f))
+	}
+	if f := observe(1, "claude-cli/1.0.1", "10.0.0.2", now.Add(time.Minute)); len(f) != 0 {
+		t.Fatalf("unexpected findings: %v", types(f))
+	}
+
+	// 新版本：新指纹告警
+	f := observe(1, "claude-cli/1.0.2", "10.0.0.2", now.Add(2*time.Minute))
+	if len(f) != 1 || f[0].AlertType != model.FingerprintAlertNewClient {
+		t.Fatalf("expected new fingerprint alert, got %v", types(f))
+	}
+
+	// 第 4 个 IP、第 4 个同时活跃的指纹：IP 过多 + 疑似共享
+	f = observe(1, "codex_cli_rs/0.20.0", "10.0.0.3", now.Add(3*time.Minute))
+	if len(f) != 1 || f[0].AlertType != model.FingerprintAlertNewClient {
+		t.Fatalf("expected only new fingerprint alert before thresholds, got %v", types(f))
+	}
+	f = observe(1, "curl/8.0", "10.0.0.4", now.Add(4*time.Minute))
+	if got := fmt.Sprint(types(f)); got != "[new_fingerprint many_ips shared_key]" {
+		t.Fatalf("unexpected findings: %s", got)
+	}
+	if f[1].Severity != model.FingerprintSeverityCritical {
+		t.Fatalf("expected critical severity")
+	}
+
+	// 冷却期内不重复告警
+	if f := observe(1, "curl/8.0", "10.0.0.5", now.Add(5*time.Minute)); len(f) != 0 {
+		t.Fatalf("expected cooldown, got %v", types(f))
+	}
+
+	// 窗口过期后活动被清理，历史指纹仍然已知
+	d.prune(now.Add(3*time.Hour), cfg.Window)
+	if len(d.keys) != 0 {
+		t.Fatalf("expected idle key pruned")
+	}
+	known[1] = map[string]bool{(&FingerprintObservation{ClientType: "claude_code", UserAgent: "claude-cli/1.0.1", OS: "Linux"}).fingerprint().Hash: true}
+	if f := observe(1, "claude-cli/1.0.1", "10.0.0.9", now.Add(3*time.Hour)); len(f) != 0 {
+		t.Fatalf("expected known fingerprint after reload, got %v", types(f))
+	}
+
+	// 其他 Key 互不影响
+	if f := observe(2, "curl/8.0", "10.0.0.1", now); len(f) != 0 {
+		t.Fatalf("unexpected findings for other key: %v", types(f))
+	}
+}
@@ -12,9 +12,15 @@
 package service
 
 import (
+	"cli-proxy/internal/egress"
 	"cli-proxy/internal/model"
+	"cli-proxy/internal/proxy/scheduler"
+	"cli-proxy/internal/proxypool"
 	"cli-proxy/internal/repository"
+	"cli-proxy/pkg/logger"
+	"encoding/json"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -210,6 +216,142 @@ func (s *ConfigService) GetLoginRateLimitWindow() int {
 	return val
 }
 
+// GetIPAutoBanEnabled 获取是否自动封禁认证失败过多的 IP
+func (s *ConfigService) GetIPAutoBanEnabled() bool {
+	return s.GetBool(model.ConfigIPAutoBanEnabled)
+}
+
+// GetIPAutoBanThreshold 获取自动封禁的认证失败次数阈值
+func (s *ConfigService) GetIPAutoBanThreshold() int {
+	val := s.GetInt(model.ConfigIPAutoBanThreshold)
+	if val <= 0 {
+		return 20 // 默认值
+	}
+	return val
+}
+
+// GetIPAutoBanWindow 获取认证失败统计时间窗口（分钟，计数器最多保留 30 分钟）
+func (s *ConfigService) GetIPAutoBanWindow() int {
+	val := s.GetInt(model.ConfigIPAutoBanWindow)
+	if val <= 0 {
+		return 5 // 默认值
+	}
+	if val > 30 {
+		return 30
+	}
+	return val
+}
+
+// GetIPAutoBanDuration 获取自动封禁时长
+func (s *ConfigService) GetIPAutoBanDuration() time.Duration {
+	val := s.GetInt(model.ConfigIPAutoBanDuration)
+	if val <= 0 {
+		return time.Hour // 默认 60 分钟
+	}
+	return time.Duration(val) * time.Minute
+}
+
+// ========== 客户端指纹分析配置便捷方法 ==========
+
+// GetFingerprintEnabled 获取是否统计客户端指纹并检测异常
+func (s *ConfigService) GetFingerprintEnabled() bool {
+	return s.GetBool(model.ConfigFingerprintEnabled)
+}
+
+// GetFingerprintConfig 获取客户端指纹异常检测配置
+func (s *ConfigService) GetFingerprintConfig() FingerprintConfig {
+	cfg := FingerprintConfig{
+		Window:        s.GetDuration(model.ConfigFingerprintWindow),
+		MaxIPs:        s.GetInt(model.ConfigFingerprintMaxIPs),
+		MaxClients:    s.GetInt(model.ConfigFingerprintMaxClients),
+		AlertNew:      s.GetBool(model.ConfigFingerprintAlertNew),
+		AutoSuspend:   s.GetBool(model.ConfigFingerprintAutoSuspend),
+		WebhookURL:    strings.TrimSpace(s.GetString(model.ConfigFingerprintWebhookURL)),
+		RetentionDays: s.GetInt(model.ConfigFingerprintRetentionDays),
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = time.Hour // 默认 60 分钟
+	}
+	if cfg.MaxIPs < 0 {
+		cfg.MaxIPs = 0
+	}
+	if cfg.MaxClients < 0 {
+		cfg.MaxClients = 0
+	}
+	if cfg.RetentionDays <= 0 {
+		cfg.RetentionDays = 30
+	}
+	return cfg
+}
+
+// ========== 代理池配置便捷方法 ==========
+
+// GetProxyHealthCheckEnabled 获取是否定期测试代理连通性
+func (s *ConfigService) GetProxyHealthCheckEnabled() bool {
+	return s.GetBool(model.ConfigProxyHealthCheckEnabled)
+}
+
+// GetProxyHealthCheckInterval 获取代理连通性测试间隔
+func (s *ConfigService) GetProxyHealthCheckInterval() time.Duration {
+	interval := s.GetDuration(model.ConfigProxyHealthCheckInterval)
+	if interval <= 0 {
+		return 5 * time.Minute
+	}
+	return interval
+}
+
+// GetProxyPoolSettings 获取代理池故障排除配置
+func (s *ConfigService) GetProxyPoolSettings() proxypool.Settings {
+	return proxypool.Settings{
+		FailureThreshold: s.GetInt(model.ConfigProxyFailureThreshold),
+		Cooldown:         s.GetDuration(model.ConfigProxyFailureCooldown),
+	}
+}
+
+// GetProxyEgressCheckEnabled 获取是否探测出口 IP 并跟踪账户出口 IP
+func (s *ConfigService) GetProxyEgressCheckEnabled() bool {
+	return s.GetBool(model.ConfigProxyEgressCheckEnabled)
+}
+
+// GetProxyEgressEchoURL 获取出口 IP 回显服务地址
+func (s *ConfigService) GetProxyEgressEchoURL() string {
+	if u := strings.TrimSpace(s.GetString(model.ConfigProxyEgressEchoURL)); u != "" {
+		return u
+	}
+	return egress.DefaultEchoURL
+}
+
+// IsProxyPoolConfig 是否为代理池相关配置
+func IsProxyPoolConfig(key string) bool {
+	return strings.HasPrefix(key, "proxy_")
+}
+
+// ========== 请求排队配置便捷方法 ==========
+
+// GetRequestQueueConfig 获取账户并发全满时的排队配置
+func (s *ConfigService) GetRequestQueueConfig() scheduler.QueueConfig {
+	cfg := scheduler.DefaultQueueConfig
+	if val := s.GetString(model.ConfigRequestQueueEnabled); val != "" {
+		cfg.Enabled = val == "true"
+	}
+	if val := s.GetInt(model.ConfigRequestQueueMaxWait); val > 0 {
+		cfg.MaxWait = time.Duration(val) * time.Second
+	}
+	if val := s.GetInt(model.ConfigRequestQueueMaxDepth); val > 0 {
+		cfg.MaxDepth = val
+	}
+	if val := s.GetString(model.ConfigRequestQueuePingInterval); val != "" {
+		interval, _ := strconv.Atoi(val)
+		cfg.PingInterval = time.Duration(interval) * time.Second
+	}
+	return cfg
+}
+
+// IsRequestQueueConfig 判断配置项是否属于请求排队配置
+func IsRequestQueueConfig(key string) bool {
+	return strings.HasPrefix(key, "request_queue_")
+}
+
 // ========== 账号健康检查配置便捷方法 ==========
 
 // GetAccountHealthCheckEnabled 获取是否启用账号健康检查
@@ -404,3 +546,118 @@ func (s *ConfigService) GetSyncOpenAIEnabled() bool {
 func (s *ConfigService) GetSyncGeminiEnabled() bool {
 	return s.GetBool(model.ConfigSyncGeminiEnabled)
 }
+
+// GetUsageReportEnabled 获取是否启用周期用量报表自动生成
+func (s *ConfigService) GetUsageReportEnabled() bool {
+	return s.GetBool(model.ConfigUsageReportEnabled)
+}
+
+// GetUsageReportPeriods 获取自动生成的报表周期（weekly/monthly，逗号分隔）
+func (s *ConfigService) GetUsageReportPeriods() []string {
+	var periods []string
+	for _, p := range strings.Split(s.GetString(model.ConfigUsageReportPeriods), ",") {
+		p = strings.TrimSpace(strings.ToLower(p))
+		if p == model.ReportPeriodWeekly || p == model.ReportPeriodMonthly {
+			periods = append(periods, p)
+		}
+	}
+	return periods
+}
+
+// GetUsageReportWebhookURL 获取报表推送 Webhook 地址
+func (s *ConfigService) GetUsageReportWebhookURL() string {
+	return strings.TrimSpace(s.GetString(model.ConfigUsageReportWebhookURL))
+}
+
+// GetUsageReportWebhookSecret 获取报表推送签名密钥
+func (s *ConfigService) GetUsageReportWebhookSecret() string {
+	return s.GetString(model.ConfigUsageReportWebhookSecret)
+}
+
+// GetRequestLogBodyRetentionDays 获取请求/响应体保留天数（0 表示不清理）
+func (s *ConfigService) GetRequestLogBodyRetentionDays() int {
+	if val := s.GetInt(model.ConfigRequestLogBodyRetentionDays); val > 0 {
+		return val
+	}
+	return 0
+}
+
+// GetRequestLogRetentionDays 获取请求日志保留天数（0 表示永久保留）
+func (s *ConfigService) GetRequestLogRetentionDays() int {
+	if val := s.GetInt(model.ConfigRequestLogRetentionDays); val > 0 {
+		return val
+	}
+	return 0
+}
+
+// GetRequestLogCompressBodies 获取是否压缩存储请求/响应体
+func (s *ConfigService) GetRequestLogCompressBodies() bool {
+	return s.GetBool(model.ConfigRequestLogCompressBodies)
+}
+
+// GetRequestLogArchiveEnabled 获取删除前是否归档
+func (s *ConfigService) GetRequestLogArchiveEnabled() bool {
+	return s.GetBool(model.ConfigRequestLogArchiveEnabled)
+}
+
+// GetRequestLogArchiveDir 获取请求日志归档目录
+func (s *ConfigService) GetRequestLogArchiveDir() string {
+	if dir := strings.TrimSpace(s.GetString(model.ConfigRequestLogArchiveDir)); dir != "" {
+		return dir
+	}
+	return "data/archive/request_logs"
+}
+
+// GetRequestLogPartitionEnabled 获取是否按月分区
+func (s *ConfigService) GetRequestLogPartitionEnabled() bool {
+	return s.GetBool(model.ConfigRequestLogPartitionEnabled)
+}
+
+// GetRequestLogRetentionInterval 获取清理任务间隔
+func (s *ConfigService) GetRequestLogRetentionInterval() time.Duration {
+	val := s.GetInt(model.ConfigRequestLogRetentionInterval)
+	if val <= 0 {
+		return time.Hour // 默认 60 分钟
+	}
+	return time.Duration(val) * time.Minute
+}
+
+// GetSystemLogRetention 获取系统日志默认保留策略（0 表示使用内置默认值）
+func (s *ConfigService) GetSystemLogRetention() logger.RetentionPolicy {
+	return logger.RetentionPolicy{
+		MaxSizeMB:  s.GetInt(model.ConfigSystemLogMaxSizeMB),
+		MaxBackups: s.GetInt(model.ConfigSystemLogMaxBackups),
+		MaxAgeDays: s.GetInt(model.ConfigSystemLogMaxAgeDays),
+	}
+}
+
+// GetSystemLogModuleRetention 获取按模块覆盖的保留策略
+func (s *ConfigService) GetSystemLogModuleRetention() (map[string]logger.RetentionPolicy, error) {
+	policies := make(map[string]logger.RetentionPolicy)
+	raw := strings.TrimSpace(s.GetString(model.ConfigSystemLogModuleRetention))
+	if raw == "" {
+		return policies, nil
+	}
+	err := json.Unmarshal([]byte(raw), &policies)
+	return policies, err
+}
+
+// GetSystemLogShipConfig 获取系统日志投递配置（未配置类型或地址时返回 nil）
+func (s *ConfigService) GetSystemLogShipConfig() (*logger.ShipConfig, error) {
+	shipType := strings.TrimSpace(s.GetString(model.ConfigSystemLogShipType))
+	shipURL := strings.TrimSpace(s.GetString(model.ConfigSystemLogShipURL))
+	if shipType == "" || shipURL == "" {
+		return nil, nil
+	}
+	cfg := &logger.ShipConfig{
+		Type:     shipType,
+		URL:      shipURL,
+		MinLevel: s.GetString(model.ConfigSystemLogShipMinLevel),
+	}
+	if raw := strings.TrimSpace(s.GetString(model.ConfigSystemLogShipLabels)); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &cfg.Labels); err != nil {
+			return nil, err
+		}
+	}
+	return cfg, nil
+}
@@ -0,0 +1,125 @@
+/*
+ * 文件作用：配置备份/恢复与声明式同步服务
+ * 负责功能：
+ *   - 导出运行时配置为版本化 YAML
+ *   - 生成配置变更计划（plan）并应用（apply）
+ *   - 应用后刷新各模块缓存
+ *   - 启动时从配置目录同步数据库
+ * 重要程度：⭐⭐⭐ 一般（运维功能）
+ * 依赖模块：configsync, repository, errormatch, scheduler
+ */
+package service
+
+import (
+	"sync"
+
+	"cli-proxy/internal/configsync"
+	"cli-proxy/internal/errormatch"
+	"cli-proxy/internal/proxy/scheduler"
+	"cli-proxy/internal/proxypool"
+	"cli-proxy/internal/repository"
+	"cli-proxy/pkg/logger"
+)
+
+// ConfigSyncService 配置同步服务
+type ConfigSyncService struct {
+	mu sync.Mutex // 串行化 apply，避免并发写入
+}
+
+var (
+	configSyncService     *ConfigSyncService
+	configSyncServiceOnce sync.Once
+)
+
+// GetConfigSyncService 获取配置同步服务单例
+func GetConfigSyncService() *ConfigSyncService {
+	configSyncServiceOnce.Do(func() {
+		configSyncService = &ConfigSyncService{}
+	})
+	return configSyncService
+}
+
+// Export 导出配置为 YAML
+func (s *ConfigSyncService) Export(opts configsync.ExportOptions) ([]byte, error) {
+	doc, err := configsync.Export(repository.GetDB(), opts)
+	if err != nil {
+		return nil, err
+	}
+	return configsync.Marshal(doc)
+}
+
+// Plan 生成变更计划（不修改数据库）
+func (s *ConfigSyncService) Plan(data []byte, opts configsync.Options) (*configsync.Plan, error) {
+	doc, err := configsync.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	return configsync.BuildPlan(repository.GetDB(), doc, opts)
+}
+
+// Apply 应用配置并刷新相关缓存
+func (s *ConfigSyncService) Apply(data []byte, opts configsync.Options) (*configsync.Plan, error) {
+	doc, err := configsync.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	return s.ApplyDocument(doc, opts)
+}
+
+// ApplyDocument 应用已解析的配置文档并刷新相关缓存
+func (s *ConfigSyncService) ApplyDocument(doc *configsync.Document, opts configsync.Options) (*configsync.Plan, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	plan, err := configsync.Apply(repository.GetDB(), doc, opts)
+	if err != nil {
+		return nil, err
+	}
+	s.refreshCaches(plan)
+	return plan, nil
+}
+
+// SyncFromDir 启动时从配置目录同步数据库
+func (s *ConfigSyncService) SyncFromDir(dir string, prune bool) (*configsync.Plan, error) {
+	doc, err := configsync.LoadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	return s.ApplyDocument(doc, configsync.Options{Prune: prune})
+}
+
+// refreshCaches 按变更的分区刷新缓存
+func (s *ConfigSyncService) refreshCaches(plan *configsync.Plan) {
+	log := logger.GetLogger("config_sync")
+
+	if plan.HasSection("system_configs") {
+		if err := GetConfigService().RefreshCache(); err != nil {
+			log.Warn("刷新系统配置缓存失败: %v", err)
+		}
+	}
+	if plan.HasSection("error_rules") {
+		errormatch.GetErrorRuleMatcher().Refresh()
+	}
+	if plan.HasSection("error_messages") {
+		if err := GetErrorMessageService().RefreshCache(); err != nil {
+			log.Warn("刷新错误消息缓存失败: %v", err)
+		}
+	}
+	if plan.HasSection("model_mappings") {
+		NewModelMappingService().RefreshCache()
+	}
+	if plan.HasSection("client_rule_sets") || plan.HasSection("client_types") || plan.HasSection("client_filter_rules") || plan.HasSection("client_filter_config") {
+		if err := GetClientFilterService().ReloadCache(); err != nil {
+			log.Warn("刷新客户端过滤缓存失败: %v", err)
+		}
+	}
+	if plan.HasSection("proxy_pools") || plan.HasSection("proxies") {
+		proxypool.GetManager().Refresh()
+	}
+	if plan.HasSection("proxies") || plan.HasSection("gateways") {
+		// 账号缓存中包含代理关联，代理变更后需重新加载
+		if err := scheduler.GetScheduler().Refresh(); err != nil {
+			log.Warn("刷新调度器缓存失败: %v", err)
+		}
+	}
+}
@@ -4,7 +4,7 @@
  *   - 错误消息配置CRUD
  *   - 消息缓存管理
  *   - 错误类型匹配
- *   - 自定义消息查找
+ *   - 自定义消息查找（按语言选择并渲染模板）
  * 重要程度：⭐⭐⭐ 一般（错误处理增强）
  * 依赖模块：repository, model
  */
@@ -61,6 +61,7 @@ func (s *ErrorMessageService) loadCache() error {
 	for i := range messages {
 		s.cache[messages[i].ErrorType] = &messages[i]
 	}
+	resetMessageTemplates()
 
 	s.log.Info("错误消息配置已加载，共 %d 条", len(messages))
 	return nil
@@ -75,6 +76,13 @@ func (s *ErrorMessageService) RefreshCache() error {
 // 返回: customMessage, originalError（用于日志）
 // 如果未启用自定义消息，返回原始错误
 func (s *ErrorMessageService) GetCustomMessage(errorType string, originalError string) (customMessage string, shouldLog bool) {
+	return s.Localize(errorType, originalError, nil, nil)
+}
+
+// Localize 获取本地化的自定义错误消息
+// locales: 候选语言（见 NegotiateLocales），data: 模板数据（为 nil 时不渲染占位符）
+// 未启用自定义消息时返回原始错误
+func (s *ErrorMessageService) Localize(errorType, originalError string, locales []string, data *ErrorMessageData) (string, bool) {
 	s.mu.RLock()
 	msg, exists := s.cache[errorType]
 	s.mu.RUnlock()
@@ -84,7 +92,7 @@ func (s *ErrorMessageService) GetCustomMessage(errorType string, originalError s
 	}
 
 	// 自定义消息启用，需要记录原始错误
-	return msg.CustomMessage, true
+	return localizeErrorMessage(msg, locales, data, true), true
 }
 
 // GetMessageByType 根据错误类型获取消息配置
@@ -109,8 +117,8 @@ func (s *ErrorMessageService) GetByCode(code int) ([]model.ErrorMessage, error)
 	return s.repo.GetByCode(code)
 }
 
-// Update 更新错误消息配置
-func (s *ErrorMessageService) Update(id uint, customMessage string, enabled bool, description string) error {
+// Update 更新错误消息配置（translations 为 nil 时保留原多语言消息）
+func (s *ErrorMessageService) Update(id uint, customMessage string, enabled bool, description string, translations *string) error {
 	msg, err := s.repo.GetByID(id)
 	if err != nil {
 		return err
@@ -119,6 +127,12 @@ func (s *ErrorMessageService) Update(id uint, customMessage string, enabled bool
 	msg.CustomMessage = customMessage
 	msg.Enabled = enabled
 	msg.Description = description
+	if translations != nil {
+		msg.Translations = strings.TrimSpace(*translations)
+	}
+	if err := ValidateErrorMessage(msg); err != nil {
+		return err
+	}
 
 	if err := s.repo.Update(msg); err != nil {
 		return err
@@ -185,6 +199,9 @@ func (s *ErrorMessageService) InitDefaultMessages() error {
 
 // Create 创建新的错误消息配置
 func (s *ErrorMessageService) Create(msg *model.ErrorMessage) error {
+	if err := ValidateErrorMessage(msg); err != nil {
+		return err
+	}
 	if err := s.repo.Create(msg); err != nil {
 		return err
 	}
@@ -0,0 +1,257 @@
+/*
+ * 文件作用：错误消息多语言选择与模板渲染
+ * 负责功能：
+ *   - 按 API Key 偏好语言、Accept-Language 协商候选语言
+ *   - 从多语言消息中选择最匹配的版本
+ *   - 渲染模板占位符（重试秒数、剩余额度、模型、请求 ID）
+ *   - 自定义消息与多语言消息校验、预览
+ * 重要程度：⭐⭐⭐ 一般（面向国际用户的错误提示）
+ * 依赖模块：model
+ */
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+
+	"cli-proxy/internal/model"
+)
+
+// ErrInvalidErrorMessage 错误消息配置无效（模板语法错误、多语言 JSON 格式错误等）
+var ErrInvalidErrorMessage = errors.New("invalid error message")
+
+// maxAcceptLanguages Accept-Language 最多解析的语言数
+const maxAcceptLanguages = 10
+
+// ErrorMessageData 错误消息模板数据（字段说明见 model.ErrorMessageTemplateFields）
+type ErrorMessageData struct {
+	RetryAfter     int    `json:"retry_after"`
+	QuotaRemaining string `json:"quota_remaining"`
+	Model          string `json:"model"`
+	RequestID      string `json:"request_id"`
+	Status         int    `json:"status"`
+	ErrorType      string `json:"error_type"`
+}
+
+// messageTemplates 已保存消息的模板缓存（key: 模板文本）
+// 消息配置重新加载时清空，预览草稿不写入，缓存大小以当前配置为上限
+var messageTemplates sync.Map
+
+// NegotiateLocales 计算候选语言（按优先级）
+// API Key 偏好语言优先，其次按 Accept-Language 的 q 值从高到低；带地区的标签之后追加其基础语言（en-us -> en）
+func NegotiateLocales(preferred, acceptLanguage string) []string {
+	var locales []string
+	seen := make(map[string]bool)
+	add := func(locale string) {
+		locale = model.NormalizeLocale(locale)
+		if locale == "" || locale == "*" {
+			return
+		}
+		for _, l := range []string{locale, baseLocale(locale)} {
+			if !seen[l] {
+				seen[l] = true
+				locales = append(locales, l)
+			}
+		}
+	}
+
+	add(preferred)
+	for _, locale := range parseAcceptLanguage(acceptLanguage) {
+		add(locale)
+	}
+	return locales
+}
+
+// parseAcceptLanguage 解析 Accept-Language，按 q 值降序返回语言标签（忽略 q=0）
+func parseAcceptLanguage(header string) []string {
+	type weighted struct {
+		locale string
+		q      float64
+	}
+
+	var items []weighted
+	for _, part := range strings.Split(header, ",") {
+		if len(items) >= maxAcceptLanguages {
+			break
+		}
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		locale := strings.TrimSpace(fields[0])
+		if locale == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if q > 0 {
+			items = append(items, weighted{locale: locale, q: q})
+		}
+	}
+
+	sort.SliceStable(items, func(i, j int) bool { return items[i].q > items[j].q })
+	locales := make([]string, len(items))
+	for i, item := range items {
+		locales[i] = item.locale
+	}
+	return locales
+}
+
+// baseLocale 语言标签的基础语言（zh-cn -> zh）
+func baseLocale(locale string) string {
+	if i := strings.IndexByte(locale, '-'); i > 0 {
+		return locale[:i]
+	}
+	return locale
+}
+
+// selectLocalizedText 选择与候选语言最匹配的消息
+// 先精确匹配，再按基础语言匹配（候选 zh 可命中 zh-tw），都没有时返回默认消息
+func selectLocalizedText(defaultText string, translations map[string]string, locales []string) string {
+	if len(translations) == 0 {
+		return defaultText
+	}
+	for _, locale := range locales {
+		if text, ok := translations[locale]; ok {
+			return text
+		}
+	}
+
+	keys := make([]string, 0, len(translations))
+	for key := range translations {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, locale := range locales {
+		base := baseLocale(locale)
+		for _, key := range keys {
+			if baseLocale(key) == base {
+				return translations[key]
+			}
+		}
+	}
+	return defaultText
+}
+
+// parseMessageTemplate 解析消息模板；cache 为 true 时使用并写入模板缓存
+func parseMessageTemplate(text string, cache bool) (*template.Template, error) {
+	if cache {
+		if cached, ok := messageTemplates.Load(text); ok {
+			return cached.(*template.Template), nil
+		}
+	}
+	tmpl, err := template.New("error_message").Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if cache {
+		messageTemplates.Store(text, tmpl)
+	}
+	return tmpl, nil
+}
+
+// resetMessageTemplates 清空模板缓存（消息配置重新加载时调用）
+func resetMessageTemplates() {
+	messageTemplates.Clear()
+}
+
+// renderErrorMessage 渲染消息模板；不含占位符或渲染失败时原样返回
+func renderErrorMessage(text string, data *ErrorMessageData, cache bool) string {
+	if data == nil || !strings.Contains(text, "{{") {
+		return text
+	}
+	tmpl, err := parseMessageTemplate(text, cache)
+	if err != nil {
+		return text
+	}
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return text
+	}
+	return buf.String()
+}
+
+// localizeErrorMessage 按候选语言选择并渲染错误消息（cache 为 false 时不缓存模板，用于预览草稿）
+func localizeErrorMessage(msg *model.ErrorMessage, locales []string, data *ErrorMessageData, cache bool) string {
+	translations, _ := msg.ParseTranslations()
+	return renderErrorMessage(selectLocalizedText(msg.CustomMessage, translations, locales), data, cache)
+}
+
+// validateMessageTemplate 校验模板语法及占位符（未知字段会在执行时报错）
+func validateMessageTemplate(text string) error {
+	if !strings.Contains(text, "{{") {
+		return nil
+	}
+	tmpl, err := template.New("error_message").Parse(text)
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(&strings.Builder{}, &ErrorMessageData{})
+}
+
+// ValidateErrorMessage 校验自定义消息与多语言消息
+func ValidateErrorMessage(msg *model.ErrorMessage) error {
+	if err := validateMessageTemplate(msg.CustomMessage); err != nil {
+		return fmt.Errorf("%w: 自定义消息模板错误: %v", ErrInvalidErrorMessage, err)
+	}
+	translations, err := msg.ParseTranslations()
+	if err != nil {
+		return fmt.Errorf("%w: 多语言消息必须是 {\"语言\":\"消息\"} 格式的 JSON: %v", ErrInvalidErrorMessage, err)
+	}
+	for locale, text := range translations {
+		if err := validateMessageTemplate(text); err != nil {
+			return fmt.Errorf("%w: %s 消息模板错误: %v", ErrInvalidErrorMessage, locale, err)
+		}
+	}
+	return nil
+}
+
+// PreviewErrorMessageRequest 错误消息预览请求
+type PreviewErrorMessageRequest struct {
+	ErrorType       string           `json:"error_type"`       // 已有配置的错误类型（未传 custom_message 时使用）
+	CustomMessage   string           `json:"custom_message"`   // 草稿消息
+	Translations    string           `json:"translations"`     // 草稿多语言消息 JSON
+	PreferredLocale string           `json:"preferred_locale"` // 模拟 API Key 偏好语言
+	AcceptLanguage  string           `json:"accept_language"`  // 模拟 Accept-Language
+	Data            ErrorMessageData `json:"data"`             // 模板数据
+}
+
+// PreviewErrorMessageResult 错误消息预览结果
+type PreviewErrorMessageResult struct {
+	Locales []string `json:"locales"` // 协商出的候选语言
+	Message string   `json:"message"` // 渲染后的消息
+}
+
+// Preview 预览指定语言与模板数据下的错误消息（不要求消息已启用）
+func (s *ErrorMessageService) Preview(req *PreviewErrorMessageRequest) (*PreviewErrorMessageResult, error) {
+	msg := &model.ErrorMessage{ErrorType: req.ErrorType, CustomMessage: req.CustomMessage, Translations: req.Translations}
+	if req.CustomMessage == "" {
+		cached := s.GetMessageByType(req.ErrorType)
+		if cached == nil {
+			return nil, fmt.Errorf("%w: 错误类型不存在: %s", ErrInvalidErrorMessage, req.ErrorType)
+		}
+		msg = cached
+	}
+	if err := ValidateErrorMessage(msg); err != nil {
+		return nil, err
+	}
+
+	data := req.Data
+	if data.ErrorType == "" {
+		data.ErrorType = msg.ErrorType
+	}
+	locales := NegotiateLocales(req.PreferredLocale, req.AcceptLanguage)
+	return &PreviewErrorMessageResult{
+		Locales: locales,
+		Message: localizeErrorMessage(msg, locales, &data, false),
+	}, nil
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"cli-proxy/internal/model"
+)
+
+func TestNegotiateLocales(t *testing.T) {
+	got := NegotiateLocales("", "fr-CH, fr;q=0.9, en;q=0.8, de;q=0.7, *;q=0.5")
+	want := []string{"fr-ch", "fr", "en", "de"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("NegotiateLocales = %v, want %v", got, want)
+	}
+
+	// API Key 偏好语言优先，q=0 的语言被忽略
+	got = NegotiateLocales("ja", "zh-CN;q=0.5, en;q=0")
+	want = []string{"ja", "zh-cn", "zh"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("NegotiateLocales = %v, want %v", got, want)
+	}
+
+	if got := NegotiateLocales("", ""); len(got) != 0 {
+		t.Fatalf("expected no locales, got %v", got)
+	}
+}
+
+func TestLocalizeErrorMessage(t *testing.T) {
+	msg := &model.ErrorMessage{
+		ErrorType:     model.ErrorTypeRateLimit,
+		CustomMessage: "请求过于频繁，请 {{.RetryAfter}} 秒后重试",
+		Translations:  `{"en":"Too many requests, retry in {{.RetryAfter}}s (request {{.RequestID}})","zh_TW":"請求過於頻繁"}`,
+	}
+	data := &ErrorMessageData{RetryAfter: 12, RequestID: "req-1"}
+
+	cases := []struct {
+		acceptLanguage string
+		want           string
+	}{
+		{"en-US,en;q=0.9", "Too many requests, retry in 12s (request req-1)"},
+		{"zh-TW", "請求過於頻繁"},
+		{"zh", "請求過於頻繁"}, // 按基础语言匹配
+		{"de", "请求过于频繁，请 12 秒后重试"},
+		{"", "请求过于频繁，请 12 秒后重试"},
+	}
+	for _, tc := range cases {
+		got := localizeErrorMessage(msg, NegotiateLocales("", tc.acceptLanguage), data, true)
+		if got != tc.want {
+			t.Fatalf("Accept-Language %q: got %q, want %q", tc.acceptLanguage, got, tc.want)
+		}
+	}
+
+	// 未传模板数据时不渲染
+	if got := localizeErrorMessage(msg, nil, nil, true); got != msg.CustomMessage {
+		t.Fatalf("expected raw message, got %q", got)
+	}
+}
+
+func TestPreviewBypassesTemplateCache(t *testing.T) {
+	resetMessageTemplates()
+	t.Cleanup(resetMessageTemplates)
+
+	draft := &model.ErrorMessage{CustomMessage: "草稿 {{.Model}}"}
+	if got := localizeErrorMessage(draft, nil, &ErrorMessageData{Model: "m"}, false); got != "草稿 m" {
+		t.Fatalf("unexpected preview: %q", got)
+	}
+	if _, ok := messageTemplates.Load(draft.CustomMessage); ok {
+		t.Fatal("expected preview template not cached")
+	}
+
+	if got := localizeErrorMessage(draft, nil, &ErrorMessageData{Model: "m"}, true); got != "草稿 m" {
+		t.Fatalf("unexpected message: %q", got)
+	}
+	if _, ok := messageTemplates.Load(draft.CustomMessage); !ok {
+		t.Fatal("expected saved template cached")
+	}
+	resetMessageTemplates()
+	if _, ok := messageTemplates.Load(draft.CustomMessage); ok {
+		t.Fatal("expected cache cleared on reload")
+	}
+}
+
+func TestValidateErrorMessage(t *testing.T) {
+	invalid := []*model.ErrorMessage{
+		{CustomMessage: "retry in {{.RetryAfter"},
+		{CustomMessage: "unknown {{.Foo}}"},
+		{CustomMessage: "ok", Translations: "not json"},
+		{CustomMessage: "ok", Translations: `{"en":"{{.Model"}`},
+	}
+	for _, msg := range invalid {
+		if err := ValidateErrorMessage(msg); !errors.Is(err, ErrInvalidErrorMessage) {
+			t.Fatalf("expected invalid message error for %+v, got %v", msg, err)
+		}
+	}
+
+	for errorType := range model.DefaultErrorTranslations {
+		msg := &model.ErrorMessage{CustomMessage: "默认", Translations: model.DefaultTranslationsJSON(errorType)}
+		if err := ValidateErrorMessage(msg); err != nil {
+			t.Fatalf("default translation for %s invalid: %v", errorType, err)
+		}
+	}
+}
@@ -5,17 +5,24 @@
  *   - 规则缓存刷新
  *   - 默认规则初始化
  *   - 规则启用/禁用
+ *   - 规则校验与样例测试
  * 重要程度：⭐⭐⭐ 一般（错误处理增强）
  * 依赖模块：repository, errormatch, model
  */
 package service
 
 import (
+	"errors"
+	"fmt"
+
 	"cli-proxy/internal/errormatch"
 	"cli-proxy/internal/model"
 	"cli-proxy/internal/repository"
 )
 
+// ErrInvalidErrorRule 规则配置无效（正则、状态码范围、重试动作等）
+var ErrInvalidErrorRule = errors.New("错误规则配置无效")
+
 type ErrorRuleService struct {
 	repo *repository.ErrorRuleRepository
 }
@@ -28,33 +35,96 @@ func NewErrorRuleService() *ErrorRuleService {
 
 // CreateRuleRequest 创建规则请求
 type CreateRuleRequest struct {
-	HTTPStatusCode int    `json:"http_status_code"`
-	Keyword        string `json:"keyword"`
-	TargetStatus   string `json:"target_status" binding:"required"`
-	Priority       int    `json:"priority"`
-	Enabled        bool   `json:"enabled"`
-	Description    string `json:"description"`
+	HTTPStatusCode  int    `json:"http_status_code"`
+	StatusCodes     string `json:"status_codes"`
+	Keyword         string `json:"keyword"`
+	MessageRegex    string `json:"message_regex"`
+	JSONPath        string `json:"json_path"`
+	JSONValue       string `json:"json_value"`
+	HeaderName      string `json:"header_name"`
+	HeaderValue     string `json:"header_value"`
+	AccountTypes    string `json:"account_types"`
+	Platforms       string `json:"platforms"`
+	TargetStatus    string `json:"target_status" binding:"required"`
+	CooldownSeconds int    `json:"cooldown_seconds"`
+	RetryAction     string `json:"retry_action"`
+	ClientMessage   string `json:"client_message"`
+	Priority        int    `json:"priority"`
+	Enabled         bool   `json:"enabled"`
+	Description     string `json:"description"`
 }
 
 // UpdateRuleRequest 更新规则请求
 type UpdateRuleRequest struct {
-	HTTPStatusCode *int    `json:"http_status_code"`
-	Keyword        *string `json:"keyword"`
-	TargetStatus   string  `json:"target_status"`
-	Priority       *int    `json:"priority"`
-	Enabled        *bool   `json:"enabled"`
-	Description    string  `json:"description"`
+	HTTPStatusCode  *int    `json:"http_status_code"`
+	StatusCodes     *string `json:"status_codes"`
+	Keyword         *string `json:"keyword"`
+	MessageRegex    *string `json:"message_regex"`
+	JSONPath        *string `json:"json_path"`
+	JSONValue       *string `json:"json_value"`
+	HeaderName      *string `json:"header_name"`
+	HeaderValue     *string `json:"header_value"`
+	AccountTypes    *string `json:"account_types"`
+	Platforms       *string `json:"platforms"`
+	TargetStatus    string  `json:"target_status"`
+	CooldownSeconds *int    `json:"cooldown_seconds"`
+	RetryAction     *string `json:"retry_action"`
+	ClientMessage   *string `json:"client_message"`
+	Priority        *int    `json:"priority"`
+	Enabled         *bool   `json:"enabled"`
+	Description     string  `json:"description"`
+}
+
+// TestRuleRequest 规则测试请求
+// Rule 为空时用当前生效的全部规则匹配样例；否则只测试该规则（可以是未保存的草稿）
+type TestRuleRequest struct {
+	Rule    *CreateRuleRequest        `json:"rule"`
+	Samples []errormatch.ErrorContext `json:"samples" binding:"required"`
+}
+
+// TestRuleResult 单个样例的测试结果
+type TestRuleResult struct {
+	Sample          errormatch.ErrorContext `json:"sample"`
+	Matched         bool                    `json:"matched"`
+	RuleID          uint                    `json:"rule_id,omitempty"`
+	Description     string                  `json:"description,omitempty"`
+	TargetStatus    string                  `json:"target_status,omitempty"`
+	CooldownSeconds int                     `json:"cooldown_seconds,omitempty"`
+	RetryAction     string                  `json:"retry_action,omitempty"`
+	ClientMessage   string                  `json:"client_message,omitempty"`
+}
+
+// maxTestSamples 单次测试的最大样例数
+const maxTestSamples = 50
+
+// toRule 转换为规则模型
+func (req *CreateRuleRequest) toRule() *model.ErrorRule {
+	return &model.ErrorRule{
+		HTTPStatusCode:  req.HTTPStatusCode,
+		StatusCodes:     req.StatusCodes,
+		Keyword:         req.Keyword,
+		MessageRegex:    req.MessageRegex,
+		JSONPath:        req.JSONPath,
+		JSONValue:       req.JSONValue,
+		HeaderName:      req.HeaderName,
+		HeaderValue:     req.HeaderValue,
+		AccountTypes:    req.AccountTypes,
+		Platforms:       req.Platforms,
+		TargetStatus:    req.TargetStatus,
+		CooldownSeconds: req.CooldownSeconds,
+		RetryAction:     req.RetryAction,
+		ClientMessage:   req.ClientMessage,
+		Priority:        req.Priority,
+		Enabled:         req.Enabled,
+		Description:     req.Description,
+	}
 }
 
 // Create 创建规则
 func (s *ErrorRuleService) Create(req *CreateRuleRequest) (*model.ErrorRule, error) {
-	rule := &model.ErrorRule{
-		HTTPStatusCode: req.HTTPStatusCode,
-		Keyword:        req.Keyword,
-		TargetStatus:   req.TargetStatus,
-		Priority:       req.Priority,
-		Enabled:        req.Enabled,
-		Description:    req.Description,
+	rule := req.toRule()
+	if err := errormatch.ValidateRule(rule); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidErrorRule, err)
 	}
 
 	if err := s.repo.Create(rule); err != nil {
@@ -82,12 +152,45 @@ func (s *ErrorRuleService) Update(id uint, req *UpdateRuleRequest) (*model.Error
 	if req.HTTPStatusCode != nil {
 		rule.HTTPStatusCode = *req.HTTPStatusCode
 	}
+	if req.StatusCodes != nil {
+		rule.StatusCodes = *req.StatusCodes
+	}
 	if req.Keyword != nil {
 		rule.Keyword = *req.Keyword
 	}
+	if req.MessageRegex != nil {
+		rule.MessageRegex = *req.MessageRegex
+	}
+	if req.JSONPath != nil {
+		rule.JSONPath = *req.JSONPath
+	}
+	if req.JSONValue != nil {
+		rule.JSONValue = *req.JSONValue
+	}
+	if req.HeaderName != nil {
+		rule.HeaderName = *req.HeaderName
+	}
+	if req.HeaderValue != nil {
+		rule.HeaderValue = *req.HeaderValue
+	}
+	if req.AccountTypes != nil {
+		rule.AccountTypes = *req.AccountTypes
+	}
+	if req.Platforms != nil {
+		rule.Platforms = *req.Platforms
+	}
 	if req.TargetStatus != "" {
 		rule.TargetStatus = req.TargetStatus
 	}
+	if req.CooldownSeconds != nil {
+		rule.CooldownSeconds = *req.CooldownSeconds
+	}
+	if req.RetryAction != nil {
+		rule.RetryAction = *req.RetryAction
+	}
+	if req.ClientMessage != nil {
+		rule.ClientMessage = *req.ClientMessage
+	}
 	if req.Priority != nil {
 		rule.Priority = *req.Priority
 	}
@@ -97,6 +200,9 @@ func (s *ErrorRuleService) Update(id uint, req *UpdateRuleRequest) (*model.Error
 	if req.Description != "" {
 		rule.Description = req.Description
 	}
+	if err := errormatch.ValidateRule(rule); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidErrorRule, err)
+	}
 
 	if err := s.repo.Update(rule); err != nil {
 		return nil, err
@@ -131,6 +237,57 @@ func (s *ErrorRuleService) List(page, pageSize int) ([]model.ErrorRule, int64, e
 	return s.repo.List(page, pageSize)
 }
 
+// Test 用错误样例测试规则
+func (s *ErrorRuleService) Test(req *TestRuleRequest) ([]TestRuleResult, error) {
+	if len(req.Samples) == 0 {
+		return nil, errors.New("至少需要一个错误样例")
+	}
+	if len(req.Samples) > maxTestSamples {
+		return nil, fmt.Errorf("单次最多测试 %d 个样例", maxTestSamples)
+	}
+
+	var draft *model.ErrorRule
+	if req.Rule != nil {
+		draft = req.Rule.toRule()
+		if err := errormatch.ValidateRule(draft); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidErrorRule, err)
+		}
+	}
+
+	matcher := errormatch.GetErrorRuleMatcher()
+	results := make([]TestRuleResult, 0, len(req.Samples))
+	for i := range req.Samples {
+		sample := req.Samples[i]
+		if sample.Platform == "" && sample.AccountType != "" {
+			sample.Platform = model.GetPlatformByType(sample.AccountType)
+		}
+
+		var result *errormatch.MatchResult
+		if draft != nil {
+			matched, _ := errormatch.TestRule(draft, &sample)
+			result = &errormatch.MatchResult{Matched: matched}
+			if matched {
+				result.TargetStatus = draft.TargetStatus
+				result.Rule = draft
+			}
+		} else {
+			result = matcher.MatchContext(&sample)
+		}
+
+		item := TestRuleResult{Sample: sample, Matched: result.Matched}
+		if result.Matched && result.Rule != nil {
+			item.RuleID = result.Rule.ID
+			item.Description = result.Rule.Description
+			item.TargetStatus = result.TargetStatus
+			item.CooldownSeconds = int(result.Cooldown().Seconds())
+			item.RetryAction = result.RetryAction()
+			item.ClientMessage = result.ClientMessage()
+		}
+		results = append(results, item)
+	}
+	return results, nil
+}
+
 // GetAllEnabled 获取所有启用的规则
 func (s *ErrorRuleService) GetAllEnabled() ([]model.ErrorRule, error) {
 	return s.repo.GetAllEnabled()
@@ -0,0 +1,454 @@
+/*
+ * 文件作用：IP 访问控制服务
+ * 负责功能：
+ *   - 全局封禁名单（内存缓存，定期从数据库刷新）
+ *   - 代理认证失败过多时自动临时封禁
+ *   - API Key 来源 IP/CIDR 白名单、国家白名单/黑名单校验
+ *   - 封禁管理（手动封禁、解封、命中统计）
+ * 重要程度：⭐⭐⭐⭐ 重要（安全防护）
+ * 依赖模块：repository, model, logger
+ */
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"cli-proxy/internal/model"
+	"cli-proxy/internal/repository"
+	"cli-proxy/pkg/logger"
+)
+
+// 封禁缓存刷新间隔（多实例部署时其他实例的封禁最多延迟该时长生效）
+const ipBanRefreshInterval = time.Minute
+
+// IPGuardService IP 访问控制服务
+type IPGuardService struct {
+	repo          *repository.IPBanRepository
+	configService *ConfigService
+	log           *logger.Logger
+
+	mu    sync.RWMutex
+	bans  *banSet
+	hits  map[uint]int64 // 待写入数据库的命中次数
+	hitMu sync.Mutex
+
+	keyNetsMu sync.RWMutex
+	keyNets   map[string][]*net.IPNet // API Key 的 AllowedIPs 解析缓存
+
+	stopChan chan struct{}
+	running  bool
+	runMu    sync.Mutex
+}
+
+var ipGuardService *IPGuardService
+var ipGuardOnce sync.Once
+
+// GetIPGuardService 获取 IP 访问控制服务单例
+func GetIPGuardService() *IPGuardService {
+	ipGuardOnce.Do(func() {
+		ipGuardService = &IPGuardService{
+			repo:          repository.NewIPBanRepository(),
+			configService: GetConfigService(),
+			log:           logger.GetLogger("ip_guard"),
+			bans:          newBanSet(nil),
+			hits:          make(map[uint]int64),
+			keyNets:       make(map[string][]*net.IPNet),
+		}
+	})
+	return ipGuardService
+}
+
+// ============ 封禁集合 ============
+
+// banEntry 缓存中的封禁
+type banEntry struct {
+	id        uint
+	ipNet     *net.IPNet
+	expiresAt *time.Time
+}
+
+// banSet 封禁集合（单个 IP 精确匹配 + CIDR 线性匹配）
+type banSet struct {
+	exact map[string]*banEntry
+	nets  []*banEntry
+}
+
+func newBanSet(bans []model.IPBan) *banSet {
+	set := &banSet{exact: make(map[string]*banEntry)}
+	for i := range bans {
+		ipNet, err := ParseIPOrCIDR(bans[i].CIDR)
+		if err != nil {
+			continue
+		}
+		e := &banEntry{id: bans[i].ID, ipNet: ipNet, expiresAt: bans[i].ExpiresAt}
+		if ones, bits := ipNet.Mask.Size(); ones == bits {
+			set.exact[ipNet.IP.String()] = e
+		} else {
+			set.nets = append(set.nets, e)
+		}
+	}
+	return set
+}
+
+// match 查找命中的生效封禁
+func (s *banSet) match(ip net.IP, now time.Time) *banEntry {
+	active := func(e *banEntry) bool {
+		return e.expiresAt == nil || e.expiresAt.After(now)
+	}
+	if e, ok := s.exact[ip.String()]; ok && active(e) {
+		return e
+	}
+	for _, e := range s.nets {
+		if e.ipNet.Contains(ip) && active(e) {
+			return e
+		}
+	}
+	return nil
+}
+
+// ParseIPOrCIDR 解析单个 IP（视为 /32 或 /128）或 CIDR
+func ParseIPOrCIDR(s string) (*net.IPNet, error) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, "/") {
+		_, ipNet, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("无效的 CIDR: %s", s)
+		}
+		return ipNet, nil
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, fmt.Errorf("无效的 IP: %s", s)
+	}
+	if v4 := ip.To4(); v4 != nil {
+		return &net.IPNet{IP: v4, Mask: net.CIDRMask(32, 32)}, nil
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+}
+
+// splitIPList 按逗号、空白和换行拆分 IP 列表
+func splitIPList(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == '\n' || r == '\r' || r == ' ' || r == '\t'
+	})
+}
+
+// ParseIPList 解析逗号/换行分隔的 IP/CIDR 列表
+func ParseIPList(s string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0)
+	for _, item := range splitIPList(s) {
+		ipNet, err := ParseIPOrCIDR(item)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// ============ 全局封禁 ============
+
+// Start 启动定期刷新（同步其他实例的封禁、写入命中统计）
+func (s *IPGuardService) Start() {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+	if s.running {
+		return
+	}
+	s.running = true
+	s.stopChan = make(chan struct{})
+
+	if err := s.Reload(); err != nil {
+		s.log.Error("加载 IP 封禁列表失败: %v", err)
+	}
+	go func(stop chan struct{}) {
+		ticker := time.NewTicker(ipBanRefreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.flushHits()
+				if err := s.Reload(); err != nil {
+					s.log.Error("刷新 IP 封禁列表失败: %v", err)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}(s.stopChan)
+}
+
+// Stop 停止定期刷新并写入剩余的命中统计
+func (s *IPGuardService) Stop() {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+	if !s.running {
+		return
+	}
+	s.running = false
+	close(s.stopChan)
+	s.flushHits()
+}
+
+// Reload 从数据库重新加载生效中的封禁
+func (s *IPGuardService) Reload() error {
+	bans, err := s.repo.ListActive()
+	if err != nil {
+		return err
+	}
+	set := newBanSet(bans)
+	s.mu.Lock()
+	s.bans = set
+	s.mu.Unlock()
+	return nil
+}
+
+// CheckBanned 检查 IP 是否被封禁（命中时累计命中次数）
+func (s *IPGuardService) CheckBanned(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	s.mu.RLock()
+	e := s.bans.match(parsed, time.Now())
+	s.mu.RUnlock()
+	if e == nil {
+		return false
+	}
+	s.hitMu.Lock()
+	s.hits[e.id]++
+	s.hitMu.Unlock()
+	return true
+}
+
+func (s *IPGuardService) flushHits() {
+	s.hitMu.Lock()
+	hits := s.hits
+	s.hits = make(map[uint]int64)
+	s.hitMu.Unlock()
+	if len(hits) == 0 {
+		return
+	}
+	if err := s.repo.AddHits(hits, time.Now()); err != nil {
+		s.log.Warn("写入封禁命中统计失败: %v", err)
+	}
+}
+
+// RecordAuthFailure 记录一次代理认证失败，超过阈值时自动封禁该 IP
+func (s *IPGuardService) RecordAuthFailure(ip string) {
+	if !s.configService.GetIPAutoBanEnabled() || net.ParseIP(ip) == nil {
+		return
+	}
+	limiter := GetAuthFailureLimiter()
+	key := "auth_fail:" + ip
+	allowed, _ := limiter.Check(key, s.configService.GetIPAutoBanThreshold(), s.configService.GetIPAutoBanWindow())
+	if allowed {
+		return
+	}
+	limiter.Reset(key)
+
+	duration := s.configService.GetIPAutoBanDuration()
+	expiresAt := time.Now().Add(duration)
+	ban := &model.IPBan{
+		CIDR:      ip,
+		Reason:    fmt.Sprintf("%d 分钟内 API Key 认证失败超过 %d 次", s.configService.GetIPAutoBanWindow(), s.configService.GetIPAutoBanThreshold()),
+		Source:    model.IPBanSourceAuto,
+		ExpiresAt: &expiresAt,
+		CreatedBy: "system",
+	}
+	if err := s.repo.Create(ban); err != nil {
+		s.log.Error("自动封禁 IP 失败 | IP: %s | Err: %v", ip, err)
+		return
+	}
+	s.log.Warn("自动封禁 IP | IP: %s | 时长: %v | BanID: %d", ip, duration, ban.ID)
+	if err := s.Reload(); err != nil {
+		s.log.Error("刷新 IP 封禁列表失败: %v", err)
+	}
+}
+
+// BanRequest 手动封禁请求
+type BanRequest struct {
+	CIDR            string `json:"cidr" binding:"required"` // 单个 IP 或 CIDR
+	Reason          string `json:"reason"`
+	DurationMinutes int    `json:"duration_minutes"` // 0 表示永久
+}
+
+// Ban 手动封禁
+func (s *IPGuardService) Ban(req *BanRequest, operator string) (*model.IPBan, error) {
+	ipNet, err := ParseIPOrCIDR(req.CIDR)
+	if err != nil {
+		return nil, err
+	}
+	if req.DurationMinutes < 0 {
+		return nil, errors.New("封禁时长不能为负数")
+	}
+	ban := &model.IPBan{
+		CIDR:      normalizeIPNet(ipNet),
+		Reason:    req.Reason,
+		Source:    model.IPBanSourceManual,
+		CreatedBy: operator,
+	}
+	if req.DurationMinutes > 0 {
+		expiresAt := time.Now().Add(time.Duration(req.DurationMinutes) * time.Minute)
+		ban.ExpiresAt = &expiresAt
+	}
+	if err := s.repo.Create(ban); err != nil {
+		return nil, err
+	}
+	s.log.Info("手动封禁 IP | CIDR: %s | 操作人: %s | BanID: %d", ban.CIDR, operator, ban.ID)
+	if err := s.Reload(); err != nil {
+		s.log.Error("刷新 IP 封禁列表失败: %v", err)
+	}
+	ban.State = ban.Status()
+	return ban, nil
+}
+
+// Unban 解封（保留记录用于审计）
+func (s *IPGuardService) Unban(id uint, operator string) error {
+	ban, err := s.repo.GetByID(id)
+	if err != nil {
+		return errors.New("封禁记录不存在")
+	}
+	if ban.RevokedAt != nil {
+		return errors.New("该封禁已解除")
+	}
+	if err := s.repo.Revoke(id, operator); err != nil {
+		return err
+	}
+	s.log.Info("解除 IP 封禁 | CIDR: %s | 操作人: %s | BanID: %d", ban.CIDR, operator, id)
+	return s.Reload()
+}
+
+// List 分页查询封禁记录
+func (s *IPGuardService) List(f *repository.IPBanFilter, page, pageSize int) ([]model.IPBan, int64, error) {
+	bans, total, err := s.repo.List(f, page, pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	for i := range bans {
+		bans[i].State = bans[i].Status()
+	}
+	return bans, total, nil
+}
+
+// ActiveBansFor 查询覆盖指定 IP 的生效中封禁（直接查库，不计入命中次数）
+func (s *IPGuardService) ActiveBansFor(ip string) []model.IPBan {
+	matched := make([]model.IPBan, 0)
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return matched
+	}
+	bans, err := s.repo.ListActive()
+	if err != nil {
+		s.log.Warn("查询 IP 封禁失败: %v", err)
+		return matched
+	}
+	for _, ban := range bans {
+		if ipNet, err := ParseIPOrCIDR(ban.CIDR); err == nil && ipNet.Contains(parsed) {
+			ban.State = ban.Status()
+			matched = append(matched, ban)
+		}
+	}
+	return matched
+}
+
+// normalizeIPNet 单个 IP 存储为 IP 本身，网段存储为 CIDR
+func normalizeIPNet(ipNet *net.IPNet) string {
+	if ones, bits := ipNet.Mask.Size(); ones == bits {
+		return ipNet.IP.String()
+	}
+	return ipNet.String()
+}
+
+// ============ API Key 来源限制 ============
+
+// ValidateAPIKeySourceRules 校验 API Key 的 IP/国家限制配置
+func ValidateAPIKeySourceRules(allowedIPs, allowedCountries, blockedCountries string) error {
+	if _, err := ParseIPList(allowedIPs); err != nil {
+		return err
+	}
+	for _, list := range []string{allowedCountries, blockedCountries} {
+		for _, code := range splitCountryCodes(list) {
+			if len(code) != 2 {
+				return fmt.Errorf("无效的国家代码: %s（应为两位 ISO 3166-1 代码）", code)
+			}
+		}
+	}
+	return nil
+}
+
+// CheckAPIKeySource 检查来源是否满足 API Key 的 IP/国家限制
+// country 为可信代理提供的国家代码，未知时为空：设置了国家白名单时未知国家视为不允许
+func (s *IPGuardService) CheckAPIKeySource(key *model.APIKey, ip, country string) (bool, string) {
+	if strings.TrimSpace(key.AllowedIPs) != "" {
+		parsed := net.ParseIP(ip)
+		if parsed == nil || !ipInNets(parsed, s.keyAllowedNets(key.AllowedIPs)) {
+			return false, "IP 不在该 API Key 允许的范围内"
+		}
+	}
+	country = strings.ToUpper(strings.TrimSpace(country))
+	if allowed := splitCountryCodes(key.AllowedCountries); len(allowed) > 0 && !containsString(allowed, country) {
+		return false, "来源地区不在该 API Key 允许的范围内"
+	}
+	if country != "" && containsString(splitCountryCodes(key.BlockedCountries), country) {
+		return false, "来源地区被该 API Key 禁止"
+	}
+	return true, ""
+}
+
+// keyAllowedNets 解析并缓存 AllowedIPs（配置校验在保存时完成，这里忽略无效项）
+func (s *IPGuardService) keyAllowedNets(allowedIPs string) []*net.IPNet {
+	s.keyNetsMu.RLock()
+	nets, ok := s.keyNets[allowedIPs]
+	s.keyNetsMu.RUnlock()
+	if ok {
+		return nets
+	}
+
+	nets = make([]*net.IPNet, 0)
+	for _, item := range splitIPList(allowedIPs) {
+		if ipNet, err := ParseIPOrCIDR(item); err == nil {
+			nets = append(nets, ipNet)
+		}
+	}
+	s.keyNetsMu.Lock()
+	if len(s.keyNets) > 10000 {
+		s.keyNets = make(map[string][]*net.IPNet)
+	}
+	s.keyNets[allowedIPs] = nets
+	s.keyNetsMu.Unlock()
+	return nets
+}
+
+func ipInNets(ip net.IP, nets []*net.IPNet) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func splitCountryCodes(s string) []string {
+	codes := make([]string, 0)
+	for _, code := range strings.Split(s, ",") {
+		if code = strings.ToUpper(strings.TrimSpace(code)); code != "" {
+			codes = append(codes, code)
+		}
+	}
+	return codes
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"cli-proxy/internal/model"
+)
+
+func TestBanSetMatch(t *testing.T) {
+	now := time.Now()
+	expired := now.Add(-time.Minute)
+	future := now.Add(time.Hour)
+	set := newBanSet([]model.IPBan{
+		{ID: 1, CIDR: "1.2.3.4"},
+		{ID: 2, CIDR: "10.0.0.0/8", ExpiresAt: &future},
+		{ID: 3, CIDR: "5.6.7.8", ExpiresAt: &expired},
+		{ID: 4, CIDR: "2001:db8::/32"},
+		{ID: 5, CIDR: "not-an-ip"},
+	})
+
+	cases := map[string]uint{
+		"1.2.3.4":        1,
+		"10.20.30.40":    2,
+		"5.6.7.8":        0,
+		"2001:db8::1":    4,
+		"192.168.1.1":    0,
+		"::ffff:1.2.3.4": 1,
+	}
+	for ip, want := range cases {
+		e := set.match(net.ParseIP(ip), now)
+		var got uint
+		if e != nil {
+			got = e.id
+		}
+		if got != want {
+			t.Errorf("match(%s) = %d, want %d", ip, got, want)
+		}
+	}
+}
+
+func TestParseIPList(t *testing.T) {
+	nets, err := ParseIPList("1.2.3.4, 10.0.0.0/8\n2001:db8::1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(nets) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(nets))
+	}
+	if _, err := ParseIPList("1.2.3.4, 300.1.1.1"); err == nil {
+		t.Fatal("expected invalid IP to be rejected")
+	}
+	if normalizeIPNet(nets[0]) != "1.2.3.4" || normalizeIPNet(nets[1]) != "10.0.0.0/8" {
+		t.Fatalf("unexpected normalized values: %s %s", normalizeIPNet(nets[0]), normalizeIPNet(nets[1]))
+	}
+}
+
+func TestCheckAPIKeySource(t *testing.T) {
+	s := &IPGuardService{keyNets: make(map[string][]*net.IPNet)}
+
+	key := &model.APIKey{AllowedIPs: "192.168.0.0/16,203.0.113.7"}
+	if ok, _ := s.CheckAPIKeySource(key, "192.168.3.4", ""); !ok {
+		t.Fatal("expected address inside allowed CIDR to pass")
+	}
+	if ok, _ := s.CheckAPIKeySource(key, "203.0.113.8", ""); ok {
+		t.Fatal("expected address outside allowlist to be rejected")
+	}
+
+	key = &model.APIKey{AllowedCountries: "CN,HK"}
+	if ok, _ := s.CheckAPIKeySource(key, "1.1.1.1", "hk"); !ok {
+		t.Fatal("expected allowed country to pass")
+	}
+	if ok, _ := s.CheckAPIKeySource(key, "1.1.1.1", ""); ok {
+		t.Fatal("expected unknown country to be rejected when allowlist is set")
+	}
+
+	key = &model.APIKey{BlockedCountries: "KP"}
+	if ok, _ := s.CheckAPIKeySource(key, "1.1.1.1", "KP"); ok {
+		t.Fatal("expected blocked country to be rejected")
+	}
+	if ok, _ := s.CheckAPIKeySource(key, "1.1.1.1", ""); !ok {
+		t.Fatal("expected unknown country to pass a blocklist")
+	}
+}
+
+func TestValidateAPIKeySourceRules(t *testing.T) {
+	if err := ValidateAPIKeySourceRules("10.0.0.0/8", "CN, us", "KP"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := ValidateAPIKeySourceRules("10.0.0.0/33", "", ""); err == nil {
+		t.Fatal("expected invalid CIDR to be rejected")
+	}
+	if err := ValidateAPIKeySourceRules("", "CHN", ""); err == nil {
+		t.Fatal("expected three-letter country code to be rejected")
+	}
+}
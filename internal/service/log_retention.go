@@ -0,0 +1,557 @@
+/*
+ * 文件作用：请求日志保留策略服务，后台维护请求日志表体积
+ * 负责功能：
+ *   - 分级保留：请求/响应体超期清空，元数据超期删除
+ *   - 删除前归档到本地 JSONL.gz 文件
+ *   - 按月分区的创建、扩展与过期分区删除
+ *   - 任务进度上报
+ * 重要程度：⭐⭐⭐ 一般（日志存储维护）
+ * 依赖模块：repository, model, logger
+ */
+package service
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"cli-proxy/internal/model"
+	"cli-proxy/internal/repository"
+	"cli-proxy/pkg/logger"
+)
+
+// 每批处理的日志条数
+const logRetentionBatchSize = 2000
+
+// 分区表提前创建的月份数（含当月）
+const logPartitionMonthsAhead = 3
+
+// 归档文件名格式：request_logs-20261019-030000.jsonl.gz
+var logArchiveNamePattern = regexp.MustCompile(`^request_logs-\d{8}-\d{6}(-\d+)?\.jsonl\.gz$`)
+
+// LogRetentionProgress 清理任务进度
+type LogRetentionProgress struct {
+	Running           bool       `json:"running"`
+	Phase             string     `json:"phase"` // partition/archive/purge_bodies/done
+	StartedAt         *time.Time `json:"started_at,omitempty"`
+	FinishedAt        *time.Time `json:"finished_at,omitempty"`
+	BodyCutoff        *time.Time `json:"body_cutoff,omitempty"` // 早于该时间的请求/响应体被清空
+	LogCutoff         *time.Time `json:"log_cutoff,omitempty"`  // 早于该时间的日志被归档删除
+	Archived          int64      `json:"archived"`
+	Deleted           int64      `json:"deleted"`
+	BodiesPurged      int64      `json:"bodies_purged"`
+	PartitionsCreated int        `json:"partitions_created"`
+	PartitionsDropped int        `json:"partitions_dropped"`
+	ArchiveFiles      []string   `json:"archive_files,omitempty"`
+	LastError         string     `json:"last_error,omitempty"`
+}
+
+// LogArchiveFile 归档文件信息
+type LogArchiveFile struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// LogRetentionService 请求日志保留策略服务
+type LogRetentionService struct {
+	repo *repository.RequestLogRepository
+	log  *logger.Logger
+
+	mu       sync.Mutex
+	progress LogRetentionProgress
+	running  bool // 后台定时任务是否启动
+	stopChan chan struct{}
+	trigger  chan struct{}
+}
+
+var (
+	logRetentionService     *LogRetentionService
+	logRetentionServiceOnce sync.Once
+)
+
+// GetLogRetentionService 获取请求日志保留策略服务单例
+func GetLogRetentionService() *LogRetentionService {
+	logRetentionServiceOnce.Do(func() {
+		logRetentionService = &LogRetentionService{
+			repo:    repository.NewRequestLogRepository(),
+			log:     logger.GetLogger("log_retention"),
+			trigger: make(chan struct{}, 1),
+		}
+	})
+	return logRetentionService
+}
+
+// Progress 获取当前（或最近一次）任务进度
+func (s *LogRetentionService) Progress() LogRetentionProgress {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := s.progress
+	p.ArchiveFiles = append([]string(nil), s.progress.ArchiveFiles...)
+	return p
+}
+
+// update 更新任务进度
+func (s *LogRetentionService) update(fn func(p *LogRetentionProgress)) {
+	s.mu.Lock()
+	fn(&s.progress)
+	s.mu.Unlock()
+}
+
+// Trigger 请求立即执行一次（已在执行时忽略）
+func (s *LogRetentionService) Trigger() bool {
+	s.mu.Lock()
+	running := s.progress.Running
+	s.mu.Unlock()
+	if running {
+		return false
+	}
+	select {
+	case s.trigger <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// Start 启动后台任务（间隔每轮重新读取配置，修改后无需重启）
+func (s *LogRetentionService) Start() {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = true
+	s.stopChan = make(chan struct{})
+	stopChan := s.stopChan
+	s.mu.Unlock()
+
+	go func() {
+		s.log.Info("请求日志清理任务已启动")
+		for {
+			timer := time.NewTimer(GetConfigService().GetRequestLogRetentionInterval())
+			select {
+			case <-timer.C:
+			case <-s.trigger:
+				timer.Stop()
+			case <-stopChan:
+				timer.Stop()
+				s.log.Info("请求日志清理任务已停止")
+				return
+			}
+			s.Run()
+		}
+	}()
+}
+
+// Stop 停止后台任务
+func (s *LogRetentionService) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.running {
+		return
+	}
+	close(s.stopChan)
+	s.running = false
+}
+
+// Run 执行一轮保留策略：分区维护 → 归档并删除过期日志 → 清空过期请求/响应体与检索文本
+func (s *LogRetentionService) Run() {
+	s.mu.Lock()
+	if s.progress.Running {
+		s.mu.Unlock()
+		return
+	}
+	now := time.Now()
+	s.progress = LogRetentionProgress{Running: true, StartedAt: &now}
+	s.mu.Unlock()
+
+	err := s.run(now)
+
+	finished := time.Now()
+	s.update(func(p *LogRetentionProgress) {
+		p.Running = false
+		p.Phase = "done"
+		p.FinishedAt = &finished
+		if err != nil {
+			p.LastError = err.Error()
+		}
+	})
+	p := s.Progress()
+	if err != nil {
+		s.log.Error("请求日志清理失败: %v", err)
+	}
+	if p.Deleted > 0 || p.BodiesPurged > 0 || p.PartitionsCreated > 0 || p.PartitionsDropped > 0 {
+		s.log.Info("请求日志清理完成 | 归档: %d | 删除: %d | 清空内容: %d | 新建分区: %d | 删除分区: %d | 耗时: %v",
+			p.Archived, p.Deleted, p.BodiesPurged, p.PartitionsCreated, p.PartitionsDropped, finished.Sub(now))
+	}
+}
+
+func (s *LogRetentionService) run(now time.Time) (err error) {
+	cfg := GetConfigService()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	var logCutoff time.Time
+	if days := cfg.GetRequestLogRetentionDays(); days > 0 {
+		logCutoff = today.AddDate(0, 0, -days)
+		s.update(func(p *LogRetentionProgress) { p.LogCutoff = &logCutoff })
+	}
+
+	var archive *logArchive
+	if cfg.GetRequestLogArchiveEnabled() && !logCutoff.IsZero() {
+		archive = newLogArchive(cfg.GetRequestLogArchiveDir(), now)
+		defer func() {
+			name, closeErr := archive.Close()
+			if closeErr != nil {
+				s.log.Error("关闭归档文件失败: %v", closeErr)
+				if err == nil {
+					err = fmt.Errorf("关闭归档文件失败: %w", closeErr)
+				}
+			} else if name != "" {
+				s.update(func(p *LogRetentionProgress) { p.ArchiveFiles = append(p.ArchiveFiles, name) })
+			}
+		}()
+	}
+
+	// 1. 分区维护
+	if cfg.GetRequestLogPartitionEnabled() {
+		s.update(func(p *LogRetentionProgress) { p.Phase = "partition" })
+		if err := s.maintainPartitions(today, logCutoff, archive); err != nil {
+			return err
+		}
+	}
+
+	// 2. 归档并删除过期日志
+	if !logCutoff.IsZero() {
+		s.update(func(p *LogRetentionProgress) { p.Phase = "archive" })
+		if err := s.archiveAndDelete(logCutoff, archive); err != nil {
+			return err
+		}
+	}
+
+	// 3. 清空过期请求/响应体
+	searchCutoff := logCutoff
+	if days := cfg.GetRequestLogBodyRetentionDays(); days > 0 {
+		bodyCutoff := today.AddDate(0, 0, -days)
+		if bodyCutoff.After(searchCutoff) {
+			searchCutoff = bodyCutoff
+		}
+		s.update(func(p *LogRetentionProgress) {
+			p.Phase = "purge_bodies"
+			p.BodyCutoff = &bodyCutoff
+		})
+		for {
+			n, err := s.repo.PurgeBodies(bodyCutoff, logRetentionBatchSize)
+			if err != nil {
+				return fmt.Errorf("清空请求/响应体失败: %w", err)
+			}
+			s.update(func(p *LogRetentionProgress) { p.BodiesPurged += n })
+			if n < logRetentionBatchSize {
+				break
+			}
+		}
+	}
+
+	// 4. 可检索文本来自请求/响应体，随内容或日志一起过期
+	if !searchCutoff.IsZero() {
+		for {
+			n, err := s.repo.PurgeSearchText(searchCutoff, logRetentionBatchSize)
+			if err != nil {
+				return fmt.Errorf("清理检索文本失败: %w", err)
+			}
+			if n < logRetentionBatchSize {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// archiveAndDelete 分批归档并物理删除 before 之前的日志
+func (s *LogRetentionService) archiveAndDelete(before time.Time, archive *logArchive) error {
+	for {
+		logs, err := s.repo.ListExpiredAfter(before, 0, logRetentionBatchSize)
+		if err != nil {
+			return fmt.Errorf("读取过期日志失败: %w", err)
+		}
+		if len(logs) == 0 {
+			return nil
+		}
+		if archive != nil {
+			// 归档落盘后才删除，归档失败时保留数据
+			if err := archive.Write(logs); err != nil {
+				return fmt.Errorf("写入归档失败: %w", err)
+			}
+			if err := archive.Commit(); err != nil {
+				return fmt.Errorf("归档落盘失败: %w", err)
+			}
+		}
+		ids := make([]uint, len(logs))
+		for i := range logs {
+			ids[i] = logs[i].ID
+		}
+		deleted, err := s.repo.HardDeleteByIDs(ids)
+		if err != nil {
+			return fmt.Errorf("删除过期日志失败: %w", err)
+		}
+		s.update(func(p *LogRetentionProgress) {
+			if archive != nil {
+				p.Archived += int64(len(logs))
+			}
+			p.Deleted += deleted
+		})
+		if len(logs) < logRetentionBatchSize {
+			return nil
+		}
+	}
+}
+
+// maintainPartitions 转换/扩展月分区，并整体删除已完全过期的分区（删除前先归档）
+func (s *LogRetentionService) maintainPartitions(today, logCutoff time.Time, archive *logArchive) error {
+	partitioned, err := s.repo.IsRequestLogPartitioned()
+	if err != nil {
+		return fmt.Errorf("查询分区状态失败: %w", err)
+	}
+	currentMonth := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, today.Location())
+
+	if !partitioned {
+		first := currentMonth
+		if oldest, err := s.repo.GetOldestRequestLogTime(); err == nil && !oldest.IsZero() && oldest.Before(first) {
+			first = time.Date(oldest.Year(), oldest.Month(), 1, 0, 0, 0, 0, today.Location())
+		}
+		months := monthsBetween(first, currentMonth.AddDate(0, logPartitionMonthsAhead-1, 0))
+		s.log.Info("正在将请求日志表转换为按月分区（%d 个分区），数据量大时耗时较长", len(months))
+		if err := s.repo.ConvertRequestLogToPartitioned(months); err != nil {
+			return err
+		}
+		s.update(func(p *LogRetentionProgress) { p.PartitionsCreated += len(months) })
+		return nil
+	}
+
+	partitions, err := s.repo.ListRequestLogPartitions()
+	if err != nil {
+		return fmt.Errorf("查询分区失败: %w", err)
+	}
+	missing := missingPartitionMonths(partitions, currentMonth, logPartitionMonthsAhead)
+	if err := s.repo.AddRequestLogPartitions(missing); err != nil {
+		return fmt.Errorf("创建分区失败: %w", err)
+	}
+	s.update(func(p *LogRetentionProgress) { p.PartitionsCreated += len(missing) })
+
+	if logCutoff.IsZero() {
+		return nil
+	}
+	for _, partition := range partitions {
+		if partition.UpperBound.IsZero() || partition.UpperBound.After(logCutoff) {
+			continue
+		}
+		// 分区内全部数据都已过期：先归档，再整体删除（比逐行 DELETE 快且立即释放空间）
+		if archive != nil {
+			archived, err := s.archiveRange(partition.UpperBound, archive)
+			if err != nil {
+				return err
+			}
+			if err := archive.Commit(); err != nil {
+				return fmt.Errorf("归档落盘失败，跳过删除分区 %s: %w", partition.Name, err)
+			}
+			s.update(func(p *LogRetentionProgress) { p.Archived += archived })
+		}
+		if err := s.repo.DropRequestLogPartition(partition.Name); err != nil {
+			return fmt.Errorf("删除分区 %s 失败: %w", partition.Name, err)
+		}
+		s.update(func(p *LogRetentionProgress) {
+			p.PartitionsDropped++
+			p.Deleted += partition.Rows
+		})
+	}
+	return nil
+}
+
+// archiveRange 归档 before 之前的全部日志（不删除）
+func (s *LogRetentionService) archiveRange(before time.Time, archive *logArchive) (int64, error) {
+	var afterID uint
+	var total int64
+	for {
+		logs, err := s.repo.ListExpiredAfter(before, afterID, logRetentionBatchSize)
+		if err != nil {
+			return total, fmt.Errorf("读取过期日志失败: %w", err)
+		}
+		if len(logs) == 0 {
+			return total, nil
+		}
+		if err := archive.Write(logs); err != nil {
+			return total, fmt.Errorf("写入归档失败: %w", err)
+		}
+		total += int64(len(logs))
+		afterID = logs[len(logs)-1].ID
+	}
+}
+
+// monthsBetween 返回 [from, to] 之间的每月 1 日
+func monthsBetween(from, to time.Time) []time.Time {
+	var months []time.Time
+	for m := from; !m.After(to); m = m.AddDate(0, 1, 0) {
+		months = append(months, m)
+	}
+	return months
+}
+
+// missingPartitionMonths 计算需要补建的月分区（从已有最后一个月分区之后到 currentMonth + ahead - 1）
+func missingPartitionMonths(partitions []repository.RequestLogPartition, currentMonth time.Time, ahead int) []time.Time {
+	var last time.Time
+	for _, p := range partitions {
+		if p.UpperBound.After(last) {
+			last = p.UpperBound
+		}
+	}
+	target := currentMonth.AddDate(0, ahead-1, 0)
+	if last.IsZero() {
+		return monthsBetween(currentMonth, target)
+	}
+	// last 为最后一个分区的上界，即下一个需要创建的月份
+	next := time.Date(last.Year(), last.Month(), 1, 0, 0, 0, 0, currentMonth.Location())
+	return monthsBetween(next, target)
+}
+
+// ========== 归档文件 ==========
+
+// logArchive JSONL.gz 归档写入器（首次写入时才创建文件）
+// 每次 Commit 结束当前 gzip 成员并同步到磁盘，之后的写入追加新的 gzip 成员（标准 gzip 读取器按多成员连续读取）
+type logArchive struct {
+	dir  string
+	name string
+	file *os.File
+	gz   *gzip.Writer
+	enc  *json.Encoder
+	err  error // 首次写入/落盘失败的错误，之后的操作均失败
+}
+
+func newLogArchive(dir string, now time.Time) *logArchive {
+	return &logArchive{dir: dir, name: "request_logs-" + now.Format("20060102-150405") + ".jsonl.gz"}
+}
+
+// Write 追加日志，每条一行 JSON
+func (a *logArchive) Write(logs []model.RequestLog) error {
+	if a.err != nil {
+		return a.err
+	}
+	if a.file == nil {
+		if err := os.MkdirAll(a.dir, 0o755); err != nil {
+			return err
+		}
+		path := filepath.Join(a.dir, a.name)
+		for i := 1; ; i++ {
+			if _, err := os.Stat(path); os.IsNotExist(err) {
+				break
+			}
+			a.name = fmt.Sprintf("%s-%d.jsonl.gz", a.name[:len("request_logs-20060102-150405")], i)
+			path = filepath.Join(a.dir, a.name)
+		}
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err != nil {
+			return err
+		}
+		a.file = file
+	}
+	if a.gz == nil {
+		a.gz = gzip.NewWriter(a.file)
+		a.enc = json.NewEncoder(a.gz)
+	}
+	for i := range logs {
+		logs[i].Account = nil
+		if err := a.enc.Encode(&logs[i]); err != nil {
+			a.err = err
+			return err
+		}
+	}
+	return nil
+}
+
+// Commit 结束当前 gzip 成员并同步到磁盘，返回 nil 后已写入的日志才可删除
+func (a *logArchive) Commit() error {
+	if a.err != nil {
+		return a.err
+	}
+	if a.file == nil {
+		return nil
+	}
+	if a.gz != nil {
+		if err := a.gz.Close(); err != nil {
+			a.err = err
+			return err
+		}
+		a.gz, a.enc = nil, nil
+	}
+	if err := a.file.Sync(); err != nil {
+		a.err = err
+		return err
+	}
+	return nil
+}
+
+// Close 落盘并关闭归档文件，返回文件名（未写入任何日志时返回空）
+func (a *logArchive) Close() (string, error) {
+	if a.file == nil {
+		return "", nil
+	}
+	if err := a.Commit(); err != nil {
+		a.file.Close()
+		return "", err
+	}
+	if err := a.file.Close(); err != nil {
+		return "", err
+	}
+	return a.name, nil
+}
+
+// ListArchives 列出归档文件（按时间倒序）
+func (s *LogRetentionService) ListArchives() ([]LogArchiveFile, error) {
+	dir := GetConfigService().GetRequestLogArchiveDir()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []LogArchiveFile{}, nil
+		}
+		return nil, err
+	}
+	files := make([]LogArchiveFile, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !logArchiveNamePattern.MatchString(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, LogArchiveFile{Name: entry.Name(), Size: info.Size(), CreatedAt: info.ModTime()})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name > files[j].Name })
+	return files, nil
+}
+
+// ArchivePath 获取归档文件路径（只接受归档文件名，防止路径穿越）
+func (s *LogRetentionService) ArchivePath(name string) (string, error) {
+	if !logArchiveNamePattern.MatchString(name) {
+		return "", fmt.Errorf("无效的归档文件名")
+	}
+	path := filepath.Join(GetConfigService().GetRequestLogArchiveDir(), name)
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("归档文件不存在")
+	}
+	return path, nil
+}
+
+// PartitionStatus 分区状态
+func (s *LogRetentionService) PartitionStatus() (bool, []repository.RequestLogPartition, error) {
+	partitioned, err := s.repo.IsRequestLogPartitioned()
+	if err != nil || !partitioned {
+		return false, nil, err
+	}
+	partitions, err := s.repo.ListRequestLogPartitions()
+	return true, partitions, err
+}
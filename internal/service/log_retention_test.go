@@ -0,0 +1,108 @@
+package service
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"cli-proxy/internal/model"
+	"cli-proxy/internal/repository"
+)
+
+func TestMissingPartitionMonths(t *testing.T) {
+	current := time.Date(2026, 10, 1, 0, 0, 0, 0, time.Local)
+	partitions := []repository.RequestLogPartition{
+		{Name: "p202609", UpperBound: time.Date(2026, 10, 1, 0, 0, 0, 0, time.Local)},
+		{Name: "p202610", UpperBound: time.Date(2026, 11, 1, 0, 0, 0, 0, time.Local)},
+		{Name: "pmax"},
+	}
+	months := missingPartitionMonths(partitions, current, 3)
+	if len(months) != 2 || months[0].Month() != time.November || months[1].Month() != time.December {
+		t.Fatalf("unexpected months: %v", months)
+	}
+
+	partitions = append(partitions, repository.RequestLogPartition{Name: "p202612", UpperBound: time.Date(2027, 1, 1, 0, 0, 0, 0, time.Local)})
+	if months := missingPartitionMonths(partitions, current, 3); len(months) != 0 {
+		t.Fatalf("expected no missing months, got %v", months)
+	}
+}
+
+func TestLogArchive(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2026, 10, 19, 3, 0, 0, 0, time.Local)
+
+	empty := newLogArchive(dir, now)
+	if name, err := empty.Close(); err != nil || name != "" {
+		t.Fatalf("empty archive should not create a file: %q, %v", name, err)
+	}
+
+	// 分批写入并落盘：每批一个 gzip 成员，读取时连续读出
+	archive := newLogArchive(dir, now)
+	logs := []model.RequestLog{{ID: 1, Model: "a"}, {ID: 2, Model: "b"}}
+	if err := archive.Write(logs[:1]); err != nil {
+		t.Fatal(err)
+	}
+	if err := archive.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := archive.Write(logs[1:]); err != nil {
+		t.Fatal(err)
+	}
+	name, err := archive.Close()
+	if err != nil || !logArchiveNamePattern.MatchString(name) {
+		t.Fatalf("close = %q, %v", name, err)
+	}
+
+	f, err := os.Open(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []uint
+	scanner := bufio.NewScanner(gz)
+	for scanner.Scan() {
+		var log model.RequestLog
+		if err := json.Unmarshal(scanner.Bytes(), &log); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, log.ID)
+	}
+	if len(ids) != 2 || ids[0] != 1 || ids[1] != 2 {
+		t.Fatalf("unexpected archived ids: %v", ids)
+	}
+
+	// 同一秒内再次归档不覆盖已有文件
+	second := newLogArchive(dir, now)
+	if err := second.Write(logs[:1]); err != nil {
+		t.Fatal(err)
+	}
+	if name2, _ := second.Close(); name2 == name || !logArchiveNamePattern.MatchString(name2) {
+		t.Fatalf("second archive name = %q", name2)
+	}
+}
+
+func TestLogArchiveCommitFailure(t *testing.T) {
+	archive := newLogArchive(t.TempDir(), time.Now())
+	if err := archive.Write([]model.RequestLog{{ID: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	// 文件句柄失效时落盘失败，之后的写入和落盘也都失败（调用方据此跳过删除）
+	archive.file.Close()
+	if err := archive.Commit(); err == nil {
+		t.Fatal("expected commit error")
+	}
+	if err := archive.Write([]model.RequestLog{{ID: 2}}); err == nil {
+		t.Fatal("expected write error after failed commit")
+	}
+	if _, err := archive.Close(); err == nil {
+		t.Fatal("expected close error after failed commit")
+	}
+}
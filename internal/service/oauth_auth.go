@@ -6,7 +6,7 @@
  *   - TLS指纹伪装
  *   - PKCE验证器生成
  * 重要程度：⭐⭐⭐⭐ 重要（OAuth授权核心）
- * 依赖模块：model, logger
+ * 依赖模块：model, adapter, logger
  */
 package service
 
@@ -26,6 +26,7 @@ import (
 	"time"
 
 	"cli-proxy/internal/model"
+	"cli-proxy/internal/proxy/adapter"
 	"cli-proxy/pkg/logger"
 	"cli-proxy/pkg/utils"
 
@@ -134,14 +135,14 @@ type ProxyConfig struct {
 }
 
 func (s *OAuthAuthService) getProxyConfig(account *model.Account) *ProxyConfig {
-	// 优先使用账号关联的代理
-	if account.Proxy != nil && account.Proxy.Enabled {
+	// 优先使用账号关联的代理池或代理
+	if p := adapter.ResolveAccountProxy(account); p != nil {
 		return &ProxyConfig{
-			Type:     account.Proxy.Type,
-			Host:     account.Proxy.Host,
-			Port:     account.Proxy.Port,
-			Username: account.Proxy.Username,
-			Password: account.Proxy.Password,
+			Type:     p.Type,
+			Host:     p.Host,
+			Port:     p.Port,
+			Username: p.Username,
+			Password: p.Password,
 		}
 	}
 
@@ -4,23 +4,43 @@
  *   - 代理配置CRUD
  *   - 默认代理管理
  *   - 代理启用/禁用
+ *   - 代理连通性探测（手动测试与后台健康检查共用）
+ *   - 变更后刷新代理池分配缓存
  * 重要程度：⭐⭐⭐ 一般（代理配置管理）
- * 依赖模块：repository, model, logger
+ * 依赖模块：repository, model, proxypool, egress, logger
  */
 package service
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
 	"sync"
 	"time"
 
+	"cli-proxy/internal/egress"
 	"cli-proxy/internal/model"
+	"cli-proxy/internal/proxypool"
 	"cli-proxy/internal/repository"
 	"cli-proxy/pkg/logger"
 
+	"golang.org/x/net/proxy"
 	"gorm.io/gorm"
 )
 
+// proxyProbeURLs 代理连通性测试目标（任一返回 200/204 即视为成功）
+var proxyProbeURLs = []string{
+	"https://www.google.com/generate_204",
+	"https://cp.cloudflare.com/",
+	"https://www.gstatic.com/generate_204",
+}
+
+// ErrProxyUnreachable 代理可连接但所有测试目标均未返回成功状态
+var ErrProxyUnreachable = errors.New("无法连接到测试目标")
+
 var (
 	proxyService     *ProxyService
 	proxyServiceOnce sync.Once
@@ -86,6 +106,7 @@ func (s *ProxyService) Create(proxy *model.Proxy) error {
 		return err
 	}
 	s.log.Info("创建代理成功: %s (%s:%d)", proxy.Name, proxy.Host, proxy.Port)
+	proxypool.GetManager().Refresh()
 	return nil
 }
 
@@ -96,6 +117,8 @@ func (s *ProxyService) Update(proxy *model.Proxy) error {
 		return err
 	}
 	s.log.Info("更新代理成功: %s (%s:%d)", proxy.Name, proxy.Host, proxy.Port)
+	egress.GetTracker().SetProxyEgress(proxy.ID, egress.Info{IP: proxy.EgressIP, Country: proxy.EgressCountry})
+	proxypool.GetManager().Refresh()
 	return nil
 }
 
@@ -115,6 +138,7 @@ func (s *ProxyService) Delete(id uint) error {
 		return err
 	}
 	s.log.Info("删除代理成功: ID=%d", id)
+	proxypool.GetManager().Refresh()
 	return nil
 }
 
@@ -134,7 +158,11 @@ func (s *ProxyService) ToggleEnabled(id uint) error {
 		return err
 	}
 	proxy.Enabled = !proxy.Enabled
-	return repository.DB.Save(&proxy).Error
+	if err := repository.DB.Save(&proxy).Error; err != nil {
+		return err
+	}
+	proxypool.GetManager().Refresh()
+	return nil
 }
 
 // GetDefaultProxy 获取默认代理（用于 OAuth 认证）
@@ -176,6 +204,17 @@ func (s *ProxyService) ClearDefault() error {
 
 // UpdateTestStatus 更新代理测试状态
 func (s *ProxyService) UpdateTestStatus(id uint, status string, latency int, errMsg string) error {
+	if err := s.saveTestStatus(id, status, latency, errMsg); err != nil {
+		s.log.Error("更新代理测试状态失败: %v", err)
+		return err
+	}
+
+	s.log.Info("更新代理测试状态: ID=%d, status=%s, latency=%dms", id, status, latency)
+	return nil
+}
+
+// saveTestStatus 保存测试结果并同步到代理池分配缓存
+func (s *ProxyService) saveTestStatus(id uint, status string, latency int, errMsg string) error {
 	now := time.Now()
 	updates := map[string]interface{}{
 		"test_status":  status,
@@ -185,10 +224,78 @@ func (s *ProxyService) UpdateTestStatus(id uint, status string, latency int, err
 	}
 
 	if err := repository.DB.Model(&model.Proxy{}).Where("id = ?", id).Updates(updates).Error; err != nil {
-		s.log.Error("更新代理测试状态失败: %v", err)
 		return err
 	}
 
-	s.log.Info("更新代理测试状态: ID=%d, status=%s, latency=%dms", id, status, latency)
+	proxypool.GetManager().UpdateHealth(id, status, latency, errMsg)
 	return nil
 }
+
+// Probe 测试代理连通性，依次请求测试目标直到成功，返回总耗时（毫秒）
+func (s *ProxyService) Probe(p *model.Proxy) (int, error) {
+	start := time.Now()
+	var lastErr error
+	for _, testURL := range proxyProbeURLs {
+		resp, err := probeThroughProxy(p, testURL)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		resp.Body.Close()
+		// 204 No Content 或 200 OK 都表示成功
+		if resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusOK {
+			return int(time.Since(start).Milliseconds()), nil
+		}
+	}
+
+	latency := int(time.Since(start).Milliseconds())
+	if lastErr != nil {
+		return latency, lastErr
+	}
+	return latency, ErrProxyUnreachable
+}
+
+// probeThroughProxy 通过代理请求测试目标
+func probeThroughProxy(p *model.Proxy, testURL string) (*http.Response, error) {
+	client, err := newProbeClient(p)
+	if err != nil {
+		return nil, err
+	}
+	return client.Get(testURL)
+}
+
+// newProbeClient 创建经代理发起请求的探测客户端（不复用连接，避免后台检查累积空闲连接）
+func newProbeClient(p *model.Proxy) (*http.Client, error) {
+	transport := &http.Transport{DisableKeepAlives: true}
+
+	switch p.Type {
+	case model.ProxyTypeSOCKS5:
+		var auth *proxy.Auth
+		if p.Username != "" && p.Password != "" {
+			auth = &proxy.Auth{
+				User:     p.Username,
+				Password: p.Password,
+			}
+		}
+		dialer, err := proxy.SOCKS5("tcp", fmt.Sprintf("%s:%d", p.Host, p.Port), auth, proxy.Direct)
+		if err != nil {
+			return nil, fmt.Errorf("创建 SOCKS5 代理失败: %v", err)
+		}
+		transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return dialer.Dial(network, addr)
+		}
+	case model.ProxyTypeHTTP, model.ProxyTypeHTTPS:
+		proxyURL, err := url.Parse(p.GetURL())
+		if err != nil {
+			return nil, fmt.Errorf("解析代理 URL 失败: %v", err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	default:
+		return nil, fmt.Errorf("不支持的代理类型: %s", p.Type)
+	}
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   10 * time.Second,
+	}, nil
+}
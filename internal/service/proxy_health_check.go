@@ -0,0 +1,252 @@
+/*
+ * 文件作用：代理健康检查服务，定期测试代理连通性
+ * 负责功能：
+ *   - 后台定时测试所有启用代理（间隔每轮重新读取配置）
+ *   - 更新代理测试状态与延迟，同步到代理池分配缓存（失败代理不参与分配）
+ *   - 同步代理池成员变更（多实例部署时）
+ *   - 开启出口 IP 检查时探测各代理及直连的出口 IP
+ *   - 手动检测指定代理池
+ * 重要程度：⭐⭐⭐ 一般（出口代理可用性）
+ * 依赖模块：model, proxypool, egress, logger
+ */
+package service
+
+import (
+	"sync"
+	"time"
+
+	"cli-proxy/internal/egress"
+	"cli-proxy/internal/model"
+	"cli-proxy/internal/proxypool"
+	"cli-proxy/pkg/logger"
+)
+
+// proxyHealthCheckConcurrency 同时测试的代理数
+const proxyHealthCheckConcurrency = 5
+
+// ProxyHealthCheckService 代理健康检查服务
+type ProxyHealthCheckService struct {
+	log *logger.Logger
+
+	runMu    sync.Mutex
+	running  bool
+	stopChan chan struct{}
+	trigger  chan struct{}
+	checkMu  sync.Mutex // 避免多轮检查重叠
+}
+
+var (
+	proxyHealthCheckService     *ProxyHealthCheckService
+	proxyHealthCheckServiceOnce sync.Once
+)
+
+// GetProxyHealthCheckService 获取代理健康检查服务单例
+func GetProxyHealthCheckService() *ProxyHealthCheckService {
+	proxyHealthCheckServiceOnce.Do(func() {
+		proxyHealthCheckService = &ProxyHealthCheckService{
+			log:     logger.GetLogger("proxy"),
+			trigger: make(chan struct{}, 1),
+		}
+	})
+	return proxyHealthCheckService
+}
+
+// Start 启动后台检查（启动后立即执行一轮）
+func (s *ProxyHealthCheckService) Start() {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+	if s.running {
+		return
+	}
+	s.running = true
+	s.stopChan = make(chan struct{})
+
+	proxypool.GetManager().SetSettings(GetConfigService().GetProxyPoolSettings())
+	s.syncEgressTracking()
+
+	go func(stop chan struct{}) {
+		s.log.Info("代理健康检查已启动")
+		for {
+			s.RunOnce()
+			timer := time.NewTimer(GetConfigService().GetProxyHealthCheckInterval())
+			select {
+			case <-timer.C:
+			case <-s.trigger:
+				timer.Stop()
+			case <-stop:
+				timer.Stop()
+				s.log.Info("代理健康检查已停止")
+				return
+			}
+		}
+	}(s.stopChan)
+}
+
+// Stop 停止后台检查
+func (s *ProxyHealthCheckService) Stop() {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+	if !s.running {
+		return
+	}
+	s.running = false
+	close(s.stopChan)
+}
+
+// OnConfigChange 代理池配置变更：更新故障排除配置与出口跟踪开关，开关、间隔或回显地址变更时立即执行一轮
+func (s *ProxyHealthCheckService) OnConfigChange(key, value string) {
+	proxypool.GetManager().SetSettings(GetConfigService().GetProxyPoolSettings())
+	if key == model.ConfigProxyEgressCheckEnabled {
+		s.syncEgressTracking()
+	}
+	switch key {
+	case model.ConfigProxyHealthCheckEnabled, model.ConfigProxyHealthCheckInterval,
+		model.ConfigProxyEgressCheckEnabled, model.ConfigProxyEgressEchoURL:
+		select {
+		case s.trigger <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// syncEgressTracking 按配置开启或关闭账户出口跟踪，开启时加载已有出口记录
+func (s *ProxyHealthCheckService) syncEgressTracking() {
+	enabled := GetConfigService().GetProxyEgressCheckEnabled()
+	tracker := egress.GetTracker()
+	if enabled && !tracker.Enabled() {
+		tracker.Refresh()
+	}
+	tracker.SetEnabled(enabled)
+}
+
+// RunOnce 执行一轮检查：同步代理池成员后测试所有启用的代理，开启出口 IP 检查时探测出口 IP
+func (s *ProxyHealthCheckService) RunOnce() {
+	config := GetConfigService()
+	healthCheck, egressCheck := config.GetProxyHealthCheckEnabled(), config.GetProxyEgressCheckEnabled()
+	if !healthCheck && !egressCheck {
+		return
+	}
+	proxypool.GetManager().Refresh()
+
+	proxies, err := GetProxyService().GetEnabledProxies()
+	if err != nil {
+		s.log.Error("获取启用代理失败: %v", err)
+		return
+	}
+	if healthCheck {
+		s.check(proxies)
+	}
+	if egressCheck {
+		if healthCheck {
+			// 重新读取以使用本轮测试结果
+			if proxies, err = GetProxyService().GetEnabledProxies(); err != nil {
+				s.log.Error("获取启用代理失败: %v", err)
+				return
+			}
+		}
+		s.discoverEgress(proxies)
+	}
+}
+
+// CheckPool 立即测试代理池内所有启用的代理并返回最新状态
+func (s *ProxyHealthCheckService) CheckPool(poolID uint) (*proxypool.PoolStatus, error) {
+	pool, err := GetProxyPoolService().GetByID(poolID)
+	if err != nil || pool == nil {
+		return nil, err
+	}
+	var proxies []model.Proxy
+	for _, p := range pool.Proxies {
+		if p.Enabled {
+			proxies = append(proxies, p)
+		}
+	}
+	s.check(proxies)
+	return proxypool.GetManager().Status(poolID), nil
+}
+
+// check 并发测试代理并保存结果，仅在状态变化时记录日志
+func (s *ProxyHealthCheckService) check(proxies []model.Proxy) {
+	if len(proxies) == 0 {
+		return
+	}
+	s.checkMu.Lock()
+	defer s.checkMu.Unlock()
+
+	proxyService := GetProxyService()
+	sem := make(chan struct{}, proxyHealthCheckConcurrency)
+	var wg sync.WaitGroup
+	var failed int
+	var failedMu sync.Mutex
+
+	for i := range proxies {
+		wg.Add(1)
+		go func(p *model.Proxy) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			status, errMsg := model.ProxyTestStatusSuccess, ""
+			latency, err := proxyService.Probe(p)
+			if err != nil {
+				status, errMsg = model.ProxyTestStatusFailed, limitBytes(err.Error(), 500)
+				failedMu.Lock()
+				failed++
+				failedMu.Unlock()
+			}
+			if err := proxyService.saveTestStatus(p.ID, status, latency, errMsg); err != nil {
+				s.log.Error("保存代理测试结果失败: ID=%d, error=%v", p.ID, err)
+				return
+			}
+
+			if status == p.TestStatus {
+				return
+			}
+			if status == model.ProxyTestStatusFailed {
+				s.log.WarnZ("代理连通性测试失败，已暂停分配",
+					logger.Uint("proxy_id", p.ID),
+					logger.String("proxy_name", p.Name),
+					logger.String("error", errMsg),
+				)
+			} else if p.TestStatus == model.ProxyTestStatusFailed {
+				s.log.InfoZ("代理连通性已恢复",
+					logger.Uint("proxy_id", p.ID),
+					logger.String("proxy_name", p.Name),
+					logger.Int("latency_ms", latency),
+				)
+			}
+		}(&proxies[i])
+	}
+	wg.Wait()
+
+	s.log.Debug("代理健康检查完成: 共 %d 个, 失败 %d 个", len(proxies), failed)
+}
+
+// discoverEgress 并发探测直连及代理的出口 IP（跳过测试失败的代理）
+func (s *ProxyHealthCheckService) discoverEgress(proxies []model.Proxy) {
+	s.checkMu.Lock()
+	defer s.checkMu.Unlock()
+
+	egressService := GetAccountEgressService()
+	if _, err := egressService.DiscoverDirectEgress(); err != nil {
+		s.log.Warn("探测直连出口 IP 失败: %v", err)
+	}
+
+	sem := make(chan struct{}, proxyHealthCheckConcurrency)
+	var wg sync.WaitGroup
+	for i := range proxies {
+		if proxies[i].TestStatus == model.ProxyTestStatusFailed {
+			continue
+		}
+		wg.Add(1)
+		go func(p *model.Proxy) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			if _, err := egressService.DiscoverProxyEgress(p); err != nil {
+				s.log.Warn("探测代理出口 IP 失败: ID=%d, name=%s, error=%v", p.ID, p.Name, err)
+			}
+		}(&proxies[i])
+	}
+	wg.Wait()
+}
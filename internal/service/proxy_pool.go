@@ -0,0 +1,126 @@
+/*
+ * 文件作用：代理池服务，处理代理池的业务逻辑
+ * 负责功能：
+ *   - 代理池CRUD与成员设置
+ *   - 删除前检查账户绑定
+ *   - 代理池运行状态查询（分配、排除、连续失败）
+ *   - 变更后刷新代理池分配缓存
+ * 重要程度：⭐⭐⭐ 一般（代理池管理）
+ * 依赖模块：repository, model, proxypool, logger
+ */
+package service
+
+import (
+	"errors"
+	"strings"
+	"sync"
+
+	"cli-proxy/internal/model"
+	"cli-proxy/internal/proxypool"
+	"cli-proxy/internal/repository"
+	"cli-proxy/pkg/logger"
+
+	"gorm.io/gorm"
+)
+
+var (
+	proxyPoolService     *ProxyPoolService
+	proxyPoolServiceOnce sync.Once
+)
+
+// ProxyPoolService 代理池服务
+type ProxyPoolService struct {
+	repo *repository.ProxyPoolRepository
+	log  *logger.Logger
+}
+
+// GetProxyPoolService 获取代理池服务单例
+func GetProxyPoolService() *ProxyPoolService {
+	proxyPoolServiceOnce.Do(func() {
+		proxyPoolService = &ProxyPoolService{
+			repo: repository.NewProxyPoolRepository(),
+			log:  logger.GetLogger("proxy"),
+		}
+	})
+	return proxyPoolService
+}
+
+// List 获取所有代理池（含池内代理）
+func (s *ProxyPoolService) List() ([]model.ProxyPool, error) {
+	return s.repo.List()
+}
+
+// GetByID 根据 ID 获取代理池，不存在时返回 nil
+func (s *ProxyPoolService) GetByID(id uint) (*model.ProxyPool, error) {
+	pool, err := s.repo.GetByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return pool, nil
+}
+
+// Create 创建代理池并设置成员
+func (s *ProxyPoolService) Create(pool *model.ProxyPool, proxyIDs []uint) error {
+	pool.Name = strings.TrimSpace(pool.Name)
+	if pool.Name == "" {
+		return errors.New("代理池名称不能为空")
+	}
+	if err := s.repo.Create(pool); err != nil {
+		s.log.Error("创建代理池失败: %v", err)
+		return err
+	}
+	if err := s.repo.SetMembers(pool.ID, proxyIDs); err != nil {
+		s.log.Error("设置代理池成员失败: %v", err)
+		return err
+	}
+	proxypool.GetManager().Refresh()
+	s.log.Info("创建代理池成功: %s (%d 个代理)", pool.Name, len(proxyIDs))
+	return nil
+}
+
+// Update 更新代理池，proxyIDs 为 nil 时不修改成员
+func (s *ProxyPoolService) Update(pool *model.ProxyPool, proxyIDs []uint) error {
+	pool.Name = strings.TrimSpace(pool.Name)
+	if pool.Name == "" {
+		return errors.New("代理池名称不能为空")
+	}
+	if err := s.repo.Update(pool); err != nil {
+		s.log.Error("更新代理池失败: %v", err)
+		return err
+	}
+	if proxyIDs != nil {
+		if err := s.repo.SetMembers(pool.ID, proxyIDs); err != nil {
+			s.log.Error("设置代理池成员失败: %v", err)
+			return err
+		}
+	}
+	proxypool.GetManager().Refresh()
+	s.log.Info("更新代理池成功: %s", pool.Name)
+	return nil
+}
+
+// Delete 删除代理池（有账户绑定时拒绝）
+func (s *ProxyPoolService) Delete(id uint) error {
+	count, err := s.repo.CountAccounts(id)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.New("该代理池正在被账户使用，无法删除")
+	}
+	if err := s.repo.Delete(id); err != nil {
+		s.log.Error("删除代理池失败: %v", err)
+		return err
+	}
+	proxypool.GetManager().Refresh()
+	s.log.Info("删除代理池成功: ID=%d", id)
+	return nil
+}
+
+// Status 获取代理池运行状态（各代理可用性、分配账户数、连续失败与排除情况）
+func (s *ProxyPoolService) Status(id uint) *proxypool.PoolStatus {
+	return proxypool.GetManager().Status(id)
+}
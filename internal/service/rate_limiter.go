@@ -3,6 +3,7 @@
  * 负责功能：
  *   - 登录频率限制
  *   - 验证码获取频率限制
+ *   - 代理认证失败计数（用于自动封禁）
  *   - 滑动窗口计数
  *   - 自动清理过期记录
  * 重要程度：⭐⭐⭐ 一般（安全防护）
@@ -31,6 +32,7 @@ var (
 	loginRateLimiter   *RateLimiter
 	captchaRateLimiter *RateLimiter
 	apiKeyRateLimiter  *RateLimiter
+	authFailureLimiter *RateLimiter
 	rateLimiterOnce    sync.Once
 )
 
@@ -45,10 +47,14 @@ func initRateLimiters() {
 		apiKeyRateLimiter = &RateLimiter{
 			attempts: make(map[string]*attemptRecord),
 		}
+		authFailureLimiter = &RateLimiter{
+			attempts: make(map[string]*attemptRecord),
+		}
 		// 定期清理过期记录
 		go loginRateLimiter.cleanup()
 		go captchaRateLimiter.cleanup()
 		go apiKeyRateLimiter.cleanup()
+		go authFailureLimiter.cleanup()
 	})
 }
 
@@ -70,6 +76,12 @@ func GetAPIKeyRateLimiter() *RateLimiter {
 	return apiKeyRateLimiter
 }
 
+// GetAuthFailureLimiter 获取代理认证失败计数器（超过阈值自动封禁 IP）
+func GetAuthFailureLimiter() *RateLimiter {
+	initRateLimiters() // 确保初始化
+	return authFailureLimiter
+}
+
 // Check 检查是否允许操作
 // ip: 客户端 IP
 // limit: 限制次数
@@ -112,6 +124,27 @@ func (r *RateLimiter) Check(ip string, limit int, window int) (bool, int) {
 	return true, 0
 }
 
+// Status 查询当前窗口的剩余次数和窗口重置剩余时间（秒），不增加计数
+func (r *RateLimiter) Status(ip string, limit int, window int) (int, int) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	windowDuration := time.Duration(window) * time.Minute
+	record, exists := r.attempts[ip]
+	if !exists {
+		return limit, 0
+	}
+	elapsed := time.Since(record.firstTime)
+	if elapsed >= windowDuration {
+		return limit, 0
+	}
+	remaining := limit - record.count
+	if remaining < 0 {
+		remaining = 0
+	}
+	return remaining, int((windowDuration - elapsed).Seconds())
+}
+
 // Reset 重置某个 IP 的计数
 func (r *RateLimiter) Reset(ip string) {
 	r.mu.Lock()
@@ -0,0 +1,109 @@
+/*
+ * 文件作用：脱敏服务，根据系统配置构建并缓存脱敏器
+ * 负责功能：
+ *   - 读取 privacy 分类下的脱敏配置
+ *   - 配置变化时重建脱敏器（无需重启）
+ *   - 日志落库前脱敏、请求转发前遮蔽
+ * 重要程度：⭐⭐⭐ 一般（合规功能）
+ * 依赖模块：redact, model
+ */
+package service
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"cli-proxy/internal/model"
+	"cli-proxy/internal/redact"
+	"cli-proxy/pkg/logger"
+)
+
+// RedactionService 脱敏服务
+type RedactionService struct {
+	configService *ConfigService
+	log           *logger.Logger
+
+	mu        sync.RWMutex
+	signature string // 构建当前脱敏器时使用的配置（检测器 + 自定义规则）
+	redactor  *redact.Redactor
+}
+
+var redactionService *RedactionService
+var redactionOnce sync.Once
+
+// GetRedactionService 获取脱敏服务单例
+func GetRedactionService() *RedactionService {
+	redactionOnce.Do(func() {
+		redactionService = &RedactionService{
+			configService: GetConfigService(),
+			log:           logger.GetLogger("redaction"),
+		}
+	})
+	return redactionService
+}
+
+// Enabled 是否开启日志脱敏
+func (s *RedactionService) Enabled() bool {
+	return s.configService.GetBool(model.ConfigRedactEnabled)
+}
+
+// Redactor 获取当前配置对应的脱敏器
+func (s *RedactionService) Redactor() *redact.Redactor {
+	detectors := s.configService.GetString(model.ConfigRedactDetectors)
+	rules := s.configService.GetString(model.ConfigRedactCustomRules)
+	signature := detectors + "\x00" + rules
+
+	s.mu.RLock()
+	if s.redactor != nil && s.signature == signature {
+		r := s.redactor
+		s.mu.RUnlock()
+		return r
+	}
+	s.mu.RUnlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.redactor != nil && s.signature == signature {
+		return s.redactor
+	}
+
+	var customRules []redact.Rule
+	if strings.TrimSpace(rules) != "" {
+		if err := json.Unmarshal([]byte(rules), &customRules); err != nil {
+			s.log.Error("解析自定义脱敏规则失败: %v", err)
+			customRules = nil
+		}
+	}
+	r, err := redact.New(strings.Split(detectors, ","), customRules)
+	if err != nil {
+		// 自定义规则有误时仅使用内置检测器，避免脱敏整体失效
+		s.log.Error("构建脱敏器失败: %v", err)
+		r, _ = redact.New(strings.Split(detectors, ","), nil)
+	}
+	s.redactor = r
+	s.signature = signature
+	return r
+}
+
+// RedactLog 在请求日志落库前脱敏（未开启时不处理）
+func (s *RedactionService) RedactLog(log *model.RequestLog) {
+	if !s.Enabled() {
+		return
+	}
+	r := s.Redactor()
+	if r.Empty() {
+		return
+	}
+	log.RequestHeaders = r.Redact(log.RequestHeaders)
+	log.RequestBody = r.Redact(log.RequestBody)
+	log.ResponseBody = r.Redact(log.ResponseBody)
+	log.Error = r.Redact(log.Error)
+	log.UpstreamError = r.Redact(log.UpstreamError)
+}
+
+// MaskRequestBody 转发上游前遮蔽请求体中的敏感内容，返回是否发生了修改
+// 该功能由 API Key 单独开启，不受日志脱敏总开关影响
+func (s *RedactionService) MaskRequestBody(raw []byte) ([]byte, bool) {
+	return s.Redactor().RedactJSON(raw)
+}
@@ -0,0 +1,70 @@
+/*
+ * 文件作用：系统日志配置服务
+ * 负责功能：
+ *   - 将 system_log 分类下的配置应用到日志系统（保留策略、外部投递）
+ *   - 配置变化时重新应用（无需重启）
+ * 重要程度：⭐⭐ 辅助（运维配置）
+ * 依赖模块：logger, model
+ */
+package service
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"cli-proxy/pkg/logger"
+)
+
+// SystemLogService 系统日志配置服务
+type SystemLogService struct {
+	configService *ConfigService
+	log           *logger.Logger
+	mu            sync.Mutex
+}
+
+var systemLogService *SystemLogService
+var systemLogOnce sync.Once
+
+// GetSystemLogService 获取系统日志配置服务单例
+func GetSystemLogService() *SystemLogService {
+	systemLogOnce.Do(func() {
+		systemLogService = &SystemLogService{
+			configService: GetConfigService(),
+			log:           logger.GetLogger("system_log"),
+		}
+	})
+	return systemLogService
+}
+
+// IsSystemLogConfig 是否为系统日志相关配置项
+func IsSystemLogConfig(key string) bool {
+	return strings.HasPrefix(key, "system_log_")
+}
+
+// Apply 应用当前配置（保留策略解析失败时仅应用默认策略；投递配置有误时关闭投递）
+func (s *SystemLogService) Apply() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	modules, err := s.configService.GetSystemLogModuleRetention()
+	if err != nil {
+		s.log.Error("解析按模块保留策略失败: %v", err)
+		modules = nil
+	}
+	logger.SetRetention(s.configService.GetSystemLogRetention(), modules)
+
+	shipCfg, err := s.configService.GetSystemLogShipConfig()
+	if err != nil {
+		logger.ConfigureShipper(nil)
+		return fmt.Errorf("解析投递标签失败: %w", err)
+	}
+	if err := logger.ConfigureShipper(shipCfg); err != nil {
+		logger.ConfigureShipper(nil)
+		return err
+	}
+	if shipCfg != nil {
+		s.log.Info("系统日志投递已启用 | 类型: %s | 地址: %s", shipCfg.Type, shipCfg.URL)
+	}
+	return nil
+}
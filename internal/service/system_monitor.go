@@ -6,9 +6,10 @@
  *   - MySQL连接统计
  *   - 账号/API Key数量统计
  *   - 今日使用量统计
+ *   - 账户池排队统计
  *   - 完整监控数据聚合
  * 重要程度：⭐⭐⭐ 一般（运维监控）
- * 依赖模块：cache, repository, gopsutil
+ * 依赖模块：cache, repository, scheduler, gopsutil
  */
 package service
 
@@ -20,6 +21,7 @@ import (
 
 	"cli-proxy/internal/cache"
 	"cli-proxy/internal/model"
+	"cli-proxy/internal/proxy/scheduler"
 	"cli-proxy/internal/repository"
 
 	"github.com/shirou/gopsutil/v3/cpu"
@@ -127,14 +129,15 @@ type TotalUsageStats struct {
 
 // MonitorData 完整监控数据
 type MonitorData struct {
-	System     SystemStats      `json:"system"`
-	Cache      MemoryCacheStats `json:"cache"` // 替代原 Redis
-	MySQL      MySQLStats       `json:"mysql"`
-	Accounts   AccountStats     `json:"accounts"`
-	APIKeys    APIKeyStats      `json:"api_keys"`
-	TodayUsage TodayUsageStats  `json:"today_usage"`
-	TotalUsage TotalUsageStats  `json:"total_usage"` // 总使用统计
-	UpdatedAt  time.Time        `json:"updated_at"`
+	System     SystemStats          `json:"system"`
+	Cache      MemoryCacheStats     `json:"cache"` // 替代原 Redis
+	MySQL      MySQLStats           `json:"mysql"`
+	Accounts   AccountStats         `json:"accounts"`
+	APIKeys    APIKeyStats          `json:"api_keys"`
+	TodayUsage TodayUsageStats      `json:"today_usage"`
+	TotalUsage TotalUsageStats      `json:"total_usage"` // 总使用统计
+	Queue      scheduler.QueueStats `json:"queue"`       // 账户池排队
+	UpdatedAt  time.Time            `json:"updated_at"`
 }
 
 // GetMonitorData 获取完整监控数据
@@ -151,10 +154,16 @@ func (s *SystemMonitorService) GetMonitorData(ctx context.Context) (*MonitorData
 	data.APIKeys = s.GetAPIKeyStats()
 	data.TodayUsage = s.GetTodayUsageStats(ctx)
 	data.TotalUsage = s.GetTotalUsageStats(ctx)
+	data.Queue = s.GetQueueStats()
 
 	return data, nil
 }
 
+// GetQueueStats 获取账户池排队统计（队列深度、等待时长、超时/拒绝次数）
+func (s *SystemMonitorService) GetQueueStats() scheduler.QueueStats {
+	return scheduler.GetFairQueue().Stats()
+}
+
 // GetSystemStats 获取系统资源统计
 func (s *SystemMonitorService) GetSystemStats() SystemStats {
 	stats := SystemStats{}
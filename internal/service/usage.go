@@ -364,6 +364,73 @@ func (s *UsageService) GetDailySummaryAll(ctx context.Context, startDate, endDat
 	return s.dailyUsageRepo.GetDailySummaryAll(startDate, endDate)
 }
 
+// PromptCacheReport 单个 API Key 的 Prompt Caching 报告
+type PromptCacheReport struct {
+	APIKeyID                 uint    `json:"api_key_id"`
+	RequestCount             int64   `json:"request_count"`
+	InputTokens              int64   `json:"input_tokens"`
+	CacheCreationInputTokens int64   `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int64   `json:"cache_read_input_tokens"`
+	HitRatio                 float64 `json:"hit_ratio"`  // 缓存读取 Token 占全部输入 Token 的比例
+	SavedCost                float64 `json:"saved_cost"` // 相比全部按输入价计费节省的费用（已扣除缓存写入溢价）
+}
+
+// GetPromptCacheReport 获取各 API Key 的 Prompt Caching 命中率和节省费用（管理员用）
+// apiKeyID 为 0 表示所有 API Key
+func (s *UsageService) GetPromptCacheReport(ctx context.Context, pricing *PricingService, apiKeyID uint, startDate, endDate string) ([]PromptCacheReport, error) {
+	usages, err := s.dailyUsageRepo.GetPromptCacheUsage(apiKeyID, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+
+	// 模型定价缓存，避免重复查询
+	prices := make(map[string]*model.AIModel)
+	reports := make(map[uint]*PromptCacheReport)
+	order := make([]uint, 0)
+
+	for _, u := range usages {
+		report, ok := reports[u.APIKeyID]
+		if !ok {
+			report = &PromptCacheReport{APIKeyID: u.APIKeyID}
+			reports[u.APIKeyID] = report
+			order = append(order, u.APIKeyID)
+		}
+		report.RequestCount += u.RequestCount
+		report.InputTokens += u.InputTokens
+		report.CacheCreationInputTokens += u.CacheCreationInputTokens
+		report.CacheReadInputTokens += u.CacheReadInputTokens
+
+		aiModel, cached := prices[u.Model]
+		if !cached {
+			aiModel, _ = pricing.GetModelPricing(ctx, u.Model)
+			prices[u.Model] = aiModel
+		}
+		if aiModel == nil {
+			continue
+		}
+		report.SavedCost += promptCacheSavedCost(aiModel, u.CacheReadInputTokens, u.CacheCreationInputTokens)
+	}
+
+	result := make([]PromptCacheReport, 0, len(order))
+	for _, id := range order {
+		report := reports[id]
+		totalInput := report.InputTokens + report.CacheCreationInputTokens + report.CacheReadInputTokens
+		if totalInput > 0 {
+			report.HitRatio = float64(report.CacheReadInputTokens) / float64(totalInput)
+		}
+		result = append(result, *report)
+	}
+	return result, nil
+}
+
+// promptCacheSavedCost 计算 Prompt Caching 节省的费用（原价）
+// 缓存读取相比按输入价计费的差额，扣除缓存写入相对输入价的溢价
+func promptCacheSavedCost(aiModel *model.AIModel, cacheReadTokens, cacheCreateTokens int64) float64 {
+	saved := float64(cacheReadTokens) * (aiModel.InputPrice - aiModel.CacheReadPrice) / 1000000
+	premium := float64(cacheCreateTokens) * (aiModel.CacheCreatePrice - aiModel.InputPrice) / 1000000
+	return saved - premium
+}
+
 // GetAllRecords 获取所有使用记录（管理员用）
 func (s *UsageService) GetAllRecords(ctx context.Context, offset, limit int, startDate, endDate, modelFilter string) ([]UsageRecord, int64, error) {
 	records, total, err := s.usageRecordRepo.GetAllRecords(offset, limit, startDate, endDate, modelFilter)
@@ -0,0 +1,109 @@
+/*
+ * 文件作用：用量数据导出服务
+ * 负责功能：
+ *   - 每日汇总（daily_usage）导出
+ *   - 单次请求记录（usage_records）导出
+ *   - 按 API Key、模型、平台、日期范围筛选
+ *   - 流式写出 CSV/XLSX/JSON，不在内存中缓冲全部数据
+ * 重要程度：⭐⭐⭐ 一般（账单导出）
+ * 依赖模块：export, repository, model
+ */
+package service
+
+import (
+	"fmt"
+	"io"
+
+	"cli-proxy/internal/export"
+	"cli-proxy/internal/model"
+	"cli-proxy/internal/repository"
+)
+
+// 导出数据集
+const (
+	UsageDatasetDaily   = "daily"   // 每日汇总
+	UsageDatasetRecords = "records" // 单次请求记录
+)
+
+// UsageExportService 用量导出服务
+type UsageExportService struct {
+	dailyUsageRepo  *repository.DailyUsageRepository
+	usageRecordRepo *repository.UsageRecordRepository
+	apiKeyRepo      *repository.APIKeyRepository
+}
+
+func NewUsageExportService() *UsageExportService {
+	return &UsageExportService{
+		dailyUsageRepo:  repository.NewDailyUsageRepository(),
+		usageRecordRepo: repository.NewUsageRecordRepository(),
+		apiKeyRepo:      repository.NewAPIKeyRepository(),
+	}
+}
+
+var dailyUsageExportColumns = []string{
+	"date", "api_key_id", "api_key_name", "model", "request_count",
+	"input_tokens", "output_tokens", "cache_creation_input_tokens", "cache_read_input_tokens", "total_tokens",
+	"input_cost", "output_cost", "cache_create_cost", "cache_read_cost", "total_cost",
+}
+
+var usageRecordExportColumns = []string{
+	"id", "request_time", "api_key_id", "api_key_name", "platform", "model", "request_ip",
+	"input_tokens", "output_tokens", "cache_creation_input_tokens", "cache_read_input_tokens", "total_tokens",
+	"total_cost",
+}
+
+// Export 按数据集和格式将用量数据流式写入 w
+func (s *UsageExportService) Export(w io.Writer, dataset, format string, filter repository.UsageExportFilter) error {
+	if dataset != UsageDatasetDaily && dataset != UsageDatasetRecords {
+		return fmt.Errorf("不支持的数据集: %s", dataset)
+	}
+
+	writer, err := export.NewWriter(format, w, dataset)
+	if err != nil {
+		return err
+	}
+
+	keyNames := s.apiKeyNames()
+
+	if dataset == UsageDatasetDaily {
+		if err := writer.WriteHeader(dailyUsageExportColumns); err != nil {
+			return err
+		}
+		err = s.dailyUsageRepo.StreamDailyUsage(filter, func(u *model.DailyUsage) error {
+			return writer.WriteRow([]interface{}{
+				u.Date, u.APIKeyID, keyNames[u.APIKeyID], u.Model, u.RequestCount,
+				u.InputTokens, u.OutputTokens, u.CacheCreationInputTokens, u.CacheReadInputTokens, u.TotalTokens,
+				u.InputCost, u.OutputCost, u.CacheCreateCost, u.CacheReadCost, u.TotalCost,
+			})
+		})
+	} else {
+		if err := writer.WriteHeader(usageRecordExportColumns); err != nil {
+			return err
+		}
+		err = s.usageRecordRepo.StreamRecords(filter, func(r *model.UsageRecord) error {
+			return writer.WriteRow([]interface{}{
+				r.ID, r.RequestTime, r.APIKeyID, keyNames[r.APIKeyID], r.Platform, r.Model, r.RequestIP,
+				r.InputTokens, r.OutputTokens, r.CacheCreationInputTokens, r.CacheReadInputTokens, r.TotalTokens,
+				r.TotalCost,
+			})
+		})
+	}
+	if err != nil {
+		// 不收尾：截断的导出不能看起来像完整文件，由调用方中断响应
+		return err
+	}
+	return writer.Close()
+}
+
+// apiKeyNames API Key ID → 名称映射（Key 数量有限，一次性加载）
+func (s *UsageExportService) apiKeyNames() map[uint]string {
+	names := make(map[uint]string)
+	keys, err := s.apiKeyRepo.ListAll()
+	if err != nil {
+		return names
+	}
+	for _, k := range keys {
+		names[k.ID] = k.Name
+	}
+	return names
+}
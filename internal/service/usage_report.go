@@ -0,0 +1,444 @@
+/*
+ * 文件作用：周期用量报表服务，按 API Key 生成周报/月报
+ * 负责功能：
+ *   - 报表内容计算（汇总、模型明细、缓存节省、实际费率）
+ *   - 定时生成上一个完整周期的报表
+ *   - Webhook 推送（HMAC-SHA256 签名）
+ *   - 报表下载渲染（JSON/CSV/XLSX）
+ * 重要程度：⭐⭐⭐ 一般（账单报表）
+ * 依赖模块：repository, model, export, pricing
+ */
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"cli-proxy/internal/export"
+	"cli-proxy/internal/model"
+	"cli-proxy/internal/repository"
+	"cli-proxy/pkg/logger"
+)
+
+// 定时检查间隔：每小时检查一次是否有未生成的报表
+const usageReportCheckInterval = time.Hour
+
+// UsageReportLine 报表中的一行用量（模型明细或合计）
+type UsageReportLine struct {
+	Model                    string  `json:"model,omitempty"`
+	RequestCount             int64   `json:"request_count"`
+	InputTokens              int64   `json:"input_tokens"`
+	OutputTokens             int64   `json:"output_tokens"`
+	CacheCreationInputTokens int64   `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int64   `json:"cache_read_input_tokens"`
+	TotalTokens              int64   `json:"total_tokens"`
+	TotalCost                float64 `json:"total_cost"`       // 实际费用（已计算倍率）
+	BaseCost                 float64 `json:"base_cost"`        // 按模型定价计算的原价
+	EffectiveRate            float64 `json:"effective_rate"`   // 实际费用 / 原价
+	CacheSavedCost           float64 `json:"cache_saved_cost"` // Prompt Caching 节省费用（原价）
+}
+
+// UsageReportContent 完整报表内容（存储与 Webhook 推送的 JSON）
+type UsageReportContent struct {
+	APIKeyID    uint              `json:"api_key_id"`
+	APIKeyName  string            `json:"api_key_name"`
+	Period      string            `json:"period"`
+	PeriodStart string            `json:"period_start"`
+	PeriodEnd   string            `json:"period_end"`
+	GeneratedAt time.Time         `json:"generated_at"`
+	Totals      UsageReportLine   `json:"totals"`
+	Models      []UsageReportLine `json:"models"`
+}
+
+// UsageReportService 周期用量报表服务
+type UsageReportService struct {
+	reportRepo     *repository.UsageReportRepository
+	dailyUsageRepo *repository.DailyUsageRepository
+	apiKeyRepo     *repository.APIKeyRepository
+	pricing        *PricingService
+	httpClient     *http.Client
+	log            *logger.Logger
+
+	mu       sync.Mutex
+	running  bool
+	stopChan chan struct{}
+}
+
+var (
+	usageReportService     *UsageReportService
+	usageReportServiceOnce sync.Once
+)
+
+// GetUsageReportService 获取周期用量报表服务单例
+func GetUsageReportService() *UsageReportService {
+	usageReportServiceOnce.Do(func() {
+		usageReportService = &UsageReportService{
+			reportRepo:     repository.NewUsageReportRepository(),
+			dailyUsageRepo: repository.NewDailyUsageRepository(),
+			apiKeyRepo:     repository.NewAPIKeyRepository(),
+			pricing:        NewPricingService(),
+			httpClient:     &http.Client{Timeout: 15 * time.Second},
+			log:            logger.GetLogger("usage_report"),
+		}
+	})
+	return usageReportService
+}
+
+// ReportPeriodRange 计算 now 之前最近一个完整周期的日期范围（包含两端）
+// weekly: 上周一 ~ 上周日；monthly: 上月 1 日 ~ 上月最后一天
+func ReportPeriodRange(period string, now time.Time) (string, string, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch period {
+	case model.ReportPeriodWeekly:
+		monday := today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+		return monday.AddDate(0, 0, -7).Format("2006-01-02"), monday.AddDate(0, 0, -1).Format("2006-01-02"), nil
+	case model.ReportPeriodMonthly:
+		first := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, today.Location())
+		return first.AddDate(0, -1, 0).Format("2006-01-02"), first.AddDate(0, 0, -1).Format("2006-01-02"), nil
+	}
+	return "", "", fmt.Errorf("不支持的报表周期: %s", period)
+}
+
+// buildUsageReportLines 根据按模型汇总的用量计算模型明细和合计
+// priceOf 返回模型定价，找不到定价时返回 nil（该模型原价和缓存节省记为 0）
+func buildUsageReportLines(usages []model.DailyUsage, priceOf func(modelName string) *model.AIModel) ([]UsageReportLine, UsageReportLine) {
+	lines := make([]UsageReportLine, 0, len(usages))
+	var totals UsageReportLine
+
+	for _, u := range usages {
+		line := UsageReportLine{
+			Model:                    u.Model,
+			RequestCount:             u.RequestCount,
+			InputTokens:              u.InputTokens,
+			OutputTokens:             u.OutputTokens,
+			CacheCreationInputTokens: u.CacheCreationInputTokens,
+			CacheReadInputTokens:     u.CacheReadInputTokens,
+			TotalTokens:              u.TotalTokens,
+			TotalCost:                u.TotalCost,
+		}
+		if aiModel := priceOf(u.Model); aiModel != nil {
+			line.BaseCost = (float64(u.InputTokens)*aiModel.InputPrice +
+				float64(u.OutputTokens)*aiModel.OutputPrice +
+				float64(u.CacheCreationInputTokens)*aiModel.CacheCreatePrice +
+				float64(u.CacheReadInputTokens)*aiModel.CacheReadPrice) / 1000000
+			line.CacheSavedCost = promptCacheSavedCost(aiModel, u.CacheReadInputTokens, u.CacheCreationInputTokens)
+		}
+		if line.BaseCost > 0 {
+			line.EffectiveRate = line.TotalCost / line.BaseCost
+		}
+		lines = append(lines, line)
+
+		totals.RequestCount += line.RequestCount
+		totals.InputTokens += line.InputTokens
+		totals.OutputTokens += line.OutputTokens
+		totals.CacheCreationInputTokens += line.CacheCreationInputTokens
+		totals.CacheReadInputTokens += line.CacheReadInputTokens
+		totals.TotalTokens += line.TotalTokens
+		totals.TotalCost += line.TotalCost
+		totals.BaseCost += line.BaseCost
+		totals.CacheSavedCost += line.CacheSavedCost
+	}
+	if totals.BaseCost > 0 {
+		totals.EffectiveRate = totals.TotalCost / totals.BaseCost
+	}
+	return lines, totals
+}
+
+// Generate 生成（或重新生成）指定 API Key 在日期范围内的报表
+func (s *UsageReportService) Generate(ctx context.Context, apiKeyID uint, period, startDate, endDate string) (*model.UsageReport, error) {
+	apiKey, err := s.apiKeyRepo.GetByID(apiKeyID)
+	if err != nil {
+		return nil, fmt.Errorf("API Key %d 不存在", apiKeyID)
+	}
+
+	usages, err := s.dailyUsageRepo.GetAPIKeyModelBreakdown(apiKeyID, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+
+	prices := make(map[string]*model.AIModel)
+	models, totals := buildUsageReportLines(usages, func(name string) *model.AIModel {
+		aiModel, cached := prices[name]
+		if !cached {
+			aiModel, _ = s.pricing.GetModelPricing(ctx, name)
+			prices[name] = aiModel
+		}
+		return aiModel
+	})
+
+	content := UsageReportContent{
+		APIKeyID:    apiKeyID,
+		APIKeyName:  apiKey.Name,
+		Period:      period,
+		PeriodStart: startDate,
+		PeriodEnd:   endDate,
+		GeneratedAt: time.Now(),
+		Totals:      totals,
+		Models:      models,
+	}
+	data, err := json.Marshal(content)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &model.UsageReport{
+		APIKeyID:       apiKeyID,
+		APIKeyName:     apiKey.Name,
+		Period:         period,
+		PeriodStart:    startDate,
+		PeriodEnd:      endDate,
+		RequestCount:   totals.RequestCount,
+		TotalTokens:    totals.TotalTokens,
+		TotalCost:      totals.TotalCost,
+		BaseCost:       totals.BaseCost,
+		EffectiveRate:  totals.EffectiveRate,
+		CacheSavedCost: totals.CacheSavedCost,
+		Content:        string(data),
+		DeliveryStatus: model.ReportDeliveryNone,
+	}
+	if GetConfigService().GetUsageReportWebhookURL() != "" {
+		report.DeliveryStatus = model.ReportDeliveryPending
+	}
+	if err := s.reportRepo.Save(report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// GenerateForPeriod 为日期范围内有用量的所有 API Key 生成报表
+// skipExisting 为 true 时跳过已生成的报表（定时任务使用）
+func (s *UsageReportService) GenerateForPeriod(ctx context.Context, period, startDate, endDate string, skipExisting bool) (int, error) {
+	ids, err := s.dailyUsageRepo.GetActiveAPIKeyIDs(startDate, endDate)
+	if err != nil {
+		return 0, err
+	}
+
+	generated := 0
+	for _, id := range ids {
+		if skipExisting {
+			if exists, err := s.reportRepo.Exists(id, period, startDate, endDate); err != nil || exists {
+				continue
+			}
+		}
+		if _, err := s.Generate(ctx, id, period, startDate, endDate); err != nil {
+			s.log.Error("生成 API Key %d 的 %s 报表失败: %v", id, period, err)
+			continue
+		}
+		generated++
+	}
+	return generated, nil
+}
+
+// RunScheduled 执行一轮定时任务：补齐上一个完整周期的报表，并推送待推送的报表
+func (s *UsageReportService) RunScheduled(ctx context.Context) {
+	cfg := GetConfigService()
+	if !cfg.GetUsageReportEnabled() {
+		return
+	}
+
+	now := time.Now()
+	for _, period := range cfg.GetUsageReportPeriods() {
+		start, end, err := ReportPeriodRange(period, now)
+		if err != nil {
+			continue
+		}
+		generated, err := s.GenerateForPeriod(ctx, period, start, end, true)
+		if err != nil {
+			s.log.Error("生成 %s 报表失败 (%s ~ %s): %v", period, start, end, err)
+			continue
+		}
+		if generated > 0 {
+			s.log.Info("已生成 %d 份 %s 报表 (%s ~ %s)", generated, period, start, end)
+		}
+	}
+
+	s.DeliverPending(ctx)
+}
+
+// DeliverPending 推送所有待推送/推送失败的报表
+func (s *UsageReportService) DeliverPending(ctx context.Context) {
+	if GetConfigService().GetUsageReportWebhookURL() == "" {
+		return
+	}
+	reports, err := s.reportRepo.ListPendingDelivery(100)
+	if err != nil {
+		s.log.Error("查询待推送报表失败: %v", err)
+		return
+	}
+	for i := range reports {
+		if err := s.deliver(ctx, &reports[i]); err != nil {
+			s.log.Warn("报表 %d 推送失败: %v", reports[i].ID, err)
+		}
+	}
+}
+
+// Deliver 立即推送指定报表
+func (s *UsageReportService) Deliver(ctx context.Context, id uint) error {
+	report, err := s.reportRepo.GetByID(id)
+	if err != nil {
+		return err
+	}
+	return s.deliver(ctx, report)
+}
+
+// deliver 以 POST JSON 推送报表内容并记录推送状态
+// 配置了签名密钥时，X-Signature 请求头为 sha256=<hex(HMAC-SHA256(secret, body))>
+func (s *UsageReportService) deliver(ctx context.Context, report *model.UsageReport) error {
+	cfg := GetConfigService()
+	url := cfg.GetUsageReportWebhookURL()
+	if url == "" {
+		return fmt.Errorf("未配置报表推送 Webhook 地址")
+	}
+
+	body := []byte(report.Content)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Report-ID", strconv.FormatUint(uint64(report.ID), 10))
+	if secret := cfg.GetUsageReportWebhookSecret(); secret != "" {
+		req.Header.Set("X-Signature", "sha256="+signUsageReport(secret, body))
+	}
+
+	err = s.post(req)
+	if err != nil {
+		s.reportRepo.UpdateDelivery(report.ID, model.ReportDeliveryFailed, err.Error())
+		return err
+	}
+	return s.reportRepo.UpdateDelivery(report.ID, model.ReportDeliveryDelivered, "")
+}
+
+// post 发送请求，非 2xx 响应视为失败
+func (s *UsageReportService) post(req *http.Request) error {
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
+		return fmt.Errorf("Webhook 返回 %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// signUsageReport 计算报表推送签名
+func signUsageReport(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// GetReport 获取报表（含完整内容）
+func (s *UsageReportService) GetReport(id uint) (*model.UsageReport, *UsageReportContent, error) {
+	report, err := s.reportRepo.GetByID(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	var content UsageReportContent
+	if report.Content != "" {
+		if err := json.Unmarshal([]byte(report.Content), &content); err != nil {
+			return nil, nil, err
+		}
+	}
+	return report, &content, nil
+}
+
+// ListReports 分页查询报表
+func (s *UsageReportService) ListReports(page, pageSize int, apiKeyID uint, period string) ([]model.UsageReport, int64, error) {
+	return s.reportRepo.List(page, pageSize, apiKeyID, period)
+}
+
+// DeleteReport 删除报表
+func (s *UsageReportService) DeleteReport(id uint) error {
+	return s.reportRepo.Delete(id)
+}
+
+var usageReportColumns = []string{
+	"model", "request_count", "input_tokens", "output_tokens", "cache_creation_input_tokens", "cache_read_input_tokens",
+	"total_tokens", "total_cost", "base_cost", "effective_rate", "cache_saved_cost",
+}
+
+// Render 按格式渲染报表用于下载
+// JSON 直接输出存储的完整内容；CSV/XLSX 输出模型明细，最后一行为合计
+func (s *UsageReportService) Render(w io.Writer, report *model.UsageReport, content *UsageReportContent, format string) error {
+	if format == export.FormatJSON {
+		_, err := io.WriteString(w, report.Content)
+		return err
+	}
+
+	writer, err := export.NewWriter(format, w, report.PeriodStart+"~"+report.PeriodEnd)
+	if err != nil {
+		return err
+	}
+	if err := writer.WriteHeader(usageReportColumns); err != nil {
+		return err
+	}
+	totals := content.Totals
+	totals.Model = "合计"
+	for _, line := range append(content.Models, totals) {
+		if err := writer.WriteRow([]interface{}{
+			line.Model, line.RequestCount, line.InputTokens, line.OutputTokens, line.CacheCreationInputTokens,
+			line.CacheReadInputTokens, line.TotalTokens, line.TotalCost, line.BaseCost, line.EffectiveRate,
+			line.CacheSavedCost,
+		}); err != nil {
+			return err
+		}
+	}
+	return writer.Close()
+}
+
+// ==================== 定时任务 ====================
+
+// Start 启动定时任务（每小时检查一次，是否生成由 usage_report_enabled 控制，修改配置无需重启）
+func (s *UsageReportService) Start() {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = true
+	s.stopChan = make(chan struct{})
+	stopChan := s.stopChan
+	s.mu.Unlock()
+
+	go func() {
+		s.log.Info("周期用量报表任务已启动，检查间隔: %v", usageReportCheckInterval)
+		ticker := time.NewTicker(usageReportCheckInterval)
+		defer ticker.Stop()
+
+		s.RunScheduled(context.Background())
+		for {
+			select {
+			case <-ticker.C:
+				s.RunScheduled(context.Background())
+			case <-stopChan:
+				s.log.Info("周期用量报表任务已停止")
+				return
+			}
+		}
+	}()
+}
+
+// Stop 停止定时任务
+func (s *UsageReportService) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.running {
+		return
+	}
+	close(s.stopChan)
+	s.running = false
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"cli-proxy/internal/model"
+)
+
+func TestReportPeriodRange(t *testing.T) {
+	// 2026-10-14 是周三
+	now := time.Date(2026, 10, 14, 9, 0, 0, 0, time.Local)
+
+	start, end, err := ReportPeriodRange(model.ReportPeriodWeekly, now)
+	if err != nil || start != "2026-10-05" || end != "2026-10-11" {
+		t.Fatalf("weekly range = %s ~ %s, %v", start, end, err)
+	}
+
+	// 周一当天取上一整周
+	monday := time.Date(2026, 10, 12, 0, 30, 0, 0, time.Local)
+	if start, end, _ = ReportPeriodRange(model.ReportPeriodWeekly, monday); start != "2026-10-05" || end != "2026-10-11" {
+		t.Fatalf("weekly range on monday = %s ~ %s", start, end)
+	}
+
+	if start, end, _ = ReportPeriodRange(model.ReportPeriodMonthly, time.Date(2026, 3, 1, 0, 0, 0, 0, time.Local)); start != "2026-02-01" || end != "2026-02-28" {
+		t.Fatalf("monthly range = %s ~ %s", start, end)
+	}
+
+	if _, _, err := ReportPeriodRange("daily", now); err == nil {
+		t.Fatal("expected error for unsupported period")
+	}
+}
+
+func TestBuildUsageReportLines(t *testing.T) {
+	prices := map[string]*model.AIModel{
+		"claude-sonnet": {InputPrice: 3, OutputPrice: 15, CacheCreatePrice: 3.75, CacheReadPrice: 0.3},
+	}
+	usages := []model.DailyUsage{
+		{
+			Model: "claude-sonnet", RequestCount: 10, InputTokens: 1000000, OutputTokens: 100000,
+			CacheCreationInputTokens: 200000, CacheReadInputTokens: 1000000, TotalTokens: 2300000,
+			TotalCost: 3.0,
+		},
+		{Model: "unknown", RequestCount: 2, InputTokens: 500, TotalTokens: 500, TotalCost: 0.01},
+	}
+
+	lines, totals := buildUsageReportLines(usages, func(name string) *model.AIModel { return prices[name] })
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d", len(lines))
+	}
+
+	// 原价 = 3 + 1.5 + 0.75 + 0.3 = 5.55
+	sonnet := lines[0]
+	if math.Abs(sonnet.BaseCost-5.55) > 1e-9 {
+		t.Fatalf("base cost = %v", sonnet.BaseCost)
+	}
+	if math.Abs(sonnet.EffectiveRate-3.0/5.55) > 1e-9 {
+		t.Fatalf("effective rate = %v", sonnet.EffectiveRate)
+	}
+	// 节省 = 1M * (3 - 0.3) / 1M - 0.2M * (3.75 - 3) / 1M = 2.7 - 0.15
+	if math.Abs(sonnet.CacheSavedCost-2.55) > 1e-9 {
+		t.Fatalf("cache saved = %v", sonnet.CacheSavedCost)
+	}
+
+	if lines[1].BaseCost != 0 || lines[1].EffectiveRate != 0 {
+		t.Fatalf("model without pricing should have no base cost: %+v", lines[1])
+	}
+
+	if totals.RequestCount != 12 || totals.TotalTokens != 2300500 || math.Abs(totals.TotalCost-3.01) > 1e-9 {
+		t.Fatalf("unexpected totals: %+v", totals)
+	}
+	if math.Abs(totals.EffectiveRate-3.01/5.55) > 1e-9 {
+		t.Fatalf("total effective rate = %v", totals.EffectiveRate)
+	}
+}
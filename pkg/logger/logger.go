@@ -6,6 +6,7 @@
  *   - 结构化日志（JSON格式）
  *   - Context日志追踪（request_id）
  *   - 同时输出到 stdout 和文件（Docker + Web UI 查看）
+ *   - 内存索引（按级别/模块/request_id/account_id 查询）及外部投递
  * 重要程度：⭐⭐⭐⭐ 重要（日志核心工具）
  * 依赖模块：zap, lumberjack
  */
@@ -20,7 +21,6 @@ import (
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
-	"gopkg.in/natefinch/lumberjack.v2"
 )
 
 // 日志级别常量（保持向后兼容）
@@ -67,21 +67,16 @@ func Init(dir string, level int) error {
 		return err
 	}
 
+	// 预热内存索引，重启后仍可查询最近的日志
+	store.warmUp(dir, warmUpLinesPerFile)
 	return nil
 }
 
 // newLogger 创建新的日志器（同时输出到 stdout 和文件）
 func newLogger(module string) (*Logger, error) {
-	// 使用 lumberjack 做日志轮转
+	// 使用 lumberjack 做日志轮转（保留策略可按模块配置）
 	filename := filepath.Join(logDir, fmt.Sprintf("%s.log", module))
-	fileWriter := &lumberjack.Logger{
-		Filename:   filename,
-		MaxSize:    100, // MB
-		MaxBackups: 30,  // 保留30个备份
-		MaxAge:     90,  // 保留90天
-		Compress:   true,
-		LocalTime:  true,
-	}
+	fileWriter := newRotatingWriter(module, filename)
 
 	// 文件编码器配置 - JSON格式（便于前端解析）
 	fileEncoderConfig := zapcore.EncoderConfig{
@@ -115,7 +110,7 @@ func newLogger(module string) (*Logger, error) {
 		EncodeCaller:   zapcore.ShortCallerEncoder,
 	}
 
-	// 创建多输出 core（文件 + stdout）
+	// 创建多输出 core（文件 + stdout + 内存索引）
 	core := zapcore.NewTee(
 		// 文件输出 - JSON格式
 		zapcore.NewCore(
@@ -129,6 +124,8 @@ func newLogger(module string) (*Logger, error) {
 			zapcore.AddSync(os.Stdout),
 			globalLevel,
 		),
+		// 内存索引 + 外部投递
+		&captureCore{LevelEnabler: globalLevel, store: store},
 	)
 
 	// 创建 logger
@@ -239,6 +236,16 @@ var (
 	Err      = zap.Error
 )
 
+// RequestID request_id 字段（会被内存索引收录）
+func RequestID(id string) Field {
+	return zap.String("request_id", id)
+}
+
+// AccountID account_id 字段（会被内存索引收录）
+func AccountID(id uint) Field {
+	return zap.Uint("account_id", id)
+}
+
 // DebugZ 结构化调试日志
 func (l *Logger) DebugZ(msg string, fields ...Field) {
 	l.zap.Debug(msg, fields...)
@@ -359,6 +366,9 @@ func Close() {
 	for _, l := range loggers {
 		l.zap.Sync()
 	}
+
+	// 发送完已排队的日志
+	ConfigureShipper(nil)
 }
 
 // SetLevel 设置全局日志级别
@@ -0,0 +1,131 @@
+/*
+ * 文件作用：日志文件保留策略
+ * 负责功能：
+ *   - 全局默认 + 按模块覆盖的保留策略（单文件大小、备份数、保留天数）
+ *   - 运行时修改策略（替换底层轮转器，已打开的日志器无需重建）
+ * 重要程度：⭐⭐⭐ 一般（运维配置）
+ * 依赖模块：lumberjack
+ */
+package logger
+
+import (
+	"sync"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// RetentionPolicy 日志文件保留策略（0 表示使用默认值）
+type RetentionPolicy struct {
+	MaxSizeMB  int `json:"max_size_mb"`  // 单个文件最大体积（MB），超过后轮转
+	MaxBackups int `json:"max_backups"`  // 保留的轮转文件数
+	MaxAgeDays int `json:"max_age_days"` // 轮转文件保留天数
+}
+
+// 内置默认策略
+var builtinRetention = RetentionPolicy{MaxSizeMB: 100, MaxBackups: 30, MaxAgeDays: 90}
+
+var (
+	retentionMu      sync.RWMutex
+	defaultRetention = builtinRetention
+	moduleRetention  = map[string]RetentionPolicy{}
+	writers          = map[string]*rotatingWriter{}
+)
+
+// withDefaults 用 base 补齐未设置的字段
+func (p RetentionPolicy) withDefaults(base RetentionPolicy) RetentionPolicy {
+	if p.MaxSizeMB <= 0 {
+		p.MaxSizeMB = base.MaxSizeMB
+	}
+	if p.MaxBackups <= 0 {
+		p.MaxBackups = base.MaxBackups
+	}
+	if p.MaxAgeDays <= 0 {
+		p.MaxAgeDays = base.MaxAgeDays
+	}
+	return p
+}
+
+// policyFor 获取模块生效的策略（需持有 retentionMu）
+func policyFor(module string) RetentionPolicy {
+	return moduleRetention[module].withDefaults(defaultRetention)
+}
+
+// SetRetention 设置默认策略和按模块覆盖的策略，立即应用到已打开的日志文件
+func SetRetention(def RetentionPolicy, modules map[string]RetentionPolicy) {
+	retentionMu.Lock()
+	defer retentionMu.Unlock()
+
+	defaultRetention = def.withDefaults(builtinRetention)
+	moduleRetention = make(map[string]RetentionPolicy, len(modules))
+	for module, p := range modules {
+		moduleRetention[module] = p
+	}
+	for module, w := range writers {
+		w.apply(policyFor(module))
+	}
+}
+
+// GetRetention 获取各模块当前生效的策略
+func GetRetention() (RetentionPolicy, map[string]RetentionPolicy) {
+	retentionMu.RLock()
+	defer retentionMu.RUnlock()
+	modules := make(map[string]RetentionPolicy, len(writers))
+	for module := range writers {
+		modules[module] = policyFor(module)
+	}
+	for module := range moduleRetention {
+		modules[module] = policyFor(module)
+	}
+	return defaultRetention, modules
+}
+
+// rotatingWriter 可替换策略的轮转写入器
+// lumberjack 在后台协程中读取 MaxBackups/MaxAge，不能直接修改字段，策略变化时整体替换
+type rotatingWriter struct {
+	mu       sync.Mutex
+	filename string
+	policy   RetentionPolicy
+	lj       *lumberjack.Logger
+}
+
+// newRotatingWriter 创建模块日志文件写入器并登记（用于策略变更）
+func newRotatingWriter(module, filename string) *rotatingWriter {
+	retentionMu.Lock()
+	defer retentionMu.Unlock()
+
+	w := &rotatingWriter{filename: filename}
+	w.apply(policyFor(module))
+	writers[module] = w
+	return w
+}
+
+func (w *rotatingWriter) apply(p RetentionPolicy) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.lj != nil && w.policy == p {
+		return
+	}
+	if w.lj != nil {
+		w.lj.Close()
+	}
+	w.policy = p
+	w.lj = &lumberjack.Logger{
+		Filename:   w.filename,
+		MaxSize:    p.MaxSizeMB,
+		MaxBackups: p.MaxBackups,
+		MaxAge:     p.MaxAgeDays,
+		Compress:   true,
+		LocalTime:  true,
+	}
+}
+
+func (w *rotatingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.lj.Write(p)
+}
+
+func (w *rotatingWriter) Sync() error {
+	return nil
+}
@@ -0,0 +1,390 @@
+/*
+ * 文件作用：系统日志外部投递
+ * 负责功能：
+ *   - 异步批量投递日志到 Loki（/loki/api/v1/push）、通用 HTTP（JSON 数组）或 syslog（RFC 5424，UDP/TCP）
+ *   - 有界队列，满时丢弃并计数（不阻塞业务日志）
+ *   - 投递统计（已发送/丢弃/失败、最近错误）
+ * 重要程度：⭐⭐ 辅助（可选功能）
+ * 依赖模块：无
+ *
+ * 注意：投递过程中不能再写日志，否则会形成递归
+ */
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 投递目标类型
+const (
+	ShipTypeLoki   = "loki"
+	ShipTypeHTTP   = "http"
+	ShipTypeSyslog = "syslog"
+)
+
+const (
+	shipQueueSize      = 10000
+	defaultShipBatch   = 500
+	defaultShipFlush   = 2 * time.Second
+	shipRequestTimeout = 10 * time.Second
+)
+
+// ShipConfig 日志投递配置
+type ShipConfig struct {
+	Type          string            // loki / http / syslog
+	URL           string            // loki/http: 推送地址；syslog: udp://host:514 或 tcp://host:601
+	Labels        map[string]string // 附加标签（loki stream 标签 / http 附加字段 / syslog 主机名用 host 标签）
+	MinLevel      string            // 最低投递级别
+	BatchSize     int
+	FlushInterval time.Duration
+}
+
+// ShipStats 投递统计
+type ShipStats struct {
+	Enabled   bool      `json:"enabled"`
+	Type      string    `json:"type,omitempty"`
+	URL       string    `json:"url,omitempty"`
+	Queued    int       `json:"queued"`
+	Sent      int64     `json:"sent"`
+	Dropped   int64     `json:"dropped"`
+	Failed    int64     `json:"failed"`
+	LastError string    `json:"last_error,omitempty"`
+	LastSent  time.Time `json:"last_sent,omitempty"`
+}
+
+// Shipper 日志投递器
+type Shipper struct {
+	cfg      ShipConfig
+	minLevel int
+	queue    chan Entry
+	stopChan chan struct{}
+	done     chan struct{}
+	client   *http.Client
+
+	sent    atomic.Int64
+	dropped atomic.Int64
+	failed  atomic.Int64
+
+	mu        sync.Mutex
+	lastError string
+	lastSent  time.Time
+}
+
+var activeShipper atomic.Pointer[Shipper]
+
+func currentShipper() *Shipper {
+	return activeShipper.Load()
+}
+
+// ConfigureShipper 启用/替换日志投递（cfg 为 nil 或 URL 为空时关闭），旧投递器会先发送完已排队的日志
+func ConfigureShipper(cfg *ShipConfig) error {
+	var next *Shipper
+	if cfg != nil && strings.TrimSpace(cfg.URL) != "" {
+		s, err := newShipper(*cfg)
+		if err != nil {
+			return err
+		}
+		next = s
+	}
+	if old := activeShipper.Swap(next); old != nil {
+		old.stop()
+	}
+	if next != nil {
+		go next.run()
+	}
+	return nil
+}
+
+// GetShipStats 获取当前投递统计
+func GetShipStats() ShipStats {
+	s := currentShipper()
+	if s == nil {
+		return ShipStats{}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return ShipStats{
+		Enabled:   true,
+		Type:      s.cfg.Type,
+		URL:       s.cfg.URL,
+		Queued:    len(s.queue),
+		Sent:      s.sent.Load(),
+		Dropped:   s.dropped.Load(),
+		Failed:    s.failed.Load(),
+		LastError: s.lastError,
+		LastSent:  s.lastSent,
+	}
+}
+
+func newShipper(cfg ShipConfig) (*Shipper, error) {
+	cfg.Type = strings.ToLower(strings.TrimSpace(cfg.Type))
+	cfg.URL = strings.TrimSpace(cfg.URL)
+	switch cfg.Type {
+	case ShipTypeLoki:
+		u, err := url.Parse(cfg.URL)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("无效的 Loki 地址: %s", cfg.URL)
+		}
+		if u.Path == "" || u.Path == "/" {
+			u.Path = "/loki/api/v1/push"
+			cfg.URL = u.String()
+		}
+	case ShipTypeHTTP:
+		if u, err := url.Parse(cfg.URL); err != nil || u.Host == "" {
+			return nil, fmt.Errorf("无效的 HTTP 地址: %s", cfg.URL)
+		}
+	case ShipTypeSyslog:
+		u, err := url.Parse(cfg.URL)
+		if err != nil || u.Host == "" || (u.Scheme != "udp" && u.Scheme != "tcp") {
+			return nil, fmt.Errorf("无效的 syslog 地址（应为 udp://host:port 或 tcp://host:port）: %s", cfg.URL)
+		}
+	default:
+		return nil, fmt.Errorf("不支持的投递类型: %s", cfg.Type)
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultShipBatch
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = defaultShipFlush
+	}
+	return &Shipper{
+		cfg:      cfg,
+		minLevel: ParseLevel(cfg.MinLevel),
+		queue:    make(chan Entry, shipQueueSize),
+		stopChan: make(chan struct{}),
+		done:     make(chan struct{}),
+		client:   &http.Client{Timeout: shipRequestTimeout},
+	}, nil
+}
+
+// Enqueue 加入投递队列（队列满时丢弃）
+func (s *Shipper) Enqueue(e Entry) {
+	if ParseLevel(e.Level) < s.minLevel {
+		return
+	}
+	select {
+	case s.queue <- e:
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+func (s *Shipper) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]Entry, 0, s.cfg.BatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		s.send(batch)
+		batch = batch[:0]
+	}
+	for {
+		select {
+		case e := <-s.queue:
+			batch = append(batch, e)
+			if len(batch) >= s.cfg.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-s.stopChan:
+			for n := len(s.queue); n > 0; n-- {
+				batch = append(batch, <-s.queue)
+			}
+			flush()
+			return
+		}
+	}
+}
+
+func (s *Shipper) stop() {
+	close(s.stopChan)
+	<-s.done
+}
+
+func (s *Shipper) send(batch []Entry) {
+	var err error
+	switch s.cfg.Type {
+	case ShipTypeLoki:
+		err = s.postJSON(buildLokiPush(batch, s.cfg.Labels))
+	case ShipTypeHTTP:
+		err = s.postJSON(buildHTTPPayload(batch, s.cfg.Labels))
+	case ShipTypeSyslog:
+		err = s.sendSyslog(batch)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.failed.Add(int64(len(batch)))
+		s.lastError = err.Error()
+		return
+	}
+	s.sent.Add(int64(len(batch)))
+	s.lastSent = time.Now()
+}
+
+func (s *Shipper) postJSON(payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Post(s.cfg.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("投递失败: HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// entryLine 单条日志的 JSON 行（与日志文件格式一致）
+func entryLine(e *Entry) string {
+	data := make(map[string]interface{}, len(e.Fields)+6)
+	for k, v := range e.Fields {
+		data[k] = v
+	}
+	data["timestamp"] = e.Time.Format("2006-01-02T15:04:05.000Z0700")
+	data["level"] = e.Level
+	data["module"] = e.Module
+	data["message"] = e.Message
+	if e.Caller != "" {
+		data["caller"] = e.Caller
+	}
+	if e.RequestID != "" {
+		data["request_id"] = e.RequestID
+	}
+	if e.AccountID != "" {
+		data["account_id"] = e.AccountID
+	}
+	line, _ := json.Marshal(data)
+	return string(line)
+}
+
+// lokiStream Loki push 接口的 stream
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+// buildLokiPush 按 module+level 分组构建 Loki push 请求体
+func buildLokiPush(batch []Entry, labels map[string]string) map[string]interface{} {
+	streams := make(map[string]*lokiStream)
+	keys := make([]string, 0)
+	for i := range batch {
+		e := &batch[i]
+		key := e.Module + "|" + e.Level
+		st, ok := streams[key]
+		if !ok {
+			st = &lokiStream{Stream: map[string]string{"module": e.Module, "level": strings.ToLower(e.Level)}}
+			for k, v := range labels {
+				st.Stream[k] = v
+			}
+			streams[key] = st
+			keys = append(keys, key)
+		}
+		st.Values = append(st.Values, [2]string{strconv.FormatInt(e.Time.UnixNano(), 10), entryLine(e)})
+	}
+	sort.Strings(keys)
+	list := make([]*lokiStream, 0, len(keys))
+	for _, key := range keys {
+		list = append(list, streams[key])
+	}
+	return map[string]interface{}{"streams": list}
+}
+
+// buildHTTPPayload 通用 HTTP 投递请求体：JSON 数组，每项附加标签
+func buildHTTPPayload(batch []Entry, labels map[string]string) []json.RawMessage {
+	list := make([]json.RawMessage, 0, len(batch))
+	for i := range batch {
+		line := entryLine(&batch[i])
+		if len(labels) > 0 {
+			var data map[string]interface{}
+			json.Unmarshal([]byte(line), &data)
+			for k, v := range labels {
+				if _, exists := data[k]; !exists {
+					data[k] = v
+				}
+			}
+			b, _ := json.Marshal(data)
+			line = string(b)
+		}
+		list = append(list, json.RawMessage(line))
+	}
+	return list
+}
+
+// syslogSeverity 日志级别对应的 syslog 严重级别（facility 使用 local0）
+func syslogSeverity(level string) int {
+	switch strings.ToUpper(level) {
+	case "DEBUG":
+		return 7
+	case "INFO":
+		return 6
+	case "WARN":
+		return 4
+	case "ERROR":
+		return 3
+	default:
+		return 2 // DPANIC/PANIC/FATAL
+	}
+}
+
+// formatSyslog 格式化 RFC 5424 消息（MSG 为 JSON 行）
+func formatSyslog(e *Entry, hostname string) string {
+	const facilityLocal0 = 16
+	appName := e.Module
+	if appName == "" {
+		appName = "-"
+	}
+	return fmt.Sprintf("<%d>1 %s %s cli-proxy %s - %s",
+		facilityLocal0*8+syslogSeverity(e.Level),
+		e.Time.Format(time.RFC3339Nano), hostname, appName, entryLine(e))
+}
+
+func (s *Shipper) sendSyslog(batch []Entry) error {
+	u, _ := url.Parse(s.cfg.URL)
+	conn, err := net.DialTimeout(u.Scheme, u.Host, shipRequestTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetWriteDeadline(time.Now().Add(shipRequestTimeout))
+
+	hostname := s.cfg.Labels["host"]
+	if hostname == "" {
+		hostname, _ = os.Hostname()
+	}
+	if hostname == "" {
+		hostname = "-"
+	}
+	for i := range batch {
+		msg := formatSyslog(&batch[i], hostname)
+		if u.Scheme == "tcp" {
+			// RFC 6587 octet-counting 分帧
+			msg = strconv.Itoa(len(msg)) + " " + msg
+		}
+		if _, err := conn.Write([]byte(msg)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,428 @@
+/*
+ * 文件作用：系统日志内存索引存储
+ * 负责功能：
+ *   - 捕获所有模块的结构化日志（zap Core）
+ *   - 有界环形缓冲区 + 按级别/模块/request_id/account_id 倒排索引
+ *   - 按时间范围、全文关键字查询（游标分页，最新在前）
+ *   - 启动时从日志文件预热最近的日志
+ * 重要程度：⭐⭐⭐ 一般（运维观测）
+ * 依赖模块：zap
+ */
+package logger
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// 默认内存索引容量（条）
+	defaultStoreCapacity = 50000
+	// 预热时每个日志文件读取的最大行数
+	warmUpLinesPerFile = 2000
+	// 单次查询最大返回条数
+	maxQueryLimit = 1000
+)
+
+// Entry 索引中的日志条目
+type Entry struct {
+	Seq       uint64                 `json:"seq"`
+	Time      time.Time              `json:"timestamp"`
+	Level     string                 `json:"level"`
+	Module    string                 `json:"module"`
+	Message   string                 `json:"message"`
+	Caller    string                 `json:"caller,omitempty"`
+	RequestID string                 `json:"request_id,omitempty"`
+	AccountID string                 `json:"account_id,omitempty"`
+	Fields    map[string]interface{} `json:"fields,omitempty"`
+
+	text string // 小写的全文检索内容
+}
+
+// Query 日志查询条件（零值表示不过滤）
+type Query struct {
+	Levels    []string // 级别（任一匹配，大小写不敏感）
+	Module    string
+	RequestID string
+	AccountID string
+	Since     time.Time
+	Until     time.Time
+	Text      string // 消息/字段关键字（大小写不敏感）
+	BeforeSeq uint64 // 游标：只返回 Seq 小于该值的条目
+	Limit     int
+}
+
+// QueryResult 查询结果
+type QueryResult struct {
+	Items      []Entry   `json:"items"`
+	NextBefore uint64    `json:"next_before_seq,omitempty"` // 为 0 表示没有更多
+	Oldest     time.Time `json:"oldest"`                    // 索引中最早的日志时间（更早的需查文件）
+	Size       int       `json:"size"`
+	Capacity   int       `json:"capacity"`
+}
+
+// Store 日志内存索引
+type Store struct {
+	mu    sync.RWMutex
+	seq   uint64
+	ring  []Entry
+	size  int
+	index map[string][]uint64 // 倒排索引：维度前缀 + 值 -> 升序 Seq 列表
+}
+
+// NewStore 创建日志索引（capacity 为最多保留的条数）
+func NewStore(capacity int) *Store {
+	if capacity <= 0 {
+		capacity = defaultStoreCapacity
+	}
+	return &Store{
+		ring:  make([]Entry, capacity),
+		index: make(map[string][]uint64),
+	}
+}
+
+// 索引键
+func levelKey(v string) string     { return "l:" + strings.ToUpper(v) }
+func moduleKey(v string) string    { return "m:" + v }
+func requestIDKey(v string) string { return "r:" + v }
+func accountIDKey(v string) string { return "a:" + v }
+
+func (e *Entry) indexKeys() []string {
+	keys := []string{levelKey(e.Level), moduleKey(e.Module)}
+	if e.RequestID != "" {
+		keys = append(keys, requestIDKey(e.RequestID))
+	}
+	if e.AccountID != "" {
+		keys = append(keys, accountIDKey(e.AccountID))
+	}
+	return keys
+}
+
+// Add 写入一条日志（容量满时淘汰最旧的条目）
+func (s *Store) Add(e Entry) {
+	if e.text == "" {
+		e.text = buildEntryText(&e)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seq++
+	e.Seq = s.seq
+	slot := int((e.Seq - 1) % uint64(len(s.ring)))
+	if s.size == len(s.ring) {
+		// 被淘汰的条目一定是各索引列表的第一个
+		old := &s.ring[slot]
+		for _, key := range old.indexKeys() {
+			list := s.index[key]
+			if len(list) > 0 && list[0] == old.Seq {
+				list = list[1:]
+			}
+			if len(list) == 0 {
+				delete(s.index, key)
+			} else {
+				s.index[key] = list
+			}
+		}
+	} else {
+		s.size++
+	}
+	s.ring[slot] = e
+	for _, key := range e.indexKeys() {
+		s.index[key] = append(s.index[key], e.Seq)
+	}
+}
+
+// Query 按条件查询（最新在前）
+func (s *Store) Query(q Query) QueryResult {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	if limit > maxQueryLimit {
+		limit = maxQueryLimit
+	}
+	text := strings.ToLower(strings.TrimSpace(q.Text))
+	levels := make(map[string]bool, len(q.Levels))
+	for _, l := range q.Levels {
+		if l = strings.TrimSpace(l); l != "" {
+			levels[strings.ToUpper(l)] = true
+		}
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := QueryResult{Items: make([]Entry, 0), Size: s.size, Capacity: len(s.ring)}
+	if s.size == 0 {
+		return result
+	}
+	result.Oldest = s.get(s.seq - uint64(s.size) + 1).Time
+
+	match := func(e *Entry) bool {
+		if len(levels) > 0 && !levels[strings.ToUpper(e.Level)] {
+			return false
+		}
+		if q.Module != "" && e.Module != q.Module {
+			return false
+		}
+		if q.RequestID != "" && e.RequestID != q.RequestID {
+			return false
+		}
+		if q.AccountID != "" && e.AccountID != q.AccountID {
+			return false
+		}
+		if !q.Since.IsZero() && e.Time.Before(q.Since) {
+			return false
+		}
+		if !q.Until.IsZero() && e.Time.After(q.Until) {
+			return false
+		}
+		return text == "" || strings.Contains(e.text, text)
+	}
+	collect := func(e *Entry) bool {
+		if len(result.Items) == limit {
+			result.NextBefore = result.Items[limit-1].Seq
+			return false
+		}
+		if match(e) {
+			result.Items = append(result.Items, *e)
+		}
+		return true
+	}
+
+	// 优先使用最短的倒排列表，否则倒序扫描整个缓冲区
+	if candidates, ok := s.candidates(&q, levels); ok {
+		end := len(candidates)
+		if q.BeforeSeq > 0 {
+			end = sort.Search(len(candidates), func(i int) bool { return candidates[i] >= q.BeforeSeq })
+		}
+		for i := end - 1; i >= 0; i-- {
+			if !collect(s.get(candidates[i])) {
+				break
+			}
+		}
+		return result
+	}
+
+	first := s.seq - uint64(s.size) + 1
+	last := s.seq
+	if q.BeforeSeq > 0 && q.BeforeSeq <= last {
+		last = q.BeforeSeq - 1
+	}
+	for seq := last; seq >= first && seq > 0; seq-- {
+		if !collect(s.get(seq)) {
+			break
+		}
+	}
+	return result
+}
+
+// candidates 选出最短的倒排列表（没有可用索引维度时返回 false）
+func (s *Store) candidates(q *Query, levels map[string]bool) ([]uint64, bool) {
+	keys := make([]string, 0, 4)
+	if q.RequestID != "" {
+		keys = append(keys, requestIDKey(q.RequestID))
+	}
+	if q.AccountID != "" {
+		keys = append(keys, accountIDKey(q.AccountID))
+	}
+	if q.Module != "" {
+		keys = append(keys, moduleKey(q.Module))
+	}
+	if len(levels) == 1 {
+		for l := range levels {
+			keys = append(keys, levelKey(l))
+		}
+	}
+	if len(keys) == 0 {
+		return nil, false
+	}
+	best := s.index[keys[0]]
+	for _, key := range keys[1:] {
+		if list := s.index[key]; len(list) < len(best) {
+			best = list
+		}
+	}
+	return best, true
+}
+
+func (s *Store) get(seq uint64) *Entry {
+	return &s.ring[int((seq-1)%uint64(len(s.ring)))]
+}
+
+// Modules 索引中出现过的模块及其条数
+func (s *Store) Modules() map[string]int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	modules := make(map[string]int)
+	for key, list := range s.index {
+		if strings.HasPrefix(key, "m:") {
+			modules[key[2:]] = len(list)
+		}
+	}
+	return modules
+}
+
+// buildEntryText 构建全文检索内容
+func buildEntryText(e *Entry) string {
+	var b strings.Builder
+	b.WriteString(e.Message)
+	b.WriteByte(' ')
+	b.WriteString(e.Caller)
+	for k, v := range e.Fields {
+		fmt.Fprintf(&b, " %s=%v", k, v)
+	}
+	return strings.ToLower(b.String())
+}
+
+// entryFromFields 从结构化字段构建条目（提取 request_id/account_id）
+func entryFromFields(t time.Time, level, module, message, caller string, fields map[string]interface{}) Entry {
+	e := Entry{Time: t, Level: level, Module: module, Message: message, Caller: caller}
+	if v, ok := fields["request_id"]; ok {
+		e.RequestID = fmt.Sprint(v)
+		delete(fields, "request_id")
+	}
+	if v, ok := fields["account_id"]; ok {
+		e.AccountID = fmt.Sprint(v)
+		delete(fields, "account_id")
+	}
+	delete(fields, "stacktrace")
+	if len(fields) > 0 {
+		e.Fields = fields
+	}
+	return e
+}
+
+// ============ zap Core：捕获日志写入索引和外部投递 ============
+
+// captureCore 将日志写入内存索引，并转交给外部投递（如已启用）
+type captureCore struct {
+	zapcore.LevelEnabler
+	fields []zapcore.Field
+	store  *Store
+}
+
+func (c *captureCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.fields = append(append(make([]zapcore.Field, 0, len(c.fields)+len(fields)), c.fields...), fields...)
+	return &clone
+}
+
+func (c *captureCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *captureCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range c.fields {
+		f.AddTo(enc)
+	}
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+	caller := ""
+	if ent.Caller.Defined {
+		caller = ent.Caller.TrimmedPath()
+	}
+	e := entryFromFields(ent.Time, ent.Level.CapitalString(), ent.LoggerName, ent.Message, caller, enc.Fields)
+	e.text = buildEntryText(&e)
+	c.store.Add(e)
+	if s := currentShipper(); s != nil {
+		s.Enqueue(e)
+	}
+	return nil
+}
+
+func (c *captureCore) Sync() error {
+	return nil
+}
+
+// ============ 全局索引 ============
+
+var store = NewStore(defaultStoreCapacity)
+
+// GetStore 获取全局日志索引
+func GetStore() *Store {
+	return store
+}
+
+// QueryLogs 查询全局日志索引
+func QueryLogs(q Query) QueryResult {
+	return store.Query(q)
+}
+
+// warmUp 从日志目录预热最近的日志（每个当前日志文件读取末尾若干行，按时间排序后写入）
+func (s *Store) warmUp(dir string, linesPerFile int) {
+	files, _ := filepath.Glob(filepath.Join(dir, "*.log"))
+	entries := make([]Entry, 0)
+	for _, file := range files {
+		for _, line := range readLastLines(file, linesPerFile) {
+			if e, ok := parseJSONLine(line); ok {
+				entries = append(entries, e)
+			}
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Time.Before(entries[j].Time) })
+	if len(entries) > len(s.ring) {
+		entries = entries[len(entries)-len(s.ring):]
+	}
+	for _, e := range entries {
+		s.Add(e)
+	}
+}
+
+// parseJSONLine 解析日志文件中的一行 JSON
+func parseJSONLine(line string) (Entry, bool) {
+	var data map[string]interface{}
+	if err := json.Unmarshal([]byte(line), &data); err != nil {
+		return Entry{}, false
+	}
+	str := func(key string) string {
+		v, _ := data[key].(string)
+		delete(data, key)
+		return v
+	}
+	t, err := time.Parse("2006-01-02T15:04:05.000Z0700", str("timestamp"))
+	if err != nil {
+		return Entry{}, false
+	}
+	level, module, message, caller := str("level"), str("module"), str("message"), str("caller")
+	return entryFromFields(t, level, module, message, caller, data), true
+}
+
+// readLastLines 读取文件末尾 n 行（日志文件单个最大约百 MB，只读取末尾部分）
+func readLastLines(path string, n int) []string {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	const maxTailBytes = 4 << 20
+	if info, err := f.Stat(); err == nil && info.Size() > maxTailBytes {
+		f.Seek(info.Size()-maxTailBytes, 0)
+	}
+	lines := make([]string, 0, n)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+		if len(lines) > n {
+			lines = lines[1:]
+		}
+	}
+	return lines
+}
@@ -0,0 +1,166 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestStoreQueryByIndex(t *testing.T) {
+	s := NewStore(100)
+	base := time.Now()
+	for i := 0; i < 10; i++ {
+		level := "INFO"
+		if i%3 == 0 {
+			level = "ERROR"
+		}
+		s.Add(Entry{
+			Time:      base.Add(time.Duration(i) * time.Second),
+			Level:     level,
+			Module:    []string{"proxy", "scheduler"}[i%2],
+			Message:   fmt.Sprintf("message %d", i),
+			RequestID: fmt.Sprintf("req-%d", i%4),
+			AccountID: fmt.Sprint(i % 5),
+		})
+	}
+
+	res := s.Query(Query{Levels: []string{"error"}})
+	if len(res.Items) != 4 || res.Items[0].Message != "message 9" {
+		t.Fatalf("unexpected level result: %+v", res.Items)
+	}
+	res = s.Query(Query{Module: "proxy", RequestID: "req-2"})
+	if len(res.Items) != 2 {
+		t.Fatalf("expected 2 entries for proxy/req-2, got %d", len(res.Items))
+	}
+	res = s.Query(Query{AccountID: "3", Text: "MESSAGE 8"})
+	if len(res.Items) != 1 || res.Items[0].Message != "message 8" {
+		t.Fatalf("unexpected text result: %+v", res.Items)
+	}
+	res = s.Query(Query{Since: base.Add(7 * time.Second), Until: base.Add(8 * time.Second)})
+	if len(res.Items) != 2 {
+		t.Fatalf("expected 2 entries in time range, got %d", len(res.Items))
+	}
+}
+
+func TestStoreCursorAndEviction(t *testing.T) {
+	s := NewStore(5)
+	for i := 0; i < 8; i++ {
+		s.Add(Entry{Time: time.Now(), Level: "INFO", Module: "main", Message: fmt.Sprint(i), RequestID: fmt.Sprint(i % 2)})
+	}
+
+	res := s.Query(Query{Limit: 2})
+	if len(res.Items) != 2 || res.Items[0].Seq != 8 || res.NextBefore != 7 {
+		t.Fatalf("unexpected first page: %+v next=%d", res.Items, res.NextBefore)
+	}
+	res = s.Query(Query{Limit: 10, BeforeSeq: res.NextBefore})
+	if len(res.Items) != 3 || res.Items[2].Seq != 4 || res.NextBefore != 0 {
+		t.Fatalf("unexpected second page: %+v next=%d", res.Items, res.NextBefore)
+	}
+
+	// 淘汰后索引也应只保留仍在缓冲区中的条目
+	res = s.Query(Query{RequestID: "0"})
+	if len(res.Items) != 2 || res.Items[1].Seq != 5 {
+		t.Fatalf("unexpected indexed result after eviction: %+v", res.Items)
+	}
+	if got := s.Modules()["main"]; got != 5 {
+		t.Fatalf("expected 5 indexed entries for main, got %d", got)
+	}
+}
+
+func TestCaptureCoreExtractsFields(t *testing.T) {
+	s := NewStore(10)
+	core := &captureCore{LevelEnabler: zapcore.DebugLevel, store: s}
+	log := zap.New(core).Named("proxy").With(zap.String("request_id", "abc"))
+	log.Warn("upstream failed", zap.Uint("account_id", 7), zap.Int("status", 529))
+
+	res := s.Query(Query{AccountID: "7"})
+	if len(res.Items) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(res.Items))
+	}
+	e := res.Items[0]
+	if e.Module != "proxy" || e.Level != "WARN" || e.RequestID != "abc" || e.Fields["status"] != int64(529) {
+		t.Fatalf("unexpected entry: %+v", e)
+	}
+	if len(s.Query(Query{Text: "status=529"}).Items) != 1 {
+		t.Fatal("expected field values to be searchable")
+	}
+}
+
+func TestParseJSONLine(t *testing.T) {
+	line := `{"level":"ERROR","timestamp":"2026-03-01T10:00:00.123+0800","module":"scheduler","caller":"a.go:1","message":"boom","request_id":"r1","account_id":12,"extra":"x"}`
+	e, ok := parseJSONLine(line)
+	if !ok {
+		t.Fatal("expected line to parse")
+	}
+	if e.Module != "scheduler" || e.RequestID != "r1" || e.AccountID != "12" || e.Fields["extra"] != "x" {
+		t.Fatalf("unexpected entry: %+v", e)
+	}
+	if _, ok := parseJSONLine("plain text"); ok {
+		t.Fatal("expected plain text to be rejected")
+	}
+}
+
+func TestShipperLoki(t *testing.T) {
+	received := make(chan map[string]interface{}, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/loki/api/v1/push" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		received <- body
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	s, err := newShipper(ShipConfig{Type: "loki", URL: srv.URL, Labels: map[string]string{"env": "test"}, MinLevel: "warn"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.run()
+	s.Enqueue(Entry{Time: time.Now(), Level: "INFO", Module: "proxy", Message: "skipped"})
+	s.Enqueue(Entry{Time: time.Now(), Level: "ERROR", Module: "proxy", Message: "shipped"})
+	s.stop()
+
+	body := <-received
+	streams := body["streams"].([]interface{})
+	if len(streams) != 1 {
+		t.Fatalf("expected 1 stream, got %d", len(streams))
+	}
+	stream := streams[0].(map[string]interface{})
+	labels := stream["stream"].(map[string]interface{})
+	if labels["env"] != "test" || labels["level"] != "error" {
+		t.Fatalf("unexpected labels: %v", labels)
+	}
+	values := stream["values"].([]interface{})
+	if len(values) != 1 || !strings.Contains(values[0].([]interface{})[1].(string), "shipped") {
+		t.Fatalf("unexpected values: %v", values)
+	}
+	if s.sent.Load() != 1 {
+		t.Fatalf("expected 1 sent, got %d", s.sent.Load())
+	}
+}
+
+func TestShipperRejectsInvalidConfig(t *testing.T) {
+	if _, err := newShipper(ShipConfig{Type: "syslog", URL: "http://host:514"}); err == nil {
+		t.Fatal("expected syslog with http scheme to be rejected")
+	}
+	if _, err := newShipper(ShipConfig{Type: "kafka", URL: "http://host"}); err == nil {
+		t.Fatal("expected unknown type to be rejected")
+	}
+}
+
+func TestFormatSyslog(t *testing.T) {
+	e := Entry{Time: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), Level: "WARN", Module: "proxy", Message: "hi"}
+	msg := formatSyslog(&e, "node1")
+	if !strings.HasPrefix(msg, "<132>1 2026-03-01T00:00:00Z node1 cli-proxy proxy - {") {
+		t.Fatalf("unexpected syslog message: %s", msg)
+	}
+}
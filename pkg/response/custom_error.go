@@ -5,13 +5,17 @@
  *   - 错误类型映射
  *   - 便捷错误响应方法
  *   - 错误响应中断处理
+ *   - 流式响应中途出错时的 SSE 错误事件
+ *   - 按 API Key 偏好语言 / Accept-Language 选择消息语言并渲染占位符
  * 重要程度：⭐⭐⭐ 一般（错误响应辅助）
  * 依赖模块：model, service, gin
  */
 package response
 
 import (
+	"io"
 	"net/http"
+	"strconv"
 
 	"cli-proxy/internal/model"
 	"cli-proxy/internal/service"
@@ -19,22 +23,77 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// requestModelCtxKey 处理器解析出的请求模型（与 middleware.PublishRequestStart 写入的 key 一致）
+const requestModelCtxKey = "request_event_model"
+
+// quotaRemainingHeaders 剩余额度响应头（按优先级，由限流中间件写入）
+var quotaRemainingHeaders = []string{
+	"x-ratelimit-remaining-tokens",
+	"anthropic-ratelimit-tokens-remaining",
+	"x-ratelimit-remaining-requests",
+	"anthropic-ratelimit-requests-remaining",
+}
+
 // CustomError 返回自定义错误消息
-// 根据 errorType 查找自定义消息配置，如果启用则返回自定义消息，否则返回 originalError
+// 根据 errorType 查找自定义消息配置，如果启用则返回本地化的自定义消息，否则返回 originalError
+// 代理接口按调用方协议输出错误结构
 // code: HTTP 状态码
 // errorType: 错误类型标识（如 model.ErrorTypeAuthFailed）
 // originalError: 原始错误消息
 func CustomError(c *gin.Context, code int, errorType, originalError string) {
-	errorMsgService := service.GetErrorMessageService()
-	customMessage, shouldLog := errorMsgService.GetCustomMessage(errorType, originalError)
+	message, custom := LocalizedMessage(c, code, errorType, originalError)
 
-	if shouldLog {
+	if custom {
 		// 使用自定义消息，需要记录原始错误
-		ErrorWithLog(c, code, customMessage, originalError, errorType)
+		ProtocolError(c, code, errorType, message, originalError)
 	} else {
 		// 未启用自定义消息，直接返回原始错误
-		Error(c, code, originalError)
+		ProtocolError(c, code, errorType, originalError, "")
+	}
+}
+
+// CustomStreamError 流式响应已开始后，按调用方协议输出本地化的 SSE 错误事件
+// 消息选择规则与 CustomError 一致
+func CustomStreamError(c *gin.Context, w io.Writer, code int, errorType, originalError string) {
+	message, custom := LocalizedMessage(c, code, errorType, originalError)
+	if custom {
+		ProtocolStreamError(c, w, code, errorType, message, originalError)
+	} else {
+		ProtocolStreamError(c, w, code, errorType, originalError, "")
+	}
+}
+
+// LocalizedMessage 获取当前请求语言下的自定义错误消息（未启用自定义消息时返回原始错误和 false）
+func LocalizedMessage(c *gin.Context, code int, errorType, originalError string) (string, bool) {
+	data := &service.ErrorMessageData{
+		Model:     c.GetString(requestModelCtxKey),
+		RequestID: c.GetString("request_id"),
+		Status:    code,
+		ErrorType: errorType,
+	}
+	header := c.Writer.Header()
+	if v, err := strconv.Atoi(header.Get("Retry-After")); err == nil {
+		data.RetryAfter = v
+	}
+	for _, name := range quotaRemainingHeaders {
+		if v := header.Get(name); v != "" {
+			data.QuotaRemaining = v
+			break
+		}
+	}
+
+	return service.GetErrorMessageService().Localize(errorType, originalError, RequestLocales(c), data)
+}
+
+// RequestLocales 当前请求的候选语言（API Key 偏好语言优先，其次 Accept-Language）
+func RequestLocales(c *gin.Context) []string {
+	preferred := ""
+	if v, ok := c.Get("api_key"); ok {
+		if key, ok := v.(*model.APIKey); ok {
+			preferred = key.PreferredLocale
+		}
 	}
+	return service.NegotiateLocales(preferred, c.GetHeader("Accept-Language"))
 }
 
 // CustomErrorAbort 返回自定义错误消息并中断请求
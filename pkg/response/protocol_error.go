@@ -0,0 +1,199 @@
+/*
+ * 文件作用：按调用方协议格式化错误响应
+ * 负责功能：
+ *   - 根据请求路径识别协议（Anthropic / OpenAI / Gemini / 管理接口）
+ *   - 输出对应协议的错误结构（Anthropic error envelope、OpenAI error object、Gemini error）
+ *   - 流式响应中途出错时输出对应协议的 SSE 错误事件
+ *   - 记录被替换的原始错误
+ * 重要程度：⭐⭐⭐ 一般（SDK 依赖错误结构解析重试与提示）
+ * 依赖模块：gin
+ */
+package response
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Protocol 错误响应协议
+type Protocol string
+
+const (
+	ProtocolGeneric   Protocol = "generic"   // 管理接口统一格式 {code, message}
+	ProtocolAnthropic Protocol = "anthropic" // /claude/*
+	ProtocolOpenAI    Protocol = "openai"    // /openai/*、/responses、/v1/responses
+	ProtocolGemini    Protocol = "gemini"    // /gemini/*
+)
+
+// DetectProtocol 根据请求路径识别调用方协议
+func DetectProtocol(path string) Protocol {
+	switch {
+	case strings.HasPrefix(path, "/claude/"):
+		return ProtocolAnthropic
+	case strings.HasPrefix(path, "/gemini/"):
+		return ProtocolGemini
+	case strings.HasPrefix(path, "/openai/"),
+		strings.HasPrefix(path, "/responses"),
+		strings.HasPrefix(path, "/v1/responses"):
+		return ProtocolOpenAI
+	default:
+		return ProtocolGeneric
+	}
+}
+
+// ProtocolError 按调用方协议返回错误
+// message: 返回给用户的消息
+// originalError: 被替换的原始错误（与 message 不同时记录到日志，不返回给用户）
+// errorType: 错误类型标识（OpenAI 格式的 code 字段，以及日志分类）
+func ProtocolError(c *gin.Context, code int, errorType, message, originalError string) {
+	logReplacedError(c, code, errorType, message, originalError)
+
+	protocol := DetectProtocol(c.Request.URL.Path)
+	if protocol == ProtocolGeneric {
+		c.JSON(code, Response{
+			Code:    code,
+			Message: message,
+		})
+		return
+	}
+	c.JSON(code, protocolErrorBody(protocol, code, errorType, message, c.GetString("request_id")))
+}
+
+// ProtocolStreamError 流式响应已开始后，按调用方协议以 SSE 事件输出错误
+// Anthropic 使用 event: error 事件，OpenAI / Gemini 使用 data 行；参数含义同 ProtocolError
+func ProtocolStreamError(c *gin.Context, w io.Writer, code int, errorType, message, originalError string) {
+	logReplacedError(c, code, errorType, message, originalError)
+
+	protocol := DetectProtocol(c.Request.URL.Path)
+	var body interface{}
+	if protocol == ProtocolGeneric {
+		body = Response{Code: code, Message: message}
+	} else {
+		body = protocolErrorBody(protocol, code, errorType, message, c.GetString("request_id"))
+	}
+	data, _ := json.Marshal(body)
+
+	if protocol == ProtocolAnthropic {
+		w.Write([]byte("event: error\n"))
+	}
+	w.Write([]byte("data: " + string(data) + "\n\n"))
+}
+
+// logReplacedError 返回消息与原始错误不同时记录原始错误
+func logReplacedError(c *gin.Context, code int, errorType, message, originalError string) {
+	if originalError == "" || originalError == message {
+		return
+	}
+	if log := getErrorLog(); log != nil {
+		log.Warn("[%s] %s | IP: %s | Path: %s | Type: %s | Original: %s | Return: %s",
+			getCodeLabel(code), c.GetString("request_id"), c.ClientIP(), c.Request.URL.Path, errorType, originalError, message)
+	}
+}
+
+// protocolErrorBody 构建协议错误结构（不含管理接口格式）
+func protocolErrorBody(protocol Protocol, code int, errorType, message, requestID string) gin.H {
+	switch protocol {
+	case ProtocolAnthropic:
+		body := gin.H{
+			"type": "error",
+			"error": gin.H{
+				"type":    anthropicErrorType(code),
+				"message": message,
+			},
+		}
+		if requestID != "" {
+			body["request_id"] = requestID
+		}
+		return body
+	case ProtocolOpenAI:
+		return gin.H{
+			"error": gin.H{
+				"message": message,
+				"type":    openAIErrorType(code),
+				"param":   nil,
+				"code":    errorType,
+			},
+		}
+	default:
+		return gin.H{
+			"error": gin.H{
+				"code":    code,
+				"message": message,
+				"status":  geminiErrorStatus(code),
+			},
+		}
+	}
+}
+
+// anthropicErrorType Anthropic 错误类型
+func anthropicErrorType(code int) string {
+	switch code {
+	case http.StatusBadRequest:
+		return "invalid_request_error"
+	case http.StatusUnauthorized:
+		return "authentication_error"
+	case http.StatusForbidden:
+		return "permission_error"
+	case http.StatusNotFound:
+		return "not_found_error"
+	case http.StatusRequestEntityTooLarge:
+		return "request_too_large"
+	case http.StatusTooManyRequests:
+		return "rate_limit_error"
+	case http.StatusServiceUnavailable, 529:
+		return "overloaded_error"
+	default:
+		if code < 500 {
+			return "invalid_request_error"
+		}
+		return "api_error"
+	}
+}
+
+// openAIErrorType OpenAI 错误类型
+func openAIErrorType(code int) string {
+	switch code {
+	case http.StatusUnauthorized:
+		return "authentication_error"
+	case http.StatusForbidden:
+		return "permission_error"
+	case http.StatusNotFound:
+		return "not_found_error"
+	case http.StatusTooManyRequests:
+		return "rate_limit_error"
+	default:
+		if code < 500 {
+			return "invalid_request_error"
+		}
+		return "server_error"
+	}
+}
+
+// geminiErrorStatus Gemini（Google API）错误状态
+func geminiErrorStatus(code int) string {
+	switch code {
+	case http.StatusBadRequest, http.StatusRequestEntityTooLarge:
+		return "INVALID_ARGUMENT"
+	case http.StatusUnauthorized:
+		return "UNAUTHENTICATED"
+	case http.StatusForbidden:
+		return "PERMISSION_DENIED"
+	case http.StatusNotFound:
+		return "NOT_FOUND"
+	case http.StatusTooManyRequests:
+		return "RESOURCE_EXHAUSTED"
+	case http.StatusServiceUnavailable, http.StatusBadGateway:
+		return "UNAVAILABLE"
+	case http.StatusGatewayTimeout:
+		return "DEADLINE_EXCEEDED"
+	default:
+		if code < 500 {
+			return "FAILED_PRECONDITION"
+		}
+		return "INTERNAL"
+	}
+}
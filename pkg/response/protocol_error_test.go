@@ -0,0 +1,199 @@
+package response
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestDetectProtocol(t *testing.T) {
+	cases := []struct {
+		path string
+		want Protocol
+	}{
+		{"/claude/v1/messages", ProtocolAnthropic},
+		{"/openai/v1/chat/completions", ProtocolOpenAI},
+		{"/responses", ProtocolOpenAI},
+		{"/v1/responses", ProtocolOpenAI},
+		{"/gemini/v1beta/models/gemini-pro:generateContent", ProtocolGemini},
+		{"/api/v1/accounts", ProtocolGeneric},
+		{"/claude", ProtocolGeneric},
+		{"", ProtocolGeneric},
+	}
+	for _, tc := range cases {
+		if got := DetectProtocol(tc.path); got != tc.want {
+			t.Errorf("DetectProtocol(%q) = %s, want %s", tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestProtocolErrorShapes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cases := []struct {
+		name      string
+		path      string
+		requestID string
+		code      int
+		errorType string
+		want      map[string]interface{}
+	}{
+		{
+			name: "anthropic", path: "/claude/v1/messages", requestID: "req-1",
+			code: http.StatusTooManyRequests, errorType: "rate_limit",
+			want: map[string]interface{}{
+				"type":       "error",
+				"error":      map[string]interface{}{"type": "rate_limit_error", "message": "slow down"},
+				"request_id": "req-1",
+			},
+		},
+		{
+			name: "anthropic without request id", path: "/claude/v1/messages",
+			code: 529, errorType: "overloaded",
+			want: map[string]interface{}{
+				"type":  "error",
+				"error": map[string]interface{}{"type": "overloaded_error", "message": "slow down"},
+			},
+		},
+		{
+			name: "openai", path: "/openai/v1/chat/completions", requestID: "req-2",
+			code: http.StatusUnauthorized, errorType: "invalid_api_key",
+			want: map[string]interface{}{
+				"error": map[string]interface{}{
+					"message": "slow down",
+					"type":    "authentication_error",
+					"param":   nil,
+					"code":    "invalid_api_key",
+				},
+			},
+		},
+		{
+			name: "openai responses", path: "/v1/responses",
+			code: http.StatusBadGateway, errorType: "upstream_error",
+			want: map[string]interface{}{
+				"error": map[string]interface{}{
+					"message": "slow down",
+					"type":    "server_error",
+					"param":   nil,
+					"code":    "upstream_error",
+				},
+			},
+		},
+		{
+			name: "gemini", path: "/gemini/v1beta/models/gemini-pro:generateContent",
+			code: http.StatusTooManyRequests, errorType: "rate_limit",
+			want: map[string]interface{}{
+				"error": map[string]interface{}{
+					"code":    float64(http.StatusTooManyRequests),
+					"message": "slow down",
+					"status":  "RESOURCE_EXHAUSTED",
+				},
+			},
+		},
+		{
+			name: "generic", path: "/api/v1/accounts",
+			code: http.StatusForbidden, errorType: "forbidden",
+			want: map[string]interface{}{
+				"code":    float64(http.StatusForbidden),
+				"message": "slow down",
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, tc.path, nil)
+		if tc.requestID != "" {
+			c.Set("request_id", tc.requestID)
+		}
+
+		ProtocolError(c, tc.code, tc.errorType, "slow down", "")
+
+		if w.Code != tc.code {
+			t.Errorf("%s: status = %d, want %d", tc.name, w.Code, tc.code)
+		}
+		var got map[string]interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("%s: invalid json %q: %v", tc.name, w.Body.String(), err)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: body = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestProtocolErrorTypeMapping(t *testing.T) {
+	cases := []struct {
+		code      int
+		anthropic string
+		openAI    string
+		gemini    string
+	}{
+		{http.StatusBadRequest, "invalid_request_error", "invalid_request_error", "INVALID_ARGUMENT"},
+		{http.StatusUnauthorized, "authentication_error", "authentication_error", "UNAUTHENTICATED"},
+		{http.StatusForbidden, "permission_error", "permission_error", "PERMISSION_DENIED"},
+		{http.StatusNotFound, "not_found_error", "not_found_error", "NOT_FOUND"},
+		{http.StatusRequestEntityTooLarge, "request_too_large", "invalid_request_error", "INVALID_ARGUMENT"},
+		{http.StatusConflict, "invalid_request_error", "invalid_request_error", "FAILED_PRECONDITION"},
+		{http.StatusTooManyRequests, "rate_limit_error", "rate_limit_error", "RESOURCE_EXHAUSTED"},
+		{http.StatusInternalServerError, "api_error", "server_error", "INTERNAL"},
+		{http.StatusBadGateway, "api_error", "server_error", "UNAVAILABLE"},
+		{http.StatusServiceUnavailable, "overloaded_error", "server_error", "UNAVAILABLE"},
+		{http.StatusGatewayTimeout, "api_error", "server_error", "DEADLINE_EXCEEDED"},
+		{529, "overloaded_error", "server_error", "INTERNAL"},
+	}
+	for _, tc := range cases {
+		if got := anthropicErrorType(tc.code); got != tc.anthropic {
+			t.Errorf("anthropicErrorType(%d) = %s, want %s", tc.code, got, tc.anthropic)
+		}
+		if got := openAIErrorType(tc.code); got != tc.openAI {
+			t.Errorf("openAIErrorType(%d) = %s, want %s", tc.code, got, tc.openAI)
+		}
+		if got := geminiErrorStatus(tc.code); got != tc.gemini {
+			t.Errorf("geminiErrorStatus(%d) = %s, want %s", tc.code, got, tc.gemini)
+		}
+	}
+}
+
+func TestProtocolStreamError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cases := []struct {
+		path string
+		want string
+	}{
+		{
+			"/claude/v1/messages",
+			"event: error\ndata: {\"error\":{\"message\":\"upstream failed\",\"type\":\"api_error\"},\"request_id\":\"req-1\",\"type\":\"error\"}\n\n",
+		},
+		{
+			"/openai/v1/chat/completions",
+			"data: {\"error\":{\"code\":\"upstream_error\",\"message\":\"upstream failed\",\"param\":null,\"type\":\"server_error\"}}\n\n",
+		},
+		{
+			"/gemini/v1/chat",
+			"data: {\"error\":{\"code\":502,\"message\":\"upstream failed\",\"status\":\"UNAVAILABLE\"}}\n\n",
+		},
+		{
+			"/api/v1/replay",
+			"data: {\"code\":502,\"message\":\"upstream failed\"}\n\n",
+		},
+	}
+	for _, tc := range cases {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, tc.path, nil)
+		c.Set("request_id", "req-1")
+
+		var out bytes.Buffer
+		ProtocolStreamError(c, &out, http.StatusBadGateway, "upstream_error", "upstream failed", "")
+		if out.String() != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.path, out.String(), tc.want)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+/*
+ * 文件作用：文本压缩/解压工具
+ * 负责功能：
+ *   - gzip + base64 压缩长文本（带前缀，可存入文本列）
+ *   - 按前缀识别并解压
+ */
+package utils
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"io"
+	"strings"
+)
+
+const compressedPrefix = "gz:"
+
+// 小于该长度的文本不压缩（压缩收益不足以抵消 base64 膨胀）
+const compressMinLength = 1024
+
+// IsCompressed 判断是否为已压缩值
+func IsCompressed(value string) bool {
+	return strings.HasPrefix(value, compressedPrefix)
+}
+
+// CompressText 压缩文本，返回 "gz:" + base64(gzip(value))
+// 空值、短文本、已压缩值或压缩后没有变小时原样返回
+func CompressText(value string) string {
+	if len(value) < compressMinLength || IsCompressed(value) {
+		return value
+	}
+	var buf bytes.Buffer
+	zw, _ := gzip.NewWriterLevel(&buf, gzip.BestSpeed)
+	if _, err := zw.Write([]byte(value)); err != nil {
+		return value
+	}
+	if err := zw.Close(); err != nil {
+		return value
+	}
+	compressed := compressedPrefix + base64.StdEncoding.EncodeToString(buf.Bytes())
+	if len(compressed) >= len(value) {
+		return value
+	}
+	return compressed
+}
+
+// DecompressText 解压 CompressText 的结果（非压缩值原样返回，解压失败也原样返回）
+func DecompressText(value string) string {
+	if !IsCompressed(value) {
+		return value
+	}
+	data, err := base64.StdEncoding.DecodeString(value[len(compressedPrefix):])
+	if err != nil {
+		return value
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return value
+	}
+	defer zr.Close()
+	plain, err := io.ReadAll(zr)
+	if err != nil {
+		return value
+	}
+	return string(plain)
+}
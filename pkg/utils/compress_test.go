@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompressText(t *testing.T) {
+	plain := strings.Repeat(`{"role":"user","content":"hello world"}`, 100)
+	compressed := CompressText(plain)
+	if !IsCompressed(compressed) || len(compressed) >= len(plain) {
+		t.Fatalf("expected compressed value, got %d bytes", len(compressed))
+	}
+	if CompressText(compressed) != compressed {
+		t.Fatal("compressing twice should be a no-op")
+	}
+	if got := DecompressText(compressed); got != plain {
+		t.Fatalf("round trip mismatch")
+	}
+}
+
+func TestCompressTextPassthrough(t *testing.T) {
+	if got := CompressText("short"); got != "short" {
+		t.Fatalf("short text should not be compressed, got %q", got)
+	}
+	if got := DecompressText("plain body"); got != "plain body" {
+		t.Fatalf("plain text should pass through, got %q", got)
+	}
+	if got := DecompressText("gz:not-base64!"); got != "gz:not-base64!" {
+		t.Fatalf("invalid data should pass through, got %q", got)
+	}
+}
@@ -0,0 +1,122 @@
+/*
+ * 文件作用：基于口令的数据加密/解密工具
+ * 负责功能：
+ *   - scrypt 派生密钥 + AES-GCM 加密（用于导出的账户凭证包等离线文件）
+ *   - 与部署密钥无关，可在不同部署间迁移
+ */
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// scrypt 默认参数（交互式场景推荐值）
+const (
+	passphraseKDF    = "scrypt"
+	passphraseN      = 1 << 15
+	passphraseR      = 8
+	passphraseP      = 1
+	passphraseKeyLen = 32
+	passphraseSalt   = 16
+)
+
+// ErrWrongPassphrase 口令错误或数据被篡改
+var ErrWrongPassphrase = errors.New("口令错误或数据已损坏")
+
+// PassphraseEnvelope 口令加密后的数据信封
+type PassphraseEnvelope struct {
+	KDF        string `json:"kdf"`
+	N          int    `json:"n"`
+	R          int    `json:"r"`
+	P          int    `json:"p"`
+	Salt       string `json:"salt"`       // base64
+	Nonce      string `json:"nonce"`      // base64
+	Ciphertext string `json:"ciphertext"` // base64
+}
+
+// EncryptWithPassphrase 使用口令加密数据
+func EncryptWithPassphrase(plain []byte, passphrase string) (*PassphraseEnvelope, error) {
+	if passphrase == "" {
+		return nil, errors.New("口令不能为空")
+	}
+
+	salt := make([]byte, passphraseSalt)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+	gcm, err := passphraseCipher(passphrase, salt, passphraseN, passphraseR, passphraseP)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return &PassphraseEnvelope{
+		KDF:        passphraseKDF,
+		N:          passphraseN,
+		R:          passphraseR,
+		P:          passphraseP,
+		Salt:       base64.StdEncoding.EncodeToString(salt),
+		Nonce:      base64.StdEncoding.EncodeToString(nonce),
+		Ciphertext: base64.StdEncoding.EncodeToString(gcm.Seal(nil, nonce, plain, nil)),
+	}, nil
+}
+
+// DecryptWithPassphrase 使用口令解密数据
+func DecryptWithPassphrase(env *PassphraseEnvelope, passphrase string) ([]byte, error) {
+	if env == nil {
+		return nil, errors.New("加密数据为空")
+	}
+	if env.KDF != passphraseKDF {
+		return nil, fmt.Errorf("不支持的密钥派生算法: %s", env.KDF)
+	}
+
+	salt, err := base64.StdEncoding.DecodeString(env.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("salt 格式无效: %w", err)
+	}
+	nonce, err := base64.StdEncoding.DecodeString(env.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("nonce 格式无效: %w", err)
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(env.Ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("密文格式无效: %w", err)
+	}
+
+	gcm, err := passphraseCipher(passphrase, salt, env.N, env.R, env.P)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != gcm.NonceSize() {
+		return nil, errors.New("nonce 长度无效")
+	}
+	plain, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	return plain, nil
+}
+
+// passphraseCipher 由口令派生 AES-GCM 实例
+func passphraseCipher(passphrase string, salt []byte, n, r, p int) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, n, r, p, passphraseKeyLen)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
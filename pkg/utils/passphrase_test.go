@@ -0,0 +1,33 @@
+package utils
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncryptDecryptWithPassphrase(t *testing.T) {
+	plain := []byte(`{"accounts":[{"name":"a","session_key":"sk-ant-sid01-xxx"}]}`)
+
+	env, err := EncryptWithPassphrase(plain, "correct horse")
+	if err != nil {
+		t.Fatalf("EncryptWithPassphrase error: %v", err)
+	}
+	if bytes.Contains([]byte(env.Ciphertext), []byte("session_key")) {
+		t.Fatalf("ciphertext should not contain plaintext")
+	}
+
+	decrypted, err := DecryptWithPassphrase(env, "correct horse")
+	if err != nil {
+		t.Fatalf("DecryptWithPassphrase error: %v", err)
+	}
+	if !bytes.Equal(decrypted, plain) {
+		t.Fatalf("expected %q, got %q", plain, decrypted)
+	}
+
+	if _, err := DecryptWithPassphrase(env, "wrong"); err != ErrWrongPassphrase {
+		t.Fatalf("expected ErrWrongPassphrase, got %v", err)
+	}
+	if _, err := EncryptWithPassphrase(plain, ""); err == nil {
+		t.Fatalf("expected error for empty passphrase")
+	}
+}